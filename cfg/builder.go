@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Builder builds a Config for programs which configure gcsfuse, e.g. a CSI
+// driver, the same way gcsfuse builds it from its flags and config file: the
+// params which aren't set keep their default values, and the Config is
+// validated and rationalized.
+//
+//	c, err := cfg.NewBuilder().
+//		WithImplicitDirs(true).
+//		WithFileCache("/tmp/cache", 1024).
+//		Set("metadata-cache.ttl-secs", 60).
+//		Build()
+//
+// Params are set by their config path, which is the path of the param in the
+// config file. The errors are reported by Build.
+type Builder struct {
+	v    *viper.Viper
+	keys []string
+	errs []error
+}
+
+// NewBuilder returns a Builder of a Config with the default values.
+func NewBuilder() *Builder {
+	b := &Builder{v: viper.New()}
+
+	flagSet := pflag.NewFlagSet("gcsfuse", pflag.ContinueOnError)
+	if err := BuildFlagSet(flagSet); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("error while declaring flags: %w", err))
+		return b
+	}
+	if err := BindFlags(b.v, flagSet); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("error while binding flags: %w", err))
+		return b
+	}
+	b.keys = b.v.AllKeys()
+
+	return b
+}
+
+// Set sets the param at the given config path, e.g. "metadata-cache.ttl-secs",
+// to value. The value is converted to the type of the param the same way as
+// the values in the config file are.
+func (b *Builder) Set(configPath string, value any) *Builder {
+	if !slices.Contains(b.keys, configPath) {
+		b.errs = append(b.errs, fmt.Errorf("unknown config path: %q", configPath))
+		return b
+	}
+
+	b.v.Set(configPath, value)
+	return b
+}
+
+// WithFuseOptions sets the mount options, e.g. "ro" or "allow_other".
+func (b *Builder) WithFuseOptions(options ...string) *Builder {
+	return b.Set("file-system.fuse-options", options)
+}
+
+// WithImplicitDirs sets whether directories are implicitly defined by the
+// objects in them.
+func (b *Builder) WithImplicitDirs(implicitDirs bool) *Builder {
+	return b.Set("implicit-dirs", implicitDirs)
+}
+
+// WithOnlyDir mounts only the given directory of the bucket.
+func (b *Builder) WithOnlyDir(dir string) *Builder {
+	return b.Set("only-dir", dir)
+}
+
+// WithOwner sets the UID and GID owning all the inodes.
+func (b *Builder) WithOwner(uid, gid int64) *Builder {
+	return b.Set("file-system.uid", uid).Set("file-system.gid", gid)
+}
+
+// WithKeyFile sets the JSON key file used to authenticate with GCS.
+func (b *Builder) WithKeyFile(path string) *Builder {
+	return b.Set("gcs-auth.key-file", path)
+}
+
+// WithFileCache enables the file cache in dir, with at most maxSizeMB MiB of
+// files, or no limit if -1.
+func (b *Builder) WithFileCache(dir string, maxSizeMB int64) *Builder {
+	return b.Set("cache-dir", dir).Set("file-cache.max-size-mb", maxSizeMB)
+}
+
+// WithMetadataCacheTTLSecs sets how long the metadata of files and directories
+// is cached for, or forever if -1.
+func (b *Builder) WithMetadataCacheTTLSecs(ttlSecs int64) *Builder {
+	return b.Set("metadata-cache.ttl-secs", ttlSecs)
+}
+
+// Build returns the Config, or the errors encountered while building and
+// validating it.
+func (b *Builder) Build() (*Config, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+
+	var c Config
+	err := b.v.Unmarshal(&c, viper.DecodeHook(DecodeHook()), func(decoderConfig *mapstructure.DecoderConfig) {
+		decoderConfig.TagName = "yaml"
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateConfig(b.v, &c); err != nil {
+		return nil, err
+	}
+	if err = Rationalize(b.v, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
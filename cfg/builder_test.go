@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder_Defaults(t *testing.T) {
+	c, err := NewBuilder().Build()
+
+	require.NoError(t, err)
+	assert.False(t, c.ImplicitDirs)
+	assert.Equal(t, int64(-1), c.FileSystem.Uid)
+	assert.Equal(t, Octal(0644), c.FileSystem.FileMode)
+	assert.Equal(t, int64(64), c.Write.BlockSizeMb)
+	assert.Equal(t, int64(60), c.MetadataCache.TtlSecs)
+}
+
+func TestBuilder_Set(t *testing.T) {
+	c, err := NewBuilder().
+		WithImplicitDirs(true).
+		WithOnlyDir("data").
+		WithOwner(1000, 1001).
+		WithFuseOptions("ro", "allow_other").
+		WithFileCache("/tmp/cache", 1024).
+		WithMetadataCacheTTLSecs(-1).
+		Set("file-system.file-mode", "600").
+		Set("gcs-connection.sequential-read-size-mb", 100).
+		Build()
+
+	require.NoError(t, err)
+	assert.True(t, c.ImplicitDirs)
+	assert.Equal(t, "data", c.OnlyDir)
+	assert.Equal(t, int64(1000), c.FileSystem.Uid)
+	assert.Equal(t, int64(1001), c.FileSystem.Gid)
+	assert.Equal(t, []string{"ro", "allow_other"}, c.FileSystem.FuseOptions)
+	assert.Equal(t, ResolvedPath("/tmp/cache"), c.CacheDir)
+	assert.Equal(t, int64(1024), c.FileCache.MaxSizeMb)
+	assert.Equal(t, Octal(0600), c.FileSystem.FileMode)
+	assert.Equal(t, int64(100), c.GcsConnection.SequentialReadSizeMb)
+	// The config is rationalized.
+	assert.Equal(t, int64(maxSupportedTTLInSeconds), c.MetadataCache.TtlSecs)
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *Builder
+	}{
+		{
+			name:    "unknown_config_path",
+			builder: NewBuilder().Set("implicit-directories", true),
+		},
+		{
+			name:    "wrong_type",
+			builder: NewBuilder().Set("file-system.uid", "root"),
+		},
+		{
+			name:    "invalid_value",
+			builder: NewBuilder().Set("gcs-connection.sequential-read-size-mb", 2000),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := tc.builder.Build()
+
+			assert.Error(t, err)
+			assert.Nil(t, c)
+		})
+	}
+}
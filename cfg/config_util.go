@@ -16,7 +16,14 @@ package cfg
 
 import (
 	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -55,3 +62,198 @@ func ListCacheTTLSecsToDuration(secs int64) time.Duration {
 func IsMetricsEnabled(c *MetricsConfig) bool {
 	return c.CloudMetricsExportIntervalSecs > 0 || c.PrometheusPort > 0
 }
+
+// IsReadOnlyMount returns true if the file system is mounted read-only, i.e.
+// with "-o ro".
+func IsReadOnlyMount(mountConfig *Config) bool {
+	for _, o := range mountConfig.FileSystem.FuseOptions {
+		for _, opt := range strings.Split(o, ",") {
+			if strings.TrimSpace(opt) == "ro" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaxLogFilesSizeMb returns the most disk space in MiB that the log files can
+// take with their rotation config, 0 if the logs aren't written to a file, or
+// -1 if it's unbounded since all the backups are retained.
+func MaxLogFilesSizeMb(c *LoggingConfig) int64 {
+	if !LogsToFile(c) {
+		return 0
+	}
+	if c.LogRotate.BackupFileCount == 0 {
+		return -1
+	}
+	return c.LogRotate.MaxFileSizeMb * (c.LogRotate.BackupFileCount + 1)
+}
+
+// LogsToFile returns true if the logs are written to logging.file-path.
+func LogsToFile(c *LoggingConfig) bool {
+	if c.FilePath == "" {
+		return false
+	}
+	if len(c.Sinks) == 0 {
+		return true
+	}
+	sinks, err := ParseLogSinks(c.Sinks, c.Severity)
+	return err == nil && slices.ContainsFunc(sinks, func(s LogSink) bool {
+		return s.Destination == LogSinkFile
+	})
+}
+
+// LogSink is a destination of the logs, to which the logs of Severity or
+// above are written.
+type LogSink struct {
+	Destination string
+	Severity    LogSeverity
+}
+
+// ParseLogSinks parses the logging.sinks config of the form
+// "<destination>[:<severity>]", the severity being defaultSeverity when
+// omitted. Each destination may only appear once.
+func ParseLogSinks(sinks []string, defaultSeverity LogSeverity) ([]LogSink, error) {
+	destinations := []string{LogSinkStdout, LogSinkFile, LogSinkSyslog, LogSinkJournald}
+	var parsed []LogSink
+	for _, s := range sinks {
+		destination, severity, hasSeverity := strings.Cut(s, ":")
+		sink := LogSink{
+			Destination: strings.ToLower(strings.TrimSpace(destination)),
+			Severity:    defaultSeverity,
+		}
+		if !slices.Contains(destinations, sink.Destination) {
+			return nil, fmt.Errorf("invalid destination in log sink %q: should be one of %v", s, destinations)
+		}
+		if slices.ContainsFunc(parsed, func(p LogSink) bool { return p.Destination == sink.Destination }) {
+			return nil, fmt.Errorf("invalid log sink %q: the destination %s is already used", s, sink.Destination)
+		}
+		if hasSeverity {
+			if err := sink.Severity.UnmarshalText([]byte(strings.TrimSpace(severity))); err != nil {
+				return nil, fmt.Errorf("invalid severity in log sink %q: %w", s, err)
+			}
+		}
+		parsed = append(parsed, sink)
+	}
+	return parsed, nil
+}
+
+// ObjectCreationRule sets the storage class and the custom time of objects
+// newly created under Prefix.
+type ObjectCreationRule struct {
+	Prefix       string
+	StorageClass string
+	// TTL is added to the creation time of the object to get its custom time.
+	// Zero means that the custom time is not set.
+	TTL time.Duration
+}
+
+// ParseObjectCreationRules parses rules of the form
+// "<prefix>:<storage-class>[:<ttl>]", where either the storage class or the
+// ttl may be empty but not both.
+func ParseObjectCreationRules(rules []string) ([]ObjectCreationRule, error) {
+	storageClasses := []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"}
+	var parsed []ObjectCreationRule
+	for _, r := range rules {
+		parts := strings.Split(r, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid object creation rule %q: expected <prefix>:<storage-class>[:<ttl>]", r)
+		}
+
+		rule := ObjectCreationRule{
+			Prefix:       parts[0],
+			StorageClass: strings.ToUpper(parts[1]),
+		}
+		if rule.StorageClass != "" && !slices.Contains(storageClasses, rule.StorageClass) {
+			return nil, fmt.Errorf("invalid storage class in object creation rule %q: should be one of %v", r, storageClasses)
+		}
+		if len(parts) == 3 && parts[2] != "" {
+			ttl, err := time.ParseDuration(parts[2])
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("invalid ttl in object creation rule %q: should be a positive duration", r)
+			}
+			rule.TTL = ttl
+		}
+		if rule.StorageClass == "" && rule.TTL == 0 {
+			return nil, fmt.Errorf("invalid object creation rule %q: either storage class or ttl should be set", r)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// ParseProxyURL parses the proxy-url config, returning nil if it's empty.
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	if proxyURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy-url %q: %w", proxyURL, err)
+	}
+	schemes := []string{"http", "https", "socks5", "socks5h"}
+	if !slices.Contains(schemes, u.Scheme) {
+		return nil, fmt.Errorf("invalid proxy-url %q: the scheme should be one of %v", proxyURL, schemes)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy-url %q: the host is missing", proxyURL)
+	}
+	return u, nil
+}
+
+// ParseProxyHeaders parses the proxy-headers config of the form
+// "<name>: <value>".
+func ParseProxyHeaders(headers []string) (http.Header, error) {
+	parsed := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid proxy header %q: expected <name>: <value>", h)
+		}
+		parsed.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value))
+	}
+	return parsed, nil
+}
+
+// ParseTracingOpSamplingRatios parses the
+// monitoring.experimental-tracing-op-sampling-ratios config of the form
+// "<op>:<ratio>", with the ratio between 0 and 1.
+func ParseTracingOpSamplingRatios(ratios []string) (map[string]float64, error) {
+	parsed := make(map[string]float64)
+	for _, r := range ratios {
+		op, ratio, ok := strings.Cut(r, ":")
+		if !ok || op == "" {
+			return nil, fmt.Errorf("invalid tracing op sampling ratio %q: expected <op>:<ratio>", r)
+		}
+		f, err := strconv.ParseFloat(ratio, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid ratio in tracing op sampling ratio %q: should be between 0 and 1", r)
+		}
+		parsed[op] = f
+	}
+	return parsed, nil
+}
+
+// ParseUidFileModes parses the uid-file-modes config of the form
+// "<uid>:<mode>", with the mode in octal.
+func ParseUidFileModes(modes []string) (map[uint32]os.FileMode, error) {
+	parsed := make(map[uint32]os.FileMode)
+	for _, m := range modes {
+		uid, mode, ok := strings.Cut(m, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid uid file mode %q: expected <uid>:<mode>", m)
+		}
+		u, err := strconv.ParseUint(uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid in uid file mode %q: %w", m, err)
+		}
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || perm > uint64(os.ModePerm) {
+			return nil, fmt.Errorf("invalid mode in uid file mode %q: should be octal permission bits, e.g. 0640", m)
+		}
+		parsed[uint32(u)] = os.FileMode(perm)
+	}
+	return parsed, nil
+}
@@ -39,11 +39,77 @@ const (
 	ExperimentalMetadataPrefetchOnMountSynchronous = "sync"
 	// ExperimentalMetadataPrefetchOnMountAsynchronous is the prefetch-mode where mounting is marked complete once prefetch has started.
 	ExperimentalMetadataPrefetchOnMountAsynchronous = "async"
+	// ExperimentalMetadataPrefetchOnMountManifest is the prefetch-mode where the stat-cache is loaded from a manifest of the objects of the bucket.
+	ExperimentalMetadataPrefetchOnMountManifest = "manifest"
+)
+
+const (
+	// ClobberActionFail fails the flush of a clobbered file.
+	ClobberActionFail = "fail"
+	// ClobberActionOverwrite overwrites the generation clobbering the flushed
+	// file.
+	ClobberActionOverwrite = "overwrite"
+	// ClobberActionConflictCopy writes the contents of a clobbered file to a
+	// conflict copy next to it, and fails the flush.
+	ClobberActionConflictCopy = "conflict-copy"
+)
+
+const (
+	// UnsupportedFsActionWarn logs a warning when cache-dir or temp-dir is on
+	// an unsupported file system.
+	UnsupportedFsActionWarn = "warn"
+	// UnsupportedFsActionRefuse fails the mount when cache-dir or temp-dir is
+	// on an unsupported file system.
+	UnsupportedFsActionRefuse = "refuse"
+)
+
+const (
+	// NestedMountActionRefuse fails the mount when the mount point is nested
+	// in another FUSE mount or in a directory of gcsfuse.
+	NestedMountActionRefuse = "refuse"
+	// NestedMountActionWarn logs a warning when the mount point is nested in
+	// another FUSE mount or in a directory of gcsfuse.
+	NestedMountActionWarn = "warn"
+)
+
+const (
+	// OfflineModeOff keeps calling GCS while it's unreachable.
+	OfflineModeOff = "off"
+	// OfflineModeServeCache serves the cached metadata and contents while GCS
+	// is unreachable, and fails the other ops fast.
+	OfflineModeServeCache = "serve-cache"
+)
+
+const (
+	// LogSinkStdout writes the logs to stdout.
+	LogSinkStdout = "stdout"
+	// LogSinkFile writes the logs to logging.file-path, rotated with
+	// logging.log-rotate.
+	LogSinkFile = "file"
+	// LogSinkSyslog writes the logs to syslog, with the priority of their
+	// severity.
+	LogSinkSyslog = "syslog"
+	// LogSinkJournald writes the logs to journald with its native protocol,
+	// with the priority of their severity.
+	LogSinkJournald = "journald"
 )
 
 const (
 	// maxSequentialReadSizeMb is the max value supported by sequential-read-size-mb flag.
 	maxSequentialReadSizeMB = 1024
+
+	// maxReadCoalesceWindowKb is the max value supported by
+	// read-coalesce-window-kb flag, the max size of the random reads.
+	maxReadCoalesceWindowKb = 8192
+
+	// maxReadColumnarFooterKb is the max value supported by
+	// read-columnar-footer-kb flag, the size of the footers kept in memory.
+	maxReadColumnarFooterKb = 65536
+
+	// maxReadWorkerProcesses is the max value supported by
+	// experimental-read-worker-processes flag, well above the processes needed
+	// to saturate the NICs of the largest machines.
+	maxReadWorkerProcesses = 64
 )
 
 const (
@@ -78,8 +144,16 @@ const (
 	MetadataCacheTTLConfigKey = "metadata-cache.ttl-secs"
 	// StatCacheMaxSizeConfigKey is the Viper configuration key for the maximum
 	//size of the metadata stat cache in megabytes.
-	StatCacheMaxSizeConfigKey      = "metadata-cache.stat-cache-max-size-mb"
+	StatCacheMaxSizeConfigKey = "metadata-cache.stat-cache-max-size-mb"
+	// MaxReadAheadKbConfigKey is the Viper configuration key for the most KiB
+	// the kernel reads ahead.
+	MaxReadAheadKbConfigKey = "file-system.max-read-ahead-kb"
+	// LogFormatConfigKey is the Viper configuration key for the log format.
+	LogFormatConfigKey             = "logging.format"
 	maxSupportedStatCacheMaxSizeMB = util.MaxMiBsInUint64
+	// maxSingleShotUploadThresholdKb is the default chunk size of resumable
+	// uploads, beyond which a single request gains nothing.
+	maxSingleShotUploadThresholdKb = 16 * 1024
 )
 
 // CacheUtilMinimumAlignSizeForWriting is the minimum buffer size used for memory-aligned
@@ -26,5 +26,6 @@ func DefaultLoggingConfig() LoggingConfig {
 			Compress:        true,
 			MaxFileSizeMb:   512,
 		},
+		RecentErrorsCount: 100,
 	}
 }
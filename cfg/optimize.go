@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
+)
+
+const (
+	sequentialReadSizeConfigKey       = "gcs-connection.sequential-read-size-mb"
+	parallelDownloadsPerFileConfigKey = "file-cache.parallel-downloads-per-file"
+	maxParallelDownloadsConfigKey     = "file-cache.max-parallel-downloads"
+	globalMaxBlocksConfigKey          = "write.global-max-blocks"
+	statCacheCapacityConfigKey        = "metadata-cache.deprecated-stat-cache-capacity"
+	typeCacheMaxSizeConfigKey         = "metadata-cache.type-cache-max-size-mb"
+
+	// Machines with less available memory than this are considered memory
+	// constrained: fewer and smaller requests are kept in flight.
+	lowMemoryThresholdBytes = 4 << 30
+	// Machines with a NIC at least this fast can sustain more parallel
+	// downloads than the defaults allow.
+	highBandwidthNICSpeedMbps = 50000
+
+	lowMemorySequentialReadSizeMb         = 50
+	lowMemoryParallelDownloadsPerFile     = 4
+	lowMemoryMaxParallelDownloads         = 8
+	highBandwidthParallelDownloadsPerFile = 32
+	// Fraction of the available memory which may be used by streaming-write
+	// buffers across all files.
+	streamingWriteMemoryFraction = 4
+	// Fraction of the memory limit which may be used by the stat cache, i.e.
+	// 2%.
+	statCacheMemoryFraction = 50
+	// Fraction of the memory limit which may be used by the type cache of each
+	// directory, i.e. 0.1%, up to maxTunedTypeCacheMaxSizeMb.
+	typeCacheMemoryFraction    = 1024
+	maxTunedTypeCacheMaxSizeMb = 16
+)
+
+// ApplyMachineProfile tunes the defaults of read-ahead, parallel downloads,
+// streaming-write buffers and metadata caches to the resources available on the
+// machine, or in the container gcsfuse runs in. Params
+// explicitly set by the user are left untouched. It returns the tuned params
+// in the form "<config-path>=<value>", in a deterministic order.
+func ApplyMachineProfile(v isSet, c *Config, p machineprofile.Profile) []string {
+	var tuned []string
+	setInt := func(key string, field *int64, value int64) {
+		if v.IsSet(key) || *field == value {
+			return
+		}
+		*field = value
+		tuned = append(tuned, fmt.Sprintf("%s=%d", key, value))
+	}
+
+	lowMemory := p.AvailableMemoryBytes > 0 && p.AvailableMemoryBytes < lowMemoryThresholdBytes
+	highBandwidth := p.NICSpeedMbps >= highBandwidthNICSpeedMbps
+
+	switch {
+	case lowMemory:
+		// Each in-flight read and download holds buffers, so keep fewer of them
+		// around rather than risking the OOM killer.
+		setInt(sequentialReadSizeConfigKey, &c.GcsConnection.SequentialReadSizeMb, lowMemorySequentialReadSizeMb)
+		setInt(parallelDownloadsPerFileConfigKey, &c.FileCache.ParallelDownloadsPerFile, lowMemoryParallelDownloadsPerFile)
+		setInt(maxParallelDownloadsConfigKey, &c.FileCache.MaxParallelDownloads, lowMemoryMaxParallelDownloads)
+	case highBandwidth:
+		setInt(parallelDownloadsPerFileConfigKey, &c.FileCache.ParallelDownloadsPerFile, highBandwidthParallelDownloadsPerFile)
+		if p.NumCPU > 0 {
+			setInt(maxParallelDownloadsConfigKey, &c.FileCache.MaxParallelDownloads, max(c.FileCache.MaxParallelDownloads, int64(4*p.NumCPU)))
+		}
+	}
+
+	// By default, streaming writes may use an unbounded number of blocks. Bound
+	// them by the memory actually available.
+	if p.AvailableMemoryBytes > 0 && c.Write.GlobalMaxBlocks == -1 && c.Write.BlockSizeMb > 0 {
+		blocks := int64(p.AvailableMemoryBytes/streamingWriteMemoryFraction) / (c.Write.BlockSizeMb << 20)
+		setInt(globalMaxBlocksConfigKey, &c.Write.GlobalMaxBlocks, max(2, blocks))
+	}
+
+	// Size the metadata caches by the memory limit rather than by the fixed
+	// defaults, which get small sidecar containers OOM-killed and underuse
+	// large machines.
+	if p.MemoryLimitBytes > 0 {
+		if !v.IsSet(StatCacheMaxSizeConfigKey) && !v.IsSet(statCacheCapacityConfigKey) {
+			setInt(StatCacheMaxSizeConfigKey, &c.MetadataCache.StatCacheMaxSizeMb, max(1, int64(p.MemoryLimitBytes/statCacheMemoryFraction)>>20))
+			// Unless stat-cache-max-size-mb is set, Rationalize resolves the
+			// stat cache size from the deprecated stat-cache-capacity.
+			c.MetadataCache.DeprecatedStatCacheCapacity = (c.MetadataCache.StatCacheMaxSizeMb << 20) / int64(AverageSizeOfPositiveStatCacheEntry+AverageSizeOfNegativeStatCacheEntry)
+		}
+		typeCacheMb := min(maxTunedTypeCacheMaxSizeMb, max(1, int64(p.MemoryLimitBytes/typeCacheMemoryFraction)>>20))
+		setInt(typeCacheMaxSizeConfigKey, &c.MetadataCache.TypeCacheMaxSizeMb, typeCacheMb)
+	}
+
+	return tuned
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfg
+
+import (
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
+	"github.com/stretchr/testify/assert"
+)
+
+type keysSet map[string]bool
+
+func (k keysSet) IsSet(key string) bool {
+	return k[key]
+}
+
+func defaultTunableConfig() *Config {
+	return &Config{
+		GcsConnection: GcsConnectionConfig{SequentialReadSizeMb: 200},
+		FileCache: FileCacheConfig{
+			ParallelDownloadsPerFile: 16,
+			MaxParallelDownloads:     16,
+		},
+		Write: WriteConfig{
+			BlockSizeMb:      64,
+			GlobalMaxBlocks:  -1,
+			MaxBlocksPerFile: -1,
+		},
+	}
+}
+
+func TestApplyMachineProfile(t *testing.T) {
+	testCases := []struct {
+		name                         string
+		profile                      machineprofile.Profile
+		isSet                        keysSet
+		expectedSequentialReadSizeMb int64
+		expectedParallelDownloads    int64
+		expectedMaxParallelDownloads int64
+		expectedGlobalMaxBlocks      int64
+		expectedTuned                []string
+	}{
+		{
+			name:                         "unknown_resources",
+			profile:                      machineprofile.Profile{},
+			expectedSequentialReadSizeMb: 200,
+			expectedParallelDownloads:    16,
+			expectedMaxParallelDownloads: 16,
+			expectedGlobalMaxBlocks:      -1,
+		},
+		{
+			name:                         "low_memory",
+			profile:                      machineprofile.Profile{AvailableMemoryBytes: 2 << 30, NumCPU: 2},
+			expectedSequentialReadSizeMb: 50,
+			expectedParallelDownloads:    4,
+			expectedMaxParallelDownloads: 8,
+			expectedGlobalMaxBlocks:      8,
+			expectedTuned: []string{
+				"gcs-connection.sequential-read-size-mb=50",
+				"file-cache.parallel-downloads-per-file=4",
+				"file-cache.max-parallel-downloads=8",
+				"write.global-max-blocks=8",
+			},
+		},
+		{
+			name:                         "low_memory_with_user_set_values",
+			profile:                      machineprofile.Profile{AvailableMemoryBytes: 2 << 30, NumCPU: 2},
+			isSet:                        keysSet{sequentialReadSizeConfigKey: true, globalMaxBlocksConfigKey: true},
+			expectedSequentialReadSizeMb: 200,
+			expectedParallelDownloads:    4,
+			expectedMaxParallelDownloads: 8,
+			expectedGlobalMaxBlocks:      -1,
+			expectedTuned: []string{
+				"file-cache.parallel-downloads-per-file=4",
+				"file-cache.max-parallel-downloads=8",
+			},
+		},
+		{
+			name:                         "high_bandwidth",
+			profile:                      machineprofile.Profile{AvailableMemoryBytes: 256 << 30, NumCPU: 64, NICSpeedMbps: 100000},
+			expectedSequentialReadSizeMb: 200,
+			expectedParallelDownloads:    32,
+			expectedMaxParallelDownloads: 256,
+			expectedGlobalMaxBlocks:      1024,
+			expectedTuned: []string{
+				"file-cache.parallel-downloads-per-file=32",
+				"file-cache.max-parallel-downloads=256",
+				"write.global-max-blocks=1024",
+			},
+		},
+		{
+			name:                         "tiny_memory_keeps_minimum_blocks",
+			profile:                      machineprofile.Profile{AvailableMemoryBytes: 128 << 20},
+			expectedSequentialReadSizeMb: 50,
+			expectedParallelDownloads:    4,
+			expectedMaxParallelDownloads: 8,
+			expectedGlobalMaxBlocks:      2,
+			expectedTuned: []string{
+				"gcs-connection.sequential-read-size-mb=50",
+				"file-cache.parallel-downloads-per-file=4",
+				"file-cache.max-parallel-downloads=8",
+				"write.global-max-blocks=2",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := defaultTunableConfig()
+
+			tuned := ApplyMachineProfile(tc.isSet, c, tc.profile)
+
+			assert.Equal(t, tc.expectedSequentialReadSizeMb, c.GcsConnection.SequentialReadSizeMb)
+			assert.Equal(t, tc.expectedParallelDownloads, c.FileCache.ParallelDownloadsPerFile)
+			assert.Equal(t, tc.expectedMaxParallelDownloads, c.FileCache.MaxParallelDownloads)
+			assert.Equal(t, tc.expectedGlobalMaxBlocks, c.Write.GlobalMaxBlocks)
+			assert.Equal(t, tc.expectedTuned, tuned)
+		})
+	}
+}
+
+func TestApplyMachineProfile_SizesMetadataCachesByMemoryLimit(t *testing.T) {
+	testCases := []struct {
+		name                       string
+		memoryLimitBytes           uint64
+		isSet                      keysSet
+		expectedStatCacheMaxSizeMb int64
+		expectedTypeCacheMaxSizeMb int64
+	}{
+		{
+			name:                       "unknown_limit",
+			expectedStatCacheMaxSizeMb: 32,
+			expectedTypeCacheMaxSizeMb: 4,
+		},
+		{
+			name:                       "small_container",
+			memoryLimitBytes:           512 << 20,
+			expectedStatCacheMaxSizeMb: 10,
+			expectedTypeCacheMaxSizeMb: 1,
+		},
+		{
+			name:                       "large_machine",
+			memoryLimitBytes:           256 << 30,
+			expectedStatCacheMaxSizeMb: 5242,
+			expectedTypeCacheMaxSizeMb: 16,
+		},
+		{
+			name:                       "user_set_stat_cache_capacity",
+			memoryLimitBytes:           512 << 20,
+			isSet:                      keysSet{statCacheCapacityConfigKey: true},
+			expectedStatCacheMaxSizeMb: 32,
+			expectedTypeCacheMaxSizeMb: 1,
+		},
+		{
+			name:                       "user_set_sizes",
+			memoryLimitBytes:           512 << 20,
+			isSet:                      keysSet{StatCacheMaxSizeConfigKey: true, typeCacheMaxSizeConfigKey: true},
+			expectedStatCacheMaxSizeMb: 32,
+			expectedTypeCacheMaxSizeMb: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := defaultTunableConfig()
+			c.MetadataCache = MetadataCacheConfig{
+				DeprecatedStatCacheCapacity: 20460,
+				StatCacheMaxSizeMb:          32,
+				TypeCacheMaxSizeMb:          4,
+			}
+
+			ApplyMachineProfile(tc.isSet, c, machineprofile.Profile{MemoryLimitBytes: tc.memoryLimitBytes})
+			// The tuned stat cache size must survive the resolution of the
+			// deprecated stat-cache-capacity.
+			assert.NoError(t, Rationalize(tc.isSet, c))
+
+			assert.Equal(t, tc.expectedStatCacheMaxSizeMb, c.MetadataCache.StatCacheMaxSizeMb)
+			assert.Equal(t, tc.expectedTypeCacheMaxSizeMb, c.MetadataCache.TypeCacheMaxSizeMb)
+		})
+	}
+}
@@ -15,8 +15,11 @@
 package cfg
 
 import (
+	"fmt"
 	"math"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 )
@@ -84,6 +87,29 @@ func resolveStreamingWriteConfig(w *WriteConfig) {
 	if w.GlobalMaxBlocks < w.MaxBlocksPerFile {
 		w.MaxBlocksPerFile = w.GlobalMaxBlocks
 	}
+
+	// The global number of blocks is only kept as a way to cap the memory of
+	// the blocks.
+	if w.MaxDirtyMb == -1 {
+		w.MaxDirtyMb = 0
+		if w.GlobalMaxBlocks != math.MaxInt64 {
+			w.MaxDirtyMb = w.GlobalMaxBlocks * w.BlockSizeMb
+		}
+	}
+}
+
+// resolveContainerConfig applies the behaviors bundled in container mode,
+// unless the logging has been set explicitly.
+func resolveContainerConfig(v isSet, c *Config) {
+	if !c.Container {
+		return
+	}
+
+	c.Foreground = true
+	c.FileSystem.HandleSigterm = true
+	if !v.IsSet(LogFormatConfigKey) {
+		c.Logging.Format = "json"
+	}
 }
 
 func resolveCloudMetricsUploadIntervalSecs(m *MetricsConfig) {
@@ -92,6 +118,40 @@ func resolveCloudMetricsUploadIntervalSecs(m *MetricsConfig) {
 	}
 }
 
+// resolveReadAheadOption removes the read_ahead_kb mount option, which the
+// kernel doesn't know, from the fuse options, and uses it as the
+// max-read-ahead-kb unless the latter has been set.
+func resolveReadAheadOption(v isSet, c *FileSystemConfig) error {
+	const option = "read_ahead_kb="
+	var found bool
+	fuseOptions := []string{}
+	for _, o := range c.FuseOptions {
+		var kept []string
+		for _, opt := range strings.Split(o, ",") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(opt), option)
+			if !ok {
+				kept = append(kept, opt)
+				continue
+			}
+			found = true
+			kb, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid mount option %q: %w", opt, err)
+			}
+			if !v.IsSet(MaxReadAheadKbConfigKey) {
+				c.MaxReadAheadKb = kb
+			}
+		}
+		if len(kept) > 0 {
+			fuseOptions = append(fuseOptions, strings.Join(kept, ","))
+		}
+	}
+	if found {
+		c.FuseOptions = fuseOptions
+	}
+	return nil
+}
+
 // Rationalize updates the config fields based on the values of other fields.
 func Rationalize(v isSet, c *Config) error {
 	var err error
@@ -107,7 +167,12 @@ func Rationalize(v isSet, c *Config) error {
 		c.Logging.Severity = "TRACE"
 	}
 
+	if err = resolveReadAheadOption(v, &c.FileSystem); err != nil {
+		return err
+	}
+
 	resolveStreamingWriteConfig(&c.Write)
+	resolveContainerConfig(v, c)
 	resolveMetadataCacheTTL(v, &c.MetadataCache)
 	resolveStatCacheMaxSizeMB(v, &c.MetadataCache)
 	resolveCloudMetricsUploadIntervalSecs(&c.Metrics)
@@ -315,6 +315,7 @@ func TestRationalize_WriteConfig(t *testing.T) {
 		config                   *Config
 		expectedCreateEmptyFile  bool
 		expectedMaxBlocksPerFile int64
+		expectedMaxDirtyMb       int64
 	}{
 		{
 			name: "valid_config_streaming_writes_enabled",
@@ -325,10 +326,12 @@ func TestRationalize_WriteConfig(t *testing.T) {
 					ExperimentalEnableStreamingWrites: true,
 					GlobalMaxBlocks:                   -1,
 					MaxBlocksPerFile:                  -1,
+					MaxDirtyMb:                        -1,
 				},
 			},
 			expectedCreateEmptyFile:  false,
 			expectedMaxBlocksPerFile: math.MaxInt64,
+			expectedMaxDirtyMb:       0,
 		},
 		{
 			name: "valid_config_global_max_blocks_less_than_blocks_per_file",
@@ -339,10 +342,12 @@ func TestRationalize_WriteConfig(t *testing.T) {
 					ExperimentalEnableStreamingWrites: true,
 					GlobalMaxBlocks:                   10,
 					MaxBlocksPerFile:                  20,
+					MaxDirtyMb:                        -1,
 				},
 			},
 			expectedCreateEmptyFile:  false,
 			expectedMaxBlocksPerFile: 10,
+			expectedMaxDirtyMb:       100,
 		},
 		{
 			name: "valid_config_global_max_blocks_more_than_blocks_per_file",
@@ -353,10 +358,12 @@ func TestRationalize_WriteConfig(t *testing.T) {
 					ExperimentalEnableStreamingWrites: true,
 					GlobalMaxBlocks:                   20,
 					MaxBlocksPerFile:                  10,
+					MaxDirtyMb:                        64,
 				},
 			},
 			expectedCreateEmptyFile:  false,
 			expectedMaxBlocksPerFile: 10,
+			expectedMaxDirtyMb:       64,
 		},
 	}
 
@@ -367,6 +374,7 @@ func TestRationalize_WriteConfig(t *testing.T) {
 			if assert.NoError(t, actualErr) {
 				assert.Equal(t, tc.expectedCreateEmptyFile, tc.config.Write.CreateEmptyFile)
 				assert.Equal(t, tc.expectedMaxBlocksPerFile, tc.config.Write.MaxBlocksPerFile)
+				assert.Equal(t, tc.expectedMaxDirtyMb, tc.config.Write.MaxDirtyMb)
 			}
 		})
 	}
@@ -422,3 +430,94 @@ func TestRationalizeMetricsConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestRationalizeContainerConfig(t *testing.T) {
+	testCases := []struct {
+		name               string
+		flags              flagSet
+		config             *Config
+		expectedFormat     string
+		expectedForeground bool
+	}{
+		{
+			name:               "container_mode",
+			flags:              flagSet{},
+			config:             &Config{Container: true, Logging: LoggingConfig{Format: "text"}},
+			expectedFormat:     "json",
+			expectedForeground: true,
+		},
+		{
+			name:               "container_mode_with_explicit_log_format",
+			flags:              flagSet{LogFormatConfigKey: true},
+			config:             &Config{Container: true, Logging: LoggingConfig{Format: "text"}},
+			expectedFormat:     "text",
+			expectedForeground: true,
+		},
+		{
+			name:               "not_container_mode",
+			flags:              flagSet{},
+			config:             &Config{Logging: LoggingConfig{Format: "text"}},
+			expectedFormat:     "text",
+			expectedForeground: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.FileSystem.HandleSigterm = false
+
+			if assert.NoError(t, Rationalize(tc.flags, tc.config)) {
+				assert.Equal(t, tc.expectedFormat, tc.config.Logging.Format)
+				assert.Equal(t, tc.expectedForeground, tc.config.Foreground)
+				assert.Equal(t, tc.config.Container, tc.config.FileSystem.HandleSigterm)
+			}
+		})
+	}
+}
+
+func TestRationalizeReadAheadOption(t *testing.T) {
+	testCases := []struct {
+		name                string
+		flags               flagSet
+		config              *Config
+		expectedFuseOptions []string
+		expectedReadAheadKb int64
+	}{
+		{
+			name:                "read_ahead_kb_option",
+			flags:               flagSet{},
+			config:              &Config{FileSystem: FileSystemConfig{FuseOptions: []string{"ro,read_ahead_kb=1024", "allow_other"}}},
+			expectedFuseOptions: []string{"ro", "allow_other"},
+			expectedReadAheadKb: 1024,
+		},
+		{
+			name:                "max_read_ahead_kb_set",
+			flags:               flagSet{MaxReadAheadKbConfigKey: true},
+			config:              &Config{FileSystem: FileSystemConfig{FuseOptions: []string{"read_ahead_kb=1024"}, MaxReadAheadKb: 2048}},
+			expectedFuseOptions: []string{},
+			expectedReadAheadKb: 2048,
+		},
+		{
+			name:                "no_read_ahead_kb_option",
+			flags:               flagSet{},
+			config:              &Config{FileSystem: FileSystemConfig{FuseOptions: []string{"ro"}}},
+			expectedFuseOptions: []string{"ro"},
+			expectedReadAheadKb: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if assert.NoError(t, Rationalize(tc.flags, tc.config)) {
+				assert.Equal(t, tc.expectedFuseOptions, tc.config.FileSystem.FuseOptions)
+				assert.Equal(t, tc.expectedReadAheadKb, tc.config.FileSystem.MaxReadAheadKb)
+			}
+		})
+	}
+}
+
+func TestRationalizeReadAheadOptionInvalid(t *testing.T) {
+	c := &Config{FileSystem: FileSystemConfig{FuseOptions: []string{"read_ahead_kb=lots"}}}
+
+	assert.ErrorContains(t, Rationalize(flagSet{}, c), "read_ahead_kb")
+}
@@ -39,19 +39,20 @@ func (o Octal) MarshalText() ([]byte, error) {
 	return []byte(strconv.FormatInt(int64(o), 8)), nil
 }
 
-// Protocol is the datatype that specifies the type of connection: http1/http2/grpc.
+// Protocol is the datatype that specifies the type of connection: http1/http2/http3/grpc.
 type Protocol string
 
 const (
 	HTTP1 = "http1"
 	HTTP2 = "http2"
+	HTTP3 = "http3"
 	GRPC  = "grpc"
 )
 
 func (p *Protocol) UnmarshalText(text []byte) error {
 	txtStr := string(text)
 	protocol := strings.ToLower(txtStr)
-	v := []string{"http1", "http2", "grpc"}
+	v := []string{"http1", "http2", "http3", "grpc"}
 	if !slices.Contains(v, protocol) {
 		return fmt.Errorf("invalid protocol value: %s. It can only accept values in the list: %v", txtStr, v)
 	}
@@ -19,14 +19,29 @@ import (
 	"fmt"
 
 	"math"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cpuset"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/ignore"
 )
 
 const (
-	FileCacheMaxSizeMBInvalidValueError       = "the value of max-size-mb for file-cache can't be less than -1"
-	MaxParallelDownloadsInvalidValueError     = "the value of max-parallel-downloads for file-cache can't be less than -1"
-	ParallelDownloadsPerFileInvalidValueError = "the value of parallel-downloads-per-file for file-cache can't be less than 1"
-	DownloadChunkSizeMBInvalidValueError      = "the value of download-chunk-size-mb for file-cache can't be less than 1"
-	MaxParallelDownloadsCantBeZeroError       = "the value of max-parallel-downloads for file-cache must not be 0 when enable-parallel-downloads is true"
+	FileCacheMaxSizeMBInvalidValueError           = "the value of max-size-mb for file-cache can't be less than -1"
+	MaxParallelDownloadsInvalidValueError         = "the value of max-parallel-downloads for file-cache can't be less than -1"
+	ParallelDownloadsPerFileInvalidValueError     = "the value of parallel-downloads-per-file for file-cache can't be less than 1"
+	DownloadChunkSizeMBInvalidValueError          = "the value of download-chunk-size-mb for file-cache can't be less than 1"
+	MaxParallelDownloadsCantBeZeroError           = "the value of max-parallel-downloads for file-cache must not be 0 when enable-parallel-downloads is true"
+	ScrubIntervalSecsInvalidValueError            = "the value of scrub-interval-secs for file-cache can't be less than 0"
+	MemoryTierSizeMBInvalidValueError             = "the value of memory-tier-size-mb for file-cache can't be less than 0"
+	MaxObjectSizeMBInvalidValueError              = "the value of max-object-size-mb for file-cache can't be less than -1"
+	MinObjectSizeMBInvalidValueError              = "the value of min-object-size-mb for file-cache can't be less than 0 or more than max-object-size-mb"
+	MaxDiskErrorsInvalidValueError                = "the value of max-disk-errors for file-cache can't be less than 0"
+	SlowDiskIOThresholdMsInvalidValueError        = "the value of slow-disk-io-threshold-ms for file-cache can't be less than 0"
+	SmallFilePrefetchMaxSizeKBInvalidValueError   = "the value of small-file-prefetch-max-size-kb for file-cache can't be less than 0"
+	SmallFilePrefetchParallelismInvalidValueError = "the value of small-file-prefetch-parallelism for file-cache can't be less than 1 when small-file-prefetch-max-size-kb is set"
 )
 
 func isValidLogRotateConfig(config *LogRotateLoggingConfig) error {
@@ -36,6 +51,49 @@ func isValidLogRotateConfig(config *LogRotateLoggingConfig) error {
 	if config.BackupFileCount < 0 {
 		return fmt.Errorf("backup-file-count should be 0 (to retain all backup files) or a positive value")
 	}
+	if config.Interval < 0 {
+		return fmt.Errorf("interval should be 0 (to rotate only by size) or a positive duration")
+	}
+	return nil
+}
+
+func isValidSlowOpsConfig(config *SlowOpsLoggingConfig) error {
+	if config.Threshold < 0 {
+		return fmt.Errorf("threshold should be 0 (to disable it) or a positive duration")
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return fmt.Errorf("sample-rate should be between 0 and 1")
+	}
+	return nil
+}
+
+func isValidLogSinks(c *LoggingConfig) error {
+	sinks, err := ParseLogSinks(c.Sinks, c.Severity)
+	if err != nil {
+		return err
+	}
+	for _, s := range sinks {
+		if s.Destination == LogSinkFile && c.FilePath == "" {
+			return fmt.Errorf("the log sink %s needs log-file to be set", LogSinkFile)
+		}
+	}
+	return nil
+}
+
+func isValidDiskBudgetConfig(config *Config) error {
+	if config.DiskBudgetMb < 0 {
+		return fmt.Errorf("disk-budget-mb can't be negative")
+	}
+	if config.DiskBudgetMb == 0 {
+		return nil
+	}
+	logFilesSizeMb := MaxLogFilesSizeMb(&config.Logging)
+	if logFilesSizeMb < 0 {
+		return fmt.Errorf("the log files must keep a bounded number of backups, i.e. a positive log-rotate backup-file-count, within disk-budget-mb")
+	}
+	if logFilesSizeMb >= config.DiskBudgetMb {
+		return fmt.Errorf("the log files can take %d MiB with their rotation config, which leaves no room within disk-budget-mb %d", logFilesSizeMb, config.DiskBudgetMb)
+	}
 	return nil
 }
 
@@ -63,6 +121,13 @@ func isValidParallelDownloadConfig(config *Config) error {
 	return nil
 }
 
+func isValidPrefetchTraceConfig(config *Config) error {
+	if config.FileCache.PrefetchTrace != "" && !IsFileCacheEnabled(config) {
+		return errors.New("file cache should be enabled to prefetch an access trace")
+	}
+	return nil
+}
+
 func isValidFileCacheConfig(config *FileCacheConfig) error {
 	if config.MaxSizeMb < -1 {
 		return errors.New(FileCacheMaxSizeMBInvalidValueError)
@@ -76,6 +141,35 @@ func isValidFileCacheConfig(config *FileCacheConfig) error {
 	if config.DownloadChunkSizeMb < 1 {
 		return errors.New(DownloadChunkSizeMBInvalidValueError)
 	}
+	if config.ScrubIntervalSecs < 0 {
+		return errors.New(ScrubIntervalSecsInvalidValueError)
+	}
+	if config.MemoryTierSizeMb < 0 {
+		return errors.New(MemoryTierSizeMBInvalidValueError)
+	}
+	if config.MaxObjectSizeMb < -1 {
+		return errors.New(MaxObjectSizeMBInvalidValueError)
+	}
+	if config.MinObjectSizeMb < 0 || (config.MaxObjectSizeMb != -1 && config.MinObjectSizeMb > config.MaxObjectSizeMb) {
+		return errors.New(MinObjectSizeMBInvalidValueError)
+	}
+	if config.MaxDiskErrors < 0 {
+		return errors.New(MaxDiskErrorsInvalidValueError)
+	}
+	if config.SlowDiskIoThresholdMs < 0 {
+		return errors.New(SlowDiskIOThresholdMsInvalidValueError)
+	}
+	if config.SmallFilePrefetchMaxSizeKb < 0 {
+		return errors.New(SmallFilePrefetchMaxSizeKBInvalidValueError)
+	}
+	if config.SmallFilePrefetchMaxSizeKb > 0 && config.SmallFilePrefetchParallelism < 1 {
+		return errors.New(SmallFilePrefetchParallelismInvalidValueError)
+	}
+	for _, pattern := range slices.Concat(config.IncludePatterns, config.ExcludePatterns) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file-cache pattern %q: %w", pattern, err)
+		}
+	}
 
 	return nil
 }
@@ -84,13 +178,25 @@ func IsValidExperimentalMetadataPrefetchOnMount(mode string) error {
 	switch mode {
 	case ExperimentalMetadataPrefetchOnMountDisabled,
 		ExperimentalMetadataPrefetchOnMountSynchronous,
-		ExperimentalMetadataPrefetchOnMountAsynchronous:
+		ExperimentalMetadataPrefetchOnMountAsynchronous,
+		ExperimentalMetadataPrefetchOnMountManifest:
 		return nil
 	default:
-		return fmt.Errorf("unsupported metadata-prefix-mode: \"%s\"; supported values: disabled, sync, async", mode)
+		return fmt.Errorf("unsupported metadata-prefix-mode: \"%s\"; supported values: disabled, sync, async, manifest", mode)
 	}
 }
 
+func isValidMetadataPrefetchManifest(c *MetadataCacheConfig) error {
+	isManifestMode := c.ExperimentalMetadataPrefetchOnMount == ExperimentalMetadataPrefetchOnMountManifest
+	if isManifestMode && c.ExperimentalMetadataPrefetchManifest == "" {
+		return fmt.Errorf("experimental-metadata-prefetch-manifest must be set with experimental-metadata-prefetch-on-mount %q", ExperimentalMetadataPrefetchOnMountManifest)
+	}
+	if !isManifestMode && c.ExperimentalMetadataPrefetchManifest != "" {
+		return fmt.Errorf("experimental-metadata-prefetch-manifest is only supported with experimental-metadata-prefetch-on-mount %q", ExperimentalMetadataPrefetchOnMountManifest)
+	}
+	return nil
+}
+
 func isValidSequentialReadSizeMB(size int64) error {
 	if size < 1 || size > maxSequentialReadSizeMB {
 		return fmt.Errorf("sequential-read-size-mb should be between 1 and %d", maxSequentialReadSizeMB)
@@ -98,6 +204,198 @@ func isValidSequentialReadSizeMB(size int64) error {
 	return nil
 }
 
+func isValidMetadataOpsBurst(burst int64) error {
+	if burst < 0 {
+		return fmt.Errorf("invalid value of limit-metadata-ops-burst: %d; can't be negative", burst)
+	}
+	return nil
+}
+
+func isValidProxyConfig(c *GcsConnectionConfig) error {
+	proxyURL, err := ParseProxyURL(c.ProxyUrl)
+	if err != nil {
+		return err
+	}
+	if proxyURL != nil && c.ClientProtocol == HTTP3 {
+		return fmt.Errorf("proxy-url isn't supported with client-protocol http3")
+	}
+	_, err = ParseProxyHeaders(c.ProxyHeaders)
+	return err
+}
+
+func isValidMaxReadStreamsPerObject(streams int64) error {
+	if streams < 0 {
+		return fmt.Errorf("max-read-streams-per-object should be 0 (for no limit) or a positive number")
+	}
+	return nil
+}
+
+func isValidReadCoalescingConfig(c *ReadConfig) error {
+	if c.CoalesceWindowKb < 0 || c.CoalesceWindowKb > maxReadCoalesceWindowKb {
+		return fmt.Errorf("read-coalesce-window-kb should be between 0 and %d", maxReadCoalesceWindowKb)
+	}
+	if c.CoalesceWindowMs < 0 {
+		return fmt.Errorf("read-coalesce-window-ms can't be less than 0")
+	}
+	if c.ColumnarFooterKb < 0 || c.ColumnarFooterKb > maxReadColumnarFooterKb {
+		return fmt.Errorf("read-columnar-footer-kb should be between 0 and %d", maxReadColumnarFooterKb)
+	}
+	if c.MaxConcurrentReadsPerHandle < 0 {
+		return fmt.Errorf("max-concurrent-reads-per-handle can't be less than 0")
+	}
+	if c.ExperimentalWorkerProcesses < 0 || c.ExperimentalWorkerProcesses > maxReadWorkerProcesses {
+		return fmt.Errorf("experimental-read-worker-processes should be between 0 and %d", maxReadWorkerProcesses)
+	}
+	return nil
+}
+
+func isValidFuseDispatchConfig(c *FileSystemConfig) error {
+	if c.MaxBackground < 0 || c.MaxBackground > math.MaxUint16 {
+		return fmt.Errorf("max-background should be between 0 and %d", math.MaxUint16)
+	}
+	if c.MaxConcurrentOps < 0 {
+		return fmt.Errorf("max-concurrent-ops should be 0 (for no limit) or a positive number")
+	}
+	if c.MaxReadAheadKb < 0 {
+		return fmt.Errorf("max-read-ahead-kb should be 0 (for the default of the kernel) or a positive number")
+	}
+	return nil
+}
+
+func isValidStatfsSize(sizeMb int64) error {
+	if sizeMb < 0 {
+		return fmt.Errorf("statfs-size-mb should be 0 (for a practically unlimited size) or a positive number")
+	}
+	return nil
+}
+
+func isValidAutoUnmountConfig(c *FileSystemConfig) error {
+	if c.AutoUnmountAfter < 0 {
+		return fmt.Errorf("auto-unmount-after should be 0 (to never unmount) or a positive duration")
+	}
+	if c.AutoUnmountIdleAfter < 0 {
+		return fmt.Errorf("auto-unmount-idle-after should be 0 (to never unmount) or a positive duration")
+	}
+	if c.IdleTeardownAfter < 0 {
+		return fmt.Errorf("idle-teardown-after should be 0 (to never release the resources) or a positive duration")
+	}
+	return nil
+}
+
+func isValidTrashConfig(c *FileSystemConfig) error {
+	if slices.Contains(strings.Split(c.TrashDir, "/"), "..") {
+		return fmt.Errorf("trash-dir should be a directory in the mount, without ..")
+	}
+	if c.TrashTtl < 0 {
+		return fmt.Errorf("trash-ttl should be 0 (to keep the files) or a positive duration")
+	}
+	return nil
+}
+
+func isValidLifecycleHintWindow(window time.Duration) error {
+	if window < 0 {
+		return fmt.Errorf("lifecycle-hint-window should be 0 (to not read the lifecycle rules) or a positive duration")
+	}
+	return nil
+}
+
+func isValidIgnorePatterns(c *FileSystemConfig) error {
+	_, err := ignore.New(c.IgnorePatterns)
+	return err
+}
+
+func isValidPinPatterns(c *FileSystemConfig) error {
+	if _, err := ignore.New(c.PinPatterns); err != nil {
+		return fmt.Errorf("invalid pin-patterns: %w", err)
+	}
+	return nil
+}
+
+func isValidCrossBucketRenameLimit(c *FileSystemConfig) error {
+	if c.CrossBucketRenameLimitMb < 0 {
+		return fmt.Errorf("cross-bucket-rename-limit-mb should be 0 (to fail the renames across buckets with EXDEV) or a positive number")
+	}
+	return nil
+}
+
+func isValidDirEntriesConfig(c *FileSystemConfig) error {
+	if c.DirEntriesInitialCapacity < 0 {
+		return fmt.Errorf("dir-entries-initial-capacity should be 0 (to grow as needed) or a positive number")
+	}
+	if c.DirEntriesMaxCount < 0 {
+		return fmt.Errorf("dir-entries-max-count should be 0 (for no limit) or a positive number")
+	}
+	if c.DirEntriesMaxSizeMb < 0 {
+		return fmt.Errorf("dir-entries-max-size-mb should be 0 (for no limit) or a positive number")
+	}
+	return nil
+}
+
+func isValidClobberAction(config *FileSystemConfig) error {
+	switch config.ClobberAction {
+	// An unset action fails.
+	case "", ClobberActionFail, ClobberActionConflictCopy:
+		return nil
+	case ClobberActionOverwrite:
+		if config.StrictOverwrites {
+			return fmt.Errorf("clobber-action %q can't be used with strict-overwrites", config.ClobberAction)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported clobber-action: %q; supported values: fail, overwrite, conflict-copy", config.ClobberAction)
+	}
+}
+
+func isValidUnsupportedFsAction(action string) error {
+	switch action {
+	// An unset action warns.
+	case "", UnsupportedFsActionWarn, UnsupportedFsActionRefuse:
+		return nil
+	default:
+		return fmt.Errorf("unsupported unsupported-fs-action: %q; supported values: warn, refuse", action)
+	}
+}
+
+func isValidNestedMountAction(action string) error {
+	switch action {
+	// An unset action refuses.
+	case "", NestedMountActionRefuse, NestedMountActionWarn:
+		return nil
+	default:
+		return fmt.Errorf("unsupported nested-mount-action: %q; supported values: refuse, warn", action)
+	}
+}
+
+func isValidOfflineMode(mode string) error {
+	switch mode {
+	// An unset mode is off.
+	case "", OfflineModeOff, OfflineModeServeCache:
+		return nil
+	default:
+		return fmt.Errorf("unsupported offline-mode: %q; supported values: off, serve-cache", mode)
+	}
+}
+
+func isValidMaxConcurrentRequests(c *GcsConnectionConfig) error {
+	for flag, limit := range map[string]int64{
+		"max-concurrent-list-requests":  c.MaxConcurrentListRequests,
+		"max-concurrent-read-requests":  c.MaxConcurrentReadRequests,
+		"max-concurrent-stat-requests":  c.MaxConcurrentStatRequests,
+		"max-concurrent-write-requests": c.MaxConcurrentWriteRequests,
+	} {
+		if limit < 0 {
+			return fmt.Errorf("%s should be 0 (for no limit) or a positive number", flag)
+		}
+	}
+	if c.ReadMinSharePercent < 0 || c.ReadMinSharePercent >= 100 {
+		return fmt.Errorf("read-min-share-percent should be between 0 and 99")
+	}
+	if c.ReadMinSharePercent > 0 && c.MaxConcurrentReadRequests == 0 {
+		return fmt.Errorf("read-min-share-percent requires max-concurrent-read-requests")
+	}
+	return nil
+}
+
 // isTTLInSecsValid return nil error if ttlInSecs is valid.
 func isTTLInSecsValid(secs int64) error {
 	if secs < -1 {
@@ -142,6 +440,40 @@ func isValidMetadataCache(v isSet, c *MetadataCacheConfig) error {
 		}
 	}
 
+	// Validate list-cache-ttl-secs and list-cache-max-size-mb.
+	if err := isTTLInSecsValid(c.ListCacheTtlSecs); err != nil {
+		return fmt.Errorf("invalid list-cache-ttl-secs: %w", err)
+	}
+	if c.ListCacheTtlSecs != 0 && c.ListCacheMaxSizeMb < 1 {
+		return fmt.Errorf("the value of list-cache-max-size-mb for metadata-cache must be at least 1")
+	}
+
+	// Validate permission-denied-ttl-secs.
+	if c.PermissionDeniedTtlSecs < 0 || c.PermissionDeniedTtlSecs > maxSupportedTTLInSeconds {
+		return fmt.Errorf("the value of permission-denied-ttl-secs for metadata-cache must be between 0 and %d", maxSupportedTTLInSeconds)
+	}
+
+	// Validate ttl-jitter-percent.
+	if c.TtlJitterPercent < 0 || c.TtlJitterPercent > 100 {
+		return fmt.Errorf("the value of ttl-jitter-percent for metadata-cache must be between 0 and 100")
+	}
+
+	// Validate batch-refresh-threshold.
+	if c.BatchRefreshThreshold < 0 {
+		return fmt.Errorf("the value of batch-refresh-threshold for metadata-cache can't be less than 0")
+	}
+
+	// Validate the limits of the metadata prefetch.
+	if c.ExperimentalMetadataPrefetchMaxDurationSecs < 0 || c.ExperimentalMetadataPrefetchMaxDurationSecs > maxSupportedTTLInSeconds {
+		return fmt.Errorf("the value of experimental-metadata-prefetch-max-duration-secs for metadata-cache must be between 0 and %d", maxSupportedTTLInSeconds)
+	}
+	if c.ExperimentalMetadataPrefetchMaxEntries < 0 {
+		return fmt.Errorf("the value of experimental-metadata-prefetch-max-entries for metadata-cache can't be less than 0")
+	}
+	if c.ExperimentalMetadataPrefetchWorkers < 0 {
+		return fmt.Errorf("the value of experimental-metadata-prefetch-workers for metadata-cache can't be less than 0")
+	}
+
 	// [Deprecated] Validate stat-cache-capacity.
 	if c.DeprecatedStatCacheCapacity < 0 {
 		return fmt.Errorf("invalid value of stat-cache-capacity (%v), can't be less than 0", c.DeprecatedStatCacheCapacity)
@@ -164,6 +496,84 @@ func isValidWriteStreamingConfig(wc *WriteConfig) error {
 	if !(wc.GlobalMaxBlocks == -1 || wc.GlobalMaxBlocks >= 2) {
 		return fmt.Errorf("invalid value of write-global-max-blocks: %d; should be >=2 or -1 (for infinite)", wc.GlobalMaxBlocks)
 	}
+	if wc.SpillMemoryThresholdPercent < 0 || wc.SpillMemoryThresholdPercent > 100 {
+		return fmt.Errorf("invalid value of write-spill-memory-threshold-percent: %d; should be between 0 and 100", wc.SpillMemoryThresholdPercent)
+	}
+	if wc.MaxDirtyMb < -1 {
+		return fmt.Errorf("invalid value of write-max-dirty-mb: %d; should be >= 0 or -1", wc.MaxDirtyMb)
+	}
+	if wc.CoalesceSizeKb < 0 || wc.CoalesceSizeKb > wc.BlockSizeMb*1024 {
+		return fmt.Errorf("invalid value of write-coalesce-size-kb: %d; should be between 0 and write-block-size-mb times 1024", wc.CoalesceSizeKb)
+	}
+	return nil
+}
+
+func isValidWriteQuotaConfig(wc *WriteConfig) error {
+	if wc.QuotaMb < 0 {
+		return fmt.Errorf("write-quota-mb should be 0 (for no quota) or a positive number")
+	}
+	if wc.QuotaObjects < 0 {
+		return fmt.Errorf("write-quota-objects should be 0 (for no quota) or a positive number")
+	}
+	return nil
+}
+
+func isValidWriteLeaseConfig(wc *WriteConfig) error {
+	if wc.LeaseTtl < 0 {
+		return fmt.Errorf("write-lease-ttl should be 0 (to not lease the objects) or a positive duration")
+	}
+	return nil
+}
+
+func isValidSingleShotUploadConfig(wc *WriteConfig) error {
+	if wc.SingleShotUploadThresholdKb < 0 || wc.SingleShotUploadThresholdKb > maxSingleShotUploadThresholdKb {
+		return fmt.Errorf("write-single-shot-upload-threshold-kb should be between 0 and %d", maxSingleShotUploadThresholdKb)
+	}
+	return nil
+}
+
+func isValidAtomicCommitConfig(wc *WriteConfig) error {
+	if len(wc.AtomicCommitPrefixes) == 0 {
+		return nil
+	}
+
+	if wc.AtomicCommitSentinel == "" || strings.Contains(wc.AtomicCommitSentinel, "/") {
+		return fmt.Errorf("invalid value of write-atomic-commit-sentinel: %q; should be a non-empty file name", wc.AtomicCommitSentinel)
+	}
+	return nil
+}
+
+func isValidAuthConfig(ac *GcsAuthConfig) error {
+	var credentials []string
+	for _, c := range []struct {
+		name string
+		set  bool
+	}{
+		{"key-file", ac.KeyFile != ""},
+		{"credential-config-file", ac.CredentialConfigFile != ""},
+		{"external-credential-command", ac.ExternalCredentialCommand != ""},
+		{"token-url", ac.TokenUrl != ""},
+	} {
+		if c.set {
+			credentials = append(credentials, c.name)
+		}
+	}
+	// key-file takes precedence over token-url, so only the new ways of
+	// getting credentials are required to be used alone.
+	if len(credentials) > 1 && (ac.CredentialConfigFile != "" || ac.ExternalCredentialCommand != "") {
+		return fmt.Errorf("only one of %s can be specified", strings.Join(credentials, ", "))
+	}
+
+	if ac.ImpersonateServiceAccount != "" {
+		if ac.AnonymousAccess {
+			return fmt.Errorf("impersonate-service-account can't be specified with anonymous-access")
+		}
+		for _, sa := range strings.Split(ac.ImpersonateServiceAccount, ",") {
+			if !strings.Contains(sa, "@") {
+				return fmt.Errorf("invalid service account in impersonate-service-account: %q", sa)
+			}
+		}
+	}
 	return nil
 }
 
@@ -190,6 +600,9 @@ func isValidMetricsConfig(m *MetricsConfig) error {
 	if m.PrometheusPort > maxPortNumber {
 		return fmt.Errorf("prometheus-port must not be higher than the maximum allowed port number: %d but received: %d instead", maxPortNumber, m.PrometheusPort)
 	}
+	if m.MaxBucketNames < 0 {
+		return fmt.Errorf("metrics-max-bucket-names can't be negative: %d", m.MaxBucketNames)
+	}
 	return nil
 }
 
@@ -200,6 +613,13 @@ func isValidChunkTransferTimeoutForRetriesConfig(chunkTransferTimeoutSecs int64)
 	return nil
 }
 
+func isValidMaxConnectionRetryAttempts(attempts int64) error {
+	if attempts < 0 {
+		return fmt.Errorf("invalid value of max-connection-retry-attempts: %d; should be >= 0", attempts)
+	}
+	return nil
+}
+
 // ValidateConfig returns a non-nil error if the config is invalid.
 func ValidateConfig(v isSet, config *Config) error {
 	var err error
@@ -208,6 +628,18 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing log-rotate config: %w", err)
 	}
 
+	if err = isValidSlowOpsConfig(&config.Logging.SlowOps); err != nil {
+		return fmt.Errorf("error parsing slow-ops config: %w", err)
+	}
+
+	if config.Logging.RecentErrorsCount < 0 {
+		return fmt.Errorf("the value of recent-errors-count for logging can't be less than 0")
+	}
+
+	if err = isValidLogSinks(&config.Logging); err != nil {
+		return fmt.Errorf("error parsing logging config: %w", err)
+	}
+
 	if err = isValidURL(config.GcsConnection.CustomEndpoint); err != nil {
 		return fmt.Errorf("error parsing custom-endpoint config: %w", err)
 	}
@@ -220,14 +652,94 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing experimental-metadata-prefetch-on-mount: %w", err)
 	}
 
+	if err = isValidMetadataPrefetchManifest(&config.MetadataCache); err != nil {
+		return fmt.Errorf("error parsing experimental-metadata-prefetch-manifest: %w", err)
+	}
+
 	if err = isValidURL(config.GcsAuth.TokenUrl); err != nil {
 		return fmt.Errorf("error parsing token-url config: %w", err)
 	}
 
+	if err = isValidAuthConfig(&config.GcsAuth); err != nil {
+		return fmt.Errorf("error parsing gcs-auth config: %w", err)
+	}
+
 	if err = isValidSequentialReadSizeMB(config.GcsConnection.SequentialReadSizeMb); err != nil {
 		return fmt.Errorf("error parsing gcs-connection config: %w", err)
 	}
 
+	if err = isValidMaxReadStreamsPerObject(config.GcsConnection.MaxReadStreamsPerObject); err != nil {
+		return fmt.Errorf("error parsing gcs-connection config: %w", err)
+	}
+
+	if err = isValidReadCoalescingConfig(&config.Read); err != nil {
+		return fmt.Errorf("error parsing read config: %w", err)
+	}
+
+	if err = isValidMaxConcurrentRequests(&config.GcsConnection); err != nil {
+		return fmt.Errorf("error parsing gcs-connection config: %w", err)
+	}
+
+	if err = isValidMetadataOpsBurst(config.GcsConnection.LimitMetadataOpsBurst); err != nil {
+		return fmt.Errorf("error parsing gcs-connection config: %w", err)
+	}
+
+	if err = isValidProxyConfig(&config.GcsConnection); err != nil {
+		return fmt.Errorf("error parsing gcs-connection config: %w", err)
+	}
+
+	if err = isValidFuseDispatchConfig(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidClobberAction(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidUnsupportedFsAction(config.FileSystem.UnsupportedFsAction); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidNestedMountAction(config.FileSystem.NestedMountAction); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidOfflineMode(config.FileSystem.OfflineMode); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidStatfsSize(config.FileSystem.StatfsSizeMb); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidAutoUnmountConfig(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidTrashConfig(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidIgnorePatterns(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidPinPatterns(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidLifecycleHintWindow(config.FileSystem.LifecycleHintWindow); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidDirEntriesConfig(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if err = isValidCrossBucketRenameLimit(&config.FileSystem); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
 	if err = isValidKernelListCacheTTL(config.FileSystem.KernelListCacheTtlSecs); err != nil {
 		return fmt.Errorf("error parsing kernel-list-cache-ttl-secs config: %w", err)
 	}
@@ -240,6 +752,18 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing write config: %w", err)
 	}
 
+	if err = isValidWriteQuotaConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidWriteLeaseConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidSingleShotUploadConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
 	if err = isValidReadStallGcsRetriesConfig(&config.GcsRetries.ReadStall); err != nil {
 		return fmt.Errorf("error parsing read-stall-gcs-retries config: %w", err)
 	}
@@ -248,13 +772,55 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing chunk-transfer-timeout-secs config: %w", err)
 	}
 
+	if err = isValidMaxConnectionRetryAttempts(config.GcsRetries.MaxConnectionRetryAttempts); err != nil {
+		return fmt.Errorf("error parsing gcs-retries config: %w", err)
+	}
+
 	if err = isValidMetricsConfig(&config.Metrics); err != nil {
 		return fmt.Errorf("error parsing metrics config: %w", err)
 	}
 
+	if config.ContainerHealthPort < 0 || config.ContainerHealthPort > math.MaxUint16 {
+		return fmt.Errorf("container-health-port should be between 0 and %d", math.MaxUint16)
+	}
+
+	if config.CpuSet != "" {
+		if _, err = cpuset.Parse(config.CpuSet); err != nil {
+			return fmt.Errorf("error parsing cpu-set: %w", err)
+		}
+	}
+
+	if config.MemoryPressureThresholdPercent < 0 || config.MemoryPressureThresholdPercent > 100 {
+		return fmt.Errorf("memory-pressure-threshold-percent should be between 0 and 100")
+	}
+
+	if _, err = ParseUidFileModes(config.FileSystem.UidFileModes); err != nil {
+		return fmt.Errorf("error parsing file-system config: %w", err)
+	}
+
+	if _, err = ParseTracingOpSamplingRatios(config.Monitoring.ExperimentalTracingOpSamplingRatios); err != nil {
+		return fmt.Errorf("error parsing monitoring config: %w", err)
+	}
+
+	if _, err = ParseObjectCreationRules(config.Write.ObjectCreationRules); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidAtomicCommitConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
 	if err = isValidParallelDownloadConfig(config); err != nil {
 		return fmt.Errorf("error parsing parallel download config: %w", err)
 	}
 
+	if err = isValidPrefetchTraceConfig(config); err != nil {
+		return fmt.Errorf("error parsing prefetch-trace config: %w", err)
+	}
+
+	if err = isValidDiskBudgetConfig(config); err != nil {
+		return fmt.Errorf("error parsing disk-budget-mb config: %w", err)
+	}
+
 	return nil
 }
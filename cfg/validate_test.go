@@ -77,6 +77,41 @@ func TestValidateConfigSuccessful(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "disk budget with bounded log files",
+			config: &Config{
+				DiskBudgetMb: 1024,
+				Logging: LoggingConfig{
+					FilePath:  "/tmp/gcsfuse.log",
+					LogRotate: LogRotateLoggingConfig{BackupFileCount: 3, MaxFileSizeMb: 10},
+				},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "max concurrent requests per class",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb:       200,
+					MaxConcurrentListRequests:  4,
+					MaxConcurrentReadRequests:  64,
+					MaxConcurrentStatRequests:  16,
+					MaxConcurrentWriteRequests: 32,
+					ReadMinSharePercent:        25,
+				},
+			},
+		},
 		{
 			name: "experimental-metadata-prefetch-on-mount disabled",
 			config: &Config{
@@ -101,28 +136,1137 @@ func TestValidateConfigSuccessful(t *testing.T) {
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 200,
 				},
-			},
-		},
-		{
-			name: "experimental-metadata-prefetch-on-mount sync",
-			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+			},
+		},
+		{
+			name: "experimental-metadata-prefetch-on-mount sync",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "experimental-metadata-prefetch-on-mount manifest",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount:  "manifest",
+					ExperimentalMetadataPrefetchManifest: "/tmp/manifest.json",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "list cache enabled",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+					ListCacheTtlSecs:                    -1,
+					ListCacheMaxSizeMb:                  32,
+					PermissionDeniedTtlSecs:             5,
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "Valid Sequential read size MB",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 10,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "Valid Sequential read size MB",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 10,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "valid_kernel_list_cache_TTL",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 10,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 30},
+			},
+		},
+		{
+			name: "valid_parallel_download_config_with_file_cache_enabled",
+			config: &Config{
+				Logging:  LoggingConfig{LogRotate: validLogRotateConfig()},
+				CacheDir: "/some/valid/path",
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					EnableParallelDownloads:  true,
+					MaxParallelDownloads:     4,
+					ParallelDownloadsPerFile: 16,
+					MaxSizeMb:                -1,
+					WriteBufferSize:          4 * 1024 * 1024,
+				},
+				GcsConnection: GcsConnectionConfig{
+					CustomEndpoint:       "https://bing.com/search?q=dotnet",
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+			},
+		},
+		{
+			name: "valid_chunk_transfer_timeout_secs",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 10,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 30},
+				GcsRetries: GcsRetriesConfig{ChunkTransferTimeoutSecs: 15},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actualErr := ValidateConfig(&mockIsSet{}, tc.config)
+
+			assert.NoError(t, actualErr)
+		})
+	}
+}
+
+func TestValidateConfig_ErrorScenarios(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config *Config
+	}{
+		{
+			name: "Invalid Config due to invalid custom endpoint",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					CustomEndpoint:       "a_b://abc",
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "log_rotate_interval_negative",
+			config: &Config{
+				Logging: LoggingConfig{
+					LogRotate: LogRotateLoggingConfig{MaxFileSizeMb: 1, Interval: -time.Hour},
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "log_slow_op_sample_rate_above_one",
+			config: &Config{
+				Logging: LoggingConfig{
+					LogRotate: LogRotateLoggingConfig{MaxFileSizeMb: 1},
+					SlowOps:   SlowOpsLoggingConfig{Threshold: time.Second, SampleRate: 1.5},
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "Invalid experimental-metadata-prefetch-on-mount",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "a",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "experimental-metadata-prefetch-on-mount manifest without manifest",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "manifest",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "experimental-metadata-prefetch-manifest without manifest mode",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount:  "sync",
+					ExperimentalMetadataPrefetchManifest: "/tmp/manifest.json",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "Invalid Config due to invalid token URL",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsAuth: GcsAuthConfig{
+					TokenUrl: "a_b://abc",
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
+		{
+			name: "Sequential read size MB more than 1024 (max permissible value)",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 2048,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "Sequential read size MB less than 1 (min permissible value)",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 0,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "Negative max read streams per object",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb:    200,
+					MaxReadStreamsPerObject: -1,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "read_coalesce_window_kb_too_high",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					CoalesceWindowKb: 8193,
+				},
+			},
+		},
+		{
+			name: "read_columnar_footer_kb_too_high",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					ColumnarFooterKb: 65537,
+				},
+			},
+		},
+		{
+			name: "negative_read_columnar_footer_kb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					ColumnarFooterKb: -1,
+				},
+			},
+		},
+		{
+			name: "too_many_read_worker_processes",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					ExperimentalWorkerProcesses: 65,
+				},
+			},
+		},
+		{
+			name: "negative_read_coalesce_window_ms",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					CoalesceWindowMs: -1,
+				},
+			},
+		},
+		{
+			name: "container_health_port_too_high",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				ContainerHealthPort: 65536,
+			},
+		},
+		{
+			name: "cpu_set_invalid",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				CpuSet: "0-3,numa:1",
+			},
+		},
+		{
+			name: "clobber_action_invalid",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				FileSystem: FileSystemConfig{
+					ClobberAction: "merge",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "clobber_action_overwrite_with_strict_overwrites",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				FileSystem: FileSystemConfig{
+					ClobberAction:    "overwrite",
+					StrictOverwrites: true,
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "unsupported_fs_action_invalid",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				FileSystem: FileSystemConfig{
+					UnsupportedFsAction: "ignore",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "nested_mount_action_invalid",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				FileSystem: FileSystemConfig{
+					NestedMountAction: "ignore",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "offline_mode_invalid",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				FileSystem: FileSystemConfig{
+					OfflineMode: "serve-stale",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "memory_pressure_threshold_percent_too_high",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				MemoryPressureThresholdPercent: 101,
+			},
+		},
+		{
+			name: "max_background_too_high",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					MaxBackground: 65536,
+				},
+			},
+		},
+		{
+			name: "negative_max_concurrent_ops",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					MaxConcurrentOps: -1,
+				},
+			},
+		},
+		{
+			name: "negative_max_read_ahead_kb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					MaxReadAheadKb: -1,
+				},
+			},
+		},
+		{
+			name: "negative_statfs_size_mb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					StatfsSizeMb: -1,
+				},
+			},
+		},
+		{
+			name: "negative_auto_unmount_after",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					AutoUnmountAfter: -time.Minute,
+				},
+			},
+		},
+		{
+			name: "negative_auto_unmount_idle_after",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					AutoUnmountIdleAfter: -time.Minute,
+				},
+			},
+		},
+		{
+			name: "negative_idle_teardown_after",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					IdleTeardownAfter: -time.Minute,
+				},
+			},
+		},
+		{
+			name: "negative_dir_entries_initial_capacity",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					DirEntriesInitialCapacity: -1,
+				},
+			},
+		},
+		{
+			name: "negative_dir_entries_max_count",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					DirEntriesMaxCount: -1,
+				},
+			},
+		},
+		{
+			name: "negative_dir_entries_max_size_mb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					DirEntriesMaxSizeMb: -1,
+				},
+			},
+		},
+		{
+			name: "negative_cross_bucket_rename_limit_mb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					CrossBucketRenameLimitMb: -1,
+				},
+			},
+		},
+		{
+			name: "negative_lifecycle_hint_window",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					LifecycleHintWindow: -time.Hour,
+				},
+			},
+		},
+		{
+			name: "negative_write_quota_mb",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Write: WriteConfig{
+					QuotaMb: -1,
+				},
+			},
+		},
+		{
+			name: "negative_write_quota_objects",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Write: WriteConfig{
+					QuotaObjects: -1,
+				},
+			},
+		},
+		{
+			name: "negative_write_lease_ttl",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Write: WriteConfig{
+					LeaseTtl: -time.Minute,
+				},
+			},
+		},
+		{
+			name: "log_sink_file_without_log_file",
+			config: &Config{
+				Logging: LoggingConfig{
+					LogRotate: validLogRotateConfig(),
+					Severity:  "INFO",
+					Sinks:     []string{"file:trace", "syslog:error"},
+				},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "trash_dir_outside_mount",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					TrashDir: "../trash",
+				},
+			},
+		},
+		{
+			name: "negative_trash_ttl",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					TrashTtl: -time.Hour,
+				},
+			},
+		},
+		{
+			name: "invalid_ignore_pattern",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					IgnorePatterns: []string{"_temporary/", "[a"},
+				},
+			},
+		},
+		{
+			name: "invalid_pin_pattern",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{
+					PinPatterns: []string{"models/**", "[a"},
+				},
+			},
+		},
+		{
+			name: "negative_max_concurrent_reads_per_handle",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Read: ReadConfig{
+					MaxConcurrentReadsPerHandle: -1,
+				},
+			},
+		},
+		{
+			name: "single_shot_upload_threshold_too_large",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				Write: WriteConfig{
+					SingleShotUploadThresholdKb: 16385,
+				},
+			},
+		},
+		{
+			name: "negative_max_concurrent_stat_requests",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb:      200,
+					MaxConcurrentStatRequests: -1,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "read_min_share_percent_100",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb:      200,
+					MaxConcurrentReadRequests: 8,
+					ReadMinSharePercent:       100,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "read_min_share_percent_without_max_concurrent_read_requests",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+					ReadMinSharePercent:  25,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "limit_metadata_ops_burst_negative",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb:  200,
+					LimitMetadataOpsBurst: -1,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "proxy_url_with_http3",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+					ClientProtocol:       HTTP3,
+					ProxyUrl:             "socks5://proxy:1080",
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "invalid_proxy_header",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+					ProxyHeaders:         []string{"Proxy-Authorization"},
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "kernel_list_cache_TTL_negative",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: -2},
+			},
+		},
+		{
+			name: "kernel_list_cache_TTL_too_large",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 88888888888888888},
+			},
+		},
+		{
+			name: "read_stall_req_increase_rate_negative",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsRetries: GcsRetriesConfig{
+					ReadStall: ReadStallGcsRetriesConfig{
+						Enable:          true,
+						ReqIncreaseRate: -1,
+					},
+				},
+			},
+		},
+		{
+			name: "read_stall_req_increase_rate_zero",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsRetries: GcsRetriesConfig{
+					ReadStall: ReadStallGcsRetriesConfig{
+						Enable:          true,
+						ReqIncreaseRate: 0,
+					},
+				},
+			},
+		},
+		{
+			name: "read_stall_req_target_percentile_large",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsRetries: GcsRetriesConfig{
+					ReadStall: ReadStallGcsRetriesConfig{
+						Enable:              true,
+						ReqTargetPercentile: 4,
+					},
+				},
+			},
+		},
+		{
+			name: "read_stall_req_target_percentile_negative",
+			config: &Config{
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+				GcsRetries: GcsRetriesConfig{
+					ReadStall: ReadStallGcsRetriesConfig{
+						Enable:              true,
+						ReqTargetPercentile: -3,
+					},
+				},
+			},
+		},
+		{
+			name: "parallel_download_config_without_file_cache_enabled",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					EnableParallelDownloads:  true,
+					MaxParallelDownloads:     4,
+					ParallelDownloadsPerFile: 16,
+					WriteBufferSize:          4 * 1024 * 1024,
+				},
+				GcsConnection: GcsConnectionConfig{
+					CustomEndpoint:       "https://bing.com/search?q=dotnet",
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+			},
+		},
+		{
+			name: "prefetch_trace_without_file_cache_enabled",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					PrefetchTrace:            "/tmp/trace.json",
+				},
+				GcsConnection: GcsConnectionConfig{
+					CustomEndpoint:       "https://bing.com/search?q=dotnet",
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+			},
+		},
+		{
+			name: "disk_budget_negative",
+			config: &Config{
+				DiskBudgetMb: -1,
+				Logging:      LoggingConfig{LogRotate: validLogRotateConfig()},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "disk_budget_with_unbounded_log_files",
+			config: &Config{
+				DiskBudgetMb: 1024,
+				Logging: LoggingConfig{
+					FilePath:  "/tmp/gcsfuse.log",
+					LogRotate: LogRotateLoggingConfig{BackupFileCount: 0, MaxFileSizeMb: 10},
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "disk_budget_smaller_than_log_files",
+			config: &Config{
+				DiskBudgetMb: 100,
+				Logging: LoggingConfig{
+					FilePath:  "/tmp/gcsfuse.log",
+					LogRotate: LogRotateLoggingConfig{BackupFileCount: 9, MaxFileSizeMb: 10},
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "file_cache_memory_tier_size_negative",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					MemoryTierSizeMb:         -1,
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
+			},
+		},
+		{
+			name: "file_cache_max_object_size_less_than_-1",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					MaxObjectSizeMb:          -2,
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
-				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 200,
-				},
 			},
 		},
 		{
-			name: "Valid Sequential read size MB",
+			name: "file_cache_min_object_size_more_than_max",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					MaxObjectSizeMb:          10,
+					MinObjectSizeMb:          20,
+				},
 				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 10,
+					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -130,12 +1274,17 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			},
 		},
 		{
-			name: "Valid Sequential read size MB",
+			name: "file_cache_invalid_exclude_pattern",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					MaxObjectSizeMb:          -1,
+					ExcludePatterns:          []string{"[a-"},
+				},
 				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 10,
+					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -143,79 +1292,66 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			},
 		},
 		{
-			name: "valid_kernel_list_cache_TTL",
+			name: "file_cache_scrub_interval_negative",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					ScrubIntervalSecs:        -1,
+				},
 				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 10,
+					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
-				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 30},
 			},
 		},
 		{
-			name: "valid_parallel_download_config_with_file_cache_enabled",
+			name: "file_cache_max_disk_errors_negative",
 			config: &Config{
-				Logging:  LoggingConfig{LogRotate: validLogRotateConfig()},
-				CacheDir: "/some/valid/path",
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: FileCacheConfig{
 					DownloadChunkSizeMb:      50,
-					EnableParallelDownloads:  true,
-					MaxParallelDownloads:     4,
 					ParallelDownloadsPerFile: 16,
-					MaxSizeMb:                -1,
-					WriteBufferSize:          4 * 1024 * 1024,
+					MaxDiskErrors:            -1,
 				},
 				GcsConnection: GcsConnectionConfig{
-					CustomEndpoint:       "https://bing.com/search?q=dotnet",
 					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "disabled",
+					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
 			},
 		},
 		{
-			name: "valid_chunk_transfer_timeout_secs",
+			name: "file_cache_slow_disk_io_threshold_negative",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					ParallelDownloadsPerFile: 16,
+					SlowDiskIoThresholdMs:    -1,
+				},
 				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 10,
+					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
-				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 30},
-				GcsRetries: GcsRetriesConfig{ChunkTransferTimeoutSecs: 15},
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			actualErr := ValidateConfig(&mockIsSet{}, tc.config)
-
-			assert.NoError(t, actualErr)
-		})
-	}
-}
-
-func TestValidateConfig_ErrorScenarios(t *testing.T) {
-	testCases := []struct {
-		name   string
-		config *Config
-	}{
 		{
-			name: "Invalid Config due to invalid custom endpoint",
+			name: "file_cache_small_file_prefetch_max_size_negative",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: validFileCacheConfig(t),
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:        50,
+					ParallelDownloadsPerFile:   16,
+					SmallFilePrefetchMaxSizeKb: -1,
+				},
 				GcsConnection: GcsConnectionConfig{
-					CustomEndpoint:       "a_b://abc",
 					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
@@ -224,175 +1360,150 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			},
 		},
 		{
-			name: "Invalid experimental-metadata-prefetch-on-mount",
+			name: "file_cache_small_file_prefetch_parallelism_zero",
 			config: &Config{
 				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
-				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "a",
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:        50,
+					ParallelDownloadsPerFile:   16,
+					SmallFilePrefetchMaxSizeKb: 64,
 				},
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 200,
 				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "sync",
+				},
 			},
 		},
 		{
-			name: "Invalid Config due to invalid token URL",
+			name: "recent_errors_count_negative",
 			config: &Config{
-				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Logging: LoggingConfig{
+					LogRotate:         validLogRotateConfig(),
+					RecentErrorsCount: -1,
+				},
 				FileCache: validFileCacheConfig(t),
-				GcsAuth: GcsAuthConfig{
-					TokenUrl: "a_b://abc",
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
 				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
-				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 200,
-				},
 			},
 		},
 		{
-			name: "Sequential read size MB more than 1024 (max permissible value)",
+			name: "chunk_transfer_timeout_in_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
-				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 2048,
-				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
+				GcsRetries: GcsRetriesConfig{
+					ChunkTransferTimeoutSecs: -5,
+				},
 			},
-		},
-		{
-			name: "Sequential read size MB less than 1 (min permissible value)",
+		}, {
+			name: "max_connection_retry_attempts_in_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
-				GcsConnection: GcsConnectionConfig{
-					SequentialReadSizeMb: 0,
-				},
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
 				},
+				GcsRetries: GcsRetriesConfig{
+					MaxConnectionRetryAttempts: -1,
+				},
 			},
 		},
 		{
-			name: "kernel_list_cache_TTL_negative",
+			name: "metadata_cache_ttl_jitter_percent_more_than_100",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
+					TtlJitterPercent:                    101,
 				},
-				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: -2},
 			},
 		},
 		{
-			name: "kernel_list_cache_TTL_too_large",
+			name: "metadata_cache_batch_refresh_threshold_in_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
+					BatchRefreshThreshold:               -1,
 				},
-				FileSystem: FileSystemConfig{KernelListCacheTtlSecs: 88888888888888888},
 			},
 		},
 		{
-			name: "read_stall_req_increase_rate_negative",
+			name: "metadata_cache_list_cache_ttl_secs_less_than_minus_one",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
-				},
-				GcsRetries: GcsRetriesConfig{
-					ReadStall: ReadStallGcsRetriesConfig{
-						Enable:          true,
-						ReqIncreaseRate: -1,
-					},
+					ListCacheTtlSecs:                    -2,
+					ListCacheMaxSizeMb:                  32,
 				},
 			},
 		},
 		{
-			name: "read_stall_req_increase_rate_zero",
+			name: "metadata_cache_permission_denied_ttl_secs_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "sync",
-				},
-				GcsRetries: GcsRetriesConfig{
-					ReadStall: ReadStallGcsRetriesConfig{
-						Enable:          true,
-						ReqIncreaseRate: 0,
-					},
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+					PermissionDeniedTtlSecs:             -1,
 				},
 			},
 		},
 		{
-			name: "read_stall_req_target_percentile_large",
+			name: "metadata_prefetch_max_duration_secs_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "sync",
-				},
-				GcsRetries: GcsRetriesConfig{
-					ReadStall: ReadStallGcsRetriesConfig{
-						Enable:              true,
-						ReqTargetPercentile: 4,
-					},
+					ExperimentalMetadataPrefetchOnMount:         "sync",
+					ExperimentalMetadataPrefetchMaxDurationSecs: -1,
 				},
 			},
 		},
 		{
-			name: "read_stall_req_target_percentile_negative",
+			name: "metadata_prefetch_max_entries_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "sync",
-				},
-				GcsRetries: GcsRetriesConfig{
-					ReadStall: ReadStallGcsRetriesConfig{
-						Enable:              true,
-						ReqTargetPercentile: -3,
-					},
+					ExperimentalMetadataPrefetchOnMount:    "sync",
+					ExperimentalMetadataPrefetchMaxEntries: -1,
 				},
 			},
 		},
 		{
-			name: "parallel_download_config_without_file_cache_enabled",
+			name: "metadata_prefetch_workers_negative",
 			config: &Config{
-				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
-				FileCache: FileCacheConfig{
-					DownloadChunkSizeMb:      50,
-					EnableParallelDownloads:  true,
-					MaxParallelDownloads:     4,
-					ParallelDownloadsPerFile: 16,
-					WriteBufferSize:          4 * 1024 * 1024,
-				},
-				GcsConnection: GcsConnectionConfig{
-					CustomEndpoint:       "https://bing.com/search?q=dotnet",
-					SequentialReadSizeMb: 200,
-				},
+				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
-					ExperimentalMetadataPrefetchOnMount: "disabled",
+					ExperimentalMetadataPrefetchOnMount: "sync",
+					ExperimentalMetadataPrefetchWorkers: -1,
 				},
 			},
 		},
 		{
-			name: "chunk_transfer_timeout_in_negative",
+			name: "metadata_cache_list_cache_max_size_mb_zero",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
-				},
-				GcsRetries: GcsRetriesConfig{
-					ChunkTransferTimeoutSecs: -5,
+					ListCacheTtlSecs:                    10,
+					ListCacheMaxSizeMb:                  0,
 				},
 			},
 		},
@@ -500,6 +1611,41 @@ func Test_isValidWriteStreamingConfig_ErrorScenarios(t *testing.T) {
 			GlobalMaxBlocks:                   20,
 			MaxBlocksPerFile:                  1,
 		}},
+		{"negative_spill_memory_threshold_percent", WriteConfig{
+			BlockSizeMb:                       10,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   20,
+			MaxBlocksPerFile:                  20,
+			SpillMemoryThresholdPercent:       -1,
+		}},
+		{"spill_memory_threshold_percent_above_100", WriteConfig{
+			BlockSizeMb:                       10,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   20,
+			MaxBlocksPerFile:                  20,
+			SpillMemoryThresholdPercent:       101,
+		}},
+		{"max_dirty_mb_below_-1", WriteConfig{
+			BlockSizeMb:                       10,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   20,
+			MaxBlocksPerFile:                  20,
+			MaxDirtyMb:                        -2,
+		}},
+		{"negative_coalesce_size", WriteConfig{
+			BlockSizeMb:                       10,
+			CoalesceSizeKb:                    -1,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   20,
+			MaxBlocksPerFile:                  20,
+		}},
+		{"coalesce_size_above_block_size", WriteConfig{
+			BlockSizeMb:                       1,
+			CoalesceSizeKb:                    1025,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   20,
+			MaxBlocksPerFile:                  20,
+		}},
 	}
 
 	for _, tc := range testCases {
@@ -549,6 +1695,20 @@ func Test_isValidWriteStreamingConfig_SuccessScenarios(t *testing.T) {
 			GlobalMaxBlocks:                   40,
 			MaxBlocksPerFile:                  20,
 		}},
+		{"valid_write_config_with_spill", WriteConfig{
+			BlockSizeMb:                       10,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   40,
+			MaxBlocksPerFile:                  20,
+			SpillMemoryThresholdPercent:       80,
+		}},
+		{"valid_write_config_with_coalescing", WriteConfig{
+			BlockSizeMb:                       1,
+			CoalesceSizeKb:                    1024,
+			ExperimentalEnableStreamingWrites: true,
+			GlobalMaxBlocks:                   40,
+			MaxBlocksPerFile:                  20,
+		}},
 	}
 
 	for _, tc := range testCases {
@@ -558,6 +1718,63 @@ func Test_isValidWriteStreamingConfig_SuccessScenarios(t *testing.T) {
 	}
 }
 
+func Test_isValidAtomicCommitConfig(t *testing.T) {
+	var testCases = []struct {
+		testName    string
+		writeConfig WriteConfig
+		wantErr     bool
+	}{
+		{"disabled", WriteConfig{AtomicCommitSentinel: ""}, false},
+		{"default_sentinel", WriteConfig{AtomicCommitPrefixes: []string{"out/"}, AtomicCommitSentinel: "_SUCCESS"}, false},
+		{"empty_sentinel", WriteConfig{AtomicCommitPrefixes: []string{"out/"}, AtomicCommitSentinel: ""}, true},
+		{"sentinel_with_slash", WriteConfig{AtomicCommitPrefixes: []string{"out/"}, AtomicCommitSentinel: "a/_SUCCESS"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidAtomicCommitConfig(&tc.writeConfig)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidAuthConfig(t *testing.T) {
+	var testCases = []struct {
+		testName   string
+		authConfig GcsAuthConfig
+		wantErr    bool
+	}{
+		{"default", GcsAuthConfig{}, false},
+		{"key_file_and_token_url", GcsAuthConfig{KeyFile: "/key.json", TokenUrl: "http://localhost/token"}, false},
+		{"credential_config_file", GcsAuthConfig{CredentialConfigFile: "/wif.json"}, false},
+		{"external_credential_command", GcsAuthConfig{ExternalCredentialCommand: "get-token"}, false},
+		{"credential_config_file_and_key_file", GcsAuthConfig{CredentialConfigFile: "/wif.json", KeyFile: "/key.json"}, true},
+		{"external_credential_command_and_token_url", GcsAuthConfig{ExternalCredentialCommand: "get-token", TokenUrl: "http://localhost/token"}, true},
+		{"credential_config_file_and_external_credential_command", GcsAuthConfig{CredentialConfigFile: "/wif.json", ExternalCredentialCommand: "get-token"}, true},
+		{"impersonate_service_account", GcsAuthConfig{ImpersonateServiceAccount: "sa@project.iam.gserviceaccount.com"}, false},
+		{"impersonate_service_account_chain", GcsAuthConfig{ImpersonateServiceAccount: "a@project.iam.gserviceaccount.com,b@project.iam.gserviceaccount.com"}, false},
+		{"impersonate_service_account_chain_with_empty_account", GcsAuthConfig{ImpersonateServiceAccount: "a@project.iam.gserviceaccount.com,"}, true},
+		{"impersonate_service_account_with_anonymous_access", GcsAuthConfig{ImpersonateServiceAccount: "sa@project.iam.gserviceaccount.com", AnonymousAccess: true}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidAuthConfig(&tc.authConfig)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func validConfig(t *testing.T) Config {
 	return Config{
 		Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
@@ -637,6 +1854,21 @@ func TestValidateMetrics(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "max_bucket_names",
+			metricsConfig: MetricsConfig{
+				BucketNames:    []string{"bucket"},
+				MaxBucketNames: 10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative_max_bucket_names",
+			metricsConfig: MetricsConfig{
+				MaxBucketNames: -1,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/fuse"
+)
+
+// autoUnmountCheckInterval is the interval at which gcsfuse checks whether
+// the mount is due to be unmounted, with auto-unmount-after and
+// auto-unmount-idle-after.
+const autoUnmountCheckInterval = 10 * time.Second
+
+// idleTimer tells how long no file system op has been processed, by sampling
+// the activity of the mount.
+type idleTimer struct {
+	activity func() (started, inFlight int64)
+
+	lastStarted int64
+	activeAt    time.Time
+}
+
+func newIdleTimer(activity func() (started, inFlight int64), now time.Time) *idleTimer {
+	started, _ := activity()
+	return &idleTimer{activity: activity, lastStarted: started, activeAt: now}
+}
+
+// idleFor returns how long the mount has been idle at now, i.e. since the
+// last sample at which an op had been started or was still being processed.
+func (t *idleTimer) idleFor(now time.Time) time.Duration {
+	started, inFlight := t.activity()
+	if started != t.lastStarted || inFlight > 0 {
+		t.lastStarted = started
+		t.activeAt = now
+	}
+	return now.Sub(t.activeAt)
+}
+
+// autoUnmountReason returns why the mount is due to be unmounted, having been
+// mounted for mountedFor and idle for idleFor, or "" if it's not.
+func autoUnmountReason(c *cfg.FileSystemConfig, mountedFor, idleFor time.Duration) string {
+	if c.AutoUnmountAfter > 0 && mountedFor >= c.AutoUnmountAfter {
+		return fmt.Sprintf("mounted for %v", c.AutoUnmountAfter)
+	}
+	if c.AutoUnmountIdleAfter > 0 && idleFor >= c.AutoUnmountIdleAfter {
+		return fmt.Sprintf("idle for %v", c.AutoUnmountIdleAfter)
+	}
+	return ""
+}
+
+// registerAutoUnmount unmounts the file system once it's due with
+// auto-unmount-after or auto-unmount-idle-after, if set, draining it like a
+// SIGTERM in container mode: unmounting is retried until the files open in
+// the mount are closed, which flushes them. draining is set once it's due.
+func registerAutoUnmount(mountPoint string, c *cfg.Config, activity func() (started, inFlight int64), draining *atomic.Bool) {
+	fsConfig := &c.FileSystem
+	if fsConfig.AutoUnmountAfter <= 0 && fsConfig.AutoUnmountIdleAfter <= 0 {
+		return
+	}
+
+	go func() {
+		mountedAt := time.Now()
+		idle := newIdleTimer(activity, mountedAt)
+		ticker := time.NewTicker(autoUnmountCheckInterval)
+		defer ticker.Stop()
+
+		var reason string
+		for reason == "" {
+			now := <-ticker.C
+			reason = autoUnmountReason(fsConfig, now.Sub(mountedAt), idle.idleFor(now))
+		}
+
+		logger.Infof("The mount has been %s, attempting to unmount...", reason)
+		draining.Store(true)
+		err := fuse.Unmount(mountPoint)
+		if err != nil {
+			logger.Infof("Waiting for the files open in %s to be closed to unmount: %v", mountPoint, err)
+		}
+		for err != nil {
+			time.Sleep(drainRetryInterval)
+			err = fuse.Unmount(mountPoint)
+		}
+		logger.Infof("Successfully unmounted after being %s.", reason)
+	}()
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleTimer(t *testing.T) {
+	var started, inFlight int64
+	activity := func() (int64, int64) { return started, inFlight }
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	idle := newIdleTimer(activity, start)
+
+	assert.Equal(t, time.Minute, idle.idleFor(start.Add(time.Minute)))
+
+	// An op started since the last sample.
+	started = 1
+	assert.Equal(t, time.Duration(0), idle.idleFor(start.Add(2*time.Minute)))
+	assert.Equal(t, time.Minute, idle.idleFor(start.Add(3*time.Minute)))
+
+	// A long op keeps the mount active while it's processed.
+	started, inFlight = 2, 1
+	assert.Equal(t, time.Duration(0), idle.idleFor(start.Add(4*time.Minute)))
+	assert.Equal(t, time.Duration(0), idle.idleFor(start.Add(5*time.Minute)))
+	inFlight = 0
+	assert.Equal(t, time.Minute, idle.idleFor(start.Add(6*time.Minute)))
+}
+
+func TestAutoUnmountReason(t *testing.T) {
+	testCases := []struct {
+		name       string
+		after      time.Duration
+		idleAfter  time.Duration
+		mountedFor time.Duration
+		idleFor    time.Duration
+		want       string
+	}{
+		{
+			name:       "disabled",
+			mountedFor: time.Hour,
+			idleFor:    time.Hour,
+		},
+		{
+			name:       "not_due",
+			after:      time.Hour,
+			idleAfter:  10 * time.Minute,
+			mountedFor: 30 * time.Minute,
+			idleFor:    5 * time.Minute,
+		},
+		{
+			name:       "mounted_long_enough",
+			after:      time.Hour,
+			mountedFor: time.Hour,
+			want:       "mounted for 1h0m0s",
+		},
+		{
+			name:       "idle_long_enough",
+			after:      time.Hour,
+			idleAfter:  10 * time.Minute,
+			mountedFor: 30 * time.Minute,
+			idleFor:    10 * time.Minute,
+			want:       "idle for 10m0s",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &cfg.FileSystemConfig{AutoUnmountAfter: tc.after, AutoUnmountIdleAfter: tc.idleAfter}
+
+			assert.Equal(t, tc.want, autoUnmountReason(c, tc.mountedFor, tc.idleFor))
+		})
+	}
+}
@@ -31,7 +31,7 @@ import (
 func getConfigObject(t *testing.T, args []string) (*cfg.Config, error) {
 	t.Helper()
 	var c *cfg.Config
-	cmd, err := newRootCmd(func(config *cfg.Config, _, _ string) error {
+	cmd, err := newRootCmd(func(config *cfg.Config, _, _ string, _ *machineTuning) error {
 		c = config
 		return nil
 	})
@@ -54,15 +54,21 @@ func getConfigObjectWithConfigFile(t *testing.T, configFilePath string) (*cfg.Co
 func defaultFileCacheConfig(t *testing.T) cfg.FileCacheConfig {
 	t.Helper()
 	return cfg.FileCacheConfig{
-		CacheFileForRangeRead:    false,
-		DownloadChunkSizeMb:      50,
-		EnableCrc:                false,
-		EnableParallelDownloads:  false,
-		MaxParallelDownloads:     int64(max(16, 2*runtime.NumCPU())),
-		MaxSizeMb:                -1,
-		ParallelDownloadsPerFile: 16,
-		WriteBufferSize:          4 * 1024 * 1024,
-		EnableODirect:            false,
+		CacheFileForRangeRead:        false,
+		DownloadChunkSizeMb:          50,
+		EnableCrc:                    false,
+		EnableParallelDownloads:      false,
+		ExcludePatterns:              []string{},
+		IncludePatterns:              []string{},
+		MaxDiskErrors:                5,
+		MaxObjectSizeMb:              -1,
+		MaxParallelDownloads:         int64(max(16, 2*runtime.NumCPU())),
+		MaxSizeMb:                    -1,
+		ParallelDownloadsPerFile:     16,
+		ScrubIntervalSecs:            3600,
+		SmallFilePrefetchParallelism: 16,
+		WriteBufferSize:              4 * 1024 * 1024,
+		EnableODirect:                false,
 	}
 }
 
@@ -189,11 +195,16 @@ func TestValidateConfigFile_WriteConfig(t *testing.T) {
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.Config{
 				Write: cfg.WriteConfig{
+					AtomicCommitPrefixes:              []string{},
+					AtomicCommitSentinel:              "_SUCCESS",
 					CreateEmptyFile:                   false,
 					BlockSizeMb:                       64,
+					CoalesceSizeKb:                    1024,
 					ExperimentalEnableStreamingWrites: false,
 					GlobalMaxBlocks:                   math.MaxInt64,
-					MaxBlocksPerFile:                  math.MaxInt64},
+					MaxBlocksPerFile:                  math.MaxInt64,
+					MaxDirtyMb:                        0,
+					ObjectCreationRules:               []string{}},
 			},
 		},
 		{
@@ -201,11 +212,16 @@ func TestValidateConfigFile_WriteConfig(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				Write: cfg.WriteConfig{
+					AtomicCommitPrefixes:              []string{"out/"},
+					AtomicCommitSentinel:              "_DONE",
 					CreateEmptyFile:                   false, // changed due to enabled streaming writes.
 					BlockSizeMb:                       10,
+					CoalesceSizeKb:                    1024,
 					ExperimentalEnableStreamingWrites: true,
 					GlobalMaxBlocks:                   20,
 					MaxBlocksPerFile:                  2,
+					MaxDirtyMb:                        200,
+					ObjectCreationRules:               []string{"archive/:COLDLINE", "tmp/::168h"},
 				},
 			},
 		},
@@ -307,6 +323,10 @@ func TestValidateConfigFile_InvalidConfigThrowsError(t *testing.T) {
 			name:       "small_max_blocks_per_file",
 			configFile: "testdata/write_config/invalid_write_config_due_to_small_max_blocks_per_file.yaml",
 		},
+		{
+			name:       "unknown_storage_class_in_object_creation_rules",
+			configFile: "testdata/write_config/invalid_write_config_due_to_unknown_storage_class.yaml",
+		},
 		{
 			name:       "negative req_increase_rate",
 			configFile: "testdata/gcs_retries/read_stall/invalid_req_increase_rate_negative.yaml",
@@ -352,15 +372,24 @@ func TestValidateConfigFile_FileCacheConfigSuccessful(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				FileCache: cfg.FileCacheConfig{
-					CacheFileForRangeRead:    true,
-					DownloadChunkSizeMb:      300,
-					EnableCrc:                true,
-					EnableParallelDownloads:  false,
-					MaxParallelDownloads:     200,
-					MaxSizeMb:                40,
-					ParallelDownloadsPerFile: 10,
-					WriteBufferSize:          8192,
-					EnableODirect:            true,
+					CacheFileForRangeRead:        true,
+					DownloadChunkSizeMb:          300,
+					EnableChunkChecksums:         true,
+					EnableCrc:                    true,
+					EnableParallelDownloads:      false,
+					ExcludePatterns:              []string{"*.tmp"},
+					IncludePatterns:              []string{},
+					MaxDiskErrors:                10,
+					MaxObjectSizeMb:              512,
+					MaxParallelDownloads:         200,
+					MaxSizeMb:                    40,
+					MemoryTierSizeMb:             8,
+					ParallelDownloadsPerFile:     10,
+					ScrubIntervalSecs:            600,
+					SlowDiskIoThresholdMs:        200,
+					SmallFilePrefetchParallelism: 16,
+					WriteBufferSize:              8192,
+					EnableODirect:                true,
 				},
 			},
 		},
@@ -452,9 +481,11 @@ func TestValidateConfigFile_GCSConnectionConfigSuccessful(t *testing.T) {
 					GrpcConnPoolSize:           1,
 					HttpClientTimeout:          0,
 					LimitBytesPerSec:           -1,
+					LimitMetadataOpsPerSec:     -1,
 					LimitOpsPerSec:             -1,
 					MaxConnsPerHost:            0,
 					MaxIdleConnsPerHost:        100,
+					ProxyHeaders:               []string{},
 					SequentialReadSizeMb:       200,
 				},
 			},
@@ -471,9 +502,13 @@ func TestValidateConfigFile_GCSConnectionConfigSuccessful(t *testing.T) {
 					GrpcConnPoolSize:           200,
 					HttpClientTimeout:          400 * time.Second,
 					LimitBytesPerSec:           20,
+					LimitMetadataOpsBurst:      5,
+					LimitMetadataOpsPerSec:     50,
 					LimitOpsPerSec:             30,
 					MaxConnsPerHost:            400,
 					MaxIdleConnsPerHost:        20,
+					ProxyHeaders:               []string{"X-Tenant: abc"},
+					ProxyUrl:                   "http://proxy:3128",
 					SequentialReadSizeMb:       450,
 				},
 			},
@@ -505,18 +540,26 @@ func TestValidateConfigFile_FileSystemConfigSuccessful(t *testing.T) {
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0755,
-					DisableParallelDirops:  false,
-					FileMode:               0644,
-					FuseOptions:            []string{},
-					Gid:                    -1,
-					IgnoreInterrupts:       true,
-					KernelListCacheTtlSecs: 0,
-					RenameDirLimit:         0,
-					TempDir:                "",
-					PreconditionErrors:     false,
-					Uid:                    -1,
-					HandleSigterm:          true,
+					ClobberAction:            "fail",
+					CrossBucketRenameLimitMb: 1024,
+					DirMode:                  0755,
+					DisableParallelDirops:    false,
+					FileMode:                 0644,
+					FuseOptions:              []string{},
+					Gid:                      -1,
+					IgnoreInterrupts:         true,
+					IgnorePatterns:           []string{},
+					PinPatterns:              []string{},
+					KernelListCacheTtlSecs:   0,
+					RenameDirLimit:           0,
+					TempDir:                  "",
+					PreconditionErrors:       false,
+					Uid:                      -1,
+					UidFileModes:             []string{},
+					UnsupportedFsAction:      "warn",
+					NestedMountAction:        "refuse",
+					OfflineMode:              "off",
+					HandleSigterm:            true,
 				},
 			},
 		},
@@ -525,18 +568,26 @@ func TestValidateConfigFile_FileSystemConfigSuccessful(t *testing.T) {
 			configFile: "testdata/file_system_config/unset_file_system_config.yaml",
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0755,
-					DisableParallelDirops:  false,
-					FileMode:               0644,
-					FuseOptions:            []string{},
-					Gid:                    -1,
-					IgnoreInterrupts:       true,
-					KernelListCacheTtlSecs: 0,
-					RenameDirLimit:         0,
-					TempDir:                "",
-					PreconditionErrors:     false,
-					Uid:                    -1,
-					HandleSigterm:          true,
+					ClobberAction:            "fail",
+					CrossBucketRenameLimitMb: 1024,
+					DirMode:                  0755,
+					DisableParallelDirops:    false,
+					FileMode:                 0644,
+					FuseOptions:              []string{},
+					Gid:                      -1,
+					IgnoreInterrupts:         true,
+					IgnorePatterns:           []string{},
+					PinPatterns:              []string{},
+					KernelListCacheTtlSecs:   0,
+					RenameDirLimit:           0,
+					TempDir:                  "",
+					PreconditionErrors:       false,
+					Uid:                      -1,
+					UidFileModes:             []string{},
+					UnsupportedFsAction:      "warn",
+					NestedMountAction:        "refuse",
+					OfflineMode:              "off",
+					HandleSigterm:            true,
 				},
 			},
 		},
@@ -545,18 +596,26 @@ func TestValidateConfigFile_FileSystemConfigSuccessful(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0777,
-					DisableParallelDirops:  true,
-					FileMode:               0666,
-					FuseOptions:            []string{"ro"},
-					Gid:                    7,
-					IgnoreInterrupts:       false,
-					KernelListCacheTtlSecs: 300,
-					RenameDirLimit:         10,
-					TempDir:                cfg.ResolvedPath(path.Join(hd, "temp")),
-					PreconditionErrors:     true,
-					Uid:                    8,
-					HandleSigterm:          true,
+					ClobberAction:            "fail",
+					CrossBucketRenameLimitMb: 1024,
+					DirMode:                  0777,
+					DisableParallelDirops:    true,
+					FileMode:                 0666,
+					FuseOptions:              []string{"ro"},
+					Gid:                      7,
+					IgnoreInterrupts:         false,
+					IgnorePatterns:           []string{},
+					PinPatterns:              []string{},
+					KernelListCacheTtlSecs:   300,
+					RenameDirLimit:           10,
+					TempDir:                  cfg.ResolvedPath(path.Join(hd, "temp")),
+					PreconditionErrors:       true,
+					Uid:                      8,
+					UidFileModes:             []string{},
+					UnsupportedFsAction:      "warn",
+					NestedMountAction:        "refuse",
+					OfflineMode:              "off",
+					HandleSigterm:            true,
 				},
 			},
 		},
@@ -658,6 +717,9 @@ func TestValidateConfigFile_MetadataCacheConfigSuccessful(t *testing.T) {
 					DeprecatedTypeCacheTtl:              60 * time.Second,
 					EnableNonexistentTypeCache:          false,
 					ExperimentalMetadataPrefetchOnMount: "disabled",
+					ExperimentalMetadataPrefetchWorkers: 16,
+					ListCacheMaxSizeMb:                  32,
+					PermissionDeniedTtlSecs:             5,
 					StatCacheMaxSizeMb:                  32,
 					TtlSecs:                             60,
 					TypeCacheMaxSizeMb:                  4,
@@ -674,6 +736,9 @@ func TestValidateConfigFile_MetadataCacheConfigSuccessful(t *testing.T) {
 					DeprecatedTypeCacheTtl:              20 * time.Second,
 					EnableNonexistentTypeCache:          true,
 					ExperimentalMetadataPrefetchOnMount: "sync",
+					ExperimentalMetadataPrefetchWorkers: 16,
+					ListCacheMaxSizeMb:                  32,
+					PermissionDeniedTtlSecs:             5,
 					StatCacheMaxSizeMb:                  40,
 					TtlSecs:                             100,
 					TypeCacheMaxSizeMb:                  10,
@@ -705,10 +770,11 @@ func TestValidateConfigFile_GCSRetries(t *testing.T) {
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.Config{
 				GcsRetries: cfg.GcsRetriesConfig{
-					ChunkTransferTimeoutSecs: 10,
-					MaxRetryAttempts:         0,
-					MaxRetrySleep:            30 * time.Second,
-					Multiplier:               2,
+					ChunkTransferTimeoutSecs:   10,
+					MaxConnectionRetryAttempts: 0,
+					MaxRetryAttempts:           0,
+					MaxRetrySleep:              30 * time.Second,
+					Multiplier:                 2,
 					ReadStall: cfg.ReadStallGcsRetriesConfig{
 						Enable:              false,
 						MinReqTimeout:       1500 * time.Millisecond,
@@ -725,10 +791,11 @@ func TestValidateConfigFile_GCSRetries(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				GcsRetries: cfg.GcsRetriesConfig{
-					ChunkTransferTimeoutSecs: 20,
-					MaxRetryAttempts:         0,
-					MaxRetrySleep:            30 * time.Second,
-					Multiplier:               2,
+					ChunkTransferTimeoutSecs:   20,
+					MaxConnectionRetryAttempts: 1,
+					MaxRetryAttempts:           0,
+					MaxRetrySleep:              30 * time.Second,
+					Multiplier:                 2,
 					ReadStall: cfg.ReadStallGcsRetriesConfig{
 						Enable:              true,
 						MinReqTimeout:       10 * time.Second,
@@ -801,6 +868,7 @@ func TestValidateConfigFile_MetricsConfigSuccessful(t *testing.T) {
 			name:       "empty_config_file",
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.MetricsConfig{
+				BucketNames:                    []string{},
 				StackdriverExportInterval:      0,
 				CloudMetricsExportIntervalSecs: 0,
 				PrometheusPort:                 0,
@@ -810,6 +878,7 @@ func TestValidateConfigFile_MetricsConfigSuccessful(t *testing.T) {
 			name:       "valid_config_file",
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.MetricsConfig{
+				BucketNames:                    []string{},
 				CloudMetricsExportIntervalSecs: 10,
 			},
 		},
@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cpuset"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// pinToCPUSet restricts gcsfuse to the CPUs of cpu-set. The read workers of
+// experimental-read-worker-processes, started afterwards, inherit them.
+func pinToCPUSet(spec string) error {
+	s, err := cpuset.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("cpu-set: %w", err)
+	}
+	cpus, err := s.Resolve()
+	if err != nil {
+		return fmt.Errorf("cpu-set: %w", err)
+	}
+	if err = cpuset.Pin(cpus); err != nil {
+		return fmt.Errorf("cpu-set: %w", err)
+	}
+	logger.Infof("Restricted gcsfuse to the %d CPUs of %s: %v", len(cpus), spec, cpus)
+	return nil
+}
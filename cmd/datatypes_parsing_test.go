@@ -548,6 +548,13 @@ func TestCLIFlagPassing(t *testing.T) {
 				assert.Equal(t, cfg.Protocol("http2"), c.GcsConnection.ClientProtocol)
 			},
 		},
+		{
+			name: "protocol6",
+			args: []string{"--client-protocol", "HTTP3"},
+			testFn: func(t *testing.T, c *cfg.Config) {
+				assert.Equal(t, cfg.Protocol("http3"), c.GcsConnection.ClientProtocol)
+			},
+		},
 		{
 			name: "resolvedpath1",
 			args: []string{"--cache-dir", "/home"},
@@ -574,7 +581,7 @@ func TestCLIFlagPassing(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			var c *cfg.Config
-			command, err := newRootCmd(func(config *cfg.Config, _, _ string) error {
+			command, err := newRootCmd(func(config *cfg.Config, _, _ string, _ *machineTuning) error {
 				c = config
 				return nil
 			})
@@ -747,7 +754,7 @@ func TestConfigPassing(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			var c *cfg.Config
-			command, err := newRootCmd(func(config *cfg.Config, _, _ string) error {
+			command, err := newRootCmd(func(config *cfg.Config, _, _ string, _ *machineTuning) error {
 				c = config
 				return nil
 			})
@@ -802,7 +809,7 @@ func TestPredefinedFlagThrowNoError(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			command, err := newRootCmd(func(config *cfg.Config, _, _ string) error {
+			command, err := newRootCmd(func(config *cfg.Config, _, _ string, _ *machineTuning) error {
 				return nil
 			})
 			require.NoError(t, err)
@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// newDiffCmd returns the command comparing two generations of a file of a
+// running mount, through its control socket.
+func newDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gcsfuse diff mount_point path generation_a generation_b",
+		Short: "Report the byte ranges in which two generations of a file differ",
+		Long: `Reports the byte ranges in which two generations of the object of a file,
+relative to the mount point or absolute, differ, one "range OFFSET LENGTH" line
+per range. Generation 0 is the live one. The mount streams both generations
+side by side without storing them. The mount must serve a control socket
+(--control-socket).`,
+		Args:         cobra.ExactArgs(4),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiff(cmd.Context(), args[0], args[1], args[2], args[3], cmd.OutOrStdout())
+		},
+	}
+}
+
+// isDiffCmd returns true if args, including the program name, invoke the diff
+// command rather than mount a bucket named diff, which takes at most two
+// arguments.
+func isDiffCmd(args []string) bool {
+	if len(args) < 2 || args[1] != "diff" {
+		return false
+	}
+	c := newDiffCmd()
+	if err := c.ParseFlags(args[2:]); err != nil {
+		return false
+	}
+	return len(c.Flags().Args()) == 4
+}
+
+func runDiff(ctx context.Context, mountPoint, path, generationA, generationB string, w io.Writer) error {
+	a, err := strconv.ParseInt(generationA, 10, 64)
+	if err != nil || a < 0 {
+		return fmt.Errorf("invalid generation %q", generationA)
+	}
+	b, err := strconv.ParseInt(generationB, 10, 64)
+	if err != nil || b < 0 {
+		return fmt.Errorf("invalid generation %q", generationB)
+	}
+	mountPoint, err = filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+	socket, err := controlSocketPath(mountPoint)
+	if err != nil {
+		return err
+	}
+	path, err = mountRelativePath(mountPoint, path)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return control.Diff(ctx, socket, path, a, b, w)
+}
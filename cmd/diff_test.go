@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDiffCmd(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{args: []string{"gcsfuse", "diff", "/mnt", "data/a", "1", "2"}, expected: true},
+		// Mounts of a bucket named diff.
+		{args: []string{"gcsfuse", "diff", "/mnt"}, expected: false},
+		{args: []string{"gcsfuse", "diff", "/mnt", "--temp-dir", "/tmp"}, expected: false},
+		{args: []string{"gcsfuse", "--implicit-dirs", "diff", "/mnt"}, expected: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isDiffCmd(tc.args), "args: %v", tc.args)
+	}
+}
+
+type recordingDiffer struct {
+	path string
+	a, b int64
+}
+
+func (d *recordingDiffer) Diff(_ context.Context, path string, a, b int64, ranges func(offset, length int64)) error {
+	d.path, d.a, d.b = path, a, b
+	ranges(4, 2)
+	return nil
+}
+
+func TestRunDiff(t *testing.T) {
+	d := &recordingDiffer{}
+	mountPoint := fakeMount(t, nil, d)
+	var out bytes.Buffer
+
+	err := runDiff(context.Background(), mountPoint, filepath.Join(mountPoint, "data/a"), "1", "0", &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, "data/a", d.path)
+	assert.Equal(t, int64(1), d.a)
+	assert.Equal(t, int64(0), d.b)
+	assert.Equal(t, "range 4 2\ndone: 2 bytes differ in 1 ranges\n", out.String())
+}
+
+func TestRunDiff_InvalidGeneration(t *testing.T) {
+	err := runDiff(context.Background(), t.TempDir(), "data/a", "1", "latest", &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, `invalid generation "latest"`)
+}
@@ -0,0 +1,347 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+// The kernel files read by the doctor. Overridden in tests.
+var (
+	doctorFuseDevice    = "/dev/fuse"
+	doctorFuseModuleDir = "/sys/module/fuse"
+	doctorBdiDir        = "/sys/class/bdi"
+)
+
+const (
+	// doctorFileSize is the size of the file written, read back and deleted by
+	// the doctor.
+	doctorFileSize = 64 * 1024
+
+	// doctorListSample is the most entries of the root directory listed by the
+	// doctor.
+	doctorListSample = 100
+
+	// doctorMinReadAheadKb is the read-ahead below which the doctor warns that
+	// the sequential reads are slower than they could be.
+	doctorMinReadAheadKb = 1024
+)
+
+// doctorStep is the outcome of one of the steps of the doctor, with the
+// statuses of the preflight checks.
+type doctorStep struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Detail    string  `json:"detail"`
+}
+
+// doctorReport is the outcome of the doctor, laid out the same on all machines
+// so that the reports can be compared.
+type doctorReport struct {
+	Version    string       `json:"version"`
+	Kernel     string       `json:"kernel"`
+	Platform   string       `json:"platform"`
+	CPUs       int          `json:"cpus"`
+	MountPoint string       `json:"mount_point"`
+	Steps      []doctorStep `json:"steps"`
+}
+
+// newDoctorCmd returns the command diagnosing a running mount through its
+// file system.
+func newDoctorCmd() *cobra.Command {
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "gcsfuse doctor mount_point",
+		Short: "Run a suite of diagnostics against a mount and print a report",
+		Long: `Runs a suite of diagnostics against a mount: checks the fuse kernel module and
+the read-ahead of the mount, stats its root, lists a sample of its entries, and
+writes, reads back and deletes a small file in a temporary directory, measuring
+the latency of each step. The report is laid out the same on all machines, so
+that it can be compared and attached to support requests. The write, read and
+delete steps are skipped on read-only mounts.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(args[0], asJSON, cmd.OutOrStdout())
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON.")
+	return c
+}
+
+// isDoctorCmd returns true if args, including the program name, invoke the
+// doctor command rather than mount a bucket named doctor: the mount point of
+// the doctor is already a FUSE mount, on which gcsfuse refuses to mount.
+func isDoctorCmd(args []string) bool {
+	if len(args) < 2 || args[1] != "doctor" {
+		return false
+	}
+	c := newDoctorCmd()
+	if err := c.ParseFlags(args[2:]); err != nil {
+		return false
+	}
+	if len(c.Flags().Args()) != 1 {
+		return false
+	}
+	var st syscall.Statfs_t
+	return statfs(c.Flags().Arg(0), &st) == nil && uint32(st.Type) == fuseSuperMagic
+}
+
+func runDoctor(mountPoint string, asJSON bool, w io.Writer) error {
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+	r := doctor(mountPoint)
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	} else {
+		err = r.writeText(w)
+	}
+	if err != nil {
+		return fmt.Errorf("writing the report: %w", err)
+	}
+
+	var failed []string
+	for _, s := range r.Steps {
+		if s.Status == preflightFail {
+			failed = append(failed, s.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed steps: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// doctor runs the diagnostics against the mount at mountPoint.
+func doctor(mountPoint string) *doctorReport {
+	r := &doctorReport{
+		Version:    common.GetVersion(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		CPUs:       runtime.NumCPU(),
+		MountPoint: mountPoint,
+	}
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err == nil {
+		r.Kernel = unix.ByteSliceToString(uts.Release[:])
+	}
+
+	r.Steps = append(r.Steps, doctorFuseModule(), doctorMount(mountPoint), doctorReadAhead(mountPoint))
+	r.Steps = append(r.Steps, timed("stat-root", func() (string, error) {
+		fi, err := os.Stat(mountPoint)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("mode %v", fi.Mode()), nil
+	}))
+	r.Steps = append(r.Steps, timed("list", func() (string, error) {
+		d, err := os.Open(mountPoint)
+		if err != nil {
+			return "", err
+		}
+		defer d.Close()
+		entries, err := d.ReadDir(doctorListSample)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return fmt.Sprintf("%d entries (at most %d listed)", len(entries), doctorListSample), nil
+	}))
+	return r.withFileSteps(mountPoint)
+}
+
+// withFileSteps adds the steps writing, reading back and deleting a file in a
+// temporary directory of the mount.
+func (r *doctorReport) withFileSteps(mountPoint string) *doctorReport {
+	skip := func(reason string, names ...string) *doctorReport {
+		for _, name := range names {
+			r.Steps = append(r.Steps, doctorStep{Name: name, Status: preflightSkipped, Detail: reason})
+		}
+		return r
+	}
+	var st syscall.Statfs_t
+	if err := statfs(mountPoint, &st); err == nil && st.Flags&unix.ST_RDONLY != 0 {
+		return skip("the mount is read-only", "write", "read", "delete")
+	}
+
+	dir, err := os.MkdirTemp(mountPoint, ".gcsfuse-doctor-")
+	if err != nil {
+		r.Steps = append(r.Steps, doctorStep{Name: "write", Status: preflightFail, Detail: fmt.Sprintf("creating a temporary directory: %v", err)})
+		return skip("nothing was written", "read", "delete")
+	}
+	// Leave no trace, whatever the steps below find.
+	defer os.RemoveAll(dir)
+
+	contents := make([]byte, doctorFileSize)
+	_, _ = rand.Read(contents)
+	file := filepath.Join(dir, "file")
+	written := timed("write", func() (string, error) {
+		// Closing the file uploads it.
+		f, err := os.Create(file)
+		if err != nil {
+			return "", err
+		}
+		if _, err = f.Write(contents); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err = f.Close(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d bytes in %s", doctorFileSize, filepath.Base(dir)), nil
+	})
+	r.Steps = append(r.Steps, written)
+	if written.Status != preflightOK {
+		return skip("the write failed", "read", "delete")
+	}
+	r.Steps = append(r.Steps, timed("read", func() (string, error) {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(got, contents) {
+			return "", fmt.Errorf("read back %d bytes differing from the %d written", len(got), len(contents))
+		}
+		return fmt.Sprintf("%d bytes", len(got)), nil
+	}))
+	r.Steps = append(r.Steps, timed("delete", func() (string, error) {
+		if err := os.Remove(file); err != nil {
+			return "", err
+		}
+		return "removed the file and its directory", os.Remove(dir)
+	}))
+	return r
+}
+
+// timed runs f as the step with the supplied name, and measures its latency.
+func timed(name string, f func() (string, error)) doctorStep {
+	start := time.Now()
+	detail, err := f()
+	s := doctorStep{
+		Name:      name,
+		Status:    preflightOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:    detail,
+	}
+	if err != nil {
+		s.Status = preflightFail
+		s.Detail = err.Error()
+	}
+	return s
+}
+
+func doctorFuseModule() doctorStep {
+	s := doctorStep{Name: "fuse-module"}
+	if _, err := os.Stat(doctorFuseDevice); err != nil {
+		s.Status = preflightFail
+		s.Detail = fmt.Sprintf("%s is missing, load the fuse module with 'modprobe fuse': %v", doctorFuseDevice, err)
+		return s
+	}
+	s.Status = preflightOK
+	s.Detail = fmt.Sprintf("%s is present", doctorFuseDevice)
+	// The module may be built into the kernel, without parameters.
+	if bgreq, err := os.ReadFile(filepath.Join(doctorFuseModuleDir, "parameters", "max_user_bgreq")); err == nil {
+		s.Detail += fmt.Sprintf(", max_user_bgreq %s", strings.TrimSpace(string(bgreq)))
+	}
+	return s
+}
+
+func doctorMount(mountPoint string) doctorStep {
+	s := doctorStep{Name: "mount"}
+	var st syscall.Statfs_t
+	if err := statfs(mountPoint, &st); err != nil {
+		s.Status = preflightFail
+		s.Detail = err.Error()
+		return s
+	}
+	if uint32(st.Type) != fuseSuperMagic {
+		s.Status = preflightFail
+		s.Detail = fmt.Sprintf("%s isn't a FUSE mount", mountPoint)
+		return s
+	}
+	s.Status = preflightOK
+	s.Detail = "FUSE mount"
+	if st.Flags&unix.ST_RDONLY != 0 {
+		s.Detail += ", read-only"
+	}
+	return s
+}
+
+func doctorReadAhead(mountPoint string) doctorStep {
+	s := doctorStep{Name: "read-ahead"}
+	var st unix.Stat_t
+	if err := unix.Stat(mountPoint, &st); err != nil {
+		s.Status = preflightFail
+		s.Detail = err.Error()
+		return s
+	}
+	f := filepath.Join(doctorBdiDir, fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)), "read_ahead_kb")
+	b, err := os.ReadFile(f)
+	if err != nil {
+		s.Status = preflightSkipped
+		s.Detail = fmt.Sprintf("reading the read-ahead: %v", err)
+		return s
+	}
+	kb, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		s.Status = preflightFail
+		s.Detail = fmt.Sprintf("parsing %s: %v", f, err)
+		return s
+	}
+	s.Status = preflightOK
+	s.Detail = fmt.Sprintf("%d KiB", kb)
+	if kb < doctorMinReadAheadKb {
+		s.Status = preflightWarn
+		s.Detail += fmt.Sprintf(", the sequential reads may be faster with --max-read-ahead-kb=%d or more", doctorMinReadAheadKb)
+	}
+	return s
+}
+
+// writeText writes the report as aligned columns.
+func (r *doctorReport) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "gcsfuse:     %s\n", r.Version)
+	fmt.Fprintf(w, "kernel:      %s\n", r.Kernel)
+	fmt.Fprintf(w, "platform:    %s, %d CPUs\n", r.Platform, r.CPUs)
+	fmt.Fprintf(w, "mount point: %s\n\n", r.MountPoint)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tLATENCY\tDETAIL")
+	for _, s := range r.Steps {
+		latency := "-"
+		if s.LatencyMs > 0 {
+			latency = fmt.Sprintf("%.1fms", s.LatencyMs)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.Status, latency, s.Detail)
+	}
+	return tw.Flush()
+}
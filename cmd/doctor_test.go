@@ -0,0 +1,151 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// fakeDoctorKernel points the doctor at kernel files in a temporary directory,
+// with a read-ahead of readAheadKb for the file system of dir.
+func fakeDoctorKernel(t *testing.T, dir string, readAheadKb int) {
+	t.Helper()
+	root := t.TempDir()
+	oldDevice, oldModule, oldBdi := doctorFuseDevice, doctorFuseModuleDir, doctorBdiDir
+	doctorFuseDevice = filepath.Join(root, "fuse")
+	doctorFuseModuleDir = filepath.Join(root, "module")
+	doctorBdiDir = filepath.Join(root, "bdi")
+	t.Cleanup(func() { doctorFuseDevice, doctorFuseModuleDir, doctorBdiDir = oldDevice, oldModule, oldBdi })
+
+	require.NoError(t, os.WriteFile(doctorFuseDevice, nil, 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(doctorFuseModuleDir, "parameters"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(doctorFuseModuleDir, "parameters", "max_user_bgreq"), []byte("12\n"), 0644))
+	var st unix.Stat_t
+	require.NoError(t, unix.Stat(dir, &st))
+	bdi := filepath.Join(doctorBdiDir, fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)))
+	require.NoError(t, os.MkdirAll(bdi, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bdi, "read_ahead_kb"), []byte(fmt.Sprintf("%d\n", readAheadKb)), 0644))
+}
+
+// steps returns the steps of the report by name.
+func steps(r *doctorReport) map[string]doctorStep {
+	m := make(map[string]doctorStep)
+	for _, s := range r.Steps {
+		m[s.Name] = s
+	}
+	return m
+}
+
+func TestIsDoctorCmd(t *testing.T) {
+	fakeStatfs(t, fuseSuperMagic)
+	mountPoint := t.TempDir()
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{args: []string{"gcsfuse", "doctor", mountPoint}, expected: true},
+		{args: []string{"gcsfuse", "doctor", "--json", mountPoint}, expected: true},
+		// Mounts of a bucket named doctor.
+		{args: []string{"gcsfuse", "doctor", filepath.Join(mountPoint, "missing")}, expected: false},
+		{args: []string{"gcsfuse", "doctor", mountPoint, "--temp-dir", "/tmp"}, expected: false},
+		{args: []string{"gcsfuse", "--implicit-dirs", "doctor", mountPoint}, expected: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isDoctorCmd(tc.args), "args: %v", tc.args)
+	}
+}
+
+func TestIsDoctorCmd_NotAFuseMount(t *testing.T) {
+	fakeStatfs(t, 0xef53) // ext4
+
+	assert.False(t, isDoctorCmd([]string{"gcsfuse", "doctor", t.TempDir()}))
+}
+
+func TestDoctor(t *testing.T) {
+	fakeStatfs(t, fuseSuperMagic)
+	mountPoint := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(mountPoint, "a"), nil, 0644))
+	fakeDoctorKernel(t, mountPoint, 4096)
+
+	r := doctor(mountPoint)
+
+	var names []string
+	for _, s := range r.Steps {
+		names = append(names, s.Name)
+		assert.Equal(t, preflightOK, s.Status, "%s: %s", s.Name, s.Detail)
+	}
+	assert.Equal(t, []string{"fuse-module", "mount", "read-ahead", "stat-root", "list", "write", "read", "delete"}, names)
+	assert.Contains(t, steps(r)["fuse-module"].Detail, "max_user_bgreq 12")
+	assert.Equal(t, "4096 KiB", steps(r)["read-ahead"].Detail)
+	assert.Equal(t, "1 entries (at most 100 listed)", steps(r)["list"].Detail)
+	assert.Greater(t, steps(r)["write"].LatencyMs, 0.0)
+	// The temporary directory is gone.
+	entries, err := os.ReadDir(mountPoint)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestDoctor_Warnings(t *testing.T) {
+	fakeStatfs(t, 0xef53)
+	mountPoint := t.TempDir()
+	fakeDoctorKernel(t, mountPoint, 128)
+	require.NoError(t, os.Remove(doctorFuseDevice))
+
+	r := doctor(mountPoint)
+
+	assert.Equal(t, preflightFail, steps(r)["fuse-module"].Status)
+	assert.Contains(t, steps(r)["fuse-module"].Detail, "modprobe fuse")
+	assert.Equal(t, preflightFail, steps(r)["mount"].Status)
+	assert.Equal(t, preflightWarn, steps(r)["read-ahead"].Status)
+	assert.Contains(t, steps(r)["read-ahead"].Detail, "--max-read-ahead-kb")
+}
+
+func TestRunDoctor(t *testing.T) {
+	fakeStatfs(t, fuseSuperMagic)
+	mountPoint := t.TempDir()
+	fakeDoctorKernel(t, mountPoint, 4096)
+	var out bytes.Buffer
+
+	require.NoError(t, runDoctor(mountPoint, false, &out))
+
+	assert.Contains(t, out.String(), "mount point: "+mountPoint+"\n")
+	assert.Regexp(t, `(?m)^STEP +STATUS +LATENCY +DETAIL$`, out.String())
+	assert.Regexp(t, `(?m)^write +ok +[0-9.]+ms +65536 bytes`, out.String())
+}
+
+func TestRunDoctor_JSON(t *testing.T) {
+	fakeStatfs(t, 0xef53)
+	mountPoint := t.TempDir()
+	fakeDoctorKernel(t, mountPoint, 4096)
+	var out bytes.Buffer
+
+	err := runDoctor(mountPoint, true, &out)
+
+	require.ErrorContains(t, err, "failed steps: mount")
+	var r doctorReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &r))
+	assert.Equal(t, mountPoint, r.MountPoint)
+	assert.Len(t, r.Steps, 8)
+}
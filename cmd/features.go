@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// newFeaturesCmd returns the command listing and flipping the kill switches of
+// the experimental subsystems of a running mount, through its control socket.
+func newFeaturesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gcsfuse features mount_point (list | name on|off)",
+		Short: "List or flip the kill switches of the experimental features of a mount",
+		Long: `Lists the kill switches of the experimental features of a mount, e.g.
+streaming writes or parallel downloads, or turns one of them on or off. A
+feature turned off stops being used by the operations started afterwards,
+without unmounting. The switches are reset when the bucket is mounted again.
+The mount must serve a control socket (--control-socket).`,
+		Args:         cobra.RangeArgs(2, 3),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFeatures(cmd.Context(), args[0], args[1:], cmd.OutOrStdout())
+		},
+	}
+}
+
+// isFeaturesCmd returns true if args, including the program name, invoke the
+// features command rather than mount a bucket named features, which takes at
+// most two arguments.
+func isFeaturesCmd(args []string) bool {
+	if len(args) < 2 || args[1] != "features" {
+		return false
+	}
+	c := newFeaturesCmd()
+	if err := c.ParseFlags(args[2:]); err != nil {
+		return false
+	}
+	n := len(c.Flags().Args())
+	return n == 3 || n == 2 && c.Flags().Arg(1) == "list"
+}
+
+func runFeatures(ctx context.Context, mountPoint string, args []string, w io.Writer) error {
+	var enabled bool
+	switch {
+	case len(args) == 1 && args[0] == "list":
+	case len(args) == 2 && args[1] == "on":
+		enabled = true
+	case len(args) == 2 && args[1] == "off":
+	default:
+		return fmt.Errorf("expected list or a feature name followed by on or off, got %q", args)
+	}
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+	socket, err := controlSocketPath(mountPoint)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(args) == 1 {
+		return control.Features(ctx, socket, w)
+	}
+	return control.SetFeature(ctx, socket, args[0], enabled, w)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFeaturesCmd(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{args: []string{"gcsfuse", "features", "/mnt", "list"}, expected: true},
+		{args: []string{"gcsfuse", "features", "/mnt", "streaming-writes", "off"}, expected: true},
+		// Mounts of a bucket named features.
+		{args: []string{"gcsfuse", "features", "/mnt"}, expected: false},
+		{args: []string{"gcsfuse", "features", "/mnt", "--temp-dir", "/tmp"}, expected: false},
+		{args: []string{"gcsfuse", "--implicit-dirs", "features", "/mnt"}, expected: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isFeaturesCmd(tc.args), "args: %v", tc.args)
+	}
+}
+
+func TestRunFeatures(t *testing.T) {
+	f := featureflag.Register("cmd-test", "A test feature.")
+	defer func() { require.NoError(t, featureflag.Set("cmd-test", true)) }()
+	mountPoint := fakeMount(t, nil, nil)
+	var out bytes.Buffer
+
+	err := runFeatures(context.Background(), mountPoint, []string{"cmd-test", "off"}, &out)
+
+	require.NoError(t, err)
+	assert.False(t, f.Enabled())
+	out.Reset()
+	require.NoError(t, runFeatures(context.Background(), mountPoint, []string{"list"}, &out))
+	assert.Contains(t, out.String(), "cmd-test off: A test feature.\n")
+}
+
+func TestRunFeatures_InvalidArgs(t *testing.T) {
+	err := runFeatures(context.Background(), t.TempDir(), []string{"streaming-writes", "disable"}, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "expected list or a feature name followed by on or off")
+}
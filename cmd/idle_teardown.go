@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/quiesce"
+)
+
+// idleTeardownCheckInterval is the longest interval at which gcsfuse checks
+// whether the mount has been idle for idle-teardown-after.
+const idleTeardownCheckInterval = 10 * time.Second
+
+// idleTeardown releases the resources of the mount once it's been idle for
+// idleAfter, and again each time it's idle again after some ops.
+type idleTeardown struct {
+	idleAfter time.Duration
+	idle      *idleTimer
+	release   func() (evicted uint64)
+
+	// Whether the resources have been released since the mount was last
+	// active.
+	released bool
+}
+
+// check releases the resources if the mount is due to be torn down at now,
+// and returns whether it did.
+func (t *idleTeardown) check(now time.Time) bool {
+	if t.idle.idleFor(now) < t.idleAfter {
+		t.released = false
+		return false
+	}
+	if t.released {
+		return false
+	}
+	evicted := t.release()
+	t.released = true
+	logger.Infof("The mount has been idle for %v, released its connections to GCS, readers and %d KiB of cached metadata.", t.idleAfter, evicted>>10)
+	return true
+}
+
+// releaseWhileIdle releases the resources of the mount with releaser while
+// it's idle with idle-teardown-after, until ctx is done. They're recreated
+// lazily by the next ops.
+func releaseWhileIdle(ctx context.Context, idleAfter time.Duration, activity func() (started, inFlight int64), releaser *quiesce.Releaser) {
+	t := &idleTeardown{
+		idleAfter: idleAfter,
+		idle:      newIdleTimer(activity, time.Now()),
+		release:   releaser.Release,
+	}
+	ticker := time.NewTicker(min(idleTeardownCheckInterval, idleAfter))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.check(now)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleTeardown(t *testing.T) {
+	var started int64
+	activity := func() (int64, int64) { return started, 0 }
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	releases := 0
+	teardown := &idleTeardown{
+		idleAfter: 10 * time.Minute,
+		idle:      newIdleTimer(activity, start),
+		release: func() uint64 {
+			releases++
+			return 0
+		},
+	}
+
+	assert.False(t, teardown.check(start.Add(5*time.Minute)))
+	assert.True(t, teardown.check(start.Add(10*time.Minute)))
+	// The resources are released once per idle period.
+	assert.False(t, teardown.check(start.Add(20*time.Minute)))
+	assert.Equal(t, 1, releases)
+
+	// The mount is used again, and then idle again.
+	started = 1
+	assert.False(t, teardown.check(start.Add(21*time.Minute)))
+	assert.False(t, teardown.check(start.Add(30*time.Minute)))
+	assert.True(t, teardown.check(start.Add(31*time.Minute)))
+	assert.Equal(t, 2, releases)
+}
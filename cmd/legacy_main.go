@@ -21,18 +21,23 @@ package cmd
 
 import (
 	"fmt"
-	"io/fs"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/canned"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/control"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/locality"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/monitor"
@@ -55,7 +60,17 @@ const (
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
-func registerTerminatingSignalHandler(mountPoint string, c *cfg.Config) {
+// drainRetryInterval is the interval at which gcsfuse retries unmounting in
+// container mode while files are open in the mount.
+const drainRetryInterval = time.Second
+
+// registerTerminatingSignalHandler unmounts the file system on SIGINT, and on
+// SIGTERM if enabled. draining is set once a signal is received.
+//
+// In container mode, the mount is drained: as unmounting fails while files
+// are open, it's retried until they're closed, rather than on the next
+// signal.
+func registerTerminatingSignalHandler(mountPoint string, c *cfg.Config, draining *atomic.Bool) {
 	// Register for SIGINT.
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt)
@@ -65,8 +80,8 @@ func registerTerminatingSignalHandler(mountPoint string, c *cfg.Config) {
 
 	// Start a goroutine that will unmount when the signal is received.
 	go func() {
+		sig := <-signalChan
 		for {
-			sig := <-signalChan
 			sigName := "undefined"
 			switch sig {
 			case unix.SIGTERM:
@@ -75,14 +90,30 @@ func registerTerminatingSignalHandler(mountPoint string, c *cfg.Config) {
 				sigName = "SIGINT"
 			}
 			logger.Infof("Received %s, attempting to unmount...", sigName)
+			draining.Store(true)
 
 			err := fuse.Unmount(mountPoint)
-			if err != nil {
-				logger.Errorf("Failed to unmount in response to %s: %v", sigName, err)
-			} else {
+			if err == nil {
 				logger.Infof("Successfully unmounted in response to %s.", sigName)
 				return
 			}
+			if !c.Container {
+				logger.Errorf("Failed to unmount in response to %s: %v", sigName, err)
+				sig = <-signalChan
+				continue
+			}
+
+			logger.Infof("Waiting for the files open in %s to be closed to unmount: %v", mountPoint, err)
+			for err != nil {
+				select {
+				case sig = <-signalChan:
+					logger.Infof("Received another signal while draining, still waiting for the open files to be closed.")
+				case <-time.After(drainRetryInterval):
+				}
+				err = fuse.Unmount(mountPoint)
+			}
+			logger.Infof("Successfully unmounted in response to %s.", sigName)
+			return
 		}
 	}()
 }
@@ -115,7 +146,7 @@ func getConfigForUserAgent(mountConfig *cfg.Config) string {
 	}
 	return fmt.Sprintf("%s:%s:%s", isFileCacheEnabled, isFileCacheForRangeReadEnabled, isParallelDownloadsEnabled)
 }
-func createStorageHandle(newConfig *cfg.Config, userAgent string) (storageHandle storage.StorageHandle, err error) {
+func createStorageHandle(newConfig *cfg.Config, userAgent string, metricHandle common.MetricHandle) (storageHandle storage.StorageHandle, err error) {
 	storageClientConfig := storageutil.StorageClientConfig{
 		ClientProtocol:             newConfig.GcsConnection.ClientProtocol,
 		MaxConnsPerHost:            int(newConfig.GcsConnection.MaxConnsPerHost),
@@ -123,29 +154,77 @@ func createStorageHandle(newConfig *cfg.Config, userAgent string) (storageHandle
 		HttpClientTimeout:          newConfig.GcsConnection.HttpClientTimeout,
 		MaxRetrySleep:              newConfig.GcsRetries.MaxRetrySleep,
 		MaxRetryAttempts:           int(newConfig.GcsRetries.MaxRetryAttempts),
+		MaxConnectionRetryAttempts: int(newConfig.GcsRetries.MaxConnectionRetryAttempts),
 		RetryMultiplier:            newConfig.GcsRetries.Multiplier,
 		UserAgent:                  userAgent,
 		CustomEndpoint:             newConfig.GcsConnection.CustomEndpoint,
 		KeyFile:                    string(newConfig.GcsAuth.KeyFile),
+		CredentialConfigFile:       string(newConfig.GcsAuth.CredentialConfigFile),
+		ExternalCredentialCommand:  newConfig.GcsAuth.ExternalCredentialCommand,
+		ImpersonateServiceAccount:  newConfig.GcsAuth.ImpersonateServiceAccount,
+		ProxyURL:                   newConfig.GcsConnection.ProxyUrl,
+		ProxyHeaders:               newConfig.GcsConnection.ProxyHeaders,
+		LogSlowRequests:            newConfig.Logging.SlowOps.Threshold > 0,
+		EnableTracing:              cfg.IsTracingEnabled(newConfig),
+		IdleConnections:            storageutil.NewIdleConnections(newConfig.FileSystem.IdleTeardownAfter),
 		AnonymousAccess:            newConfig.GcsAuth.AnonymousAccess,
+		ReadOnly:                   cfg.IsReadOnlyMount(newConfig),
+		SingleShotUploadThreshold:  newConfig.Write.SingleShotUploadThresholdKb * 1024,
 		TokenUrl:                   newConfig.GcsAuth.TokenUrl,
 		ReuseTokenFromUrl:          newConfig.GcsAuth.ReuseTokenFromUrl,
 		ExperimentalEnableJsonRead: newConfig.GcsConnection.ExperimentalEnableJsonRead,
+		PreferNearestRegionReads:   newConfig.GcsConnection.PreferNearestRegionReads,
 		GrpcConnPoolSize:           int(newConfig.GcsConnection.GrpcConnPoolSize),
 		EnableHNS:                  newConfig.EnableHns,
 		ReadStallRetryConfig:       newConfig.GcsRetries.ReadStall,
+		MetricHandle:               metricHandle,
 	}
 	logger.Infof("UserAgent = %s\n", storageClientConfig.UserAgent)
 	storageHandle, err = storage.NewStorageHandle(context.Background(), storageClientConfig)
 	return
 }
 
+// isThroughputCritical returns true if the mount has been configured for high
+// read or write throughput.
+func isThroughputCritical(mountConfig *cfg.Config) bool {
+	return cfg.IsParallelDownloadsEnabled(mountConfig) || mountConfig.Write.ExperimentalEnableStreamingWrites
+}
+
+// adviseOnBucketLocation compares the location of the bucket against the zone
+// of the VM and emits a warning along with a metric when they aren't
+// co-located. Failures are logged and otherwise ignored, as this is only
+// advisory.
+func adviseOnBucketLocation(ctx context.Context, storageHandle storage.StorageHandle, bucketName string, newConfig *cfg.Config, metricHandle common.MetricHandle) {
+	zone, err := locality.VMZone(ctx)
+	if err != nil {
+		logger.Debugf("Skipping bucket location check: %v", err)
+		return
+	}
+	if zone == "" {
+		// Not running on GCE.
+		return
+	}
+
+	location, locationType, err := storageHandle.BucketLocation(ctx, bucketName, newConfig.GcsConnection.BillingProject)
+	if err != nil {
+		logger.Debugf("Skipping bucket location check: %v", err)
+		return
+	}
+
+	advice := locality.Advise(bucketName, location, locationType, zone, isThroughputCritical(newConfig))
+	if advice.Reason == "" {
+		return
+	}
+	logger.Warnf("%s", advice.Message)
+	metricHandle.GCSBucketLocationMismatchCount(ctx, 1, []common.MetricAttr{{Key: common.LocationMismatchReason, Value: advice.Reason}})
+}
+
 ////////////////////////////////////////////////////////////////////////
 // main logic
 ////////////////////////////////////////////////////////////////////////
 
 // Mount the file system according to arguments in the supplied context.
-func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config, metricHandle common.MetricHandle) (mfs *fuse.MountedFileSystem, err error) {
+func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config, metricHandle common.MetricHandle, prefetcher *fs.CachePrefetcher, differ *fs.GenerationDiffer, uploads *fs.UploadMonitor, opStats *wrappers.OpStats) (mfs *fuse.MountedFileSystem, err error) {
 	// Enable invariant checking if requested.
 	if newConfig.Debug.ExitOnInvariantViolation {
 		locker.EnableInvariantsCheck()
@@ -154,6 +233,12 @@ func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config,
 		locker.EnableDebugMessages()
 	}
 
+	if newConfig.CpuSet != "" {
+		if err = pinToCPUSet(newConfig.CpuSet); err != nil {
+			return
+		}
+	}
+
 	// Grab the connection.
 	//
 	// Special case: if we're mounting the fake bucket, we don't need an actual
@@ -162,11 +247,17 @@ func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config,
 	if bucketName != canned.FakeBucketName {
 		userAgent := getUserAgent(newConfig.AppName, getConfigForUserAgent(newConfig))
 		logger.Info("Creating Storage handle...")
-		storageHandle, err = createStorageHandle(newConfig, userAgent)
+		storageHandle, err = createStorageHandle(newConfig, userAgent, metricHandle)
 		if err != nil {
 			err = fmt.Errorf("failed to create storage handle using createStorageHandle: %w", err)
 			return
 		}
+
+		// The bucket location can't be compared for dynamic mounts or against
+		// custom endpoints, and the check shouldn't delay the mount.
+		if !isDynamicMount(bucketName) && newConfig.GcsConnection.CustomEndpoint == "" {
+			go adviseOnBucketLocation(context.Background(), storageHandle, bucketName, newConfig, metricHandle)
+		}
 	}
 
 	// Mount the file system.
@@ -177,7 +268,11 @@ func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config,
 		mountPoint,
 		newConfig,
 		storageHandle,
-		metricHandle)
+		metricHandle,
+		prefetcher,
+		differ,
+		uploads,
+		opStats)
 
 	if err != nil {
 		err = fmt.Errorf("mountWithStorageHandle: %w", err)
@@ -221,40 +316,21 @@ func populateArgs(args []string) (
 	return
 }
 
-func callListRecursive(mountPoint string) (err error) {
-	logger.Debugf("Started recursive metadata-prefetch of directory: \"%s\" ...", mountPoint)
-	numItems := 0
-	err = filepath.WalkDir(mountPoint, func(path string, d fs.DirEntry, err error) error {
-		if err == nil {
-			numItems++
-			return err
-		}
-		if d == nil {
-			return fmt.Errorf("got error walking: path=\"%s\" does not exist, error = %w", path, err)
-		}
-		return fmt.Errorf("got error walking: path=\"%s\", dentry=\"%s\", isDir=%v, error = %w", path, d.Name(), d.IsDir(), err)
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed in recursive metadata-prefetch of directory: \"%s\"; error = %w", mountPoint, err)
-	}
-
-	logger.Debugf("... Completed recursive metadata-prefetch of directory: \"%s\". Number of items discovered: %v", mountPoint, numItems)
-
-	return nil
-}
-
 func isDynamicMount(bucketName string) bool {
 	return bucketName == "" || bucketName == "_"
 }
 
-func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
+func Mount(newConfig *cfg.Config, bucketName, mountPoint string, tuning *machineTuning) (err error) {
 	// Ideally this call to SetLogFormat (which internally creates a new defaultLogger)
 	// should be set as an else to the 'if flags.Foreground' check below, but currently
 	// that means the logs generated by resolveConfigFilePaths below don't honour
 	// the user-provided log-format.
 	logger.SetLogFormat(newConfig.Logging.Format)
 
+	if newConfig.Diagnose {
+		return diagnose(newConfig, bucketName, mountPoint, os.Stdout)
+	}
+
 	if newConfig.Foreground {
 		err = logger.InitLogFile(newConfig.Logging)
 		if err != nil {
@@ -262,6 +338,12 @@ func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
 		}
 	}
 
+	// The read workers of experimental-read-worker-processes run gcsfuse with
+	// the arguments of the file system process.
+	if socketPath, ok := os.LookupEnv(readWorkerSocketEnv); ok {
+		return serveReadWorker(newConfig, socketPath)
+	}
+
 	logger.Infof("Start gcsfuse/%s for app %q using mount point: %s\n", common.GetVersion(), newConfig.AppName, mountPoint)
 
 	// Log mount-config and the CLI flags in the log-file.
@@ -273,6 +355,10 @@ func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
 		logger.Info("GCSFuse config", "config", newConfig)
 	}
 
+	if tuning != nil {
+		logger.Infof("Machine profile: %s; tuned config: %v", tuning.profile, tuning.tunedParams)
+	}
+
 	// The following will not warn if the user explicitly passed the default value for StatCacheCapacity.
 	if newConfig.MetadataCache.DeprecatedStatCacheCapacity != mount.DefaultStatCacheCapacity {
 		logger.Warnf("Deprecated flag stat-cache-capacity used! Please switch to config parameter 'metadata-cache: stat-cache-max-size-mb'.")
@@ -283,6 +369,16 @@ func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
 		logger.Warnf("Deprecated flag stat-cache-ttl and/or type-cache-ttl used! Please switch to config parameter 'metadata-cache: ttl-secs' .")
 	}
 
+	// Fail early on misconfigurations, before daemonizing, rather than in the
+	// logs of the daemon. The daemon doesn't repeat the checks.
+	if _, inBackground := os.LookupEnv(logger.GCSFuseInBackgroundMode); !newConfig.DisablePreflightChecks && !inBackground {
+		d := runPreflightChecks(newConfig, bucketName, mountPoint, newPreflightProber(newConfig))
+		d.logWarnings()
+		if err = d.err(); err != nil {
+			return fmt.Errorf("%w; use --disable-preflight-checks to mount anyway", err)
+		}
+	}
+
 	// If we haven't been asked to run in foreground mode, we should run a daemon
 	// with the foreground flag set and wait for it to mount.
 	if !newConfig.Foreground {
@@ -394,13 +490,21 @@ func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
 	// Mount, writing information about our progress to the writer that package
 	// daemonize gives us and telling it about the outcome.
 	var mfs *fuse.MountedFileSystem
+	prefetcher := &fs.CachePrefetcher{}
+	differ := &fs.GenerationDiffer{}
+	uploads := &fs.UploadMonitor{}
+	opStats := &wrappers.OpStats{}
 	{
-		mfs, err = mountWithArgs(bucketName, mountPoint, newConfig, metricHandle)
+		mfs, err = mountWithArgs(bucketName, mountPoint, newConfig, metricHandle, prefetcher, differ, uploads, opStats)
 
 		// This utility is to absorb the error
 		// returned by daemonize.SignalOutcome calls by simply
 		// logging them as error logs.
 		callDaemonizeSignalOutcome := func(err error) {
+			// A container isn't started by a parent gcsfuse process.
+			if newConfig.Container {
+				return
+			}
 			if err2 := daemonize.SignalOutcome(err); err2 != nil {
 				logger.Errorf("Failed to signal error to parent-process from daemon: %v", err2)
 			}
@@ -425,26 +529,39 @@ func Mount(newConfig *cfg.Config, bucketName, mountPoint string) (err error) {
 			markMountFailure(err)
 			return err
 		}
-		if !isDynamicMount(bucketName) {
-			switch newConfig.MetadataCache.ExperimentalMetadataPrefetchOnMount {
-			case cfg.ExperimentalMetadataPrefetchOnMountSynchronous:
-				if err = callListRecursive(mountPoint); err != nil {
-					markMountFailure(err)
-					return err
-				}
-			case cfg.ExperimentalMetadataPrefetchOnMountAsynchronous:
-				go func() {
-					if err := callListRecursive(mountPoint); err != nil {
-						logger.Errorf("Metadata-prefetch failed: %v", err)
-					}
-				}()
-			}
-		}
 		markSuccessfulMount()
 	}
 
+	if newConfig.Debug.ControlSocket != "" {
+		var handler http.Handler
+		if cfg.IsFileCacheEnabled(newConfig) {
+			handler = control.NewHandler(prefetcher, differ, uploads)
+		} else {
+			handler = control.NewHandler(nil, differ, uploads)
+		}
+		controlServer, err := control.Listen(string(newConfig.Debug.ControlSocket), handler)
+		if err != nil {
+			logger.Warnf("Failed to serve the control socket: %v", err)
+		} else {
+			defer controlServer.Close()
+		}
+	}
+
+	var draining atomic.Bool
+	if newConfig.Container && newConfig.ContainerHealthPort > 0 {
+		healthServer, err := control.ListenTCP(fmt.Sprintf(":%d", newConfig.ContainerHealthPort), control.NewHealthHandler(mountPoint, draining.Load))
+		if err != nil {
+			logger.Warnf("Failed to serve the health of the mount: %v", err)
+		} else {
+			defer healthServer.Close()
+		}
+	}
+
 	// Let the user unmount with Ctrl-C (SIGINT).
-	registerTerminatingSignalHandler(mfs.Dir(), newConfig)
+	registerTerminatingSignalHandler(mfs.Dir(), newConfig, &draining)
+
+	// Unmount batch jobs' mounts once they're done with them.
+	registerAutoUnmount(mfs.Dir(), newConfig, opStats.Activity, &draining)
 
 	// Wait for the file system to be unmounted.
 	if err = mfs.Join(ctx); err != nil {
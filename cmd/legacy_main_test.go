@@ -44,7 +44,7 @@ func (t *MainTest) TestCreateStorageHandle() {
 		GcsAuth:       cfg.GcsAuthConfig{KeyFile: "testdata/test_creds.json"}}
 
 	userAgent := "AppName"
-	storageHandle, err := createStorageHandle(newConfig, userAgent)
+	storageHandle, err := createStorageHandle(newConfig, userAgent, common.NewNoopMetrics())
 
 	assert.Equal(t.T(), nil, err)
 	assert.NotEqual(t.T(), nil, storageHandle)
@@ -57,7 +57,7 @@ func (t *MainTest) TestCreateStorageHandle_WithClientProtocolAsGRPC() {
 	}
 
 	userAgent := "AppName"
-	storageHandle, err := createStorageHandle(newConfig, userAgent)
+	storageHandle, err := createStorageHandle(newConfig, userAgent, common.NewNoopMetrics())
 
 	assert.Equal(t.T(), nil, err)
 	assert.NotEqual(t.T(), nil, storageHandle)
@@ -190,32 +190,6 @@ func (t *MainTest) TestGetUserAgentWhenMetadataImageTypeEnvVarSetAndAppNameNotSe
 	assert.Equal(t.T(), expectedUserAgent, userAgent)
 }
 
-func (t *MainTest) TestCallListRecursiveOnExistingDirectory() {
-	// Set up a mini file-system to test on.
-	rootdir, err := os.MkdirTemp("/tmp", "TestCallListRecursive-*")
-	if err != nil {
-		t.T().Fatalf("Failed to set up test. error = %v", err)
-	}
-	defer os.RemoveAll(rootdir)
-	_, err = os.CreateTemp(rootdir, "abc-*.txt")
-	if err != nil {
-		t.T().Fatalf("Failed to set up test. error = %v", err)
-	}
-
-	err = callListRecursive(rootdir)
-
-	assert.Nil(t.T(), err)
-}
-
-func (t *MainTest) TestCallListRecursiveOnNonExistingDirectory() {
-	// Set up a mini file-system to test on, which must fail.
-	rootdir := "/path/to/non/existing/directory"
-
-	err := callListRecursive(rootdir)
-
-	assert.ErrorContains(t.T(), err, "does not exist")
-}
-
 func (t *MainTest) TestIsDynamicMount() {
 	for _, input := range []struct {
 		bucketName string
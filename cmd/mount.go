@@ -15,8 +15,10 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
@@ -25,15 +27,59 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
 	"golang.org/x/net/context"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufpool"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/lifecycle"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/memorygovernor"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/monitor"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/perms"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/quiesce"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/ratelimit"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/readworker"
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fsutil"
 	"github.com/jacobsa/timeutil"
 )
 
+// How often the fuse kernel queue of the mount is sampled for metrics.
+const kernelQueueSampleInterval = 5 * time.Second
+
+// How often the utilization of the buffer pool of the read paths is recorded
+// in the metrics.
+const bufferPoolReportInterval = 5 * time.Second
+
+// How often GCS is probed while it's unreachable, with offline-mode
+// serve-cache.
+const offlineProbeInterval = 10 * time.Second
+
+// readLifecycleRules reads the lifecycle rules of the mounted bucket for
+// file-system.lifecycle-hint-window, or returns nil if they can't be read.
+func readLifecycleRules(ctx context.Context, storageHandle storage.StorageHandle, bucketName string, newConfig *cfg.Config) *lifecycle.Rules {
+	if isDynamicMount(bucketName) {
+		logger.Warnf("Not reading the lifecycle rules of the bucket: file-system.lifecycle-hint-window needs a single bucket mounted")
+		return nil
+	}
+	if storageHandle == nil {
+		// The fake bucket has no lifecycle rules.
+		return nil
+	}
+
+	rules, err := storageHandle.BucketLifecycle(ctx, bucketName, newConfig.GcsConnection.BillingProject)
+	if err != nil {
+		logger.Warnf("Not reading the lifecycle rules of the bucket: %v", err)
+		return nil
+	}
+	var namePrefix string
+	if newConfig.OnlyDir != "" {
+		namePrefix = path.Clean(newConfig.OnlyDir) + "/"
+	}
+	return lifecycle.New(rules, namePrefix)
+}
+
 // Mount the file system based on the supplied arguments, returning a
 // fuse.MountedFileSystem that can be joined to wait for unmounting.
 func mountWithStorageHandle(
@@ -42,7 +88,19 @@ func mountWithStorageHandle(
 	mountPoint string,
 	newConfig *cfg.Config,
 	storageHandle storage.StorageHandle,
-	metricHandle common.MetricHandle) (mfs *fuse.MountedFileSystem, err error) {
+	metricHandle common.MetricHandle,
+	prefetcher *fs.CachePrefetcher,
+	differ *fs.GenerationDiffer,
+	uploads *fs.UploadMonitor,
+	opStats *wrappers.OpStats) (mfs *fuse.MountedFileSystem, err error) {
+	if err = checkLocalDirs(newConfig); err != nil {
+		return
+	}
+
+	if err = checkMountPoint(mountPoint, newConfig); err != nil {
+		return
+	}
+
 	// Sanity check: make sure the temporary directory exists and is writable
 	// currently. This gives a better user experience than harder to debug EIO
 	// errors when reading files in the future.
@@ -86,17 +144,83 @@ be interacting with the file system.`)
 		gid = uint32(newConfig.FileSystem.Gid)
 	}
 
+	objectCreationRules, err := cfg.ParseObjectCreationRules(newConfig.Write.ObjectCreationRules)
+	if err != nil {
+		err = fmt.Errorf("ParseObjectCreationRules: %w", err)
+		return
+	}
+
+	diskBudget, err := newDiskBudget(newConfig)
+	if err != nil {
+		err = fmt.Errorf("newDiskBudget: %w", err)
+		return
+	}
+
+	var readWorkers *readworker.Pool
+	if newConfig.Read.ExperimentalWorkerProcesses > 0 && storageHandle != nil {
+		logger.Infof("Starting %d read worker processes...", newConfig.Read.ExperimentalWorkerProcesses)
+		if readWorkers, err = startReadWorkers(int(newConfig.Read.ExperimentalWorkerProcesses)); err != nil {
+			logger.Warnf("Reading the objects from the file system process: %v", err)
+			readWorkers, err = nil, nil
+		}
+	}
+
+	memoryGovernor := memorygovernor.New(newConfig.MemoryPressureThresholdPercent)
+	idleReleaser := quiesce.New(newConfig.FileSystem.IdleTeardownAfter)
+	if storageHandle != nil {
+		idleReleaser.RegisterFunc(storageHandle.CloseIdleConnections)
+	}
 	bucketCfg := gcsx.BucketConfig{
 		BillingProject:                     newConfig.GcsConnection.BillingProject,
 		OnlyDir:                            newConfig.OnlyDir,
 		EgressBandwidthLimitBytesPerSecond: newConfig.GcsConnection.LimitBytesPerSec,
 		OpRateLimitHz:                      newConfig.GcsConnection.LimitOpsPerSec,
-		StatCacheMaxSizeMB:                 uint64(newConfig.MetadataCache.StatCacheMaxSizeMb),
-		StatCacheTTL:                       time.Duration(newConfig.MetadataCache.TtlSecs) * time.Second,
-		EnableMonitoring:                   cfg.IsMetricsEnabled(&newConfig.Metrics),
-		AppendThreshold:                    1 << 21, // 2 MiB, a total guess.
-		ChunkTransferTimeoutSecs:           newConfig.GcsRetries.ChunkTransferTimeoutSecs,
-		TmpObjectPrefix:                    ".gcsfuse_tmp/",
+		MetadataOpRateLimitHz:              newConfig.GcsConnection.LimitMetadataOpsPerSec,
+		MetadataOpBurst:                    newConfig.GcsConnection.LimitMetadataOpsBurst,
+		MaxReadStreamsPerObject:            newConfig.GcsConnection.MaxReadStreamsPerObject,
+		MaxConcurrentRequests: ratelimit.ConcurrencyLimits{
+			List:  newConfig.GcsConnection.MaxConcurrentListRequests,
+			Stat:  newConfig.GcsConnection.MaxConcurrentStatRequests,
+			Read:  newConfig.GcsConnection.MaxConcurrentReadRequests,
+			Write: newConfig.GcsConnection.MaxConcurrentWriteRequests,
+
+			ReadMinSharePercent: newConfig.GcsConnection.ReadMinSharePercent,
+		},
+		StatCacheMaxSizeMB:             uint64(newConfig.MetadataCache.StatCacheMaxSizeMb),
+		ReadWorkers:                    readWorkers,
+		MemoryGovernor:                 memoryGovernor,
+		IdleReleaser:                   idleReleaser,
+		StatCacheTTL:                   time.Duration(newConfig.MetadataCache.TtlSecs) * time.Second,
+		StatCacheTTLJitter:             time.Duration(newConfig.MetadataCache.TtlSecs) * time.Second / 100 * time.Duration(newConfig.MetadataCache.TtlJitterPercent),
+		StatCacheBatchRefreshThreshold: int(newConfig.MetadataCache.BatchRefreshThreshold),
+		ListCacheTTL:                   cfg.ListCacheTTLSecsToDuration(newConfig.MetadataCache.ListCacheTtlSecs),
+		ListCacheMaxSizeMB:             uint64(newConfig.MetadataCache.ListCacheMaxSizeMb),
+		EmulateReadDirPlus:             newConfig.MetadataCache.ExperimentalEmulateReaddirplus,
+		PermissionDeniedTTL:            time.Duration(newConfig.MetadataCache.PermissionDeniedTtlSecs) * time.Second,
+		EnableMonitoring:               cfg.IsMetricsEnabled(&newConfig.Metrics),
+		BucketNames:                    common.NewBucketNames(newConfig.Metrics.BucketNames, int(newConfig.Metrics.MaxBucketNames)),
+		EnableCostEstimation:           newConfig.Metrics.EnableCostEstimation,
+		AppendThreshold:                1 << 21, // 2 MiB, a total guess.
+		ChunkTransferTimeoutSecs:       newConfig.GcsRetries.ChunkTransferTimeoutSecs,
+		TmpObjectPrefix:                ".gcsfuse_tmp/",
+		ObjectCreationRules:            objectCreationRules,
+		AtomicCommitPrefixes:           newConfig.Write.AtomicCommitPrefixes,
+		AtomicCommitSentinel:           newConfig.Write.AtomicCommitSentinel,
+		AtomicCommitStagingPrefix:      ".gcsfuse_staging/",
+	}
+	if newConfig.FileSystem.OfflineMode == cfg.OfflineModeServeCache {
+		bucketCfg.OfflineProbeInterval = offlineProbeInterval
+	}
+	switch newConfig.MetadataCache.ExperimentalMetadataPrefetchOnMount {
+	case cfg.ExperimentalMetadataPrefetchOnMountManifest:
+		bucketCfg.MetadataPrefetchManifest = string(newConfig.MetadataCache.ExperimentalMetadataPrefetchManifest)
+	case cfg.ExperimentalMetadataPrefetchOnMountSynchronous, cfg.ExperimentalMetadataPrefetchOnMountAsynchronous:
+		bucketCfg.MetadataPrefetch = &gcsx.MetadataPrefetchConfig{
+			Async:       newConfig.MetadataCache.ExperimentalMetadataPrefetchOnMount == cfg.ExperimentalMetadataPrefetchOnMountAsynchronous,
+			Workers:     int(newConfig.MetadataCache.ExperimentalMetadataPrefetchWorkers),
+			MaxDuration: time.Duration(newConfig.MetadataCache.ExperimentalMetadataPrefetchMaxDurationSecs) * time.Second,
+			MaxEntries:  newConfig.MetadataCache.ExperimentalMetadataPrefetchMaxEntries,
+		}
 	}
 	bm := gcsx.NewBucketManager(bucketCfg, storageHandle)
 
@@ -119,6 +243,46 @@ be interacting with the file system.`)
 		EnableNonexistentTypeCache: newConfig.MetadataCache.EnableNonexistentTypeCache,
 		NewConfig:                  newConfig,
 		MetricHandle:               metricHandle,
+		OpStats:                    opStats,
+		CachePrefetcher:            prefetcher,
+		GenerationDiffer:           differ,
+		UploadMonitor:              uploads,
+		DiskBudget:                 diskBudget,
+		IdleReleaser:               idleReleaser,
+	}
+	if newConfig.Logging.RecentErrorsCount > 0 {
+		serverCfg.VirtualFiles = append(serverCfg.VirtualFiles, wrappers.VirtualFile{
+			Name: "errors",
+			Content: func() []byte {
+				var buf bytes.Buffer
+				_ = logger.WriteRecentErrors(&buf)
+				return buf.Bytes()
+			},
+		})
+	}
+
+	if newConfig.Debug.ControlSocket != "" {
+		// Lets the gcsfuse prefetch command find the control socket of the mount.
+		serverCfg.VirtualFiles = append(serverCfg.VirtualFiles, wrappers.VirtualFile{
+			Name: controlSocketVirtualFile,
+			Content: func() []byte {
+				return []byte(string(newConfig.Debug.ControlSocket) + "\n")
+			},
+		})
+	}
+
+	if project := newConfig.FileSystem.StatfsMonitoringProject; project != "" {
+		if isDynamicMount(bucketName) {
+			logger.Warnf("Not reporting the used space of the bucket: file-system.statfs-monitoring-project needs a single bucket mounted")
+		} else if usage, err := monitor.NewBucketUsage(ctx, project, bucketName, string(newConfig.GcsAuth.KeyFile)); err != nil {
+			logger.Warnf("Not reporting the used space of the bucket: %v", err)
+		} else {
+			serverCfg.BucketUsage = usage
+		}
+	}
+
+	if newConfig.FileSystem.LifecycleHintWindow > 0 {
+		serverCfg.LifecycleRules = readLifecycleRules(ctx, storageHandle, bucketName, newConfig)
 	}
 
 	logger.Infof("Creating a new server...\n")
@@ -144,9 +308,54 @@ be interacting with the file system.`)
 		return
 	}
 
+	if newConfig.FileSystem.MaxBackground > 0 {
+		if err := wrappers.SetMaxBackground(mountPoint, newConfig.FileSystem.MaxBackground); err != nil {
+			logger.Warnf("Keeping the default max-background: %v", err)
+		}
+	}
+
+	if newConfig.FileSystem.MaxReadAheadKb > 0 {
+		if err := wrappers.SetMaxReadAhead(mountPoint, newConfig.FileSystem.MaxReadAheadKb); err != nil {
+			logger.Warnf("Keeping the default read-ahead of the kernel: %v", err)
+		}
+	}
+
+	go memoryGovernor.Run(ctx)
+
+	if idleReleaser != nil && serverCfg.OpStats != nil {
+		go releaseWhileIdle(ctx, newConfig.FileSystem.IdleTeardownAfter, serverCfg.OpStats.Activity, idleReleaser)
+	}
+
+	if cfg.IsMetricsEnabled(&newConfig.Metrics) {
+		if err := wrappers.MonitorKernelQueue(ctx, mountPoint, kernelQueueSampleInterval, serverCfg.OpStats, metricHandle); err != nil {
+			logger.Infof("Kernel queue metrics are unavailable: %v", err)
+		}
+		go bufpool.Report(ctx, bufferPoolReportInterval, metricHandle)
+	}
+
 	return
 }
 
+// newDiskBudget returns the disk budget of the mount, in which the most disk
+// space the log files can take is reserved, or nil if the disk usage isn't
+// capped.
+func newDiskBudget(newConfig *cfg.Config) (*diskbudget.Budget, error) {
+	if newConfig.DiskBudgetMb <= 0 {
+		return nil, nil
+	}
+
+	const MiB = 1 << 20
+	budget := diskbudget.New(uint64(newConfig.DiskBudgetMb) * MiB)
+	logFilesSizeMb := cfg.MaxLogFilesSizeMb(&newConfig.Logging)
+	if logFilesSizeMb < 0 {
+		return nil, fmt.Errorf("the log files aren't capped")
+	}
+	if err := budget.Reserve(uint64(logFilesSizeMb) * MiB); err != nil {
+		return nil, fmt.Errorf("reserving the log files: %w", err)
+	}
+	return budget, nil
+}
+
 func getFuseMountConfig(fsName string, newConfig *cfg.Config) *fuse.MountConfig {
 	// Handle the repeated "-o" flag.
 	parsedOptions := make(map[string]string)
@@ -168,6 +377,9 @@ func getFuseMountConfig(fsName string, newConfig *cfg.Config) *fuse.MountConfig
 		// access two files under same directory parallely, then the lookups also
 		// happen parallely.
 		EnableParallelDirOps: !(newConfig.FileSystem.DisableParallelDirops),
+		// Unless its writeback cache is disabled, the kernel gathers the dirty
+		// pages of the files into writes of up to 1 MiB.
+		DisableWritebackCaching: newConfig.FileSystem.DisableWritebackCache,
 	}
 
 	mountCfg.ErrorLogger = logger.NewLegacyLogger(logger.LevelError, "fuse: ")
@@ -19,6 +19,7 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetFuseMountConfig_MountOptionsFormattedCorrectly(t *testing.T) {
@@ -66,3 +67,25 @@ func TestGetFuseMountConfig_MountOptionsFormattedCorrectly(t *testing.T) {
 		assert.True(t, fuseMountCfg.EnableParallelDirOps) // Default true unless explicitly disabled
 	}
 }
+
+func TestNewDiskBudget(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		budget, err := newDiskBudget(&cfg.Config{})
+
+		require.NoError(t, err)
+		assert.Nil(t, budget)
+	})
+
+	t.Run("ReservesLogFiles", func(t *testing.T) {
+		budget, err := newDiskBudget(&cfg.Config{
+			DiskBudgetMb: 100,
+			Logging: cfg.LoggingConfig{
+				FilePath:  "/tmp/gcsfuse.log",
+				LogRotate: cfg.LogRotateLoggingConfig{BackupFileCount: 2, MaxFileSizeMb: 10},
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(30<<20), budget.Used())
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// isWithin returns true if path is dir or one of its descendants.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// nestedMountError returns why mounting at the supplied mount point makes
+// gcsfuse read or write through a FUSE file system, possibly itself, if it
+// does.
+func nestedMountError(mountPoint string, c *cfg.Config) error {
+	var st syscall.Statfs_t
+	if err := statfs(mountPoint, &st); err == nil && uint32(st.Type) == fuseSuperMagic {
+		return fmt.Errorf("the mount point %q is inside another FUSE mount, e.g. of gcsfuse", mountPoint)
+	}
+
+	dirs := []struct {
+		flag string
+		path cfg.ResolvedPath
+	}{
+		{"cache-dir", c.CacheDir},
+		{"temp-dir", c.FileSystem.TempDir},
+	}
+	for _, d := range dirs {
+		if d.path == "" {
+			continue
+		}
+		if isWithin(mountPoint, string(d.path)) {
+			return fmt.Errorf("the mount point %q is inside %s %q", mountPoint, d.flag, d.path)
+		}
+		if isWithin(string(d.path), mountPoint) {
+			return fmt.Errorf("%s %q is inside the mount point %q, so gcsfuse would store its files in the bucket", d.flag, d.path, mountPoint)
+		}
+	}
+	return nil
+}
+
+// checkMountPoint fails, or warns with nested-mount-action warn, if the mount
+// point is inside another FUSE mount, or inside cache-dir or temp-dir, or
+// contains one of them: such recursive configurations loop back into gcsfuse
+// in ways that are hard to diagnose.
+func checkMountPoint(mountPoint string, c *cfg.Config) error {
+	err := nestedMountError(mountPoint, c)
+	if err == nil {
+		return nil
+	}
+	if c.FileSystem.NestedMountAction == cfg.NestedMountActionWarn {
+		logger.Warnf("%v; gcsfuse may misbehave, e.g. hang or loop", err)
+		return nil
+	}
+	return fmt.Errorf("%w; use --nested-mount-action=warn to mount anyway", err)
+}
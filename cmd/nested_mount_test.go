@@ -0,0 +1,91 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMountPoint(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name       string
+		fsType     int64
+		mountPoint string
+		cacheDir   string
+		action     string
+		wantErr    string
+	}{
+		{
+			name:       "local_fs",
+			fsType:     0xef53, // ext4
+			mountPoint: dir,
+			cacheDir:   filepath.Join(t.TempDir(), "cache"),
+		},
+		{
+			name:       "inside_fuse_mount",
+			fsType:     fuseSuperMagic,
+			mountPoint: dir,
+			wantErr:    "inside another FUSE mount",
+		},
+		{
+			name:       "inside_fuse_mount_warns",
+			fsType:     fuseSuperMagic,
+			mountPoint: dir,
+			action:     cfg.NestedMountActionWarn,
+		},
+		{
+			name:       "inside_cache_dir",
+			fsType:     0xef53,
+			mountPoint: filepath.Join(dir, "mnt"),
+			cacheDir:   dir,
+			wantErr:    "is inside cache-dir",
+		},
+		{
+			name:       "cache_dir_inside",
+			fsType:     0xef53,
+			mountPoint: dir,
+			cacheDir:   filepath.Join(dir, "cache"),
+			wantErr:    "is inside the mount point",
+		},
+		{
+			name:       "cache_dir_sibling",
+			fsType:     0xef53,
+			mountPoint: filepath.Join(dir, "mnt"),
+			cacheDir:   filepath.Join(dir, "mnt-cache"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeStatfs(t, tc.fsType)
+			c := &cfg.Config{
+				CacheDir:   cfg.ResolvedPath(tc.cacheDir),
+				FileSystem: cfg.FileSystemConfig{NestedMountAction: tc.action},
+			}
+
+			err := checkMountPoint(tc.mountPoint, c)
+
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/control"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
+	"github.com/spf13/cobra"
+)
+
+// controlSocketVirtualFile is the name of the virtual file holding the path
+// of the control socket of a mount, if any.
+const controlSocketVirtualFile = "control-socket"
+
+const defaultPrefetchParallelism = 8
+
+// newPrefetchCmd returns the command prefetching files of a running mount
+// into its file cache, through its control socket.
+func newPrefetchCmd() *cobra.Command {
+	var parallelism int
+	prefetchCmd := &cobra.Command{
+		Use:   "gcsfuse prefetch [flags] mount_point path_or_glob",
+		Short: "Prefetch files of a mount into its file cache",
+		Long: `Prefetches the files matching a path or a glob, relative to the mount point
+or absolute, into the file cache of a mount, reporting the progress. A
+directory matches the files under it. The mount must serve a control socket
+(--control-socket) and have a file cache.`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrefetch(cmd.Context(), args[0], args[1], parallelism, cmd.OutOrStdout())
+		},
+	}
+	prefetchCmd.Flags().IntVar(&parallelism, "parallelism", defaultPrefetchParallelism, "The number of files prefetched at a time.")
+	return prefetchCmd
+}
+
+// isPrefetchCmd returns true if args, including the program name, invoke the
+// prefetch command rather than mount a bucket named prefetch, i.e. if they
+// are valid prefetch arguments.
+func isPrefetchCmd(args []string) bool {
+	if len(args) < 2 || args[1] != "prefetch" {
+		return false
+	}
+	c := newPrefetchCmd()
+	if err := c.ParseFlags(args[2:]); err != nil {
+		return false
+	}
+	return len(c.Flags().Args()) == 2
+}
+
+func runPrefetch(ctx context.Context, mountPoint, pattern string, parallelism int, w io.Writer) error {
+	if parallelism < 1 {
+		return fmt.Errorf("--parallelism must be positive, got %d", parallelism)
+	}
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+	socket, err := controlSocketPath(mountPoint)
+	if err != nil {
+		return err
+	}
+	pattern, err = mountRelativePath(mountPoint, pattern)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return control.Prefetch(ctx, socket, pattern, parallelism, w)
+}
+
+// controlSocketPath returns the path of the control socket of the mount at
+// the absolute mountPoint.
+func controlSocketPath(mountPoint string) (string, error) {
+	socket, err := os.ReadFile(filepath.Join(mountPoint, wrappers.VirtualDirName, controlSocketVirtualFile))
+	if err != nil {
+		return "", fmt.Errorf("finding the control socket of the mount at %s, mounted with --control-socket: %w", mountPoint, err)
+	}
+	return strings.TrimSpace(string(socket)), nil
+}
+
+// mountRelativePath returns p, relative to the absolute mountPoint or
+// absolute, relative to the root of the mount, with slashes, and empty for
+// the root itself.
+func mountRelativePath(mountPoint, p string) (string, error) {
+	if filepath.IsAbs(p) {
+		rel, err := filepath.Rel(mountPoint, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return "", fmt.Errorf("%s isn't under the mount point %s", p, mountPoint)
+		}
+		p = rel
+	}
+	if p == "." {
+		p = ""
+	}
+	return filepath.ToSlash(p), nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/control"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPrefetchCmd(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{args: []string{"gcsfuse", "prefetch", "/mnt", "data/*"}, expected: true},
+		{args: []string{"gcsfuse", "prefetch", "--parallelism", "4", "/mnt", "data"}, expected: true},
+		{args: []string{"gcsfuse", "prefetch", "/mnt", "data", "--parallelism=4"}, expected: true},
+		// Mounts of a bucket named prefetch.
+		{args: []string{"gcsfuse", "prefetch", "/mnt"}, expected: false},
+		{args: []string{"gcsfuse", "prefetch", "/mnt", "--temp-dir", "/tmp"}, expected: false},
+		{args: []string{"gcsfuse", "--implicit-dirs", "prefetch", "/mnt"}, expected: false},
+		{args: []string{"gcsfuse", "bucket", "/mnt"}, expected: false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isPrefetchCmd(tc.args), "args: %v", tc.args)
+	}
+}
+
+type recordingPrefetcher struct {
+	pattern string
+}
+
+func (p *recordingPrefetcher) Prefetch(_ context.Context, pattern string, _ int, progress func(name string, size uint64, err error)) error {
+	p.pattern = pattern
+	progress("data/a", 1, nil)
+	return nil
+}
+
+// fakeMount returns a directory with the virtual file holding the path of a
+// control socket served with p and d, like a mount.
+func fakeMount(t *testing.T, p control.Prefetcher, d control.Differ) string {
+	t.Helper()
+	mountPoint := t.TempDir()
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := control.Listen(socketPath, control.NewHandler(p, d, nil))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	require.NoError(t, os.Mkdir(filepath.Join(mountPoint, wrappers.VirtualDirName), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(mountPoint, wrappers.VirtualDirName, controlSocketVirtualFile), []byte(socketPath+"\n"), 0600))
+	return mountPoint
+}
+
+func TestRunPrefetch(t *testing.T) {
+	p := &recordingPrefetcher{}
+	mountPoint := fakeMount(t, p, nil)
+	tests := []struct {
+		name            string
+		pattern         string
+		expectedPattern string
+	}{
+		{name: "Relative", pattern: "data/*", expectedPattern: "data/*"},
+		{name: "Absolute", pattern: filepath.Join(mountPoint, "data"), expectedPattern: "data"},
+		{name: "MountPoint", pattern: mountPoint, expectedPattern: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := runPrefetch(context.Background(), mountPoint, tc.pattern, 2, &out)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPattern, p.pattern)
+			assert.Contains(t, out.String(), "prefetched data/a (1 bytes)\n")
+		})
+	}
+}
+
+func TestRunPrefetch_OutsideMountPoint(t *testing.T) {
+	mountPoint := fakeMount(t, &recordingPrefetcher{}, nil)
+
+	err := runPrefetch(context.Background(), mountPoint, filepath.Dir(mountPoint), 2, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "isn't under the mount point")
+}
+
+func TestRunPrefetch_NoControlSocket(t *testing.T) {
+	err := runPrefetch(context.Background(), t.TempDir(), "data", 2, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "finding the control socket")
+}
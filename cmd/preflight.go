@@ -0,0 +1,345 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	cacheutil "github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/canned"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/fuse/fsutil"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// preflightTimeout bounds the requests to GCS made by the preflight checks.
+const preflightTimeout = 30 * time.Second
+
+// The outcomes of a preflight check.
+const (
+	preflightOK      = "ok"
+	preflightWarn    = "warn"
+	preflightFail    = "fail"
+	preflightSkipped = "skipped"
+)
+
+// preflightCheck is the outcome of one of the checks run before mounting.
+type preflightCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Remedy  string `json:"remedy,omitempty"`
+}
+
+// diagnosis is the outcome of the preflight checks printed by --diagnose.
+type diagnosis struct {
+	Bucket     string           `json:"bucket"`
+	MountPoint string           `json:"mount_point"`
+	OK         bool             `json:"ok"`
+	Checks     []preflightCheck `json:"checks"`
+}
+
+// bucketProber is the part of the storage handle used by the preflight
+// checks.
+type bucketProber interface {
+	BucketPermissions(ctx context.Context, bucketName string, billingProject string, permissions []string) ([]string, error)
+	BucketHierarchicalNamespace(ctx context.Context, bucketName string, billingProject string) (bool, error)
+}
+
+func isAuthError(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == http.StatusUnauthorized
+	}
+	if status.Code(err) == codes.Unauthenticated {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "oauth2: ") || strings.Contains(msg, "could not find default credentials")
+}
+
+func isNotFoundError(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == http.StatusNotFound
+	}
+	return errors.Is(err, storage.ErrBucketNotExist) || status.Code(err) == codes.NotFound
+}
+
+func isForbiddenError(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == http.StatusForbidden
+	}
+	return status.Code(err) == codes.PermissionDenied
+}
+
+// requiredPermissions returns the IAM permissions on the bucket needed by the
+// mount.
+func requiredPermissions(c *cfg.Config) []string {
+	perms := []string{"storage.objects.list", "storage.objects.get"}
+	if !cfg.IsReadOnlyMount(c) {
+		perms = append(perms, "storage.objects.create", "storage.objects.delete")
+	}
+	return perms
+}
+
+// checkBucket checks the credentials, the existence of the bucket, the
+// permissions on it and its namespace with the prober returned by newProber.
+func checkBucket(ctx context.Context, c *cfg.Config, bucketName string, newProber func() (bucketProber, error)) []preflightCheck {
+	credentials := preflightCheck{Name: "credentials"}
+	bucket := preflightCheck{Name: "bucket"}
+	iam := preflightCheck{Name: "iam"}
+	hns := preflightCheck{Name: "hns"}
+	checks := func() []preflightCheck {
+		return []preflightCheck{credentials, bucket, iam, hns}
+	}
+	skip := func(reason string, rest ...*preflightCheck) {
+		for _, check := range rest {
+			check.Status = preflightSkipped
+			check.Message = reason
+		}
+	}
+	const credentialsRemedy = "Set up the application default credentials, e.g. with 'gcloud auth application-default login', or pass a service account key with --key-file."
+
+	switch {
+	case isDynamicMount(bucketName):
+		skip("the buckets are mounted dynamically", &credentials, &bucket, &iam, &hns)
+		return checks()
+	case bucketName == canned.FakeBucketName:
+		skip("the bucket is fake", &credentials, &bucket, &iam, &hns)
+		return checks()
+	case c.GcsConnection.CustomEndpoint != "":
+		skip("the bucket is served by a custom endpoint", &credentials, &bucket, &iam, &hns)
+		return checks()
+	}
+
+	prober, err := newProber()
+	if err != nil {
+		credentials.Status = preflightFail
+		credentials.Message = fmt.Sprintf("creating the storage client: %v", err)
+		credentials.Remedy = credentialsRemedy
+		skip("the credentials are unusable", &bucket, &iam, &hns)
+		return checks()
+	}
+
+	billingProject := c.GcsConnection.BillingProject
+	perms := requiredPermissions(c)
+	granted, err := prober.BucketPermissions(ctx, bucketName, billingProject, perms)
+	switch {
+	case err == nil:
+	case isAuthError(err):
+		credentials.Status = preflightFail
+		credentials.Message = fmt.Sprintf("authenticating to GCS: %v", err)
+		credentials.Remedy = credentialsRemedy
+		skip("the credentials are unusable", &bucket, &iam, &hns)
+		return checks()
+	case isNotFoundError(err):
+		credentials.Status = preflightOK
+		credentials.Message = "authenticated to GCS"
+		bucket.Status = preflightFail
+		bucket.Message = fmt.Sprintf("the bucket %q doesn't exist", bucketName)
+		bucket.Remedy = "Check the name of the bucket, which is passed without the gs:// prefix."
+		skip("the bucket doesn't exist", &iam, &hns)
+		return checks()
+	default:
+		// GCS may be unreachable for now, which shouldn't prevent mounting, e.g.
+		// with offline-mode serve-cache.
+		credentials.Status = preflightWarn
+		credentials.Message = fmt.Sprintf("couldn't verify the credentials: %v", err)
+		skip("GCS couldn't be queried", &bucket, &iam, &hns)
+		return checks()
+	}
+
+	credentials.Status = preflightOK
+	credentials.Message = "authenticated to GCS"
+	bucket.Status = preflightOK
+	bucket.Message = fmt.Sprintf("the bucket %q exists", bucketName)
+
+	var missing []string
+	for _, p := range perms {
+		if !slices.Contains(granted, p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		iam.Status = preflightOK
+		iam.Message = fmt.Sprintf("granted %s", strings.Join(perms, ", "))
+	} else {
+		// The permissions may still be granted on managed folders, so they only
+		// warn.
+		iam.Status = preflightWarn
+		iam.Message = fmt.Sprintf("missing %s on the bucket", strings.Join(missing, ", "))
+		if cfg.IsReadOnlyMount(c) || slices.Contains(missing, "storage.objects.list") || slices.Contains(missing, "storage.objects.get") {
+			iam.Remedy = "Grant roles/storage.objectViewer on the bucket to the account gcsfuse runs as."
+		} else {
+			iam.Remedy = "Grant roles/storage.objectUser on the bucket to the account gcsfuse runs as, or mount it read-only with -o ro."
+		}
+	}
+
+	enabled, err := prober.BucketHierarchicalNamespace(ctx, bucketName, billingProject)
+	switch {
+	case err != nil && isForbiddenError(err):
+		skip("reading the namespace of the bucket requires storage.buckets.get", &hns)
+	case err != nil:
+		skip(fmt.Sprintf("couldn't read the namespace of the bucket: %v", err), &hns)
+	case enabled && !c.EnableHns:
+		hns.Status = preflightWarn
+		hns.Message = "the bucket has a hierarchical namespace, but its support is disabled"
+		hns.Remedy = "Drop --enable-hns=false so that the folders of the bucket are renamed atomically."
+	case enabled:
+		hns.Status = preflightOK
+		hns.Message = "hierarchical namespace"
+	default:
+		hns.Status = preflightOK
+		hns.Message = "flat namespace"
+	}
+	return checks()
+}
+
+// checkLocalDirsWritable checks that gcsfuse can write to cache-dir, if the
+// file cache is enabled, and temp-dir.
+func checkLocalDirsWritable(c *cfg.Config) []preflightCheck {
+	cacheDir := preflightCheck{Name: "cache-dir"}
+	if !cfg.IsFileCacheEnabled(c) {
+		cacheDir.Status = preflightSkipped
+		cacheDir.Message = "the file cache is disabled"
+	} else if err := cacheutil.CreateCacheDirectoryIfNotPresentAt(path.Join(string(c.CacheDir), cacheutil.FileCache), cacheutil.DefaultDirPerm); err != nil {
+		cacheDir.Status = preflightFail
+		cacheDir.Message = err.Error()
+		cacheDir.Remedy = "Pass a --cache-dir gcsfuse can write to, or disable the file cache."
+	} else {
+		cacheDir.Status = preflightOK
+		cacheDir.Message = fmt.Sprintf("%q is writable", c.CacheDir)
+	}
+
+	// An empty temp-dir stands for the default temporary directory.
+	tempDir := preflightCheck{Name: "temp-dir"}
+	f, err := fsutil.AnonymousFile(string(c.FileSystem.TempDir))
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		tempDir.Status = preflightFail
+		tempDir.Message = fmt.Sprintf("creating a file in the temp-dir: %v", err)
+		tempDir.Remedy = "Pass a --temp-dir gcsfuse can write to."
+	} else {
+		tempDir.Status = preflightOK
+		tempDir.Message = "the temp-dir is writable"
+	}
+	return []preflightCheck{cacheDir, tempDir}
+}
+
+// runPreflightChecks runs the checks of the mount of the supplied bucket at
+// the supplied mount point.
+func runPreflightChecks(c *cfg.Config, bucketName, mountPoint string, newProber func() (bucketProber, error)) *diagnosis {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+
+	d := &diagnosis{Bucket: bucketName, MountPoint: mountPoint, OK: true}
+	d.Checks = append(checkBucket(ctx, c, bucketName, newProber), checkLocalDirsWritable(c)...)
+	for _, check := range d.Checks {
+		if check.Status == preflightFail {
+			d.OK = false
+		}
+	}
+	return d
+}
+
+// newPreflightProber returns a prober creating its own storage handle, with
+// the configured credentials.
+func newPreflightProber(c *cfg.Config) func() (bucketProber, error) {
+	return func() (bucketProber, error) {
+		storageHandle, err := createStorageHandle(c, getUserAgent(c.AppName, getConfigForUserAgent(c)), common.NewNoopMetrics())
+		if err != nil {
+			return nil, err
+		}
+		return storageHandle, nil
+	}
+}
+
+// err returns the failures of the diagnosis, with their remedies, as one
+// error, nil if all checks passed.
+func (d *diagnosis) err() error {
+	var failures []string
+	for _, check := range d.Checks {
+		if check.Status != preflightFail {
+			continue
+		}
+		failure := fmt.Sprintf("%s: %s", check.Name, check.Message)
+		if check.Remedy != "" {
+			failure += "; " + check.Remedy
+		}
+		failures = append(failures, failure)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("preflight checks failed: %s", strings.Join(failures, "; "))
+}
+
+// logWarnings logs the warnings of the diagnosis.
+func (d *diagnosis) logWarnings() {
+	for _, check := range d.Checks {
+		if check.Status != preflightWarn {
+			continue
+		}
+		if check.Remedy != "" {
+			logger.Warnf("Preflight check %s: %s. %s", check.Name, check.Message, check.Remedy)
+		} else {
+			logger.Warnf("Preflight check %s: %s.", check.Name, check.Message)
+		}
+	}
+}
+
+// writeJSON writes the diagnosis to w, as printed by --diagnose.
+func (d *diagnosis) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// diagnose runs the preflight checks, and writes their outcome to w as JSON
+// instead of mounting. It returns an error if a check failed.
+func diagnose(c *cfg.Config, bucketName, mountPoint string, w io.Writer) error {
+	// Keep the logs out of the JSON, unless they're written to a file.
+	logConfig := c.Logging
+	if logConfig.FilePath == "" {
+		logConfig.Severity = cfg.LogSeverity(cfg.OFF)
+	}
+	if err := logger.InitLogFile(logConfig); err != nil {
+		return fmt.Errorf("init log file: %w", err)
+	}
+
+	d := runPreflightChecks(c, bucketName, mountPoint, newPreflightProber(c))
+	if err := d.writeJSON(w); err != nil {
+		return fmt.Errorf("writing the diagnosis: %w", err)
+	}
+	return d.err()
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+)
+
+type fakeProber struct {
+	granted  []string
+	permsErr error
+	hns      bool
+	hnsErr   error
+}
+
+func (p *fakeProber) BucketPermissions(_ context.Context, _ string, _ string, _ []string) ([]string, error) {
+	return p.granted, p.permsErr
+}
+
+func (p *fakeProber) BucketHierarchicalNamespace(_ context.Context, _ string, _ string) (bool, error) {
+	return p.hns, p.hnsErr
+}
+
+func proberOf(p *fakeProber) func() (bucketProber, error) {
+	return func() (bucketProber, error) { return p, nil }
+}
+
+var allPermissions = []string{"storage.objects.list", "storage.objects.get", "storage.objects.create", "storage.objects.delete"}
+
+func preflightConfig(t *testing.T) *cfg.Config {
+	t.Helper()
+	return &cfg.Config{
+		EnableHns:  true,
+		FileSystem: cfg.FileSystemConfig{TempDir: cfg.ResolvedPath(t.TempDir())},
+	}
+}
+
+// statuses returns the status of each check by name.
+func statuses(d *diagnosis) map[string]string {
+	m := make(map[string]string)
+	for _, check := range d.Checks {
+		m[check.Name] = check.Status
+	}
+	return m
+}
+
+func TestRunPreflightChecks_OK(t *testing.T) {
+	c := preflightConfig(t)
+
+	d := runPreflightChecks(c, "some-bucket", "/mnt", proberOf(&fakeProber{granted: allPermissions, hns: true}))
+
+	assert.True(t, d.OK)
+	assert.NoError(t, d.err())
+	assert.Equal(t, map[string]string{
+		"credentials": preflightOK,
+		"bucket":      preflightOK,
+		"iam":         preflightOK,
+		"hns":         preflightOK,
+		"cache-dir":   preflightSkipped,
+		"temp-dir":    preflightOK,
+	}, statuses(d))
+}
+
+func TestRunPreflightChecks_Failures(t *testing.T) {
+	testCases := []struct {
+		name       string
+		newProber  func() (bucketProber, error)
+		wantFailed string
+		wantErr    string
+	}{
+		{
+			name:       "unusable_credentials",
+			newProber:  func() (bucketProber, error) { return nil, errors.New("could not find default credentials") },
+			wantFailed: "credentials",
+			wantErr:    "gcloud auth application-default login",
+		},
+		{
+			name:       "token_rejected",
+			newProber:  proberOf(&fakeProber{permsErr: &googleapi.Error{Code: 401}}),
+			wantFailed: "credentials",
+			wantErr:    "authenticating to GCS",
+		},
+		{
+			name:       "missing_bucket",
+			newProber:  proberOf(&fakeProber{permsErr: &googleapi.Error{Code: 404}}),
+			wantFailed: "bucket",
+			wantErr:    `the bucket "some-bucket" doesn't exist`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := runPreflightChecks(preflightConfig(t), "some-bucket", "/mnt", tc.newProber)
+
+			assert.False(t, d.OK)
+			assert.Equal(t, preflightFail, statuses(d)[tc.wantFailed])
+			assert.Equal(t, preflightSkipped, statuses(d)["iam"])
+			require.Error(t, d.err())
+			assert.Contains(t, d.err().Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestRunPreflightChecks_Warnings(t *testing.T) {
+	c := preflightConfig(t)
+	c.EnableHns = false
+
+	d := runPreflightChecks(c, "some-bucket", "/mnt", proberOf(&fakeProber{granted: allPermissions[:2], hns: true}))
+
+	assert.True(t, d.OK)
+	assert.Equal(t, preflightWarn, statuses(d)["iam"])
+	assert.Equal(t, preflightWarn, statuses(d)["hns"])
+	assert.Contains(t, d.Checks[2].Message, "storage.objects.create, storage.objects.delete")
+	assert.Contains(t, d.Checks[2].Remedy, "-o ro")
+}
+
+func TestRunPreflightChecks_ReadOnlyNeedsNoWritePermissions(t *testing.T) {
+	c := preflightConfig(t)
+	c.FileSystem.FuseOptions = []string{"ro"}
+
+	d := runPreflightChecks(c, "some-bucket", "/mnt", proberOf(&fakeProber{granted: allPermissions[:2]}))
+
+	assert.Equal(t, preflightOK, statuses(d)["iam"])
+	assert.Equal(t, "flat namespace", d.Checks[3].Message)
+}
+
+func TestRunPreflightChecks_UnreachableOnlyWarns(t *testing.T) {
+	d := runPreflightChecks(preflightConfig(t), "some-bucket", "/mnt", proberOf(&fakeProber{permsErr: &googleapi.Error{Code: 503}}))
+
+	assert.True(t, d.OK)
+	assert.Equal(t, preflightWarn, statuses(d)["credentials"])
+	assert.Equal(t, preflightSkipped, statuses(d)["bucket"])
+}
+
+func TestRunPreflightChecks_SkipsGCSForDynamicMounts(t *testing.T) {
+	newProber := func() (bucketProber, error) {
+		t.Fatal("GCS was probed")
+		return nil, nil
+	}
+
+	d := runPreflightChecks(preflightConfig(t), "", "/mnt", newProber)
+
+	assert.True(t, d.OK)
+	assert.Equal(t, preflightSkipped, statuses(d)["credentials"])
+}
+
+func TestRunPreflightChecks_UnwritableDirs(t *testing.T) {
+	// Not even root can create files under a regular file.
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+	c := preflightConfig(t)
+	c.CacheDir = cfg.ResolvedPath(file)
+	c.FileCache.MaxSizeMb = -1
+	c.FileSystem.TempDir = cfg.ResolvedPath(file)
+
+	d := runPreflightChecks(c, "some-bucket", "/mnt", proberOf(&fakeProber{granted: allPermissions}))
+
+	assert.False(t, d.OK)
+	assert.Equal(t, preflightFail, statuses(d)["cache-dir"])
+	assert.Equal(t, preflightFail, statuses(d)["temp-dir"])
+	assert.Contains(t, d.err().Error(), "--cache-dir")
+}
+
+func TestDiagnosisWriteJSON(t *testing.T) {
+	d := runPreflightChecks(preflightConfig(t), "some-bucket", "/mnt", proberOf(&fakeProber{permsErr: &googleapi.Error{Code: 404}}))
+	var buf bytes.Buffer
+
+	require.NoError(t, d.writeJSON(&buf))
+
+	var got diagnosis
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, *d, got)
+	assert.Contains(t, buf.String(), `"mount_point": "/mnt"`)
+}
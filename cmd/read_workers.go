@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/readworker"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/kardianos/osext"
+)
+
+// readWorkerSocketEnv is set to the socket a read worker serves in the
+// environment of the read workers of experimental-read-worker-processes, which
+// run gcsfuse with the arguments of the file system process.
+const readWorkerSocketEnv = "GCSFUSE_READ_WORKER_SOCKET"
+
+// parentCheckInterval is the interval at which the read workers check whether
+// the file system process has exited, in which case they exit too.
+const parentCheckInterval = time.Second
+
+// startReadWorkers starts n read workers running gcsfuse with the arguments
+// of this process.
+func startReadWorkers(n int) (*readworker.Pool, error) {
+	path, err := osext.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("osext.Executable: %w", err)
+	}
+	return readworker.Start(n, func(socketPath string) *exec.Cmd {
+		return readWorkerCommand(path, os.Args[1:], os.Environ(), socketPath)
+	})
+}
+
+func readWorkerCommand(path string, args, env []string, socketPath string) *exec.Cmd {
+	c := exec.Command(path, args...)
+	c.Env = append(env, fmt.Sprintf("%s=%s", readWorkerSocketEnv, socketPath))
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c
+}
+
+// serveReadWorker serves the reads of the file system process on socketPath
+// until it exits.
+func serveReadWorker(newConfig *cfg.Config, socketPath string) error {
+	// The reads are monitored by the file system process.
+	userAgent := getUserAgent(newConfig.AppName, getConfigForUserAgent(newConfig))
+	storageHandle, err := createStorageHandle(newConfig, userAgent, common.NewNoopMetrics())
+	if err != nil {
+		return fmt.Errorf("failed to create storage handle using createStorageHandle: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnParentExit(ctx, cancel, os.Getppid())
+	defer func() {
+		os.Remove(socketPath)
+		// Fails until the last worker is done with the directory.
+		os.Remove(filepath.Dir(socketPath))
+	}()
+
+	logger.Infof("Serving the reads of the file system process on %q", socketPath)
+	return readworker.Serve(ctx, socketPath, readworker.NewHandler(func(name string) gcs.Bucket {
+		return storageHandle.BucketHandle(ctx, name, newConfig.GcsConnection.BillingProject)
+	}))
+}
+
+// cancelOnParentExit calls cancel once the process isn't the child of parent
+// anymore, i.e. once parent has exited, or ctx is done.
+func cancelOnParentExit(ctx context.Context, cancel func(), parent int) {
+	ticker := time.NewTicker(parentCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if os.Getppid() != parent {
+				logger.Infof("The file system process has exited, stopping the read worker.")
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWorkerCommand(t *testing.T) {
+	c := readWorkerCommand("/usr/bin/gcsfuse", []string{"--foreground", "bucket", "/mnt"}, []string{"PATH=/usr/bin"}, "/tmp/workers/worker-0.sock")
+
+	assert.Equal(t, []string{"/usr/bin/gcsfuse", "--foreground", "bucket", "/mnt"}, c.Args)
+	assert.Equal(t, []string{"PATH=/usr/bin", "GCSFUSE_READ_WORKER_SOCKET=/tmp/workers/worker-0.sock"}, c.Env)
+}
+
+func TestCancelOnParentExit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The parent of the test process isn't the process 1.
+	go cancelOnParentExit(ctx, cancel, 1)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * parentCheckInterval):
+		t.Fatal("not cancelled")
+	}
+}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
@@ -29,16 +31,40 @@ import (
 	"github.com/spf13/viper"
 )
 
-type mountFn func(c *cfg.Config, bucketName, mountPoint string) error
+// machineTuning records how the config was tuned to the machine with
+// enable-autoconfig, so that it can be logged once logging has been set up.
+type machineTuning struct {
+	profile     machineprofile.Profile
+	tunedParams []string
+}
+
+// mountFn is passed the tuning of the config, nil unless enable-autoconfig is
+// set.
+type mountFn func(c *cfg.Config, bucketName, mountPoint string, tuning *machineTuning) error
+
+// probeMachineProfile is overridden in tests so that the parsed config doesn't
+// depend on the machine running them.
+var probeMachineProfile = machineprofile.Probe
+
+// versionTemplate prints the build provenance as JSON with --version --json,
+// and the version as cobra does by default otherwise.
+const versionTemplate = `{{if eq (.Flags.Lookup "json").Value.String "true"}}{{index .Annotations "buildInfo"}}
+{{else}}{{with .Name}}{{printf "%s " .}}{{end}}{{printf "version %s" .Version}}
+{{end}}`
 
 // newRootCmd accepts the mountFn that it executes with the parsed configuration
 func newRootCmd(m mountFn) (*cobra.Command, error) {
 	var (
 		configObj cfg.Config
+		tuning    *machineTuning
 		cfgFile   string
 		cfgErr    error
 		v         = viper.New()
 	)
+	buildInfo, err := json.Marshal(common.GetBuildInfo())
+	if err != nil {
+		return nil, fmt.Errorf("error while encoding the build info: %w", err)
+	}
 	rootCmd := &cobra.Command{
 		Use:   "gcsfuse [flags] bucket mount_point",
 		Short: "Mount a specified GCS bucket or all accessible buckets locally",
@@ -46,6 +72,7 @@ func newRootCmd(m mountFn) (*cobra.Command, error) {
 and access Cloud Storage buckets as local file systems. For a technical overview
 of Cloud Storage FUSE, see https://cloud.google.com/storage/docs/gcs-fuse.`,
 		Version:      common.GetVersion(),
+		Annotations:  map[string]string{"buildInfo": string(buildInfo)},
 		Args:         cobra.RangeArgs(2, 3),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -56,7 +83,7 @@ of Cloud Storage FUSE, see https://cloud.google.com/storage/docs/gcs-fuse.`,
 			if err != nil {
 				return fmt.Errorf("error occurred while extracting the bucket and mountPoint: %w", err)
 			}
-			return m(&configObj, bucket, mountPoint)
+			return m(&configObj, bucket, mountPoint, tuning)
 		},
 	}
 	initConfig := func() {
@@ -83,6 +110,11 @@ of Cloud Storage FUSE, see https://cloud.google.com/storage/docs/gcs-fuse.`,
 		); cfgErr != nil {
 			return
 		}
+		if configObj.EnableAutoconfig {
+			tuning = &machineTuning{profile: probeMachineProfile()}
+			tuning.tunedParams = cfg.ApplyMachineProfile(v, &configObj, tuning.profile)
+		}
+		// The tuned values are validated too.
 		if cfgErr = cfg.ValidateConfig(v, &configObj); cfgErr != nil {
 			return
 		}
@@ -90,9 +122,11 @@ of Cloud Storage FUSE, see https://cloud.google.com/storage/docs/gcs-fuse.`,
 			return
 		}
 	}
+	rootCmd.SetVersionTemplate(versionTemplate)
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, cfg.ConfigFileFlagName, "", "The path to the config file where all gcsfuse related config needs to be specified. "+
 		"Refer to 'https://cloud.google.com/storage/docs/gcsfuse-cli#config-file' for possible configurations.")
+	rootCmd.PersistentFlags().Bool("json", false, "With --version, print the version, commit, build time and builder of gcsfuse as JSON.")
 
 	// Add all the other flags.
 	if err := cfg.BuildFlagSet(rootCmd.PersistentFlags()); err != nil {
@@ -147,6 +181,38 @@ func convertToPosixArgs(args []string, c *cobra.Command) []string {
 }
 
 var ExecuteMountCmd = func() {
+	if isPrefetchCmd(os.Args) {
+		prefetchCmd := newPrefetchCmd()
+		prefetchCmd.SetArgs(os.Args[2:])
+		if err := prefetchCmd.Execute(); err != nil {
+			log.Fatalf("Error occurred during command execution: %v", err)
+		}
+		return
+	}
+	if isDiffCmd(os.Args) {
+		diffCmd := newDiffCmd()
+		diffCmd.SetArgs(os.Args[2:])
+		if err := diffCmd.Execute(); err != nil {
+			log.Fatalf("Error occurred during command execution: %v", err)
+		}
+		return
+	}
+	if isDoctorCmd(os.Args) {
+		doctorCmd := newDoctorCmd()
+		doctorCmd.SetArgs(os.Args[2:])
+		if err := doctorCmd.Execute(); err != nil {
+			log.Fatalf("Error occurred during command execution: %v", err)
+		}
+		return
+	}
+	if isFeaturesCmd(os.Args) {
+		featuresCmd := newFeaturesCmd()
+		featuresCmd.SetArgs(os.Args[2:])
+		if err := featuresCmd.Execute(); err != nil {
+			log.Fatalf("Error occurred during command execution: %v", err)
+		}
+		return
+	}
 	rootCmd, err := newRootCmd(Mount)
 	if err != nil {
 		log.Fatalf("Error occurred while creating the root command: %v", err)
@@ -15,6 +15,8 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
@@ -24,13 +26,81 @@ import (
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func TestMain(m *testing.M) {
+	// An unknown profile leaves all the defaults untouched.
+	probeMachineProfile = func() machineprofile.Profile { return machineprofile.Profile{} }
+	os.Exit(m.Run())
+}
+
+func TestAutoconfig(t *testing.T) {
+	defer func(f func() machineprofile.Profile) { probeMachineProfile = f }(probeMachineProfile)
+	probeMachineProfile = func() machineprofile.Profile {
+		return machineprofile.Profile{AvailableMemoryBytes: 2 << 30, NumCPU: 2}
+	}
+	tests := []struct {
+		name                             string
+		args                             []string
+		expectedSequentialReadSizeMb     int64
+		expectedParallelDownloadsPerFile int64
+		expectedGlobalMaxBlocks          int64
+		expectedTuned                    bool
+	}{
+		{
+			name:                             "Tuned",
+			args:                             []string{"gcsfuse", "--enable-autoconfig", "abc", "pqr"},
+			expectedSequentialReadSizeMb:     50,
+			expectedParallelDownloadsPerFile: 4,
+			expectedGlobalMaxBlocks:          8,
+			expectedTuned:                    true,
+		},
+		{
+			name:                             "User set value is not tuned",
+			args:                             []string{"gcsfuse", "--enable-autoconfig", "--sequential-read-size-mb=100", "abc", "pqr"},
+			expectedSequentialReadSizeMb:     100,
+			expectedParallelDownloadsPerFile: 4,
+			expectedGlobalMaxBlocks:          8,
+			expectedTuned:                    true,
+		},
+		{
+			name:                             "Autoconfig disabled by default",
+			args:                             []string{"gcsfuse", "abc", "pqr"},
+			expectedSequentialReadSizeMb:     200,
+			expectedParallelDownloadsPerFile: 16,
+			// Rationalized from the default -1.
+			expectedGlobalMaxBlocks: math.MaxInt64,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual *cfg.Config
+			var actualTuning *machineTuning
+			cmd, err := newRootCmd(func(c *cfg.Config, _, _ string, tuning *machineTuning) error {
+				actual = c
+				actualTuning = tuning
+				return nil
+			})
+			require.Nil(t, err)
+			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
+
+			if assert.Nil(t, cmd.Execute()) {
+				assert.Equal(t, tc.expectedSequentialReadSizeMb, actual.GcsConnection.SequentialReadSizeMb)
+				assert.Equal(t, tc.expectedParallelDownloadsPerFile, actual.FileCache.ParallelDownloadsPerFile)
+				assert.Equal(t, tc.expectedGlobalMaxBlocks, actual.Write.GlobalMaxBlocks)
+				assert.Equal(t, tc.expectedTuned, actualTuning != nil)
+			}
+		})
+	}
+}
+
 func TestDefaultMaxParallelDownloads(t *testing.T) {
 	var actual *cfg.Config
-	cmd, err := newRootCmd(func(c *cfg.Config, _, _ string) error {
+	cmd, err := newRootCmd(func(c *cfg.Config, _, _ string, _ *machineTuning) error {
 		actual = c
 		return nil
 	})
@@ -42,6 +112,37 @@ func TestDefaultMaxParallelDownloads(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		json bool
+	}{
+		{"plain", []string{"gcsfuse", "--version"}, false},
+		{"json", []string{"gcsfuse", "--version", "--json"}, true},
+		{"json_single_hyphen", []string{"gcsfuse", "-v", "-json"}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := newRootCmd(func(*cfg.Config, string, string, *machineTuning) error { return nil })
+			require.NoError(t, err)
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
+
+			require.NoError(t, cmd.Execute())
+
+			if !tc.json {
+				assert.Equal(t, fmt.Sprintf("gcsfuse version %s\n", common.GetVersion()), out.String())
+				return
+			}
+			var buildInfo common.BuildInfo
+			require.NoError(t, json.Unmarshal(out.Bytes(), &buildInfo))
+			assert.Equal(t, common.GetBuildInfo(), buildInfo)
+		})
+	}
+}
+
 func TestCobraArgsNumInRange(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -72,7 +173,7 @@ func TestCobraArgsNumInRange(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, err := newRootCmd(func(*cfg.Config, string, string) error { return nil })
+			cmd, err := newRootCmd(func(*cfg.Config, string, string, *machineTuning) error { return nil })
 			require.Nil(t, err)
 			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
 
@@ -127,7 +228,7 @@ func TestArgsParsing_MountPoint(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var bucketName, mountPoint string
-			cmd, err := newRootCmd(func(_ *cfg.Config, b string, m string) error {
+			cmd, err := newRootCmd(func(_ *cfg.Config, b string, m string, _ *machineTuning) error {
 				bucketName = b
 				mountPoint = m
 				return nil
@@ -176,7 +277,7 @@ func TestArgsParsing_MountOptions(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var mountOptions []string
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				mountOptions = cfg.FileSystem.FuseOptions
 				return nil
 			})
@@ -279,7 +380,7 @@ func TestArgsParsing_WriteConfigFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var wc cfg.WriteConfig
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				wc = cfg.Write
 				return nil
 			})
@@ -306,19 +407,30 @@ func TestArgsParsing_FileCacheFlags(t *testing.T) {
 	}{
 		{
 			name: "Test file cache flags.",
-			args: []string{"gcsfuse", "--file-cache-cache-file-for-range-read", "--file-cache-download-chunk-size-mb=20", "--file-cache-enable-crc", "--cache-dir=/some/valid/dir", "--file-cache-enable-parallel-downloads", "--file-cache-max-parallel-downloads=40", "--file-cache-max-size-mb=100", "--file-cache-parallel-downloads-per-file=2", "--file-cache-enable-o-direct=false", "abc", "pqr"},
+			args: []string{"gcsfuse", "--file-cache-cache-file-for-range-read", "--file-cache-download-chunk-size-mb=20", "--file-cache-enable-chunk-checksums", "--file-cache-enable-crc", "--cache-dir=/some/valid/dir", "--file-cache-enable-parallel-downloads", "--file-cache-max-parallel-downloads=40", "--file-cache-exclude-patterns=*.tmp,scratch/*", "--file-cache-include-patterns=*.tfrecord", "--file-cache-max-disk-errors=3", "--file-cache-max-object-size-mb=1024", "--file-cache-max-size-mb=100", "--file-cache-memory-tier-size-mb=64", "--file-cache-min-object-size-mb=1", "--file-cache-parallel-downloads-per-file=2", "--file-cache-scrub-interval-secs=60", "--file-cache-slow-disk-io-threshold-ms=500", "--file-cache-small-file-prefetch-max-size-kb=256", "--file-cache-small-file-prefetch-parallelism=32", "--file-cache-enable-o-direct=false", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				CacheDir: "/some/valid/dir",
 				FileCache: cfg.FileCacheConfig{
-					CacheFileForRangeRead:    true,
-					DownloadChunkSizeMb:      20,
-					EnableCrc:                true,
-					EnableParallelDownloads:  true,
-					MaxParallelDownloads:     40,
-					MaxSizeMb:                100,
-					ParallelDownloadsPerFile: 2,
-					WriteBufferSize:          4 * 1024 * 1024,
-					EnableODirect:            false,
+					CacheFileForRangeRead:        true,
+					DownloadChunkSizeMb:          20,
+					EnableChunkChecksums:         true,
+					EnableCrc:                    true,
+					EnableParallelDownloads:      true,
+					ExcludePatterns:              []string{"*.tmp", "scratch/*"},
+					IncludePatterns:              []string{"*.tfrecord"},
+					MaxDiskErrors:                3,
+					MaxObjectSizeMb:              1024,
+					MaxParallelDownloads:         40,
+					MaxSizeMb:                    100,
+					MemoryTierSizeMb:             64,
+					MinObjectSizeMb:              1,
+					ParallelDownloadsPerFile:     2,
+					ScrubIntervalSecs:            60,
+					SlowDiskIoThresholdMs:        500,
+					SmallFilePrefetchMaxSizeKb:   256,
+					SmallFilePrefetchParallelism: 32,
+					WriteBufferSize:              4 * 1024 * 1024,
+					EnableODirect:                false,
 				},
 			},
 		},
@@ -327,15 +439,21 @@ func TestArgsParsing_FileCacheFlags(t *testing.T) {
 			args: []string{"gcsfuse", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				FileCache: cfg.FileCacheConfig{
-					CacheFileForRangeRead:    false,
-					DownloadChunkSizeMb:      50,
-					EnableCrc:                false,
-					EnableParallelDownloads:  false,
-					MaxParallelDownloads:     int64(max(16, 2*runtime.NumCPU())),
-					MaxSizeMb:                -1,
-					ParallelDownloadsPerFile: 16,
-					WriteBufferSize:          4 * 1024 * 1024,
-					EnableODirect:            false,
+					CacheFileForRangeRead:        false,
+					DownloadChunkSizeMb:          50,
+					EnableCrc:                    false,
+					EnableParallelDownloads:      false,
+					ExcludePatterns:              []string{},
+					IncludePatterns:              []string{},
+					MaxDiskErrors:                5,
+					MaxObjectSizeMb:              -1,
+					MaxParallelDownloads:         int64(max(16, 2*runtime.NumCPU())),
+					MaxSizeMb:                    -1,
+					ParallelDownloadsPerFile:     16,
+					ScrubIntervalSecs:            3600,
+					SmallFilePrefetchParallelism: 16,
+					WriteBufferSize:              4 * 1024 * 1024,
+					EnableODirect:                false,
 				},
 			},
 		},
@@ -344,7 +462,7 @@ func TestArgsParsing_FileCacheFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -396,7 +514,7 @@ func TestArgParsing_ExperimentalMetadataPrefetchFlag(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var experimentalMetadataPrefetch string
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				experimentalMetadataPrefetch = cfg.MetadataCache.ExperimentalMetadataPrefetchOnMount
 				return nil
 			})
@@ -429,7 +547,7 @@ func TestArgParsing_ExperimentalMetadataPrefetchFlag_Failed(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				return nil
 			})
 			require.Nil(t, err)
@@ -479,7 +597,7 @@ func TestArgsParsing_GCSAuthFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -517,7 +635,7 @@ func TestArgsParsing_GCSAuthFlagsThrowsError(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				return nil
 			})
 			require.Nil(t, err)
@@ -536,7 +654,7 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 	}{
 		{
 			name: "Test gcs connection flags.",
-			args: []string{"gcsfuse", "--billing-project=abc", "--client-protocol=http2", "--custom-endpoint=www.abc.com", "--experimental-enable-json-read", "--experimental-grpc-conn-pool-size=20", "--http-client-timeout=20s", "--limit-bytes-per-sec=30", "--limit-ops-per-sec=10", "--max-conns-per-host=1000", "--max-idle-conns-per-host=20", "--sequential-read-size-mb=70", "abc", "pqr"},
+			args: []string{"gcsfuse", "--billing-project=abc", "--client-protocol=http2", "--custom-endpoint=www.abc.com", "--experimental-enable-json-read", "--experimental-grpc-conn-pool-size=20", "--http-client-timeout=20s", "--limit-bytes-per-sec=30", "--limit-metadata-ops-burst=2", "--limit-metadata-ops-per-sec=5", "--limit-ops-per-sec=10", "--max-conns-per-host=1000", "--max-idle-conns-per-host=20", "--proxy-url=socks5://proxy:1080", "--proxy-headers=X-Tenant: abc", "--sequential-read-size-mb=70", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				GcsConnection: cfg.GcsConnectionConfig{
 					BillingProject:             "abc",
@@ -546,9 +664,13 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 					GrpcConnPoolSize:           20,
 					HttpClientTimeout:          20 * time.Second,
 					LimitBytesPerSec:           30,
+					LimitMetadataOpsBurst:      2,
+					LimitMetadataOpsPerSec:     5,
 					LimitOpsPerSec:             10,
 					MaxConnsPerHost:            1000,
 					MaxIdleConnsPerHost:        20,
+					ProxyHeaders:               []string{"X-Tenant: abc"},
+					ProxyUrl:                   "socks5://proxy:1080",
 					SequentialReadSizeMb:       70,
 				},
 			},
@@ -565,9 +687,11 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 					GrpcConnPoolSize:           1,
 					HttpClientTimeout:          0,
 					LimitBytesPerSec:           -1,
+					LimitMetadataOpsPerSec:     -1,
 					LimitOpsPerSec:             -1,
 					MaxConnsPerHost:            0,
 					MaxIdleConnsPerHost:        100,
+					ProxyHeaders:               []string{},
 					SequentialReadSizeMb:       200,
 				},
 			},
@@ -577,7 +701,7 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -607,7 +731,7 @@ func TestArgsParsing_GCSConnectionFlagsThrowsError(t *testing.T) {
 		},
 		{
 			name: "Invalid value for client-protocol flag",
-			args: []string{"gcsfuse", "--client-protocol=http3", "abc", "pqr"},
+			args: []string{"gcsfuse", "--client-protocol=http4", "abc", "pqr"},
 		},
 		{
 			name: "Invalid value for custom-endpoint flag",
@@ -621,7 +745,7 @@ func TestArgsParsing_GCSConnectionFlagsThrowsError(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				return nil
 			})
 			require.Nil(t, err)
@@ -642,21 +766,35 @@ func TestArgsParsing_FileSystemFlags(t *testing.T) {
 	}{
 		{
 			name: "normal",
-			args: []string{"gcsfuse", "--dir-mode=0777", "--disable-parallel-dirops", "--file-mode=0666", "--o", "ro", "--gid=7", "--ignore-interrupts=false", "--kernel-list-cache-ttl-secs=300", "--rename-dir-limit=10", "--temp-dir=~/temp", "--uid=8", "--precondition-errors=true", "abc", "pqr"},
+			args: []string{"gcsfuse", "--dir-entries-initial-capacity=1024", "--dir-entries-max-count=100000", "--dir-entries-max-size-mb=512", "--dir-mode=0777", "--disable-parallel-dirops", "--disable-writeback-cache", "--file-mode=0666", "--o", "ro", "--gid=7", "--idle-teardown-after=30m", "--ignore-interrupts=false", "--ignore-patterns=_temporary/,.DS_Store", "--pin-patterns=models/**/*.safetensors", "--kernel-list-cache-ttl-secs=300", "--rename-dir-limit=10", "--temp-dir=~/temp", "--uid=8", "--precondition-errors=true", "--honor-umask", "--uid-file-modes=1000:0640", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0777,
-					DisableParallelDirops:  true,
-					FileMode:               0666,
-					FuseOptions:            []string{"ro"},
-					Gid:                    7,
-					IgnoreInterrupts:       false,
-					KernelListCacheTtlSecs: 300,
-					RenameDirLimit:         10,
-					TempDir:                cfg.ResolvedPath(path.Join(hd, "temp")),
-					PreconditionErrors:     true,
-					Uid:                    8,
-					HandleSigterm:          true,
+					ClobberAction:             "fail",
+					CrossBucketRenameLimitMb:  1024,
+					HonorUmask:                true,
+					DirEntriesInitialCapacity: 1024,
+					DirEntriesMaxCount:        100000,
+					DirEntriesMaxSizeMb:       512,
+					DirMode:                   0777,
+					DisableParallelDirops:     true,
+					DisableWritebackCache:     true,
+					FileMode:                  0666,
+					FuseOptions:               []string{"ro"},
+					Gid:                       7,
+					IdleTeardownAfter:         30 * time.Minute,
+					IgnoreInterrupts:          false,
+					IgnorePatterns:            []string{"_temporary/", ".DS_Store"},
+					PinPatterns:               []string{"models/**/*.safetensors"},
+					KernelListCacheTtlSecs:    300,
+					RenameDirLimit:            10,
+					TempDir:                   cfg.ResolvedPath(path.Join(hd, "temp")),
+					PreconditionErrors:        true,
+					Uid:                       8,
+					UidFileModes:              []string{"1000:0640"},
+					UnsupportedFsAction:       "warn",
+					NestedMountAction:         "refuse",
+					OfflineMode:               "off",
+					HandleSigterm:             true,
 				},
 			},
 		},
@@ -665,18 +803,26 @@ func TestArgsParsing_FileSystemFlags(t *testing.T) {
 			args: []string{"gcsfuse", "--dir-mode=777", "--file-mode=666", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0777,
-					DisableParallelDirops:  false,
-					FileMode:               0666,
-					FuseOptions:            []string{},
-					Gid:                    -1,
-					IgnoreInterrupts:       true,
-					KernelListCacheTtlSecs: 0,
-					RenameDirLimit:         0,
-					TempDir:                "",
-					PreconditionErrors:     false,
-					Uid:                    -1,
-					HandleSigterm:          true,
+					ClobberAction:            "fail",
+					CrossBucketRenameLimitMb: 1024,
+					DirMode:                  0777,
+					DisableParallelDirops:    false,
+					FileMode:                 0666,
+					FuseOptions:              []string{},
+					Gid:                      -1,
+					IgnoreInterrupts:         true,
+					IgnorePatterns:           []string{},
+					PinPatterns:              []string{},
+					KernelListCacheTtlSecs:   0,
+					RenameDirLimit:           0,
+					TempDir:                  "",
+					PreconditionErrors:       false,
+					Uid:                      -1,
+					UidFileModes:             []string{},
+					UnsupportedFsAction:      "warn",
+					NestedMountAction:        "refuse",
+					OfflineMode:              "off",
+					HandleSigterm:            true,
 				},
 			},
 		},
@@ -685,18 +831,26 @@ func TestArgsParsing_FileSystemFlags(t *testing.T) {
 			args: []string{"gcsfuse", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				FileSystem: cfg.FileSystemConfig{
-					DirMode:                0755,
-					DisableParallelDirops:  false,
-					FileMode:               0644,
-					FuseOptions:            []string{},
-					Gid:                    -1,
-					IgnoreInterrupts:       true,
-					KernelListCacheTtlSecs: 0,
-					RenameDirLimit:         0,
-					TempDir:                "",
-					PreconditionErrors:     false,
-					Uid:                    -1,
-					HandleSigterm:          true,
+					ClobberAction:            "fail",
+					CrossBucketRenameLimitMb: 1024,
+					DirMode:                  0755,
+					DisableParallelDirops:    false,
+					FileMode:                 0644,
+					FuseOptions:              []string{},
+					Gid:                      -1,
+					IgnoreInterrupts:         true,
+					IgnorePatterns:           []string{},
+					PinPatterns:              []string{},
+					KernelListCacheTtlSecs:   0,
+					RenameDirLimit:           0,
+					TempDir:                  "",
+					PreconditionErrors:       false,
+					Uid:                      -1,
+					UidFileModes:             []string{},
+					UnsupportedFsAction:      "warn",
+					NestedMountAction:        "refuse",
+					OfflineMode:              "off",
+					HandleSigterm:            true,
 				},
 			},
 		},
@@ -705,7 +859,7 @@ func TestArgsParsing_FileSystemFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -750,7 +904,7 @@ func TestArgsParsing_FileSystemFlagsThrowsError(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				return nil
 			})
 			require.Nil(t, err)
@@ -786,7 +940,7 @@ func TestArgsParsing_ListFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -823,7 +977,7 @@ func TestArgsParsing_EnableHNSFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotEnableHNS bool
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotEnableHNS = cfg.EnableHns
 				return nil
 			})
@@ -839,6 +993,122 @@ func TestArgsParsing_EnableHNSFlags(t *testing.T) {
 	}
 }
 
+func TestArgsParsing_SlowOpsFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected cfg.SlowOpsLoggingConfig
+	}{
+		{
+			name:     "normal",
+			args:     []string{"gcsfuse", "--log-slow-op-threshold=2s", "--log-slow-op-sample-rate=0.1", "abc", "pqr"},
+			expected: cfg.SlowOpsLoggingConfig{Threshold: 2 * time.Second, SampleRate: 0.1},
+		},
+		{
+			name:     "default",
+			args:     []string{"gcsfuse", "abc", "pqr"},
+			expected: cfg.SlowOpsLoggingConfig{Threshold: 0, SampleRate: 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotConfig *cfg.Config
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
+				gotConfig = cfg
+				return nil
+			})
+			require.Nil(t, err)
+			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
+
+			err = cmd.Execute()
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, gotConfig.Logging.SlowOps)
+			}
+		})
+	}
+}
+
+func TestArgsParsing_MetricsBucketNamesFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected cfg.MetricsConfig
+	}{
+		{
+			name:     "normal",
+			args:     []string{"gcsfuse", "--metrics-bucket-names=a,b", "--metrics-max-bucket-names=10", "abc", "pqr"},
+			expected: cfg.MetricsConfig{BucketNames: []string{"a", "b"}, MaxBucketNames: 10},
+		},
+		{
+			name:     "default",
+			args:     []string{"gcsfuse", "abc", "pqr"},
+			expected: cfg.MetricsConfig{BucketNames: []string{}, MaxBucketNames: 0},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotConfig *cfg.Config
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
+				gotConfig = cfg
+				return nil
+			})
+			require.Nil(t, err)
+			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
+
+			err = cmd.Execute()
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected.BucketNames, gotConfig.Metrics.BucketNames)
+				assert.Equal(t, tc.expected.MaxBucketNames, gotConfig.Metrics.MaxBucketNames)
+			}
+		})
+	}
+}
+
+func TestArgsParsing_RecentErrorsFlags(t *testing.T) {
+	tests := []struct {
+		name                      string
+		args                      []string
+		expectedRecentErrorsCount int64
+		expectedControlSocket     cfg.ResolvedPath
+	}{
+		{
+			name:                      "normal",
+			args:                      []string{"gcsfuse", "--log-recent-errors-count=20", "--control-socket=/tmp/gcsfuse.sock", "abc", "pqr"},
+			expectedRecentErrorsCount: 20,
+			expectedControlSocket:     "/tmp/gcsfuse.sock",
+		},
+		{
+			name:                      "default",
+			args:                      []string{"gcsfuse", "abc", "pqr"},
+			expectedRecentErrorsCount: 100,
+			expectedControlSocket:     "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotConfig *cfg.Config
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
+				gotConfig = cfg
+				return nil
+			})
+			require.Nil(t, err)
+			cmd.SetArgs(convertToPosixArgs(tc.args, cmd))
+
+			err = cmd.Execute()
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expectedRecentErrorsCount, gotConfig.Logging.RecentErrorsCount)
+				assert.Equal(t, tc.expectedControlSocket, gotConfig.Debug.ControlSocket)
+			}
+		})
+	}
+}
+
 func TestArgsParsing_MetricsFlags(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -849,45 +1119,54 @@ func TestArgsParsing_MetricsFlags(t *testing.T) {
 			name: "default",
 			args: []string{"gcsfuse", "abc", "pqr"},
 			expected: &cfg.MetricsConfig{
-				EnableOtel: false,
+				BucketNames: []string{},
+				EnableOtel:  false,
 			},
 		},
 		{
 			name: "enable_otel_normal",
 			args: []string{"gcsfuse", "--enable-otel", "abc", "pqr"},
 			expected: &cfg.MetricsConfig{
-				EnableOtel: true,
+				BucketNames: []string{},
+				EnableOtel:  true,
 			},
 		},
 		{
 			name: "enable_otel_false",
 			args: []string{"gcsfuse", "--enable-otel=false", "abc", "pqr"},
 			expected: &cfg.MetricsConfig{
-				EnableOtel: false,
+				BucketNames: []string{},
+				EnableOtel:  false,
 			},
 		},
 		{
 			name: "enable_otel_false",
 			args: []string{"gcsfuse", "--enable-otel=true", "abc", "pqr"},
 			expected: &cfg.MetricsConfig{
-				EnableOtel: true,
+				BucketNames: []string{},
+				EnableOtel:  true,
 			},
 		},
 		{
 			name:     "cloud-metrics-export-interval-secs-positive",
 			args:     []string{"gcsfuse", "--cloud-metrics-export-interval-secs=10", "abc", "pqr"},
-			expected: &cfg.MetricsConfig{CloudMetricsExportIntervalSecs: 10},
+			expected: &cfg.MetricsConfig{BucketNames: []string{}, CloudMetricsExportIntervalSecs: 10},
 		},
 		{
 			name:     "stackdriver-export-interval-positive",
 			args:     []string{"gcsfuse", "--stackdriver-export-interval=10h", "abc", "pqr"},
-			expected: &cfg.MetricsConfig{CloudMetricsExportIntervalSecs: 10 * 3600, StackdriverExportInterval: time.Duration(10) * time.Hour},
+			expected: &cfg.MetricsConfig{BucketNames: []string{}, CloudMetricsExportIntervalSecs: 10 * 3600, StackdriverExportInterval: time.Duration(10) * time.Hour},
+		},
+		{
+			name:     "metrics-enable-cost-estimation",
+			args:     []string{"gcsfuse", "--metrics-enable-cost-estimation", "abc", "pqr"},
+			expected: &cfg.MetricsConfig{BucketNames: []string{}, EnableCostEstimation: true},
 		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -913,38 +1192,41 @@ func TestArgsParsing_MetricsViewConfig(t *testing.T) {
 			name:    "default",
 			cfgFile: "empty.yml",
 			expected: &cfg.MetricsConfig{
-				EnableOtel: false,
+				BucketNames: []string{},
+				EnableOtel:  false,
 			},
 		},
 		{
 			name:    "enable_otel_true",
 			cfgFile: "enable_otel_true.yml",
 			expected: &cfg.MetricsConfig{
-				EnableOtel: true,
+				BucketNames: []string{},
+				EnableOtel:  true,
 			},
 		},
 		{
 			name:    "enable_otel_false",
 			cfgFile: "enable_otel_false.yml",
 			expected: &cfg.MetricsConfig{
-				EnableOtel: false,
+				BucketNames: []string{},
+				EnableOtel:  false,
 			},
 		},
 		{
 			name:     "cloud-metrics-export-interval-secs-positive",
 			cfgFile:  "metrics_export_interval_positive.yml",
-			expected: &cfg.MetricsConfig{CloudMetricsExportIntervalSecs: 100},
+			expected: &cfg.MetricsConfig{BucketNames: []string{}, CloudMetricsExportIntervalSecs: 100},
 		},
 		{
 			name:     "stackdriver-export-interval-positive",
 			cfgFile:  "stackdriver_export_interval_positive.yml",
-			expected: &cfg.MetricsConfig{CloudMetricsExportIntervalSecs: 12 * 3600, StackdriverExportInterval: 12 * time.Hour},
+			expected: &cfg.MetricsConfig{BucketNames: []string{}, CloudMetricsExportIntervalSecs: 12 * 3600, StackdriverExportInterval: 12 * time.Hour},
 		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -968,7 +1250,7 @@ func TestArgsParsing_MetadataCacheFlags(t *testing.T) {
 	}{
 		{
 			name: "normal",
-			args: []string{"gcsfuse", "--stat-cache-capacity=2000", "--stat-cache-ttl=2m", "--type-cache-ttl=1m20s", "--enable-nonexistent-type-cache", "--experimental-metadata-prefetch-on-mount=async", "--stat-cache-max-size-mb=15", "--metadata-cache-ttl-secs=25", "--type-cache-max-size-mb=30", "abc", "pqr"},
+			args: []string{"gcsfuse", "--stat-cache-capacity=2000", "--stat-cache-ttl=2m", "--type-cache-ttl=1m20s", "--enable-nonexistent-type-cache", "--experimental-metadata-prefetch-on-mount=async", "--stat-cache-max-size-mb=15", "--metadata-cache-ttl-secs=25", "--type-cache-max-size-mb=30", "--permission-denied-ttl-secs=10", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				MetadataCache: cfg.MetadataCacheConfig{
 					DeprecatedStatCacheCapacity:         2000,
@@ -976,6 +1258,9 @@ func TestArgsParsing_MetadataCacheFlags(t *testing.T) {
 					DeprecatedTypeCacheTtl:              80 * time.Second,
 					EnableNonexistentTypeCache:          true,
 					ExperimentalMetadataPrefetchOnMount: "async",
+					ExperimentalMetadataPrefetchWorkers: 16,
+					ListCacheMaxSizeMb:                  32,
+					PermissionDeniedTtlSecs:             10,
 					StatCacheMaxSizeMb:                  15,
 					TtlSecs:                             25,
 					TypeCacheMaxSizeMb:                  30,
@@ -992,6 +1277,9 @@ func TestArgsParsing_MetadataCacheFlags(t *testing.T) {
 					DeprecatedTypeCacheTtl:              60 * time.Second,
 					EnableNonexistentTypeCache:          false,
 					ExperimentalMetadataPrefetchOnMount: "disabled",
+					ExperimentalMetadataPrefetchWorkers: 16,
+					ListCacheMaxSizeMb:                  32,
+					PermissionDeniedTtlSecs:             5,
 					StatCacheMaxSizeMb:                  32,
 					TtlSecs:                             60,
 					TypeCacheMaxSizeMb:                  4,
@@ -1003,7 +1291,7 @@ func TestArgsParsing_MetadataCacheFlags(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
@@ -1027,13 +1315,14 @@ func TestArgParsing_GCSRetries(t *testing.T) {
 	}{
 		{
 			name: "Test with non default chunkTransferTimeout",
-			args: []string{"gcsfuse", "--chunk-transfer-timeout-secs=30", "abc", "pqr"},
+			args: []string{"gcsfuse", "--chunk-transfer-timeout-secs=30", "--max-connection-retry-attempts=5", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				GcsRetries: cfg.GcsRetriesConfig{
-					ChunkTransferTimeoutSecs: 30,
-					MaxRetryAttempts:         0,
-					MaxRetrySleep:            30 * time.Second,
-					Multiplier:               2,
+					ChunkTransferTimeoutSecs:   30,
+					MaxConnectionRetryAttempts: 5,
+					MaxRetryAttempts:           0,
+					MaxRetrySleep:              30 * time.Second,
+					Multiplier:                 2,
 					ReadStall: cfg.ReadStallGcsRetriesConfig{
 						Enable:              false,
 						InitialReqTimeout:   20 * time.Second,
@@ -1049,7 +1338,7 @@ func TestArgParsing_GCSRetries(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			var gotConfig *cfg.Config
-			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string) error {
+			cmd, err := newRootCmd(func(cfg *cfg.Config, _, _ string, _ *machineTuning) error {
 				gotConfig = cfg
 				return nil
 			})
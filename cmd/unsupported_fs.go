@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// fuseSuperMagic is the magic number of the FUSE file systems in statfs(2).
+const fuseSuperMagic = 0x65735546
+
+// unsupportedFilesystems are the file systems, by their magic number in
+// statfs(2), on which the file locking and atime semantics gcsfuse relies on
+// for cache-dir and temp-dir misbehave.
+var unsupportedFilesystems = map[uint32]string{
+	0x6969:         "NFS",
+	0xff534d42:     "CIFS",
+	0xfe534d42:     "SMB2",
+	0x517b:         "SMB",
+	0x01021997:     "9p",
+	fuseSuperMagic: "FUSE",
+	0x794c7630:     "overlayfs",
+}
+
+// Overridden in tests.
+var statfs = syscall.Statfs
+
+// checkDirFilesystem returns an error if the supplied directory, or its closest
+// existing ancestor if it's yet to be created, is on one of
+// unsupportedFilesystems.
+func checkDirFilesystem(dir string) error {
+	for {
+		var st syscall.Statfs_t
+		err := statfs(dir, &st)
+		if errors.Is(err, os.ErrNotExist) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			// The directory is checked for being usable separately.
+			return nil
+		}
+		if name, ok := unsupportedFilesystems[uint32(st.Type)]; ok {
+			return fmt.Errorf("%q is on %s, on which file locking and atime don't behave as on a local file system", dir, name)
+		}
+		return nil
+	}
+}
+
+// checkLocalDirs warns, or fails with unsupported-fs-action refuse, if
+// cache-dir or temp-dir is on an unsupported file system.
+func checkLocalDirs(c *cfg.Config) error {
+	dirs := []struct {
+		flag string
+		path cfg.ResolvedPath
+	}{
+		{"cache-dir", c.CacheDir},
+		{"temp-dir", c.FileSystem.TempDir},
+	}
+	for _, d := range dirs {
+		if d.path == "" {
+			continue
+		}
+		err := checkDirFilesystem(string(d.path))
+		if err == nil {
+			continue
+		}
+		if c.FileSystem.UnsupportedFsAction == cfg.UnsupportedFsActionRefuse {
+			return fmt.Errorf("%s: %w", d.flag, err)
+		}
+		logger.Warnf("%s: %v; gcsfuse may misbehave, e.g. corrupt the cached or staged files", d.flag, err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatfs reports the supplied file system type for the existing paths.
+func fakeStatfs(t *testing.T, fsType int64) {
+	t.Helper()
+	old := statfs
+	statfs = func(path string, st *syscall.Statfs_t) error {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		st.Type = fsType
+		return nil
+	}
+	t.Cleanup(func() { statfs = old })
+}
+
+func TestCheckLocalDirs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsType  int64
+		action  string
+		wantErr string
+	}{
+		{
+			name:   "local_fs",
+			fsType: 0xef53, // ext4
+			action: cfg.UnsupportedFsActionRefuse,
+		},
+		{
+			name:   "nfs_warns",
+			fsType: 0x6969,
+			action: cfg.UnsupportedFsActionWarn,
+		},
+		{
+			name:    "nfs_refused",
+			fsType:  0x6969,
+			action:  cfg.UnsupportedFsActionRefuse,
+			wantErr: "is on NFS",
+		},
+		{
+			name:    "overlayfs_refused",
+			fsType:  0x794c7630,
+			action:  cfg.UnsupportedFsActionRefuse,
+			wantErr: "is on overlayfs",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeStatfs(t, tc.fsType)
+			dir := t.TempDir()
+			c := &cfg.Config{
+				// The cache directory is yet to be created, its parent is checked.
+				CacheDir:   cfg.ResolvedPath(filepath.Join(dir, "cache", "gcsfuse")),
+				FileSystem: cfg.FileSystemConfig{UnsupportedFsAction: tc.action},
+			}
+
+			err := checkLocalDirs(c)
+
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "cache-dir: \""+dir+"\"")
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// OtherBuckets is the bucket_name of the metrics of the buckets past
+// metrics-max-bucket-names.
+const OtherBuckets = "other"
+
+// BucketNames decides the bucket_name attribute of the GCS metrics of the
+// buckets of a dynamic mount, capping the number of series: their name if
+// they're allow-listed or among the first max buckets mounted, or else
+// OtherBuckets.
+type BucketNames struct {
+	allowed map[string]bool
+	max     int
+
+	mu sync.Mutex
+	// The buckets named past the allow-list.
+	//
+	// INVARIANT: len(named) <= max
+	// GUARDED_BY(mu)
+	named map[string]bool
+}
+
+// NewBucketNames returns the BucketNames naming the allowed buckets and the
+// first max other ones. It returns nil, with which the metrics of all the
+// buckets are merged, if there are none.
+func NewBucketNames(allowed []string, max int) *BucketNames {
+	if len(allowed) == 0 && max == 0 {
+		return nil
+	}
+	b := &BucketNames{
+		allowed: make(map[string]bool, len(allowed)),
+		max:     max,
+		named:   make(map[string]bool),
+	}
+	for _, name := range allowed {
+		b.allowed[name] = true
+	}
+	return b
+}
+
+// Of returns the bucket_name of the metrics of the bucket, "" if the metrics of
+// the buckets are merged.
+func (b *BucketNames) Of(bucket string) string {
+	if b == nil {
+		return ""
+	}
+	if b.allowed[bucket] {
+		return bucket
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.named[bucket] {
+		return bucket
+	}
+	if len(b.named) < b.max {
+		b.named[bucket] = true
+		return bucket
+	}
+	return OtherBuckets
+}
+
+// BucketMetricHandler is implemented by the buckets recording their GCS
+// metrics with a handle of their own.
+type BucketMetricHandler interface {
+	MetricHandle() MetricHandle
+}
+
+// MetricHandleOf returns the handle of the GCS metrics of the bucket, if it has
+// one, or else h.
+func MetricHandleOf(bucket any, h MetricHandle) MetricHandle {
+	if b, ok := bucket.(BucketMetricHandler); ok && b.MetricHandle() != nil {
+		return b.MetricHandle()
+	}
+	return h
+}
+
+// WithBucketName returns h annotating the bytes read and written, the readers,
+// the requests and the cost drivers of GCS with the supplied bucket_name, h
+// itself if it's "".
+func WithBucketName(h MetricHandle, bucketName string) MetricHandle {
+	if bucketName == "" {
+		return h
+	}
+	return &bucketMetrics{
+		MetricHandle: h,
+		attr:         MetricAttr{Key: BucketName, Value: bucketName},
+	}
+}
+
+type bucketMetrics struct {
+	MetricHandle
+	attr MetricAttr
+}
+
+// with returns attrs and the bucket_name, without modifying attrs, which the
+// callers may reuse.
+func (b *bucketMetrics) with(attrs []MetricAttr) []MetricAttr {
+	return append(attrs[:len(attrs):len(attrs)], b.attr)
+}
+
+func (b *bucketMetrics) GCSReadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSReadBytesCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSReaderCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSReaderCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSRequestCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSRequestCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSRequestLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	b.MetricHandle.GCSRequestLatency(ctx, value, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSReadCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSDownloadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSDownloadBytesCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSWriteBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSWriteBytesCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSClassAOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSClassAOpCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSClassBOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSClassBOpCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSEgressBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSEgressBytesCount(ctx, inc, b.with(attrs))
+}
+
+func (b *bucketMetrics) GCSRetrievalBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	b.MetricHandle.GCSRetrievalBytesCount(ctx, inc, b.with(attrs))
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketNames_Disabled(t *testing.T) {
+	b := NewBucketNames(nil, 0)
+
+	assert.Nil(t, b)
+	assert.Equal(t, "", b.Of("bucket"))
+}
+
+func TestBucketNames_CapsTheNamedBuckets(t *testing.T) {
+	b := NewBucketNames([]string{"allowed"}, 2)
+
+	assert.Equal(t, "a", b.Of("a"))
+	assert.Equal(t, "allowed", b.Of("allowed"))
+	assert.Equal(t, "b", b.Of("b"))
+	assert.Equal(t, OtherBuckets, b.Of("c"))
+	// The buckets keep their name.
+	assert.Equal(t, "a", b.Of("a"))
+	assert.Equal(t, OtherBuckets, b.Of("c"))
+}
+
+func TestBucketNames_OnlyAllowList(t *testing.T) {
+	b := NewBucketNames([]string{"allowed"}, 0)
+
+	assert.Equal(t, "allowed", b.Of("allowed"))
+	assert.Equal(t, OtherBuckets, b.Of("a"))
+}
+
+type recordedAttrsMetrics struct {
+	MetricHandle
+	attrs [][]MetricAttr
+}
+
+func (r *recordedAttrsMetrics) GCSRequestCount(_ context.Context, _ int64, attrs []MetricAttr) {
+	r.attrs = append(r.attrs, attrs)
+}
+
+func (r *recordedAttrsMetrics) GCSTokenRefreshFailureCount(_ context.Context, _ int64, attrs []MetricAttr) {
+	r.attrs = append(r.attrs, attrs)
+}
+
+func TestWithBucketName(t *testing.T) {
+	r := &recordedAttrsMetrics{MetricHandle: NewNoopMetrics()}
+	h := WithBucketName(r, "bucket")
+	attrs := make([]MetricAttr, 1, 2)
+	attrs[0] = MetricAttr{Key: GCSMethod, Value: "StatObject"}
+
+	h.GCSRequestCount(context.Background(), 1, attrs)
+	h.GCSTokenRefreshFailureCount(context.Background(), 1, nil)
+
+	assert.Equal(t, [][]MetricAttr{
+		{{Key: GCSMethod, Value: "StatObject"}, {Key: BucketName, Value: "bucket"}},
+		nil,
+	}, r.attrs)
+	// The attributes of the caller aren't modified.
+	assert.Equal(t, []MetricAttr{{Key: GCSMethod, Value: "StatObject"}}, attrs)
+}
+
+func TestWithBucketName_Merged(t *testing.T) {
+	h := NewNoopMetrics()
+
+	assert.Equal(t, h, WithBucketName(h, ""))
+}
+
+type bucketWithMetricHandle struct {
+	h MetricHandle
+}
+
+func (b bucketWithMetricHandle) MetricHandle() MetricHandle {
+	return b.h
+}
+
+func TestMetricHandleOf(t *testing.T) {
+	fallback := NewNoopMetrics()
+	own := WithBucketName(fallback, "bucket")
+
+	assert.Equal(t, own, MetricHandleOf(bucketWithMetricHandle{own}, fallback))
+	assert.Equal(t, fallback, MetricHandleOf(bucketWithMetricHandle{nil}, fallback))
+	assert.Equal(t, fallback, MetricHandleOf("not a bucket", fallback))
+}
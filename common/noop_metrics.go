@@ -23,17 +23,46 @@ func NewNoopMetrics() MetricHandle {
 
 type noopMetrics struct{}
 
-func (*noopMetrics) GCSReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)       {}
-func (*noopMetrics) GCSReaderCount(_ context.Context, _ int64, _ []MetricAttr)          {}
-func (*noopMetrics) GCSRequestCount(_ context.Context, _ int64, _ []MetricAttr)         {}
-func (*noopMetrics) GCSRequestLatency(_ context.Context, value float64, _ []MetricAttr) {}
-func (*noopMetrics) GCSReadCount(_ context.Context, _ int64, _ []MetricAttr)            {}
-func (*noopMetrics) GCSDownloadBytesCount(_ context.Context, _ int64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)              {}
+func (*noopMetrics) GCSReaderCount(_ context.Context, _ int64, _ []MetricAttr)                 {}
+func (*noopMetrics) GCSRequestCount(_ context.Context, _ int64, _ []MetricAttr)                {}
+func (*noopMetrics) GCSRequestLatency(_ context.Context, value float64, _ []MetricAttr)        {}
+func (*noopMetrics) GCSReadCount(_ context.Context, _ int64, _ []MetricAttr)                   {}
+func (*noopMetrics) GCSDownloadBytesCount(_ context.Context, _ int64, _ []MetricAttr)          {}
+func (*noopMetrics) GCSBucketLocationMismatchCount(_ context.Context, _ int64, _ []MetricAttr) {}
+func (*noopMetrics) GCSReadStreamQueuedCount(_ context.Context, _ int64, _ []MetricAttr)       {}
+func (*noopMetrics) GCSTokenRefreshLatency(_ context.Context, value float64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSTokenRefreshFailureCount(_ context.Context, _ int64, _ []MetricAttr)    {}
+func (*noopMetrics) GCSReadChecksumMismatchCount(_ context.Context, _ int64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSUploadBytesRetriedCount(_ context.Context, _ int64, _ []MetricAttr)     {}
+func (*noopMetrics) GCSUploadSessionRestartCount(_ context.Context, _ int64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSUploadFinalizeLatency(_ context.Context, value float64, _ []MetricAttr) {}
+func (*noopMetrics) GCSUploadBackpressureTime(_ context.Context, _ int64, _ []MetricAttr)      {}
+func (*noopMetrics) GCSInflightRequests(_ context.Context, _ int64, _ []MetricAttr)            {}
+func (*noopMetrics) GCSCoalescedReadCount(_ context.Context, _ int64, _ []MetricAttr)          {}
+func (*noopMetrics) GCSReadSchedulingDelay(_ context.Context, value float64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSOfflineRequestCount(_ context.Context, _ int64, _ []MetricAttr)         {}
+func (*noopMetrics) GCSWriteBytesCount(_ context.Context, _ int64, _ []MetricAttr)             {}
+func (*noopMetrics) GCSClassAOpCount(_ context.Context, _ int64, _ []MetricAttr)               {}
+func (*noopMetrics) GCSClassBOpCount(_ context.Context, _ int64, _ []MetricAttr)               {}
+func (*noopMetrics) GCSEgressBytesCount(_ context.Context, _ int64, _ []MetricAttr)            {}
+func (*noopMetrics) GCSRetrievalBytesCount(_ context.Context, _ int64, _ []MetricAttr)         {}
 
-func (*noopMetrics) OpsCount(_ context.Context, _ int64, _ []MetricAttr)         {}
-func (*noopMetrics) OpsLatency(_ context.Context, value float64, _ []MetricAttr) {}
-func (*noopMetrics) OpsErrorCount(_ context.Context, _ int64, _ []MetricAttr)    {}
+func (*noopMetrics) OpsCount(_ context.Context, _ int64, _ []MetricAttr)                    {}
+func (*noopMetrics) OpsLatency(_ context.Context, value float64, _ []MetricAttr)            {}
+func (*noopMetrics) OpsErrorCount(_ context.Context, _ int64, _ []MetricAttr)               {}
+func (*noopMetrics) OpsKernelQueueDepth(_ context.Context, _ int64, _ []MetricAttr)         {}
+func (*noopMetrics) OpsKernelQueueLatency(_ context.Context, value float64, _ []MetricAttr) {}
+func (*noopMetrics) OpsDispatchLatency(_ context.Context, value float64, _ []MetricAttr)    {}
+func (*noopMetrics) MetadataPrefetchEntryCount(_ context.Context, _ int64, _ []MetricAttr)  {}
+func (*noopMetrics) WriteQuotaExceededCount(_ context.Context, _ int64, _ []MetricAttr)     {}
+func (*noopMetrics) DirListingEvictionCount(_ context.Context, _ int64, _ []MetricAttr)     {}
+func (*noopMetrics) BufferPoolGetCount(_ context.Context, _ int64, _ []MetricAttr)          {}
+func (*noopMetrics) BufferPoolInUseBytes(_ context.Context, _ int64, _ []MetricAttr)        {}
 
 func (*noopMetrics) FileCacheReadCount(_ context.Context, _ int64, _ []MetricAttr)         {}
 func (*noopMetrics) FileCacheReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)    {}
 func (*noopMetrics) FileCacheReadLatency(_ context.Context, value float64, _ []MetricAttr) {}
+func (*noopMetrics) FileCacheCorruptFileCount(_ context.Context, _ int64, _ []MetricAttr)  {}
+func (*noopMetrics) FileCacheDiskErrorCount(_ context.Context, _ int64, _ []MetricAttr)    {}
+func (*noopMetrics) FileCacheDegraded(_ context.Context, _ int64, _ []MetricAttr)          {}
@@ -45,6 +45,65 @@ const (
 
 	// CacheHit annotates the read operation from file cache with true or false.
 	CacheHit = "cache_hit"
+
+	// LocationMismatchReason annotates why the bucket isn't co-located with the
+	// VM - region_mismatch/multi_region.
+	LocationMismatchReason = "location_mismatch_reason"
+
+	// DetectedBy annotates the detection of a corrupt file in file cache with
+	// how it was detected - read/scrub.
+	DetectedBy = "detected_by"
+
+	// DiskErrorType annotates the failed or slow IOs on the file cache
+	// directory with their type - io_error/slow_io.
+	DiskErrorType = "disk_error_type"
+
+	// ReadRegion annotates the bytes read from GCS with the region serving
+	// them, when reads are sent to a regional endpoint.
+	ReadRegion = "read_region"
+
+	// RequestClass annotates the GCS requests limited by the
+	// max-concurrent-*-requests with their class - list/stat/read/write.
+	RequestClass = "request_class"
+
+	// CoalesceResult annotates the small random reads, with
+	// read-coalesce-window-kb, with whether they fetched a range from GCS or
+	// were merged into the range of a previous read - fetched/merged.
+	CoalesceResult = "coalesce_result"
+
+	// ReadClass annotates the read streams scheduled fairly across the file
+	// handles, with read-min-share-percent, with their class -
+	// interactive/bulk.
+	ReadClass = "read_class"
+
+	// OfflineOutcome annotates the GCS requests made while GCS is unreachable,
+	// with offline-mode=serve-cache, with how they were answered -
+	// served_from_cache/failed_fast.
+	OfflineOutcome = "offline_outcome"
+
+	// PrefetchResult annotates the entries discovered by the metadata prefetch
+	// on mount with how it ended - completed/capped/failed.
+	PrefetchResult = "prefetch_result"
+
+	// QuotaType annotates the ops failed by the write quota of the mount with
+	// the quota they exceeded - bytes/objects.
+	QuotaType = "quota_type"
+
+	// BucketName annotates the GCS metrics of the buckets of a dynamic mount
+	// with their name, with metrics-bucket-names or metrics-max-bucket-names.
+	BucketName = "bucket_name"
+
+	// EgressLocality annotates the bytes read from GCS with where they travel
+	// from the bucket - same_region/cross_region/internet/unknown.
+	EgressLocality = "egress_locality"
+
+	// StorageClass annotates the bytes read from GCS incurring retrieval fees
+	// with the storage class of the bucket.
+	StorageClass = "storage_class"
+
+	// PoolHit annotates the buffers taken from the buffer pool of the read
+	// paths with whether they were reused - true/false.
+	PoolHit = "pool_hit"
 )
 
 type ocMetrics struct {
@@ -56,15 +115,48 @@ type ocMetrics struct {
 	gcsReadCount          *stats.Int64Measure
 	gcsDownloadBytesCount *stats.Int64Measure
 
+	gcsBucketLocationMismatchCount *stats.Int64Measure
+	gcsReadStreamQueuedCount       *stats.Int64Measure
+	gcsTokenRefreshLatency         *stats.Float64Measure
+	gcsTokenRefreshFailureCount    *stats.Int64Measure
+	gcsReadChecksumMismatchCount   *stats.Int64Measure
+
+	gcsUploadBytesRetriedCount    *stats.Int64Measure
+	gcsUploadSessionRestartCount  *stats.Int64Measure
+	gcsUploadFinalizeLatency      *stats.Float64Measure
+	gcsUploadBackpressureTimeUsec *stats.Int64Measure
+	gcsInflightRequests           *stats.Int64Measure
+	gcsCoalescedReadCount         *stats.Int64Measure
+	gcsReadSchedulingDelay        *stats.Float64Measure
+	gcsOfflineRequestCount        *stats.Int64Measure
+	gcsWriteBytesCount            *stats.Int64Measure
+	gcsClassAOpCount              *stats.Int64Measure
+	gcsClassBOpCount              *stats.Int64Measure
+	gcsEgressBytesCount           *stats.Int64Measure
+	gcsRetrievalBytesCount        *stats.Int64Measure
+
 	// Ops measures
 	opsCount      *stats.Int64Measure
 	opsErrorCount *stats.Int64Measure
 	opsLatency    *stats.Float64Measure
 
+	opsKernelQueueDepth   *stats.Int64Measure
+	opsKernelQueueLatency *stats.Float64Measure
+	opsDispatchLatency    *stats.Float64Measure
+
+	metadataPrefetchEntryCount *stats.Int64Measure
+	writeQuotaExceededCount    *stats.Int64Measure
+	dirListingEvictionCount    *stats.Int64Measure
+	bufferPoolGetCount         *stats.Int64Measure
+	bufferPoolInUseBytes       *stats.Int64Measure
+
 	// File cache measures
-	fileCacheReadCount      *stats.Int64Measure
-	fileCacheReadBytesCount *stats.Int64Measure
-	fileCacheReadLatency    *stats.Float64Measure
+	fileCacheReadCount        *stats.Int64Measure
+	fileCacheReadBytesCount   *stats.Int64Measure
+	fileCacheReadLatency      *stats.Float64Measure
+	fileCacheCorruptFileCount *stats.Int64Measure
+	fileCacheDiskErrorCount   *stats.Int64Measure
+	fileCacheDegraded         *stats.Int64Measure
 }
 
 func attrsToTags(attrs []MetricAttr) []tag.Mutator {
@@ -112,6 +204,69 @@ func (o *ocMetrics) GCSReadCount(ctx context.Context, inc int64, attrs []MetricA
 func (o *ocMetrics) GCSDownloadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	recordOCMetric(ctx, o.gcsDownloadBytesCount, inc, attrs, "GCS download bytes count")
 }
+func (o *ocMetrics) GCSBucketLocationMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsBucketLocationMismatchCount, inc, attrs, "GCS bucket location mismatch count")
+}
+func (o *ocMetrics) GCSReadStreamQueuedCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsReadStreamQueuedCount, inc, attrs, "GCS read stream queued count")
+}
+func (o *ocMetrics) GCSTokenRefreshLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	recordOCLatencyMetric(ctx, o.gcsTokenRefreshLatency, value, attrs, "GCS token refresh latency")
+}
+func (o *ocMetrics) GCSTokenRefreshFailureCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsTokenRefreshFailureCount, inc, attrs, "GCS token refresh failure count")
+}
+func (o *ocMetrics) GCSReadChecksumMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsReadChecksumMismatchCount, inc, attrs, "GCS read checksum mismatch count")
+}
+func (o *ocMetrics) GCSUploadBytesRetriedCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsUploadBytesRetriedCount, inc, attrs, "GCS upload bytes retried count")
+}
+func (o *ocMetrics) GCSUploadSessionRestartCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsUploadSessionRestartCount, inc, attrs, "GCS upload session restart count")
+}
+func (o *ocMetrics) GCSUploadFinalizeLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	recordOCLatencyMetric(ctx, o.gcsUploadFinalizeLatency, value, attrs, "GCS upload finalize latency")
+}
+func (o *ocMetrics) GCSUploadBackpressureTime(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsUploadBackpressureTimeUsec, inc, attrs, "GCS upload backpressure time")
+}
+
+func (o *ocMetrics) GCSInflightRequests(ctx context.Context, value int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsInflightRequests, value, attrs, "GCS inflight requests")
+}
+
+func (o *ocMetrics) GCSCoalescedReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsCoalescedReadCount, inc, attrs, "GCS coalesced read count")
+}
+
+func (o *ocMetrics) GCSReadSchedulingDelay(ctx context.Context, value float64, attrs []MetricAttr) {
+	recordOCLatencyMetric(ctx, o.gcsReadSchedulingDelay, value, attrs, "GCS read scheduling delay")
+}
+
+func (o *ocMetrics) GCSOfflineRequestCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsOfflineRequestCount, inc, attrs, "GCS offline request count")
+}
+
+func (o *ocMetrics) GCSWriteBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsWriteBytesCount, inc, attrs, "GCS write bytes count")
+}
+
+func (o *ocMetrics) GCSClassAOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsClassAOpCount, inc, attrs, "GCS class A op count")
+}
+
+func (o *ocMetrics) GCSClassBOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsClassBOpCount, inc, attrs, "GCS class B op count")
+}
+
+func (o *ocMetrics) GCSEgressBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsEgressBytesCount, inc, attrs, "GCS egress bytes count")
+}
+
+func (o *ocMetrics) GCSRetrievalBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsRetrievalBytesCount, inc, attrs, "GCS retrieval bytes count")
+}
 
 func (o *ocMetrics) OpsCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	recordOCMetric(ctx, o.opsCount, inc, attrs, "file system op count")
@@ -122,6 +277,35 @@ func (o *ocMetrics) OpsLatency(ctx context.Context, value float64, attrs []Metri
 func (o *ocMetrics) OpsErrorCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	recordOCMetric(ctx, o.opsErrorCount, inc, attrs, "file system op error count")
 }
+func (o *ocMetrics) OpsKernelQueueDepth(ctx context.Context, value int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.opsKernelQueueDepth, value, attrs, "file system op kernel queue depth")
+}
+func (o *ocMetrics) OpsKernelQueueLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	recordOCLatencyMetric(ctx, o.opsKernelQueueLatency, value, attrs, "file system op kernel queue latency")
+}
+func (o *ocMetrics) OpsDispatchLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	recordOCLatencyMetric(ctx, o.opsDispatchLatency, value, attrs, "file system op dispatch latency")
+}
+
+func (o *ocMetrics) MetadataPrefetchEntryCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.metadataPrefetchEntryCount, inc, attrs, "metadata prefetch entry count")
+}
+
+func (o *ocMetrics) WriteQuotaExceededCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.writeQuotaExceededCount, inc, attrs, "write quota exceeded count")
+}
+
+func (o *ocMetrics) DirListingEvictionCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.dirListingEvictionCount, inc, attrs, "dir listing eviction count")
+}
+
+func (o *ocMetrics) BufferPoolGetCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.bufferPoolGetCount, inc, attrs, "buffer pool get count")
+}
+
+func (o *ocMetrics) BufferPoolInUseBytes(ctx context.Context, value int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.bufferPoolInUseBytes, value, attrs, "buffer pool in use bytes")
+}
 
 func (o *ocMetrics) FileCacheReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	recordOCMetric(ctx, o.fileCacheReadCount, inc, attrs, "file cache read count")
@@ -132,6 +316,15 @@ func (o *ocMetrics) FileCacheReadBytesCount(ctx context.Context, inc int64, attr
 func (o *ocMetrics) FileCacheReadLatency(ctx context.Context, value float64, attrs []MetricAttr) {
 	recordOCLatencyMetric(ctx, o.fileCacheReadLatency, value, attrs, "file cache read latency")
 }
+func (o *ocMetrics) FileCacheCorruptFileCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.fileCacheCorruptFileCount, inc, attrs, "file cache corrupt file count")
+}
+func (o *ocMetrics) FileCacheDiskErrorCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.fileCacheDiskErrorCount, inc, attrs, "file cache disk error count")
+}
+func (o *ocMetrics) FileCacheDegraded(ctx context.Context, value int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.fileCacheDegraded, value, attrs, "file cache degraded")
+}
 
 func recordOCMetric(ctx context.Context, m *stats.Int64Measure, inc int64, attrs []MetricAttr, metricStr string) {
 	if err := stats.RecordWithTags(
@@ -154,62 +347,210 @@ func recordOCLatencyMetric(ctx context.Context, m *stats.Float64Measure, inc flo
 }
 
 func NewOCMetrics() (MetricHandle, error) {
-	gcsReadBytesCount := stats.Int64("gcs/read_bytes_count", "The number of bytes read from GCS objects.", stats.UnitBytes)
+	gcsReadBytesCount := stats.Int64("gcs/read_bytes_count", "The number of bytes read from GCS objects along with the region serving them, if known.", stats.UnitBytes)
 	gcsReaderCount := stats.Int64("gcs/reader_count", "The number of GCS object readers opened or closed.", stats.UnitDimensionless)
 	gcsRequestCount := stats.Int64("gcs/request_count", "The number of GCS requests processed.", stats.UnitDimensionless)
 	gcsRequestLatency := stats.Float64("gcs/request_latency", "The latency of a GCS request.", stats.UnitMilliseconds)
 	gcsReadCount := stats.Int64("gcs/read_count", "Specifies the number of gcs reads made along with type - Sequential/Random", stats.UnitDimensionless)
 	gcsDownloadBytesCount := stats.Int64("gcs/download_bytes_count", "The cumulative number of bytes downloaded from GCS along with type - Sequential/Random", stats.UnitBytes)
+	gcsReadStreamQueuedCount := stats.Int64("gcs/read_stream_queued_count", "The number of GCS read streams which had to wait for other streams of the same object to be closed.", stats.UnitDimensionless)
+	gcsTokenRefreshLatency := stats.Float64("gcs/token_refresh_latency", "The latency of refreshing the token used to authenticate GCS requests.", stats.UnitMilliseconds)
+	gcsTokenRefreshFailureCount := stats.Int64("gcs/token_refresh_failure_count", "The number of failed refreshes of the token used to authenticate GCS requests.", stats.UnitDimensionless)
+	gcsReadChecksumMismatchCount := stats.Int64("gcs/read_checksum_mismatch_count", "The number of object reads whose CRC32C checksum didn't match the one of the object.", stats.UnitDimensionless)
+	gcsUploadBytesRetriedCount := stats.Int64("gcs/upload_bytes_retried_count", "The number of bytes of resumable upload chunks sent to GCS again after a failed attempt.", stats.UnitBytes)
+	gcsUploadSessionRestartCount := stats.Int64("gcs/upload_session_restart_count", "The number of resumable upload sessions lost or expired in GCS, whose uploads had to be started again.", stats.UnitDimensionless)
+	gcsUploadFinalizeLatency := stats.Float64("gcs/upload_finalize_latency", "The latency of finalizing an upload to GCS, i.e. of sending its last chunk and waiting for the object to be created.", stats.UnitMilliseconds)
+	gcsUploadBackpressureTimeUsec := stats.Int64("gcs/upload_backpressure_time", "The time writes spent blocked waiting for buffers being uploaded to GCS to be freed.", "us")
+	gcsInflightRequests := stats.Int64("gcs/inflight_requests", "The number of GCS requests in flight along with their class - list/stat/read/write.", stats.UnitDimensionless)
+	gcsCoalescedReadCount := stats.Int64("gcs/coalesced_read_count", "The number of small random reads along with whether they fetched a range from GCS or were merged into the range of a previous read - fetched/merged.", stats.UnitDimensionless)
+	gcsReadSchedulingDelay := stats.Float64("gcs/read_scheduling_delay", "The time the read streams waited for their turn among the file handles along with their class - interactive/bulk.", stats.UnitMilliseconds)
+	gcsOfflineRequestCount := stats.Int64("gcs/offline_request_count", "The number of GCS requests made while GCS is unreachable along with whether they were served from the stat cache or failed fast - served_from_cache/failed_fast.", stats.UnitDimensionless)
+	gcsWriteBytesCount := stats.Int64("gcs/write_bytes_count", "The number of bytes written to GCS objects.", stats.UnitBytes)
+	gcsClassAOpCount := stats.Int64("gcs/class_a_op_count", "The number of GCS requests billed as class A operations, e.g. the creations, the listings and the updates of the objects.", stats.UnitDimensionless)
+	gcsClassBOpCount := stats.Int64("gcs/class_b_op_count", "The number of GCS requests billed as class B operations, e.g. the reads and the stats of the objects.", stats.UnitDimensionless)
+	gcsEgressBytesCount := stats.Int64("gcs/egress_bytes_count", "The number of bytes read from GCS objects along with where they travel from the bucket - same_region/cross_region/internet/unknown.", stats.UnitBytes)
+	gcsRetrievalBytesCount := stats.Int64("gcs/retrieval_bytes_count", "The number of bytes read from GCS objects incurring retrieval fees along with the storage class of the bucket - NEARLINE/COLDLINE/ARCHIVE.", stats.UnitBytes)
+	gcsBucketLocationMismatchCount := stats.Int64("gcs/bucket_location_mismatch_count", "The number of mounted buckets which are not co-located with the VM along with reason - region_mismatch/multi_region", stats.UnitDimensionless)
 
 	opsCount := stats.Int64("fs/ops_count", "The number of ops processed by the file system.", stats.UnitDimensionless)
 	opsLatency := stats.Float64("fs/ops_latency", "The latency of a file system operation.", "us")
 	opsErrorCount := stats.Int64("fs/ops_error_count", "The number of errors generated by file system operation.", stats.UnitDimensionless)
+	opsKernelQueueDepth := stats.Int64("fs/kernel_queue_depth", "The number of file system ops queued in the kernel which gcsfuse hasn't started processing yet.", stats.UnitDimensionless)
+	opsKernelQueueLatency := stats.Float64("fs/kernel_queue_latency", "The estimated time file system ops spend queued in the kernel before gcsfuse starts processing them.", "us")
+	opsDispatchLatency := stats.Float64("fs/ops_dispatch_latency", "The time file system ops wait in gcsfuse for one of the max-concurrent-ops slots.", "us")
+	metadataPrefetchEntryCount := stats.Int64("fs/metadata_prefetch_entry_count", "The number of files and directories discovered by the metadata prefetch on mount along with how it ended - completed/capped/failed.", stats.UnitDimensionless)
+	writeQuotaExceededCount := stats.Int64("fs/write_quota_exceeded_count", "The number of writes and creations of objects failed with EDQUOT by the write quota of the mount along with the quota exceeded - bytes/objects.", stats.UnitDimensionless)
+	dirListingEvictionCount := stats.Int64("fs/dir_listing_eviction_count", "The number of times the entries of a directory cached by a handle were evicted to stay within dir-entries-max-size-mb.", stats.UnitDimensionless)
+	bufferPoolGetCount := stats.Int64("buffer_pool/get_count", "The number of buffers taken from the buffer pool of the read paths along with whether they were reused - true/false.", stats.UnitDimensionless)
+	bufferPoolInUseBytes := stats.Int64("buffer_pool/in_use_bytes", "The bytes of the buffers of the buffer pool of the read paths in use.", stats.UnitBytes)
 
 	fileCacheReadCount := stats.Int64("file_cache/read_count", "Specifies the number of read requests made via file cache along with type - Sequential/Random and cache hit - true/false", stats.UnitDimensionless)
 	fileCacheReadBytesCount := stats.Int64("file_cache/read_bytes_count", "The cumulative number of bytes read from file cache along with read type - Sequential/Random", stats.UnitBytes)
 	fileCacheReadLatency := stats.Float64("file_cache/read_latency", "Latency of read from file cache along with cache hit - true/false", "us")
+	fileCacheCorruptFileCount := stats.Int64("file_cache/corrupt_file_count", "The number of files evicted from file cache because a chunk didn't match its checksum along with the detector - read/scrub", stats.UnitDimensionless)
+	fileCacheDiskErrorCount := stats.Int64("file_cache/disk_error_count", "The number of failed or slow IOs on the file cache directory along with the error type - io_error/slow_io", stats.UnitDimensionless)
+	fileCacheDegraded := stats.Int64("file_cache/degraded", "1 once the file cache is bypassed because of the failed or slow IOs on its directory, 0 otherwise.", stats.UnitDimensionless)
 	// OpenCensus views (aggregated measures)
 	if err := view.Register(
 		&view.View{
 			Name:        "gcs/read_bytes_count",
 			Measure:     gcsReadBytesCount,
-			Description: "The cumulative number of bytes read from GCS objects.",
+			Description: "The cumulative number of bytes read from GCS objects along with the region serving them, if known.",
 			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(ReadRegion), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "gcs/reader_count",
 			Measure:     gcsReaderCount,
 			Description: "The cumulative number of GCS object readers opened or closed.",
 			Aggregation: view.Sum(),
-			TagKeys:     []tag.Key{tag.MustNewKey(IOMethod)},
+			TagKeys:     []tag.Key{tag.MustNewKey(IOMethod), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "gcs/request_count",
 			Measure:     gcsRequestCount,
 			Description: "The cumulative number of GCS requests processed.",
 			Aggregation: view.Sum(),
-			TagKeys:     []tag.Key{tag.MustNewKey(GCSMethod)},
+			TagKeys:     []tag.Key{tag.MustNewKey(GCSMethod), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "gcs/request_latencies",
 			Measure:     gcsRequestLatency,
 			Description: "The cumulative distribution of the GCS request latencies.",
 			Aggregation: ochttp.DefaultLatencyDistribution,
-			TagKeys:     []tag.Key{tag.MustNewKey(GCSMethod)},
+			TagKeys:     []tag.Key{tag.MustNewKey(GCSMethod), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "gcs/read_count",
 			Measure:     gcsReadCount,
 			Description: "Specifies the number of gcs reads made along with type - Sequential/Random",
 			Aggregation: view.Sum(),
-			TagKeys:     []tag.Key{tag.MustNewKey(ReadType)},
+			TagKeys:     []tag.Key{tag.MustNewKey(ReadType), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "gcs/download_bytes_count",
 			Measure:     gcsDownloadBytesCount,
 			Description: "The cumulative number of bytes downloaded from GCS along with type - Sequential/Random",
 			Aggregation: view.Sum(),
-			TagKeys:     []tag.Key{tag.MustNewKey(ReadType)},
+			TagKeys:     []tag.Key{tag.MustNewKey(ReadType), tag.MustNewKey(BucketName)},
+		},
+		&view.View{
+			Name:        "gcs/bucket_location_mismatch_count",
+			Measure:     gcsBucketLocationMismatchCount,
+			Description: "The number of mounted buckets which are not co-located with the VM along with reason - region_mismatch/multi_region",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(LocationMismatchReason)},
+		},
+		&view.View{
+			Name:        "gcs/read_stream_queued_count",
+			Measure:     gcsReadStreamQueuedCount,
+			Description: "The number of GCS read streams which had to wait for other streams of the same object to be closed.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/token_refresh_latencies",
+			Measure:     gcsTokenRefreshLatency,
+			Description: "The cumulative distribution of the latencies of refreshing the token used to authenticate GCS requests.",
+			Aggregation: ochttp.DefaultLatencyDistribution,
+		},
+		&view.View{
+			Name:        "gcs/token_refresh_failure_count",
+			Measure:     gcsTokenRefreshFailureCount,
+			Description: "The cumulative number of failed refreshes of the token used to authenticate GCS requests.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/read_checksum_mismatch_count",
+			Measure:     gcsReadChecksumMismatchCount,
+			Description: "The cumulative number of object reads whose CRC32C checksum didn't match the one of the object.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/upload_bytes_retried_count",
+			Measure:     gcsUploadBytesRetriedCount,
+			Description: "The cumulative number of bytes of resumable upload chunks sent to GCS again after a failed attempt.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/upload_session_restart_count",
+			Measure:     gcsUploadSessionRestartCount,
+			Description: "The cumulative number of resumable upload sessions lost or expired in GCS, whose uploads had to be started again.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/upload_finalize_latencies",
+			Measure:     gcsUploadFinalizeLatency,
+			Description: "The cumulative distribution of the latencies of finalizing uploads to GCS.",
+			Aggregation: ochttp.DefaultLatencyDistribution,
+		},
+		&view.View{
+			Name:        "gcs/upload_backpressure_time",
+			Measure:     gcsUploadBackpressureTimeUsec,
+			Description: "The cumulative time writes spent blocked waiting for buffers being uploaded to GCS to be freed.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "gcs/inflight_requests",
+			Measure:     gcsInflightRequests,
+			Description: "The number of GCS requests in flight along with their class - list/stat/read/write.",
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{tag.MustNewKey(RequestClass)},
+		},
+		&view.View{
+			Name:        "gcs/coalesced_read_count",
+			Measure:     gcsCoalescedReadCount,
+			Description: "The cumulative number of small random reads along with whether they fetched a range from GCS or were merged into the range of a previous read - fetched/merged.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(CoalesceResult)},
+		},
+		&view.View{
+			Name:        "gcs/read_scheduling_delays",
+			Measure:     gcsReadSchedulingDelay,
+			Description: "The cumulative distribution of the times the read streams waited for their turn among the file handles along with their class - interactive/bulk.",
+			Aggregation: ochttp.DefaultLatencyDistribution,
+			TagKeys:     []tag.Key{tag.MustNewKey(ReadClass)},
+		},
+		&view.View{
+			Name:        "gcs/offline_request_count",
+			Measure:     gcsOfflineRequestCount,
+			Description: "The cumulative number of GCS requests made while GCS is unreachable along with whether they were served from the stat cache or failed fast - served_from_cache/failed_fast.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(OfflineOutcome)},
+		},
+		&view.View{
+			Name:        "gcs/write_bytes_count",
+			Measure:     gcsWriteBytesCount,
+			Description: "The cumulative number of bytes written to GCS objects.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(BucketName)},
+		},
+		&view.View{
+			Name:        "gcs/class_a_op_count",
+			Measure:     gcsClassAOpCount,
+			Description: "The cumulative number of GCS requests billed as class A operations, e.g. the creations, the listings and the updates of the objects.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(BucketName)},
+		},
+		&view.View{
+			Name:        "gcs/class_b_op_count",
+			Measure:     gcsClassBOpCount,
+			Description: "The cumulative number of GCS requests billed as class B operations, e.g. the reads and the stats of the objects.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(BucketName)},
+		},
+		&view.View{
+			Name:        "gcs/egress_bytes_count",
+			Measure:     gcsEgressBytesCount,
+			Description: "The cumulative number of bytes read from GCS objects along with where they travel from the bucket - same_region/cross_region/internet/unknown.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(EgressLocality), tag.MustNewKey(BucketName)},
+		},
+		&view.View{
+			Name:        "gcs/retrieval_bytes_count",
+			Measure:     gcsRetrievalBytesCount,
+			Description: "The cumulative number of bytes read from GCS objects incurring retrieval fees along with the storage class of the bucket - NEARLINE/COLDLINE/ARCHIVE.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(StorageClass), tag.MustNewKey(BucketName)},
 		},
 		&view.View{
 			Name:        "fs/ops_count",
@@ -232,6 +573,58 @@ func NewOCMetrics() (MetricHandle, error) {
 			Aggregation: ochttp.DefaultLatencyDistribution,
 			TagKeys:     []tag.Key{tag.MustNewKey(FSOp)},
 		},
+		&view.View{
+			Name:        "fs/kernel_queue_depth",
+			Measure:     opsKernelQueueDepth,
+			Description: "The number of file system ops queued in the kernel which gcsfuse hasn't started processing yet.",
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Name:        "fs/kernel_queue_latency",
+			Measure:     opsKernelQueueLatency,
+			Description: "The cumulative distribution of the estimated time file system ops spend queued in the kernel.",
+			Aggregation: ochttp.DefaultLatencyDistribution,
+		},
+		&view.View{
+			Name:        "fs/ops_dispatch_latency",
+			Measure:     opsDispatchLatency,
+			Description: "The cumulative distribution of the time file system ops wait in gcsfuse for one of the max-concurrent-ops slots.",
+			Aggregation: ochttp.DefaultLatencyDistribution,
+			TagKeys:     []tag.Key{tag.MustNewKey(FSOp)},
+		},
+		&view.View{
+			Name:        "fs/metadata_prefetch_entry_count",
+			Measure:     metadataPrefetchEntryCount,
+			Description: "The cumulative number of files and directories discovered by the metadata prefetch on mount along with how it ended - completed/capped/failed.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(PrefetchResult)},
+		},
+		&view.View{
+			Name:        "fs/write_quota_exceeded_count",
+			Measure:     writeQuotaExceededCount,
+			Description: "The cumulative number of writes and creations of objects failed with EDQUOT by the write quota of the mount along with the quota exceeded - bytes/objects.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(QuotaType)},
+		},
+		&view.View{
+			Name:        "fs/dir_listing_eviction_count",
+			Measure:     dirListingEvictionCount,
+			Description: "The cumulative number of times the entries of a directory cached by a handle were evicted to stay within dir-entries-max-size-mb.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "buffer_pool/get_count",
+			Measure:     bufferPoolGetCount,
+			Description: "The cumulative number of buffers taken from the buffer pool of the read paths along with whether they were reused - true/false.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(PoolHit)},
+		},
+		&view.View{
+			Name:        "buffer_pool/in_use_bytes",
+			Measure:     bufferPoolInUseBytes,
+			Description: "The bytes of the buffers of the buffer pool of the read paths in use.",
+			Aggregation: view.LastValue(),
+		},
 		// File cache related metrics
 		&view.View{
 			Name:        "file_cache/read_count",
@@ -253,6 +646,26 @@ func NewOCMetrics() (MetricHandle, error) {
 			Description: "The cumulative distribution of the file cache read latencies along with cache hit - true/false",
 			Aggregation: ochttp.DefaultLatencyDistribution,
 			TagKeys:     []tag.Key{tag.MustNewKey(CacheHit)},
+		},
+		&view.View{
+			Name:        "file_cache/corrupt_file_count",
+			Measure:     fileCacheCorruptFileCount,
+			Description: "The cumulative number of files evicted from file cache because a chunk didn't match its checksum along with the detector - read/scrub",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(DetectedBy)},
+		},
+		&view.View{
+			Name:        "file_cache/disk_error_count",
+			Measure:     fileCacheDiskErrorCount,
+			Description: "The cumulative number of failed or slow IOs on the file cache directory along with the error type - io_error/slow_io",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(DiskErrorType)},
+		},
+		&view.View{
+			Name:        "file_cache/degraded",
+			Measure:     fileCacheDegraded,
+			Description: "1 once the file cache is bypassed because of the failed or slow IOs on its directory, 0 otherwise.",
+			Aggregation: view.LastValue(),
 		}); err != nil {
 		return nil, fmt.Errorf("failed to register OpenCensus metrics for GCS client library: %w", err)
 	}
@@ -264,12 +677,45 @@ func NewOCMetrics() (MetricHandle, error) {
 		gcsReadCount:          gcsReadCount,
 		gcsDownloadBytesCount: gcsDownloadBytesCount,
 
+		gcsBucketLocationMismatchCount: gcsBucketLocationMismatchCount,
+		gcsReadStreamQueuedCount:       gcsReadStreamQueuedCount,
+		gcsTokenRefreshLatency:         gcsTokenRefreshLatency,
+		gcsTokenRefreshFailureCount:    gcsTokenRefreshFailureCount,
+		gcsReadChecksumMismatchCount:   gcsReadChecksumMismatchCount,
+
+		gcsUploadBytesRetriedCount:    gcsUploadBytesRetriedCount,
+		gcsUploadSessionRestartCount:  gcsUploadSessionRestartCount,
+		gcsUploadFinalizeLatency:      gcsUploadFinalizeLatency,
+		gcsUploadBackpressureTimeUsec: gcsUploadBackpressureTimeUsec,
+		gcsInflightRequests:           gcsInflightRequests,
+		gcsCoalescedReadCount:         gcsCoalescedReadCount,
+		gcsReadSchedulingDelay:        gcsReadSchedulingDelay,
+		gcsOfflineRequestCount:        gcsOfflineRequestCount,
+		gcsWriteBytesCount:            gcsWriteBytesCount,
+		gcsClassAOpCount:              gcsClassAOpCount,
+		gcsClassBOpCount:              gcsClassBOpCount,
+		gcsEgressBytesCount:           gcsEgressBytesCount,
+		gcsRetrievalBytesCount:        gcsRetrievalBytesCount,
+
 		opsCount:      opsCount,
 		opsErrorCount: opsErrorCount,
 		opsLatency:    opsLatency,
 
-		fileCacheReadCount:      fileCacheReadCount,
-		fileCacheReadBytesCount: fileCacheReadBytesCount,
-		fileCacheReadLatency:    fileCacheReadLatency,
+		opsKernelQueueDepth:   opsKernelQueueDepth,
+		opsKernelQueueLatency: opsKernelQueueLatency,
+		opsDispatchLatency:    opsDispatchLatency,
+
+		metadataPrefetchEntryCount: metadataPrefetchEntryCount,
+		writeQuotaExceededCount:    writeQuotaExceededCount,
+		dirListingEvictionCount:    dirListingEvictionCount,
+		bufferPoolGetCount:         bufferPoolGetCount,
+		bufferPoolInUseBytes:       bufferPoolInUseBytes,
+
+		fileCacheReadCount:        fileCacheReadCount,
+		fileCacheReadBytesCount:   fileCacheReadBytesCount,
+		fileCacheReadLatency:      fileCacheReadLatency,
+		fileCacheCorruptFileCount: fileCacheCorruptFileCount,
+		fileCacheDiskErrorCount:   fileCacheDiskErrorCount,
+		fileCacheDegraded:         fileCacheDegraded,
 	}, nil
 }
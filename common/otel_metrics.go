@@ -34,6 +34,16 @@ type otelMetrics struct {
 	fsOpsErrorCount metric.Int64Counter
 	fsOpsLatency    metric.Float64Histogram
 
+	fsOpsKernelQueueDepth   metric.Int64Gauge
+	fsOpsKernelQueueLatency metric.Float64Histogram
+	fsOpsDispatchLatency    metric.Float64Histogram
+
+	metadataPrefetchEntryCount metric.Int64Counter
+	writeQuotaExceededCount    metric.Int64Counter
+	dirListingEvictionCount    metric.Int64Counter
+	bufferPoolGetCount         metric.Int64Counter
+	bufferPoolInUseBytes       metric.Int64Gauge
+
 	gcsReadCount          metric.Int64Counter
 	gcsReadBytesCount     metric.Int64Counter
 	gcsReaderCount        metric.Int64Counter
@@ -41,9 +51,32 @@ type otelMetrics struct {
 	gcsRequestLatency     metric.Float64Histogram
 	gcsDownloadBytesCount metric.Int64Counter
 
-	fileCacheReadCount      metric.Int64Counter
-	fileCacheReadBytesCount metric.Int64Counter
-	fileCacheReadLatency    metric.Float64Histogram
+	gcsBucketLocationMismatchCount metric.Int64Counter
+	gcsReadStreamQueuedCount       metric.Int64Counter
+	gcsTokenRefreshLatency         metric.Float64Histogram
+	gcsTokenRefreshFailureCount    metric.Int64Counter
+	gcsReadChecksumMismatchCount   metric.Int64Counter
+
+	gcsUploadBytesRetriedCount    metric.Int64Counter
+	gcsUploadSessionRestartCount  metric.Int64Counter
+	gcsUploadFinalizeLatency      metric.Float64Histogram
+	gcsUploadBackpressureTimeUsec metric.Int64Counter
+	gcsInflightRequests           metric.Int64Gauge
+	gcsCoalescedReadCount         metric.Int64Counter
+	gcsReadSchedulingDelay        metric.Float64Histogram
+	gcsOfflineRequestCount        metric.Int64Counter
+	gcsWriteBytesCount            metric.Int64Counter
+	gcsClassAOpCount              metric.Int64Counter
+	gcsClassBOpCount              metric.Int64Counter
+	gcsEgressBytesCount           metric.Int64Counter
+	gcsRetrievalBytesCount        metric.Int64Counter
+
+	fileCacheReadCount        metric.Int64Counter
+	fileCacheReadBytesCount   metric.Int64Counter
+	fileCacheReadLatency      metric.Float64Histogram
+	fileCacheCorruptFileCount metric.Int64Counter
+	fileCacheDiskErrorCount   metric.Int64Counter
+	fileCacheDegraded         metric.Int64Gauge
 }
 
 func (o *otelMetrics) GCSReadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
@@ -70,6 +103,78 @@ func (o *otelMetrics) GCSDownloadBytesCount(ctx context.Context, inc int64, attr
 	o.gcsDownloadBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
 
+func (o *otelMetrics) GCSBucketLocationMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsBucketLocationMismatchCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSReadStreamQueuedCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsReadStreamQueuedCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSTokenRefreshLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	o.gcsTokenRefreshLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSTokenRefreshFailureCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsTokenRefreshFailureCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSReadChecksumMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsReadChecksumMismatchCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSUploadBytesRetriedCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsUploadBytesRetriedCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSUploadSessionRestartCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsUploadSessionRestartCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSUploadFinalizeLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	o.gcsUploadFinalizeLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSUploadBackpressureTime(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsUploadBackpressureTimeUsec.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSInflightRequests(ctx context.Context, value int64, attrs []MetricAttr) {
+	o.gcsInflightRequests.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSCoalescedReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsCoalescedReadCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSReadSchedulingDelay(ctx context.Context, value float64, attrs []MetricAttr) {
+	o.gcsReadSchedulingDelay.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSOfflineRequestCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsOfflineRequestCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSWriteBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsWriteBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSClassAOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsClassAOpCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSClassBOpCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsClassBOpCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSEgressBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsEgressBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSRetrievalBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsRetrievalBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
 func (o *otelMetrics) OpsCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	o.fsOpsCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
@@ -82,6 +187,38 @@ func (o *otelMetrics) OpsErrorCount(ctx context.Context, inc int64, attrs []Metr
 	o.fsOpsErrorCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
 
+func (o *otelMetrics) OpsKernelQueueDepth(ctx context.Context, value int64, attrs []MetricAttr) {
+	o.fsOpsKernelQueueDepth.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) OpsKernelQueueLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	o.fsOpsKernelQueueLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) OpsDispatchLatency(ctx context.Context, value float64, attrs []MetricAttr) {
+	o.fsOpsDispatchLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
+func (o *otelMetrics) MetadataPrefetchEntryCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.metadataPrefetchEntryCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) WriteQuotaExceededCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.writeQuotaExceededCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) DirListingEvictionCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.dirListingEvictionCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) BufferPoolGetCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.bufferPoolGetCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) BufferPoolInUseBytes(ctx context.Context, value int64, attrs []MetricAttr) {
+	o.bufferPoolInUseBytes.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
 func (o *otelMetrics) FileCacheReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	o.fileCacheReadCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
@@ -94,20 +231,99 @@ func (o *otelMetrics) FileCacheReadLatency(ctx context.Context, value float64, a
 	o.fileCacheReadLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
 }
 
+func (o *otelMetrics) FileCacheCorruptFileCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.fileCacheCorruptFileCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) FileCacheDiskErrorCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.fileCacheDiskErrorCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) FileCacheDegraded(ctx context.Context, value int64, attrs []MetricAttr) {
+	o.fileCacheDegraded.Record(ctx, value, attrsToRecordOption(attrs)...)
+}
+
 func NewOTelMetrics() (MetricHandle, error) {
 	fsOpsCount, err1 := fsOpsMeter.Int64Counter("fs/ops_count", metric.WithDescription("The number of ops processed by the file system."))
 	fsOpsLatency, err2 := fsOpsMeter.Float64Histogram("fs/ops_latency", metric.WithDescription("The latency of a file system operation."), metric.WithUnit("us"),
 		defaultLatencyDistribution)
 	fsOpsErrorCount, err3 := fsOpsMeter.Int64Counter("fs/ops_error_count", metric.WithDescription("The number of errors generated by file system operation."))
+	fsOpsKernelQueueDepth, err15 := fsOpsMeter.Int64Gauge("fs/kernel_queue_depth",
+		metric.WithDescription("The number of file system ops queued in the kernel which gcsfuse hasn't started processing yet."))
+	fsOpsKernelQueueLatency, err16 := fsOpsMeter.Float64Histogram("fs/kernel_queue_latency",
+		metric.WithDescription("The estimated time file system ops spend queued in the kernel before gcsfuse starts processing them."),
+		metric.WithUnit("us"),
+		defaultLatencyDistribution)
+	fsOpsDispatchLatency, err29 := fsOpsMeter.Float64Histogram("fs/ops_dispatch_latency",
+		metric.WithDescription("The time file system ops wait in gcsfuse for one of the max-concurrent-ops slots."),
+		metric.WithUnit("us"),
+		defaultLatencyDistribution)
+	metadataPrefetchEntryCount, err30 := fsOpsMeter.Int64Counter("fs/metadata_prefetch_entry_count",
+		metric.WithDescription("The number of files and directories discovered by the metadata prefetch on mount along with how it ended - completed/capped/failed."))
+	writeQuotaExceededCount, err31 := fsOpsMeter.Int64Counter("fs/write_quota_exceeded_count",
+		metric.WithDescription("The number of writes and creations of objects failed with EDQUOT by the write quota of the mount along with the quota exceeded - bytes/objects."))
+	dirListingEvictionCount, err32 := fsOpsMeter.Int64Counter("fs/dir_listing_eviction_count",
+		metric.WithDescription("The number of times the entries of a directory cached by a handle were evicted to stay within dir-entries-max-size-mb."))
+	bufferPoolGetCount, err40 := fsOpsMeter.Int64Counter("buffer_pool/get_count",
+		metric.WithDescription("The number of buffers taken from the buffer pool of the read paths along with whether they were reused - true/false."))
+	bufferPoolInUseBytes, err41 := fsOpsMeter.Int64Gauge("buffer_pool/in_use_bytes",
+		metric.WithDescription("The bytes of the buffers of the buffer pool of the read paths in use."),
+		metric.WithUnit("By"))
 
 	gcsReadCount, err4 := gcsMeter.Int64Counter("gcs/read_count", metric.WithDescription("Specifies the number of gcs reads made along with type - Sequential/Random"))
 	gcsDownloadBytesCount, err5 := gcsMeter.Int64Counter("gcs/download_bytes_count",
 		metric.WithDescription("The cumulative number of bytes downloaded from GCS along with type - Sequential/Random"),
 		metric.WithUnit("By"))
-	gcsReadBytesCount, err6 := gcsMeter.Int64Counter("gcs/read_bytes_count", metric.WithDescription("The number of bytes read from GCS objects."), metric.WithUnit("By"))
+	gcsReadBytesCount, err6 := gcsMeter.Int64Counter("gcs/read_bytes_count", metric.WithDescription("The number of bytes read from GCS objects along with the region serving them, if known."), metric.WithUnit("By"))
 	gcsReaderCount, err7 := gcsMeter.Int64Counter("gcs/reader_count", metric.WithDescription("The number of GCS object readers opened or closed."))
 	gcsRequestCount, err8 := gcsMeter.Int64Counter("gcs/request_count", metric.WithDescription("The cumulative number of GCS requests processed."))
 	gcsRequestLatency, err9 := gcsMeter.Float64Histogram("gcs/request_latency", metric.WithDescription("The latency of a GCS request."), metric.WithUnit("ms"))
+	gcsBucketLocationMismatchCount, err13 := gcsMeter.Int64Counter("gcs/bucket_location_mismatch_count",
+		metric.WithDescription("The number of mounted buckets which are not co-located with the VM along with reason - region_mismatch/multi_region"))
+	gcsReadStreamQueuedCount, err14 := gcsMeter.Int64Counter("gcs/read_stream_queued_count",
+		metric.WithDescription("The number of GCS read streams which had to wait for other streams of the same object to be closed."))
+	gcsTokenRefreshLatency, err17 := gcsMeter.Float64Histogram("gcs/token_refresh_latency",
+		metric.WithDescription("The latency of refreshing the token used to authenticate GCS requests."),
+		metric.WithUnit("ms"))
+	gcsTokenRefreshFailureCount, err18 := gcsMeter.Int64Counter("gcs/token_refresh_failure_count",
+		metric.WithDescription("The number of failed refreshes of the token used to authenticate GCS requests."))
+	gcsReadChecksumMismatchCount, err19 := gcsMeter.Int64Counter("gcs/read_checksum_mismatch_count",
+		metric.WithDescription("The number of object reads whose CRC32C checksum didn't match the one of the object."))
+	gcsUploadBytesRetriedCount, err21 := gcsMeter.Int64Counter("gcs/upload_bytes_retried_count",
+		metric.WithDescription("The cumulative number of bytes of resumable upload chunks sent to GCS again after a failed attempt."),
+		metric.WithUnit("By"))
+	gcsUploadSessionRestartCount, err22 := gcsMeter.Int64Counter("gcs/upload_session_restart_count",
+		metric.WithDescription("The number of resumable upload sessions lost or expired in GCS, whose uploads had to be started again."))
+	gcsUploadFinalizeLatency, err23 := gcsMeter.Float64Histogram("gcs/upload_finalize_latency",
+		metric.WithDescription("The latency of finalizing an upload to GCS, i.e. of sending its last chunk and waiting for the object to be created."),
+		metric.WithUnit("ms"),
+		defaultLatencyDistribution)
+	gcsUploadBackpressureTimeUsec, err24 := gcsMeter.Int64Counter("gcs/upload_backpressure_time",
+		metric.WithDescription("The cumulative time writes spent blocked waiting for buffers being uploaded to GCS to be freed."),
+		metric.WithUnit("us"))
+	gcsInflightRequests, err25 := gcsMeter.Int64Gauge("gcs/inflight_requests",
+		metric.WithDescription("The number of GCS requests in flight along with their class - list/stat/read/write, with max-concurrent-*-requests."))
+	gcsCoalescedReadCount, err28 := gcsMeter.Int64Counter("gcs/coalesced_read_count",
+		metric.WithDescription("The number of small random reads, with read-coalesce-window-kb, along with whether they fetched a range from GCS or were merged into the range fetched by a previous read - fetched/merged."))
+	gcsReadSchedulingDelay, err33 := gcsMeter.Float64Histogram("gcs/read_scheduling_delay",
+		metric.WithDescription("The time the read streams waited for their turn among the file handles, with read-min-share-percent, along with their class - interactive/bulk."),
+		metric.WithUnit("ms"),
+		defaultLatencyDistribution)
+	gcsOfflineRequestCount, err34 := gcsMeter.Int64Counter("gcs/offline_request_count",
+		metric.WithDescription("The number of GCS requests made while GCS is unreachable, with offline-mode=serve-cache, along with whether they were served from the stat cache or failed fast - served_from_cache/failed_fast."))
+	gcsWriteBytesCount, err35 := gcsMeter.Int64Counter("gcs/write_bytes_count",
+		metric.WithDescription("The number of bytes written to GCS objects."),
+		metric.WithUnit("By"))
+	gcsClassAOpCount, err36 := gcsMeter.Int64Counter("gcs/class_a_op_count",
+		metric.WithDescription("The number of GCS requests billed as class A operations, e.g. the creations, the listings and the updates of the objects."))
+	gcsClassBOpCount, err37 := gcsMeter.Int64Counter("gcs/class_b_op_count",
+		metric.WithDescription("The number of GCS requests billed as class B operations, e.g. the reads and the stats of the objects."))
+	gcsEgressBytesCount, err38 := gcsMeter.Int64Counter("gcs/egress_bytes_count",
+		metric.WithDescription("The number of bytes read from GCS objects along with where they travel from the bucket - same_region/cross_region/internet/unknown."),
+		metric.WithUnit("By"))
+	gcsRetrievalBytesCount, err39 := gcsMeter.Int64Counter("gcs/retrieval_bytes_count",
+		metric.WithDescription("The number of bytes read from GCS objects incurring retrieval fees along with the storage class of the bucket - NEARLINE/COLDLINE/ARCHIVE."),
+		metric.WithUnit("By"))
 
 	fileCacheReadCount, err10 := fileCacheMeter.Int64Counter("file_cache/read_count",
 		metric.WithDescription("Specifies the number of read requests made via file cache along with type - Sequential/Random and cache hit - true/false"))
@@ -118,22 +334,57 @@ func NewOTelMetrics() (MetricHandle, error) {
 		metric.WithDescription("Latency of read from file cache along with cache hit - true/false"),
 		metric.WithUnit("us"),
 		defaultLatencyDistribution)
+	fileCacheCorruptFileCount, err20 := fileCacheMeter.Int64Counter("file_cache/corrupt_file_count",
+		metric.WithDescription("The number of files evicted from file cache because a chunk didn't match its checksum along with the detector - read/scrub"))
+	fileCacheDiskErrorCount, err26 := fileCacheMeter.Int64Counter("file_cache/disk_error_count",
+		metric.WithDescription("The number of failed or slow IOs on the file cache directory along with the error type - io_error/slow_io"))
+	fileCacheDegraded, err27 := fileCacheMeter.Int64Gauge("file_cache/degraded",
+		metric.WithDescription("1 once the file cache is bypassed because of the failed or slow IOs on its directory, 0 otherwise."))
 
-	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8, err9, err10, err11, err12); err != nil {
+	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8, err9, err10, err11, err12, err13, err14, err15, err16, err17, err18, err19, err20, err21, err22, err23, err24, err25, err26, err27, err28, err29, err30, err31, err32, err33, err34, err35, err36, err37, err38, err39, err40, err41); err != nil {
 		return nil, err
 	}
 	return &otelMetrics{
-		fsOpsCount:              fsOpsCount,
-		fsOpsErrorCount:         fsOpsErrorCount,
-		fsOpsLatency:            fsOpsLatency,
-		gcsReadCount:            gcsReadCount,
-		gcsReadBytesCount:       gcsReadBytesCount,
-		gcsReaderCount:          gcsReaderCount,
-		gcsRequestCount:         gcsRequestCount,
-		gcsRequestLatency:       gcsRequestLatency,
-		gcsDownloadBytesCount:   gcsDownloadBytesCount,
-		fileCacheReadCount:      fileCacheReadCount,
-		fileCacheReadBytesCount: fileCacheReadBytesCount,
-		fileCacheReadLatency:    fileCacheReadLatency,
+		fsOpsCount:                     fsOpsCount,
+		fsOpsErrorCount:                fsOpsErrorCount,
+		fsOpsLatency:                   fsOpsLatency,
+		fsOpsKernelQueueDepth:          fsOpsKernelQueueDepth,
+		fsOpsKernelQueueLatency:        fsOpsKernelQueueLatency,
+		fsOpsDispatchLatency:           fsOpsDispatchLatency,
+		metadataPrefetchEntryCount:     metadataPrefetchEntryCount,
+		writeQuotaExceededCount:        writeQuotaExceededCount,
+		dirListingEvictionCount:        dirListingEvictionCount,
+		bufferPoolGetCount:             bufferPoolGetCount,
+		bufferPoolInUseBytes:           bufferPoolInUseBytes,
+		gcsReadCount:                   gcsReadCount,
+		gcsReadBytesCount:              gcsReadBytesCount,
+		gcsReaderCount:                 gcsReaderCount,
+		gcsRequestCount:                gcsRequestCount,
+		gcsRequestLatency:              gcsRequestLatency,
+		gcsDownloadBytesCount:          gcsDownloadBytesCount,
+		gcsBucketLocationMismatchCount: gcsBucketLocationMismatchCount,
+		gcsReadStreamQueuedCount:       gcsReadStreamQueuedCount,
+		gcsTokenRefreshLatency:         gcsTokenRefreshLatency,
+		gcsTokenRefreshFailureCount:    gcsTokenRefreshFailureCount,
+		gcsReadChecksumMismatchCount:   gcsReadChecksumMismatchCount,
+		gcsUploadBytesRetriedCount:     gcsUploadBytesRetriedCount,
+		gcsUploadSessionRestartCount:   gcsUploadSessionRestartCount,
+		gcsUploadFinalizeLatency:       gcsUploadFinalizeLatency,
+		gcsUploadBackpressureTimeUsec:  gcsUploadBackpressureTimeUsec,
+		gcsInflightRequests:            gcsInflightRequests,
+		gcsCoalescedReadCount:          gcsCoalescedReadCount,
+		gcsReadSchedulingDelay:         gcsReadSchedulingDelay,
+		gcsOfflineRequestCount:         gcsOfflineRequestCount,
+		gcsWriteBytesCount:             gcsWriteBytesCount,
+		gcsClassAOpCount:               gcsClassAOpCount,
+		gcsClassBOpCount:               gcsClassBOpCount,
+		gcsEgressBytesCount:            gcsEgressBytesCount,
+		gcsRetrievalBytesCount:         gcsRetrievalBytesCount,
+		fileCacheReadCount:             fileCacheReadCount,
+		fileCacheReadBytesCount:        fileCacheReadBytesCount,
+		fileCacheReadLatency:           fileCacheReadLatency,
+		fileCacheCorruptFileCount:      fileCacheCorruptFileCount,
+		fileCacheDiskErrorCount:        fileCacheDiskErrorCount,
+		fileCacheDegraded:              fileCacheDegraded,
 	}, nil
 }
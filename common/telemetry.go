@@ -58,18 +58,47 @@ type GCSMetricHandle interface {
 	GCSRequestLatency(ctx context.Context, value float64, attrs []MetricAttr)
 	GCSReadCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	GCSDownloadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSBucketLocationMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSReadStreamQueuedCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSTokenRefreshLatency(ctx context.Context, value float64, attrs []MetricAttr)
+	GCSTokenRefreshFailureCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSReadChecksumMismatchCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSUploadBytesRetriedCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSUploadSessionRestartCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSUploadFinalizeLatency(ctx context.Context, value float64, attrs []MetricAttr)
+	GCSUploadBackpressureTime(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSInflightRequests(ctx context.Context, value int64, attrs []MetricAttr)
+	GCSCoalescedReadCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSReadSchedulingDelay(ctx context.Context, value float64, attrs []MetricAttr)
+	GCSOfflineRequestCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSWriteBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSClassAOpCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSClassBOpCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSEgressBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSRetrievalBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
 }
 
 type OpsMetricHandle interface {
 	OpsCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	OpsLatency(ctx context.Context, value float64, attrs []MetricAttr)
 	OpsErrorCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	OpsKernelQueueDepth(ctx context.Context, value int64, attrs []MetricAttr)
+	OpsKernelQueueLatency(ctx context.Context, value float64, attrs []MetricAttr)
+	OpsDispatchLatency(ctx context.Context, value float64, attrs []MetricAttr)
+	MetadataPrefetchEntryCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	WriteQuotaExceededCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	DirListingEvictionCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	BufferPoolGetCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	BufferPoolInUseBytes(ctx context.Context, value int64, attrs []MetricAttr)
 }
 
 type FileCacheMetricHandle interface {
 	FileCacheReadCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	FileCacheReadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	FileCacheReadLatency(ctx context.Context, value float64, attrs []MetricAttr)
+	FileCacheCorruptFileCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	FileCacheDiskErrorCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	FileCacheDegraded(ctx context.Context, value int64, attrs []MetricAttr)
 }
 type MetricHandle interface {
 	GCSMetricHandle
@@ -17,12 +17,22 @@ package common
 import (
 	"fmt"
 	"runtime"
+	"runtime/debug"
 )
 
 // Set with `-ldflags -X github.com/googlecloudplatform/gcsfuse/v2/common.gcsfuseVersion=1.2.3`
 // by tools/build_gcsfuse. If not defined, we use "unknown" in getVersion.
 var gcsfuseVersion string
 
+// The provenance of the build, set with -ldflags -X like gcsfuseVersion by
+// tools/build_gcsfuse. If not defined, the commit and the build time are taken
+// from the version control information embedded by go build, if any.
+var (
+	gcsfuseCommit    string
+	gcsfuseBuildTime string
+	gcsfuseBuilder   string
+)
+
 // GetVersion returns the version of the GCSFuse binary
 func GetVersion() string {
 	v := gcsfuseVersion
@@ -32,3 +42,42 @@ func GetVersion() string {
 
 	return fmt.Sprintf("%s (Go version %s)", v, runtime.Version())
 }
+
+// BuildInfo describes which build of gcsfuse is running, so that fleets can
+// audit it. The unknown fields are "unknown".
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Builder   string `json:"builder"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetBuildInfo returns the provenance of the GCSFuse binary.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   gcsfuseVersion,
+		Commit:    gcsfuseCommit,
+		BuildTime: gcsfuseBuildTime,
+		Builder:   gcsfuseBuilder,
+		GoVersion: runtime.Version(),
+	}
+	if goInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range goInfo.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				// The time of the commit, the closest to the build time known.
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	for _, field := range []*string{&info.Version, &info.Commit, &info.BuildTime, &info.Builder} {
+		if *field == "" {
+			*field = "unknown"
+		}
+	}
+	return info
+}
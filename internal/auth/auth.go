@@ -16,6 +16,7 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -26,6 +27,13 @@ import (
 
 const universeDomainDefault = "googleapis.com"
 
+// The types of the credential configuration files for workload identity
+// federation.
+const (
+	externalAccountCredentials               = "external_account"
+	externalAccountAuthorizedUserCredentials = "external_account_authorized_user"
+)
+
 func getUniverseDomain(ctx context.Context, contents []byte, scope string) (string, error) {
 	creds, err := google.CredentialsFromJSON(ctx, contents, scope)
 	if err != nil {
@@ -81,23 +89,80 @@ func newTokenSourceFromPath(ctx context.Context, path string, scope string) (oau
 	return ts, err
 }
 
+// Create token source from the credential configuration file at the supplied
+// path, used for workload identity federation.
+func newTokenSourceFromCredentialConfig(ctx context.Context, path string, scope string) (oauth2.TokenSource, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("ReadFile(%q): %w", path, err)
+		return nil, err
+	}
+
+	// Service account keys are rejected, since they aren't handled the same
+	// way as with key-file.
+	var f struct {
+		Type string `json:"type"`
+	}
+	if err = json.Unmarshal(contents, &f); err != nil {
+		err = fmt.Errorf("Unmarshal(%q): %w", path, err)
+		return nil, err
+	}
+	if f.Type != externalAccountCredentials && f.Type != externalAccountAuthorizedUserCredentials {
+		err = fmt.Errorf("%q has credentials of type %q, want %q or %q", path, f.Type, externalAccountCredentials, externalAccountAuthorizedUserCredentials)
+		return nil, err
+	}
+
+	creds, err := google.CredentialsFromJSON(ctx, contents, scope)
+	if err != nil {
+		err = fmt.Errorf("CredentialsFromJSON(): %w", err)
+		return nil, err
+	}
+	return creds.TokenSource, nil
+}
+
+// tokenScope returns the OAuth scope of the tokens used to access GCS. Tokens
+// of read-only mounts can't be used to modify buckets, as a defense against
+// bugs.
+func tokenScope(readOnly bool) string {
+	if readOnly {
+		return storagev1.DevstorageReadOnlyScope
+	}
+	return storagev1.DevstorageFullControlScope
+}
+
 // GetTokenSource generates the token-source for GCS endpoint by following oauth2.0 authentication
 // for key-file and default-credential flow.
 // It also supports generating the self-signed JWT tokenSource for key-file authentication which can be
-// used by custom-endpoint(e.g. TPC).
+// used by custom-endpoint(e.g. TPC), and getting tokens with workload identity federation or from an
+// external command. If impersonateServiceAccount is set, these credentials are only used to impersonate
+// the service account, or the last one of its comma-separated delegation chain.
 func GetTokenSource(
 	ctx context.Context,
 	keyFile string,
+	credentialConfigFile string,
+	externalCredentialCommand string,
 	tokenUrl string,
 	reuseTokenFromUrl bool,
+	impersonateServiceAccount string,
+	readOnly bool,
 ) (tokenSrc oauth2.TokenSource, err error) {
 	// Create the oauth2 token source.
-	const scope = storagev1.DevstorageFullControlScope
+	scope := tokenScope(readOnly)
+	if impersonateServiceAccount != "" {
+		// The credentials are only used to call the IAM Credentials API.
+		scope = cloudPlatformScope
+	}
 	var method string
 
 	if keyFile != "" {
 		tokenSrc, err = newTokenSourceFromPath(ctx, keyFile, scope)
 		method = "newTokenSourceFromPath"
+	} else if credentialConfigFile != "" {
+		tokenSrc, err = newTokenSourceFromCredentialConfig(ctx, credentialConfigFile, scope)
+		method = "newTokenSourceFromCredentialConfig"
+	} else if externalCredentialCommand != "" {
+		tokenSrc = newCommandTokenSource(ctx, externalCredentialCommand)
+		method = "newCommandTokenSource"
 	} else if tokenUrl != "" {
 		tokenSrc, err = newProxyTokenSource(ctx, tokenUrl, reuseTokenFromUrl)
 		method = "newProxyTokenSource"
@@ -106,6 +171,11 @@ func GetTokenSource(
 		method = "DefaultTokenSource"
 	}
 
+	if err == nil && impersonateServiceAccount != "" {
+		tokenSrc, err = newImpersonatedTokenSource(ctx, tokenSrc, impersonateServiceAccount, tokenScope(readOnly))
+		method = "newImpersonatedTokenSource"
+	}
+
 	if err != nil {
 		err = fmt.Errorf("%s: %w", method, err)
 		return
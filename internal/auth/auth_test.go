@@ -71,3 +71,21 @@ func (t *AuthTest) TestGetUniverseDomainForEmptyCreds() {
 	assert.Error(t.T(), err)
 	assert.Equal(t.T(), "CredentialsFromJSON(): unexpected end of JSON input", err.Error())
 }
+
+func (t *AuthTest) TestTokenScope() {
+	assert.Equal(t.T(), storagev1.DevstorageFullControlScope, tokenScope(false))
+	assert.Equal(t.T(), storagev1.DevstorageReadOnlyScope, tokenScope(true))
+}
+
+func (t *AuthTest) TestNewTokenSourceFromCredentialConfig() {
+	ts, err := newTokenSourceFromCredentialConfig(context.Background(), "testdata/external_account_creds.json", storagev1.DevstorageFullControlScope)
+
+	assert.NoError(t.T(), err)
+	assert.NotNil(t.T(), ts)
+}
+
+func (t *AuthTest) TestNewTokenSourceFromCredentialConfigWithServiceAccountKey() {
+	_, err := newTokenSourceFromCredentialConfig(context.Background(), "testdata/google_creds.json", storagev1.DevstorageFullControlScope)
+
+	assert.ErrorContains(t.T(), err, `credentials of type "service_account"`)
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// commandTimeout is how long the external credential command is given to
+// print a token.
+const commandTimeout = time.Minute
+
+// newCommandTokenSource returns a TokenSource that runs the given command
+// with the shell to get an access token, and runs it again once the token
+// expires.
+func newCommandTokenSource(ctx context.Context, command string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, commandTokenSource{
+		ctx:     ctx,
+		command: command,
+	})
+}
+
+type commandTokenSource struct {
+	ctx     context.Context
+	command string
+}
+
+func (ts commandTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(ts.ctx, commandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", ts.command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("commandTokenSource cannot run %q: %w: %s", ts.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseCommandToken(stdout.Bytes(), time.Now())
+}
+
+// parseCommandToken parses the token printed by the external credential
+// command. The token must expire, or it would never be refreshed.
+func parseCommandToken(output []byte, now time.Time) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(output, token); err != nil {
+		return nil, fmt.Errorf("commandTokenSource cannot decode output: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("commandTokenSource output has no access_token")
+	}
+	if token.Expiry.IsZero() {
+		if token.ExpiresIn <= 0 {
+			return nil, fmt.Errorf("commandTokenSource output has neither expires_in nor expiry")
+		}
+		token.Expiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommandToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		output         string
+		expectedExpiry time.Time
+		wantErr        bool
+	}{
+		{
+			name:           "expires_in",
+			output:         `{"access_token": "token", "expires_in": 3600}`,
+			expectedExpiry: now.Add(time.Hour),
+		},
+		{
+			name:           "expiry",
+			output:         `{"access_token": "token", "expiry": "2024-01-01T00:30:00Z"}`,
+			expectedExpiry: now.Add(30 * time.Minute),
+		},
+		{
+			name:    "no_expiry",
+			output:  `{"access_token": "token"}`,
+			wantErr: true,
+		},
+		{
+			name:    "no_access_token",
+			output:  `{"expires_in": 3600}`,
+			wantErr: true,
+		},
+		{
+			name:    "not_json",
+			output:  "token",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := parseCommandToken([]byte(tc.output), now)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "token", token.AccessToken)
+			assert.True(t, tc.expectedExpiry.Equal(token.Expiry))
+		})
+	}
+}
+
+func TestCommandTokenSource(t *testing.T) {
+	ts := newCommandTokenSource(context.Background(), `echo '{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}'`)
+
+	token, err := ts.Token()
+
+	require.NoError(t, err)
+	assert.Equal(t, "token", token.AccessToken)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.True(t, token.Valid())
+}
+
+func TestCommandTokenSourceWithFailingCommand(t *testing.T) {
+	ts := newCommandTokenSource(context.Background(), "echo 'no credentials' >&2; exit 1")
+
+	_, err := ts.Token()
+
+	assert.ErrorContains(t, err, "no credentials")
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// cloudPlatformScope is the OAuth scope required to impersonate service
+// accounts with the IAM Credentials API.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// impersonationConfig returns the config to impersonate the last service
+// account of the comma-separated delegation chain, like gcloud's
+// --impersonate-service-account.
+func impersonationConfig(chain string, scope string) impersonate.CredentialsConfig {
+	accounts := strings.Split(chain, ",")
+	for i := range accounts {
+		accounts[i] = strings.TrimSpace(accounts[i])
+	}
+
+	return impersonate.CredentialsConfig{
+		TargetPrincipal: accounts[len(accounts)-1],
+		Delegates:       accounts[:len(accounts)-1],
+		Scopes:          []string{scope},
+	}
+}
+
+// newImpersonatedTokenSource returns a TokenSource of tokens of the service
+// account impersonated with the tokens of base.
+func newImpersonatedTokenSource(ctx context.Context, base oauth2.TokenSource, chain string, scope string) (oauth2.TokenSource, error) {
+	return impersonate.CredentialsTokenSource(ctx, impersonationConfig(chain, scope), option.WithTokenSource(base))
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	storagev1 "google.golang.org/api/storage/v1"
+)
+
+func TestImpersonationConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		chain             string
+		expectedTarget    string
+		expectedDelegates []string
+	}{
+		{
+			name:              "single_account",
+			chain:             "target@project.iam.gserviceaccount.com",
+			expectedTarget:    "target@project.iam.gserviceaccount.com",
+			expectedDelegates: []string{},
+		},
+		{
+			name:              "delegation_chain",
+			chain:             "a@project.iam.gserviceaccount.com, b@project.iam.gserviceaccount.com,target@project.iam.gserviceaccount.com",
+			expectedTarget:    "target@project.iam.gserviceaccount.com",
+			expectedDelegates: []string{"a@project.iam.gserviceaccount.com", "b@project.iam.gserviceaccount.com"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := impersonationConfig(tc.chain, storagev1.DevstorageReadOnlyScope)
+
+			assert.Equal(t, tc.expectedTarget, config.TargetPrincipal)
+			assert.Equal(t, tc.expectedDelegates, config.Delegates)
+			assert.Equal(t, []string{storagev1.DevstorageReadOnlyScope}, config.Scopes)
+		})
+	}
+}
+
+func TestNewImpersonatedTokenSource(t *testing.T) {
+	base := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+	ts, err := newImpersonatedTokenSource(context.Background(), base, "target@project.iam.gserviceaccount.com", storagev1.DevstorageFullControlScope)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, ts)
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// How long before they expire tokens are refreshed in the background.
+	tokenRefreshBefore = 5 * time.Minute
+
+	// How long to wait before retrying a failed background refresh.
+	tokenRefreshRetryInterval = 10 * time.Second
+)
+
+// RefreshingTokenSource is a TokenSource refreshing its token in the
+// background before it expires, so that requests don't wait for refreshes,
+// and on demand when GCS rejects the token. It is safe for concurrent use.
+type RefreshingTokenSource struct {
+	metricHandle  common.MetricHandle
+	refreshBefore time.Duration
+	retryInterval time.Duration
+
+	mu sync.Mutex
+
+	// The source of the tokens. It is replaced by an empty cache for every
+	// refresh, so that each refresh fetches a new token.
+	//
+	// GUARDED_BY(mu)
+	source oauth2.TokenSource
+
+	// The last token, nil until the first refresh.
+	//
+	// GUARDED_BY(mu)
+	token *oauth2.Token
+
+	// When the last refresh failed, if it did.
+	//
+	// GUARDED_BY(mu)
+	failedAt time.Time
+
+	// The timer of the next background refresh, if any.
+	//
+	// GUARDED_BY(mu)
+	timer *time.Timer
+}
+
+// NewRefreshingTokenSource returns a RefreshingTokenSource of the tokens of
+// base. The first token is fetched by the first call to Token.
+func NewRefreshingTokenSource(base oauth2.TokenSource, metricHandle common.MetricHandle) *RefreshingTokenSource {
+	return newRefreshingTokenSource(base, metricHandle, tokenRefreshBefore, tokenRefreshRetryInterval)
+}
+
+func newRefreshingTokenSource(base oauth2.TokenSource, metricHandle common.MetricHandle, refreshBefore, retryInterval time.Duration) *RefreshingTokenSource {
+	if metricHandle == nil {
+		metricHandle = common.NewNoopMetrics()
+	}
+	return &RefreshingTokenSource{
+		metricHandle:  metricHandle,
+		refreshBefore: refreshBefore,
+		retryInterval: retryInterval,
+		source:        newEmptyTokenCache(base),
+	}
+}
+
+// newEmptyTokenCache returns a TokenSource caching the tokens of base, starting
+// with no token. If base is itself a cache, e.g. the one returned by
+// google.DefaultTokenSource, the cache is replaced rather than wrapped, so that
+// its token isn't reused. The tokens aren't expired early by the cache: when
+// they are refreshed is decided by the RefreshingTokenSource, according to
+// their lifetime.
+func newEmptyTokenCache(base oauth2.TokenSource) oauth2.TokenSource {
+	return oauth2.ReuseTokenSourceWithExpiry(&oauth2.Token{}, base, 0)
+}
+
+// Token returns the last token, unless it's about to expire and the background
+// refresh didn't replace it yet, in which case it is refreshed first.
+func (ts *RefreshingTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != nil && ts.token.Valid() {
+		return ts.token, nil
+	}
+	// Leave the retries of a failed refresh to the background while the last
+	// token can still be used, instead of retrying for every request.
+	if ts.token != nil && time.Now().Before(ts.token.Expiry) && time.Since(ts.failedAt) < ts.retryInterval {
+		return ts.token, nil
+	}
+	return ts.refresh(false)
+}
+
+// ForceRefresh refreshes the token because GCS rejected it, unless it has
+// already been replaced. This way, the requests rejected concurrently with the
+// same token cause a single refresh.
+func (ts *RefreshingTokenSource) ForceRefresh(rejected *oauth2.Token) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != rejected {
+		return nil
+	}
+	_, err := ts.refresh(true)
+	return err
+}
+
+// refresh fetches a new token and schedules the next background refresh. If
+// the refresh fails, the last token is returned as long as it hasn't expired
+// yet, unless force is true, and the refresh is retried in the background.
+//
+// LOCKS_REQUIRED(ts.mu)
+func (ts *RefreshingTokenSource) refresh(force bool) (*oauth2.Token, error) {
+	ts.source = newEmptyTokenCache(ts.source)
+
+	start := time.Now()
+	token, err := ts.source.Token()
+	if err != nil {
+		ts.failedAt = time.Now()
+		ts.metricHandle.GCSTokenRefreshFailureCount(context.Background(), 1, nil)
+		ts.schedule(ts.retryInterval)
+		if !force && ts.token != nil && time.Now().Before(ts.token.Expiry) {
+			logger.Warnf("Failed to refresh the token, using the previous one until it expires at %v: %v", ts.token.Expiry, err)
+			return ts.token, nil
+		}
+		return nil, err
+	}
+
+	ts.metricHandle.GCSTokenRefreshLatency(context.Background(), float64(time.Since(start).Milliseconds()), nil)
+	ts.token = token
+	if !token.Expiry.IsZero() {
+		ts.schedule(ts.refreshDelay(time.Until(token.Expiry)))
+	}
+	return token, nil
+}
+
+// refreshDelay returns how long to wait before refreshing a token expiring
+// after remaining: refreshBefore before it expires, but no sooner than half of
+// remaining nor retryInterval. This way, the tokens issued with less than
+// refreshBefore of lifetime, e.g. by a command or by the metadata server near
+// the end of the lifetime of its token, aren't refreshed in a loop.
+func (ts *RefreshingTokenSource) refreshDelay(remaining time.Duration) time.Duration {
+	return max(remaining-ts.refreshBefore, remaining/2, ts.retryInterval)
+}
+
+// schedule schedules the next background refresh after d, replacing the
+// previous one.
+//
+// LOCKS_REQUIRED(ts.mu)
+func (ts *RefreshingTokenSource) schedule(d time.Duration) {
+	if ts.timer != nil {
+		ts.timer.Stop()
+	}
+	ts.timer = time.AfterFunc(d, ts.refreshInBackground)
+}
+
+func (ts *RefreshingTokenSource) refreshInBackground() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, err := ts.refresh(false); err != nil {
+		logger.Warnf("Failed to refresh the token in the background: %v", err)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Retrying unauthorized requests
+////////////////////////////////////////////////////////////////////////
+
+// NewUnauthorizedRetryTransport returns a RoundTripper retrying the requests
+// rejected with 401 by the wrapped transport exactly once, after forcing the
+// refresh of the token of ts.
+//
+// The wrapped transport is expected to authenticate the requests with the
+// tokens of ts, e.g. oauth2.Transport. The requests whose body can't be read
+// again are not retried.
+func NewUnauthorizedRetryTransport(wrapped http.RoundTripper, ts *RefreshingTokenSource) http.RoundTripper {
+	return &unauthorizedRetryTransport{
+		wrapped:     wrapped,
+		tokenSource: ts,
+	}
+}
+
+type unauthorizedRetryTransport struct {
+	wrapped     http.RoundTripper
+	tokenSource *RefreshingTokenSource
+}
+
+func (t *unauthorizedRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The token the wrapped transport authenticates the request with. The
+	// error, if any, is returned by the wrapped transport.
+	rejected, _ := t.tokenSource.Token()
+	resp, err := t.wrapped.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	if err := t.tokenSource.ForceRefresh(rejected); err != nil {
+		logger.Warnf("Failed to refresh the token rejected by %s %s: %v", req.Method, req.URL.Redacted(), err)
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.wrapped.RoundTrip(retry)
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeTokenSource returns the tokens "token1", "token2", ... expiring after
+// lifetime, or err if set.
+type fakeTokenSource struct {
+	mu       sync.Mutex
+	lifetime time.Duration
+	err      error
+	calls    int
+}
+
+func (s *fakeTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &oauth2.Token{
+		AccessToken: fmt.Sprintf("token%d", s.calls),
+		Expiry:      time.Now().Add(s.lifetime),
+	}, nil
+}
+
+func (s *fakeTokenSource) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *fakeTokenSource) numCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestRefreshingTokenSource_ReusesToken(t *testing.T) {
+	base := &fakeTokenSource{lifetime: time.Hour}
+	ts := newRefreshingTokenSource(base, nil, time.Minute, time.Second)
+
+	token1, err := ts.Token()
+	require.NoError(t, err)
+	token2, err := ts.Token()
+	require.NoError(t, err)
+
+	assert.Equal(t, "token1", token1.AccessToken)
+	assert.Equal(t, "token1", token2.AccessToken)
+	assert.Equal(t, 1, base.numCalls())
+}
+
+func TestRefreshingTokenSource_RefreshesBeforeExpiry(t *testing.T) {
+	base := &fakeTokenSource{lifetime: time.Second}
+	ts := newRefreshingTokenSource(base, nil, 400*time.Millisecond, 10*time.Millisecond)
+	_, err := ts.Token()
+	require.NoError(t, err)
+
+	// The token is refreshed in the background after 600ms.
+	assert.Eventually(t, func() bool { return base.numCalls() >= 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRefreshingTokenSource_ShortLivedTokens(t *testing.T) {
+	// The tokens expire sooner than they would be refreshed before expiry.
+	base := &fakeTokenSource{lifetime: 400 * time.Millisecond}
+	ts := newRefreshingTokenSource(base, nil, time.Minute, 50*time.Millisecond)
+	_, err := ts.Token()
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	// The tokens are refreshed after half their lifetime, i.e. every 200ms,
+	// rather than in a loop.
+	assert.GreaterOrEqual(t, base.numCalls(), 3)
+	assert.LessOrEqual(t, base.numCalls(), 7)
+}
+
+func TestRefreshingTokenSource_RefreshDelay(t *testing.T) {
+	ts := newRefreshingTokenSource(&fakeTokenSource{}, nil, 5*time.Minute, 10*time.Second)
+
+	assert.Equal(t, 55*time.Minute, ts.refreshDelay(time.Hour))
+	assert.Equal(t, 4*time.Minute, ts.refreshDelay(8*time.Minute))
+	assert.Equal(t, 2*time.Minute, ts.refreshDelay(4*time.Minute))
+	assert.Equal(t, 10*time.Second, ts.refreshDelay(time.Second))
+	assert.Equal(t, 10*time.Second, ts.refreshDelay(-time.Second))
+}
+
+func TestRefreshingTokenSource_KeepsTokenOnFailure(t *testing.T) {
+	base := &fakeTokenSource{lifetime: time.Hour}
+	ts := newRefreshingTokenSource(base, nil, time.Minute, time.Hour)
+	rejected, err := ts.Token()
+	require.NoError(t, err)
+	base.setErr(errors.New("refresh failed"))
+
+	err = ts.ForceRefresh(rejected)
+	require.Error(t, err)
+	token, err := ts.Token()
+
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token.AccessToken)
+}
+
+func TestRefreshingTokenSource_FailsWithoutToken(t *testing.T) {
+	base := &fakeTokenSource{err: errors.New("refresh failed")}
+	ts := newRefreshingTokenSource(base, nil, time.Minute, time.Hour)
+
+	_, err := ts.Token()
+
+	assert.Error(t, err)
+}
+
+func TestRefreshingTokenSource_ForceRefresh(t *testing.T) {
+	// The base source caches its tokens like most of those of the google
+	// package.
+	base := &fakeTokenSource{lifetime: time.Hour}
+	ts := newRefreshingTokenSource(oauth2.ReuseTokenSource(nil, base), nil, time.Minute, time.Second)
+	rejected, err := ts.Token()
+	require.NoError(t, err)
+
+	require.NoError(t, ts.ForceRefresh(rejected))
+	// A concurrent rejection of the same token doesn't refresh it again.
+	require.NoError(t, ts.ForceRefresh(rejected))
+	token, err := ts.Token()
+
+	require.NoError(t, err)
+	assert.Equal(t, "token2", token.AccessToken)
+	assert.Equal(t, 2, base.numCalls())
+}
+
+func TestUnauthorizedRetryTransport(t *testing.T) {
+	tests := []struct {
+		name             string
+		validToken       string
+		body             string
+		expectedStatus   int
+		expectedRequests int
+	}{
+		{
+			name:             "authorized",
+			validToken:       "token1",
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 1,
+		},
+		{
+			name:             "retried_with_refreshed_token",
+			validToken:       "token2",
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		{
+			name:             "retried_with_body",
+			validToken:       "token2",
+			body:             "body",
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		{
+			name:             "retried_once",
+			validToken:       "token3",
+			expectedStatus:   http.StatusUnauthorized,
+			expectedRequests: 2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("Authorization") != "Bearer "+tc.validToken {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+			ts := newRefreshingTokenSource(&fakeTokenSource{lifetime: time.Hour}, nil, time.Minute, time.Second)
+			client := &http.Client{
+				Transport: NewUnauthorizedRetryTransport(&oauth2.Transport{Source: ts}, ts),
+			}
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			resp, err := client.Do(req)
+
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
@@ -16,10 +16,14 @@ package block
 
 import (
 	"fmt"
-
-	"golang.org/x/sync/semaphore"
+	"sync"
+	"time"
 )
 
+// blockWaitInterval bounds the wait of Get for the upload of a block of the
+// file, after which it checks again whether the dirty budget allows a new one.
+const blockWaitInterval = 10 * time.Millisecond
+
 // BlockPool handles the creation of blocks as per the user configuration.
 type BlockPool struct {
 	// Channel holding free blocks.
@@ -31,33 +35,50 @@ type BlockPool struct {
 	// Max number of blocks this blockPool can create.
 	maxBlocks int64
 
+	// Guards totalBlocks, which the dirty budget decreases when it releases the
+	// free blocks on behalf of the other files.
+	mu sync.Mutex
+
 	// Total number of blocks created so far.
+	//
+	// GUARDED_BY(mu)
 	totalBlocks int64
 
-	// Semaphore used to limit the total number of blocks created across
-	// different files.
-	globalMaxBlocksSem *semaphore.Weighted
+	// Limits the memory held by the blocks across different files, unlimited if
+	// nil.
+	dirty *DirtyBudget
+
+	// Places the blocks in memory or files, all in memory if nil.
+	spill *Spill
+
+	// Uploads the partly filled block of the file ahead of time, for the dirty
+	// budget under pressure, if not nil. Set before the pool is used.
+	flusher func() bool
 }
 
-// NewBlockPool creates the blockPool based on the user configuration.
-func NewBlockPool(blockSize int64, maxBlocks int64, globalMaxBlocksSem *semaphore.Weighted) (bp *BlockPool, err error) {
+// NewBlockPool creates the blockPool based on the user configuration. The
+// blocks are charged to dirty and spilled to files under memory pressure by
+// spill, if not nil.
+func NewBlockPool(blockSize int64, maxBlocks int64, dirty *DirtyBudget, spill *Spill) (bp *BlockPool, err error) {
 	if blockSize <= 0 || maxBlocks <= 0 {
 		err = fmt.Errorf("invalid configuration provided for blockPool, blocksize: %d, maxBlocks: %d", blockSize, maxBlocks)
 		return
 	}
 
 	bp = &BlockPool{
-		freeBlocksCh:       make(chan Block, maxBlocks),
-		blockSize:          blockSize,
-		maxBlocks:          maxBlocks,
-		totalBlocks:        0,
-		globalMaxBlocksSem: globalMaxBlocksSem,
+		freeBlocksCh: make(chan Block, maxBlocks),
+		blockSize:    blockSize,
+		maxBlocks:    maxBlocks,
+		totalBlocks:  0,
+		dirty:        dirty,
+		spill:        spill,
 	}
 	return
 }
 
 // Get returns a block. It returns an existing block if it's ready for reuse or
-// creates a new one if required.
+// creates a new one if required. Once the dirty budget is exhausted, it waits
+// for the upload of one of the blocks of the file instead.
 func (bp *BlockPool) Get() (Block, error) {
 	for {
 		select {
@@ -67,28 +88,63 @@ func (bp *BlockPool) Get() (Block, error) {
 			return b, nil
 
 		default:
-			// No lock is required here since blockPool is per file and all write
-			// calls to a single file are serialized because of inode.lock().
-			if bp.totalBlocks < bp.maxBlocks {
-				freeSlotsAvailable := bp.globalMaxBlocksSem.TryAcquire(1)
-				// We are allowed to create one block per file irrespective of free slots.
-				if bp.totalBlocks > 0 && !freeSlotsAvailable {
-					continue
-				}
-
-				b, err := createBlock(bp.blockSize)
-				if err != nil {
-					return nil, err
-				}
-
-				bp.totalBlocks++
-				return b, nil
+		}
 
+		bp.mu.Lock()
+		totalBlocks := bp.totalBlocks
+		bp.mu.Unlock()
+		// We are allowed to create one block per file irrespective of the dirty
+		// budget.
+		if totalBlocks < bp.maxBlocks && bp.dirty.reserve(bp, bp.blockSize, totalBlocks == 0) {
+			b, err := bp.spill.createBlock(bp.blockSize)
+			if err != nil {
+				bp.dirty.release(bp, bp.blockSize, totalBlocks)
+				return nil, err
 			}
+
+			bp.mu.Lock()
+			bp.totalBlocks++
+			bp.mu.Unlock()
+			return b, nil
+		}
+
+		select {
+		case b := <-bp.freeBlocksCh:
+			b.Reuse()
+			return b, nil
+		case <-time.After(blockWaitInterval):
 		}
 	}
 }
 
+// SetFlusher sets the function the dirty budget calls under pressure to have
+// the file upload its partly filled block, returning whether it did. The block
+// is then released once uploaded, like the other free blocks.
+func (bp *BlockPool) SetFlusher(flusher func() bool) {
+	bp.flusher = flusher
+}
+
+// flush has the file upload its partly filled block, if it has one and a
+// flusher is set.
+func (bp *BlockPool) flush() bool {
+	return bp.flusher != nil && bp.flusher()
+}
+
+// Release deallocates a block of the pool which isn't in its free channel,
+// e.g. the partly filled block of a file whose writes are discarded.
+func (bp *BlockPool) Release(b Block) error {
+	err := b.Deallocate()
+	bp.mu.Lock()
+	bp.totalBlocks--
+	totalBlocks := bp.totalBlocks
+	bp.mu.Unlock()
+	bp.dirty.release(bp, bp.blockSize, totalBlocks)
+	if err != nil {
+		return fmt.Errorf("munmap error: %v", err)
+	}
+	return nil
+}
+
 // FreeBlocksChannel returns the freeBlocksCh being used by the block pool.
 func (bp *BlockPool) FreeBlocksChannel() chan Block {
 	return bp.freeBlocksCh
@@ -108,8 +164,11 @@ func (bp *BlockPool) ClearFreeBlockChannel() error {
 				// if we get here, there is likely memory corruption.
 				return fmt.Errorf("munmap error: %v", err)
 			}
+			bp.mu.Lock()
 			bp.totalBlocks--
-			bp.globalMaxBlocksSem.Release(1)
+			totalBlocks := bp.totalBlocks
+			bp.mu.Unlock()
+			bp.dirty.release(bp, bp.blockSize, totalBlocks)
 		default:
 			// Return if there are no more blocks on the channel.
 			return nil
@@ -23,7 +23,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"golang.org/x/sync/semaphore"
 )
 
 const invalidConfigError string = "invalid configuration provided for blockPool, blocksize: %d, maxBlocks: %d"
@@ -37,7 +36,7 @@ func TestBlockPoolTestSuite(t *testing.T) {
 }
 
 func (t *BlockPoolTest) TestInitBlockPool() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(10*1024), nil)
 
 	require.Nil(t.T(), err)
 	require.NotNil(t.T(), bp)
@@ -47,28 +46,28 @@ func (t *BlockPoolTest) TestInitBlockPool() {
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForZeroBlockSize() {
-	_, err := NewBlockPool(0, 10, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(0, 10, NewDirtyBudget(10*1024), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 0, 10), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForNegativeBlockSize() {
-	_, err := NewBlockPool(-1, 10, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(-1, 10, NewDirtyBudget(10*1024), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, -1, 10), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForZeroMaxBlocks() {
-	_, err := NewBlockPool(10, 0, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(10, 0, NewDirtyBudget(10*1024), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 10, 0), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForNegativeMaxBlocks() {
-	_, err := NewBlockPool(10, -1, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(10, -1, NewDirtyBudget(10*1024), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 10, -1), err)
@@ -76,7 +75,7 @@ func (t *BlockPoolTest) TestInitBlockPoolForNegativeMaxBlocks() {
 
 // Represents when block is available on the freeBlocksCh.
 func (t *BlockPoolTest) TestGetWhenBlockIsAvailableForReuse() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(10*1024), nil)
 	require.Nil(t.T(), err)
 	// Creating a block with some data and send it to blockCh.
 	b, err := createBlock(2)
@@ -101,7 +100,7 @@ func (t *BlockPoolTest) TestGetWhenBlockIsAvailableForReuse() {
 }
 
 func (t *BlockPoolTest) TestGetWhenTotalBlocksIsLessThanThanMaxBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(10*1024), nil)
 	require.Nil(t.T(), err)
 
 	block, err := bp.Get()
@@ -113,7 +112,7 @@ func (t *BlockPoolTest) TestGetWhenTotalBlocksIsLessThanThanMaxBlocks() {
 
 func (t *BlockPoolTest) TestCreateBlockWithLargeSize() {
 	// Creating block of size 1TB
-	bp, err := NewBlockPool(1024*1024*1024*1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024*1024*1024*1024, 10, nil, nil)
 	require.Nil(t.T(), err)
 
 	_, err = bp.Get()
@@ -123,14 +122,14 @@ func (t *BlockPoolTest) TestCreateBlockWithLargeSize() {
 }
 
 func (t *BlockPoolTest) TestBlockSize() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(10*1024), nil)
 
 	require.Nil(t.T(), err)
 	require.Equal(t.T(), int64(1024), bp.BlockSize())
 }
 
 func (t *BlockPoolTest) TestClearFreeBlockChannel() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(3))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(3*1024), nil)
 	require.Nil(t.T(), err)
 	b1, err := bp.Get()
 	require.Nil(t.T(), err)
@@ -153,16 +152,15 @@ func (t *BlockPoolTest) TestClearFreeBlockChannel() {
 	require.Nil(t.T(), b1.(*memoryBlock).buffer)
 	require.Nil(t.T(), b2.(*memoryBlock).buffer)
 	require.NotNil(t.T(), b3.(*memoryBlock).buffer)
-	// Check if semaphore is released correctly.
-	require.True(t.T(), bp.globalMaxBlocksSem.TryAcquire(2))
-	require.False(t.T(), bp.globalMaxBlocksSem.TryAcquire(1))
+	// Check if the dirty budget is released correctly.
+	require.Equal(t.T(), int64(1024), bp.dirty.Used())
 }
 
-func (t *BlockPoolTest) TestGetWhenGlobalMaxBlocksIsZero() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(0))
+func (t *BlockPoolTest) TestGetWhenDirtyBudgetIsZero() {
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(0), nil)
 	require.Nil(t.T(), err)
 
-	// First block is allowed even with globalMaxBlocks being zero.
+	// First block is allowed even with the dirty budget being zero.
 	b1, err := bp.Get()
 	require.Nil(t.T(), err)
 	require.NotNil(t.T(), b1)
@@ -170,8 +168,8 @@ func (t *BlockPoolTest) TestGetWhenGlobalMaxBlocksIsZero() {
 	t.validateGetBlockIsBlocked(bp)
 }
 
-func (t *BlockPoolTest) TestGetWhenTotalBlocksEqualToGlobalBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2))
+func (t *BlockPoolTest) TestGetWhenDirtyBudgetIsExhausted() {
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(2*1024), nil)
 	require.Nil(t.T(), err)
 
 	// Create 1st block
@@ -188,7 +186,7 @@ func (t *BlockPoolTest) TestGetWhenTotalBlocksEqualToGlobalBlocks() {
 }
 
 func (t *BlockPoolTest) TestGetWhenTotalBlocksEqualToMaxBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2))
+	bp, err := NewBlockPool(1024, 10, NewDirtyBudget(2*1024), nil)
 	require.Nil(t.T(), err)
 	bp.totalBlocks = 10
 
@@ -222,3 +220,38 @@ func (t *BlockPoolTest) TestBlockPool_FreeBlocksChannel() {
 	assert.NotNil(t.T(), ch)
 	assert.Equal(t.T(), freeBlocksCh, ch)
 }
+
+func (t *BlockPoolTest) TestGetReleasesTheFreeBlocksOfTheFilesHoldingTheMost() {
+	dirty := NewDirtyBudget(3 * 1024)
+	newPoolWithFreeBlocks := func(n int) *BlockPool {
+		bp, err := NewBlockPool(1024, 10, dirty, nil)
+		require.NoError(t.T(), err)
+		var blocks []Block
+		for i := 0; i < n; i++ {
+			b, err := bp.Get()
+			require.NoError(t.T(), err)
+			blocks = append(blocks, b)
+		}
+		for _, b := range blocks {
+			bp.freeBlocksCh <- b
+		}
+		return bp
+	}
+	largest := newPoolWithFreeBlocks(2)
+	smallest := newPoolWithFreeBlocks(1)
+	require.Equal(t.T(), int64(3*1024), dirty.Used())
+	bp, err := NewBlockPool(1024, 10, dirty, nil)
+	require.NoError(t.T(), err)
+	// The first block is allowed beyond the budget.
+	_, err = bp.Get()
+	require.NoError(t.T(), err)
+
+	b, err := bp.Get()
+
+	require.NoError(t.T(), err)
+	require.NotNil(t.T(), b)
+	assert.Equal(t.T(), int64(2), bp.totalBlocks)
+	assert.Equal(t.T(), int64(0), largest.totalBlocks)
+	assert.Equal(t.T(), int64(1), smallest.totalBlocks)
+	assert.Equal(t.T(), int64(3*1024), dirty.Used())
+}
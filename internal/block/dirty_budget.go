@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// DirtyBudget caps the bytes held by the blocks of the streaming writes of all
+// the files together, rather than their number per file. When a file needs a
+// new block beyond the cap, the free blocks kept for reuse by the other files
+// are released, from the files holding the most of them, before the file
+// waits for the upload of its own blocks. If none are free, the file holding
+// the most blocks uploads its partly filled one ahead of time, to be released
+// once uploaded. So many files written at once share the memory instead of
+// failing once each has kept its blocks.
+//
+// A nil *DirtyBudget doesn't cap the blocks.
+type DirtyBudget struct {
+	limit int64
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	used int64
+
+	// The pools holding blocks charged to the budget.
+	//
+	// GUARDED_BY(mu)
+	pools map[*BlockPool]struct{}
+}
+
+// NewDirtyBudget returns a budget capping the blocks at limitBytes.
+func NewDirtyBudget(limitBytes int64) *DirtyBudget {
+	return &DirtyBudget{
+		limit: limitBytes,
+		pools: make(map[*BlockPool]struct{}),
+	}
+}
+
+// Used returns the bytes held by the blocks charged to the budget.
+func (d *DirtyBudget) Used() int64 {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.used
+}
+
+// reserve charges size bytes for a new block of bp, releasing the free blocks
+// of the other pools if needed. force charges them even beyond the limit. It
+// returns whether the bytes were charged.
+//
+// LOCKS_EXCLUDED(bp.mu)
+func (d *DirtyBudget) reserve(bp *BlockPool, size int64, force bool) bool {
+	if d == nil {
+		return true
+	}
+	if d.charge(bp, size, false) {
+		return true
+	}
+
+	for _, p := range d.idlePools(bp) {
+		if err := p.ClearFreeBlockChannel(); err != nil {
+			logger.Errorf("Releasing the free blocks of streaming writes: %v", err)
+		}
+		if d.charge(bp, size, false) {
+			return true
+		}
+	}
+
+	// The largest file with a partly filled block uploads it, for a next
+	// reservation to release it.
+	for _, p := range d.busyPools(bp) {
+		if p.flush() {
+			break
+		}
+	}
+	return d.charge(bp, size, force)
+}
+
+func (d *DirtyBudget) charge(bp *BlockPool, size int64, force bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !force && d.used+size > d.limit {
+		return false
+	}
+	d.used += size
+	d.pools[bp] = struct{}{}
+	return true
+}
+
+// idlePools returns the pools other than bp with free blocks, the ones with
+// the most first.
+func (d *DirtyBudget) idlePools(bp *BlockPool) []*BlockPool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var pools []*BlockPool
+	for p := range d.pools {
+		if p != bp && len(p.freeBlocksCh) > 0 {
+			pools = append(pools, p)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return int64(len(pools[i].freeBlocksCh))*pools[i].blockSize > int64(len(pools[j].freeBlocksCh))*pools[j].blockSize
+	})
+	return pools
+}
+
+// busyPools returns the pools other than bp, the ones holding the most blocks
+// first.
+func (d *DirtyBudget) busyPools(bp *BlockPool) []*BlockPool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var pools []*BlockPool
+	held := make(map[*BlockPool]int64)
+	for p := range d.pools {
+		if p != bp {
+			p.mu.Lock()
+			held[p] = p.totalBlocks * p.blockSize
+			p.mu.Unlock()
+			pools = append(pools, p)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return held[pools[i]] > held[pools[j]]
+	})
+	return pools
+}
+
+// release gives back size bytes of a block of bp, which holds totalBlocks
+// blocks left.
+func (d *DirtyBudget) release(bp *BlockPool, size int64, totalBlocks int64) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.used -= size
+	if totalBlocks == 0 {
+		delete(d.pools, bp)
+	}
+}
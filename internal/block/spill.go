@@ -0,0 +1,165 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
+)
+
+// Spill places the new blocks in files in a local directory, e.g. on a local
+// SSD, instead of memory while the memory usage of gcsfuse's cgroup exceeds a
+// percentage of its limit, or if the memory can't be allocated. This keeps
+// memory-constrained containers from being killed while streaming large
+// writes, e.g. checkpoints.
+//
+// A nil *Spill places all the blocks in memory.
+type Spill struct {
+	dir string
+
+	// budget reserves the files, if not nil, like the other temporary files
+	// of dir.
+	budget *diskbudget.Budget
+
+	thresholdPercent uint64
+
+	// memoryUsage is overridden in tests.
+	memoryUsage func() (usedBytes, limitBytes uint64)
+}
+
+// NewSpill returns a Spill placing the blocks in files in dir, reserved in
+// budget, while the memory usage exceeds thresholdPercent of the limit.
+func NewSpill(dir string, budget *diskbudget.Budget, thresholdPercent int64) *Spill {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return &Spill{
+		dir:              dir,
+		budget:           budget,
+		thresholdPercent: uint64(thresholdPercent),
+		memoryUsage:      machineprofile.MemoryUsage,
+	}
+}
+
+func (s *Spill) underMemoryPressure() bool {
+	used, limit := s.memoryUsage()
+	return limit > 0 && used*100 > limit*s.thresholdPercent
+}
+
+// createBlock creates a new block in memory, or in a file under memory
+// pressure.
+func (s *Spill) createBlock(blockSize int64) (Block, error) {
+	if s == nil {
+		return createBlock(blockSize)
+	}
+
+	if !s.underMemoryPressure() {
+		b, err := createBlock(blockSize)
+		if err == nil {
+			return b, nil
+		}
+		logger.Warnf("Buffering the write in %s: %v", s.dir, err)
+	}
+
+	// The file may not be reserved when the disk budget is exhausted, the
+	// memory is then the only option left.
+	b, err := createFileBlock(s.dir, blockSize, s.budget)
+	if err != nil {
+		logger.Warnf("Buffering the write in memory despite the memory pressure: %v", err)
+		return createBlock(blockSize)
+	}
+	return b, nil
+}
+
+// fileBlock is a block in an unlinked file, so that it's deleted once closed,
+// even if gcsfuse crashes.
+type fileBlock struct {
+	file      *os.File
+	blockSize int64
+	size      int64
+	budget    *diskbudget.Budget
+}
+
+func createFileBlock(dir string, blockSize int64, budget *diskbudget.Budget) (Block, error) {
+	if err := budget.Reserve(uint64(blockSize)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, "gcsfuse-block")
+	if err == nil {
+		err = os.Remove(f.Name())
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		budget.Release(uint64(blockSize))
+		return nil, fmt.Errorf("creating the block file: %w", err)
+	}
+
+	return &fileBlock{
+		file:      f,
+		blockSize: blockSize,
+		budget:    budget,
+	}, nil
+}
+
+func (b *fileBlock) Reuse() {
+	// The data is overwritten anyway, truncating only frees the disk space.
+	if err := b.file.Truncate(0); err != nil {
+		logger.Warnf("Truncating the block file: %v", err)
+	}
+	b.size = 0
+}
+
+func (b *fileBlock) Size() int64 {
+	return b.size
+}
+
+func (b *fileBlock) Write(bytes []byte) error {
+	if b.size+int64(len(bytes)) > b.blockSize {
+		return fmt.Errorf("received data more than capacity of the block")
+	}
+
+	n, err := b.file.WriteAt(bytes, b.size)
+	b.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing the block file: %w", err)
+	}
+	return nil
+}
+
+func (b *fileBlock) Reader() io.Reader {
+	return io.NewSectionReader(b.file, 0, b.size)
+}
+
+func (b *fileBlock) Deallocate() error {
+	if b.file == nil {
+		return fmt.Errorf("invalid block file")
+	}
+
+	err := b.file.Close()
+	b.file = nil
+	b.budget.Release(uint64(b.blockSize))
+	if err != nil {
+		return fmt.Errorf("closing the block file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpillTest struct {
+	suite.Suite
+	dir    string
+	budget *diskbudget.Budget
+	spill  *Spill
+	used   uint64
+}
+
+func TestSpillTestSuite(t *testing.T) {
+	suite.Run(t, new(SpillTest))
+}
+
+func (t *SpillTest) SetupTest() {
+	t.dir = t.T().TempDir()
+	t.budget = diskbudget.New(1024)
+	t.spill = NewSpill(t.dir, t.budget, 80)
+	t.spill.memoryUsage = func() (uint64, uint64) { return t.used, 100 }
+}
+
+func (t *SpillTest) TestBlocksAreInMemoryBelowThreshold() {
+	t.used = 80
+
+	b, err := t.spill.createBlock(512)
+
+	require.NoError(t.T(), err)
+	assert.IsType(t.T(), &memoryBlock{}, b)
+	assert.Equal(t.T(), uint64(0), t.budget.Used())
+}
+
+func (t *SpillTest) TestBlocksAreInFilesAboveThreshold() {
+	t.used = 81
+
+	b, err := t.spill.createBlock(512)
+
+	require.NoError(t.T(), err)
+	assert.IsType(t.T(), &fileBlock{}, b)
+	assert.Equal(t.T(), uint64(512), t.budget.Used())
+	// The file is unlinked right away.
+	entries, err := os.ReadDir(t.dir)
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), entries)
+	require.NoError(t.T(), b.Deallocate())
+	assert.Equal(t.T(), uint64(0), t.budget.Used())
+}
+
+func (t *SpillTest) TestBlocksAreInMemoryWhenTheDiskBudgetIsExhausted() {
+	t.used = 100
+	require.NoError(t.T(), t.budget.Reserve(1000))
+
+	b, err := t.spill.createBlock(512)
+
+	require.NoError(t.T(), err)
+	assert.IsType(t.T(), &memoryBlock{}, b)
+}
+
+func (t *SpillTest) TestFileBlockWriteReadAndReuse() {
+	b, err := createFileBlock(t.dir, 4, nil)
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), b.Write([]byte("hi")))
+	require.NoError(t.T(), b.Write([]byte("yo")))
+	assert.EqualError(t.T(), b.Write([]byte("!")), outOfCapacityError)
+
+	output, err := io.ReadAll(b.Reader())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), []byte("hiyo"), output)
+	assert.Equal(t.T(), int64(4), b.Size())
+	b.Reuse()
+	assert.Equal(t.T(), int64(0), b.Size())
+	require.NoError(t.T(), b.Write([]byte("a")))
+	output, err = io.ReadAll(b.Reader())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), []byte("a"), output)
+	require.NoError(t.T(), b.Deallocate())
+	assert.Error(t.T(), b.Deallocate())
+}
+
+func (t *SpillTest) TestBlockPoolSpills() {
+	t.used = 90
+	bp, err := NewBlockPool(512, 10, NewDirtyBudget(10*512), t.spill)
+	require.NoError(t.T(), err)
+
+	b, err := bp.Get()
+
+	require.NoError(t.T(), err)
+	assert.IsType(t.T(), &fileBlock{}, b)
+}
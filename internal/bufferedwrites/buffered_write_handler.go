@@ -15,30 +15,41 @@
 package bufferedwrites
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
-	"golang.org/x/sync/semaphore"
 )
 
-// Note: All the write operations take inode lock in fs.go, hence we don't need any locks here
-// as we will get write operations serially.
+// Note: All the write operations take inode lock in fs.go, hence we get write
+// operations serially. mu only excludes the uploads of the current block on
+// behalf of the dirty budget, from the goroutines writing the other files.
 
 // BufferedWriteHandler is responsible for filling up the buffers with the data
 // as it receives and handing over to uploadHandler which uploads to GCS.
 type BufferedWriteHandler struct {
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
 	current       block.Block
 	blockPool     *block.BlockPool
 	uploadHandler *UploadHandler
 	// Total size of data buffered so far. Some part of buffered data might have
 	// been uploaded to GCS as well. Depending on the state we are in, it might or
-	// might not include truncatedSize.
+	// might not include truncatedSize. It includes the pending data.
 	totalSize int64
+	// The adjacent writes smaller than coalesceSize are gathered in pending and
+	// appended to the current block at once, so that e.g. writes of 4KiB don't
+	// each pay for getting the block and copying into it.
+	coalesceSize int
+	pending      []byte
 	// Stores the mtime value updated by kernel as part of setInodeAttributes call.
 	mtime time.Time
 	// Stores the size to truncate. No action is made when truncate is called.
@@ -48,6 +59,9 @@ type BufferedWriteHandler struct {
 	// 2. If write is started after the truncate offset, dummy data is created
 	// as per the truncatedSize and then new data is appended to it.
 	truncatedSize int64
+	// Records the time the writes are blocked waiting for free blocks, i.e. for
+	// the upload of the previous ones.
+	metricHandle common.MetricHandle
 }
 
 // WriteFileInfo is used as part of serving fileInode attributes (GetInodeAttributes call).
@@ -60,21 +74,49 @@ var ErrOutOfOrderWrite = errors.New("outOfOrder write detected")
 var ErrUploadFailure = errors.New("error while uploading object to GCS")
 
 type CreateBWHandlerRequest struct {
-	Object                   *gcs.Object
-	ObjectName               string
-	Bucket                   gcs.Bucket
-	BlockSize                int64
-	MaxBlocksPerFile         int64
-	GlobalMaxBlocksSem       *semaphore.Weighted
+	Object           *gcs.Object
+	ObjectName       string
+	Bucket           gcs.Bucket
+	BlockSize        int64
+	MaxBlocksPerFile int64
+	// DirtyBudget caps the blocks of all the files together. Optional.
+	DirtyBudget              *block.DirtyBudget
 	ChunkTransferTimeoutSecs int64
+	// CoalesceSize is the size below which the adjacent writes are gathered
+	// before being appended to the block. Optional, 0 appending every write on
+	// its own.
+	CoalesceSize int64
+	// MetricHandle records the upload backpressure. Optional.
+	MetricHandle common.MetricHandle
+	// Spill places the blocks in files under memory pressure. Optional.
+	Spill *block.Spill
+	// Progress records the progress of the upload. Optional.
+	Progress ProgressRecorder
+}
+
+// ProgressRecorder records the progress of the upload of the blocks, e.g. to
+// report it to the users waiting on a flush. Its methods are called from the
+// uploader goroutine as well.
+type ProgressRecorder interface {
+	// BlocksPending is called with the change in the number of blocks waiting
+	// for their upload.
+	BlocksPending(delta int64)
+
+	// Committed is called with the number of bytes of the object committed to
+	// GCS so far.
+	Committed(bytesUploadedSoFar int64)
 }
 
 // NewBWHandler creates the bufferedWriteHandler struct.
 func NewBWHandler(req *CreateBWHandlerRequest) (bwh *BufferedWriteHandler, err error) {
-	bp, err := block.NewBlockPool(req.BlockSize, req.MaxBlocksPerFile, req.GlobalMaxBlocksSem)
+	bp, err := block.NewBlockPool(req.BlockSize, req.MaxBlocksPerFile, req.DirtyBudget, req.Spill)
 	if err != nil {
 		return
 	}
+	metricHandle := req.MetricHandle
+	if metricHandle == nil {
+		metricHandle = common.NewNoopMetrics()
+	}
 
 	bwh = &BufferedWriteHandler{
 		current:   nil,
@@ -87,17 +129,58 @@ func NewBWHandler(req *CreateBWHandlerRequest) (bwh *BufferedWriteHandler, err e
 			MaxBlocksPerFile:         req.MaxBlocksPerFile,
 			BlockSize:                req.BlockSize,
 			ChunkTransferTimeoutSecs: req.ChunkTransferTimeoutSecs,
+			Progress:                 req.Progress,
 		}),
 		totalSize:     0,
-		mtime:         time.Now(),
 		truncatedSize: -1,
+		coalesceSize:  int(req.CoalesceSize),
+		metricHandle:  metricHandle,
 	}
+	bwh.SetMtime(time.Now())
+	bp.SetFlusher(bwh.flushUnderPressure)
 	return
 }
 
+// flushUnderPressure uploads the partly filled current block ahead of time, so
+// that the dirty budget releases it once uploaded on behalf of the other
+// files. It returns whether it uploaded a block, and gives up if the file is
+// being written.
+func (wh *BufferedWriteHandler) flushUnderPressure() bool {
+	if !wh.mu.TryLock() {
+		return false
+	}
+	defer wh.mu.Unlock()
+
+	if wh.current == nil || wh.current.Size() == 0 {
+		return false
+	}
+	if err := wh.uploadHandler.Upload(wh.current); err != nil {
+		logger.Warnf("Uploading the partly filled block of %s under memory pressure: %v", wh.uploadHandler.objectName, err)
+		return false
+	}
+	wh.current = nil
+	return true
+}
+
+// releaseCurrent releases the partly filled current block, whose data is
+// discarded.
+//
+// LOCKS_REQUIRED(wh.mu)
+func (wh *BufferedWriteHandler) releaseCurrent() error {
+	if wh.current == nil {
+		return nil
+	}
+	err := wh.blockPool.Release(wh.current)
+	wh.current = nil
+	return err
+}
+
 // Write writes the given data to the buffer. It writes to an existing buffer if
 // the capacity is available otherwise writes to a new buffer.
 func (wh *BufferedWriteHandler) Write(data []byte, offset int64) (err error) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
 	// Fail early if the uploadHandler has failed.
 	select {
 	case <-wh.uploadHandler.SignalUploadFailure():
@@ -120,17 +203,51 @@ func (wh *BufferedWriteHandler) Write(data []byte, offset int64) (err error) {
 		}
 	}
 
-	return wh.appendBuffer(data)
+	if len(data) < wh.coalesceSize {
+		if len(wh.pending)+len(data) > wh.coalesceSize {
+			if err = wh.flushPending(); err != nil {
+				return
+			}
+		}
+		if wh.pending == nil {
+			wh.pending = make([]byte, 0, wh.coalesceSize)
+		}
+		wh.pending = append(wh.pending, data...)
+	} else {
+		if err = wh.flushPending(); err != nil {
+			return
+		}
+		if err = wh.appendBuffer(data); err != nil {
+			return
+		}
+	}
+
+	wh.totalSize += int64(len(data))
+	return
+}
+
+// flushPending appends the data gathered from the small writes to the block.
+func (wh *BufferedWriteHandler) flushPending() error {
+	if len(wh.pending) == 0 {
+		return nil
+	}
+
+	err := wh.appendBuffer(wh.pending)
+	wh.pending = wh.pending[:0]
+	return err
 }
 
 func (wh *BufferedWriteHandler) appendBuffer(data []byte) (err error) {
 	dataWritten := 0
 	for dataWritten < len(data) {
 		if wh.current == nil {
+			start := time.Now()
 			wh.current, err = wh.blockPool.Get()
 			if err != nil {
 				return fmt.Errorf("failed to get new block: %w", err)
 			}
+			// Getting a block blocks until one is uploaded once all are in use.
+			wh.metricHandle.GCSUploadBackpressureTime(context.Background(), time.Since(start).Microseconds(), nil)
 		}
 
 		remainingBlockSize := float64(wh.blockPool.BlockSize()) - float64(wh.current.Size())
@@ -152,12 +269,20 @@ func (wh *BufferedWriteHandler) appendBuffer(data []byte) (err error) {
 		}
 	}
 
-	wh.totalSize += int64(dataWritten)
 	return
 }
 
 // Sync uploads all the pending full buffers to GCS.
 func (wh *BufferedWriteHandler) Sync() (err error) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
+	// The pending data may fill up the current block.
+	err = wh.flushPending()
+	if err != nil {
+		return
+	}
+
 	// Upload all the pending buffers and release the buffers.
 	wh.uploadHandler.AwaitBlocksUpload()
 	err = wh.blockPool.ClearFreeBlockChannel()
@@ -176,8 +301,17 @@ func (wh *BufferedWriteHandler) Sync() (err error) {
 
 // Flush finalizes the upload.
 func (wh *BufferedWriteHandler) Flush() (*gcs.MinObject, error) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
+	err := wh.flushPending()
+	if err != nil {
+		return nil, err
+	}
+	wh.pending = nil
+
 	// In case it is a truncated file, upload empty blocks as required.
-	err := wh.writeDataForTruncatedSize()
+	err = wh.writeDataForTruncatedSize()
 	if err != nil {
 		return nil, err
 	}
@@ -212,9 +346,11 @@ func (wh *BufferedWriteHandler) Flush() (*gcs.MinObject, error) {
 	return obj, nil
 }
 
-// SetMtime stores the mtime with the bufferedWriteHandler.
+// SetMtime stores the mtime with the bufferedWriteHandler. It is persisted in
+// the object metadata when the upload is finalized.
 func (wh *BufferedWriteHandler) SetMtime(mtime time.Time) {
 	wh.mtime = mtime
+	wh.uploadHandler.mtime = mtime
 }
 
 func (wh *BufferedWriteHandler) Truncate(size int64) error {
@@ -236,8 +372,15 @@ func (wh *BufferedWriteHandler) WriteFileInfo() WriteFileInfo {
 }
 
 func (wh *BufferedWriteHandler) Destroy() error {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
 	// Destroy the upload handler and then free up the buffers.
+	wh.pending = nil
 	wh.uploadHandler.Destroy()
+	if err := wh.releaseCurrent(); err != nil {
+		return err
+	}
 	return wh.blockPool.ClearFreeBlockChannel()
 }
 
@@ -248,6 +391,11 @@ func (wh *BufferedWriteHandler) writeDataForTruncatedSize() error {
 		return nil
 	}
 
+	err := wh.flushPending()
+	if err != nil {
+		return err
+	}
+
 	// Otherwise append dummy data to match truncatedSize.
 	diff := wh.truncatedSize - wh.totalSize
 	// Create 1MB of data at a time to avoid OOM
@@ -258,13 +406,21 @@ func (wh *BufferedWriteHandler) writeDataForTruncatedSize() error {
 		if err != nil {
 			return err
 		}
+		wh.totalSize += int64(size)
 	}
 
 	return nil
 }
 
 func (wh *BufferedWriteHandler) Unlink() {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
+	wh.pending = nil
 	wh.uploadHandler.CancelUpload()
+	if err := wh.releaseCurrent(); err != nil {
+		logger.Errorf("blockPool.Release() failed: %v", err)
+	}
 	err := wh.blockPool.ClearFreeBlockChannel()
 	if err != nil {
 		// Only logging an error in case of resource leak.
@@ -0,0 +1,59 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufferedwrites
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+)
+
+// BenchmarkBufferedWriteHandler_WriteFile writes files of 32 MiB in writes of
+// 1 MiB to a bucket simulating the latencies of GCS, for the benchmark not to
+// depend on the network.
+func BenchmarkBufferedWriteHandler_WriteFile(b *testing.B) {
+	const fileSize, writeSize = 32 << 20, 1 << 20
+	bucket := fake.NewLatencyBucket(fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), fake.GCSLatencyProfile, 1)
+	data := make([]byte, writeSize)
+	b.SetBytes(fileSize)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		bwh, err := NewBWHandler(&CreateBWHandlerRequest{
+			ObjectName:               fmt.Sprintf("foo%d", i),
+			Bucket:                   bucket,
+			BlockSize:                8 << 20,
+			MaxBlocksPerFile:         4,
+			DirtyBudget:              block.NewDirtyBudget(4 * 8 << 20),
+			ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for offset := int64(0); offset < fileSize; offset += writeSize {
+			if err = bwh.Write(data, offset); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if _, err = bwh.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
@@ -15,18 +15,21 @@
 package bufferedwrites
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/operations"
 	"github.com/jacobsa/timeutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"golang.org/x/sync/semaphore"
 )
 
 const chunkTransferTimeoutSecs int64 = 10
@@ -48,7 +51,7 @@ func (testSuite *BufferedWriteTest) SetupTest() {
 		Bucket:                   bucket,
 		BlockSize:                blockSize,
 		MaxBlocksPerFile:         10,
-		GlobalMaxBlocksSem:       semaphore.NewWeighted(10),
+		DirtyBudget:              block.NewDirtyBudget(10 * blockSize),
 		ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
 	})
 	require.Nil(testSuite.T(), err)
@@ -385,3 +388,153 @@ func (testSuite *BufferedWriteTest) TestUnlinkAfterWrite() {
 	assert.Equal(testSuite.T(), 0, len(testSuite.bwh.uploadHandler.uploadCh))
 	assert.Equal(testSuite.T(), 0, len(testSuite.bwh.blockPool.FreeBlocksChannel()))
 }
+
+// backpressureMetricHandle counts the recordings of the upload backpressure.
+type backpressureMetricHandle struct {
+	common.MetricHandle
+	recordings int
+}
+
+func (m *backpressureMetricHandle) GCSUploadBackpressureTime(_ context.Context, _ int64, _ []common.MetricAttr) {
+	m.recordings++
+}
+
+func TestBufferedWriteHandlerRecordsBackpressureForEachBlock(t *testing.T) {
+	m := &backpressureMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	bwh, err := NewBWHandler(&CreateBWHandlerRequest{
+		ObjectName:               "testObject",
+		Bucket:                   fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.NonHierarchical),
+		BlockSize:                blockSize,
+		MaxBlocksPerFile:         1,
+		DirtyBudget:              block.NewDirtyBudget(blockSize),
+		ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
+		MetricHandle:             m,
+	})
+	require.NoError(t, err)
+
+	// The second block is only available once the first one is uploaded.
+	err = bwh.Write(make([]byte, 2*blockSize), 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.recordings)
+}
+
+func newCoalescingBWHandler(t *testing.T, bucket gcs.Bucket) *BufferedWriteHandler {
+	t.Helper()
+	bwh, err := NewBWHandler(&CreateBWHandlerRequest{
+		ObjectName:               "testObject",
+		Bucket:                   bucket,
+		BlockSize:                blockSize,
+		MaxBlocksPerFile:         10,
+		DirtyBudget:              block.NewDirtyBudget(10 * blockSize),
+		ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
+		CoalesceSize:             16,
+	})
+	require.NoError(t, err)
+	return bwh
+}
+
+func TestBufferedWriteHandlerCoalescesSmallWrites(t *testing.T) {
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.NonHierarchical)
+	bwh := newCoalescingBWHandler(t, bucket)
+
+	// The small writes are gathered until they don't fit together.
+	for i, data := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		require.NoError(t, bwh.Write([]byte(data), int64(4*i)))
+	}
+	assert.Nil(t, bwh.current)
+	assert.Equal(t, int64(16), bwh.WriteFileInfo().TotalSize)
+	require.NoError(t, bwh.Write([]byte("e"), 16))
+	require.NotNil(t, bwh.current)
+	assert.Equal(t, int64(16), bwh.current.Size())
+	// The large writes are appended right away, after the pending data.
+	require.NoError(t, bwh.Write([]byte(strings.Repeat("f", 16)), 17))
+	assert.Empty(t, bwh.pending)
+	assert.Equal(t, int64(33), bwh.current.Size())
+	require.NoError(t, bwh.Write([]byte("g"), 33))
+	// Out of order writes are still detected with pending data.
+	assert.Equal(t, ErrOutOfOrderWrite, bwh.Write([]byte("h"), 33))
+
+	obj, err := bwh.Flush()
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(34), obj.Size)
+	contents, err := storageutil.ReadObject(context.Background(), bucket, "testObject")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaabbbbccccdddde"+strings.Repeat("f", 16)+"g", string(contents))
+}
+
+func TestBufferedWriteHandlerCoalescesSmallWritesAfterTruncate(t *testing.T) {
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.NonHierarchical)
+	bwh := newCoalescingBWHandler(t, bucket)
+	require.NoError(t, bwh.Write([]byte("ab"), 0))
+	require.NoError(t, bwh.Truncate(4))
+
+	err := bwh.Write([]byte("cd"), 4)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), bwh.WriteFileInfo().TotalSize)
+	_, err = bwh.Flush()
+	require.NoError(t, err)
+	contents, err := storageutil.ReadObject(context.Background(), bucket, "testObject")
+	require.NoError(t, err)
+	assert.Equal(t, "ab\x00\x00cd", string(contents))
+}
+
+func newBudgetedBWHandler(t *testing.T, dirty *block.DirtyBudget) *BufferedWriteHandler {
+	t.Helper()
+	bwh, err := NewBWHandler(&CreateBWHandlerRequest{
+		ObjectName:               "testObject",
+		Bucket:                   fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.NonHierarchical),
+		BlockSize:                blockSize,
+		MaxBlocksPerFile:         10,
+		DirtyBudget:              dirty,
+		ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
+	})
+	require.NoError(t, err)
+	return bwh
+}
+
+func TestBufferedWriteHandlerUnlinkReleasesThePartlyFilledBlock(t *testing.T) {
+	dirty := block.NewDirtyBudget(10 * blockSize)
+	bwh := newBudgetedBWHandler(t, dirty)
+	require.NoError(t, bwh.Write(make([]byte, blockSize/2), 0))
+	require.Equal(t, int64(blockSize), dirty.Used())
+
+	bwh.Unlink()
+
+	assert.Equal(t, int64(0), dirty.Used())
+}
+
+func TestBufferedWriteHandlerDestroyReleasesThePartlyFilledBlock(t *testing.T) {
+	dirty := block.NewDirtyBudget(10 * blockSize)
+	bwh := newBudgetedBWHandler(t, dirty)
+	require.NoError(t, bwh.Write(make([]byte, blockSize/2), 0))
+	require.Equal(t, int64(blockSize), dirty.Used())
+
+	err := bwh.Destroy()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), dirty.Used())
+}
+
+func TestBufferedWriteHandlerUploadsThePartlyFilledBlockOfAnotherFileUnderPressure(t *testing.T) {
+	dirty := block.NewDirtyBudget(blockSize)
+	idle := newBudgetedBWHandler(t, dirty)
+	require.NoError(t, idle.Write(make([]byte, blockSize/2), 0))
+	bwh := newBudgetedBWHandler(t, dirty)
+
+	// The budget is held by the idle file.
+	err := bwh.Write(make([]byte, blockSize/2), 0)
+
+	require.NoError(t, err)
+	assert.Nil(t, idle.current)
+	// Once uploaded, its block is released for the next block of the other
+	// file.
+	idle.uploadHandler.AwaitBlocksUpload()
+	require.NoError(t, bwh.Write(make([]byte, blockSize), blockSize/2))
+	assert.Equal(t, int64(blockSize), dirty.Used())
+	obj, err := idle.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(blockSize/2), obj.Size)
+}
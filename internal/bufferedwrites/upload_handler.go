@@ -19,10 +19,12 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 )
 
 // UploadHandler is responsible for synchronized uploads of the filled blocks
@@ -53,6 +55,16 @@ type UploadHandler struct {
 	obj                  *gcs.Object
 	chunkTransferTimeout int64
 	blockSize            int64
+
+	// The mtime to persist in the object metadata, and the one the writer was
+	// created with. The metadata is sent when the resumable upload starts, so a
+	// later change is applied with a metadata update once the upload is
+	// finalized.
+	mtime       time.Time
+	writerMtime time.Time
+
+	// progress records the blocks queued and the bytes committed, if not nil.
+	progress ProgressRecorder
 }
 
 type CreateUploadHandlerRequest struct {
@@ -63,6 +75,7 @@ type CreateUploadHandlerRequest struct {
 	MaxBlocksPerFile         int64
 	BlockSize                int64
 	ChunkTransferTimeoutSecs int64
+	Progress                 ProgressRecorder
 }
 
 // newUploadHandler creates the UploadHandler struct.
@@ -77,6 +90,7 @@ func newUploadHandler(req *CreateUploadHandlerRequest) *UploadHandler {
 		blockSize:            req.BlockSize,
 		signalUploadFailure:  make(chan error, 1),
 		chunkTransferTimeout: req.ChunkTransferTimeoutSecs,
+		progress:             req.Progress,
 	}
 	return uh
 }
@@ -97,18 +111,30 @@ func (uh *UploadHandler) Upload(block block.Block) error {
 		go uh.uploader()
 	}
 
+	if uh.progress != nil {
+		uh.progress.BlocksPending(1)
+	}
 	uh.uploadCh <- block
 	return nil
 }
 
 // createObjectWriter creates a GCS object writer.
 func (uh *UploadHandler) createObjectWriter() (err error) {
-	req := gcs.NewCreateObjectRequest(uh.obj, uh.objectName, nil, uh.chunkTransferTimeout)
+	var mtime *time.Time
+	if !uh.mtime.IsZero() {
+		mtime = &uh.mtime
+	}
+	req := gcs.NewCreateObjectRequest(uh.obj, uh.objectName, mtime, uh.chunkTransferTimeout)
+	uh.writerMtime = uh.mtime
 	// We need a new context here, since the first writeFile() call will be complete
 	// (and context will be cancelled) by the time complete upload is done.
 	var ctx context.Context
 	ctx, uh.cancelFunc = context.WithCancel(context.Background())
-	uh.writer, err = uh.bucket.CreateObjectChunkWriter(ctx, req, int(uh.blockSize), nil)
+	var callBack func(bytesUploadedSoFar int64)
+	if uh.progress != nil {
+		callBack = uh.progress.Committed
+	}
+	uh.writer, err = uh.bucket.CreateObjectChunkWriter(ctx, req, int(uh.blockSize), callBack)
 	return
 }
 
@@ -127,8 +153,16 @@ func (uh *UploadHandler) uploader() {
 		}
 		// Put back the uploaded block on the freeBlocksChannel for re-use.
 		uh.freeBlocksCh <- currBlock
-		uh.wg.Done()
+		uh.blockDone()
+	}
+}
+
+// blockDone marks a block queued by Upload as done with.
+func (uh *UploadHandler) blockDone() {
+	if uh.progress != nil {
+		uh.progress.BlocksPending(-1)
 	}
+	uh.wg.Done()
 }
 
 // Finalize finalizes the upload.
@@ -149,7 +183,31 @@ func (uh *UploadHandler) Finalize() (*gcs.MinObject, error) {
 	if err != nil {
 		return nil, fmt.Errorf("FinalizeUpload failed for object %s: %w", uh.objectName, err)
 	}
-	return obj, nil
+
+	if uh.mtime.Equal(uh.writerMtime) {
+		return obj, nil
+	}
+	return uh.updateMtime(obj), nil
+}
+
+// updateMtime sets the mtime metadata of the finalized object to the mtime
+// received after the upload started. The contents are already persisted, so
+// a failure is only logged.
+func (uh *UploadHandler) updateMtime(obj *gcs.MinObject) *gcs.MinObject {
+	formatted := uh.mtime.UTC().Format(time.RFC3339Nano)
+	o, err := uh.bucket.UpdateObject(context.Background(), &gcs.UpdateObjectRequest{
+		Name:                       obj.Name,
+		Generation:                 obj.Generation,
+		MetaGenerationPrecondition: &obj.MetaGeneration,
+		Metadata: map[string]*string{
+			gcs.MtimeMetadataKey: &formatted,
+		},
+	})
+	if err != nil {
+		logger.Warnf("Could not persist the mtime of object %s: %v", uh.objectName, err)
+		return obj
+	}
+	return storageutil.ConvertObjToMinObject(o)
 }
 
 func (uh *UploadHandler) CancelUpload() {
@@ -180,7 +238,7 @@ func (uh *UploadHandler) Destroy() {
 			}
 			uh.freeBlocksCh <- currBlock
 			// Marking as wg.Done to ensure any waiters are unblocked.
-			uh.wg.Done()
+			uh.blockDone()
 		default:
 			// This will get executed when there are no blocks pending in uploadCh and its not closed.
 			close(uh.uploadCh)
@@ -28,7 +28,6 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"golang.org/x/sync/semaphore"
 )
 
 const (
@@ -50,7 +49,7 @@ func TestUploadHandlerTestSuite(t *testing.T) {
 func (t *UploadHandlerTest) SetupTest() {
 	t.mockBucket = new(storagemock.TestifyMockBucket)
 	var err error
-	t.blockPool, err = block.NewBlockPool(blockSize, maxBlocks, semaphore.NewWeighted(maxBlocks))
+	t.blockPool, err = block.NewBlockPool(blockSize, maxBlocks, block.NewDirtyBudget(maxBlocks*blockSize), nil)
 	require.NoError(t.T(), err)
 	t.uh = newUploadHandler(&CreateUploadHandlerRequest{
 		Object:                   nil,
@@ -163,6 +162,59 @@ func (t *UploadHandlerTest) TestFinalizeWhenFinalizeUploadFails() {
 	assert.ErrorContains(t.T(), err, "FinalizeUpload failed for object")
 }
 
+func (t *UploadHandlerTest) TestFinalizeWithNoWriterPersistsMtime() {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	t.uh.mtime = mtime
+	writer := &storagemock.Writer{}
+	t.mockBucket.On("CreateObjectChunkWriter", mock.Anything, mock.MatchedBy(func(req *gcs.CreateObjectRequest) bool {
+		return req.Metadata[gcs.MtimeMetadataKey] == mtime.Format(time.RFC3339Nano)
+	}), mock.Anything, mock.Anything).Return(writer, nil)
+	mockObj := &gcs.MinObject{}
+	t.mockBucket.On("FinalizeUpload", mock.Anything, writer).Return(mockObj, nil)
+
+	obj, err := t.uh.Finalize()
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), mockObj, obj)
+	t.mockBucket.AssertNotCalled(t.T(), "UpdateObject", mock.Anything, mock.Anything)
+}
+
+func (t *UploadHandlerTest) TestFinalizeUpdatesMtimeChangedAfterWriterCreation() {
+	t.uh.mtime = time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	writer := &storagemock.Writer{}
+	t.mockBucket.On("CreateObjectChunkWriter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(writer, nil)
+	require.NoError(t.T(), t.uh.createObjectWriter())
+	mtime := time.Date(2010, 1, 2, 3, 4, 5, 6, time.UTC)
+	t.uh.mtime = mtime
+	t.mockBucket.On("FinalizeUpload", mock.Anything, writer).Return(&gcs.MinObject{Name: "foo", Generation: 2, MetaGeneration: 1}, nil)
+	updated := &gcs.Object{Name: "foo", Generation: 2, MetaGeneration: 2}
+	t.mockBucket.On("UpdateObject", mock.Anything, mock.MatchedBy(func(req *gcs.UpdateObjectRequest) bool {
+		return req.Generation == 2 && *req.MetaGenerationPrecondition == 1 &&
+			*req.Metadata[gcs.MtimeMetadataKey] == mtime.Format(time.RFC3339Nano)
+	})).Return(updated, nil)
+
+	obj, err := t.uh.Finalize()
+
+	require.NoError(t.T(), err)
+	require.NotNil(t.T(), obj)
+	assert.Equal(t.T(), int64(2), obj.MetaGeneration)
+}
+
+func (t *UploadHandlerTest) TestFinalizeIgnoresMtimeUpdateFailure() {
+	writer := &storagemock.Writer{}
+	t.mockBucket.On("CreateObjectChunkWriter", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(writer, nil)
+	require.NoError(t.T(), t.uh.createObjectWriter())
+	t.uh.mtime = time.Date(2010, 1, 2, 3, 4, 5, 6, time.UTC)
+	mockObj := &gcs.MinObject{Name: "foo"}
+	t.mockBucket.On("FinalizeUpload", mock.Anything, writer).Return(mockObj, nil)
+	t.mockBucket.On("UpdateObject", mock.Anything, mock.Anything).Return((*gcs.Object)(nil), errors.New("taco"))
+
+	obj, err := t.uh.Finalize()
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), mockObj, obj)
+}
+
 func (t *UploadHandlerTest) TestUploadSingleBlockThrowsErrorInCopy() {
 	// Create a block with test data.
 	b, err := t.blockPool.Get()
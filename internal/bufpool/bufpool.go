@@ -0,0 +1,153 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufpool pools the buffers of the read paths, e.g. of the random
+// reads and of the downloads into the file cache, so that reading at several
+// GB/s doesn't allocate a buffer per read, whose garbage collection then
+// dominates the CPU usage of gcsfuse.
+package bufpool
+
+import (
+	"context"
+	"io"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+)
+
+const (
+	// The buffers are pooled in size classes of powers of two, from
+	// 1<<minClassShift to 1<<maxClassShift bytes. Larger buffers are allocated
+	// and left to the garbage collector.
+	minClassShift = 12
+	maxClassShift = 26
+
+	numClasses = maxClassShift - minClassShift + 1
+
+	// The size of the buffers of CopyN, the one of io.Copy.
+	copyBufferSize = 32 << 10
+)
+
+// Pool is a pool of buffers in size classes.
+type Pool struct {
+	classes [numClasses]sync.Pool
+
+	hits       atomic.Int64
+	misses     atomic.Int64
+	inUseBytes atomic.Int64
+}
+
+// Default is the pool of the read paths.
+var Default = &Pool{}
+
+// classOf returns the size class of the buffers of size bytes, and whether
+// they're pooled.
+func classOf(size int) (class int, ok bool) {
+	if size > 1<<maxClassShift {
+		return 0, false
+	}
+	if size <= 1<<minClassShift {
+		return 0, true
+	}
+	return bits.Len(uint(size-1)) - minClassShift, true
+}
+
+// Get returns a buffer of size bytes, whose contents are undefined, to be
+// given back with Put once it's not used anymore.
+func (p *Pool) Get(size int) []byte {
+	class, ok := classOf(size)
+	if !ok {
+		p.misses.Add(1)
+		return make([]byte, size)
+	}
+
+	capacity := 1 << (class + minClassShift)
+	p.inUseBytes.Add(int64(capacity))
+	if b, ok := p.classes[class].Get().(*[]byte); ok {
+		p.hits.Add(1)
+		return (*b)[:size]
+	}
+	p.misses.Add(1)
+	return make([]byte, size, capacity)
+}
+
+// Put gives back a buffer returned by Get, or nil, which is a no-op. The
+// buffer mustn't be used afterwards.
+func (p *Pool) Put(buf []byte) {
+	class, ok := classOf(cap(buf))
+	if !ok || cap(buf) != 1<<(class+minClassShift) {
+		return
+	}
+	p.inUseBytes.Add(-int64(cap(buf)))
+	buf = buf[:cap(buf)]
+	p.classes[class].Put(&buf)
+}
+
+// Stats returns the number of buffers taken from the pool which were reused
+// and which were allocated, and the bytes of the buffers in use.
+func (p *Pool) Stats() (hits, misses, inUseBytes int64) {
+	return p.hits.Load(), p.misses.Load(), p.inUseBytes.Load()
+}
+
+// Get returns a buffer of size bytes from the default pool.
+func Get(size int) []byte {
+	return Default.Get(size)
+}
+
+// Put gives back a buffer to the default pool.
+func Put(buf []byte) {
+	Default.Put(buf)
+}
+
+// CopyN copies n bytes from src to dst like io.CopyN, with a buffer of the
+// default pool instead of allocating one.
+func CopyN(dst io.Writer, src io.Reader, n int64) (written int64, err error) {
+	buf := Get(int(min(n, copyBufferSize)))
+	defer Put(buf)
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	written, err = io.CopyBuffer(dst, io.LimitReader(src, n), buf)
+	if written == n {
+		return n, nil
+	}
+	if written < n && err == nil {
+		// src stopped early; must have been EOF.
+		err = io.EOF
+	}
+	return
+}
+
+// Report records the utilization of the default pool with metricHandle every
+// interval until ctx is done.
+func Report(ctx context.Context, interval time.Duration, metricHandle common.MetricHandle) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var reportedHits, reportedMisses int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hits, misses, inUseBytes := Default.Stats()
+			metricHandle.BufferPoolGetCount(ctx, hits-reportedHits, []common.MetricAttr{{Key: common.PoolHit, Value: "true"}})
+			metricHandle.BufferPoolGetCount(ctx, misses-reportedMisses, []common.MetricAttr{{Key: common.PoolHit, Value: "false"}})
+			metricHandle.BufferPoolInUseBytes(ctx, inUseBytes, nil)
+			reportedHits, reportedMisses = hits, misses
+		}
+	}
+}
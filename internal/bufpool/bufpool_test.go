@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassOf(t *testing.T) {
+	tests := []struct {
+		size          int
+		expectedClass int
+		expectedOk    bool
+	}{
+		{size: 0, expectedClass: 0, expectedOk: true},
+		{size: 4096, expectedClass: 0, expectedOk: true},
+		{size: 4097, expectedClass: 1, expectedOk: true},
+		{size: 1 << 20, expectedClass: 8, expectedOk: true},
+		{size: 1 << 26, expectedClass: numClasses - 1, expectedOk: true},
+		{size: 1<<26 + 1, expectedOk: false},
+	}
+	for _, tc := range tests {
+		class, ok := classOf(tc.size)
+
+		assert.Equal(t, tc.expectedOk, ok, "size %d", tc.size)
+		if ok {
+			assert.Equal(t, tc.expectedClass, class, "size %d", tc.size)
+		}
+	}
+}
+
+func TestPool_GetAndPut(t *testing.T) {
+	p := &Pool{}
+
+	buf := p.Get(5000)
+
+	assert.Len(t, buf, 5000)
+	assert.Equal(t, 8192, cap(buf))
+	_, _, inUse := p.Stats()
+	assert.Equal(t, int64(8192), inUse)
+	p.Put(buf)
+	_, misses, inUse := p.Stats()
+	assert.Equal(t, int64(1), misses)
+	assert.Equal(t, int64(0), inUse)
+	// A buffer of the same class is reused, unless the garbage collector
+	// emptied the pool meanwhile.
+	buf = p.Get(6000)
+	assert.Len(t, buf, 6000)
+	hits, misses, _ := p.Stats()
+	assert.Equal(t, int64(2), hits+misses)
+}
+
+func TestPool_LargeBuffersAreNotPooled(t *testing.T) {
+	p := &Pool{}
+
+	buf := p.Get(1<<26 + 1)
+	p.Put(buf)
+
+	assert.Len(t, buf, 1<<26+1)
+	hits, misses, inUse := p.Stats()
+	assert.Equal(t, int64(0), hits)
+	assert.Equal(t, int64(1), misses)
+	assert.Equal(t, int64(0), inUse)
+}
+
+func TestPool_PutNil(t *testing.T) {
+	p := &Pool{}
+
+	p.Put(nil)
+
+	_, _, inUse := p.Stats()
+	assert.Equal(t, int64(0), inUse)
+}
+
+func TestCopyN(t *testing.T) {
+	var dst bytes.Buffer
+
+	written, err := CopyN(&dst, strings.NewReader("0123456789"), 4)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), written)
+	assert.Equal(t, "0123", dst.String())
+}
+
+func TestCopyN_ShortSource(t *testing.T) {
+	var dst bytes.Buffer
+
+	written, err := CopyN(&dst, strings.NewReader("0123"), 10)
+
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int64(4), written)
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accesstrace records the objects read during a run, and how far,
+// so that the next run can prefetch them into the file cache before they are
+// read.
+package accesstrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultMaxObjects is the number of objects recorded by default. The objects
+// first read after that are left out of the trace.
+const DefaultMaxObjects = 100000
+
+// Trace is the access trace of a run, saved as JSON.
+type Trace struct {
+	// Objects are in the order in which they were first read.
+	Objects []Object `json:"objects"`
+}
+
+// Object is an object read during a run.
+//
+// Only the offset after the last byte read is recorded, rather than the
+// ranges read, since the file cache downloads objects sequentially from their
+// start anyway.
+type Object struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+
+	// End is the offset after the last byte read.
+	End int64 `json:"end"`
+}
+
+// Recorder records the access trace of a run. It is safe for concurrent use.
+type Recorder struct {
+	maxObjects int
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	trace Trace
+
+	// The index in trace.Objects of each object, keyed by bucket and name.
+	//
+	// GUARDED_BY(mu)
+	index map[[2]string]int
+
+	// Whether objects were left out of the trace because of maxObjects.
+	//
+	// GUARDED_BY(mu)
+	truncated bool
+}
+
+// NewRecorder returns a recorder which records at most maxObjects objects.
+func NewRecorder(maxObjects int) *Recorder {
+	return &Recorder{
+		maxObjects: maxObjects,
+		index:      make(map[[2]string]int),
+	}
+}
+
+// Record records that length bytes were read at offset of the given object.
+func (r *Recorder) Record(bucket, name string, offset, length int64) {
+	if length <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{bucket, name}
+	i, ok := r.index[key]
+	if !ok {
+		if len(r.trace.Objects) >= r.maxObjects {
+			if !r.truncated {
+				r.truncated = true
+				logger.Warnf("The access trace reached %d objects, the objects read from now on aren't recorded", r.maxObjects)
+			}
+			return
+		}
+		i = len(r.trace.Objects)
+		r.index[key] = i
+		r.trace.Objects = append(r.trace.Objects, Object{Bucket: bucket, Name: name})
+	}
+	o := &r.trace.Objects[i]
+	o.End = max(o.End, offset+length)
+}
+
+// Save writes the recorded trace to the given path, replacing the previous
+// trace atomically.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	contents, err := json.Marshal(&r.trace)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("CreateTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %q: %w", f.Name(), err)
+	}
+
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("Rename: %w", err)
+	}
+	return nil
+}
+
+// Load reads the trace saved at the given path.
+func Load(path string) (*Trace, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Trace
+	if err = json.Unmarshal(contents, &t); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%q): %w", path, err)
+	}
+	return &t, nil
+}
+
+// Cache is the cache the objects are prefetched into.
+type Cache interface {
+	// Prefetch downloads the object into the cache until the given offset.
+	Prefetch(ctx context.Context, object *gcs.MinObject, bucket gcs.Bucket, offset int64) error
+}
+
+// Prefetch prefetches the objects of the trace into the cache, with at most
+// parallelism objects at a time. The objects which can't be prefetched, e.g.
+// because they were deleted since the trace was recorded, are skipped.
+func Prefetch(ctx context.Context, t *Trace, bucket func(name string) (gcs.Bucket, error), cache Cache, parallelism int) {
+	var g errgroup.Group
+	g.SetLimit(parallelism)
+	for _, o := range t.Objects {
+		if ctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			if err := prefetchObject(ctx, &o, bucket, cache); err != nil {
+				logger.Warnf("Skipping prefetch of %s/%s: %v", o.Bucket, o.Name, err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+func prefetchObject(ctx context.Context, o *Object, bucket func(name string) (gcs.Bucket, error), cache Cache) error {
+	b, err := bucket(o.Bucket)
+	if err != nil {
+		return err
+	}
+
+	minObject, _, err := b.StatObject(ctx, &gcs.StatObjectRequest{Name: o.Name})
+	if err != nil {
+		return fmt.Errorf("StatObject: %w", err)
+	}
+
+	// The object may have been replaced by a smaller one since the trace was
+	// recorded.
+	offset := min(o.End, int64(minObject.Size))
+	return cache.Prefetch(ctx, minObject, b, offset)
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesstrace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderSaveAndLoad(t *testing.T) {
+	r := NewRecorder(DefaultMaxObjects)
+	r.Record("bucket", "b", 10, 10)
+	r.Record("bucket", "a", 100, 10)
+	r.Record("bucket", "b", 0, 10)
+	r.Record("bucket", "c", 0, 0)
+	r.Record("other_bucket", "a", 0, 10)
+	path := filepath.Join(t.TempDir(), "trace.json")
+
+	require.NoError(t, r.Save(path))
+	trace, err := Load(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Object{
+		{Bucket: "bucket", Name: "b", End: 20},
+		{Bucket: "bucket", Name: "a", End: 110},
+		{Bucket: "other_bucket", Name: "a", End: 10},
+	}, trace.Objects)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestRecorderMaxObjects(t *testing.T) {
+	r := NewRecorder(2)
+	r.Record("bucket", "a", 0, 10)
+	r.Record("bucket", "b", 0, 10)
+	r.Record("bucket", "c", 0, 10)
+	// Objects already recorded are still updated.
+	r.Record("bucket", "a", 10, 10)
+	path := filepath.Join(t.TempDir(), "trace.json")
+
+	require.NoError(t, r.Save(path))
+	trace, err := Load(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Object{
+		{Bucket: "bucket", Name: "a", End: 20},
+		{Bucket: "bucket", Name: "b", End: 10},
+	}, trace.Objects)
+}
+
+func TestLoadMissingTrace(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "trace.json"))
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+type fakeCache struct {
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+func (c *fakeCache) Prefetch(_ context.Context, object *gcs.MinObject, bucket gcs.Bucket, offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offsets[bucket.Name()+"/"+object.Name] = offset
+	return nil
+}
+
+func TestPrefetch(t *testing.T) {
+	ctx := context.Background()
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(ctx, bucket, "a", make([]byte, 100))
+	require.NoError(t, err)
+	_, err = storageutil.CreateObject(ctx, bucket, "b", make([]byte, 100))
+	require.NoError(t, err)
+	trace := &Trace{Objects: []Object{
+		{Bucket: "bucket", Name: "a", End: 30},
+		// The object shrank since the trace was recorded.
+		{Bucket: "bucket", Name: "b", End: 200},
+		{Bucket: "bucket", Name: "deleted", End: 10},
+		{Bucket: "other_bucket", Name: "a", End: 10},
+	}}
+	getBucket := func(name string) (gcs.Bucket, error) {
+		if name != "bucket" {
+			return nil, fmt.Errorf("unknown bucket %q", name)
+		}
+		return bucket, nil
+	}
+	cache := &fakeCache{offsets: make(map[string]int64)}
+
+	Prefetch(ctx, trace, getBucket, cache, 2)
+
+	assert.Equal(t, map[string]int64{"bucket/a": 30, "bucket/b": 100}, cache.offsets)
+}
@@ -48,6 +48,11 @@ type FileInfo struct {
 	ObjectGeneration int64
 	Offset           uint64
 	FileSize         uint64
+
+	// ChunkChecksums are the CRC-32 checksums of the chunks of the file in
+	// cache, set once the object is fully downloaded if chunk checksums are
+	// enabled.
+	ChunkChecksums []uint32
 }
 
 func (fi FileInfo) Size() uint64 {
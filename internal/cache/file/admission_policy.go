@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"math"
+	"path"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// AdmissionPolicy decides which objects are cached in the file cache, so that
+// reading e.g. a giant scratch file doesn't evict the working set.
+type AdmissionPolicy struct {
+	minObjectSize uint64
+	maxObjectSize uint64
+
+	// includePatterns are the glob patterns of the only objects admitted, if
+	// not empty.
+	includePatterns []string
+
+	// excludePatterns are the glob patterns of the objects never admitted.
+	excludePatterns []string
+}
+
+// NewAdmissionPolicy returns the admission policy of the config, or nil if
+// it admits all the objects. The config must be valid.
+func NewAdmissionPolicy(c *cfg.FileCacheConfig) *AdmissionPolicy {
+	if c.MinObjectSizeMb == 0 && c.MaxObjectSizeMb == -1 && len(c.IncludePatterns) == 0 && len(c.ExcludePatterns) == 0 {
+		return nil
+	}
+
+	p := &AdmissionPolicy{
+		minObjectSize:   uint64(c.MinObjectSizeMb) * util.MiB,
+		maxObjectSize:   math.MaxUint64,
+		includePatterns: c.IncludePatterns,
+		excludePatterns: c.ExcludePatterns,
+	}
+	if c.MaxObjectSizeMb != -1 {
+		p.maxObjectSize = uint64(c.MaxObjectSizeMb) * util.MiB
+	}
+	return p
+}
+
+// matches returns true if the object name matches one of the patterns. A
+// pattern without a "/" matches the base name of the object, otherwise its full
+// name.
+func matches(objectName string, patterns []string) bool {
+	for _, pattern := range patterns {
+		name := objectName
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(objectName)
+		}
+		// The patterns are validated with the config.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Admits returns true if the object may be cached. A nil policy admits all the
+// objects.
+func (p *AdmissionPolicy) Admits(object *gcs.MinObject) bool {
+	if p == nil {
+		return true
+	}
+	if object.Size < p.minObjectSize || object.Size > p.maxObjectSize {
+		return false
+	}
+	if matches(object.Name, p.excludePatterns) {
+		return false
+	}
+	return len(p.includePatterns) == 0 || matches(object.Name, p.includePatterns)
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAdmissionPolicy_AdmitsAllByDefault(t *testing.T) {
+	p := NewAdmissionPolicy(&cfg.FileCacheConfig{MaxObjectSizeMb: -1})
+
+	assert.Nil(t, p)
+	assert.True(t, p.Admits(&gcs.MinObject{Name: "a/b.tmp", Size: 1 << 40}))
+}
+
+func TestAdmissionPolicy_Admits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   cfg.FileCacheConfig
+		object   gcs.MinObject
+		expected bool
+	}{
+		{
+			name:     "below_max_object_size",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: 10},
+			object:   gcs.MinObject{Name: "a", Size: 10 * util.MiB},
+			expected: true,
+		},
+		{
+			name:     "above_max_object_size",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: 10},
+			object:   gcs.MinObject{Name: "a", Size: 10*util.MiB + 1},
+			expected: false,
+		},
+		{
+			name:     "below_min_object_size",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, MinObjectSizeMb: 1},
+			object:   gcs.MinObject{Name: "a", Size: util.MiB - 1},
+			expected: false,
+		},
+		{
+			name:     "above_min_object_size",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, MinObjectSizeMb: 1},
+			object:   gcs.MinObject{Name: "a", Size: util.MiB},
+			expected: true,
+		},
+		{
+			name:     "excluded_base_name",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{"*.tmp"}},
+			object:   gcs.MinObject{Name: "dir/scratch.tmp"},
+			expected: false,
+		},
+		{
+			name:     "not_excluded",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{"*.tmp"}},
+			object:   gcs.MinObject{Name: "dir/data.tfrecord"},
+			expected: true,
+		},
+		{
+			name:     "excluded_full_name",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{"scratch/*"}},
+			object:   gcs.MinObject{Name: "scratch/a"},
+			expected: false,
+		},
+		{
+			name:     "full_name_pattern_doesnt_match_base_name",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{"scratch/*"}},
+			object:   gcs.MinObject{Name: "data/scratch/a"},
+			expected: true,
+		},
+		{
+			name:     "included",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, IncludePatterns: []string{"*.tfrecord"}},
+			object:   gcs.MinObject{Name: "dir/data.tfrecord"},
+			expected: true,
+		},
+		{
+			name:     "not_included",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, IncludePatterns: []string{"*.tfrecord"}},
+			object:   gcs.MinObject{Name: "dir/data.csv"},
+			expected: false,
+		},
+		{
+			name:     "exclusion_takes_precedence",
+			config:   cfg.FileCacheConfig{MaxObjectSizeMb: -1, IncludePatterns: []string{"*.tfrecord"}, ExcludePatterns: []string{"tmp-*"}},
+			object:   gcs.MinObject{Name: "dir/tmp-data.tfrecord"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewAdmissionPolicy(&tc.config)
+
+			assert.Equal(t, tc.expected, p.Admits(&tc.object))
+		})
+	}
+}
@@ -15,12 +15,15 @@
 package file
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufpool"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file/downloader"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
@@ -50,10 +53,21 @@ type CacheHandle struct {
 	// prevOffset stores the offset of previous cache handle read call. This is used
 	// to decide the type of read.
 	prevOffset int64
+
+	// verifiedChunks records which chunks of the file in cache matched their
+	// checksum, so that they're verified only once per handle.
+	verifiedChunks []bool
+
+	// memoryTier keeps the most recently read chunks in memory, if not nil.
+	memoryTier *MemoryTier
+
+	// diskHealth counts the failed and slow reads of the local file, and makes
+	// the handle invalid once the file cache is degraded.
+	diskHealth *DiskHealth
 }
 
 func NewCacheHandle(localFileHandle *os.File, fileDownloadJob *downloader.Job,
-	fileInfoCache *lru.Cache, cacheFileForRangeRead bool, initialOffset int64) *CacheHandle {
+	fileInfoCache *lru.Cache, cacheFileForRangeRead bool, initialOffset int64, memoryTier *MemoryTier, diskHealth *DiskHealth) *CacheHandle {
 	return &CacheHandle{
 		fileHandle:            localFileHandle,
 		fileDownloadJob:       fileDownloadJob,
@@ -61,6 +75,8 @@ func NewCacheHandle(localFileHandle *os.File, fileDownloadJob *downloader.Job,
 		cacheFileForRangeRead: cacheFileForRangeRead,
 		isSequential:          initialOffset == 0,
 		prevOffset:            initialOffset,
+		memoryTier:            memoryTier,
+		diskHealth:            diskHealth,
 	}
 }
 
@@ -73,6 +89,10 @@ func (fch *CacheHandle) validateCacheHandle() error {
 		return errors.New(util.InvalidFileInfoCacheErrMsg)
 	}
 
+	if fch.diskHealth.Degraded() {
+		return errors.New(util.CacheDegradedErrMsg)
+	}
+
 	return nil
 }
 
@@ -93,17 +113,17 @@ func (fch *CacheHandle) shouldReadFromCache(jobStatus *downloader.JobStatus, req
 
 // validateEntryInFileInfoCache checks if entry is present for a given object in
 // file info cache with same generation and at least requiredOffset.
-// It returns nil if entry is present, otherwise returns an appropriate error.
+// It returns the entry if it is present, otherwise returns an appropriate error.
 // Whether to change the order in cache while lookup is controlled via
 // changeCacheOrder.
-func (fch *CacheHandle) validateEntryInFileInfoCache(bucket gcs.Bucket, object *gcs.MinObject, requiredOffset uint64, changeCacheOrder bool) error {
+func (fch *CacheHandle) validateEntryInFileInfoCache(bucket gcs.Bucket, object *gcs.MinObject, requiredOffset uint64, changeCacheOrder bool) (data.FileInfo, error) {
 	fileInfoKey := data.FileInfoKey{
 		BucketName: bucket.Name(),
 		ObjectName: object.Name,
 	}
 	fileInfoKeyName, err := fileInfoKey.Key()
 	if err != nil {
-		return fmt.Errorf("error while creating key for bucket %s and object %s: %w", bucket.Name(), object.Name, err)
+		return data.FileInfo{}, fmt.Errorf("error while creating key for bucket %s and object %s: %w", bucket.Name(), object.Name, err)
 	}
 
 	var fileInfo lru.ValueType
@@ -114,7 +134,7 @@ func (fch *CacheHandle) validateEntryInFileInfoCache(bucket gcs.Bucket, object *
 	}
 	if fileInfo == nil {
 		err = fmt.Errorf("%v: no entry found in file info cache for key %v", util.InvalidFileInfoCacheErrMsg, fileInfoKeyName)
-		return err
+		return data.FileInfo{}, err
 	}
 
 	// The generation check below is required because it may happen that file
@@ -123,14 +143,14 @@ func (fch *CacheHandle) validateEntryInFileInfoCache(bucket gcs.Bucket, object *
 	fileInfoData := fileInfo.(data.FileInfo)
 	if fileInfoData.ObjectGeneration != object.Generation {
 		err = fmt.Errorf("%v: generation of cached object: %v is different from required generation: %v", util.InvalidFileInfoCacheErrMsg, fileInfoData.ObjectGeneration, object.Generation)
-		return err
+		return data.FileInfo{}, err
 	}
 	if fileInfoData.Offset < requiredOffset {
 		err = fmt.Errorf("%v offset of cached object: %v is less than required offset %v", util.InvalidFileInfoCacheErrMsg, fileInfoData.Offset, requiredOffset)
-		return err
+		return data.FileInfo{}, err
 	}
 
-	return nil
+	return fileInfoData, nil
 }
 
 // Read attempts to read the data from the cached location.
@@ -167,6 +187,16 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 		requiredOffset = objSize
 	}
 
+	if fch.memoryTier != nil && fch.memoryTier.Read(fileInfoKeyName(bucket, object), object.Generation, offset, dst[:requiredOffset-offset]) {
+		fch.prevOffset = offset
+		// Like for the reads from the local file, the file being read becomes the
+		// most recently used in the file info cache.
+		if _, err = fch.validateEntryInFileInfoCache(bucket, object, uint64(requiredOffset), true); err != nil {
+			return 0, false, err
+		}
+		return int(requiredOffset - offset), true, nil
+	}
+
 	// If fileDownloadJob is not nil, it's better to get status of cache file
 	// from the job itself than to use file info cache.
 	if fch.fileDownloadJob != nil {
@@ -204,7 +234,7 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 		// If fileDownloadJob is nil then it means either the job is successfully
 		// completed or failed. The offset must be equal to size of object for job
 		// to be completed.
-		err = fch.validateEntryInFileInfoCache(bucket, object, object.Size, false)
+		_, err = fch.validateEntryInFileInfoCache(bucket, object, object.Size, false)
 		if err != nil {
 			return 0, false, err
 		}
@@ -212,7 +242,9 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 	}
 
 	// We are here means, we have the data downloaded which kernel has asked for.
+	readStart := time.Now()
 	n, err = fch.fileHandle.ReadAt(dst, offset)
+	fch.diskHealth.RecordLatency(ctx, time.Since(readStart))
 	requestedNumBytes := int(requiredOffset - offset)
 	// dst buffer has fixed size of 1 MiB even when the offset is such that
 	// offset + 1 MiB > object size. In that case, io.ErrUnexpectedEOF is thrown
@@ -228,6 +260,7 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 		err = nil
 	}
 	if err != nil {
+		fch.diskHealth.RecordError(ctx, err)
 		err = fmt.Errorf("%s: while reading from %d offset of the local file: %w", util.ErrInReadingFileHandleMsg, offset, err)
 		return 0, false, err
 	}
@@ -235,14 +268,106 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 	// Look up of file being read in file info cache is required to update the LRU
 	// order on every read request from kernel i.e. with every read request from
 	// kernel, the file being read becomes most recently used.
-	err = fch.validateEntryInFileInfoCache(bucket, object, uint64(requiredOffset), true)
+	fileInfo, err := fch.validateEntryInFileInfoCache(bucket, object, uint64(requiredOffset), true)
+	if err != nil {
+		return 0, false, err
+	}
+
+	err = fch.verifyChunkChecksums(fileInfo, offset, dst[:n])
 	if err != nil {
 		return 0, false, err
 	}
 
+	if fch.memoryTier != nil {
+		fch.promoteToMemoryTier(fileInfo, offset, dst[:n])
+	}
+
 	return
 }
 
+func fileInfoKeyName(bucket gcs.Bucket, object *gcs.MinObject) string {
+	fileInfoKey := data.FileInfoKey{
+		BucketName: bucket.Name(),
+		ObjectName: object.Name,
+	}
+	// The key is valid for the objects being read.
+	keyName, _ := fileInfoKey.Key()
+	return keyName
+}
+
+// promoteToMemoryTier promotes the downloaded chunks of the file in cache which
+// overlap the given data, read at offset, to the memory tier. The chunks which
+// aren't entirely within the read data are read again from the local file.
+func (fch *CacheHandle) promoteToMemoryTier(fileInfo data.FileInfo, offset int64, readData []byte) {
+	keyName, err := fileInfo.Key.Key()
+	if err != nil {
+		return
+	}
+
+	end := offset + int64(len(readData))
+	for chunk := offset / MemoryTierChunkSize; chunk*MemoryTierChunkSize < end; chunk++ {
+		chunkStart := chunk * MemoryTierChunkSize
+		chunkEnd := min(chunkStart+MemoryTierChunkSize, int64(fileInfo.FileSize))
+		if chunkEnd > int64(fileInfo.Offset) || fch.memoryTier.Contains(keyName, fileInfo.ObjectGeneration, chunk) {
+			continue
+		}
+
+		var content []byte
+		if chunkStart >= offset && chunkEnd <= end {
+			content = bytes.Clone(readData[chunkStart-offset : chunkEnd-offset])
+		} else {
+			content = make([]byte, chunkEnd-chunkStart)
+			if n, _ := fch.fileHandle.ReadAt(content, chunkStart); n != len(content) {
+				continue
+			}
+		}
+		fch.memoryTier.Promote(keyName, fileInfo.ObjectGeneration, chunk, content)
+	}
+}
+
+// verifyChunkChecksums verifies that the chunks of the file in cache which
+// overlap the given data, read at offset, match their checksum, unless they've
+// already been verified by this handle. Nothing is verified until the checksums
+// are stored, once the object is fully downloaded.
+func (fch *CacheHandle) verifyChunkChecksums(fileInfo data.FileInfo, offset int64, readData []byte) error {
+	checksums := fileInfo.ChunkChecksums
+	if len(checksums) == 0 {
+		return nil
+	}
+	if len(fch.verifiedChunks) != len(checksums) {
+		fch.verifiedChunks = make([]bool, len(checksums))
+	}
+
+	end := offset + int64(len(readData))
+	for chunk := offset / util.ChecksumChunkSize; chunk < int64(len(checksums)) && chunk*util.ChecksumChunkSize < end; chunk++ {
+		if fch.verifiedChunks[chunk] {
+			continue
+		}
+
+		// The chunk is read again unless it's entirely within the read data.
+		chunkStart := chunk * util.ChecksumChunkSize
+		chunkEnd := min(chunkStart+util.ChecksumChunkSize, int64(fileInfo.FileSize))
+		var checksum uint32
+		if chunkStart >= offset && chunkEnd <= end {
+			checksum = util.ChunkChecksum(readData[chunkStart-offset : chunkEnd-offset])
+		} else {
+			content := bufpool.Get(int(chunkEnd - chunkStart))
+			_, err := fch.fileHandle.ReadAt(content, chunkStart)
+			checksum = util.ChunkChecksum(content)
+			bufpool.Put(content)
+			if err != nil {
+				return fmt.Errorf("%s: while reading chunk at %d offset of the local file: %w", util.ErrInReadingFileHandleMsg, chunkStart, err)
+			}
+		}
+
+		if checksum != checksums[chunk] {
+			return fmt.Errorf("%s: checksum mismatch of the chunk at %d offset of the local file", util.CorruptFileInCacheErrMsg, chunkStart)
+		}
+		fch.verifiedChunks[chunk] = true
+	}
+	return nil
+}
+
 // IsSequential returns true if the sequential read is being performed, false for
 // random read.
 func (fch *CacheHandle) IsSequential(currentOffset int64) bool {
@@ -152,7 +152,7 @@ func (cht *cacheHandleTest) SetupTest() {
 		common.NewNoopMetrics(),
 	)
 
-	cht.cacheHandle = NewCacheHandle(readLocalFileHandle, fileDownloadJob, cht.cache, false, 0)
+	cht.cacheHandle = NewCacheHandle(readLocalFileHandle, fileDownloadJob, cht.cache, false, 0, nil, nil)
 }
 
 func (cht *cacheHandleTest) TearDownTest() {
@@ -366,7 +366,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_FileInfoPresent()
 	_, err = cht.cache.Insert(fileInfoKeyName, fileInfo)
 	assert.Nil(cht.T(), err)
 
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, cht.object.Size, false)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, cht.object.Size, false)
 
 	assert.Nil(cht.T(), err)
 }
@@ -380,7 +380,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_FileInfoNotPresent
 	assert.Nil(cht.T(), err)
 
 	_ = cht.cache.Erase(fileInfoKeyName)
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, false)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, false)
 
 	expectedErr := fmt.Errorf("%v: no entry found in file info cache for key %v", util.InvalidFileInfoCacheErrMsg, fileInfoKeyName)
 	assert.True(cht.T(), strings.Contains(err.Error(), expectedErr.Error()))
@@ -402,7 +402,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_FileInfoGeneration
 	_, err = cht.cache.Insert(fileInfoKeyName, fileInfo)
 	assert.Nil(cht.T(), err)
 
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, cht.object.Size-1, true)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, cht.object.Size-1, true)
 
 	expectedErr := fmt.Errorf("%v: generation of cached object: %v is different from required generation: ", util.InvalidFileInfoCacheErrMsg, fileInfo.ObjectGeneration)
 	assert.True(cht.T(), strings.Contains(err.Error(), expectedErr.Error()))
@@ -424,7 +424,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_FileInfoOffsetLess
 	_, err = cht.cache.Insert(fileInfoKeyName, fileInfo)
 	assert.Nil(cht.T(), err)
 
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 11, true)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 11, true)
 
 	assert.NotNil(cht.T(), err)
 	expectedErr := fmt.Errorf("%v offset of cached object: %v is less than required offset %v", util.InvalidFileInfoCacheErrMsg, 10, 11)
@@ -454,7 +454,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_changeCacheOrderIs
 
 	// Because changeCacheOrder is true, the entry corresponding to cht.object.Size
 	// should come on top
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, true)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, true)
 
 	assert.Nil(cht.T(), err)
 	// Inserting new entry should evict the newObjectName
@@ -498,7 +498,7 @@ func (cht *cacheHandleTest) Test_validateEntryInFileInfoCache_changeCacheOrderIs
 	assert.Equal(cht.T(), 0, len(evictedEntries))
 
 	// Because changeCacheOrder is false, the new object entry should remain on top.
-	err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, false)
+	_, err = cht.cacheHandle.validateEntryInFileInfoCache(cht.bucket, cht.object, 0, false)
 
 	assert.Nil(cht.T(), err)
 	// Inserting new entry should evict the entry corresponding to cht.object.
@@ -582,6 +582,63 @@ func (cht *cacheHandleTest) Test_Read_WithNilFileDownloadJobAndCacheHit() {
 	assert.True(cht.T(), cacheHit)
 }
 
+// downloadWithChunkChecksums downloads the complete object via job and stores
+// the checksums of its chunks in the file info cache.
+func (cht *cacheHandleTest) downloadWithChunkChecksums() {
+	_, err := cht.cacheHandle.fileDownloadJob.Download(context.Background(), int64(cht.object.Size), true)
+	assert.Nil(cht.T(), err)
+	checksums, err := util.CalculateFileChunkChecksums(context.Background(), cht.fileSpec.Path)
+	assert.Nil(cht.T(), err)
+	fileInfoKey := data.FileInfoKey{BucketName: storage.TestBucketName, ObjectName: TestObjectName}
+	fileInfoKeyName, err := fileInfoKey.Key()
+	assert.Nil(cht.T(), err)
+	err = cht.cache.UpdateWithoutChangingOrder(fileInfoKeyName, data.FileInfo{
+		Key:              fileInfoKey,
+		ObjectGeneration: cht.object.Generation,
+		FileSize:         cht.object.Size,
+		Offset:           cht.object.Size,
+		ChunkChecksums:   checksums,
+	})
+	assert.Nil(cht.T(), err)
+	cht.cacheHandle.isSequential = false
+	cht.cacheHandle.fileDownloadJob = nil
+}
+
+func (cht *cacheHandleTest) Test_Read_WithChunkChecksums() {
+	cht.downloadWithChunkChecksums()
+	dst := make([]byte, 100)
+	offset := int64(3*util.ChecksumChunkSize + 1)
+
+	n, cacheHit, err := cht.cacheHandle.Read(context.Background(), cht.bucket, cht.object, offset, dst)
+
+	assert.Nil(cht.T(), err)
+	assert.Equal(cht.T(), 100, n)
+	assert.True(cht.T(), cacheHit)
+	assert.True(cht.T(), cht.cacheHandle.verifiedChunks[3])
+	assert.False(cht.T(), cht.cacheHandle.verifiedChunks[4])
+	cht.verifyContentRead(offset, dst)
+}
+
+func (cht *cacheHandleTest) Test_Read_WithChunkChecksums_CorruptChunk() {
+	cht.downloadWithChunkChecksums()
+	// Corrupt the chunk outside of the read range.
+	file, err := os.OpenFile(cht.fileSpec.Path, os.O_WRONLY, 0)
+	assert.Nil(cht.T(), err)
+	_, err = file.WriteAt([]byte("bitrot"), 4*util.ChecksumChunkSize-10)
+	assert.Nil(cht.T(), err)
+	assert.Nil(cht.T(), file.Close())
+	dst := make([]byte, 100)
+
+	_, _, err = cht.cacheHandle.Read(context.Background(), cht.bucket, cht.object, 3*util.ChecksumChunkSize+1, dst)
+
+	assert.NotNil(cht.T(), err)
+	assert.True(cht.T(), strings.Contains(err.Error(), util.CorruptFileInCacheErrMsg))
+	assert.True(cht.T(), util.IsCacheHandleInvalid(err))
+	// The other chunks are still served.
+	_, _, err = cht.cacheHandle.Read(context.Background(), cht.bucket, cht.object, 0, dst)
+	assert.Nil(cht.T(), err)
+}
+
 func (cht *cacheHandleTest) Test_RandomRead() {
 	dst := make([]byte, ReadContentSize)
 	offset := int64(cht.object.Size - ReadContentSize)
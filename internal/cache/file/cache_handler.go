@@ -15,9 +15,12 @@
 package file
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file/downloader"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
@@ -49,18 +52,35 @@ type CacheHandler struct {
 	// dirPerm parameter specifies the permission of cache directory.
 	dirPerm os.FileMode
 
+	metricHandle common.MetricHandle
+
+	// memoryTier keeps the most recently read chunks of the files in cache in
+	// memory, if not nil.
+	memoryTier *MemoryTier
+
+	// admissionPolicy decides which objects are cached, all of them if nil.
+	admissionPolicy *AdmissionPolicy
+
+	// diskHealth degrades the file cache, i.e. makes it bypassed, after too
+	// many failed or slow IOs on the cache directory, never if nil.
+	diskHealth *DiskHealth
+
 	// mu guards the handling of insertion into and eviction from file cache.
 	mu locker.Locker
 }
 
-func NewCacheHandler(fileInfoCache *lru.Cache, jobManager *downloader.JobManager, cacheDir string, filePerm os.FileMode, dirPerm os.FileMode) *CacheHandler {
+func NewCacheHandler(fileInfoCache *lru.Cache, jobManager *downloader.JobManager, cacheDir string, filePerm os.FileMode, dirPerm os.FileMode, metricHandle common.MetricHandle, memoryTier *MemoryTier, admissionPolicy *AdmissionPolicy, diskHealth *DiskHealth) *CacheHandler {
 	return &CacheHandler{
-		fileInfoCache: fileInfoCache,
-		jobManager:    jobManager,
-		cacheDir:      cacheDir,
-		filePerm:      filePerm,
-		dirPerm:       dirPerm,
-		mu:            locker.New("FileCacheHandler", func() {}),
+		fileInfoCache:   fileInfoCache,
+		jobManager:      jobManager,
+		cacheDir:        cacheDir,
+		filePerm:        filePerm,
+		dirPerm:         dirPerm,
+		metricHandle:    metricHandle,
+		memoryTier:      memoryTier,
+		admissionPolicy: admissionPolicy,
+		diskHealth:      diskHealth,
+		mu:              locker.New("FileCacheHandler", func() {}),
 	}
 }
 
@@ -76,7 +96,7 @@ func (chr *CacheHandler) createLocalFileReadHandle(objectName string, bucketName
 
 // cleanUpEvictedFile is a utility method called for the evicted/deleted fileInfo.
 // As part of execution, it (a) stops and removes the download job (b) truncates
-// and deletes the file in cache.
+// and deletes the file in cache (c) erases its chunks from the memory tier.
 func (chr *CacheHandler) cleanUpEvictedFile(fileInfo *data.FileInfo) error {
 	key := fileInfo.Key
 	_, err := key.Key()
@@ -85,6 +105,9 @@ func (chr *CacheHandler) cleanUpEvictedFile(fileInfo *data.FileInfo) error {
 	}
 
 	chr.jobManager.InvalidateAndRemoveJob(key.ObjectName, key.BucketName)
+	if chr.memoryTier != nil {
+		chr.memoryTier.Erase(fileInfo)
+	}
 
 	localFilePath := util.GetDownloadPath(chr.cacheDir, util.GetObjectPath(key.BucketName, key.ObjectName))
 	err = util.TruncateAndRemoveFile(localFilePath)
@@ -99,6 +122,28 @@ func (chr *CacheHandler) cleanUpEvictedFile(fileInfo *data.FileInfo) error {
 	return nil
 }
 
+// cleanUpEvictedFiles cleans up the evicted entries, logging the errors.
+func (chr *CacheHandler) cleanUpEvictedFiles(evictedValues []lru.ValueType) {
+	for _, val := range evictedValues {
+		fileInfo := val.(data.FileInfo)
+		if err := chr.cleanUpEvictedFile(&fileInfo); err != nil {
+			logger.Warnf("Failed to clean up the evicted file of %s: %v", fileInfo.Key.ObjectName, err)
+		}
+	}
+}
+
+// ReclaimDiskSpace evicts the least recently used files of the cache until
+// their sizes sum up to at least n bytes, or the cache is empty. It's the
+// reclaimer of the file cache in the disk budget.
+//
+// Acquires and releases LOCK(CacheHandler.mu)
+func (chr *CacheHandler) ReclaimDiskSpace(n uint64) {
+	chr.mu.Lock()
+	defer chr.mu.Unlock()
+
+	chr.cleanUpEvictedFiles(chr.fileInfoCache.EvictLeastRecentlyUsed(n))
+}
+
 // addFileInfoEntryAndCreateDownloadJob adds data.FileInfo entry for the given
 // object and bucket in the file info cache and creates download job if they do
 // not already exist. It also cleans up for entries that are evicted at the time
@@ -142,8 +187,12 @@ func (chr *CacheHandler) addFileInfoEntryAndCreateDownloadJob(object *gcs.MinObj
 		existingJob := chr.jobManager.GetJob(object.Name, bucket.Name())
 		shouldInvalidate := (existingJob == nil) && (fileInfoData.Offset < object.Size)
 		if (!shouldInvalidate) && (existingJob != nil) {
-			existingJobStatus := existingJob.GetStatus().Name
-			shouldInvalidate = (existingJobStatus == downloader.Failed) || (existingJobStatus == downloader.Invalid)
+			existingJobStatus := existingJob.GetStatus()
+			shouldInvalidate = (existingJobStatus.Name == downloader.Failed) || (existingJobStatus.Name == downloader.Invalid)
+			if existingJobStatus.Name == downloader.Failed {
+				// The job may have failed to write the cache file.
+				chr.diskHealth.RecordError(context.Background(), existingJobStatus.Err)
+			}
 		}
 		if (fileInfoData.ObjectGeneration != object.Generation) || shouldInvalidate {
 			erasedVal := chr.fileInfoCache.Erase(fileInfoKeyName)
@@ -168,6 +217,9 @@ func (chr *CacheHandler) addFileInfoEntryAndCreateDownloadJob(object *gcs.MinObj
 
 		evictedValues, err := chr.fileInfoCache.Insert(fileInfoKeyName, fileInfo)
 		if err != nil {
+			// Entries may have been evicted for the disk budget before the new
+			// one turned out not to fit in it.
+			chr.cleanUpEvictedFiles(evictedValues)
 			return fmt.Errorf("addFileInfoEntryAndCreateDownloadJob: while inserting into the cache: %w", err)
 		}
 		// Create download job for new entry added to cache.
@@ -196,9 +248,20 @@ func (chr *CacheHandler) addFileInfoEntryAndCreateDownloadJob(object *gcs.MinObj
 // Note: It returns nil if cacheForRangeRead is set to False, initialOffset is
 // non-zero (i.e. random read) and entry for file doesn't already exist in
 // fileInfoCache then no need to create file in cache.
+// It also returns nil if the object isn't admitted by the admission policy, or
+// the file cache is degraded because of disk errors. The disk errors are
+// returned along with util.DiskErrorInCacheErrMsg, so that the reads fall back
+// to GCS rather than fail.
 //
 // Acquires and releases LOCK(CacheHandler.mu)
 func (chr *CacheHandler) GetCacheHandle(object *gcs.MinObject, bucket gcs.Bucket, cacheForRangeRead bool, initialOffset int64) (*CacheHandle, error) {
+	if !chr.admissionPolicy.Admits(object) {
+		return nil, fmt.Errorf("GetCacheHandle: %s", util.ObjectNotAdmittedErrMsg)
+	}
+	if chr.diskHealth.Degraded() {
+		return nil, fmt.Errorf("GetCacheHandle: %s", util.CacheDegradedErrMsg)
+	}
+
 	chr.mu.Lock()
 	defer chr.mu.Unlock()
 
@@ -223,15 +286,57 @@ func (chr *CacheHandler) GetCacheHandle(object *gcs.MinObject, bucket gcs.Bucket
 
 	err := chr.addFileInfoEntryAndCreateDownloadJob(object, bucket)
 	if err != nil {
+		if chr.diskHealth.RecordError(context.Background(), err) {
+			return nil, fmt.Errorf("GetCacheHandle: %s: while adding the entry in the cache: %w", util.DiskErrorInCacheErrMsg, err)
+		}
 		return nil, fmt.Errorf("GetCacheHandle: while adding the entry in the cache: %w", err)
 	}
 
 	localFileReadHandle, err := chr.createLocalFileReadHandle(object.Name, bucket.Name())
 	if err != nil {
+		if chr.diskHealth.RecordError(context.Background(), err) {
+			return nil, fmt.Errorf("GetCacheHandle: %s: while creating local-file read handle: %w", util.DiskErrorInCacheErrMsg, err)
+		}
 		return nil, fmt.Errorf("GetCacheHandle: while creating local-file read handle: %w", err)
 	}
 
-	return NewCacheHandle(localFileReadHandle, chr.jobManager.GetJob(object.Name, bucket.Name()), chr.fileInfoCache, cacheForRangeRead, initialOffset), nil
+	return NewCacheHandle(localFileReadHandle, chr.jobManager.GetJob(object.Name, bucket.Name()), chr.fileInfoCache, cacheForRangeRead, initialOffset, chr.memoryTier, chr.diskHealth), nil
+}
+
+// Prefetch creates an entry in fileInfoCache for the object if it does not
+// already exist, and waits until its download job has downloaded it until the
+// given offset, or the context is cancelled. Objects not admitted by the
+// admission policy aren't prefetched, nor any object once the file cache is
+// degraded.
+//
+// Acquires and releases LOCK(CacheHandler.mu)
+func (chr *CacheHandler) Prefetch(ctx context.Context, object *gcs.MinObject, bucket gcs.Bucket, offset int64) error {
+	if !chr.admissionPolicy.Admits(object) || chr.diskHealth.Degraded() {
+		return nil
+	}
+
+	chr.mu.Lock()
+	err := chr.addFileInfoEntryAndCreateDownloadJob(object, bucket)
+	job := chr.jobManager.GetJob(object.Name, bucket.Name())
+	chr.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Prefetch: while adding the entry in the cache: %w", err)
+	}
+
+	// The object is already in the cache.
+	if job == nil {
+		return nil
+	}
+
+	jobStatus, err := job.Download(ctx, offset, true)
+	if err != nil {
+		return fmt.Errorf("Prefetch: while downloading: %w", err)
+	}
+	if jobStatus.Name == downloader.Failed || jobStatus.Name == downloader.Invalid {
+		chr.diskHealth.RecordError(ctx, jobStatus.Err)
+		return fmt.Errorf("Prefetch: download job is %s: %v", jobStatus.Name, jobStatus.Err)
+	}
+	return nil
 }
 
 // InvalidateCache removes the file entry from the fileInfoCache and performs clean
@@ -262,6 +367,95 @@ func (chr *CacheHandler) InvalidateCache(objectName string, bucketName string) e
 	return nil
 }
 
+// evictCorruptFile evicts the file in cache of the given file info cache entry,
+// one of whose chunks didn't match its checksum, detected by read or scrub.
+//
+// Requires Lock(chr.mu)
+func (chr *CacheHandler) evictCorruptFile(ctx context.Context, fileInfoKeyName string, fileInfo data.FileInfo, detectedBy string) error {
+	logger.Warnf("Evicting %s:/%s from the file cache: a chunk of the file doesn't match its checksum", fileInfo.Key.BucketName, fileInfo.Key.ObjectName)
+	chr.metricHandle.FileCacheCorruptFileCount(ctx, 1, []common.MetricAttr{{Key: common.DetectedBy, Value: detectedBy}})
+
+	erasedVal := chr.fileInfoCache.Erase(fileInfoKeyName)
+	if erasedVal != nil {
+		erasedFileInfo := erasedVal.(data.FileInfo)
+		err := chr.cleanUpEvictedFile(&erasedFileInfo)
+		if err != nil {
+			return fmt.Errorf("evictCorruptFile: while performing clean-up for evicted %s object, error: %w", erasedFileInfo.Key.ObjectName, err)
+		}
+	}
+	return nil
+}
+
+// EvictCorruptFile evicts the file in cache of the given object after one of
+// its chunks didn't match its checksum when it was read, unless the file has
+// been replaced in the meantime.
+//
+// Acquires and releases LOCK(CacheHandler.mu)
+func (chr *CacheHandler) EvictCorruptFile(ctx context.Context, object *gcs.MinObject, bucket gcs.Bucket) error {
+	fileInfoKey := data.FileInfoKey{
+		BucketName: bucket.Name(),
+		ObjectName: object.Name,
+	}
+	fileInfoKeyName, err := fileInfoKey.Key()
+	if err != nil {
+		return fmt.Errorf("EvictCorruptFile: while creating key: %v", fileInfoKeyName)
+	}
+
+	chr.mu.Lock()
+	defer chr.mu.Unlock()
+
+	fileInfo := chr.fileInfoCache.LookUpWithoutChangingOrder(fileInfoKeyName)
+	if fileInfo == nil || fileInfo.(data.FileInfo).ObjectGeneration != object.Generation {
+		return nil
+	}
+	return chr.evictCorruptFile(ctx, fileInfoKeyName, fileInfo.(data.FileInfo), "read")
+}
+
+// Scrub verifies the chunks of all the fully downloaded files in cache, and
+// evicts the files with chunks which don't match their checksum, so that the
+// corruption of the local storage is detected before the files are read.
+//
+// Acquires and releases LOCK(CacheHandler.mu) for each evicted file.
+func (chr *CacheHandler) Scrub(ctx context.Context) error {
+	for _, val := range chr.fileInfoCache.Values() {
+		fileInfo := val.(data.FileInfo)
+		if len(fileInfo.ChunkChecksums) == 0 {
+			continue
+		}
+
+		filePath := util.GetDownloadPath(chr.cacheDir, util.GetObjectPath(fileInfo.Key.BucketName, fileInfo.Key.ObjectName))
+		checksums, err := util.CalculateFileChunkChecksums(ctx, filePath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			// The file may have been evicted in the meantime.
+			logger.Tracef("Scrub: skipping %s: %v", filePath, err)
+			continue
+		}
+		if slices.Equal(checksums, fileInfo.ChunkChecksums) {
+			continue
+		}
+
+		fileInfoKeyName, err := fileInfo.Key.Key()
+		if err != nil {
+			return fmt.Errorf("Scrub: while creating key: %w", err)
+		}
+		chr.mu.Lock()
+		// The file may have been replaced while it was verified.
+		current := chr.fileInfoCache.LookUpWithoutChangingOrder(fileInfoKeyName)
+		if current != nil && current.(data.FileInfo).ObjectGeneration == fileInfo.ObjectGeneration &&
+			slices.Equal(current.(data.FileInfo).ChunkChecksums, fileInfo.ChunkChecksums) {
+			err = chr.evictCorruptFile(ctx, fileInfoKeyName, fileInfo, "scrub")
+		}
+		chr.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("Scrub: %w", err)
+		}
+	}
+	return nil
+}
+
 // Destroy destroys the job manager (i.e. invalidate all the jobs).
 // Note: This method is expected to be called at the time of unmounting and
 // because file info cache is in-memory, it is not required to destroy it.
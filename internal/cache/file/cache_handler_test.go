@@ -15,6 +15,7 @@
 package file
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"os"
@@ -35,6 +36,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/operations"
+	"github.com/jacobsa/timeutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -81,7 +83,7 @@ func initializeCacheHandlerTestArgs(t *testing.T, fileCacheConfig *cfg.FileCache
 		util.DefaultDirPerm, cacheDir, DefaultSequentialReadSizeMb, fileCacheConfig, common.NewNoopMetrics())
 
 	// Mocked cached handler object.
-	cacheHandler := NewCacheHandler(cache, jobManager, cacheDir, util.DefaultFilePerm, util.DefaultDirPerm)
+	cacheHandler := NewCacheHandler(cache, jobManager, cacheDir, util.DefaultFilePerm, util.DefaultDirPerm, common.NewNoopMetrics(), nil, nil, nil)
 
 	// Follow consistency, local-cache file, entry in fileInfo cache and job should exist initially.
 	fileInfoKeyName := addTestFileInfoEntryInCache(t, cache, object, storage.TestBucketName)
@@ -674,6 +676,165 @@ func Test_GetCacheHandle_ConcurrentDifferentFiles(t *testing.T) {
 	assert.False(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
 }
 
+func Test_Prefetch_WhenFileInfoAndJobAreAlreadyPresent(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableCrc: true}, cacheDir)
+	existingJob := getDownloadJobForTestObject(t, chTestArgs)
+
+	err := chTestArgs.cacheHandler.Prefetch(context.Background(), chTestArgs.object, chTestArgs.bucket, int64(chTestArgs.object.Size))
+
+	assert.NoError(t, err)
+	// The job completes once the CRC of the downloaded file is validated.
+	assert.Eventually(t, func() bool { return existingJob.GetStatus().Name == downloader.Completed }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(chTestArgs.object.Size), existingJob.GetStatus().Offset)
+}
+
+func Test_Prefetch_WhenFileInfoAndJobAreNotPresent(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableCrc: true}, cacheDir)
+	minObject := createObject(t, chTestArgs.bucket, "object_1", []byte("content of object_1"))
+	require.False(t, isEntryInFileInfoCache(t, chTestArgs.cache, minObject.Name, chTestArgs.bucket.Name()))
+
+	err := chTestArgs.cacheHandler.Prefetch(context.Background(), minObject, chTestArgs.bucket, int64(minObject.Size))
+
+	assert.NoError(t, err)
+	assert.True(t, isEntryInFileInfoCache(t, chTestArgs.cache, minObject.Name, chTestArgs.bucket.Name()))
+	downloadPath := util.GetDownloadPath(cacheDir, util.GetObjectPath(chTestArgs.bucket.Name(), minObject.Name))
+	assert.Eventually(t, func() bool {
+		contents, err := os.ReadFile(downloadPath)
+		return err == nil && string(contents) == "content of object_1"
+	}, time.Second, time.Millisecond)
+	// Prefetching an object which is already in the cache is a no-op.
+	assert.NoError(t, chTestArgs.cacheHandler.Prefetch(context.Background(), minObject, chTestArgs.bucket, int64(minObject.Size)))
+}
+
+func Test_Read_WithMemoryTier(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	chTestArgs.cacheHandler.memoryTier = NewMemoryTier(4 * MemoryTierChunkSize)
+	cacheHandle, err := chTestArgs.cacheHandler.GetCacheHandle(chTestArgs.object, chTestArgs.bucket, false, 0)
+	require.NoError(t, err)
+	defer cacheHandle.Close()
+	ctx := context.Background()
+	buf := make([]byte, MemoryTierChunkSize)
+	// The chunk is read from the local file and promoted to the memory tier.
+	_, cacheHit, err := cacheHandle.Read(ctx, chTestArgs.bucket, chTestArgs.object, 0, buf)
+	require.NoError(t, err)
+	require.False(t, cacheHit)
+	expected := bytes.Clone(buf)
+	corruptFile(t, chTestArgs.downloadPath, 3)
+
+	n, cacheHit, err := cacheHandle.Read(ctx, chTestArgs.bucket, chTestArgs.object, 0, buf)
+
+	// The corrupted local file isn't read anymore.
+	require.NoError(t, err)
+	assert.True(t, cacheHit)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, expected, buf)
+}
+
+func Test_InvalidateCache_ErasesMemoryTier(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	chTestArgs.cacheHandler.memoryTier = NewMemoryTier(4 * MemoryTierChunkSize)
+	cacheHandle, err := chTestArgs.cacheHandler.GetCacheHandle(chTestArgs.object, chTestArgs.bucket, false, 0)
+	require.NoError(t, err)
+	defer cacheHandle.Close()
+	_, _, err = cacheHandle.Read(context.Background(), chTestArgs.bucket, chTestArgs.object, 0, make([]byte, MemoryTierChunkSize))
+	require.NoError(t, err)
+	require.True(t, chTestArgs.cacheHandler.memoryTier.Contains(chTestArgs.fileInfoKeyName, chTestArgs.object.Generation, 0))
+
+	err = chTestArgs.cacheHandler.InvalidateCache(chTestArgs.object.Name, chTestArgs.bucket.Name())
+
+	assert.NoError(t, err)
+	assert.False(t, chTestArgs.cacheHandler.memoryTier.Contains(chTestArgs.fileInfoKeyName, chTestArgs.object.Generation, 0))
+}
+
+// prefetchWithChunkChecksums downloads the test object into the cache and
+// waits for the checksums of its chunks to be stored.
+func prefetchWithChunkChecksums(t *testing.T, chTestArgs *cacheHandlerTestArgs) {
+	t.Helper()
+	err := chTestArgs.cacheHandler.Prefetch(context.Background(), chTestArgs.object, chTestArgs.bucket, int64(chTestArgs.object.Size))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		fileInfo := chTestArgs.cache.LookUpWithoutChangingOrder(chTestArgs.fileInfoKeyName)
+		return fileInfo != nil && len(fileInfo.(data.FileInfo).ChunkChecksums) == TestObjectSize/util.ChecksumChunkSize
+	}, 5*time.Second, time.Millisecond)
+}
+
+func corruptFile(t *testing.T, filePath string, offset int64) {
+	t.Helper()
+	file, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte("bitrot"), offset)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func Test_Scrub_KeepsIntactFile(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableChunkChecksums: true}, cacheDir)
+	prefetchWithChunkChecksums(t, chTestArgs)
+
+	err := chTestArgs.cacheHandler.Scrub(context.Background())
+
+	assert.NoError(t, err)
+	assert.True(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+	assert.True(t, doesFileExist(t, chTestArgs.downloadPath))
+}
+
+func Test_Scrub_EvictsCorruptFile(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableChunkChecksums: true}, cacheDir)
+	prefetchWithChunkChecksums(t, chTestArgs)
+	corruptFile(t, chTestArgs.downloadPath, 5*util.ChecksumChunkSize+3)
+
+	err := chTestArgs.cacheHandler.Scrub(context.Background())
+
+	assert.NoError(t, err)
+	assert.False(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+	assert.False(t, doesFileExist(t, chTestArgs.downloadPath))
+}
+
+func Test_Scrub_SkipsFilesWithoutChecksums(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	existingJob := getDownloadJobForTestObject(t, chTestArgs)
+	_, err := existingJob.Download(context.Background(), int64(chTestArgs.object.Size), true)
+	require.NoError(t, err)
+	corruptFile(t, chTestArgs.downloadPath, 3)
+
+	err = chTestArgs.cacheHandler.Scrub(context.Background())
+
+	assert.NoError(t, err)
+	assert.True(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+}
+
+func Test_EvictCorruptFile(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableChunkChecksums: true}, cacheDir)
+	prefetchWithChunkChecksums(t, chTestArgs)
+
+	err := chTestArgs.cacheHandler.EvictCorruptFile(context.Background(), chTestArgs.object, chTestArgs.bucket)
+
+	assert.NoError(t, err)
+	assert.False(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+	assert.False(t, doesFileExist(t, chTestArgs.downloadPath))
+}
+
+func Test_EvictCorruptFile_WhenCacheHasDifferentGeneration(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableChunkChecksums: true}, cacheDir)
+	prefetchWithChunkChecksums(t, chTestArgs)
+	newerObject := *chTestArgs.object
+	newerObject.Generation++
+
+	err := chTestArgs.cacheHandler.EvictCorruptFile(context.Background(), &newerObject, chTestArgs.bucket)
+
+	assert.NoError(t, err)
+	assert.True(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+}
+
 func Test_InvalidateCache_WhenAlreadyInCache(t *testing.T) {
 	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
 	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{EnableCrc: true}, cacheDir)
@@ -1041,3 +1202,62 @@ func Test_Destroy(t *testing.T) {
 		})
 	}
 }
+
+func Test_GetCacheHandle_WhenObjectIsNotAdmitted(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	chTestArgs.cacheHandler.admissionPolicy = NewAdmissionPolicy(&cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{chTestArgs.object.Name}})
+
+	cacheHandle, err := chTestArgs.cacheHandler.GetCacheHandle(chTestArgs.object, chTestArgs.bucket, true, 0)
+
+	assert.Nil(t, cacheHandle)
+	assert.ErrorContains(t, err, util.ObjectNotAdmittedErrMsg)
+}
+
+func Test_Prefetch_WhenObjectIsNotAdmitted(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	chTestArgs.cacheHandler.admissionPolicy = NewAdmissionPolicy(&cfg.FileCacheConfig{MaxObjectSizeMb: -1, ExcludePatterns: []string{"*.tmp"}})
+	object := createObject(t, chTestArgs.bucket, "scratch.tmp", []byte("scratch"))
+
+	err := chTestArgs.cacheHandler.Prefetch(context.Background(), object, chTestArgs.bucket, int64(object.Size))
+
+	assert.NoError(t, err)
+	assert.Nil(t, chTestArgs.jobManager.GetJob(object.Name, chTestArgs.bucket.Name()))
+	fileInfoKeyName, err := data.FileInfoKey{BucketName: chTestArgs.bucket.Name(), ObjectName: object.Name}.Key()
+	require.NoError(t, err)
+	assert.Nil(t, chTestArgs.cache.LookUpWithoutChangingOrder(fileInfoKeyName))
+}
+
+func Test_ReclaimDiskSpace(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	existingJob := getDownloadJobForTestObject(t, chTestArgs)
+
+	chTestArgs.cacheHandler.ReclaimDiskSpace(1)
+
+	assert.Equal(t, downloader.Invalid, existingJob.GetStatus().Name)
+	assert.False(t, doesFileExist(t, chTestArgs.downloadPath))
+	assert.False(t, isEntryInFileInfoCache(t, chTestArgs.cache, chTestArgs.object.Name, chTestArgs.bucket.Name()))
+}
+
+func Test_GetCacheHandle_WhenDiskErrorsDegradeTheCache(t *testing.T) {
+	cacheDir := path.Join(os.Getenv("HOME"), "CacheHandlerTest/dir")
+	chTestArgs := initializeCacheHandlerTestArgs(t, &cfg.FileCacheConfig{}, cacheDir)
+	chTestArgs.cacheHandler.diskHealth = NewDiskHealth(1, 0, timeutil.RealClock(), common.NewNoopMetrics())
+	// Replace the directory of the file in cache with a file, failing the IOs
+	// on the file with ENOTDIR.
+	bucketDir := path.Dir(chTestArgs.downloadPath)
+	require.NoError(t, os.RemoveAll(bucketDir))
+	require.NoError(t, os.WriteFile(bucketDir, []byte("taco"), util.DefaultFilePerm))
+
+	cacheHandle, err := chTestArgs.cacheHandler.GetCacheHandle(chTestArgs.object, chTestArgs.bucket, true, 0)
+
+	assert.Nil(t, cacheHandle)
+	assert.ErrorContains(t, err, util.DiskErrorInCacheErrMsg)
+	assert.True(t, chTestArgs.cacheHandler.diskHealth.Degraded())
+	cacheHandle, err = chTestArgs.cacheHandler.GetCacheHandle(chTestArgs.object, chTestArgs.bucket, true, 0)
+	assert.Nil(t, cacheHandle)
+	assert.ErrorContains(t, err, util.CacheDegradedErrMsg)
+	assert.NoError(t, chTestArgs.cacheHandler.Prefetch(context.Background(), chTestArgs.object, chTestArgs.bucket, int64(chTestArgs.object.Size)))
+}
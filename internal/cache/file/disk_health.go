@@ -0,0 +1,139 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/timeutil"
+)
+
+// diskErrorWindow is the period within which max-disk-errors errors degrade
+// the file cache.
+const diskErrorWindow = time.Minute
+
+// The types of the disk errors annotating the disk error count metric.
+const (
+	ioError = "io_error"
+	slowIO  = "slow_io"
+)
+
+// DiskHealth counts the failed and slow IOs on the cache directory, and
+// degrades the file cache once maxErrors of them happen within
+// diskErrorWindow. A degraded file cache is bypassed, i.e. the reads are
+// served from GCS, until gcsfuse is restarted, so that a failing local SSD
+// doesn't fail the reads with EIO as if GCS did.
+//
+// A nil *DiskHealth never degrades the file cache.
+type DiskHealth struct {
+	// maxErrors is the number of errors within diskErrorWindow degrading the
+	// file cache, 0 means the file cache is never degraded.
+	maxErrors int64
+
+	// slowIOThreshold is the latency above which an IO counts as an error, 0
+	// means only the failed IOs count.
+	slowIOThreshold time.Duration
+
+	clock        timeutil.Clock
+	metricHandle common.MetricHandle
+
+	degraded atomic.Bool
+
+	mu sync.Mutex
+
+	// The times of the errors within the last diskErrorWindow.
+	//
+	// GUARDED_BY(mu)
+	errorTimes []time.Time
+}
+
+func NewDiskHealth(maxErrors int64, slowIOThreshold time.Duration, clock timeutil.Clock, metricHandle common.MetricHandle) *DiskHealth {
+	metricHandle.FileCacheDegraded(context.Background(), 0, nil)
+	return &DiskHealth{
+		maxErrors:       maxErrors,
+		slowIOThreshold: slowIOThreshold,
+		clock:           clock,
+		metricHandle:    metricHandle,
+	}
+}
+
+// Degraded returns true once the file cache is to be bypassed.
+func (h *DiskHealth) Degraded() bool {
+	return h != nil && h.degraded.Load()
+}
+
+// isDiskError returns true if the error was returned by an IO on the cache
+// directory. The missing files are evicted ones rather than disk errors.
+func isDiskError(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) &&
+		!errors.Is(err, os.ErrNotExist) &&
+		!errors.Is(err, os.ErrClosed)
+}
+
+// RecordError counts the supplied error of an IO on the cache directory, and
+// returns true if it's a disk error. Other errors are ignored.
+func (h *DiskHealth) RecordError(ctx context.Context, err error) bool {
+	if h == nil || !isDiskError(err) {
+		return false
+	}
+	h.record(ctx, ioError, err)
+	return true
+}
+
+// RecordLatency counts an IO on the cache directory slower than the slow IO
+// threshold as an error.
+func (h *DiskHealth) RecordLatency(ctx context.Context, latency time.Duration) {
+	if h == nil || h.slowIOThreshold == 0 || latency <= h.slowIOThreshold {
+		return
+	}
+	h.record(ctx, slowIO, nil)
+}
+
+// LOCKS_EXCLUDED(h.mu)
+func (h *DiskHealth) record(ctx context.Context, errorType string, err error) {
+	h.metricHandle.FileCacheDiskErrorCount(ctx, 1, []common.MetricAttr{{Key: common.DiskErrorType, Value: errorType}})
+	if h.maxErrors == 0 || h.Degraded() {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	i := 0
+	for i < len(h.errorTimes) && now.Sub(h.errorTimes[i]) >= diskErrorWindow {
+		i++
+	}
+	h.errorTimes = append(h.errorTimes[i:], now)
+	if int64(len(h.errorTimes)) < h.maxErrors || h.degraded.Swap(true) {
+		return
+	}
+
+	h.errorTimes = nil
+	h.metricHandle.FileCacheDegraded(ctx, 1, nil)
+	if err != nil {
+		logger.Errorf("Bypassing the file cache until gcsfuse is restarted: %d failed or slow IOs on the cache directory within %v, the last one: %v", h.maxErrors, diskErrorWindow, err)
+	} else {
+		logger.Errorf("Bypassing the file cache until gcsfuse is restarted: %d failed or slow IOs on the cache directory within %v, the last one slower than %v", h.maxErrors, diskErrorWindow, h.slowIOThreshold)
+	}
+}
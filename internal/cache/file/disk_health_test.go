@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// diskHealthMetricHandle keeps the disk error counts by type and the last
+// recorded degraded value.
+type diskHealthMetricHandle struct {
+	common.MetricHandle
+	diskErrors map[string]int64
+	degraded   int64
+}
+
+func (m *diskHealthMetricHandle) FileCacheDiskErrorCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.diskErrors[attrs[0].Value] += inc
+}
+
+func (m *diskHealthMetricHandle) FileCacheDegraded(_ context.Context, value int64, _ []common.MetricAttr) {
+	m.degraded = value
+}
+
+func newTestDiskHealth(maxErrors int64, slowIOThreshold time.Duration) (*DiskHealth, *timeutil.SimulatedClock, *diskHealthMetricHandle) {
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	metricHandle := &diskHealthMetricHandle{
+		MetricHandle: common.NewNoopMetrics(),
+		diskErrors:   make(map[string]int64),
+	}
+	return NewDiskHealth(maxErrors, slowIOThreshold, clock, metricHandle), clock, metricHandle
+}
+
+func ioErr() error {
+	return fmt.Errorf("while reading: %w", &os.PathError{Op: "read", Path: "/cache/foo", Err: syscall.EIO})
+}
+
+func TestDiskHealth_DegradesAfterMaxErrorsWithinWindow(t *testing.T) {
+	h, clock, metricHandle := newTestDiskHealth(3, 0)
+
+	assert.True(t, h.RecordError(context.Background(), ioErr()))
+	clock.AdvanceTime(diskErrorWindow / 2)
+	h.RecordError(context.Background(), ioErr())
+	assert.False(t, h.Degraded())
+	assert.Equal(t, int64(0), metricHandle.degraded)
+	h.RecordError(context.Background(), ioErr())
+
+	assert.True(t, h.Degraded())
+	assert.Equal(t, int64(1), metricHandle.degraded)
+	assert.Equal(t, int64(3), metricHandle.diskErrors[ioError])
+}
+
+func TestDiskHealth_ErrorsOutsideWindowDontDegrade(t *testing.T) {
+	h, clock, metricHandle := newTestDiskHealth(2, 0)
+
+	for i := 0; i < 5; i++ {
+		h.RecordError(context.Background(), ioErr())
+		clock.AdvanceTime(diskErrorWindow)
+	}
+
+	assert.False(t, h.Degraded())
+	assert.Equal(t, int64(5), metricHandle.diskErrors[ioError])
+}
+
+func TestDiskHealth_IgnoresOtherErrors(t *testing.T) {
+	h, _, metricHandle := newTestDiskHealth(1, 0)
+
+	// A missing file was evicted rather than lost by the disk.
+	assert.False(t, h.RecordError(context.Background(), &os.PathError{Op: "open", Path: "/cache/foo", Err: syscall.ENOENT}))
+	assert.False(t, h.RecordError(context.Background(), errors.New("googleapi: Error 503")))
+	assert.False(t, h.RecordError(context.Background(), nil))
+
+	assert.False(t, h.Degraded())
+	assert.Empty(t, metricHandle.diskErrors)
+}
+
+func TestDiskHealth_CountsSlowIOs(t *testing.T) {
+	h, _, metricHandle := newTestDiskHealth(2, 100*time.Millisecond)
+
+	h.RecordLatency(context.Background(), 100*time.Millisecond)
+	h.RecordLatency(context.Background(), time.Second)
+	assert.False(t, h.Degraded())
+	h.RecordLatency(context.Background(), time.Second)
+
+	assert.True(t, h.Degraded())
+	assert.Equal(t, int64(2), metricHandle.diskErrors[slowIO])
+}
+
+func TestDiskHealth_NeverDegradesWithoutMaxErrors(t *testing.T) {
+	h, _, metricHandle := newTestDiskHealth(0, time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		h.RecordError(context.Background(), ioErr())
+		h.RecordLatency(context.Background(), time.Second)
+	}
+
+	assert.False(t, h.Degraded())
+	assert.Equal(t, int64(10), metricHandle.diskErrors[ioError])
+	assert.Equal(t, int64(10), metricHandle.diskErrors[slowIO])
+}
+
+func TestDiskHealth_Nil(t *testing.T) {
+	var h *DiskHealth
+
+	assert.False(t, h.RecordError(context.Background(), ioErr()))
+	h.RecordLatency(context.Background(), time.Hour)
+
+	assert.False(t, h.Degraded())
+}
@@ -27,13 +27,19 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufpool"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	cacheutil "github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"golang.org/x/sync/semaphore"
 )
@@ -50,6 +56,18 @@ const (
 
 const ReadChunkSize = 8 * cacheutil.MiB
 
+// tracerName is the name of the tracer of the spans of the downloads.
+const tracerName = "cloud.google.com/gcsfuse"
+
+// parallelDownloadsFeature is the kill switch of the parallel downloads, with
+// which the downloads started afterwards are sequential.
+var parallelDownloadsFeature = featureflag.Register("parallel-downloads", "Download the objects into the file cache with parallel range reads (--file-cache-enable-parallel-downloads).")
+
+// Max number of times a download stream which failed mid-object is reopened at
+// the failing offset, without downloading anything in between, before the job
+// fails.
+const maxDownloadResumesWithoutProgress = 3
+
 // Job downloads the requested object from GCS into the specified local file
 // path with given permissions and ownership.
 type Job struct {
@@ -82,6 +100,10 @@ type Job struct {
 	cancelCtx  context.Context
 	cancelFunc context.CancelFunc
 
+	// span traces the async download, as a child of the span of the op which
+	// started it. It's non-nil only while the async job is running.
+	span trace.Span
+
 	// doneCh for waiting for cancellation of async download in progress.
 	doneCh chan struct{}
 
@@ -135,7 +157,8 @@ func NewJob(
 		removeJobCallback:    removeJobCallback,
 		fileCacheConfig:      fileCacheConfig,
 		maxParallelismSem:    maxParallelismSem,
-		metricsHandle:        metricHandle,
+		// The downloads are recorded in the GCS metrics of the bucket.
+		metricsHandle: common.MetricHandleOf(bucket, metricHandle),
 	}
 	job.mu = locker.New("Job-"+fileSpec.Path, job.checkInvariants)
 	job.init()
@@ -301,6 +324,9 @@ func (job *Job) downloadObjectToFile(cacheFile *os.File) (err error) {
 	end = int64(job.object.Size)
 	sequentialReadSize = int64(job.sequentialReadSizeMb) * cacheutil.MiB
 
+	// The number of times the reader failed since it last returned data.
+	failures := 0
+
 	// Each iteration of this for loop, reads ReadChunkSize size of range of the
 	// backing object from reader into the file handle and updates the file info
 	// cache. In case, reader is not present for reading, it creates a
@@ -330,14 +356,29 @@ func (job *Job) downloadObjectToFile(cacheFile *os.File) (err error) {
 
 		// Copy the contents from NewReader to cache file.
 		offsetWriter := io.NewOffsetWriter(cacheFile, start)
-		_, err = io.CopyN(offsetWriter, newReader, maxRead)
+		var written int64
+		written, err = bufpool.CopyN(offsetWriter, newReader, maxRead)
+		start += written
 		if err != nil {
-			err = fmt.Errorf("downloadObjectToFile: error at the time of copying content to cache file %w", err)
-			return err
-		}
-
-		start += maxRead
-		if start == newReaderLimit {
+			// The stream died mid-object, e.g. because the connection was reset.
+			// Instead of failing the job, which discards what was downloaded,
+			// resume with a new reader starting at the failing offset, unless the
+			// job was cancelled or the new readers keep failing without returning
+			// anything.
+			if closeErr := newReader.Close(); closeErr != nil {
+				logger.Warnf("Job:%p (%s:/%s) error while closing reader: %v", job, job.bucket.Name(), job.object.Name, closeErr)
+			}
+			newReader = nil
+			if written > 0 {
+				failures = 0
+			}
+			failures++
+			if job.cancelCtx.Err() != nil || failures > maxDownloadResumesWithoutProgress {
+				err = fmt.Errorf("downloadObjectToFile: error at the time of copying content to cache file %w", err)
+				return err
+			}
+			logger.Warnf("Job:%p (%s:/%s) resuming the download at offset %d after: %v", job, job.bucket.Name(), job.object.Name, start, err)
+		} else if start == newReaderLimit {
 			// Reader is closed after the data has been read and the error from closure
 			// is not reported as failure of async job, similar to how it's done for
 			// foreground reads: https://github.com/GoogleCloudPlatform/gcsfuse/blob/master/internal/gcsx/random_reader.go#L298.
@@ -373,20 +414,27 @@ func (job *Job) cleanUpDownloadAsyncJob() {
 		job.removeJobCallback()
 		job.removeJobCallback = nil
 	}
-	job.cancelCtx, job.cancelFunc = nil, nil
+	if job.span != nil {
+		if job.status.Err != nil {
+			job.span.RecordError(job.status.Err)
+			job.span.SetStatus(codes.Error, job.status.Err.Error())
+		}
+		job.span.End()
+	}
+	job.cancelCtx, job.cancelFunc, job.span = nil, nil, nil
 	job.mu.Unlock()
 }
 
 // createCacheFile is a helper function which creates file in cache using
 // appropriate open file flags.
-func (job *Job) createCacheFile() (*os.File, error) {
+func (job *Job) createCacheFile(parallel bool) (*os.File, error) {
 	// Create, open and truncate cache file for writing object into it.
 	openFileFlags := os.O_TRUNC | os.O_WRONLY
 	var cacheFile *os.File
 	var err error
 	// Try using O_DIRECT while opening file when parallel downloads are enabled
 	// and O_DIRECT use is not disabled.
-	if job.fileCacheConfig.EnableParallelDownloads && job.fileCacheConfig.EnableODirect {
+	if parallel && job.fileCacheConfig.EnableODirect {
 		cacheFile, err = cacheutil.CreateFile(job.fileSpec, openFileFlags|syscall.O_DIRECT)
 		if errors.Is(err, fs.ErrInvalid) || errors.Is(err, syscall.EINVAL) {
 			logger.Warnf("downloadObjectAsync: failure in opening file with O_DIRECT, falling back to without O_DIRECT")
@@ -408,7 +456,10 @@ func (job *Job) downloadObjectAsync() {
 	// Cleanup the async job in all cases - completion/failure/invalidation.
 	defer job.cleanUpDownloadAsyncJob()
 
-	cacheFile, err := job.createCacheFile()
+	// Decide once whether to download in parallel, as the kill switch of the
+	// parallel downloads can be flipped meanwhile.
+	parallel := job.IsParallelDownloadsEnabled()
+	cacheFile, err := job.createCacheFile(parallel)
 	if err != nil {
 		err = fmt.Errorf("downloadObjectAsync: error in creating cache file: %w", err)
 		job.handleError(err)
@@ -424,7 +475,7 @@ func (job *Job) downloadObjectAsync() {
 
 	// Both parallel and non-parallel download functions support cancellation in
 	// case of job's cancellation.
-	if job.fileCacheConfig.EnableParallelDownloads {
+	if parallel {
 		err = job.parallelDownloadObjectToFile(cacheFile)
 	} else {
 		err = job.downloadObjectToFile(cacheFile)
@@ -459,6 +510,16 @@ func (job *Job) downloadObjectAsync() {
 		return
 	}
 
+	err = job.storeChunkChecksums()
+	if err != nil {
+		if strings.Contains(err.Error(), lru.EntryNotExistErrMsg) {
+			job.updateStatusAndNotifySubscribers(Invalid, err)
+			return
+		}
+		job.handleError(err)
+		return
+	}
+
 	job.updateStatusAndNotifySubscribers(Completed, err)
 }
 
@@ -480,13 +541,20 @@ func (job *Job) Download(ctx context.Context, offset int64, waitForDownload bool
 		defer job.mu.Unlock()
 		return job.status, nil
 	} else if job.status.Name == NotStarted {
-		// Start the async download
+		// Start the async download. It outlives the op starting it, so it's
+		// traced in a span of its own, which the span of the op is the parent of.
 		job.status.Name = Downloading
-		job.cancelCtx, job.cancelFunc = context.WithCancel(context.Background())
+		spanCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+		spanCtx, job.span = otel.Tracer(tracerName).Start(spanCtx, "DownloadObject",
+			trace.WithAttributes(attribute.String("object", job.object.Name)))
+		job.cancelCtx, job.cancelFunc = context.WithCancel(spanCtx)
 		go job.downloadObjectAsync()
 	} else if job.status.Name == Failed || job.status.Name == Invalid || job.status.Offset >= offset {
 		defer job.mu.Unlock()
 		return job.status, nil
+	} else if sc := trace.SpanContextFromContext(ctx); job.span != nil && sc.IsValid() && !sc.Equal(job.span.SpanContext()) {
+		// The op is served by the download started by another op.
+		trace.SpanFromContext(ctx).AddLink(trace.Link{SpanContext: job.span.SpanContext()})
 	}
 
 	if !waitForDownload {
@@ -518,10 +586,51 @@ func (job *Job) GetStatus() JobStatus {
 	return job.status
 }
 
+// storeChunkChecksums calculates the checksums of the chunks of the downloaded
+// file and stores them in its file info cache entry, so that the chunks are
+// verified when they're read.
+//
+// Acquires and releases LOCK(job.mu)
+func (job *Job) storeChunkChecksums() error {
+	if !job.fileCacheConfig.EnableChunkChecksums {
+		return nil
+	}
+
+	checksums, err := cacheutil.CalculateFileChunkChecksums(job.cancelCtx, job.fileSpec.Path)
+	if err != nil {
+		return fmt.Errorf("storeChunkChecksums: while calculating checksums: %w", err)
+	}
+
+	fileInfoKey := data.FileInfoKey{
+		BucketName: job.bucket.Name(),
+		ObjectName: job.object.Name,
+	}
+	fileInfoKeyName, err := fileInfoKey.Key()
+	if err != nil {
+		return fmt.Errorf("storeChunkChecksums: error while creating fileInfoKeyName for bucket %s and object %s %w",
+			fileInfoKey.BucketName, fileInfoKey.ObjectName, err)
+	}
+
+	updatedFileInfo := data.FileInfo{
+		Key: fileInfoKey, ObjectGeneration: job.object.Generation,
+		FileSize: job.object.Size, Offset: job.object.Size,
+		ChunkChecksums: checksums,
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	err = job.fileInfoCache.UpdateWithoutChangingOrder(fileInfoKeyName, updatedFileInfo)
+	if err != nil {
+		return fmt.Errorf("storeChunkChecksums: error while updating checksums in fileInfoCache %s: %w", updatedFileInfo.Key, err)
+	}
+	return nil
+}
+
 // Compares CRC32 of the downloaded file with the CRC32 from GCS object metadata.
 // In case of mismatch deletes the file and corresponding entry from file cache.
 func (job *Job) validateCRC() (err error) {
-	if !job.fileCacheConfig.EnableCrc {
+	// The objects of CMEK buckets don't have a CRC32C.
+	if !job.fileCacheConfig.EnableCrc || job.object.CRC32C == nil {
 		return
 	}
 
@@ -537,6 +646,7 @@ func (job *Job) validateCRC() (err error) {
 
 	// If the checksum doesn't match there is an error in downloading the object contents.
 	// Delete the file and corresponding key from fileInfoCache.
+	job.metricsHandle.GCSReadChecksumMismatchCount(job.cancelCtx, 1, nil)
 	err = fmt.Errorf("checksum mismatch detected. Actual: %d, expected: %d", crc32Val, *job.object.CRC32C)
 	fileInfoKey := data.FileInfoKey{
 		BucketName: job.bucket.Name(),
@@ -573,7 +683,7 @@ func (job *Job) handleError(err error) {
 }
 
 func (job *Job) IsParallelDownloadsEnabled() bool {
-	if job.fileCacheConfig != nil && job.fileCacheConfig.EnableParallelDownloads {
+	if job.fileCacheConfig != nil && job.fileCacheConfig.EnableParallelDownloads && parallelDownloadsFeature.Enabled() {
 		return true
 	}
 	return false
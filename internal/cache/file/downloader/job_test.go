@@ -15,10 +15,12 @@
 package downloader
 
 import (
+	"bytes"
 	"container/list"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path"
@@ -26,16 +28,23 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"testing/iotest"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	testutil "github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -392,6 +401,71 @@ func (dt *downloaderTest) Test_downloadObjectToFile_CtxCancelled() {
 	AssertTrue(errors.Is(err, context.Canceled), fmt.Sprintf("didn't get context canceled error: %v", err))
 }
 
+// resettingBucket serves the reads of content with streams which are reset
+// after every resetAfter bytes, and records where they start.
+type resettingBucket struct {
+	gcs.Bucket
+	content    []byte
+	resetAfter uint64
+	starts     []uint64
+}
+
+func (b *resettingBucket) NewReader(_ context.Context, req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	b.starts = append(b.starts, req.Range.Start)
+	end := min(req.Range.Start+b.resetAfter, req.Range.Limit)
+	r := io.Reader(bytes.NewReader(b.content[req.Range.Start:end]))
+	if end < req.Range.Limit {
+		r = io.MultiReader(r, iotest.ErrReader(errors.New("connection reset")))
+	}
+	return io.NopCloser(r), nil
+}
+
+func (dt *downloaderTest) Test_downloadObjectToFile_ResumesAtFailingOffset() {
+	objectName := "path/in/gcs/reset.txt"
+	objectSize := 10 * util.MiB
+	objectContent := testutil.GenerateRandomBytes(objectSize)
+	dt.initJobTest(objectName, objectContent, DefaultSequentialReadSizeMb, uint64(2*objectSize), func() {})
+	bucket := &resettingBucket{Bucket: dt.bucket, content: objectContent, resetAfter: 3 * util.MiB}
+	dt.job.bucket = bucket
+	dt.job.cancelCtx, dt.job.cancelFunc = context.WithCancel(context.Background())
+	file, err := util.CreateFile(data.FileSpec{Path: dt.job.fileSpec.Path,
+		FilePerm: os.FileMode(0600), DirPerm: os.FileMode(0700)}, os.O_TRUNC|os.O_RDWR)
+	AssertEq(nil, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	err = dt.job.downloadObjectToFile(file)
+
+	AssertEq(nil, err)
+	ExpectTrue(reflect.DeepEqual([]uint64{0, 3 * util.MiB, 6 * util.MiB, 9 * util.MiB}, bucket.starts))
+	dt.job.mu.Lock()
+	defer dt.job.mu.Unlock()
+	dt.verifyFile(objectContent)
+	dt.verifyFileInfoEntry(uint64(objectSize))
+}
+
+func (dt *downloaderTest) Test_downloadObjectToFile_FailsWithoutProgress() {
+	objectName := "path/in/gcs/reset.txt"
+	objectSize := util.MiB
+	objectContent := testutil.GenerateRandomBytes(objectSize)
+	dt.initJobTest(objectName, objectContent, DefaultSequentialReadSizeMb, uint64(2*objectSize), func() {})
+	bucket := &resettingBucket{Bucket: dt.bucket, content: objectContent, resetAfter: 0}
+	dt.job.bucket = bucket
+	dt.job.cancelCtx, dt.job.cancelFunc = context.WithCancel(context.Background())
+	file, err := util.CreateFile(data.FileSpec{Path: dt.job.fileSpec.Path,
+		FilePerm: os.FileMode(0600), DirPerm: os.FileMode(0700)}, os.O_TRUNC|os.O_RDWR)
+	AssertEq(nil, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	err = dt.job.downloadObjectToFile(file)
+
+	ExpectThat(err, Error(HasSubstr("connection reset")))
+	ExpectEq(maxDownloadResumesWithoutProgress+1, len(bucket.starts))
+}
+
 // Note: We can't test Test_downloadObjectAsync_MoreThanSequentialReadSize as
 // the fake storage bucket/server in the testing environment doesn't support
 // reading ranges (start and limit in NewReader call)
@@ -528,6 +602,43 @@ func (dt *downloaderTest) Test_Download_WhenAlreadyDownloading() {
 	dt.verifyFileInfoEntry(uint64(jobStatus.Offset))
 }
 
+func (dt *downloaderTest) Test_Download_TracedInSpanOfOp() {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(tracerProvider)
+	objectName := "path/in/gcs/foo.txt"
+	objectSize := 50 * util.MiB
+	objectContent := testutil.GenerateRandomBytes(objectSize)
+	dt.initJobTest(objectName, objectContent, DefaultSequentialReadSizeMb, uint64(2*objectSize), func() {})
+	tracer := otel.Tracer("test")
+	startingCtx, startingOp := tracer.Start(context.Background(), "ReadFile")
+	waitingCtx, waitingOp := tracer.Start(context.Background(), "ReadFile")
+
+	_, err := dt.job.Download(startingCtx, 1, false)
+	AssertEq(nil, err)
+	_, err = dt.job.Download(waitingCtx, int64(objectSize), true)
+	AssertEq(nil, err)
+	startingOp.End()
+	waitingOp.End()
+
+	// The download is traced as a child of the op starting it, and linked from
+	// the ops waiting for it.
+	var download, waiting sdktrace.ReadOnlySpan
+	for _, s := range recorder.Started() {
+		switch {
+		case s.Name() == "DownloadObject":
+			download = s
+		case s.SpanContext().Equal(waitingOp.SpanContext()):
+			waiting = s
+		}
+	}
+	AssertNe(nil, download)
+	ExpectTrue(startingOp.SpanContext().Equal(download.Parent()))
+	AssertEq(1, len(waiting.Links()))
+	ExpectTrue(download.SpanContext().Equal(waiting.Links()[0].SpanContext))
+}
+
 func (dt *downloaderTest) Test_Download_WhenAlreadyCompleted() {
 	objectName := "path/in/gcs/foo.txt"
 	objectSize := 16 * util.MiB
@@ -1020,11 +1131,24 @@ func (dt *downloaderTest) Test_When_Parallel_Download_Is_Disabled() {
 	AssertFalse(result)
 }
 
+func (dt *downloaderTest) Test_When_Parallel_Download_Is_Killed() {
+	//Arrange - initJobTest is being called in setup of downloader.go
+	dt.job.fileCacheConfig.EnableParallelDownloads = true
+	AssertEq(nil, featureflag.Set("parallel-downloads", false))
+	defer func() {
+		AssertEq(nil, featureflag.Set("parallel-downloads", true))
+	}()
+
+	result := dt.job.IsParallelDownloadsEnabled()
+
+	AssertFalse(result)
+}
+
 func (dt *downloaderTest) Test_createCacheFile_WhenNonParallelDownloads() {
 	//Arrange - initJobTest is being called in setup of downloader.go
 	dt.job.fileCacheConfig.EnableParallelDownloads = false
 
-	cacheFile, err := dt.job.createCacheFile()
+	cacheFile, err := dt.job.createCacheFile(false)
 
 	AssertEq(nil, err)
 	defer func() {
@@ -1036,7 +1160,7 @@ func (dt *downloaderTest) Test_createCacheFile_WhenParallelDownloads() {
 	//Arrange - initJobTest is being called in setup of downloader.go
 	dt.job.fileCacheConfig.EnableParallelDownloads = true
 
-	cacheFile, err := dt.job.createCacheFile()
+	cacheFile, err := dt.job.createCacheFile(true)
 
 	AssertEq(nil, err)
 	defer func() {
@@ -1049,7 +1173,7 @@ func (dt *downloaderTest) Test_createCacheFile_WhenParallelDownloadsEnabledAndOD
 	dt.job.fileCacheConfig.EnableParallelDownloads = true
 	dt.job.fileCacheConfig.EnableODirect = false
 
-	cacheFile, err := dt.job.createCacheFile()
+	cacheFile, err := dt.job.createCacheFile(true)
 
 	AssertEq(nil, err)
 	defer func() {
@@ -22,6 +22,7 @@ import (
 	"os"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufpool"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	cacheutil "github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
@@ -65,7 +66,7 @@ func (job *Job) downloadRange(ctx context.Context, dstWriter io.Writer, start, e
 	// Use standard copy function if O_DIRECT is disabled and memory aligned
 	// buffer otherwise.
 	if !job.fileCacheConfig.EnableODirect {
-		_, err = io.CopyN(dstWriter, newReader, end-start)
+		_, err = bufpool.CopyN(dstWriter, newReader, end-start)
 	} else {
 		_, err = cacheutil.CopyUsingMemoryAlignedBuffer(ctx, newReader, dstWriter, end-start,
 			job.fileCacheConfig.WriteBufferSize)
@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
+)
+
+// MemoryTierChunkSize is the size of the chunks of the files in cache which are
+// kept in the memory tier.
+const MemoryTierChunkSize = util.MiB
+
+// memoryChunk is the content of a chunk of a file in cache.
+type memoryChunk []byte
+
+func (c memoryChunk) Size() uint64 {
+	return uint64(len(c))
+}
+
+// MemoryTier keeps the most recently read chunks of the files in cache in
+// memory, in front of the cache directory. The chunks are promoted to the
+// memory tier when they're read from the cache directory, and demoted, i.e.
+// only left in the cache directory, when the memory tier is full. The files
+// evicted from the cache directory are erased from the memory tier.
+//
+// The chunks are those of a given generation of the objects, so they never
+// need to be invalidated. It is safe for concurrent use.
+type MemoryTier struct {
+	chunks *lru.Cache
+}
+
+// NewMemoryTier returns a MemoryTier of at most maxSize bytes.
+func NewMemoryTier(maxSize uint64) *MemoryTier {
+	return &MemoryTier{
+		chunks: lru.NewCache(maxSize),
+	}
+}
+
+// memoryChunkKey returns the key of a chunk of the given generation of the
+// file. The numbers are first, so that the keys can't be ambiguous.
+func memoryChunkKey(fileInfoKeyName string, generation int64, chunk int64) string {
+	return fmt.Sprintf("%d:%d:%s", generation, chunk, fileInfoKeyName)
+}
+
+func numMemoryChunks(fileSize uint64) int64 {
+	return int64((fileSize + MemoryTierChunkSize - 1) / MemoryTierChunkSize)
+}
+
+// Read copies the data at offset of the given generation of the file to dst,
+// if all the chunks it overlaps are in the memory tier.
+func (mt *MemoryTier) Read(fileInfoKeyName string, generation int64, offset int64, dst []byte) bool {
+	end := offset + int64(len(dst))
+	var chunks []memoryChunk
+	for chunk := offset / MemoryTierChunkSize; chunk*MemoryTierChunkSize < end; chunk++ {
+		content := mt.chunks.LookUp(memoryChunkKey(fileInfoKeyName, generation, chunk))
+		if content == nil {
+			return false
+		}
+		chunks = append(chunks, content.(memoryChunk))
+	}
+
+	n := 0
+	for i, content := range chunks {
+		chunkStart := (offset/MemoryTierChunkSize + int64(i)) * MemoryTierChunkSize
+		start := max(offset-chunkStart, 0)
+		if start >= int64(len(content)) {
+			return false
+		}
+		n += copy(dst[n:], content[start:])
+	}
+	return n == len(dst)
+}
+
+// Contains returns true if the chunk of the given generation of the file is in
+// the memory tier.
+func (mt *MemoryTier) Contains(fileInfoKeyName string, generation int64, chunk int64) bool {
+	return mt.chunks.LookUpWithoutChangingOrder(memoryChunkKey(fileInfoKeyName, generation, chunk)) != nil
+}
+
+// Promote adds the content of the chunk of the given generation of the file
+// to the memory tier, demoting the least recently read chunks if it's full.
+// The content must not be modified afterwards.
+func (mt *MemoryTier) Promote(fileInfoKeyName string, generation int64, chunk int64, content []byte) {
+	// The only error is for chunks larger than the memory tier, which aren't
+	// promoted.
+	_, _ = mt.chunks.Insert(memoryChunkKey(fileInfoKeyName, generation, chunk), memoryChunk(content))
+}
+
+// Erase erases the chunks of the file from the memory tier.
+func (mt *MemoryTier) Erase(fileInfo *data.FileInfo) {
+	fileInfoKeyName, err := fileInfo.Key.Key()
+	if err != nil {
+		return
+	}
+	for chunk := int64(0); chunk < numMemoryChunks(fileInfo.FileSize); chunk++ {
+		mt.chunks.Erase(memoryChunkKey(fileInfoKeyName, fileInfo.ObjectGeneration, chunk))
+	}
+}
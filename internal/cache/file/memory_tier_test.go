@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMemoryTierFileInfoKey = data.FileInfoKey{BucketName: "bucket", ObjectName: "object"}
+
+func testMemoryTierKeyName(t *testing.T) string {
+	t.Helper()
+	keyName, err := testMemoryTierFileInfoKey.Key()
+	require.NoError(t, err)
+	return keyName
+}
+
+// promoteTestChunks promotes the chunks of a file of the given size, whose
+// bytes are their offset modulo 251, and returns its content.
+func promoteTestChunks(t *testing.T, mt *MemoryTier, generation int64, size int64) []byte {
+	t.Helper()
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	for chunk := int64(0); chunk < numMemoryChunks(uint64(size)); chunk++ {
+		start := chunk * MemoryTierChunkSize
+		mt.Promote(testMemoryTierKeyName(t), generation, chunk, bytes.Clone(content[start:min(start+MemoryTierChunkSize, size)]))
+	}
+	return content
+}
+
+func TestMemoryTier_ReadAcrossChunks(t *testing.T) {
+	mt := NewMemoryTier(4 * MemoryTierChunkSize)
+	content := promoteTestChunks(t, mt, 1, 2*MemoryTierChunkSize+10)
+	dst := make([]byte, MemoryTierChunkSize+10)
+
+	ok := mt.Read(testMemoryTierKeyName(t), 1, MemoryTierChunkSize-5, dst)
+
+	require.True(t, ok)
+	assert.Equal(t, content[MemoryTierChunkSize-5:2*MemoryTierChunkSize+5], dst)
+}
+
+func TestMemoryTier_ReadLastChunk(t *testing.T) {
+	mt := NewMemoryTier(4 * MemoryTierChunkSize)
+	content := promoteTestChunks(t, mt, 1, MemoryTierChunkSize+10)
+	dst := make([]byte, 10)
+
+	ok := mt.Read(testMemoryTierKeyName(t), 1, MemoryTierChunkSize, dst)
+
+	require.True(t, ok)
+	assert.Equal(t, content[MemoryTierChunkSize:], dst)
+	// The data beyond the end of the file isn't in the memory tier.
+	assert.False(t, mt.Read(testMemoryTierKeyName(t), 1, MemoryTierChunkSize, make([]byte, 11)))
+}
+
+func TestMemoryTier_ReadOtherGeneration(t *testing.T) {
+	mt := NewMemoryTier(4 * MemoryTierChunkSize)
+	promoteTestChunks(t, mt, 1, MemoryTierChunkSize)
+
+	assert.False(t, mt.Read(testMemoryTierKeyName(t), 2, 0, make([]byte, 10)))
+}
+
+func TestMemoryTier_DemotesLeastRecentlyReadChunks(t *testing.T) {
+	mt := NewMemoryTier(2 * MemoryTierChunkSize)
+	promoteTestChunks(t, mt, 1, 2*MemoryTierChunkSize)
+	// The first chunk becomes the most recently read.
+	require.True(t, mt.Read(testMemoryTierKeyName(t), 1, 0, make([]byte, 10)))
+
+	mt.Promote(testMemoryTierKeyName(t), 2, 0, make([]byte, MemoryTierChunkSize))
+
+	assert.True(t, mt.Contains(testMemoryTierKeyName(t), 1, 0))
+	assert.False(t, mt.Contains(testMemoryTierKeyName(t), 1, 1))
+	assert.True(t, mt.Contains(testMemoryTierKeyName(t), 2, 0))
+}
+
+func TestMemoryTier_Erase(t *testing.T) {
+	mt := NewMemoryTier(4 * MemoryTierChunkSize)
+	promoteTestChunks(t, mt, 1, 2*MemoryTierChunkSize+10)
+	promoteTestChunks(t, mt, 2, MemoryTierChunkSize)
+
+	mt.Erase(&data.FileInfo{
+		Key:              testMemoryTierFileInfoKey,
+		ObjectGeneration: 1,
+		FileSize:         2*MemoryTierChunkSize + 10,
+	})
+
+	for chunk := int64(0); chunk < 3; chunk++ {
+		assert.False(t, mt.Contains(testMemoryTierKeyName(t), 1, chunk))
+	}
+	assert.True(t, mt.Contains(testMemoryTierKeyName(t), 2, 0))
+}
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"syscall"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 )
 
@@ -42,6 +44,9 @@ type Cache struct {
 	// INVARIANT: maxSize > 0
 	maxSize uint64
 
+	// The disk budget in which the size of the entries is reserved, if any.
+	budget *diskbudget.Budget
+
 	/////////////////////////
 	// Mutable state
 	/////////////////////////
@@ -49,6 +54,11 @@ type Cache struct {
 	// Sum of entry.Value.Size() of all the entries in the cache.
 	currentSize uint64
 
+	// The size reserved in budget.
+	//
+	// INVARIANT: budget != nil => reserved == currentSize
+	reserved uint64
+
 	// List of cache entries, with least recently used at the tail.
 	//
 	// INVARIANT: currentSize <= maxSize
@@ -88,6 +98,15 @@ func NewCache(maxSize uint64) *Cache {
 	return c
 }
 
+// NewCacheWithDiskBudget returns a cache like NewCache, whose entries' sizes
+// are also reserved in budget, e.g. since they are files on disk. Entries are
+// evicted when budget is exhausted too.
+func NewCacheWithDiskBudget(maxSize uint64, budget *diskbudget.Budget) *Cache {
+	c := NewCache(maxSize)
+	c.budget = budget
+	return c
+}
+
 // checkInvariants panic if any internal invariants have been violated.
 func (c *Cache) checkInvariants() {
 	// INVARIANT: maxSize > 0
@@ -100,6 +119,11 @@ func (c *Cache) checkInvariants() {
 		panic(fmt.Sprintf("CurrentSize %v over maxSize %v", c.currentSize, c.maxSize))
 	}
 
+	// INVARIANT: budget != nil => reserved == currentSize
+	if c.budget != nil && c.reserved != c.currentSize {
+		panic(fmt.Sprintf("Reserved %v for currentSize %v", c.reserved, c.currentSize))
+	}
+
 	// INVARIANT: Each element is of type entry
 	for e := c.entries.Front(); e != nil; e = e.Next() {
 		switch e.Value.(type) {
@@ -134,10 +158,41 @@ func (c *Cache) evictOne() ValueType {
 
 	c.entries.Remove(e)
 	delete(c.index, key)
+	c.releaseUnused()
 
 	return evictedEntry
 }
 
+// releaseUnused releases the size reserved in the budget beyond currentSize.
+func (c *Cache) releaseUnused() {
+	if c.budget != nil && c.reserved > c.currentSize {
+		c.budget.Release(c.reserved - c.currentSize)
+		c.reserved = c.currentSize
+	}
+}
+
+// reserve reserves currentSize in the budget, evicting the least recently
+// used entries but the most recently used one while it's exhausted. It
+// returns the evicted values, and an error wrapping syscall.ENOSPC if the
+// most recently used entry doesn't fit in the budget, in which case it's
+// evicted but not returned.
+func (c *Cache) reserve() (evictedValues []ValueType, err error) {
+	if c.budget == nil {
+		return
+	}
+	for c.currentSize > c.reserved && !c.budget.TryReserve(c.currentSize-c.reserved) {
+		if c.entries.Len() == 1 {
+			c.evictOne()
+			err = fmt.Errorf("no room in the disk budget: %w", syscall.ENOSPC)
+			return
+		}
+		evictedValues = append(evictedValues, c.evictOne())
+	}
+	c.reserved = max(c.reserved, c.currentSize)
+	c.releaseUnused()
+	return
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Cache interface
 ////////////////////////////////////////////////////////////////////////
@@ -180,7 +235,9 @@ func (c *Cache) Insert(
 		evictedValues = append(evictedValues, c.evictOne())
 	}
 
-	return evictedValues, nil
+	// Evict more while the disk budget is exhausted.
+	evictedForBudget, err := c.reserve()
+	return append(evictedValues, evictedForBudget...), err
 }
 
 // Erase any entry for the supplied key, also returns the value of erased key.
@@ -198,10 +255,26 @@ func (c *Cache) Erase(key string) (value ValueType) {
 
 	delete(c.index, key)
 	c.entries.Remove(e)
+	c.releaseUnused()
 
 	return deletedEntry
 }
 
+// EvictLeastRecentlyUsed evicts the least recently used entries until their
+// sizes sum up to at least size, or the cache is empty, and returns them.
+func (c *Cache) EvictLeastRecentlyUsed(size uint64) (evictedValues []ValueType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var evictedSize uint64
+	for evictedSize < size && c.entries.Len() > 0 {
+		v := c.evictOne()
+		evictedSize += v.Size()
+		evictedValues = append(evictedValues, v)
+	}
+	return
+}
+
 // LookUp a previously-inserted value for the given key. Return nil if no
 // value is present.
 func (c *Cache) LookUp(key string) (value ValueType) {
@@ -240,6 +313,18 @@ func (c *Cache) LookUpWithoutChangingOrder(key string) (value ValueType) {
 	return e.Value.(entry).Value
 }
 
+// Values returns the values of all the entries in the cache, from the most to
+// the least recently used, without changing the order of entries in cache.
+func (c *Cache) Values() (values []ValueType) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for e := c.entries.Front(); e != nil; e = e.Next() {
+		values = append(values, e.Value.(entry).Value)
+	}
+	return
+}
+
 // UpdateWithoutChangingOrder updates entry with the given key in cache with
 // given value without changing order of entries in cache, returning error if an
 // entry with given key doesn't exist. Also, the size of value for entry
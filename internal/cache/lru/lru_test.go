@@ -19,9 +19,11 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	. "github.com/jacobsa/ogletest"
 )
@@ -314,6 +316,74 @@ func (t *CacheTest) TestLookUpWithoutChangingOrder_NotChangeOrder() {
 
 // This will detect race if we run the test with `-race` flag.
 // We get the race condition failure if we remove lock from Insert or Erase method.
+func (t *CacheTest) TestValues() {
+	t.insertAndAssert("burrito", testData{Value: 23, DataSize: 4}, []int64{}, nil)
+	t.insertAndAssert("taco", testData{Value: 26, DataSize: 20}, []int64{}, nil)
+	t.insertAndAssert("enchilada", testData{Value: 28, DataSize: 26}, []int64{}, nil)
+	t.cache.LookUp("burrito")
+
+	values := t.cache.Values()
+
+	AssertEq(3, len(values))
+	ExpectEq(23, values[0].(testData).Value)
+	ExpectEq(28, values[1].(testData).Value)
+	ExpectEq(26, values[2].(testData).Value)
+	// The order isn't changed.
+	t.insertAndAssert("fajita", testData{Value: 30, DataSize: 4}, []int64{26}, nil)
+}
+
+func (t *CacheTest) TestValuesOfEmptyCache() {
+	ExpectEq(0, len(t.cache.Values()))
+}
+
+func (t *CacheTest) TestEvictLeastRecentlyUsed() {
+	t.insertAndAssert("a", testData{Value: 1, DataSize: 10}, []int64{}, nil)
+	t.insertAndAssert("b", testData{Value: 2, DataSize: 10}, []int64{}, nil)
+	t.insertAndAssert("c", testData{Value: 3, DataSize: 10}, []int64{}, nil)
+	t.cache.LookUp("a")
+
+	evicted := t.cache.EvictLeastRecentlyUsed(15)
+
+	AssertEq(2, len(evicted))
+	ExpectEq(2, evicted[0].(testData).Value)
+	ExpectEq(3, evicted[1].(testData).Value)
+	ExpectEq(1, t.cache.LookUp("a").(testData).Value)
+	ExpectEq(0, len(t.cache.EvictLeastRecentlyUsed(0)))
+	ExpectEq(1, len(t.cache.EvictLeastRecentlyUsed(100)))
+}
+
+func (t *CacheTest) TestDiskBudget() {
+	budget := diskbudget.New(25)
+	t.cache = lru.NewCacheWithDiskBudget(MaxSize, budget)
+	t.insertAndAssert("a", testData{Value: 1, DataSize: 10}, []int64{}, nil)
+	t.insertAndAssert("b", testData{Value: 2, DataSize: 10}, []int64{}, nil)
+	ExpectEq(20, budget.Used())
+
+	// The least recently used entry is evicted for the budget, not maxSize.
+	t.insertAndAssert("c", testData{Value: 3, DataSize: 10}, []int64{1}, nil)
+	ExpectEq(20, budget.Used())
+
+	t.cache.Erase("b")
+	ExpectEq(10, budget.Used())
+	t.cache.EvictLeastRecentlyUsed(1)
+	ExpectEq(0, budget.Used())
+}
+
+func (t *CacheTest) TestDiskBudget_EntryDoesNotFit() {
+	budget := diskbudget.New(25)
+	AssertTrue(budget.TryReserve(20))
+	t.cache = lru.NewCacheWithDiskBudget(MaxSize, budget)
+	t.insertAndAssert("a", testData{Value: 1, DataSize: 5}, []int64{}, nil)
+
+	evicted, err := t.cache.Insert("b", testData{Value: 2, DataSize: 10})
+
+	ExpectTrue(errors.Is(err, syscall.ENOSPC))
+	AssertEq(1, len(evicted))
+	ExpectEq(1, evicted[0].(testData).Value)
+	ExpectEq(nil, t.cache.LookUp("b"))
+	ExpectEq(20, budget.Used())
+}
+
 func (t *CacheTest) TestRaceCondition() {
 	var wg sync.WaitGroup
 	wg.Add(5)
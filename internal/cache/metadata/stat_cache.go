@@ -50,6 +50,12 @@ type StatCache interface {
 	// entry, or the entry has expired according to the supplied current time.
 	LookUp(name string, now time.Time) (hit bool, m *gcs.MinObject)
 
+	// Return the object entry for the given name like LookUp, but along with
+	// whether it has expired according to the supplied current time rather
+	// than as a miss, and without erasing it if it has, so that it can still be
+	// served, e.g. while GCS is unreachable.
+	LookUpKeepingExpired(name string, now time.Time) (hit bool, m *gcs.MinObject, expired bool)
+
 	// Insert an entry for the given folder resource.
 	//
 	// In order to help cope with caching of arbitrarily out of date (i.e.
@@ -242,6 +248,18 @@ func (sc *statCacheBucketView) LookUp(
 	return false, nil
 }
 
+func (sc *statCacheBucketView) LookUpKeepingExpired(
+	objectName string,
+	now time.Time) (bool, *gcs.MinObject, bool) {
+	value := sc.sharedCache.LookUp(sc.key(objectName))
+	if value == nil {
+		return false, nil, false
+	}
+
+	e := value.(entry)
+	return true, e.m, e.expiration.Before(now)
+}
+
 func (sc *statCacheBucketView) LookUpFolder(
 	folderName string,
 	now time.Time) (bool, *gcs.Folder) {
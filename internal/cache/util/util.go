@@ -27,6 +27,7 @@ import (
 	"unsafe"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufpool"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
 	"github.com/jacobsa/fuse/fsutil"
 )
@@ -40,6 +41,10 @@ const (
 	FallbackToGCSErrMsg                       = "read via gcs"
 	FileNotPresentInCacheErrMsg               = "file is not present in cache"
 	CacheHandleNotRequiredForRandomReadErrMsg = "cacheFileForRangeRead is false, read type random read and fileInfo entry is absent"
+	CorruptFileInCacheErrMsg                  = "corrupt file in cache"
+	ObjectNotAdmittedErrMsg                   = "object is not admitted by the file cache admission policy"
+	CacheDegradedErrMsg                       = "file cache is bypassed because of disk errors"
+	DiskErrorInCacheErrMsg                    = "disk error in file cache"
 )
 
 const (
@@ -49,6 +54,10 @@ const (
 	DefaultDirPerm   = os.FileMode(0700)
 	FileCache        = "gcsfuse-file-cache"
 	BufferSizeForCRC = 65536
+
+	// ChecksumChunkSize is the size of the chunks of the files in cache whose
+	// checksums are verified when they're read.
+	ChecksumChunkSize = MiB
 )
 
 // CreateFile creates file with given file spec i.e. permissions and returns
@@ -103,7 +112,9 @@ func IsCacheHandleInvalid(readErr error) bool {
 		strings.Contains(readErr.Error(), InvalidFileDownloadJobErrMsg) ||
 		strings.Contains(readErr.Error(), InvalidFileInfoCacheErrMsg) ||
 		strings.Contains(readErr.Error(), ErrInSeekingFileHandleMsg) ||
-		strings.Contains(readErr.Error(), ErrInReadingFileHandleMsg)
+		strings.Contains(readErr.Error(), ErrInReadingFileHandleMsg) ||
+		strings.Contains(readErr.Error(), CorruptFileInCacheErrMsg) ||
+		strings.Contains(readErr.Error(), CacheDegradedErrMsg)
 }
 
 // CreateCacheDirectoryIfNotPresentAt Creates directory at given path with
@@ -137,7 +148,8 @@ func CreateCacheDirectoryIfNotPresentAt(dirPath string, dirPerm os.FileMode) err
 func calculateCRC32(ctx context.Context, reader io.Reader) (uint32, error) {
 	table := crc32.MakeTable(crc32.Castagnoli)
 	checksum := crc32.Checksum([]byte(""), table)
-	buf := make([]byte, BufferSizeForCRC)
+	buf := bufpool.Get(BufferSizeForCRC)
+	defer bufpool.Put(buf)
 	for {
 		select {
 		case <-ctx.Done():
@@ -167,6 +179,44 @@ func CalculateFileCRC32(ctx context.Context, filePath string) (uint32, error) {
 	return calculateCRC32(ctx, file)
 }
 
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkChecksum returns the CRC-32 checksum of the content of a chunk.
+func ChunkChecksum(content []byte) uint32 {
+	return crc32.Checksum(content, crc32cTable)
+}
+
+// CalculateFileChunkChecksums calculates and returns the CRC-32 checksums of
+// the ChecksumChunkSize chunks of a file, the last one being shorter unless
+// the size of the file is a multiple of ChecksumChunkSize.
+func CalculateFileChunkChecksums(ctx context.Context, filePath string) ([]uint32, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	var checksums []uint32
+	buf := bufpool.Get(ChecksumChunkSize)
+	defer bufpool.Put(buf)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("CRC computation is cancelled: %w", err)
+		}
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			checksums = append(checksums, ChunkChecksum(buf[:n]))
+		}
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return checksums, nil
+		default:
+			return nil, err
+		}
+	}
+}
+
 // TruncateAndRemoveFile first truncates the file to 0 and then remove (delete)
 // the file at given path.
 func TruncateAndRemoveFile(filePath string) error {
@@ -195,17 +245,7 @@ func GetMemoryAlignedBuffer(bufferSize int64, alignSize int64) (buffer []byte, e
 
 	// Create and align buffer
 	createAndAlignBuffer := func() ([]byte, error) {
-		newBuffer := make([]byte, bufferSize+alignSize)
-		l := int64(uintptr(unsafe.Pointer(&newBuffer[0])) % uintptr(alignSize))
-		skipOffset := alignSize - l
-		newBuffer = newBuffer[skipOffset : skipOffset+bufferSize]
-
-		// Check if buffer is aligned or not
-		l = int64(uintptr(unsafe.Pointer(&newBuffer[0])) % uintptr(alignSize))
-		if l != 0 {
-			return nil, fmt.Errorf("failed to align buffer")
-		}
-		return newBuffer, nil
+		return alignBuffer(make([]byte, bufferSize+alignSize), bufferSize, alignSize)
 	}
 
 	// Though we haven't seen any error while aligning buffer but still it is safer
@@ -219,6 +259,22 @@ func GetMemoryAlignedBuffer(bufferSize int64, alignSize int64) (buffer []byte, e
 	return buffer, err
 }
 
+// alignBuffer returns the bufferSize bytes of buf, of at least
+// bufferSize+alignSize bytes, starting at a memory address multiple of
+// alignSize.
+func alignBuffer(buf []byte, bufferSize int64, alignSize int64) ([]byte, error) {
+	l := int64(uintptr(unsafe.Pointer(&buf[0])) % uintptr(alignSize))
+	skipOffset := alignSize - l
+	buf = buf[skipOffset : skipOffset+bufferSize]
+
+	// Check if buffer is aligned or not
+	l = int64(uintptr(unsafe.Pointer(&buf[0])) % uintptr(alignSize))
+	if l != 0 {
+		return nil, fmt.Errorf("failed to align buffer")
+	}
+	return buf, nil
+}
+
 // CopyUsingMemoryAlignedBuffer copies content from src reader to dst writer
 // by staging content into a memory aligned buffer of size bufferSize and
 // aligned to multiple of cfg.CacheUtilMinimumAlignSizeForWriting. Note: The minimum write
@@ -243,7 +299,9 @@ func CopyUsingMemoryAlignedBuffer(ctx context.Context, src io.Reader, dst io.Wri
 	}
 
 	reqBufferSize := calculateReqBufferSize(contentSize)
-	buffer, err := GetMemoryAlignedBuffer(reqBufferSize, alignSize)
+	pooled := bufpool.Get(int(reqBufferSize + alignSize))
+	defer bufpool.Put(pooled)
+	buffer, err := alignBuffer(pooled, reqBufferSize, alignSize)
 	if err != nil {
 		return 0, fmt.Errorf("error in creating memory aligned buffer %w", err)
 	}
@@ -234,6 +234,7 @@ func (ut *utilTest) Test_IsCacheHandleValid_True() {
 		InvalidFileInfoCacheErrMsg + "test",
 		ErrInSeekingFileHandleMsg + "test",
 		ErrInReadingFileHandleMsg + "test",
+		CorruptFileInCacheErrMsg + "test",
 	}
 
 	for _, errMsg := range errMessages {
@@ -283,6 +284,49 @@ func (ut *utilTest) Test_CalculateFileCRC32_ShouldReturnErrorWhenContextIsCancel
 	ExpectEq(0, crc)
 }
 
+func (ut *utilTest) Test_CalculateFileChunkChecksums_ShouldReturnChecksumOfSingleChunk() {
+	checksums, err := CalculateFileChunkChecksums(context.Background(), "testdata/validfile.txt")
+
+	ExpectEq(nil, err)
+	ExpectTrue(reflect.DeepEqual([]uint32{515179668}, checksums))
+}
+
+func (ut *utilTest) Test_CalculateFileChunkChecksums_ShouldReturnChecksumOfEachChunk() {
+	content := make([]byte, 2*ChecksumChunkSize+10)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	fileName := path.Join(os.TempDir(), "chunks.txt")
+	AssertEq(nil, os.WriteFile(fileName, content, 0600))
+	defer os.Remove(fileName)
+
+	checksums, err := CalculateFileChunkChecksums(context.Background(), fileName)
+
+	ExpectEq(nil, err)
+	expected := []uint32{
+		ChunkChecksum(content[:ChecksumChunkSize]),
+		ChunkChecksum(content[ChecksumChunkSize : 2*ChecksumChunkSize]),
+		ChunkChecksum(content[2*ChecksumChunkSize:]),
+	}
+	ExpectTrue(reflect.DeepEqual(expected, checksums))
+}
+
+func (ut *utilTest) Test_CalculateFileChunkChecksums_ShouldReturnNoChecksumForEmptyFile() {
+	checksums, err := CalculateFileChunkChecksums(context.Background(), "testdata/emptyfile.txt")
+
+	ExpectEq(nil, err)
+	ExpectEq(0, len(checksums))
+}
+
+func (ut *utilTest) Test_CalculateFileChunkChecksums_ShouldReturnErrorWhenContextIsCancelled() {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+
+	_, err := CalculateFileChunkChecksums(ctx, "testdata/validfile.txt")
+
+	ExpectTrue(errors.Is(err, context.Canceled))
+}
+
 func (ut *utilTest) Test_TruncateAndRemoveFile_FileExists() {
 	// Create a file to be deleted.
 	fileName := "temp.txt"
@@ -26,6 +26,8 @@ import (
 	"regexp"
 	"sync"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/jacobsa/timeutil"
@@ -47,6 +49,8 @@ type ContentCache struct {
 	tempDir    string
 	fileMap    map[CacheObjectKey]*CacheObject
 	mtimeClock timeutil.Clock
+	budget     *diskbudget.Budget
+	dirty      *block.DirtyBudget
 }
 
 // Metadata store struct
@@ -181,19 +185,34 @@ func matchPattern(fileName string) bool {
 	return match
 }
 
-// New creates a ContentCache.
-func New(tempDir string, mtimeClock timeutil.Clock) *ContentCache {
+// New creates a ContentCache, whose files are reserved in budget, if not nil.
+func New(tempDir string, mtimeClock timeutil.Clock, budget *diskbudget.Budget, dirty *block.DirtyBudget) *ContentCache {
 	return &ContentCache{
 		tempDir:    tempDir,
 		fileMap:    make(map[CacheObjectKey]*CacheObject),
 		mtimeClock: mtimeClock,
+		budget:     budget,
+		dirty:      dirty,
 	}
 }
 
 // NewTempFile returns a handle for a temporary file on the disk. The caller
 // must call Destroy on the TempFile before releasing it.
 func (c *ContentCache) NewTempFile(rc io.ReadCloser) (gcsx.TempFile, error) {
-	return gcsx.NewTempFile(rc, c.tempDir, c.mtimeClock)
+	return gcsx.NewTempFile(rc, c.tempDir, c.mtimeClock, c.budget)
+}
+
+// NewSpill returns a block.Spill placing the blocks of streaming writes, under
+// memory pressure, in files on the disk along with the temporary files of the
+// staged writes.
+func (c *ContentCache) NewSpill(thresholdPercent int64) *block.Spill {
+	return block.NewSpill(c.tempDir, c.budget, thresholdPercent)
+}
+
+// DirtyBudget returns the budget shared by the blocks of the streaming writes
+// of all the files, nil if they aren't capped.
+func (c *ContentCache) DirtyBudget() *block.DirtyBudget {
+	return c.dirty
 }
 
 // AddOrReplace creates a new cache file or updates an existing cache file
@@ -253,7 +272,7 @@ func (c *ContentCache) Remove(cacheObjectKey *CacheObjectKey) {
 
 // NewCacheFile returns a cache tempfile wrapper around the source reader and file
 func (c *ContentCache) NewCacheFile(rc io.ReadCloser, f *os.File) gcsx.TempFile {
-	return gcsx.NewCacheFile(rc, f, c.tempDir, c.mtimeClock)
+	return gcsx.NewCacheFile(rc, f, c.tempDir, c.mtimeClock, c.budget)
 }
 
 // recoverCacheFile returns a tempfile wrapper around a prepopulated cache file from disk
@@ -62,7 +62,7 @@ func TestValidateGenerationNegative(t *testing.T) {
 
 func TestReadWriteMetadataCheckpointFile(t *testing.T) {
 	mtimeClock := timeutil.RealClock()
-	contentCache := contentcache.New(testTempDir, mtimeClock)
+	contentCache := contentcache.New(testTempDir, mtimeClock, nil, nil)
 	f, err := fsutil.AnonymousFile(testTempDir)
 	AssertEq(err, nil)
 	objectMetadata := contentcache.CacheFileObjectMetadata{
@@ -92,7 +92,7 @@ func TestReadWriteMetadataCheckpointFile(t *testing.T) {
 func TestContentCacheAddOrReplace(t *testing.T) {
 	var wg sync.WaitGroup
 	mtimeClock := timeutil.RealClock()
-	contentCache := contentcache.New(testTempDir, mtimeClock)
+	contentCache := contentcache.New(testTempDir, mtimeClock, nil, nil)
 	cacheObjectKey := &contentcache.CacheObjectKey{
 		BucketName: "foo",
 		ObjectName: "baz",
@@ -111,7 +111,7 @@ func TestContentCacheAddOrReplace(t *testing.T) {
 func TestContentCacheGet(t *testing.T) {
 	var wg sync.WaitGroup
 	mtimeClock := timeutil.RealClock()
-	contentCache := contentcache.New(testTempDir, mtimeClock)
+	contentCache := contentcache.New(testTempDir, mtimeClock, nil, nil)
 	cacheObjectKey := &contentcache.CacheObjectKey{
 		BucketName: "foo",
 		ObjectName: "baz",
@@ -135,7 +135,7 @@ func TestContentCacheGet(t *testing.T) {
 func TestContentCacheRemove(t *testing.T) {
 	var wg sync.WaitGroup
 	mtimeClock := timeutil.RealClock()
-	contentCache := contentcache.New(testTempDir, mtimeClock)
+	contentCache := contentcache.New(testTempDir, mtimeClock, nil, nil)
 	for i := 1; i <= numConcurrentGoRoutines; i++ {
 		cacheObjectKey := &contentcache.CacheObjectKey{
 			BucketName: "foo",
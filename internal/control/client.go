@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Prefetch asks the mount serving the control socket at socketPath to
+// prefetch the files matching pattern, at most parallelism at a time, and
+// copies its progress to w. It returns an error if any file fails to be
+// prefetched.
+func Prefetch(ctx context.Context, socketPath, pattern string, parallelism int, w io.Writer) error {
+	query := url.Values{}
+	query.Set("pattern", pattern)
+	query.Set("parallelism", strconv.Itoa(parallelism))
+	resp, err := do(ctx, socketPath, http.MethodPost, "/prefetch?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.New("the mount doesn't have a file cache to prefetch into")
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("prefetch: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err = copyLines(resp.Body, w); err != nil {
+		return fmt.Errorf("prefetch failed: %w", err)
+	}
+	return nil
+}
+
+// Diff asks the mount serving the control socket at socketPath for the byte
+// ranges in which the generations a and b of the file at path differ, and
+// copies them to w.
+func Diff(ctx context.Context, socketPath, path string, a, b int64, w io.Writer) error {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("a", strconv.FormatInt(a, 10))
+	query.Set("b", strconv.FormatInt(b, 10))
+	resp, err := do(ctx, socketPath, http.MethodGet, "/diff?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.New("the mount doesn't support comparing generations")
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("diff: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err = copyLines(resp.Body, w); err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+	return nil
+}
+
+// Uploads copies the upload status of the files of the mount serving the
+// control socket at socketPath with contents waiting to be written out to GCS
+// to w.
+func Uploads(ctx context.Context, socketPath string, w io.Writer) error {
+	resp, err := do(ctx, socketPath, http.MethodGet, "/uploads")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.New("the mount doesn't report its uploads")
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("uploads: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return copyLines(resp.Body, w)
+}
+
+// Features copies the kill switches of the experimental subsystems of the
+// mount serving the control socket at socketPath to w.
+func Features(ctx context.Context, socketPath string, w io.Writer) error {
+	resp, err := do(ctx, socketPath, http.MethodGet, "/features")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("features: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return copyLines(resp.Body, w)
+}
+
+// SetFeature turns the kill switch with the supplied name of the mount
+// serving the control socket at socketPath on or off, and copies the outcome
+// to w.
+func SetFeature(ctx context.Context, socketPath, name string, enabled bool, w io.Writer) error {
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("enabled", strconv.FormatBool(enabled))
+	resp, err := do(ctx, socketPath, http.MethodPost, "/features?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("features: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return copyLines(resp.Body, w)
+}
+
+// do sends a request for urlPath to the control socket at socketPath.
+func do(ctx context.Context, socketPath, method, urlPath string) (*http.Response, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://gcsfuse"+urlPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the control socket %s: %w", socketPath, err)
+	}
+	return resp, nil
+}
+
+// copyLines copies the lines of a response to w, and returns an error unless
+// the last one starts with "done: ".
+func copyLines(body io.Reader, w io.Writer) error {
+	var last string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		last = scanner.Text()
+		fmt.Fprintln(w, last)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading the response: %w", err)
+	}
+	if strings.HasPrefix(last, "done: ") {
+		return nil
+	}
+	if msg, ok := strings.CutPrefix(last, "error: "); ok {
+		return errors.New(msg)
+	}
+	return errors.New("the response is truncated")
+}
@@ -0,0 +1,337 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control serves HTTP requests about the state of a mount on a Unix
+// domain socket, the control socket, for the operators of the mount, e.g.
+//
+//	curl --unix-socket /run/gcsfuse.sock http://gcsfuse/errors
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// Server serves the control requests until it is closed.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// Prefetcher prefetches the files of the mount into its file cache.
+type Prefetcher interface {
+	// Prefetch prefetches the files matching pattern, a path or a glob relative
+	// to the root of the mount, at most parallelism at a time. progress is
+	// called once per matched file, one call at a time, after it's prefetched
+	// or failed to be.
+	Prefetch(ctx context.Context, pattern string, parallelism int, progress func(name string, size uint64, err error)) error
+}
+
+// Differ compares generations of the objects of the mount.
+type Differ interface {
+	// Diff calls ranges with the byte ranges in which the generations a and b
+	// of the object at path, relative to the root of the mount, differ, from
+	// the first to the last. Zero is the live generation.
+	Diff(ctx context.Context, path string, a, b int64, ranges func(offset, length int64)) error
+}
+
+// UploadMonitor reports the uploads of the files of the mount.
+type UploadMonitor interface {
+	// Uploads calls status with the path, relative to the root of the mount,
+	// and the upload status of each file with contents waiting to be written
+	// out or being written out.
+	Uploads(status func(path, status string)) error
+}
+
+// NewHandler returns the handler of the control requests:
+//
+//	GET /errors: the recent WARNING and ERROR logs, from the oldest to the
+//	newest, in text format.
+//
+//	POST /prefetch?pattern=P&parallelism=N: prefetches the files matching P,
+//	all of them when empty, with prefetcher, reporting the progress one line per file. The last line
+//	starts with "done: " on success, or "error: " otherwise. Not found when
+//	prefetcher is nil.
+//
+//	GET /diff?path=P&a=A&b=B: the byte ranges in which the generations A and B
+//	of the file P differ, compared with differ, one "range OFFSET LENGTH" line
+//	per range. The last line starts with "done: " on success, or "error: "
+//	otherwise. Not found when differ is nil.
+//
+//	GET /features: the kill switches of the experimental subsystems, one
+//	"NAME on|off: DESCRIPTION" line per switch, followed by a "done: " line.
+//
+//	POST /features?name=N&enabled=B: turns the kill switch N on or off.
+//
+//	GET /uploads: the files with contents waiting to be written out to GCS or
+//	being written out, one "PATH: STATUS" line per file, where STATUS is the
+//	upload status reported by uploads. The last line starts with "done: " on
+//	success, or "error: " otherwise. Not found when uploads is nil.
+//
+//	GET /log-level: the severity of the logs and the debug scope, on a
+//	"severity S" and a "debug scope D" line, followed by a "done: " line.
+//
+//	POST /log-level?severity=S: sets the severity of the logs to S, e.g. DEBUG,
+//	until the next restart.
+//
+//	POST /debug-scope?path=P&ops=A,B: logs the ops A and B, all of them when
+//	empty, on the paths under P, the whole mount when empty, at DEBUG severity
+//	whatever the severity of the logs.
+//
+//	DELETE /debug-scope: stops logging the ops in the debug scope.
+func NewHandler(prefetcher Prefetcher, differ Differ, uploads UploadMonitor) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /errors", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := logger.WriteRecentErrors(w); err != nil {
+			logger.Debugf("control: writing the recent errors: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /features", serveFeatures)
+	mux.HandleFunc("POST /features", serveSetFeature)
+	mux.HandleFunc("GET /log-level", serveLogLevel)
+	mux.HandleFunc("POST /log-level", serveSetLogLevel)
+	mux.HandleFunc("POST /debug-scope", serveSetDebugScope)
+	mux.HandleFunc("DELETE /debug-scope", serveClearDebugScope)
+	if prefetcher != nil {
+		mux.HandleFunc("POST /prefetch", func(w http.ResponseWriter, r *http.Request) {
+			servePrefetch(w, r, prefetcher)
+		})
+	}
+	if differ != nil {
+		mux.HandleFunc("GET /diff", func(w http.ResponseWriter, r *http.Request) {
+			serveDiff(w, r, differ)
+		})
+	}
+	if uploads != nil {
+		mux.HandleFunc("GET /uploads", func(w http.ResponseWriter, _ *http.Request) {
+			serveUploads(w, uploads)
+		})
+	}
+	return mux
+}
+
+func servePrefetch(w http.ResponseWriter, r *http.Request, prefetcher Prefetcher) {
+	if !r.URL.Query().Has("pattern") {
+		http.Error(w, "missing pattern", http.StatusBadRequest)
+		return
+	}
+	parallelism, err := strconv.Atoi(r.URL.Query().Get("parallelism"))
+	if err != nil || parallelism < 1 {
+		http.Error(w, "parallelism must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	var prefetched, failed int
+	var bytes uint64
+	err = prefetcher.Prefetch(r.Context(), r.URL.Query().Get("pattern"), parallelism, func(name string, size uint64, err error) {
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "failed %s: %v\n", name, err)
+		} else {
+			prefetched++
+			bytes += size
+			fmt.Fprintf(w, "prefetched %s (%d bytes)\n", name, size)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "error: %v\n", err)
+	case failed > 0:
+		fmt.Fprintf(w, "error: %d of %d files failed to be prefetched\n", failed, failed+prefetched)
+	default:
+		fmt.Fprintf(w, "done: prefetched %d files (%d bytes)\n", prefetched, bytes)
+	}
+}
+
+func serveDiff(w http.ResponseWriter, r *http.Request, differ Differ) {
+	query := r.URL.Query()
+	if query.Get("path") == "" {
+		http.Error(w, "missing path", http.StatusBadRequest)
+		return
+	}
+	a, errA := strconv.ParseInt(query.Get("a"), 10, 64)
+	b, errB := strconv.ParseInt(query.Get("b"), 10, 64)
+	if errA != nil || errB != nil || a < 0 || b < 0 {
+		http.Error(w, "generations must be non-negative integers", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var count, bytes int64
+	err := differ.Diff(r.Context(), query.Get("path"), a, b, func(offset, length int64) {
+		count++
+		bytes += length
+		fmt.Fprintf(w, "range %d %d\n", offset, length)
+	})
+	if err != nil {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "done: %d bytes differ in %d ranges\n", bytes, count)
+}
+
+func serveUploads(w http.ResponseWriter, uploads UploadMonitor) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var count int
+	err := uploads.Uploads(func(path, status string) {
+		count++
+		fmt.Fprintf(w, "%s: %s\n", path, status)
+	})
+	if err != nil {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "done: %d files\n", count)
+}
+
+func serveFeatures(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flags := featureflag.All()
+	for _, f := range flags {
+		state := "on"
+		if !f.Enabled() {
+			state = "off"
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", f.Name(), state, f.Description())
+	}
+	fmt.Fprintf(w, "done: %d features\n", len(flags))
+}
+
+func serveSetFeature(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	enabled, err := strconv.ParseBool(query.Get("enabled"))
+	if err != nil {
+		http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+		return
+	}
+	if err = featureflag.Set(query.Get("name"), enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	state := "on"
+	if !enabled {
+		state = "off"
+	}
+	logger.Infof("Turned the feature %q %s through the control socket", query.Get("name"), state)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "done: turned %s %s\n", query.Get("name"), state)
+}
+
+func serveLogLevel(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "severity %s\n", logger.Severity())
+	if s := logger.CurrentDebugScope(); s != nil {
+		fmt.Fprintf(w, "debug scope %s\n", s)
+	} else {
+		fmt.Fprintln(w, "debug scope none")
+	}
+	fmt.Fprintln(w, "done: log level")
+}
+
+func serveSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	severity := strings.ToUpper(r.URL.Query().Get("severity"))
+	if err := logger.SetSeverity(severity); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.Infof("Set the log severity to %s through the control socket", severity)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "done: severity %s\n", severity)
+}
+
+func serveSetDebugScope(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	s := &logger.DebugScope{PathPrefix: strings.TrimPrefix(query.Get("path"), "/")}
+	for _, op := range strings.Split(query.Get("ops"), ",") {
+		if op = strings.TrimSpace(op); op != "" {
+			s.Ops = append(s.Ops, op)
+		}
+	}
+	logger.SetDebugScope(s)
+	logger.Infof("Set the debug scope to %s through the control socket", s)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "done: debugging %s\n", s)
+}
+
+func serveClearDebugScope(w http.ResponseWriter, _ *http.Request) {
+	logger.SetDebugScope(nil)
+	logger.Infof("Cleared the debug scope through the control socket")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "done: cleared the debug scope")
+}
+
+// Listen serves the requests with handler on a socket created at path, only
+// accessible to the user running gcsfuse. A socket left at path by a previous
+// mount is replaced.
+func Listen(path string, handler http.Handler) (*Server, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing the stale socket %s: %w", path, err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("chmod %s: %w", path, err)
+	}
+
+	s := &Server{
+		listener: l,
+		server:   &http.Server{Handler: handler},
+	}
+	go func() {
+		if err := s.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warnf("The control socket %s stopped serving: %v", path, err)
+		}
+	}()
+	return s, nil
+}
+
+// Close stops serving the requests and removes the socket.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	// Closing the listener of a Unix socket removes it. The server closes it
+	// too, unless it's closed before it starts serving.
+	if closeErr := s.listener.Close(); err == nil && !errors.Is(closeErr, net.ErrClosed) {
+		err = closeErr
+	}
+	return err
+}
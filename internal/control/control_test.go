@@ -0,0 +1,388 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, socketPath, urlPath string) (int, string) {
+	t.Helper()
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	resp, err := client.Get("http://gcsfuse" + urlPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestListen_ServesRecentErrors(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	logger.Errorf("control socket test error")
+
+	status, body := get(t, socketPath, "/errors")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "control socket test error")
+	fi, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestListen_UnknownPath(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	status, _ := get(t, socketPath, "/unknown")
+
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestListen_ReplacesStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	// Leave the socket behind, like a crashed mount does.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+
+	require.NoError(t, err)
+	defer s.Close()
+	status, _ := get(t, socketPath, "/errors")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestListen_DoesNotReplaceOtherFiles(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	require.NoError(t, os.WriteFile(socketPath, []byte("data"), 0600))
+
+	_, err := Listen(socketPath, NewHandler(nil, nil, nil))
+
+	assert.ErrorContains(t, err, "is not a socket")
+}
+
+func TestClose_RemovesSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Close())
+
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+type fakePrefetcher struct {
+	pattern     string
+	parallelism int
+	failed      []string
+	err         error
+}
+
+func (p *fakePrefetcher) Prefetch(_ context.Context, pattern string, parallelism int, progress func(name string, size uint64, err error)) error {
+	p.pattern = pattern
+	p.parallelism = parallelism
+	progress("a/1", 10, nil)
+	for _, name := range p.failed {
+		progress(name, 0, errors.New("download failed"))
+	}
+	return p.err
+}
+
+func TestPrefetch_ReportsProgress(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	p := &fakePrefetcher{}
+	s, err := Listen(socketPath, NewHandler(p, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Prefetch(context.Background(), socketPath, "a/*", 4, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, "a/*", p.pattern)
+	assert.Equal(t, 4, p.parallelism)
+	assert.Equal(t, "prefetched a/1 (10 bytes)\ndone: prefetched 1 files (10 bytes)\n", out.String())
+}
+
+func TestPrefetch_FailedFiles(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(&fakePrefetcher{failed: []string{"a/2"}}, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Prefetch(context.Background(), socketPath, "a", 1, &out)
+
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "failed a/2: download failed\n")
+	assert.Contains(t, out.String(), "error: 1 of 2 files failed to be prefetched\n")
+}
+
+func TestPrefetch_PrefetcherError(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(&fakePrefetcher{err: errors.New("listing failed")}, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Prefetch(context.Background(), socketPath, "a", 1, &out)
+
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "error: listing failed\n")
+}
+
+func TestPrefetch_NoPrefetcher(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = Prefetch(context.Background(), socketPath, "a", 1, io.Discard)
+
+	assert.ErrorContains(t, err, "doesn't have a file cache")
+}
+
+func TestPrefetch_InvalidParallelism(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(&fakePrefetcher{}, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = Prefetch(context.Background(), socketPath, "a", 0, io.Discard)
+
+	assert.ErrorContains(t, err, "parallelism must be a positive integer")
+}
+
+type fakeDiffer struct {
+	path string
+	a, b int64
+	err  error
+}
+
+func (d *fakeDiffer) Diff(_ context.Context, path string, a, b int64, ranges func(offset, length int64)) error {
+	d.path, d.a, d.b = path, a, b
+	ranges(0, 3)
+	ranges(10, 5)
+	return d.err
+}
+
+func TestDiff_ReportsRanges(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	d := &fakeDiffer{}
+	s, err := Listen(socketPath, NewHandler(nil, d, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Diff(context.Background(), socketPath, "a/b", 1, 2, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, "a/b", d.path)
+	assert.Equal(t, int64(1), d.a)
+	assert.Equal(t, int64(2), d.b)
+	assert.Equal(t, "range 0 3\nrange 10 5\ndone: 8 bytes differ in 2 ranges\n", out.String())
+}
+
+func TestDiff_DifferError(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, &fakeDiffer{err: errors.New("generation not found")}, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Diff(context.Background(), socketPath, "a/b", 1, 2, &out)
+
+	assert.ErrorContains(t, err, "generation not found")
+	assert.Contains(t, out.String(), "error: generation not found\n")
+}
+
+func TestDiff_InvalidGeneration(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, &fakeDiffer{}, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	status, body := get(t, socketPath, "/diff?path=a&a=1&b=x")
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "generations must be non-negative integers")
+}
+
+func TestDiff_NoDiffer(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = Diff(context.Background(), socketPath, "a", 1, 2, io.Discard)
+
+	assert.ErrorContains(t, err, "doesn't support comparing generations")
+}
+
+type fakeUploadMonitor struct{}
+
+func (fakeUploadMonitor) Uploads(status func(path, status string)) error {
+	status("a/b", "buffered=10 committed=0 pending_blocks=0 uploading=false")
+	return nil
+}
+
+func TestUploads_ReportsFiles(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, fakeUploadMonitor{}))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Uploads(context.Background(), socketPath, &out)
+
+	require.NoError(t, err)
+	assert.Equal(t, "a/b: buffered=10 committed=0 pending_blocks=0 uploading=false\ndone: 1 files\n", out.String())
+}
+
+func TestUploads_NoUploadMonitor(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = Uploads(context.Background(), socketPath, io.Discard)
+
+	assert.ErrorContains(t, err, "doesn't report its uploads")
+}
+
+var testFeature = featureflag.Register("control-test", "A test feature.")
+
+func TestFeatures_ListsFeatures(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	var out bytes.Buffer
+
+	err = Features(context.Background(), socketPath, &out)
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "control-test on: A test feature.\n")
+	assert.Contains(t, out.String(), "done: ")
+}
+
+func TestSetFeature_TurnsFeatureOff(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	defer func() { require.NoError(t, featureflag.Set("control-test", true)) }()
+	var out bytes.Buffer
+
+	err = SetFeature(context.Background(), socketPath, "control-test", false, &out)
+
+	require.NoError(t, err)
+	assert.False(t, testFeature.Enabled())
+	assert.Equal(t, "done: turned control-test off\n", out.String())
+}
+
+func TestSetFeature_UnknownFeature(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = SetFeature(context.Background(), socketPath, "unknown", false, io.Discard)
+
+	assert.ErrorContains(t, err, `unknown feature "unknown"`)
+}
+
+func TestLogLevel_SetsSeverityAndDebugScope(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	severity := logger.Severity()
+	defer func() { require.NoError(t, logger.SetSeverity(severity)) }()
+	defer logger.SetDebugScope(nil)
+
+	resp, err := do(context.Background(), socketPath, http.MethodPost, "/log-level?severity=debug")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = do(context.Background(), socketPath, http.MethodPost, "/debug-scope?path=/models/&ops=ReadFile,OpenFile")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	status, body := get(t, socketPath, "/log-level")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "severity DEBUG\ndebug scope ops ReadFile,OpenFile under \"/models/\"\ndone: log level\n", body)
+	assert.Equal(t, &logger.DebugScope{PathPrefix: "models/", Ops: []string{"ReadFile", "OpenFile"}}, logger.CurrentDebugScope())
+}
+
+func TestLogLevel_ClearsDebugScope(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	logger.SetDebugScope(&logger.DebugScope{PathPrefix: "models/"})
+
+	resp, err := do(context.Background(), socketPath, http.MethodDelete, "/debug-scope")
+
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Nil(t, logger.CurrentDebugScope())
+}
+
+func TestLogLevel_InvalidSeverity(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "gcsfuse.sock")
+	s, err := Listen(socketPath, NewHandler(nil, nil, nil))
+	require.NoError(t, err)
+	defer s.Close()
+	severity := logger.Severity()
+
+	resp, err := do(context.Background(), socketPath, http.MethodPost, "/log-level?severity=verbose")
+
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, severity, logger.Severity())
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// healthCheckTimeout bounds the time the file system has to respond to a
+// health check, so that a hung mount fails the check rather than hanging it.
+const healthCheckTimeout = 5 * time.Second
+
+// NewHealthHandler returns the handler of the health checks of the mount at
+// mountPoint, e.g. for the probes of a container orchestrator:
+//   - GET /healthz succeeds while the file system responds,
+//   - GET /readyz also fails once draining returns true, i.e. once gcsfuse is
+//     unmounting.
+func NewHealthHandler(mountPoint string, draining func() bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		serveHealth(w, mountPoint)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if draining() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		serveHealth(w, mountPoint)
+	})
+	return mux
+}
+
+func serveHealth(w http.ResponseWriter, mountPoint string) {
+	// The stat is served by the file system, unless it has been unmounted.
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(mountPoint)
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(healthCheckTimeout):
+		err = fmt.Errorf("stat %s: no response within %v", mountPoint, healthCheckTimeout)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// ListenTCP serves the requests with handler on the supplied TCP address.
+func ListenTCP(addr string, handler http.Handler) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := &Server{
+		listener: l,
+		server:   &http.Server{Handler: handler},
+	}
+	go func() {
+		if err := s.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warnf("The health server on %s stopped serving: %v", addr, err)
+		}
+	}()
+	return s, nil
+}
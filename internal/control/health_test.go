@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTCP(t *testing.T, s *Server, urlPath string) (int, string) {
+	t.Helper()
+	resp, err := http.Get("http://" + s.listener.Addr().String() + urlPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestHealth(t *testing.T) {
+	var draining atomic.Bool
+	s, err := ListenTCP("127.0.0.1:0", NewHealthHandler(t.TempDir(), draining.Load))
+	require.NoError(t, err)
+	defer s.Close()
+
+	status, body := getTCP(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok\n", body)
+	status, _ = getTCP(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	draining.Store(true)
+
+	status, _ = getTCP(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	status, body = getTCP(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "draining\n", body)
+}
+
+func TestHealth_UnmountedFileSystem(t *testing.T) {
+	s, err := ListenTCP("127.0.0.1:0", NewHealthHandler(filepath.Join(t.TempDir(), "missing"), func() bool { return false }))
+	require.NoError(t, err)
+	defer s.Close()
+
+	status, _ := getTCP(t, s, "/healthz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
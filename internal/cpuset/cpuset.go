@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpuset restricts gcsfuse to a set of CPUs, e.g. those of the NUMA
+// node of the GPUs it feeds, so that its goroutines and the memory they
+// allocate, such as the read buffers, stay on that node.
+package cpuset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// numaPrefix prefixes the NUMA nodes whose CPUs make a set, e.g. "numa:0".
+const numaPrefix = "numa:"
+
+// Overridden in tests.
+var (
+	sysFsNodePath   = "/sys/devices/system/node"
+	procSelfTaskDir = "/proc/self/task"
+)
+
+// Spec is a set of CPUs, or of NUMA nodes standing for their CPUs.
+type Spec struct {
+	CPUs  []int
+	Nodes []int
+}
+
+// Parse parses a set of CPUs in the list format of taskset and of the cpuset
+// cgroups, e.g. "0-15,32-47", or a set of NUMA nodes in that format prefixed
+// by "numa:", e.g. "numa:1".
+func Parse(spec string) (Spec, error) {
+	if nodes, ok := strings.CutPrefix(spec, numaPrefix); ok {
+		n, err := parseList(nodes)
+		if err != nil {
+			return Spec{}, fmt.Errorf("invalid NUMA nodes %q: %w", nodes, err)
+		}
+		return Spec{Nodes: n}, nil
+	}
+	cpus, err := parseList(spec)
+	if err != nil {
+		return Spec{}, fmt.Errorf("invalid CPUs %q: %w", spec, err)
+	}
+	return Spec{CPUs: cpus}, nil
+}
+
+// parseList parses a list of ranges like "0-3,8,10-11" into the sorted
+// numbers in them.
+func parseList(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("%q isn't a number or a range", r)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("%q isn't a number or a range", r)
+			}
+		}
+		for i := start; i <= end; i++ {
+			seen[i] = true
+		}
+	}
+	numbers := make([]int, 0, len(seen))
+	for i := range seen {
+		numbers = append(numbers, i)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// Resolve returns the CPUs of s, reading those of its NUMA nodes from sysfs.
+func (s Spec) Resolve() ([]int, error) {
+	if len(s.Nodes) == 0 {
+		return s.CPUs, nil
+	}
+	var cpus []int
+	for _, node := range s.Nodes {
+		list, err := os.ReadFile(filepath.Join(sysFsNodePath, fmt.Sprintf("node%d", node), "cpulist"))
+		if err != nil {
+			return nil, fmt.Errorf("reading the CPUs of NUMA node %d: %w", node, err)
+		}
+		nodeCPUs, err := parseList(string(list))
+		if err != nil {
+			return nil, fmt.Errorf("parsing the CPUs of NUMA node %d: %w", node, err)
+		}
+		cpus = append(cpus, nodeCPUs...)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// Pin restricts all the threads of the process to cpus, and sets GOMAXPROCS
+// to their number. The threads started afterwards inherit the CPUs of the
+// thread starting them, and so do the child processes. The memory is then
+// allocated on the NUMA nodes of cpus by the default policy of the kernel,
+// which allocates the pages on the node of the CPU first touching them.
+func Pin(cpus []int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("no CPUs to pin to")
+	}
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+
+	// The affinity of a thread is set one thread at a time. The threads
+	// started while iterating inherit it from the ones already pinned, or are
+	// listed by the next iteration.
+	pinned := make(map[int]bool)
+	for {
+		tids, err := threads()
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, tid := range tids {
+			if pinned[tid] {
+				continue
+			}
+			done = false
+			if err := unix.SchedSetaffinity(tid, &set); err != nil && !errors.Is(err, unix.ESRCH) {
+				return fmt.Errorf("pinning thread %d to CPUs %v: %w", tid, cpus, err)
+			}
+			pinned[tid] = true
+		}
+		if done {
+			break
+		}
+	}
+
+	runtime.GOMAXPROCS(len(cpus))
+	return nil
+}
+
+// threads returns the IDs of the threads of the process.
+func threads() ([]int, error) {
+	entries, err := os.ReadDir(procSelfTaskDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing the threads: %w", err)
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Spec
+	}{
+		{spec: "3", expected: Spec{CPUs: []int{3}}},
+		{spec: "0-3", expected: Spec{CPUs: []int{0, 1, 2, 3}}},
+		{spec: "8,0-1,1-2", expected: Spec{CPUs: []int{0, 1, 2, 8}}},
+		{spec: "numa:1", expected: Spec{Nodes: []int{1}}},
+		{spec: "numa:0-1", expected: Spec{Nodes: []int{0, 1}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "a", "3-1", "-1", "1,", "numa:", "numa:x"} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := Parse(spec)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestResolve_NUMANodes(t *testing.T) {
+	defer func(p string) { sysFsNodePath = p }(sysFsNodePath)
+	sysFsNodePath = t.TempDir()
+	for node, cpus := range []string{"0-1,4-5\n", "2-3,6-7\n"} {
+		dir := filepath.Join(sysFsNodePath, fmt.Sprintf("node%d", node))
+		require.NoError(t, os.Mkdir(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cpulist"), []byte(cpus), 0644))
+	}
+
+	cpus, err := Spec{Nodes: []int{0, 1}}.Resolve()
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, cpus)
+	_, err = Spec{Nodes: []int{2}}.Resolve()
+	assert.ErrorContains(t, err, "NUMA node 2")
+}
+
+func TestPin(t *testing.T) {
+	var initial unix.CPUSet
+	require.NoError(t, unix.SchedGetaffinity(0, &initial))
+	var cpu int
+	for !initial.IsSet(cpu) {
+		cpu++
+	}
+	defer func(n int) { runtime.GOMAXPROCS(n) }(runtime.GOMAXPROCS(0))
+	defer func() {
+		var all []int
+		for i := 0; i < len(initial)*64; i++ {
+			if initial.IsSet(i) {
+				all = append(all, i)
+			}
+		}
+		require.NoError(t, Pin(all))
+	}()
+
+	require.NoError(t, Pin([]int{cpu}))
+
+	var pinned unix.CPUSet
+	require.NoError(t, unix.SchedGetaffinity(0, &pinned))
+	assert.Equal(t, 1, pinned.Count())
+	assert.True(t, pinned.IsSet(cpu))
+	assert.Equal(t, 1, runtime.GOMAXPROCS(0))
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diskbudget caps the disk space used by gcsfuse across its features,
+// e.g. the file cache, the staging of the writes and the log files.
+package diskbudget
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+)
+
+// Budget is a hard cap on the disk space shared by its consumers, which
+// reserve the space before using it and release it once it's freed. When the
+// budget is exhausted, the space is reclaimed from the reclaimers, e.g. caches,
+// in the order they were added, and the reservations fail with ENOSPC if that
+// isn't enough.
+//
+// A nil Budget is unlimited. Safe for concurrent use.
+type Budget struct {
+	limit uint64
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	used uint64
+
+	// GUARDED_BY(mu)
+	reclaimers []func(n uint64)
+}
+
+// New returns a budget of limit bytes.
+func New(limit uint64) *Budget {
+	return &Budget{limit: limit}
+}
+
+// AddReclaimer adds a function reclaiming space when the budget is exhausted,
+// by releasing at least n bytes if it can. It's called without any lock of
+// the budget held, and must not reserve space itself.
+func (b *Budget) AddReclaimer(reclaim func(n uint64)) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reclaimers = append(b.reclaimers, reclaim)
+}
+
+// TryReserve reserves n bytes if they are left, without reclaiming space.
+func (b *Budget) TryReserve(n uint64) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > b.limit-b.used {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Reserve reserves n bytes, reclaiming space if they aren't left. The
+// returned error wraps syscall.ENOSPC if they can't be reclaimed.
+func (b *Budget) Reserve(n uint64) error {
+	if b.TryReserve(n) {
+		return nil
+	}
+
+	// Reclaiming is useless if the reservation can't fit in the whole budget.
+	if n <= b.limit {
+		b.mu.Lock()
+		reclaimers := b.reclaimers
+		b.mu.Unlock()
+		for _, reclaim := range reclaimers {
+			reclaim(b.missing(n))
+			if b.TryReserve(n) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("reserving %d bytes in the disk budget of %d bytes: %w", n, b.limit, syscall.ENOSPC)
+}
+
+// missing returns the number of bytes missing to reserve n bytes.
+func (b *Budget) missing(n uint64) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n <= b.limit {
+		return 0
+	}
+	return b.used + n - b.limit
+}
+
+// Release releases n bytes previously reserved.
+func (b *Budget) Release(n uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > b.used {
+		panic(fmt.Sprintf("releasing %d bytes while %d are reserved", n, b.used))
+	}
+	b.used -= n
+}
+
+// Used returns the number of bytes reserved.
+func (b *Budget) Used() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
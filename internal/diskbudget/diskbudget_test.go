@@ -0,0 +1,100 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskbudget
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserve_WithinLimit(t *testing.T) {
+	b := New(100)
+
+	require.NoError(t, b.Reserve(60))
+	require.NoError(t, b.Reserve(40))
+
+	assert.Equal(t, uint64(100), b.Used())
+}
+
+func TestReserve_ReclaimsInOrder(t *testing.T) {
+	b := New(100)
+	require.NoError(t, b.Reserve(90))
+	var calls []string
+	b.AddReclaimer(func(n uint64) {
+		calls = append(calls, "first")
+		assert.Equal(t, uint64(20), n)
+		b.Release(10)
+	})
+	b.AddReclaimer(func(n uint64) {
+		calls = append(calls, "second")
+		assert.Equal(t, uint64(10), n)
+		b.Release(10)
+	})
+	b.AddReclaimer(func(uint64) {
+		calls = append(calls, "third")
+	})
+
+	require.NoError(t, b.Reserve(30))
+
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, uint64(100), b.Used())
+}
+
+func TestReserve_NoSpace(t *testing.T) {
+	b := New(100)
+	require.NoError(t, b.Reserve(90))
+	reclaimed := false
+	b.AddReclaimer(func(uint64) { reclaimed = true })
+
+	err := b.Reserve(20)
+
+	assert.True(t, errors.Is(err, syscall.ENOSPC))
+	assert.True(t, reclaimed)
+	assert.Equal(t, uint64(90), b.Used())
+}
+
+func TestReserve_MoreThanLimitDoesNotReclaim(t *testing.T) {
+	b := New(100)
+	b.AddReclaimer(func(uint64) { t.Error("unexpected reclaim") })
+
+	err := b.Reserve(101)
+
+	assert.True(t, errors.Is(err, syscall.ENOSPC))
+}
+
+func TestTryReserve(t *testing.T) {
+	b := New(100)
+	b.AddReclaimer(func(uint64) { t.Error("unexpected reclaim") })
+
+	assert.True(t, b.TryReserve(100))
+	assert.False(t, b.TryReserve(1))
+	b.Release(50)
+	assert.True(t, b.TryReserve(1))
+	assert.Equal(t, uint64(51), b.Used())
+}
+
+func TestNilBudgetIsUnlimited(t *testing.T) {
+	var b *Budget
+	b.AddReclaimer(func(uint64) {})
+
+	assert.NoError(t, b.Reserve(1<<62))
+	assert.True(t, b.TryReserve(1<<62))
+	b.Release(1 << 62)
+	assert.Equal(t, uint64(0), b.Used())
+}
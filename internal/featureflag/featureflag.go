@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package featureflag holds the kill switches of the experimental subsystems
+// of gcsfuse, so that a misbehaving one can be turned off in a running mount,
+// e.g. through the control socket, without unmounting it.
+//
+// A subsystem registers its switch once, in a package variable, and checks it
+// where it would otherwise be used, falling back to the path it replaces when
+// it's off. The switches only gate the operations started after they are
+// flipped: a file already written with streaming writes, for instance, keeps
+// being written that way until it's closed.
+package featureflag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Flag is the kill switch of an experimental subsystem. It's on unless turned
+// off. Safe for concurrent use.
+type Flag struct {
+	name        string
+	description string
+	off         atomic.Bool
+}
+
+// Name returns the name with which the flag was registered.
+func (f *Flag) Name() string {
+	return f.name
+}
+
+// Description returns what the subsystem behind the flag does.
+func (f *Flag) Description() string {
+	return f.description
+}
+
+// Enabled returns true unless the flag has been turned off.
+func (f *Flag) Enabled() bool {
+	return !f.off.Load()
+}
+
+var (
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	flags = make(map[string]*Flag)
+)
+
+// Register returns the flag of the subsystem with the supplied name, turned
+// on. It panics if the name is already registered, as flags are registered by
+// the package variables of their subsystems.
+func Register(name, description string) *Flag {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := flags[name]; ok {
+		panic(fmt.Sprintf("feature flag %q registered twice", name))
+	}
+	f := &Flag{name: name, description: description}
+	flags[name] = f
+	return f
+}
+
+// Set turns the flag with the supplied name on or off.
+func Set(name string, enabled bool) error {
+	mu.Lock()
+	f, ok := flags[name]
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown feature %q", name)
+	}
+	f.off.Store(!enabled)
+	return nil
+}
+
+// All returns the registered flags, sorted by name.
+func All() []*Flag {
+	mu.Lock()
+	defer mu.Unlock()
+	all := make([]*Flag, 0, len(flags))
+	for _, f := range flags {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return all
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister_EnabledByDefault(t *testing.T) {
+	f := Register("test-enabled-by-default", "A test feature.")
+
+	assert.True(t, f.Enabled())
+	assert.Equal(t, "test-enabled-by-default", f.Name())
+	assert.Equal(t, "A test feature.", f.Description())
+}
+
+func TestRegister_Twice(t *testing.T) {
+	Register("test-registered-twice", "")
+
+	assert.Panics(t, func() { Register("test-registered-twice", "") })
+}
+
+func TestSet(t *testing.T) {
+	f := Register("test-set", "")
+
+	require.NoError(t, Set("test-set", false))
+	assert.False(t, f.Enabled())
+	require.NoError(t, Set("test-set", true))
+	assert.True(t, f.Enabled())
+}
+
+func TestSet_UnknownFeature(t *testing.T) {
+	err := Set("test-unknown", false)
+
+	assert.ErrorContains(t, err, `unknown feature "test-unknown"`)
+}
+
+func TestAll_SortedByName(t *testing.T) {
+	b := Register("test-all-b", "")
+	a := Register("test-all-a", "")
+
+	var names []string
+	for _, f := range All() {
+		if f == a || f == b {
+			names = append(names, f.Name())
+		}
+	}
+
+	assert.Equal(t, []string{"test-all-a", "test-all-b"}, names)
+}
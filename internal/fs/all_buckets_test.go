@@ -15,12 +15,15 @@
 package fs_test
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
 	"github.com/jacobsa/timeutil"
@@ -75,8 +78,9 @@ func (t *AllBucketsTest) BaseDir_Rename() {
 		filename, []byte("content"), os.FileMode(0644))
 	AssertEq(nil, err)
 
+	// Renames across buckets are disabled by default, for mv to copy the file.
 	err = os.Rename(mntDir+"/bucket-0/foo", mntDir+"/bucket-1/foo")
-	ExpectThat(err, Error(HasSubstr("operation not supported")))
+	ExpectThat(err, Error(HasSubstr("invalid cross-device link")))
 
 	err = os.Rename(mntDir+"/bucket-0/foo", mntDir+"/bucket-99")
 	ExpectThat(err, Error(HasSubstr("input/output error")))
@@ -147,3 +151,81 @@ func (t *AllBucketsTest) SingleBucket_ReadAfterWrite() {
 	AssertEq(nil, err)
 	ExpectEq("000o111ritoenchilada222", string(fileContents))
 }
+
+////////////////////////////////////////////////////////////////////////
+// Renames across buckets
+////////////////////////////////////////////////////////////////////////
+
+type CrossBucketRenameTest struct {
+	fsTest
+}
+
+func init() {
+	RegisterTestSuite(&CrossBucketRenameTest{})
+}
+
+func (t *CrossBucketRenameTest) SetUpTestSuite() {
+	mtimeClock = timeutil.RealClock()
+	buckets = map[string]gcs.Bucket{
+		"bucket-0": fake.NewFakeBucket(mtimeClock, "bucket-0", gcs.NonHierarchical),
+		"bucket-1": fake.NewFakeBucket(mtimeClock, "bucket-1", gcs.NonHierarchical),
+	}
+	fake.LinkFakeBuckets(buckets["bucket-0"], buckets["bucket-1"])
+	t.serverCfg.NewConfig = &cfg.Config{
+		FileCache: defaultFileCacheConfig(),
+		MetadataCache: cfg.MetadataCacheConfig{
+			StatCacheMaxSizeMb: 32,
+			TtlSecs:            60,
+			TypeCacheMaxSizeMb: 4,
+		},
+		FileSystem: cfg.FileSystemConfig{
+			CrossBucketRenameLimitMb: 1,
+		},
+	}
+	t.fsTest.SetUpTestSuite()
+}
+
+func (t *CrossBucketRenameTest) File() {
+	err := os.WriteFile(path.Join(mntDir, "bucket-0/foo"), []byte("taco"), 0644)
+	AssertEq(nil, err)
+
+	err = os.Rename(path.Join(mntDir, "bucket-0/foo"), path.Join(mntDir, "bucket-1/bar"))
+
+	AssertEq(nil, err)
+	// The object is rewritten into the other bucket, and deleted from its own.
+	contents, err := storageutil.ReadObject(ctx, buckets["bucket-1"], "bar")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+	var notFoundErr *gcs.NotFoundError
+	_, _, err = buckets["bucket-0"].StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	ExpectTrue(errors.As(err, &notFoundErr), "err: %v", err)
+	// And so is the file.
+	contents, err = os.ReadFile(path.Join(mntDir, "bucket-1/bar"))
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+	_, err = os.Stat(path.Join(mntDir, "bucket-0/foo"))
+	ExpectTrue(os.IsNotExist(err), "err: %v", err)
+}
+
+func (t *CrossBucketRenameTest) FileOverLimit() {
+	err := os.WriteFile(path.Join(mntDir, "bucket-0/large"), make([]byte, 1<<20+1), 0644)
+	AssertEq(nil, err)
+
+	err = os.Rename(path.Join(mntDir, "bucket-0/large"), path.Join(mntDir, "bucket-1/large"))
+
+	ExpectThat(err, Error(HasSubstr("invalid cross-device link")))
+	_, _, err = buckets["bucket-0"].StatObject(ctx, &gcs.StatObjectRequest{Name: "large"})
+	ExpectEq(nil, err)
+	_, _, err = buckets["bucket-1"].StatObject(ctx, &gcs.StatObjectRequest{Name: "large"})
+	var notFoundErr *gcs.NotFoundError
+	ExpectTrue(errors.As(err, &notFoundErr), "err: %v", err)
+}
+
+func (t *CrossBucketRenameTest) Directory() {
+	err := os.MkdirAll(path.Join(mntDir, "bucket-0/dir"), 0755)
+	AssertEq(nil, err)
+
+	err = os.Rename(path.Join(mntDir, "bucket-0/dir"), path.Join(mntDir, "bucket-1/dir"))
+
+	ExpectThat(err, Error(HasSubstr("invalid cross-device link")))
+}
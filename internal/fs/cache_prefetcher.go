@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/sync/errgroup"
+)
+
+// CachePrefetcher prefetches the files of a mount into its file cache, on the
+// request of the control socket. It's set up by NewFileSystem when the file
+// cache is enabled, and fails to prefetch otherwise.
+type CachePrefetcher struct {
+	cache  *file.CacheHandler
+	bucket func(name string) (gcs.Bucket, error)
+
+	// The name of the mounted bucket, or empty when all the buckets are
+	// mounted, in which case the first component of the paths is the bucket.
+	bucketName string
+}
+
+// Prefetch prefetches the whole content of the objects matching pattern, a
+// path or a glob relative to the root of the mount as accepted by
+// storageutil.ListGlob, at most parallelism at a time. The objects not
+// admitted by the file cache are skipped.
+func (p *CachePrefetcher) Prefetch(ctx context.Context, pattern string, parallelism int, progress func(name string, size uint64, err error)) error {
+	if p.cache == nil {
+		return errors.New("the file cache is disabled")
+	}
+
+	bucketName, objectPattern := p.bucketName, strings.TrimPrefix(pattern, "/")
+	var namePrefix string
+	if bucketName == "" {
+		bucketName, objectPattern, _ = strings.Cut(objectPattern, "/")
+		if bucketName == "" || strings.ContainsAny(bucketName, `*?[\`) {
+			return fmt.Errorf("%q doesn't start with a bucket name", pattern)
+		}
+		namePrefix = bucketName + "/"
+	}
+	bucket, err := p.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	objects, err := storageutil.ListGlob(ctx, bucket, objectPattern)
+	if err != nil {
+		return fmt.Errorf("listing the objects matching %q: %w", pattern, err)
+	}
+
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(parallelism)
+	for _, o := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			err := p.cache.Prefetch(ctx, o, bucket, int64(o.Size))
+			mu.Lock()
+			defer mu.Unlock()
+			progress(namePrefix+o.Name, o.Size, err)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return ctx.Err()
+}
@@ -61,6 +61,9 @@ func (t *cachingTestCommon) SetUpTestSuite() {
 	statCache := metadata.NewStatCacheBucketView(lruCache, "")
 	bucket = caching.NewFastStatBucket(
 		ttl,
+		0,
+		0,
+		nil,
 		statCache,
 		&cacheClock,
 		uncachedBucket)
@@ -467,6 +470,9 @@ func (t *MultiBucketMountCachingTest) SetUpTestSuite() {
 		statCache := metadata.NewStatCacheBucketView(sharedCache, bucketName)
 		buckets[bucketName] = caching.NewFastStatBucket(
 			ttl,
+			0,
+			0,
+			nil,
 			statCache,
 			&cacheClock,
 			uncachedBuckets[bucketName])
@@ -18,13 +18,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	iofs "io/fs"
 	"math"
 	"os"
 	"path"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -32,24 +35,38 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/accesstrace"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file/downloader"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	cacheutil "github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/handle"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/ignore"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/lifecycle"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/quiesce"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/writequota"
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 	"github.com/jacobsa/timeutil"
+	"golang.org/x/sys/unix"
 )
 
+// BucketUsage reports the bytes stored in the bucket of the mount.
+type BucketUsage interface {
+	UsedBytes(ctx context.Context) (uint64, error)
+}
+
 type ServerConfig struct {
 	// A clock used for cache expiration. It is *not* used for inode times, for
 	// which we use the wall clock.
@@ -129,6 +146,39 @@ type ServerConfig struct {
 	NewConfig *cfg.Config
 
 	MetricHandle common.MetricHandle
+
+	// Counts the ops processed by the file system, if not nil.
+	OpStats *wrappers.OpStats
+
+	// Set up to prefetch the files of the mount into the file cache, if not
+	// nil.
+	CachePrefetcher *CachePrefetcher
+
+	// Set up to compare generations of the objects of the mount, if not nil.
+	GenerationDiffer *GenerationDiffer
+
+	// Set up to report the uploads of the files of the mount, if not nil.
+	UploadMonitor *UploadMonitor
+
+	// Reports the bytes stored in the bucket of the mount to StatFS, if not
+	// nil.
+	BucketUsage BucketUsage
+
+	// LifecycleRules are the lifecycle rules of the bucket for
+	// file-system.lifecycle-hint-window, if not nil.
+	LifecycleRules *lifecycle.Rules
+
+	// The disk budget shared by the file cache and the staging of the writes,
+	// if not nil. The file cache is its first reclaimer.
+	DiskBudget *diskbudget.Budget
+
+	// The read-only files exposed in the .gcsfuse directory at the root of the
+	// mount, if any.
+	VirtualFiles []wrappers.VirtualFile
+
+	// Releases the readers of the open files while the mount is idle, if not
+	// nil.
+	IdleReleaser *quiesce.Releaser
 }
 
 // Create a fuse file system server according to the supplied configuration.
@@ -142,9 +192,28 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 		return nil, fmt.Errorf("illegal dir perms: %v", serverCfg.FilePerms)
 	}
 
+	uidFileModes, err := cfg.ParseUidFileModes(serverCfg.NewConfig.FileSystem.UidFileModes)
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreRules, err := ignore.New(serverCfg.NewConfig.FileSystem.IgnorePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	pinRules, err := ignore.New(serverCfg.NewConfig.FileSystem.PinPatterns)
+	if err != nil {
+		return nil, err
+	}
+
 	mtimeClock := timeutil.RealClock()
 
-	contentCache := contentcache.New(serverCfg.TempDir, mtimeClock)
+	var dirtyBudget *block.DirtyBudget
+	if maxDirtyMb := serverCfg.NewConfig.Write.MaxDirtyMb; maxDirtyMb > 0 {
+		dirtyBudget = block.NewDirtyBudget(maxDirtyMb * cacheutil.MiB)
+	}
+	contentCache := contentcache.New(serverCfg.TempDir, mtimeClock, serverCfg.DiskBudget, dirtyBudget)
 
 	if serverCfg.LocalFileCache {
 		err := contentCache.RecoverCache()
@@ -178,29 +247,71 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 		dirTypeCacheTTL:            serverCfg.DirTypeCacheTTL,
 		kernelListCacheTTL:         cfg.ListCacheTTLSecsToDuration(serverCfg.NewConfig.FileSystem.KernelListCacheTtlSecs),
 		renameDirLimit:             serverCfg.RenameDirLimit,
+		renameJournal:              newRenameJournal(),
 		sequentialReadSizeMb:       serverCfg.SequentialReadSizeMb,
 		uid:                        serverCfg.Uid,
 		gid:                        serverCfg.Gid,
 		fileMode:                   serverCfg.FilePerms,
 		dirMode:                    serverCfg.DirPerms | os.ModeDir,
+		uidFileModes:               uidFileModes,
 		inodes:                     make(map[fuseops.InodeID]inode.Inode),
 		nextInodeID:                fuseops.RootInodeID + 1,
 		generationBackedInodes:     make(map[inode.Name]inode.GenerationBackedInode),
 		implicitDirInodes:          make(map[inode.Name]inode.DirInode),
 		folderInodes:               make(map[inode.Name]inode.DirInode),
 		localFileInodes:            make(map[inode.Name]inode.Inode),
+		pinnedGenerations:          make(map[inode.Name]int64),
+		pinnedObjects:              make(map[inode.Name]*gcs.MinObject),
 		handles:                    make(map[fuseops.HandleID]interface{}),
 		newConfig:                  serverCfg.NewConfig,
 		fileCacheHandler:           fileCacheHandler,
 		cacheFileForRangeRead:      serverCfg.NewConfig.FileCache.CacheFileForRangeRead,
-		metricHandle:               serverCfg.MetricHandle,
+		readCoalescing: gcsx.ReadCoalescing{
+			WindowBytes: serverCfg.NewConfig.Read.CoalesceWindowKb * cacheutil.KiB,
+			Window:      time.Duration(serverCfg.NewConfig.Read.CoalesceWindowMs) * time.Millisecond,
+		},
+		metricHandle:   serverCfg.MetricHandle,
+		bucketUsage:    serverCfg.BucketUsage,
+		lifecycleRules: serverCfg.LifecycleRules,
+		trashPrefix:    trashPrefix(serverCfg.NewConfig.FileSystem.TrashDir),
+		ignoreRules:    ignoreRules,
+		pinRules:       pinRules,
+		writeQuota:     writequota.New(serverCfg.NewConfig.Write.QuotaMb*cacheutil.MiB, serverCfg.NewConfig.Write.QuotaObjects, serverCfg.MetricHandle),
+		dirListings: handle.NewDirListings(
+			int(serverCfg.NewConfig.FileSystem.DirEntriesInitialCapacity),
+			int(serverCfg.NewConfig.FileSystem.DirEntriesMaxCount),
+			uint64(serverCfg.NewConfig.FileSystem.DirEntriesMaxSizeMb)*cacheutil.MiB,
+			serverCfg.MetricHandle),
+	}
+
+	if serverCfg.NewConfig.FileCache.RecordAccessTrace != "" {
+		fs.accessTrace = accesstrace.NewRecorder(accesstrace.DefaultMaxObjects)
 	}
 
 	// Set up root bucket
 	var root inode.DirInode
+	// Returns the bucket of the objects to prefetch or to compare.
+	var prefetchBucket func(name string) (gcs.Bucket, error)
 	if serverCfg.BucketName == "" || serverCfg.BucketName == "_" {
 		logger.Info("Set up root directory for all accessible buckets")
 		root = makeRootForAllBuckets(fs)
+		// Each bucket is set up once, on first use, rather than listed and
+		// garbage collected again for every object.
+		var bucketsMu sync.Mutex
+		buckets := make(map[string]gcs.Bucket)
+		prefetchBucket = func(name string) (gcs.Bucket, error) {
+			bucketsMu.Lock()
+			defer bucketsMu.Unlock()
+			if b, ok := buckets[name]; ok {
+				return b, nil
+			}
+			b, err := fs.bucketManager.SetUpBucket(context.Background(), name, true, fs.metricHandle)
+			if err != nil {
+				return nil, err
+			}
+			buckets[name] = b
+			return b, nil
+		}
 	} else {
 		logger.Info("Set up root directory for bucket " + serverCfg.BucketName)
 		syncerBucket, err := fs.bucketManager.SetUpBucket(ctx, serverCfg.BucketName, false, fs.metricHandle)
@@ -208,6 +319,15 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 			return nil, fmt.Errorf("SetUpBucket: %w", err)
 		}
 		root = makeRootForBucket(ctx, fs, syncerBucket)
+		if fs.trashPrefix != "" && serverCfg.NewConfig.FileSystem.TrashTtl > 0 {
+			fs.purgeTrashPeriodically(syncerBucket, serverCfg.NewConfig.FileSystem.TrashTtl)
+		}
+		prefetchBucket = func(name string) (gcs.Bucket, error) {
+			if name != serverCfg.BucketName {
+				return nil, fmt.Errorf("bucket %q isn't mounted", name)
+			}
+			return syncerBucket, nil
+		}
 	}
 	root.Lock()
 	root.IncrementLookupCount()
@@ -218,9 +338,95 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 
 	// Set up invariant checking.
 	fs.mu = locker.New("FS", fs.checkInvariants)
+
+	if fileCacheHandler != nil && serverCfg.CachePrefetcher != nil {
+		serverCfg.CachePrefetcher.cache = fileCacheHandler
+		serverCfg.CachePrefetcher.bucket = prefetchBucket
+		if serverCfg.BucketName != "_" {
+			serverCfg.CachePrefetcher.bucketName = serverCfg.BucketName
+		}
+	}
+	if serverCfg.GenerationDiffer != nil {
+		serverCfg.GenerationDiffer.bucket = prefetchBucket
+		if serverCfg.BucketName != "_" {
+			serverCfg.GenerationDiffer.bucketName = serverCfg.BucketName
+		}
+	}
+	if serverCfg.UploadMonitor != nil {
+		serverCfg.UploadMonitor.fs = fs
+	}
+	serverCfg.IdleReleaser.RegisterFunc(fs.releaseIdleReaders)
+	if fileCacheHandler != nil && serverCfg.NewConfig.FileCache.PrefetchTrace != "" {
+		fs.prefetchAccessTrace(string(serverCfg.NewConfig.FileCache.PrefetchTrace), prefetchBucket)
+	}
+	if fileCacheHandler != nil && serverCfg.NewConfig.FileCache.EnableChunkChecksums && serverCfg.NewConfig.FileCache.ScrubIntervalSecs > 0 {
+		fs.scrubFileCache(time.Duration(serverCfg.NewConfig.FileCache.ScrubIntervalSecs) * time.Second)
+	}
+	if fileCacheHandler != nil && serverCfg.NewConfig.FileCache.SmallFilePrefetchMaxSizeKb > 0 {
+		fs.smallFiles = newSmallFilePrefetcher(fs.prefetchSmallFile, uint64(serverCfg.NewConfig.FileCache.SmallFilePrefetchMaxSizeKb)*cacheutil.KiB, int(serverCfg.NewConfig.FileCache.SmallFilePrefetchParallelism))
+	}
 	return fs, nil
 }
 
+// prefetchAccessTraceParallelism is the number of objects of the access trace
+// prefetched at a time.
+const prefetchAccessTraceParallelism = 8
+
+// prefetchAccessTrace prefetches the objects of the access trace at the given
+// path into the file cache in the background, until the file system is
+// destroyed.
+func (fs *fileSystem) prefetchAccessTrace(path string, bucket func(name string) (gcs.Bucket, error)) {
+	trace, err := accesstrace.Load(path)
+	if os.IsNotExist(err) {
+		logger.Infof("No access trace to prefetch at %q", path)
+		return
+	}
+	if err != nil {
+		logger.Warnf("Not prefetching the access trace: %v", err)
+		return
+	}
+
+	var ctx context.Context
+	ctx, fs.cancelPrefetch = context.WithCancel(context.Background())
+	go func() {
+		logger.Infof("Prefetching %d objects of the access trace at %q", len(trace.Objects), path)
+		accesstrace.Prefetch(ctx, trace, bucket, fs.fileCacheHandler, prefetchAccessTraceParallelism)
+		logger.Infof("Prefetched the access trace at %q", path)
+	}()
+}
+
+// prefetchSmallFile downloads the given object of a directory whose small
+// files are being read into the file cache, unless it's about to expire.
+func (fs *fileSystem) prefetchSmallFile(ctx context.Context, o *gcs.MinObject, bucket gcs.Bucket) error {
+	if fs.lifecycleRules != nil {
+		if _, expiring := fs.lifecycleRules.Expiring(o.Name, o.Updated, fs.mtimeClock.Now(), fs.newConfig.FileSystem.LifecycleHintWindow); expiring {
+			return nil
+		}
+	}
+	return fs.fileCacheHandler.Prefetch(ctx, o, bucket, int64(o.Size))
+}
+
+// scrubFileCache verifies the chunks of the files in the file cache every
+// interval in the background, until the file system is destroyed.
+func (fs *fileSystem) scrubFileCache(interval time.Duration) {
+	var ctx context.Context
+	ctx, fs.cancelScrub = context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := fs.fileCacheHandler.Scrub(ctx); err != nil && ctx.Err() == nil {
+				logger.Warnf("Failed to scrub the file cache: %v", err)
+			}
+		}
+	}()
+}
+
 func createFileCacheHandler(serverCfg *ServerConfig) (fileCacheHandler *file.CacheHandler, err error) {
 	var sizeInBytes uint64
 	// -1 means unlimited size for cache, the underlying LRU cache doesn't handle
@@ -230,7 +436,7 @@ func createFileCacheHandler(serverCfg *ServerConfig) (fileCacheHandler *file.Cac
 	} else {
 		sizeInBytes = uint64(serverCfg.NewConfig.FileCache.MaxSizeMb) * cacheutil.MiB
 	}
-	fileInfoCache := lru.NewCache(sizeInBytes)
+	fileInfoCache := lru.NewCacheWithDiskBudget(sizeInBytes, serverCfg.DiskBudget)
 
 	cacheDir := string(serverCfg.NewConfig.CacheDir)
 	// Adding a new directory inside cacheDir to keep file-cache separate from
@@ -246,8 +452,17 @@ func createFileCacheHandler(serverCfg *ServerConfig) (fileCacheHandler *file.Cac
 		return nil, fmt.Errorf("createFileCacheHandler: while creating file cache directory: %w", cacheDirErr)
 	}
 
-	jobManager := downloader.NewJobManager(fileInfoCache, filePerm, dirPerm, cacheDir, serverCfg.SequentialReadSizeMb, &serverCfg.NewConfig.FileCache, serverCfg.MetricHandle)
-	fileCacheHandler = file.NewCacheHandler(fileInfoCache, jobManager, cacheDir, filePerm, dirPerm)
+	// The content read through the cache is verified when it's downloaded.
+	fileCacheConfig := serverCfg.NewConfig.FileCache
+	fileCacheConfig.EnableCrc = fileCacheConfig.EnableCrc || serverCfg.NewConfig.Read.VerifyChecksums
+	jobManager := downloader.NewJobManager(fileInfoCache, filePerm, dirPerm, cacheDir, serverCfg.SequentialReadSizeMb, &fileCacheConfig, serverCfg.MetricHandle)
+	var memoryTier *file.MemoryTier
+	if fileCacheConfig.MemoryTierSizeMb > 0 {
+		memoryTier = file.NewMemoryTier(uint64(fileCacheConfig.MemoryTierSizeMb) * cacheutil.MiB)
+	}
+	diskHealth := file.NewDiskHealth(fileCacheConfig.MaxDiskErrors, time.Duration(fileCacheConfig.SlowDiskIoThresholdMs)*time.Millisecond, serverCfg.CacheClock, serverCfg.MetricHandle)
+	fileCacheHandler = file.NewCacheHandler(fileInfoCache, jobManager, cacheDir, filePerm, dirPerm, serverCfg.MetricHandle, memoryTier, file.NewAdmissionPolicy(&fileCacheConfig), diskHealth)
+	serverCfg.DiskBudget.AddReclaimer(fileCacheHandler.ReclaimDiskSpace)
 	return
 }
 
@@ -264,9 +479,9 @@ func makeRootForBucket(
 			Mode: fs.dirMode,
 
 			// We guarantee only that directory times be "reasonable".
-			Atime: fs.mtimeClock.Now(),
-			Ctime: fs.mtimeClock.Now(),
-			Mtime: fs.mtimeClock.Now(),
+			Atime: fs.dirTime(inode.Core{}),
+			Ctime: fs.dirTime(inode.Core{}),
+			Mtime: fs.dirTime(inode.Core{}),
 		},
 		fs.implicitDirs,
 		fs.newConfig.List.EnableEmptyManagedFolders,
@@ -277,6 +492,7 @@ func makeRootForBucket(
 		fs.cacheClock,
 		fs.newConfig.MetadataCache.TypeCacheMaxSizeMb,
 		fs.newConfig.EnableHns,
+		fs.newConfig.FileSystem.DisableSymlinks,
 	)
 }
 
@@ -290,9 +506,9 @@ func makeRootForAllBuckets(fs *fileSystem) inode.DirInode {
 			Mode: fs.dirMode,
 
 			// We guarantee only that directory times be "reasonable".
-			Atime: fs.mtimeClock.Now(),
-			Ctime: fs.mtimeClock.Now(),
-			Mtime: fs.mtimeClock.Now(),
+			Atime: fs.dirTime(inode.Core{}),
+			Ctime: fs.dirTime(inode.Core{}),
+			Mtime: fs.dirTime(inode.Core{}),
 		},
 		fs.bucketManager,
 		fs.metricHandle,
@@ -355,6 +571,10 @@ type fileSystem struct {
 	renameDirLimit       int64
 	sequentialReadSizeMb int32
 
+	// Excludes the directory renames and the mutations of the names within
+	// them, without keeping the directories locked during the renames.
+	renameJournal *renameJournal
+
 	// The user and group owning everything in the file system.
 	uid uint32
 	gid uint32
@@ -363,6 +583,10 @@ type fileSystem struct {
 	fileMode os.FileMode
 	dirMode  os.FileMode
 
+	// The permission bits of the files created by the processes of the UIDs,
+	// with honor-umask.
+	uidFileModes map[uint32]os.FileMode
+
 	/////////////////////////
 	// Mutable state
 	/////////////////////////
@@ -381,7 +605,8 @@ type fileSystem struct {
 	// The collection of live inodes, keyed by inode ID. No ID less than
 	// fuseops.RootInodeID is ever used.
 	//
-	// INVARIANT: For all keys k, fuseops.RootInodeID <= k < nextInodeID
+	// INVARIANT: For all keys k, fuseops.RootInodeID <= k < nextInodeID, unless
+	//            the IDs are derived from the names with sync-tool-compat
 	// INVARIANT: For all keys k, inodes[k].ID() == k
 	// INVARIANT: inodes[fuseops.RootInodeID] is missing or of type inode.DirInode
 	// INVARIANT: For all v, if v.Name().IsDir() then v is inode.DirInode
@@ -458,6 +683,21 @@ type fileSystem struct {
 	// GUARDED_BY(mu)
 	localFileInodes map[inode.Name]inode.Inode
 
+	// The generations of the objects last flushed by the mount by file name,
+	// with read-your-writes. The inodes of older generations of the files
+	// can't be opened anymore. The generation of a name is dropped once the
+	// inode of that generation is destroyed.
+	//
+	// GUARDED_BY(mu)
+	pinnedGenerations map[inode.Name]int64
+
+	// The objects of the files pinned by file-system.pin-patterns by name, as
+	// of their first open. The lookups of the names keep returning them for the
+	// lifetime of the mount.
+	//
+	// GUARDED_BY(mu)
+	pinnedObjects map[inode.Name]*gcs.MinObject
+
 	// The collection of live handles, keyed by handle ID.
 	//
 	// INVARIANT: All values are of type *dirHandle or *handle.FileHandle
@@ -483,7 +723,57 @@ type fileSystem struct {
 	// random file access.
 	cacheFileForRangeRead bool
 
+	// readCoalescing configures the coalescing of the small random reads from
+	// GCS, with read.coalesce-window-kb.
+	readCoalescing gcsx.ReadCoalescing
+
+	// accessTrace records how far the files were read, if
+	// file-cache.record-access-trace is set.
+	accessTrace *accesstrace.Recorder
+
+	// cancelPrefetch cancels the prefetch of the access trace, if any.
+	cancelPrefetch context.CancelFunc
+
+	// cancelScrub stops the scrubbing of the file cache, if any.
+	cancelScrub context.CancelFunc
+
+	// smallFiles prefetches the small files of the directories read file by
+	// file into the file cache, with file-cache.small-file-prefetch-max-size-kb,
+	// if not nil.
+	smallFiles *smallFilePrefetcher
+
+	// trashPrefix is the prefix of the objects in file-system.trash-dir, to
+	// which the unlinked files are moved, or empty to delete them.
+	trashPrefix string
+
+	// cancelPurgeTrash stops the purge of trash-dir, if any.
+	cancelPurgeTrash context.CancelFunc
+
+	// ignoreRules hide the paths matching file-system.ignore-patterns, if not
+	// nil.
+	ignoreRules *ignore.Rules
+
+	// pinRules pin the generation of the files matching
+	// file-system.pin-patterns at their first open, if not nil.
+	pinRules *ignore.Rules
+
 	metricHandle common.MetricHandle
+
+	// bucketUsage reports the bytes stored in the bucket to StatFS, if not nil.
+	bucketUsage BucketUsage
+
+	// lifecycleRules tell apart the objects about to be deleted or moved to
+	// another storage class by the lifecycle rules of the bucket, within
+	// file-system.lifecycle-hint-window, if not nil.
+	lifecycleRules *lifecycle.Rules
+
+	// writeQuota caps the bytes written and the objects created through the
+	// mount, with write.quota-mb and write.quota-objects, if not nil.
+	writeQuota *writequota.Quota
+
+	// dirListings limits the memory of the entries kept by the directory
+	// handles, with the file-system.dir-entries-* configs, if not nil.
+	dirListings *handle.DirListings
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -636,9 +926,10 @@ func (fs *fileSystem) checkInvariantsForGenerationBackedInodes() {
 }
 
 func (fs *fileSystem) checkInvariantsForInodes() {
-	// INVARIANT: For all keys k, fuseops.RootInodeID <= k < nextInodeID
+	// INVARIANT: For all keys k, fuseops.RootInodeID <= k < nextInodeID, unless
+	// the IDs are derived from the names with sync-tool-compat
 	for id := range fs.inodes {
-		if id < fuseops.RootInodeID || id >= fs.nextInodeID {
+		if id < fuseops.RootInodeID || (id >= fs.nextInodeID && !fs.newConfig.FileSystem.SyncToolCompat) {
 			panic(fmt.Sprintf("Illegal inode ID: %v", id))
 		}
 	}
@@ -720,9 +1011,9 @@ func (fs *fileSystem) createExplicitDirInode(inodeID fuseops.InodeID, ic inode.C
 			Mode: fs.dirMode,
 
 			// We guarantee only that directory times be "reasonable".
-			Atime: fs.mtimeClock.Now(),
-			Ctime: fs.mtimeClock.Now(),
-			Mtime: fs.mtimeClock.Now(),
+			Atime: fs.dirTime(ic),
+			Ctime: fs.dirTime(ic),
+			Mtime: fs.dirTime(ic),
 		},
 		fs.implicitDirs,
 		fs.newConfig.List.EnableEmptyManagedFolders,
@@ -732,19 +1023,57 @@ func (fs *fileSystem) createExplicitDirInode(inodeID fuseops.InodeID, ic inode.C
 		fs.mtimeClock,
 		fs.cacheClock,
 		fs.newConfig.MetadataCache.TypeCacheMaxSizeMb,
-		fs.newConfig.EnableHns)
+		fs.newConfig.EnableHns,
+		fs.newConfig.FileSystem.DisableSymlinks)
 
 	return in
 }
 
+// allocateInodeID returns the ID of a new inode with the supplied name. With
+// sync-tool-compat, it's derived from the name, so that it's stable across
+// remounts, skipping the IDs of the live inodes, e.g. of an older generation
+// of the name or of a colliding name.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *fileSystem) allocateInodeID(name inode.Name) fuseops.InodeID {
+	if !fs.newConfig.FileSystem.SyncToolCompat {
+		id := fs.nextInodeID
+		fs.nextInodeID++
+		return id
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(name.String()))
+	id := fuseops.InodeID(h.Sum64())
+	for id <= fuseops.RootInodeID || fs.inodes[id] != nil {
+		id++
+	}
+	return id
+}
+
+// dirTime returns the time reported for the directory with the supplied core,
+// or for the root when empty: the time of its lookup, or with
+// sync-tool-compat, the update time of its object or folder, or else the Unix
+// epoch.
+func (fs *fileSystem) dirTime(ic inode.Core) time.Time {
+	switch {
+	case !fs.newConfig.FileSystem.SyncToolCompat:
+		return fs.mtimeClock.Now()
+	case ic.MinObject != nil:
+		return ic.MinObject.Updated
+	case ic.Folder != nil:
+		return ic.Folder.UpdateTime
+	}
+	return time.Unix(0, 0)
+}
+
 // Implementation detail of lookUpOrCreateInodeIfNotStale; do not use outside
 // of that function.
 //
 // LOCKS_REQUIRED(fs.mu)
 func (fs *fileSystem) mintInode(ic inode.Core) (in inode.Inode) {
 	// Choose an ID.
-	id := fs.nextInodeID
-	fs.nextInodeID++
+	id := fs.allocateInodeID(ic.FullName)
 
 	// Create the inode.
 	switch {
@@ -763,9 +1092,9 @@ func (fs *fileSystem) mintInode(ic inode.Core) (in inode.Inode) {
 				Mode: fs.dirMode,
 
 				// We guarantee only that directory times be "reasonable".
-				Atime: fs.mtimeClock.Now(),
-				Ctime: fs.mtimeClock.Now(),
-				Mtime: fs.mtimeClock.Now(),
+				Atime: fs.dirTime(ic),
+				Ctime: fs.dirTime(ic),
+				Mtime: fs.dirTime(ic),
 			},
 			fs.implicitDirs,
 			fs.newConfig.List.EnableEmptyManagedFolders,
@@ -776,9 +1105,11 @@ func (fs *fileSystem) mintInode(ic inode.Core) (in inode.Inode) {
 			fs.cacheClock,
 			fs.newConfig.MetadataCache.TypeCacheMaxSizeMb,
 			fs.newConfig.EnableHns,
+			fs.newConfig.FileSystem.DisableSymlinks,
 		)
 
-	case inode.IsSymlink(ic.MinObject):
+	// With disable-symlinks, the symlink objects are regular files.
+	case inode.IsSymlink(ic.MinObject) && !fs.newConfig.FileSystem.DisableSymlinks:
 		in = inode.NewSymlinkInode(
 			id,
 			ic.FullName,
@@ -804,7 +1135,8 @@ func (fs *fileSystem) mintInode(ic inode.Core) (in inode.Inode) {
 			fs.contentCache,
 			fs.mtimeClock,
 			ic.Local,
-			fs.newConfig)
+			fs.newConfig,
+			fs.metricHandle)
 	}
 
 	// Place it in our map of IDs to inodes.
@@ -934,8 +1266,9 @@ func (fs *fileSystem) lookUpOrCreateInodeIfNotStale(ic inode.Core) (in inode.Ino
 		}
 
 		// The existing inode is newer than the backing object. The caller
-		// should call again with a newer backing object.
-		if cmp == -1 {
+		// should call again with a newer backing object, unless the backing
+		// object is the pinned generation of the file.
+		if cmp == -1 && !fs.pinnedOver(ic, existingInode) {
 			existingInode.Unlock()
 			return
 		}
@@ -1005,6 +1338,7 @@ func (fs *fileSystem) lookUpOrCreateChildInode(
 			return
 		}
 
+		core = fs.pinnedCore(parent, childName, core)
 		if core == nil {
 			err = fuse.ENOENT
 			return
@@ -1145,6 +1479,9 @@ func (fs *fileSystem) syncFile(
 	if !ok {
 		fs.generationBackedInodes[f.Name()] = f
 	}
+	if fs.newConfig.Read.ReadYourWrites {
+		fs.pinnedGenerations[f.Name()] = max(fs.pinnedGenerations[f.Name()], f.SourceGeneration().Object)
+	}
 	fs.mu.Unlock()
 
 	// We need not update fileIndex:
@@ -1195,6 +1532,11 @@ func (fs *fileSystem) unlockAndDecrementLookupCount(in inode.Inode, N uint64) {
 		if fs.folderInodes[name] == in {
 			delete(fs.folderInodes, name)
 		}
+		if pinned, ok := fs.pinnedGenerations[name]; ok {
+			if f, isFile := in.(*inode.FileInode); isFile && f.SourceGeneration().Object >= pinned {
+				delete(fs.pinnedGenerations, name)
+			}
+		}
 		fs.mu.Unlock()
 	}
 
@@ -1281,6 +1623,21 @@ func (fs *fileSystem) inodeOrDie(id fuseops.InodeID) (in inode.Inode) {
 	return
 }
 
+// inodePath returns the path of the inode with the given ID, relative to the
+// root of the mount, or "" if it doesn't exist. The paths of the directories
+// end with a slash, but for the root.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) inodePath(id fuseops.InodeID) string {
+	fs.mu.Lock()
+	in := fs.inodes[id]
+	fs.mu.Unlock()
+	if in == nil {
+		return ""
+	}
+	return in.Name().LocalName()
+}
+
 // dirInodeOrDie returns the directory inode with the given ID, panicking with
 // a helpful error message if it doesn't exist or is the wrong type.
 //
@@ -1353,6 +1710,24 @@ func (fs *fileSystem) invalidateChildFileCacheIfExist(parentInode inode.DirInode
 ////////////////////////////////////////////////////////////////////////
 
 func (fs *fileSystem) Destroy() {
+	if fs.cancelPrefetch != nil {
+		fs.cancelPrefetch()
+	}
+	if fs.cancelPurgeTrash != nil {
+		fs.cancelPurgeTrash()
+	}
+	if fs.cancelScrub != nil {
+		fs.cancelScrub()
+	}
+	if fs.smallFiles != nil {
+		fs.smallFiles.stop()
+	}
+	if fs.accessTrace != nil {
+		path := string(fs.newConfig.FileCache.RecordAccessTrace)
+		if err := fs.accessTrace.Save(path); err != nil {
+			logger.Errorf("Failed to save the access trace to %q: %v", path, err)
+		}
+	}
 	fs.bucketManager.ShutDown()
 	if fs.fileCacheHandler != nil {
 		_ = fs.fileCacheHandler.Destroy()
@@ -1362,13 +1737,28 @@ func (fs *fileSystem) Destroy() {
 func (fs *fileSystem) StatFS(
 	ctx context.Context,
 	op *fuseops.StatFSOp) (err error) {
-	// Simulate a large amount of free space so that the Finder doesn't refuse to
-	// copy in files. (See issue #125.) Use 2^17 as the block size because that
-	// is the largest that OS X will pass on.
+	// Unless file-system.statfs-size-mb is set, simulate a large amount of
+	// space so that the Finder doesn't refuse to copy in files. (See issue
+	// #125.) Use 2^17 as the block size because that is the largest that OS X
+	// will pass on.
 	op.BlockSize = 1 << 17
 	op.Blocks = 1 << 33
-	op.BlocksFree = op.Blocks
-	op.BlocksAvailable = op.Blocks
+	if sizeMb := fs.newConfig.FileSystem.StatfsSizeMb; sizeMb > 0 {
+		op.Blocks = uint64(sizeMb) * cacheutil.MiB / uint64(op.BlockSize)
+	}
+
+	// The space used is the bytes stored in the bucket, if known. Never report
+	// that no space is free, or the tools checking df refuse to write.
+	var usedBlocks uint64
+	if fs.bucketUsage != nil {
+		used, err := fs.bucketUsage.UsedBytes(ctx)
+		if err != nil {
+			logger.Warnf("StatFS: reporting no used space: %v", err)
+		}
+		usedBlocks = (used + uint64(op.BlockSize) - 1) / uint64(op.BlockSize)
+	}
+	op.BlocksFree = op.Blocks - min(usedBlocks, op.Blocks-1)
+	op.BlocksAvailable = op.BlocksFree
 
 	// Similarly with inodes.
 	op.Inodes = 1 << 50
@@ -1397,12 +1787,22 @@ func (fs *fileSystem) LookUpInode(
 	parent := fs.dirInodeOrDie(op.Parent)
 	fs.mu.Unlock()
 
+	if fs.ignored(parent, op.Name, false) && fs.ignored(parent, op.Name, true) {
+		return fuse.ENOENT
+	}
+
 	// Find or create the child inode.
 	child, err := fs.lookUpOrCreateChildInode(ctx, parent, op.Name)
 	if err != nil {
 		return err
 	}
 
+	// The child may be hidden only if it's e.g. a directory.
+	if fs.ignored(parent, op.Name, child.Name().IsDir()) {
+		fs.unlockAndDecrementLookupCount(child, 1)
+		return fuse.ENOENT
+	}
+
 	defer fs.unlockAndMaybeDisposeOfInode(child, &err)
 
 	// Fill out the response.
@@ -1476,6 +1876,9 @@ func (fs *fileSystem) SetInodeAttributes(
 
 	// Truncate files.
 	if isFile && op.Size != nil {
+		if fs.pinned(file.Name()) {
+			return fmt.Errorf("truncate %q, pinned by pin-patterns: %w", file.Name().GcsObjectName(), syscall.EROFS)
+		}
 		err = file.Truncate(ctx, int64(*op.Size))
 		if err != nil {
 			err = fmt.Errorf("truncate: %w", err)
@@ -1522,6 +1925,19 @@ func (fs *fileSystem) MkDir(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
+	if err = fs.checkNotIgnored(op.Parent, op.Name, true); err != nil {
+		return err
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, true)
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := fs.reserveObject(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
 	// Find the parent.
 	fs.mu.Lock()
 	parent := fs.dirInodeOrDie(op.Parent)
@@ -1581,6 +1997,19 @@ func (fs *fileSystem) MkNode(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
+	if err = fs.checkNotIgnored(op.Parent, op.Name, false); err != nil {
+		return err
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, false)
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := fs.reserveObject(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
 	if (op.Mode & (iofs.ModeNamedPipe | iofs.ModeSocket)) != 0 {
 		return syscall.ENOTSUP
 	}
@@ -1592,6 +2021,7 @@ func (fs *fileSystem) MkNode(
 	}
 
 	defer fs.unlockAndMaybeDisposeOfInode(child, &err)
+	fs.setCreationMode(child, op.OpContext, op.Mode)
 
 	// Fill out the response.
 	e := &op.Entry
@@ -1652,6 +2082,111 @@ func (fs *fileSystem) createFile(
 	return
 }
 
+// setCreationMode sets, with honor-umask, the permission bits of the supplied
+// file created by the process of the supplied op context: the ones of
+// uid-file-modes for its UID, or else the supplied ones it requested, already
+// masked by its umask by the kernel.
+//
+// LOCKS_REQUIRED(child)
+func (fs *fileSystem) setCreationMode(child inode.Inode, opCtx fuseops.OpContext, mode os.FileMode) {
+	if !fs.newConfig.FileSystem.HonorUmask {
+		return
+	}
+	if uidMode, ok := fs.uidFileModes[opCtx.Uid]; ok {
+		mode = uidMode
+	}
+	child.(*inode.FileInode).SetCreationMode(mode)
+}
+
+// reserveObject reserves the object created by an op in the write quota,
+// returning a function to be deferred with the error of the op, which
+// releases it if the op failed.
+func (fs *fileSystem) reserveObject(ctx context.Context) (release func(*error), err error) {
+	if err = fs.writeQuota.ReserveObject(ctx); err != nil {
+		return nil, err
+	}
+	return func(opErr *error) {
+		if *opErr != nil {
+			fs.writeQuota.ReleaseObject()
+		}
+	}, nil
+}
+
+// ignored returns true if the child with the supplied name of the supplied
+// parent is hidden by file-system.ignore-patterns. The buckets of the base
+// directory are never hidden.
+func (fs *fileSystem) ignored(parent inode.DirInode, childName string, isDir bool) bool {
+	if _, ok := parent.(inode.BucketOwnedInode); !ok {
+		return false
+	}
+	return fs.ignoreRules.Match(path.Join(parent.Name().GcsObjectName(), childName), isDir)
+}
+
+// checkNotIgnored returns EPERM if the child to be created with the supplied
+// name of the supplied parent would be hidden by file-system.ignore-patterns.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) checkNotIgnored(parentID fuseops.InodeID, childName string, isDir bool) error {
+	if fs.ignoreRules == nil {
+		return nil
+	}
+	fs.mu.Lock()
+	parent := fs.dirInodeOrDie(parentID)
+	fs.mu.Unlock()
+
+	if fs.ignored(parent, childName, isDir) {
+		return fmt.Errorf("create %q, hidden by ignore-patterns: %w", childName, syscall.EPERM)
+	}
+	return nil
+}
+
+// pinned returns true if the file with the supplied name is pinned by
+// file-system.pin-patterns.
+func (fs *fileSystem) pinned(name inode.Name) bool {
+	return fs.pinRules.Match(name.GcsObjectName(), false)
+}
+
+// pinnedCore returns the core of the pinned object of the child with the
+// supplied name of the supplied parent in place of the supplied core, nil if
+// the child no longer exists, if it's of another generation.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) pinnedCore(parent inode.DirInode, childName string, core *inode.Core) *inode.Core {
+	if fs.pinRules == nil {
+		return core
+	}
+	owned, ok := parent.(inode.BucketOwnedInode)
+	if !ok {
+		return core
+	}
+	name := inode.NewFileName(parent.Name(), childName)
+
+	fs.mu.Lock()
+	pinned, ok := fs.pinnedObjects[name]
+	fs.mu.Unlock()
+	if !ok || (core != nil && core.MinObject != nil && core.FullName == name && core.MinObject.Generation == pinned.Generation) {
+		return core
+	}
+
+	o := *pinned
+	return &inode.Core{
+		FullName:  name,
+		Bucket:    owned.Bucket(),
+		MinObject: &o,
+	}
+}
+
+// pinnedOver returns true if the backing object of the supplied core is the
+// pinned generation of its file, and the supplied inode of the name is of
+// another generation, which it replaces.
+//
+// LOCKS_REQUIRED(fs.mu)
+// LOCKS_REQUIRED(existing)
+func (fs *fileSystem) pinnedOver(ic inode.Core, existing inode.GenerationBackedInode) bool {
+	pinned, ok := fs.pinnedObjects[ic.FullName]
+	return ok && ic.MinObject.Generation == pinned.Generation && existing.SourceGeneration().Object != pinned.Generation
+}
+
 // Creates localFileInode with the given name under the parent inode.
 // LOCKS_EXCLUDED(fs.mu)
 // UNLOCK_FUNCTION(fs.mu)
@@ -1717,6 +2252,19 @@ func (fs *fileSystem) CreateFile(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
+	if err = fs.checkNotIgnored(op.Parent, op.Name, false); err != nil {
+		return err
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, false)
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := fs.reserveObject(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
 	// Create the child.
 	var child inode.Inode
 	if fs.newConfig.Write.CreateEmptyFile {
@@ -1730,6 +2278,7 @@ func (fs *fileSystem) CreateFile(
 	}
 
 	defer fs.unlockAndMaybeDisposeOfInode(child, &err)
+	fs.setCreationMode(child, op.OpContext, op.Mode)
 
 	// Allocate a handle.
 	fs.mu.Lock()
@@ -1738,7 +2287,7 @@ func (fs *fileSystem) CreateFile(
 	fs.nextHandleID++
 
 	// Creating new file is always a write operation, hence passing readOnly as false.
-	fs.handles[handleID] = handle.NewFileHandle(child.(*inode.FileInode), fs.fileCacheHandler, fs.cacheFileForRangeRead, fs.metricHandle, false)
+	fs.handles[handleID] = handle.NewFileHandle(child.(*inode.FileInode), fs.fileCacheHandler, fs.cacheFileForRangeRead, fs.newConfig.Read.VerifyChecksums, fs.readCoalescing, fs.newConfig.Read.ColumnarFooterKb*cacheutil.KiB, fs.metricHandle, false, fs.newConfig.Read.MaxConcurrentReadsPerHandle)
 	op.Handle = handleID
 
 	fs.mu.Unlock()
@@ -1756,10 +2305,14 @@ func (fs *fileSystem) CreateFile(
 	return
 }
 
+// CreateLink emulates a hard link with a server-side copy of the object of
+// the target file, which is synced first. The link is a separate file: the
+// writes through either name aren't seen through the other one.
+//
 // LOCKS_EXCLUDED(fs.mu)
-func (fs *fileSystem) CreateSymlink(
+func (fs *fileSystem) CreateLink(
 	ctx context.Context,
-	op *fuseops.CreateSymlinkOp) (err error) {
+	op *fuseops.CreateLinkOp) (err error) {
 	if fs.newConfig.FileSystem.IgnoreInterrupts {
 		// When ignore interrupts config is set, we are creating a new context not
 		// cancellable by parent context.
@@ -1767,14 +2320,49 @@ func (fs *fileSystem) CreateSymlink(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
-	// Find the parent.
+	if err = fs.checkNotIgnored(op.Parent, op.Name, false); err != nil {
+		return err
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, false)
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := fs.reserveObject(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
+	// Find the parent and the target.
 	fs.mu.Lock()
 	parent := fs.dirInodeOrDie(op.Parent)
+	target := fs.inodes[op.Target]
 	fs.mu.Unlock()
 
-	// Create the object in GCS, failing if it already exists.
+	file, ok := target.(*inode.FileInode)
+	if !ok {
+		// Like link(2), which doesn't allow hard links to directories.
+		if _, ok := target.(inode.DirInode); ok {
+			return syscall.EPERM
+		}
+		return fmt.Errorf("link to %T: %w", target, syscall.ENOTSUP)
+	}
+	if p, ok := parent.(inode.BucketOwnedInode); !ok || p.Bucket().Name() != file.Bucket().Name() {
+		return syscall.EXDEV
+	}
+
+	// Sync the file so that the link gets its latest contents.
+	file.Lock()
+	err = fs.syncFile(ctx, file)
+	src := file.Source()
+	file.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Copy the object, failing if the name already exists.
 	parent.Lock()
-	result, err := parent.CreateChildSymlink(ctx, op.Name, op.Target)
+	result, err := parent.CloneToNewChildFile(ctx, op.Name, src)
 	parent.Unlock()
 
 	// Special case: *gcs.PreconditionError means the name already exists.
@@ -1786,7 +2374,7 @@ func (fs *fileSystem) CreateSymlink(
 
 	// Propagate other errors.
 	if err != nil {
-		err = fmt.Errorf("CreateChildSymlink: %w", err)
+		err = fmt.Errorf("CloneToNewChildFile: %w", err)
 		return err
 	}
 
@@ -1815,21 +2403,12 @@ func (fs *fileSystem) CreateSymlink(
 }
 
 // LOCKS_EXCLUDED(fs.mu)
-func (fs *fileSystem) RmDir(
-	// When rm -r or os.RemoveAll call is made, the following calls are made in order
-	//	 1. RmDir (only in the case of os.RemoveAll)
-	//	 2. Unlink all nested files,
-	//	 3. lookupInode call on implicit directory
-	//	 4. Rmdir on the directory.
-	//
-	// When type cache ttl is set, we construct an implicitDir even though one doesn't
-	// exist on GCS (https://github.com/GoogleCloudPlatform/gcsfuse/blob/master/internal/fs/inode/dir.go#L452),
-	// and thus, we get rmDir call to GCSFuse.
-	// Whereas when ttl is zero, lookupInode call itself fails and RmDir is not called
-	// because object is not present in GCS.
-
+func (fs *fileSystem) CreateSymlink(
 	ctx context.Context,
-	op *fuseops.RmDirOp) (err error) {
+	op *fuseops.CreateSymlinkOp) (err error) {
+	if fs.newConfig.FileSystem.DisableSymlinks {
+		return syscall.EPERM
+	}
 	if fs.newConfig.FileSystem.IgnoreInterrupts {
 		// When ignore interrupts config is set, we are creating a new context not
 		// cancellable by parent context.
@@ -1837,21 +2416,109 @@ func (fs *fileSystem) RmDir(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
+	if err = fs.checkNotIgnored(op.Parent, op.Name, false); err != nil {
+		return err
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, false)
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := fs.reserveObject(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(&err)
 	// Find the parent.
 	fs.mu.Lock()
 	parent := fs.dirInodeOrDie(op.Parent)
 	fs.mu.Unlock()
 
-	// Find or create the child inode, locked.
-	child, err := fs.lookUpOrCreateChildInode(ctx, parent, op.Name)
-	if err != nil {
+	// Create the object in GCS, failing if it already exists.
+	parent.Lock()
+	result, err := parent.CreateChildSymlink(ctx, op.Name, op.Target)
+	parent.Unlock()
+
+	// Special case: *gcs.PreconditionError means the name already exists.
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		err = fuse.EEXIST
 		return
 	}
 
-	// Set up a function that throws away the lookup count increment that we
-	// implicitly did above (since we're not handing the child back to the
-	// kernel) and unlocks the child, but only once. Ensure it is called at least
-	// once in case we exit early.
+	// Propagate other errors.
+	if err != nil {
+		err = fmt.Errorf("CreateChildSymlink: %w", err)
+		return err
+	}
+
+	// Attempt to create a child inode using the object we created. If we fail to
+	// do so, it means someone beat us to the punch with a newer generation
+	// (unlikely, so we're probably okay with failing here).
+	child := fs.lookUpOrCreateInodeIfNotStale(*result)
+	if child == nil {
+		err = fmt.Errorf("newly-created record is already stale")
+		return err
+	}
+
+	defer fs.unlockAndMaybeDisposeOfInode(child, &err)
+
+	// Fill out the response.
+	e := &op.Entry
+	e.Child = child.ID()
+	e.Attributes, e.AttributesExpiration, err = fs.getAttributes(ctx, child)
+
+	if err != nil {
+		err = fmt.Errorf("getAttributes: %w", err)
+		return err
+	}
+
+	return
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) RmDir(
+	// When rm -r or os.RemoveAll call is made, the following calls are made in order
+	//	 1. RmDir (only in the case of os.RemoveAll)
+	//	 2. Unlink all nested files,
+	//	 3. lookupInode call on implicit directory
+	//	 4. Rmdir on the directory.
+	//
+	// When type cache ttl is set, we construct an implicitDir even though one doesn't
+	// exist on GCS (https://github.com/GoogleCloudPlatform/gcsfuse/blob/master/internal/fs/inode/dir.go#L452),
+	// and thus, we get rmDir call to GCSFuse.
+	// Whereas when ttl is zero, lookupInode call itself fails and RmDir is not called
+	// because object is not present in GCS.
+
+	ctx context.Context,
+	op *fuseops.RmDirOp) (err error) {
+	if fs.newConfig.FileSystem.IgnoreInterrupts {
+		// When ignore interrupts config is set, we are creating a new context not
+		// cancellable by parent context.
+		var cancel context.CancelFunc
+		ctx, cancel = util.IsolateContextFromParentContext(ctx)
+		defer cancel()
+	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, true)
+	if err != nil {
+		return err
+	}
+	defer done()
+	// Find the parent.
+	fs.mu.Lock()
+	parent := fs.dirInodeOrDie(op.Parent)
+	fs.mu.Unlock()
+
+	// Find or create the child inode, locked.
+	child, err := fs.lookUpOrCreateChildInode(ctx, parent, op.Name)
+	if err != nil {
+		return
+	}
+
+	// Set up a function that throws away the lookup count increment that we
+	// implicitly did above (since we're not handing the child back to the
+	// kernel) and unlocks the child, but only once. Ensure it is called at least
+	// once in case we exit early.
 	childCleanedUp := false
 	cleanUpAndUnlockChild := func() {
 		if !childCleanedUp {
@@ -1926,6 +2593,16 @@ func (fs *fileSystem) RmDir(
 	err = parent.DeleteChildDir(ctx, op.Name, isImplicitDir, childDir)
 	parent.Unlock()
 
+	// On hierarchical buckets, the folder may have been deleted or filled
+	// concurrently since it was listed above.
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return fuse.ENOENT
+	}
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		return fuse.ENOTEMPTY
+	}
 	if err != nil {
 		err = fmt.Errorf("DeleteChildDir: %w", err)
 		return err
@@ -1957,11 +2634,16 @@ func (fs *fileSystem) Rename(
 		// is to rename a bucket, which is not supported.
 		return fmt.Errorf("rename a bucket: %w", syscall.ENOTSUP)
 	} else {
-		// The target path must exist in the same bucket.
+		// The target path must exist in a bucket.
 		oldBucket := oldInode.Bucket().Name()
-		if newInode, ok := newParent.(inode.BucketOwnedInode); !ok || oldBucket != newInode.Bucket().Name() {
+		newInode, ok := newParent.(inode.BucketOwnedInode)
+		if !ok {
 			return fmt.Errorf("move out of bucket %q: %w", oldBucket, syscall.ENOTSUP)
 		}
+		// In dynamic mounts, the target path may be in another bucket.
+		if newBucket := newInode.Bucket().Name(); oldBucket != newBucket {
+			return fs.renameAcrossBuckets(ctx, oldParent, oldBucket, op.OldName, newParent, newBucket, op.NewName)
+		}
 	}
 
 	// If object to be renamed is a local file inode (un-synced), rename operation is not supported.
@@ -1986,7 +2668,17 @@ func (fs *fileSystem) Rename(
 		return err
 	}
 
+	if fs.ignored(newParent, op.NewName, child.FullName.IsDir()) {
+		return fmt.Errorf("rename to %q, hidden by ignore-patterns: %w", op.NewName, syscall.EPERM)
+	}
+
 	if child.FullName.IsDir() {
+		done, err := fs.renameJournal.startRename(ctx, child.FullName, inode.NewDirName(newParent.Name(), op.NewName))
+		if err != nil {
+			return err
+		}
+		defer done()
+
 		// If 'enable-hns' flag is false, the bucket type is set to 'NonHierarchical' even for HNS buckets because the control client is nil.
 		// Therefore, an additional 'enable hns' check is not required here.
 		if child.Bucket.BucketType() == gcs.Hierarchical {
@@ -1994,6 +2686,12 @@ func (fs *fileSystem) Rename(
 		}
 		return fs.renameNonHierarchicalDir(ctx, oldParent, op.OldName, newParent, op.NewName)
 	}
+
+	done, err := fs.renameJournal.startMutation(ctx, child.FullName, inode.NewFileName(newParent.Name(), op.NewName))
+	if err != nil {
+		return err
+	}
+	defer done()
 	return fs.renameFile(ctx, oldParent, op.OldName, child.MinObject, newParent, op.NewName)
 }
 
@@ -2017,10 +2715,22 @@ func (fs *fileSystem) renameFile(
 		return err
 	}
 
+	return fs.deleteRenamedFile(ctx, oldParent, oldName, oldObject)
+}
+
+// deleteRenamedFile deletes the source of a file renamed by cloning it.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_EXCLUDED(oldParent)
+func (fs *fileSystem) deleteRenamedFile(
+	ctx context.Context,
+	oldParent inode.DirInode,
+	oldName string,
+	oldObject *gcs.MinObject) error {
 	// Delete behind. Make sure to delete exactly the generation we cloned, in
 	// case the referent of the name has changed in the meantime.
 	oldParent.Lock()
-	err = oldParent.DeleteChildFile(
+	err := oldParent.DeleteChildFile(
 		ctx,
 		oldName,
 		oldObject.Generation,
@@ -2040,6 +2750,80 @@ func (fs *fileSystem) renameFile(
 	return nil
 }
 
+// renameAcrossBuckets moves a file between the directories of two buckets of
+// a dynamic mount, GCS rewriting its object into the new bucket before the old
+// one is deleted. The renames of directories, of unsynced files and of files
+// larger than cross-bucket-rename-limit-mb fail with EXDEV, for which mv
+// copies the contents itself.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_EXCLUDED(oldParent)
+// LOCKS_EXCLUDED(newParent)
+func (fs *fileSystem) renameAcrossBuckets(
+	ctx context.Context,
+	oldParent inode.DirInode,
+	oldBucket string,
+	oldName string,
+	newParent inode.DirInode,
+	newBucket string,
+	newName string) error {
+	limitMb := fs.newConfig.FileSystem.CrossBucketRenameLimitMb
+	if limitMb <= 0 {
+		return fmt.Errorf("move out of bucket %q: %w", oldBucket, syscall.EXDEV)
+	}
+
+	if localChild := fs.lookUpLocalFileInode(oldParent, oldName); localChild != nil {
+		fs.unlockAndDecrementLookupCount(localChild, 1)
+		return fmt.Errorf("move open file %q out of bucket %q: %w", oldName, oldBucket, syscall.EXDEV)
+	}
+
+	oldParent.Lock()
+	child, err := oldParent.LookUpChild(ctx, oldName)
+	oldParent.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("LookUpChild: %w", err)
+	}
+
+	if child == nil {
+		return fuse.ENOENT
+	}
+
+	if child.FullName.IsDir() {
+		return fmt.Errorf("move directory %q out of bucket %q: %w", oldName, oldBucket, syscall.EXDEV)
+	}
+
+	if child.MinObject.Size > uint64(limitMb)*cacheutil.MiB {
+		return fmt.Errorf("move %q of %d bytes out of bucket %q, over cross-bucket-rename-limit-mb: %w", oldName, child.MinObject.Size, oldBucket, syscall.EXDEV)
+	}
+
+	if fs.ignored(newParent, newName, false) {
+		return fmt.Errorf("rename to %q, hidden by ignore-patterns: %w", newName, syscall.EPERM)
+	}
+
+	done, err := fs.renameJournal.startMutation(ctx, child.FullName, inode.NewFileName(newParent.Name(), newName))
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	oldObject := child.MinObject
+	progress := func(copiedBytes, totalBytes uint64) {
+		logger.Infof("Rewriting %q from bucket %q into bucket %q: %d of %d bytes copied", oldObject.Name, oldBucket, newBucket, copiedBytes, totalBytes)
+	}
+
+	// Rewrite into the new location.
+	newParent.Lock()
+	_, err = newParent.CloneFromBucketToChildFile(ctx, newName, oldBucket, oldObject, progress)
+	newParent.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("CloneFromBucketToChildFile: %w", err)
+	}
+
+	return fs.deleteRenamedFile(ctx, oldParent, oldName, oldObject)
+}
+
 func (fs *fileSystem) releaseInodes(inodes *[]inode.DirInode) {
 	for _, in := range *inodes {
 		fs.unlockAndDecrementLookupCount(in, 1)
@@ -2117,9 +2901,12 @@ func (fs *fileSystem) renameHierarchicalDir(ctx context.Context, oldParent inode
 		// The RenameFolder API does not allow renaming to an existing empty directory.
 		// To make this work, we delete the empty directory first from gcsfuse and then perform rename.
 		newParent.Lock()
-		_ = newParent.DeleteChildDir(ctx, newName, false, newDirInode)
+		err = newParent.DeleteChildDir(ctx, newName, false, newDirInode)
 		newParent.Unlock()
 		pendingInodes = append(pendingInodes, newDirInode)
+		if err != nil {
+			return fmt.Errorf("DeleteChildDir: %w", err)
+		}
 	}
 
 	// Note:The renameDirLimit is not utilized in the folder rename operation because there is no user-defined limit on new renames.
@@ -2202,24 +2989,19 @@ func (fs *fileSystem) renameNonHierarchicalDir(
 		return err
 	}
 
-	// Move all the files from the old directory to the new directory, keeping both directories locked.
-	for _, descendant := range descendants {
-		nameDiff := strings.TrimPrefix(descendant.FullName.GcsObjectName(), oldDir.Name().GcsObjectName())
-		if nameDiff == descendant.FullName.GcsObjectName() {
-			return fmt.Errorf("unwanted descendant %q not from dir %q", descendant.FullName, oldDir.Name())
-		}
-
-		o := descendant.MinObject
-		if _, err := newDir.CloneToChildFile(ctx, nameDiff, o); err != nil {
-			return fmt.Errorf("copy file %q: %w", o.Name, err)
-		}
-		if err := oldDir.DeleteChildFile(ctx, nameDiff, o.Generation, &o.MetaGeneration); err != nil {
-			return fmt.Errorf("delete file %q: %w", o.Name, err)
-		}
-
-		if err = fs.invalidateChildFileCacheIfExist(oldDir, o.Name); err != nil {
-			return fmt.Errorf("unlink: while invalidating cache for delete file: %w", err)
-		}
+	// Move all the files from the old directory to the new directory. The
+	// directories are only locked while each file is moved, so that the
+	// lookups in them don't stall until the whole directory is moved. The
+	// rename journal keeps the mutations within them waiting meanwhile.
+	for _, in := range pendingInodes {
+		in.Unlock()
+	}
+	err = fs.moveDescendants(ctx, oldDir, newDir, descendants)
+	for _, in := range pendingInodes {
+		in.Lock()
+	}
+	if err != nil {
+		return err
 	}
 
 	fs.releaseInodes(&pendingInodes)
@@ -2238,6 +3020,69 @@ func (fs *fileSystem) renameNonHierarchicalDir(
 	return nil
 }
 
+// Move the supplied descendants of oldDir to newDir, locking each of them
+// only while a file is copied to it or deleted from it.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_EXCLUDED(oldDir)
+// LOCKS_EXCLUDED(newDir)
+func (fs *fileSystem) moveDescendants(
+	ctx context.Context,
+	oldDir inode.BucketOwnedDirInode,
+	newDir inode.BucketOwnedDirInode,
+	descendants map[inode.Name]*inode.Core) error {
+	for _, descendant := range descendants {
+		nameDiff := strings.TrimPrefix(descendant.FullName.GcsObjectName(), oldDir.Name().GcsObjectName())
+		if nameDiff == descendant.FullName.GcsObjectName() {
+			return fmt.Errorf("unwanted descendant %q not from dir %q", descendant.FullName, oldDir.Name())
+		}
+
+		o := descendant.MinObject
+		newDir.Lock()
+		_, err := newDir.CloneToChildFile(ctx, nameDiff, o)
+		newDir.Unlock()
+		if err != nil {
+			return fmt.Errorf("copy file %q: %w", o.Name, err)
+		}
+
+		oldDir.Lock()
+		err = oldDir.DeleteChildFile(ctx, nameDiff, o.Generation, &o.MetaGeneration)
+		if err == nil {
+			if err = fs.invalidateChildFileCacheIfExist(oldDir, o.Name); err != nil {
+				err = fmt.Errorf("unlink: while invalidating cache for delete file: %w", err)
+			}
+		} else {
+			err = fmt.Errorf("delete file %q: %w", o.Name, err)
+		}
+		oldDir.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startChildMutation waits until no directory rename covering the child with
+// the supplied name of the supplied parent is in progress, and records its
+// mutation in the rename journal until the returned function is called.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) startChildMutation(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	childName string,
+	isDir bool) (done func(), err error) {
+	fs.mu.Lock()
+	parent := fs.dirInodeOrDie(parentID)
+	fs.mu.Unlock()
+
+	name := inode.NewFileName(parent.Name(), childName)
+	if isDir {
+		name = inode.NewDirName(parent.Name(), childName)
+	}
+	return fs.renameJournal.startMutation(ctx, name)
+}
+
 // LOCKS_EXCLUDED(fs.mu)
 func (fs *fileSystem) Unlink(
 	ctx context.Context,
@@ -2249,6 +3094,11 @@ func (fs *fileSystem) Unlink(
 		ctx, cancel = util.IsolateContextFromParentContext(ctx)
 		defer cancel()
 	}
+	done, err := fs.startChildMutation(ctx, op.Parent, op.Name, false)
+	if err != nil {
+		return err
+	}
+	defer done()
 
 	fs.mu.Lock()
 
@@ -2282,19 +3132,27 @@ func (fs *fileSystem) Unlink(
 		return
 	}
 
-	// Delete the backing object present on GCS.
+	// Delete the backing object present on GCS, or move it to the trash.
 	parent.Lock()
 	defer parent.Unlock()
 
-	err = parent.DeleteChildFile(
-		ctx,
-		op.Name,
-		0,   // Latest generation
-		nil) // No meta-generation precondition
+	if trashName := fs.trashName(fileName); trashName != "" {
+		err = parent.TrashChildFile(ctx, op.Name, trashName)
+		if err != nil {
+			err = fmt.Errorf("TrashChildFile: %w", err)
+			return err
+		}
+	} else {
+		err = parent.DeleteChildFile(
+			ctx,
+			op.Name,
+			0,   // Latest generation
+			nil) // No meta-generation precondition
 
-	if err != nil {
-		err = fmt.Errorf("DeleteChildFile: %w", err)
-		return err
+		if err != nil {
+			err = fmt.Errorf("DeleteChildFile: %w", err)
+			return err
+		}
 	}
 
 	if err := fs.invalidateChildFileCacheIfExist(parent, fileName.GcsObjectName()); err != nil {
@@ -2319,7 +3177,11 @@ func (fs *fileSystem) OpenDir(
 	handleID := fs.nextHandleID
 	fs.nextHandleID++
 
-	fs.handles[handleID] = handle.NewDirHandle(in, fs.implicitDirs)
+	var hidden func(name string, isDir bool) bool
+	if fs.ignoreRules != nil {
+		hidden = func(name string, isDir bool) bool { return fs.ignored(in, name, isDir) }
+	}
+	fs.handles[handleID] = handle.NewDirHandle(in, fs.implicitDirs, hidden, fs.dirListings)
 	op.Handle = handleID
 
 	fs.mu.Unlock()
@@ -2355,6 +3217,12 @@ func (fs *fileSystem) ReadDir(
 	localFileEntries := in.LocalFileEntries(fs.localFileInodes)
 	fs.mu.Unlock()
 
+	if fs.smallFiles != nil && op.Offset == 0 {
+		if b, ok := in.(inode.BucketOwnedDirInode); ok {
+			fs.smallFiles.listed(b.Bucket(), in.Name().GcsObjectName())
+		}
+	}
+
 	dh.Mu.Lock()
 	defer dh.Mu.Unlock()
 	// Serve the request.
@@ -2373,9 +3241,10 @@ func (fs *fileSystem) ReleaseDirHandle(
 	defer fs.mu.Unlock()
 
 	// Sanity check that this handle exists and is of the correct type.
-	_ = fs.handles[op.Handle].(*handle.DirHandle)
+	dh := fs.handles[op.Handle].(*handle.DirHandle)
 
-	// Clear the entry from the map.
+	// Release its entries, and clear the entry from the map.
+	dh.Destroy()
 	delete(fs.handles, op.Handle)
 
 	return
@@ -2399,18 +3268,57 @@ func (fs *fileSystem) OpenFile(
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	// With read-your-writes, the kernel may still resolve the name to an
+	// inode older than the object last flushed under it by another inode.
+	// ESTALE makes the kernel look the name up again and retry the open.
+	if fs.newConfig.Read.ReadYourWrites && !in.IsLocal() && in.SourceGeneration().Object < fs.pinnedGenerations[in.Name()] {
+		return syscall.ESTALE
+	}
+
+	// The generation of a pinned file is recorded at its first open. The
+	// kernel may still resolve the name to an inode of another generation,
+	// ESTALE makes it look the name up again, finding the pinned one.
+	if !in.IsLocal() && fs.pinned(in.Name()) {
+		if !op.OpenFlags.IsReadOnly() {
+			return fmt.Errorf("open %q for writing, pinned by pin-patterns: %w", in.Name().GcsObjectName(), syscall.EROFS)
+		}
+		pinned, ok := fs.pinnedObjects[in.Name()]
+		if !ok {
+			fs.pinnedObjects[in.Name()] = in.Source()
+		} else if in.SourceGeneration().Object != pinned.Generation {
+			return syscall.ESTALE
+		}
+	}
+
 	// Allocate a handle.
 	handleID := fs.nextHandleID
 	fs.nextHandleID++
 
-	fs.handles[handleID] = handle.NewFileHandle(in, fs.fileCacheHandler, fs.cacheFileForRangeRead, fs.metricHandle, op.OpenFlags.IsReadOnly())
+	// Don't admit the objects about to be deleted or moved to another storage
+	// class by a lifecycle rule of the bucket to the file cache.
+	fileCacheHandler := fs.fileCacheHandler
+	if _, expiring := fs.expiring(in); expiring {
+		fileCacheHandler = nil
+	}
+
+	fs.handles[handleID] = handle.NewFileHandle(in, fileCacheHandler, fs.cacheFileForRangeRead, fs.newConfig.Read.VerifyChecksums, fs.readCoalescing, fs.newConfig.Read.ColumnarFooterKb*cacheutil.KiB, fs.metricHandle, op.OpenFlags.IsReadOnly(), fs.newConfig.Read.MaxConcurrentReadsPerHandle)
 	op.Handle = handleID
 
+	if fs.smallFiles != nil && fileCacheHandler != nil && op.OpenFlags.IsReadOnly() && !in.IsLocal() {
+		fs.smallFiles.opened(in.Bucket(), in.Source())
+	}
+
 	// When we observe object generations that we didn't create, we assign them
 	// new inode IDs. So for a given inode, all modifications go through the
 	// kernel. Therefore it's safe to tell the kernel to keep the page cache from
 	// open to open for a given inode.
 	op.KeepPageCache = true
+	if fs.newConfig.Read.ReadYourWrites {
+		// Drop the pages cached by the kernel if the object has been flushed
+		// since they were cached, as e.g. the writes with O_DIRECT bypass
+		// them.
+		op.KeepPageCache = in.KeepPageCache()
+	}
 
 	return
 }
@@ -2429,17 +3337,24 @@ func (fs *fileSystem) ReadFile(
 	// Save readOp in context for access in logs.
 	ctx = context.WithValue(ctx, gcsx.ReadOp, op)
 
-	// Find the handle and lock it.
+	// Find the handle.
 	fs.mu.Lock()
 	fh := fs.handles[op.Handle].(*handle.FileHandle)
 	fs.mu.Unlock()
 
-	fh.Lock()
-	defer fh.Unlock()
-
-	// Serve the read.
+	// Serve the read, concurrently with the other reads of the handle.
 	op.BytesRead, err = fh.Read(ctx, op.Dst, op.Offset, fs.sequentialReadSizeMb)
 
+	// The pinned generation of the file no longer exists.
+	var clobberedErr *gcsfuse_errors.FileClobberedError
+	if errors.As(err, &clobberedErr) && fs.pinned(fh.Inode().Name()) {
+		err = fmt.Errorf("%w: the pinned generation of %q: %v", syscall.ESTALE, fh.Inode().Name().GcsObjectName(), err)
+	}
+
+	if fs.accessTrace != nil && op.BytesRead > 0 {
+		fs.accessTrace.Record(fh.Inode().Bucket().Name(), fh.Inode().Name().GcsObjectName(), op.Offset, int64(op.BytesRead))
+	}
+
 	// As required by fuse, we don't treat EOF as an error.
 	if err == io.EOF {
 		err = nil
@@ -2485,8 +3400,13 @@ func (fs *fileSystem) WriteFile(
 	in.Lock()
 	defer in.Unlock()
 
+	if err := fs.writeQuota.ReserveBytes(ctx, int64(len(op.Data))); err != nil {
+		return err
+	}
+
 	// Serve the request.
 	if err := in.Write(ctx, op.Data, op.Offset); err != nil {
+		fs.writeQuota.ReleaseBytes(int64(len(op.Data)))
 		return err
 	}
 
@@ -2518,6 +3438,14 @@ func (fs *fileSystem) SyncFile(
 	file.Lock()
 	defer file.Unlock()
 
+	// Unless asked to finalize them, the streaming writes only appear in GCS
+	// once the file is closed.
+	if !fs.newConfig.Write.FinalizeOnFsync {
+		if synced, err := file.SyncBufferedWrites(); synced {
+			return err
+		}
+	}
+
 	// Sync it.
 	if err := fs.syncFile(ctx, file); err != nil {
 		return err
@@ -2573,14 +3501,224 @@ func (fs *fileSystem) ReleaseFileHandle(
 	return
 }
 
+// Extended attributes of files for optimistic concurrency: the generation of
+// the object the contents of the file were read from, and the generation the
+// object must have for the next flush of the file to write it out, zero
+// meaning that it must not exist.
+const (
+	generationXattr        = "user.gcsfuse.generation"
+	ifGenerationMatchXattr = "user.gcsfuse.if_generation_match"
+)
+
+// Extended attribute of the files whose objects are about to be deleted or
+// moved to another storage class by a lifecycle rule of the bucket, with the
+// action and the earliest time GCS can take it, e.g.
+// "Delete 2024-01-02T00:00:00Z".
+const lifecycleXattr = "user.gcsfuse.lifecycle"
+
+// Extended attribute of the files with contents waiting to be written out to
+// GCS, or being written out, with the progress of their upload, e.g.
+// "buffered=1024 committed=4096 pending_blocks=1 uploading=true".
+const uploadStatusXattr = "user.gcsfuse.upload_status"
+
+// servedXattr returns whether the supplied extended attribute is one gcsfuse
+// serves.
+func servedXattr(name string) bool {
+	switch name {
+	case generationXattr, ifGenerationMatchXattr, lifecycleXattr, uploadStatusXattr:
+		return true
+	}
+	return false
+}
+
+// expiring returns the action of a lifecycle rule of the bucket that GCS can
+// take on the object of the file within file-system.lifecycle-hint-window, if
+// any. The update time of the object stands for its creation time, from which
+// the rules count the age of the objects.
+//
+// LOCKS_REQUIRED(f)
+func (fs *fileSystem) expiring(f *inode.FileInode) (lifecycle.Expiry, bool) {
+	if fs.lifecycleRules == nil || f.IsLocal() {
+		return lifecycle.Expiry{}, false
+	}
+	src := f.Source()
+	return fs.lifecycleRules.Expiring(src.Name, src.Updated, fs.mtimeClock.Now(), fs.newConfig.FileSystem.LifecycleHintWindow)
+}
+
+// LOCKS_EXCLUDED(fs.mu)
 func (fs *fileSystem) GetXattr(
 	ctx context.Context,
 	op *fuseops.GetXattrOp) (err error) {
-	return syscall.ENOSYS
+	// Without enable-xattrs, the kernel stops asking on ENOSYS.
+	if !fs.newConfig.FileSystem.EnableXattrs {
+		return fuse.ENOSYS
+	}
+	// Fail the other attributes, e.g. the security.capability the kernel asks
+	// for on writes, without waiting for the lock of the file.
+	if !servedXattr(op.Name) {
+		return syscall.ENODATA
+	}
+
+	fs.mu.Lock()
+	in := fs.inodeOrDie(op.Inode)
+	fs.mu.Unlock()
+
+	file, ok := in.(*inode.FileInode)
+	if !ok {
+		return syscall.ENODATA
+	}
+	if op.Name == uploadStatusXattr {
+		// Read without the lock of the file, which its flushes hold throughout
+		// the upload.
+		return copyXattrValue(op, file.UploadStatus().String())
+	}
+	file.Lock()
+	defer file.Unlock()
+
+	var formatted string
+	switch op.Name {
+	case generationXattr:
+		if file.IsLocal() {
+			return syscall.ENODATA
+		}
+		formatted = strconv.FormatInt(file.SourceGeneration().Object, 10)
+	case ifGenerationMatchXattr:
+		precondition := file.GenerationPrecondition()
+		if precondition == nil {
+			return syscall.ENODATA
+		}
+		formatted = strconv.FormatInt(*precondition, 10)
+	case lifecycleXattr:
+		expiry, expiring := fs.expiring(file)
+		if !expiring {
+			return syscall.ENODATA
+		}
+		formatted = expiry.String()
+	default:
+		return syscall.ENODATA
+	}
+
+	return copyXattrValue(op, formatted)
+}
+
+// copyXattrValue serves op with the supplied value of the attribute.
+func copyXattrValue(op *fuseops.GetXattrOp, value string) error {
+	op.BytesRead = len(value)
+	if len(op.Dst) == 0 {
+		return nil
+	}
+	if len(op.Dst) < len(value) {
+		return syscall.ERANGE
+	}
+	copy(op.Dst, value)
+	return nil
 }
 
+// LOCKS_EXCLUDED(fs.mu)
 func (fs *fileSystem) ListXattr(
 	ctx context.Context,
 	op *fuseops.ListXattrOp) error {
-	return syscall.ENOSYS
+	if !fs.newConfig.FileSystem.EnableXattrs {
+		return fuse.ENOSYS
+	}
+
+	fs.mu.Lock()
+	in := fs.inodeOrDie(op.Inode)
+	fs.mu.Unlock()
+
+	file, ok := in.(*inode.FileInode)
+	if !ok {
+		return nil
+	}
+	file.Lock()
+	defer file.Unlock()
+
+	var names []byte
+	if !file.IsLocal() {
+		names = append(names, generationXattr+"\x00"...)
+	}
+	if file.GenerationPrecondition() != nil {
+		names = append(names, ifGenerationMatchXattr+"\x00"...)
+	}
+	if _, expiring := fs.expiring(file); expiring {
+		names = append(names, lifecycleXattr+"\x00"...)
+	}
+	if file.UploadStatus().Pending() {
+		names = append(names, uploadStatusXattr+"\x00"...)
+	}
+
+	op.BytesRead = len(names)
+	if len(op.Dst) == 0 {
+		return nil
+	}
+	if len(op.Dst) < len(names) {
+		return syscall.ERANGE
+	}
+	copy(op.Dst, names)
+	return nil
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	if !fs.newConfig.FileSystem.EnableXattrs {
+		return fuse.ENOSYS
+	}
+	if op.Name != ifGenerationMatchXattr {
+		return syscall.ENOTSUP
+	}
+
+	fs.mu.Lock()
+	in := fs.inodeOrDie(op.Inode)
+	fs.mu.Unlock()
+
+	file, ok := in.(*inode.FileInode)
+	if !ok {
+		return syscall.ENOTSUP
+	}
+	generation, err := strconv.ParseInt(strings.TrimSpace(string(op.Value)), 10, 64)
+	if err != nil || generation < 0 {
+		return syscall.EINVAL
+	}
+
+	file.Lock()
+	defer file.Unlock()
+
+	exists := file.GenerationPrecondition() != nil
+	switch {
+	case op.Flags == unix.XATTR_CREATE && exists:
+		return syscall.EEXIST
+	case op.Flags == unix.XATTR_REPLACE && !exists:
+		return syscall.ENODATA
+	}
+	return file.SetGenerationPrecondition(&generation)
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	if !fs.newConfig.FileSystem.EnableXattrs {
+		return fuse.ENOSYS
+	}
+	if op.Name != ifGenerationMatchXattr {
+		return syscall.ENODATA
+	}
+
+	fs.mu.Lock()
+	in := fs.inodeOrDie(op.Inode)
+	fs.mu.Unlock()
+
+	file, ok := in.(*inode.FileInode)
+	if !ok {
+		return syscall.ENODATA
+	}
+	file.Lock()
+	defer file.Unlock()
+
+	if file.GenerationPrecondition() == nil {
+		return syscall.ENODATA
+	}
+	return file.SetGenerationPrecondition(nil)
 }
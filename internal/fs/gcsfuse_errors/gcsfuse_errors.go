@@ -31,3 +31,20 @@ func (fce *FileClobberedError) Error() string {
 func (fce *FileClobberedError) Unwrap() error {
 	return fce.Err
 }
+
+// GenerationMismatchError represents a flush failed with strict-overwrites
+// because the object was modified or deleted since the generation from which
+// the file was opened.
+type GenerationMismatchError struct {
+	Name       string
+	Generation int64
+	Err        error
+}
+
+func (gme *GenerationMismatchError) Error() string {
+	return fmt.Sprintf("Not overwriting %q, which was modified or deleted since generation %d was opened: %v", gme.Name, gme.Generation, gme.Err)
+}
+
+func (gme *GenerationMismatchError) Unwrap() error {
+	return gme.Err
+}
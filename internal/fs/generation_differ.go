@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// diffChunkSize is the size of the chunks of the generations compared at a
+// time.
+const diffChunkSize = 1 << 20
+
+// GenerationDiffer compares generations of the objects of a mount, on the
+// request of the control socket. It's set up by NewFileSystem.
+type GenerationDiffer struct {
+	bucket func(name string) (gcs.Bucket, error)
+
+	// The name of the mounted bucket, or empty when all the buckets are
+	// mounted, in which case the first component of the paths is the bucket.
+	bucketName string
+}
+
+// Diff calls ranges with the byte ranges in which the generations a and b of
+// the object at path, relative to the root of the mount, differ, from the
+// first to the last. Zero is the live generation. Both generations are
+// streamed side by side, one chunk at a time, so the memory used doesn't
+// depend on their size, but they're read in full.
+func (d *GenerationDiffer) Diff(ctx context.Context, path string, a, b int64, ranges func(offset, length int64)) error {
+	if d.bucket == nil {
+		return errors.New("the file system isn't set up")
+	}
+
+	bucketName, objectName := d.bucketName, strings.TrimPrefix(path, "/")
+	if bucketName == "" {
+		bucketName, objectName, _ = strings.Cut(objectName, "/")
+		if bucketName == "" {
+			return fmt.Errorf("%q doesn't start with a bucket name", path)
+		}
+	}
+	if objectName == "" || strings.HasSuffix(objectName, "/") {
+		return fmt.Errorf("%q isn't a file", path)
+	}
+	bucket, err := d.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	ra, err := bucket.NewReader(ctx, &gcs.ReadObjectRequest{Name: objectName, Generation: a})
+	if err != nil {
+		return fmt.Errorf("reading generation %d of %q: %w", a, objectName, err)
+	}
+	defer ra.Close()
+	rb, err := bucket.NewReader(ctx, &gcs.ReadObjectRequest{Name: objectName, Generation: b})
+	if err != nil {
+		return fmt.Errorf("reading generation %d of %q: %w", b, objectName, err)
+	}
+	defer rb.Close()
+
+	return diffReaders(ra, rb, ranges)
+}
+
+// diffReaders calls ranges with the byte ranges in which the contents of a and
+// b differ, from the first to the last. The bytes past the end of the shorter
+// one differ.
+func diffReaders(a, b io.Reader, ranges func(offset, length int64)) error {
+	bufA, bufB := make([]byte, diffChunkSize), make([]byte, diffChunkSize)
+	var offset int64
+	// The start of the range being reported, or -1.
+	start := int64(-1)
+	end := func(at int64) {
+		if start >= 0 {
+			ranges(start, at-start)
+			start = -1
+		}
+	}
+
+	for {
+		na, err := readChunk(a, bufA)
+		if err != nil {
+			return err
+		}
+		nb, err := readChunk(b, bufB)
+		if err != nil {
+			return err
+		}
+
+		if na == nb && bytes.Equal(bufA[:na], bufB[:nb]) {
+			end(offset)
+		} else {
+			for i := 0; i < max(na, nb); i++ {
+				if i < na && i < nb && bufA[i] == bufB[i] {
+					end(offset + int64(i))
+				} else if start < 0 {
+					start = offset + int64(i)
+				}
+			}
+		}
+		offset += int64(max(na, nb))
+
+		if na < len(bufA) && nb < len(bufB) {
+			end(offset)
+			return nil
+		}
+	}
+}
+
+// readChunk fills buf from r, short only at the end of r.
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type byteRange struct {
+	offset, length int64
+}
+
+func diffBytes(t *testing.T, a, b []byte) []byteRange {
+	t.Helper()
+	var ranges []byteRange
+	err := diffReaders(bytes.NewReader(a), bytes.NewReader(b), func(offset, length int64) {
+		ranges = append(ranges, byteRange{offset, length})
+	})
+	require.NoError(t, err)
+	return ranges
+}
+
+func TestDiffReaders(t *testing.T) {
+	base := bytes.Repeat([]byte("0123456789"), diffChunkSize/4)
+	modified := bytes.Clone(base)
+	modified[3] = 'x'
+	// A range across the boundary of the first two chunks.
+	for i := diffChunkSize - 2; i < diffChunkSize+3; i++ {
+		modified[i] = 'x'
+	}
+
+	assert.Empty(t, diffBytes(t, base, base))
+	assert.Equal(t, []byteRange{{3, 1}, {diffChunkSize - 2, 5}}, diffBytes(t, base, modified))
+	assert.Equal(t, []byteRange{{int64(len(base)) - 10, 10}}, diffBytes(t, base, base[:len(base)-10]))
+	assert.Equal(t, []byteRange{{0, 3}}, diffBytes(t, nil, []byte("abc")))
+	assert.Empty(t, diffBytes(t, nil, nil))
+}
+
+func TestGenerationDifferDiff(t *testing.T) {
+	ctx := context.Background()
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	o, err := storageutil.CreateObject(ctx, bucket, "dir/foo", []byte("taco"))
+	require.NoError(t, err)
+	d := &GenerationDiffer{
+		bucket: func(name string) (gcs.Bucket, error) {
+			assert.Equal(t, "some_bucket", name)
+			return bucket, nil
+		},
+	}
+	var ranges []byteRange
+	record := func(offset, length int64) { ranges = append(ranges, byteRange{offset, length}) }
+
+	err = d.Diff(ctx, "some_bucket/dir/foo", o.Generation, 0, record)
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	err = d.Diff(ctx, "some_bucket/dir/foo", o.Generation+1, 0, record)
+	assert.ErrorContains(t, err, "reading generation")
+
+	err = d.Diff(ctx, "some_bucket/dir/", 0, 0, record)
+	assert.ErrorContains(t, err, "isn't a file")
+
+	d.bucketName = "some_bucket"
+	err = d.Diff(ctx, "dir/foo", 0, 0, record)
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+}
@@ -16,7 +16,9 @@ package handle
 
 import (
 	"fmt"
+	"slices"
 	"sort"
+	"sync"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
@@ -35,35 +37,62 @@ type DirHandle struct {
 	in           inode.DirInode
 	implicitDirs bool
 
+	// hidden returns true for the entries left out of the listings, if not nil.
+	hidden func(name string, isDir bool) bool
+
+	// listings limits the memory of the entries kept between the reads.
+	listings *DirListings
+
 	/////////////////////////
 	// Mutable state
 	/////////////////////////
 
 	Mu locker.Locker
 
-	// All entries in the directory. Populated the first time we need one.
+	// entriesMu guards the entries separately from Mu, so that they can be
+	// evicted while listing other directories.
+	//
+	// LOCK ORDERING: DirListings.mu before entriesMu.
+	entriesMu sync.Mutex
+
+	// The entries in the directory from the offset entriesStart, all of them
+	// unless limited by listings. Populated the first time we need one, and
+	// again when reading entries beyond them or after they're evicted.
 	//
 	// INVARIANT: For each i, entries[i+1].Offset == entries[i].Offset + 1
+	// INVARIANT: If len(entries) > 0, then entries[0].Offset == entriesStart + 1
 	//
-	// GUARDED_BY(Mu)
-	entries []fuseutil.Dirent
+	// GUARDED_BY(entriesMu)
+	entries      []fuseutil.Dirent
+	entriesStart int
 
 	// Has entries yet been populated?
 	//
 	// INVARIANT: If !entriesValid, then len(entries) == 0
 	//
-	// GUARDED_BY(Mu)
+	// GUARDED_BY(entriesMu)
 	entriesValid bool
+
+	// Do entries extend to the end of the directory?
+	//
+	// GUARDED_BY(entriesMu)
+	entriesComplete bool
 }
 
-// NewDirHandle creates a directory handle that obtains listings from the supplied inode.
+// NewDirHandle creates a directory handle that obtains listings from the
+// supplied inode, leaving out the entries for which hidden, if not nil, returns
+// true, and keeping them between the reads within the limits of listings.
 func NewDirHandle(
 	in inode.DirInode,
-	implicitDirs bool) (dh *DirHandle) {
+	implicitDirs bool,
+	hidden func(name string, isDir bool) bool,
+	listings *DirListings) (dh *DirHandle) {
 	// Set up the basic struct.
 	dh = &DirHandle{
 		in:           in,
 		implicitDirs: implicitDirs,
+		hidden:       hidden,
+		listings:     listings,
 	}
 
 	// Set up invariant checking.
@@ -84,6 +113,9 @@ func (p sortedDirents) Less(i, j int) bool { return p[i].Name < p[j].Name }
 func (p sortedDirents) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
 func (dh *DirHandle) checkInvariants() {
+	dh.entriesMu.Lock()
+	defer dh.entriesMu.Unlock()
+
 	// INVARIANT: For each i, entries[i+1].Offset == entries[i].Offset + 1
 	for i := 0; i < len(dh.entries)-1; i++ {
 		if !(dh.entries[i+1].Offset == dh.entries[i].Offset+1) {
@@ -95,6 +127,15 @@ func (dh *DirHandle) checkInvariants() {
 		}
 	}
 
+	// INVARIANT: If len(entries) > 0, then entries[0].Offset == entriesStart + 1
+	if len(dh.entries) > 0 && int(dh.entries[0].Offset) != dh.entriesStart+1 {
+		panic(
+			fmt.Sprintf(
+				"Unexpected first offset %v for start %v",
+				dh.entries[0].Offset,
+				dh.entriesStart))
+	}
+
 	// INVARIANT: If !entriesValid, then len(entries) == 0
 	if !dh.entriesValid && len(dh.entries) != 0 {
 		panic("Unexpected non-empty entries slice")
@@ -171,7 +212,13 @@ func fixConflictingNames(entries []fuseutil.Dirent, localEntries map[string]fuse
 func readAllEntries(
 	ctx context.Context,
 	in inode.DirInode,
-	localEntries map[string]fuseutil.Dirent) (entries []fuseutil.Dirent, err error) {
+	localEntries map[string]fuseutil.Dirent,
+	hidden func(name string, isDir bool) bool,
+	capacity int) (entries []fuseutil.Dirent, err error) {
+	if capacity > 0 {
+		entries = make([]fuseutil.Dirent, 0, capacity)
+	}
+
 	// Read entries from GCS.
 	// Read one batch at a time.
 	var tok string
@@ -199,6 +246,13 @@ func readAllEntries(
 		entries = append(entries, localEntry)
 	}
 
+	// Leave out the hidden entries.
+	if hidden != nil {
+		entries = slices.DeleteFunc(entries, func(e fuseutil.Dirent) bool {
+			return hidden(e.Name, e.Type == fuseutil.DT_Directory)
+		})
+	}
+
 	// Ensure that the entries are sorted, for use in fixConflictingNames
 	// below.
 	sort.Sort(sortedDirents(entries))
@@ -239,24 +293,81 @@ func readAllEntries(
 	return
 }
 
+// setEntries replaces the entries kept by the handle with the ones from the
+// offset start, complete if they extend to the end of the directory.
+//
+// LOCKS_EXCLUDED(dh.entriesMu)
+func (dh *DirHandle) setEntries(entries []fuseutil.Dirent, start int, complete bool) {
+	dh.entriesMu.Lock()
+	defer dh.entriesMu.Unlock()
+
+	dh.entries = entries
+	dh.entriesStart = start
+	dh.entriesValid = entries != nil
+	dh.entriesComplete = complete
+}
+
+// cachedEntries returns the entries kept by the handle from the offset index,
+// or false if they must be read again: they haven't been populated, were
+// evicted, or don't include index.
+//
+// LOCKS_EXCLUDED(dh.entriesMu)
+func (dh *DirHandle) cachedEntries(index int) (entries []fuseutil.Dirent, ok bool, err error) {
+	dh.entriesMu.Lock()
+	defer dh.entriesMu.Unlock()
+
+	if !dh.entriesValid || index < dh.entriesStart {
+		return nil, false, nil
+	}
+
+	end := dh.entriesStart + len(dh.entries)
+	switch {
+	case index < end:
+		return dh.entries[index-dh.entriesStart:], true, nil
+	case !dh.entriesComplete:
+		return nil, false, nil
+	case index == end:
+		return nil, true, nil
+	default:
+		// Is the offset past the end of the directory? If so, this must be an
+		// invalid seekdir according to posix.
+		return nil, false, fuse.EINVAL
+	}
+}
+
+// ensureEntries reads all entries for the directory and keeps the ones from
+// the offset index that fit in the limits of the listings, returning them.
+//
 // LOCKS_REQUIRED(dh.Mu)
 // LOCKS_EXCLUDED(dh.in)
-func (dh *DirHandle) ensureEntries(ctx context.Context, localFileEntries map[string]fuseutil.Dirent) (err error) {
+// LOCKS_EXCLUDED(dh.entriesMu)
+func (dh *DirHandle) ensureEntries(ctx context.Context, index int, localFileEntries map[string]fuseutil.Dirent) (entries []fuseutil.Dirent, err error) {
 	dh.in.Lock()
 	defer dh.in.Unlock()
 
 	// Read entries.
-	var entries []fuseutil.Dirent
-	entries, err = readAllEntries(ctx, dh.in, localFileEntries)
+	var all []fuseutil.Dirent
+	all, err = readAllEntries(ctx, dh.in, localFileEntries, dh.hidden, dh.listings.initialCapacity())
 	if err != nil {
 		err = fmt.Errorf("readAllEntries: %w", err)
 		return
 	}
 
+	// Is the offset past the end of the directory? If so, this must be an
+	// invalid seekdir according to posix.
+	if index > len(all) {
+		err = fuse.EINVAL
+		return
+	}
+
 	// Update state.
-	dh.entries = entries
-	dh.entriesValid = true
+	kept, start, complete := dh.listings.window(all, index)
+	if kept == nil {
+		kept = []fuseutil.Dirent{}
+	}
+	dh.listings.store(ctx, dh, kept, start, complete)
 
+	entries = kept[index-start:]
 	return
 }
 
@@ -279,29 +390,25 @@ func (dh *DirHandle) ReadDir(
 	// If the request is for offset zero, we assume that either this is the first
 	// call or rewinddir has been called. Reset state.
 	if op.Offset == 0 {
-		dh.entries = nil
-		dh.entriesValid = false
+		dh.listings.store(ctx, dh, nil, 0, false)
 	}
 
 	// Do we need to read entries from GCS?
-	if !dh.entriesValid {
-		err = dh.ensureEntries(ctx, localFileEntries)
+	index := int(op.Offset)
+	entries, ok, err := dh.cachedEntries(index)
+	if err != nil {
+		return
+	}
+	if !ok {
+		entries, err = dh.ensureEntries(ctx, index, localFileEntries)
 		if err != nil {
 			return
 		}
 	}
 
-	// Is the offset past the end of what we have buffered? If so, this must be
-	// an invalid seekdir according to posix.
-	index := int(op.Offset)
-	if index > len(dh.entries) {
-		err = fuse.EINVAL
-		return
-	}
-
 	// We copy out entries until we run out of entries or space.
-	for i := index; i < len(dh.entries); i++ {
-		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], dh.entries[i])
+	for _, e := range entries {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], e)
 		if n == 0 {
 			break
 		}
@@ -311,3 +418,8 @@ func (dh *DirHandle) ReadDir(
 
 	return
 }
+
+// Destroy releases the entries kept by the handle.
+func (dh *DirHandle) Destroy() {
+	dh.listings.store(context.Background(), dh, nil, 0, false)
+}
@@ -21,12 +21,14 @@ import (
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 	. "github.com/jacobsa/ogletest"
@@ -44,6 +46,9 @@ type DirHandleTest struct {
 	bucket gcsx.SyncerBucket
 	clock  timeutil.SimulatedClock
 
+	// The limits of the entries kept by the handles, nil for none.
+	listings *DirListings
+
 	dh *DirHandle
 }
 
@@ -66,9 +71,13 @@ func (t *DirHandleTest) TearDown() {}
 // Helpers
 // //////////////////////////////////////////////////////////////////////
 func (t *DirHandleTest) resetDirHandle() {
+	t.dh = t.newDirHandle("testDir")
+}
+
+func (t *DirHandleTest) newDirHandle(dirName string) *DirHandle {
 	dirInode := inode.NewDirInode(
 		17,
-		inode.NewDirName(inode.NewRootName(""), "testDir"),
+		inode.NewDirName(inode.NewRootName(""), dirName),
 		fuseops.InodeAttributes{
 			Uid:  123,
 			Gid:  456,
@@ -82,11 +91,14 @@ func (t *DirHandleTest) resetDirHandle() {
 		&t.clock,
 		&t.clock,
 		0,
+		false,
 		false)
 
-	t.dh = NewDirHandle(
+	return NewDirHandle(
 		dirInode,
 		true,
+		nil,
+		t.listings,
 	)
 }
 
@@ -102,10 +114,29 @@ func (t *DirHandleTest) createLocalFileInode(name string, id fuseops.InodeID) (i
 		},
 		&t.bucket,
 		false, // localFileCache
-		contentcache.New("", &t.clock),
+		contentcache.New("", &t.clock, nil, nil),
 		&t.clock,
 		true, // localFile
-		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}})
+		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}},
+		common.NewNoopMetrics())
+	return
+}
+
+func (t *DirHandleTest) createObjects(names ...string) {
+	for _, name := range names {
+		_, err := storageutil.CreateObject(t.ctx, t.bucket, name, nil)
+		AssertEq(nil, err)
+	}
+}
+
+func (t *DirHandleTest) readDir(dh *DirHandle, offset int) (op *fuseops.ReadDirOp, err error) {
+	op = &fuseops.ReadDirOp{
+		Offset: fuseops.DirOffset(offset),
+		Dst:    make([]byte, 4096),
+	}
+	dh.Mu.Lock()
+	defer dh.Mu.Unlock()
+	err = dh.ReadDir(t.ctx, op, nil)
 	return
 }
 
@@ -135,7 +166,7 @@ func (t *DirHandleTest) EnsureEntriesWithLocalAndGCSFiles() {
 	}
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -158,7 +189,7 @@ func (t *DirHandleTest) EnsureEntriesWithOnlyGCSFiles() {
 	var localFileEntries map[string]fuseutil.Dirent
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -178,7 +209,7 @@ func (t *DirHandleTest) EnsureEntriesWithOnlyLocalFiles() {
 	}
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -200,7 +231,7 @@ func (t *DirHandleTest) EnsureEntriesWithSameNameLocalAndGCSFile() {
 	}
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -221,7 +252,7 @@ func (t *DirHandleTest) EnsureEntriesWithSameNameLocalFileAndGCSDirectory() {
 	}
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -235,7 +266,7 @@ func (t *DirHandleTest) EnsureEntriesWithNoFiles() {
 	localFileEntries := map[string]fuseutil.Dirent{}
 
 	// Ensure entries.
-	err := t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err := t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -252,7 +283,7 @@ func (t *DirHandleTest) EnsureEntriesWithOneGCSFile() {
 	var localFileEntries map[string]fuseutil.Dirent
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
@@ -269,10 +300,134 @@ func (t *DirHandleTest) EnsureEntriesWithOneLocalFile() {
 	}
 
 	// Ensure entries.
-	err = t.dh.ensureEntries(t.ctx, localFileEntries)
+	_, err = t.dh.ensureEntries(t.ctx, 0, localFileEntries)
 
 	// Validations
 	AssertEq(nil, err)
 	AssertEq(1, len(t.dh.entries))
 	t.validateEntry(t.dh.entries[0], localFileName1, fuseutil.DT_File)
 }
+
+func (t *DirHandleTest) EnsureEntriesWithHiddenEntries() {
+	var err error
+	// Set up empty GCS objects.
+	// DirHandle holds a DirInode pointing to "testDir".
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "testDir/gcsObject1", nil)
+	AssertEq(nil, err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "testDir/.DS_Store", nil)
+	AssertEq(nil, err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "testDir/_temporary/", nil)
+	AssertEq(nil, err)
+	t.dh.hidden = func(name string, isDir bool) bool {
+		return name == ".DS_Store" || (name == "_temporary" && isDir)
+	}
+
+	// Ensure entries.
+	_, err = t.dh.ensureEntries(t.ctx, 0, nil)
+
+	// Validations
+	AssertEq(nil, err)
+	AssertEq(1, len(t.dh.entries))
+	t.validateEntry(t.dh.entries[0], "gcsObject1", fuseutil.DT_File)
+	AssertEq(1, t.dh.entries[0].Offset)
+}
+
+type listingsMetricHandle struct {
+	common.MetricHandle
+	evictions int64
+}
+
+func (m *listingsMetricHandle) DirListingEvictionCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.evictions += inc
+}
+
+func (t *DirHandleTest) ReadDirWithMaxEntries() {
+	t.createObjects("testDir/a", "testDir/b", "testDir/c", "testDir/d", "testDir/e")
+	t.listings = NewDirListings(0, 2, 0, common.NewNoopMetrics())
+	t.resetDirHandle()
+
+	// Only the entries about to be read are kept.
+	op, err := t.readDir(t.dh, 0)
+	AssertEq(nil, err)
+	ExpectGt(op.BytesRead, 0)
+	AssertEq(2, len(t.dh.entries))
+	t.validateEntry(t.dh.entries[0], "a", fuseutil.DT_File)
+	t.validateEntry(t.dh.entries[1], "b", fuseutil.DT_File)
+
+	// Reading beyond them lists the directory again.
+	op, err = t.readDir(t.dh, 2)
+	AssertEq(nil, err)
+	ExpectGt(op.BytesRead, 0)
+	AssertEq(2, len(t.dh.entries))
+	t.validateEntry(t.dh.entries[0], "c", fuseutil.DT_File)
+	AssertEq(3, t.dh.entries[0].Offset)
+	t.validateEntry(t.dh.entries[1], "d", fuseutil.DT_File)
+
+	op, err = t.readDir(t.dh, 4)
+	AssertEq(nil, err)
+	ExpectGt(op.BytesRead, 0)
+	AssertEq(1, len(t.dh.entries))
+	t.validateEntry(t.dh.entries[0], "e", fuseutil.DT_File)
+
+	// The end of the directory is known without listing it again.
+	op, err = t.readDir(t.dh, 5)
+	AssertEq(nil, err)
+	ExpectEq(0, op.BytesRead)
+	_, err = t.readDir(t.dh, 6)
+	ExpectEq(fuse.EINVAL, err)
+}
+
+func (t *DirHandleTest) ReadDirWithMaxEntriesAboveDirectorySize() {
+	t.createObjects("testDir/a", "testDir/b")
+	t.listings = NewDirListings(0, 2, 0, common.NewNoopMetrics())
+	t.resetDirHandle()
+
+	_, err := t.readDir(t.dh, 0)
+
+	AssertEq(nil, err)
+	AssertEq(2, len(t.dh.entries))
+	ExpectTrue(t.dh.entriesComplete)
+}
+
+func (t *DirHandleTest) ReadDirEvictsLargestDirectoriesFirst() {
+	t.createObjects("large/a", "large/b", "large/c", "small1/a", "small2/a")
+	metricHandle := &listingsMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	t.listings = NewDirListings(0, 0, math.MaxUint64, metricHandle)
+	large := t.newDirHandle("large")
+	small1 := t.newDirHandle("small1")
+	small2 := t.newDirHandle("small2")
+	_, err := t.readDir(large, 0)
+	AssertEq(nil, err)
+	_, err = t.readDir(small1, 0)
+	AssertEq(nil, err)
+	// Only the entries read so far fit.
+	t.listings.maxBytes = t.listings.bytes
+
+	_, err = t.readDir(small2, 0)
+
+	AssertEq(nil, err)
+	ExpectFalse(large.entriesValid)
+	ExpectTrue(small1.entriesValid)
+	ExpectTrue(small2.entriesValid)
+	ExpectEq(1, metricHandle.evictions)
+	// The evicted directory is listed again from where it's read.
+	op, err := t.readDir(large, 1)
+	AssertEq(nil, err)
+	ExpectGt(op.BytesRead, 0)
+	AssertEq(3, len(large.entries))
+}
+
+func (t *DirHandleTest) DestroyReleasesEntries() {
+	t.createObjects("testDir/a")
+	t.listings = NewDirListings(0, 0, math.MaxUint64, common.NewNoopMetrics())
+	t.resetDirHandle()
+	_, err := t.readDir(t.dh, 0)
+	AssertEq(nil, err)
+	ExpectGt(t.listings.bytes, 0)
+
+	t.dh.Destroy()
+
+	ExpectEq(0, t.listings.bytes)
+	ExpectEq(0, len(t.listings.sizes))
+	ExpectFalse(t.dh.entriesValid)
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handle
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"unsafe"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// DirListings limits the memory of the entries of the directories that the
+// directory handles keep between their reads: at most maxEntries per handle,
+// beyond which only the entries about to be read are kept, and maxBytes across
+// all of them, beyond which the entries of the largest directories are evicted
+// first. The handles read the entries they don't keep again when reaching them.
+//
+// A nil DirListings limits nothing. Safe for concurrent use.
+type DirListings struct {
+	// The number of entries allocated up front by each listing, 0 to grow as
+	// needed.
+	capacity int
+
+	// The limits, 0 for no limit.
+	maxEntries int
+	maxBytes   uint64
+
+	metricHandle common.MetricHandle
+
+	mu sync.Mutex
+
+	// The size of the entries kept by each handle, and their total.
+	//
+	// GUARDED_BY(mu)
+	sizes map[*DirHandle]uint64
+	bytes uint64
+}
+
+// NewDirListings returns the limits of maxEntries entries per handle and
+// maxBytes bytes in total, 0 for no limit, allocating capacity entries up front
+// for each listing, or nil if there's nothing to limit or allocate.
+func NewDirListings(capacity, maxEntries int, maxBytes uint64, metricHandle common.MetricHandle) *DirListings {
+	if capacity <= 0 && maxEntries <= 0 && maxBytes == 0 {
+		return nil
+	}
+	return &DirListings{
+		capacity:     capacity,
+		maxEntries:   maxEntries,
+		maxBytes:     maxBytes,
+		metricHandle: metricHandle,
+		sizes:        make(map[*DirHandle]uint64),
+	}
+}
+
+func (l *DirListings) initialCapacity() int {
+	if l == nil {
+		return 0
+	}
+	return l.capacity
+}
+
+// direntsSize returns the memory used by the supplied entries.
+func direntsSize(entries []fuseutil.Dirent) (size uint64) {
+	size = uint64(cap(entries)) * uint64(unsafe.Sizeof(fuseutil.Dirent{}))
+	for _, e := range entries {
+		size += uint64(len(e.Name))
+	}
+	return
+}
+
+// window returns the entries out of all the ones of a directory that a handle
+// reading from the offset index keeps, from the offset start, and whether they
+// extend to the end of the directory.
+func (l *DirListings) window(all []fuseutil.Dirent, index int) (entries []fuseutil.Dirent, start int, complete bool) {
+	if l == nil || l.maxEntries <= 0 || len(all) <= l.maxEntries {
+		return all, 0, true
+	}
+
+	end := min(index+l.maxEntries, len(all))
+	// Copy them, so that the others can be garbage collected.
+	return slices.Clone(all[index:end]), index, end == len(all)
+}
+
+// store replaces the entries kept by dh, releasing them if nil, and evicts the
+// entries kept by the handles of the largest directories while beyond
+// maxBytes, the ones of dh last.
+//
+// LOCKS_EXCLUDED(l.mu)
+// LOCKS_EXCLUDED(dh.entriesMu)
+func (l *DirListings) store(ctx context.Context, dh *DirHandle, entries []fuseutil.Dirent, start int, complete bool) {
+	if l == nil || l.maxBytes == 0 {
+		dh.setEntries(entries, start, complete)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dh.setEntries(entries, start, complete)
+	l.bytes -= l.sizes[dh]
+	delete(l.sizes, dh)
+	if entries == nil {
+		return
+	}
+	size := direntsSize(entries)
+	l.sizes[dh] = size
+	l.bytes += size
+
+	for l.bytes > l.maxBytes {
+		victim := l.largest(dh)
+		l.bytes -= l.sizes[victim]
+		delete(l.sizes, victim)
+		victim.setEntries(nil, 0, false)
+		l.metricHandle.DirListingEvictionCount(ctx, 1, nil)
+	}
+}
+
+// largest returns the handle keeping the most entries other than dh, or dh if
+// it's the only one.
+//
+// LOCKS_REQUIRED(l.mu)
+func (l *DirListings) largest(dh *DirHandle) (largest *DirHandle) {
+	largest = dh
+	var largestSize uint64
+	for h, size := range l.sizes {
+		if h != dh && size > largestSize {
+			largest, largestSize = h, size
+		}
+	}
+	return
+}
@@ -17,11 +17,13 @@ package handle
 import (
 	"fmt"
 	"io"
+	"slices"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/ratelimit"
 	"github.com/jacobsa/syncutil"
 	"golang.org/x/net/context"
 )
@@ -31,13 +33,30 @@ type FileHandle struct {
 
 	mu syncutil.InvariantMutex
 
-	// A random reader configured to some (potentially previous) generation of
-	// the object backing the inode, or nil.
+	// The random readers configured to readerGeneration of the object backing
+	// the inode not in use by a read, at most one per concurrent read. Each read
+	// takes one out while it's in progress.
 	//
-	// INVARIANT: If reader != nil, reader.CheckInvariants() doesn't panic.
+	// INVARIANT: For each r, r.reader.CheckInvariants() doesn't panic.
 	//
 	// GUARDED_BY(mu)
-	reader gcsx.RandomReader
+	idleReaders []idleReader
+
+	// The generation of the object the readers are configured to, or 0 if the
+	// reads fall through to the inode. The readers of other generations are
+	// destroyed when the reads using them finish.
+	//
+	// GUARDED_BY(mu)
+	readerGeneration int64
+
+	// Whether the handle has been destroyed, in which case the readers are
+	// destroyed when the reads using them finish.
+	//
+	// GUARDED_BY(mu)
+	destroyed bool
+
+	// A token per read in progress, at most max-concurrent-reads-per-handle.
+	readSlots chan struct{}
 
 	// fileCacheHandler is used to get file cache handle and read happens using that.
 	// This will be nil if the file cache is disabled.
@@ -46,19 +65,48 @@ type FileHandle struct {
 	// cacheFileForRangeRead is also valid for cache workflow, if true, object content
 	// will be downloaded for random reads as well too.
 	cacheFileForRangeRead bool
-	metricHandle          common.MetricHandle
+
+	// verifyChecksums enables the verification of the CRC32C checksum of the
+	// objects read from GCS from start to end in sequence.
+	verifyChecksums bool
+
+	// readCoalescing configures the coalescing of the small random reads.
+	readCoalescing gcsx.ReadCoalescing
+
+	// columnarFooterBytes is the size of the footer of the columnar files whose
+	// reads are recognized, or 0.
+	columnarFooterBytes int64
+
+	metricHandle common.MetricHandle
 	// For now, we will consider the files which are open in append mode also as write,
 	// as we are not doing anything special for append. When required we will
 	// define an enum instead of boolean to hold the type of open.
 	readOnly bool
 }
 
+// idleReader is a random reader not in use by a read, along with the offset
+// following the last read it served, so that the sequential reads keep using the
+// same reader.
+type idleReader struct {
+	reader gcsx.RandomReader
+	next   int64
+}
+
+// NewFileHandle creates a handle serving up to maxConcurrentReads reads at a
+// time, each with its own reader, or one if it's less than 1. The readers
+// recognize the reads of columnar files with a footer of columnarFooterBytes,
+// unless it's 0.
+//
 // LOCKS_REQUIRED(fh.inode.mu)
-func NewFileHandle(inode *inode.FileInode, fileCacheHandler *file.CacheHandler, cacheFileForRangeRead bool, metricHandle common.MetricHandle, readOnly bool) (fh *FileHandle) {
+func NewFileHandle(inode *inode.FileInode, fileCacheHandler *file.CacheHandler, cacheFileForRangeRead bool, verifyChecksums bool, readCoalescing gcsx.ReadCoalescing, columnarFooterBytes int64, metricHandle common.MetricHandle, readOnly bool, maxConcurrentReads int64) (fh *FileHandle) {
 	fh = &FileHandle{
 		inode:                 inode,
+		readSlots:             make(chan struct{}, max(maxConcurrentReads, 1)),
 		fileCacheHandler:      fileCacheHandler,
 		cacheFileForRangeRead: cacheFileForRangeRead,
+		verifyChecksums:       verifyChecksums,
+		readCoalescing:        readCoalescing,
+		columnarFooterBytes:   columnarFooterBytes,
 		metricHandle:          metricHandle,
 		readOnly:              readOnly,
 	}
@@ -79,9 +127,8 @@ func (fh *FileHandle) Destroy() {
 	fh.inode.Lock()
 	fh.inode.DeRegisterFileHandle(fh.readOnly)
 	fh.inode.Unlock()
-	if fh.reader != nil {
-		fh.reader.Destroy()
-	}
+	fh.destroyed = true
+	fh.destroyIdleReaders()
 }
 
 // Inode returns the inode backing this handle.
@@ -98,30 +145,49 @@ func (fh *FileHandle) Unlock() {
 }
 
 // Equivalent to locking fh.Inode() and calling fh.Inode().Read, but may be
-// more efficient.
+// more efficient. Up to max-concurrent-reads-per-handle reads run
+// concurrently, each with its own reader.
 //
-// LOCKS_REQUIRED(fh)
+// LOCKS_EXCLUDED(fh)
 // LOCKS_EXCLUDED(fh.inode)
 func (fh *FileHandle) Read(ctx context.Context, dst []byte, offset int64, sequentialReadSizeMb int32) (n int, err error) {
-	// Lock the inode and attempt to ensure that we have a reader for its current
-	// state, or clear fh.reader if it's not possible to create one (probably
+	select {
+	case fh.readSlots <- struct{}{}:
+		defer func() { <-fh.readSlots }()
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	// The read streams of the handle are scheduled as one flow, with
+	// read-min-share-percent.
+	ctx = ratelimit.WithReadFlow(ctx, fh)
+
+	// Lock the inode and attempt to take a reader for its current state, or
+	// destroy the idle readers if it's not possible to create one (probably
 	// because the inode is dirty).
+	fh.mu.Lock()
 	fh.inode.Lock()
-	err = fh.tryEnsureReader(ctx, sequentialReadSizeMb)
+	reader, err := fh.tryTakeReader(ctx, offset, sequentialReadSizeMb)
 	if err != nil {
 		fh.inode.Unlock()
-		err = fmt.Errorf("tryEnsureReader: %w", err)
+		fh.mu.Unlock()
+		err = fmt.Errorf("tryTakeReader: %w", err)
 		return
 	}
 
-	// If we have an appropriate reader, unlock the inode and use that. This
-	// allows reads to proceed concurrently with other operations; in particular,
-	// multiple reads can run concurrently. It's safe because the user can't tell
-	// if a concurrent write started during or after a read.
-	if fh.reader != nil {
+	// If we have an appropriate reader, unlock the inode and the handle and use
+	// that. This allows reads to proceed concurrently with other operations; in
+	// particular, multiple reads can run concurrently, also on this handle. It's
+	// safe because the user can't tell if a concurrent write started during or
+	// after a read.
+	if reader != nil {
 		fh.inode.Unlock()
+		fh.mu.Unlock()
 
-		n, _, err = fh.reader.ReadAt(ctx, dst, offset)
+		n, _, err = reader.ReadAt(ctx, dst, offset)
+
+		fh.mu.Lock()
+		fh.returnReader(reader, offset+int64(n))
+		fh.mu.Unlock()
 		switch {
 		case err == io.EOF:
 			return
@@ -135,6 +201,7 @@ func (fh *FileHandle) Read(ctx context.Context, dst []byte, offset int64, sequen
 	}
 
 	// Otherwise we must fall through to the inode.
+	fh.mu.Unlock()
 	defer fh.inode.Unlock()
 	n, err = fh.inode.Read(ctx, dst, offset)
 
@@ -147,48 +214,82 @@ func (fh *FileHandle) Read(ctx context.Context, dst []byte, offset int64, sequen
 
 // LOCKS_REQUIRED(fh.mu)
 func (fh *FileHandle) checkInvariants() {
-	// INVARIANT: If reader != nil, reader.CheckInvariants() doesn't panic.
-	if fh.reader != nil {
-		fh.reader.CheckInvariants()
+	// INVARIANT: For each r, r.reader.CheckInvariants() doesn't panic.
+	for _, r := range fh.idleReaders {
+		r.reader.CheckInvariants()
 	}
 }
 
-// If possible, ensure that fh.reader is set to an appropriate random reader
-// for the current state of the inode. Otherwise set it to nil.
+// ReleaseIdleReaders destroys the readers not in use by a read, closing their
+// streams from GCS, e.g. while the mount is idle. The next reads create new
+// ones.
 //
-// LOCKS_REQUIRED(fh)
+// LOCKS_REQUIRED(fh.mu)
+func (fh *FileHandle) ReleaseIdleReaders() {
+	fh.destroyIdleReaders()
+}
+
+// LOCKS_REQUIRED(fh.mu)
+func (fh *FileHandle) destroyIdleReaders() {
+	for _, r := range fh.idleReaders {
+		r.reader.Destroy()
+	}
+	fh.idleReaders = nil
+}
+
+// If possible, take a random reader for the current state of the inode out of
+// the idle ones, preferring the one whose last read ends at the supplied
+// offset, or create one. Otherwise return nil.
+//
+// LOCKS_REQUIRED(fh.mu)
 // LOCKS_REQUIRED(fh.inode)
-func (fh *FileHandle) tryEnsureReader(ctx context.Context, sequentialReadSizeMb int32) (err error) {
+func (fh *FileHandle) tryTakeReader(ctx context.Context, offset int64, sequentialReadSizeMb int32) (reader gcsx.RandomReader, err error) {
 	// If content cache enabled, CacheEnsureContent forces the file handler to fall through to the inode
 	// and fh.inode.SourceGenerationIsAuthoritative() will return false
 	err = fh.inode.CacheEnsureContent(ctx)
 	if err != nil {
 		return
 	}
-	// If the inode is dirty, there's nothing we can do. Throw away our reader if
-	// we have one.
+	// If the inode is dirty, there's nothing we can do. Throw away our readers
+	// if we have some.
 	if !fh.inode.SourceGenerationIsAuthoritative() {
-		if fh.reader != nil {
-			fh.reader.Destroy()
-			fh.reader = nil
-		}
-
+		fh.destroyIdleReaders()
+		fh.readerGeneration = 0
 		return
 	}
 
-	// If we already have a reader, and it's at the appropriate generation, we
-	// can use it. Otherwise we must throw it away.
-	if fh.reader != nil {
-		if fh.reader.Object().Generation == fh.inode.SourceGeneration().Object {
-			return
+	// If we already have readers, and they're at the appropriate generation, we
+	// can use them. Otherwise we must throw them away.
+	if generation := fh.inode.SourceGeneration().Object; fh.readerGeneration != generation {
+		fh.destroyIdleReaders()
+		fh.readerGeneration = generation
+	}
+	if len(fh.idleReaders) > 0 {
+		i := len(fh.idleReaders) - 1
+		for j, r := range fh.idleReaders {
+			if r.next == offset {
+				i = j
+				break
+			}
 		}
-		fh.reader.Destroy()
-		fh.reader = nil
+		reader = fh.idleReaders[i].reader
+		fh.idleReaders = slices.Delete(fh.idleReaders, i, i+1)
+		return
 	}
 
 	// Attempt to create an appropriate reader.
-	rr := gcsx.NewRandomReader(fh.inode.Source(), fh.inode.Bucket(), sequentialReadSizeMb, fh.fileCacheHandler, fh.cacheFileForRangeRead, fh.metricHandle)
-
-	fh.reader = rr
+	reader = gcsx.NewRandomReader(fh.inode.Source(), fh.inode.Bucket(), sequentialReadSizeMb, fh.fileCacheHandler, fh.cacheFileForRangeRead, fh.verifyChecksums, fh.readCoalescing, fh.columnarFooterBytes, fh.metricHandle)
 	return
 }
+
+// returnReader makes the reader taken by a read idle again, its last read
+// ending at next, or destroys it if it's no longer appropriate.
+//
+// LOCKS_REQUIRED(fh.mu)
+func (fh *FileHandle) returnReader(reader gcsx.RandomReader, next int64) {
+	if fh.destroyed || reader.Object().Generation != fh.readerGeneration {
+		reader.Destroy()
+		return
+	}
+	fh.idleReaders = append(fh.idleReaders, idleReader{reader: reader, next: next})
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handle
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testObjectSize = 8 << 20
+
+// newTestFileHandle returns a handle of a file of testObjectSize bytes, whose
+// object is read from GCS with the faults of the schedule.
+func newTestFileHandle(t *testing.T, schedule *fake.FaultSchedule, maxConcurrentReads int64) *FileHandle {
+	t.Helper()
+	clock := timeutil.RealClock()
+	wrapped := fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)
+	o, err := storageutil.CreateObject(context.Background(), wrapped, "foo", make([]byte, testObjectSize))
+	require.NoError(t, err)
+	bucket := gcsx.NewSyncerBucket(1, 10, ".gcsfuse_tmp/", fake.NewFaultyBucket(wrapped, schedule))
+	in := inode.NewFileInode(
+		fuseops.RootInodeID+1,
+		inode.NewFileName(inode.NewRootName(""), "foo"),
+		storageutil.ConvertObjToMinObject(o),
+		fuseops.InodeAttributes{Mode: 0644},
+		&bucket,
+		false, // localFileCache
+		contentcache.New("", clock, nil, nil),
+		clock,
+		false, // localFile
+		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}},
+		common.NewNoopMetrics())
+	in.Lock()
+	defer in.Unlock()
+	return NewFileHandle(in, nil, false, false, gcsx.ReadCoalescing{}, 0, common.NewNoopMetrics(), true, maxConcurrentReads)
+}
+
+// concurrentReaders schedules a fault recording the max number of NewReader
+// calls in progress at once.
+func concurrentReaders(schedule *fake.FaultSchedule) *atomic.Int32 {
+	var inProgress, maxInProgress atomic.Int32
+	schedule.Add(fake.Fault{
+		Method: "NewReader",
+		Before: func() {
+			n := inProgress.Add(1)
+			for m := maxInProgress.Load(); n > m && !maxInProgress.CompareAndSwap(m, n); m = maxInProgress.Load() {
+			}
+			time.Sleep(50 * time.Millisecond)
+		},
+		After: func() { inProgress.Add(-1) },
+	})
+	return &maxInProgress
+}
+
+// readConcurrently reads 4 distant ranges of the handle concurrently.
+func readConcurrently(t *testing.T, fh *FileHandle) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := fh.Read(context.Background(), make([]byte, 4096), int64(i)*2<<20, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 4096, n)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFileHandle_ReadsConcurrentlyUpToLimit(t *testing.T) {
+	schedule := fake.NewFaultSchedule()
+	maxInProgress := concurrentReaders(schedule)
+	fh := newTestFileHandle(t, schedule, 2)
+
+	readConcurrently(t, fh)
+
+	assert.EqualValues(t, 2, maxInProgress.Load())
+	fh.Lock()
+	assert.LessOrEqual(t, len(fh.idleReaders), 2)
+	fh.Destroy()
+	fh.Unlock()
+}
+
+func TestFileHandle_ReadsOneAtATimeByDefault(t *testing.T) {
+	schedule := fake.NewFaultSchedule()
+	maxInProgress := concurrentReaders(schedule)
+	fh := newTestFileHandle(t, schedule, 0)
+
+	readConcurrently(t, fh)
+
+	assert.EqualValues(t, 1, maxInProgress.Load())
+	fh.Lock()
+	assert.Len(t, fh.idleReaders, 1)
+	fh.Destroy()
+	fh.Unlock()
+}
+
+func TestFileHandle_SequentialReadsKeepTheirReader(t *testing.T) {
+	schedule := fake.NewFaultSchedule()
+	concurrentReaders(schedule)
+	fh := newTestFileHandle(t, schedule, 2)
+	readConcurrently(t, fh)
+	fh.Lock()
+	require.Len(t, fh.idleReaders, 2)
+	first := fh.idleReaders[0]
+	fh.Unlock()
+	calls := schedule.Calls("NewReader")
+
+	// Reading on from where the first idle reader stopped reuses it and its
+	// stream.
+	_, err := fh.Read(context.Background(), make([]byte, 4096), first.next, 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, calls, schedule.Calls("NewReader"))
+	fh.Lock()
+	defer fh.Unlock()
+	require.Len(t, fh.idleReaders, 2)
+	assert.Equal(t, first.reader, fh.idleReaders[1].reader)
+	assert.Equal(t, first.next+4096, fh.idleReaders[1].next)
+	fh.Destroy()
+}
+
+func TestFileHandle_ReleaseIdleReaders(t *testing.T) {
+	schedule := fake.NewFaultSchedule()
+	concurrentReaders(schedule)
+	fh := newTestFileHandle(t, schedule, 2)
+	readConcurrently(t, fh)
+	calls := schedule.Calls("NewReader")
+
+	fh.Lock()
+	fh.ReleaseIdleReaders()
+	assert.Empty(t, fh.idleReaders)
+	fh.Unlock()
+
+	// The next read creates a reader again.
+	n, err := fh.Read(context.Background(), make([]byte, 4096), 0, 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, 4096, n)
+	assert.Equal(t, calls+1, schedule.Calls("NewReader"))
+	fh.Lock()
+	defer fh.Unlock()
+	assert.Len(t, fh.idleReaders, 1)
+	fh.Destroy()
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import "github.com/googlecloudplatform/gcsfuse/v2/internal/fs/handle"
+
+// releaseIdleReaders destroys the readers of the open files not in use by a
+// read, which hold streams from GCS, while the mount is idle.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) releaseIdleReaders() {
+	fs.mu.Lock()
+	var fileHandles []*handle.FileHandle
+	for _, h := range fs.handles {
+		if fh, ok := h.(*handle.FileHandle); ok {
+			fileHandles = append(fileHandles, fh)
+		}
+	}
+	fs.mu.Unlock()
+
+	// The handles are locked without fs.mu, like by the ops. The ones released
+	// meanwhile have no readers left.
+	for _, fh := range fileHandles {
+		fh.Lock()
+		fh.ReleaseIdleReaders()
+		fh.Unlock()
+	}
+}
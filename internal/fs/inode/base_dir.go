@@ -216,6 +216,14 @@ func (d *baseDirInode) CloneToChildFile(ctx context.Context, name string, src *g
 	return nil, fuse.ENOSYS
 }
 
+func (d *baseDirInode) CloneToNewChildFile(ctx context.Context, name string, src *gcs.MinObject) (*Core, error) {
+	return nil, fuse.ENOSYS
+}
+
+func (d *baseDirInode) CloneFromBucketToChildFile(ctx context.Context, name string, srcBucket string, src *gcs.MinObject, progress func(copiedBytes, totalBytes uint64)) (*Core, error) {
+	return nil, fuse.ENOSYS
+}
+
 func (d *baseDirInode) CreateChildSymlink(ctx context.Context, name string, target string) (*Core, error) {
 	return nil, fuse.ENOSYS
 }
@@ -233,6 +241,11 @@ func (d *baseDirInode) DeleteChildFile(
 	return
 }
 
+func (d *baseDirInode) TrashChildFile(ctx context.Context, name string, trashName string) (err error) {
+	err = fuse.ENOSYS
+	return
+}
+
 func (d *baseDirInode) DeleteChildDir(
 	ctx context.Context,
 	name string,
@@ -110,6 +110,15 @@ type DirInode interface {
 	// Return the full name of the child and the GCS object it backs up.
 	CloneToChildFile(ctx context.Context, name string, src *gcs.MinObject) (*Core, error)
 
+	// Like CloneToChildFile, except fail with *gcs.PreconditionError if a
+	// backing object already exists in GCS.
+	CloneToNewChildFile(ctx context.Context, name string, src *gcs.MinObject) (*Core, error)
+
+	// Like CloneToChildFile, except the source object is in the supplied other
+	// bucket, GCS rewriting its contents into the bucket of the directory. If
+	// non-nil, progress is called with the bytes rewritten so far.
+	CloneFromBucketToChildFile(ctx context.Context, name string, srcBucket string, src *gcs.MinObject, progress func(copiedBytes, totalBytes uint64)) (*Core, error)
+
 	// Create a symlink object with the supplied (relative) name and the supplied
 	// target, failing with *gcs.PreconditionError if a backing object already
 	// exists in GCS.
@@ -134,6 +143,11 @@ type DirInode interface {
 		generation int64,
 		metaGeneration *int64) (err error)
 
+	// Move the backing object for the child file or symlink with the given
+	// (relative) name to the object named trashName, by copying its latest
+	// generation there and deleting it, so that it can be restored.
+	TrashChildFile(ctx context.Context, name string, trashName string) (err error)
+
 	// Delete the backing object for the child directory with the given
 	// (relative) name if it is not an Implicit Directory.
 	DeleteChildDir(
@@ -197,6 +211,10 @@ type dirInode struct {
 
 	enableNonexistentTypeCache bool
 
+	// If set, the symlink children are listed as regular files, like they're
+	// looked up.
+	disableSymlinks bool
+
 	// INVARIANT: name.IsDir()
 	name Name
 
@@ -264,6 +282,7 @@ func NewDirInode(
 	cacheClock timeutil.Clock,
 	typeCacheMaxSizeMB int64,
 	isHNSEnabled bool,
+	disableSymlinks bool,
 ) (d DirInode) {
 
 	if !name.IsDir() {
@@ -282,6 +301,7 @@ func NewDirInode(
 		attrs:                      attrs,
 		cache:                      metadata.NewTypeCache(typeCacheMaxSizeMB, typeCacheTTL),
 		isHNSEnabled:               isHNSEnabled,
+		disableSymlinks:            disableSymlinks,
 		unlinked:                   false,
 	}
 
@@ -429,6 +449,23 @@ func findDirInode(ctx context.Context, bucket *gcsx.SyncerBucket, name Name) (*C
 	return result, nil
 }
 
+// inaccessibleDir returns the core of the directory with the given name in
+// place of err, the error of its lookup, GCS having denied access to it, e.g.
+// because it is a managed folder whose IAM policy denies access. This way, it
+// shows up as a directory, whose listing fails with EACCES, rather than failing
+// the lookup of its parent's entries.
+func (d *dirInode) inaccessibleDir(name Name, err error) *Core {
+	logger.Warnf("Access to %q is denied, showing it as an inaccessible directory: %v", name.GcsObjectName(), err)
+	core := &Core{
+		Bucket:   d.Bucket(),
+		FullName: name,
+	}
+	if d.isBucketHierarchical() {
+		core.Folder = &gcs.Folder{Name: name.GcsObjectName()}
+	}
+	return core
+}
+
 // Fail if the name already exists. Pass on errors directly.
 func (d *dirInode) createNewObject(
 	ctx context.Context,
@@ -547,22 +584,31 @@ func (d *dirInode) LookUpChild(ctx context.Context, name string) (*Core, error)
 		fileResult, err = findExplicitInode(ctx, d.Bucket(), NewFileName(d.Name(), name))
 		return
 	}
-	lookUpExplicitDir := func() (err error) {
-		dirResult, err = findExplicitInode(ctx, d.Bucket(), NewDirName(d.Name(), name))
-		return
-	}
-	lookUpImplicitOrExplicitDir := func() (err error) {
-		dirResult, err = findDirInode(ctx, d.Bucket(), NewDirName(d.Name(), name))
-		return
-	}
-	lookUpHNSDir := func() (err error) {
-		dirResult, err = findExplicitFolder(ctx, d.Bucket(), NewDirName(d.Name(), name))
-		return
+	// The error of the lookup of the directory if access to it is denied.
+	var dirDeniedErr error
+	lookUpDir := func(find func(context.Context, *gcsx.SyncerBucket, Name) (*Core, error)) func() error {
+		return func() (err error) {
+			dirResult, err = find(ctx, d.Bucket(), NewDirName(d.Name(), name))
+			var permissionDeniedErr *gcs.PermissionDeniedError
+			if errors.As(err, &permissionDeniedErr) {
+				dirDeniedErr, err = err, nil
+			}
+			return
+		}
 	}
+	lookUpExplicitDir := lookUpDir(findExplicitInode)
+	lookUpImplicitOrExplicitDir := lookUpDir(findDirInode)
+	lookUpHNSDir := lookUpDir(findExplicitFolder)
 
 	cachedType := d.cache.Get(d.cacheClock.Now(), name)
 	switch cachedType {
 	case metadata.ImplicitDirType:
+		// Hierarchical buckets don't have implicit dirs, the directory is
+		// looked up as a folder so that its inode is backed by it.
+		if d.isBucketHierarchical() {
+			group.Go(lookUpHNSDir)
+			break
+		}
 		dirResult = &Core{
 			Bucket:    d.Bucket(),
 			FullName:  NewDirName(d.Name(), name),
@@ -596,6 +642,16 @@ func (d *dirInode) LookUpChild(ctx context.Context, name string) (*Core, error)
 		return nil, err
 	}
 
+	// Unlike the file, a directory whose access is denied may not exist: it is
+	// only shown as inaccessible if the listing of d showed it, so that those
+	// denied access don't see every name as a directory.
+	if dirDeniedErr != nil && fileResult == nil {
+		if cachedType != metadata.ImplicitDirType && cachedType != metadata.ExplicitDirType {
+			return nil, dirDeniedErr
+		}
+		dirResult = d.inaccessibleDir(NewDirName(d.Name(), name), dirDeniedErr)
+	}
+
 	var result *Core
 	if dirResult != nil {
 		result = dirResult
@@ -789,6 +845,9 @@ func (d *dirInode) ReadEntries(
 		switch core.Type() {
 		case metadata.SymlinkType:
 			entry.Type = fuseutil.DT_Link
+			if d.disableSymlinks {
+				entry.Type = fuseutil.DT_File
+			}
 		case metadata.RegularFileType:
 			entry.Type = fuseutil.DT_File
 		case metadata.ImplicitDirType, metadata.ExplicitDirType:
@@ -843,19 +902,40 @@ func (d *dirInode) EraseFromTypeCache(name string) {
 
 // LOCKS_REQUIRED(d)
 func (d *dirInode) CloneToChildFile(ctx context.Context, name string, src *gcs.MinObject) (*Core, error) {
+	// Clone over anything that might already exist for the name.
+	return d.cloneToChildFile(ctx, name, src, &gcs.CopyObjectRequest{})
+}
+
+// LOCKS_REQUIRED(d)
+func (d *dirInode) CloneToNewChildFile(ctx context.Context, name string, src *gcs.MinObject) (*Core, error) {
+	var precond int64
+	return d.cloneToChildFile(ctx, name, src, &gcs.CopyObjectRequest{
+		DstGenerationPrecondition: &precond,
+	})
+}
+
+// LOCKS_REQUIRED(d)
+func (d *dirInode) CloneFromBucketToChildFile(ctx context.Context, name string, srcBucket string, src *gcs.MinObject, progress func(copiedBytes, totalBytes uint64)) (*Core, error) {
+	return d.cloneToChildFile(ctx, name, src, &gcs.CopyObjectRequest{
+		SrcBucketName: srcBucket,
+		Progress:      progress,
+	})
+}
+
+// cloneToChildFile copies src to the child of the supplied name, with the
+// remaining options of req.
+//
+// LOCKS_REQUIRED(d)
+func (d *dirInode) cloneToChildFile(ctx context.Context, name string, src *gcs.MinObject, req *gcs.CopyObjectRequest) (*Core, error) {
 	// Erase any existing type information for this name.
 	d.cache.Erase(name)
 	fullName := NewFileName(d.Name(), name)
 
-	// Clone over anything that might already exist for the name.
-	o, err := d.bucket.CopyObject(
-		ctx,
-		&gcs.CopyObjectRequest{
-			SrcName:                       src.Name,
-			SrcGeneration:                 src.Generation,
-			SrcMetaGenerationPrecondition: &src.MetaGeneration,
-			DstName:                       fullName.GcsObjectName(),
-		})
+	req.SrcName = src.Name
+	req.SrcGeneration = src.Generation
+	req.SrcMetaGenerationPrecondition = &src.MetaGeneration
+	req.DstName = fullName.GcsObjectName()
+	o, err := d.bucket.CopyObject(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -873,11 +953,18 @@ func (d *dirInode) CloneToChildFile(ctx context.Context, name string, src *gcs.M
 // LOCKS_REQUIRED(d)
 func (d *dirInode) CreateChildSymlink(ctx context.Context, name string, target string) (*Core, error) {
 	fullName := NewFileName(d.Name(), name)
-	childMetadata := map[string]string{
-		SymlinkMetadataKey: target,
-	}
 
-	o, err := d.createNewObject(ctx, fullName, childMetadata)
+	// Like createNewObject, but the target is also the contents of the object.
+	var precond int64
+	o, err := d.bucket.CreateObject(ctx, &gcs.CreateObjectRequest{
+		Name:                   fullName.GcsObjectName(),
+		Contents:               strings.NewReader(target),
+		ContentType:            SymlinkContentType,
+		GenerationPrecondition: &precond,
+		Metadata: map[string]string{
+			SymlinkMetadataKey: target,
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -955,6 +1042,34 @@ func (d *dirInode) DeleteChildFile(
 	return
 }
 
+// LOCKS_REQUIRED(d)
+func (d *dirInode) TrashChildFile(ctx context.Context, name string, trashName string) error {
+	d.cache.Erase(name)
+	childName := NewFileName(d.Name(), name)
+
+	o, _, err := d.bucket.StatObject(ctx, &gcs.StatObjectRequest{
+		Name:              childName.GcsObjectName(),
+		ForceFetchFromGcs: true,
+	})
+	if err != nil {
+		return fmt.Errorf("StatObject: %w", err)
+	}
+
+	// Copy and delete exactly the same generation, in case the referent of the
+	// name changes in the meantime.
+	_, err = d.bucket.CopyObject(ctx, &gcs.CopyObjectRequest{
+		SrcName:                       o.Name,
+		DstName:                       trashName,
+		SrcGeneration:                 o.Generation,
+		SrcMetaGenerationPrecondition: &o.MetaGeneration,
+	})
+	if err != nil {
+		return fmt.Errorf("CopyObject: %w", err)
+	}
+
+	return d.DeleteChildFile(ctx, name, o.Generation, &o.MetaGeneration)
+}
+
 // LOCKS_REQUIRED(d)
 func (d *dirInode) DeleteChildDir(
 	ctx context.Context,
@@ -965,8 +1080,10 @@ func (d *dirInode) DeleteChildDir(
 
 	// If the directory is an implicit directory, then no backing object
 	// exists in the gcs bucket, so returning from here.
-	// Hierarchical buckets don't have implicit dirs so this will be always false in hierarchical bucket case.
-	if isImplicitDir {
+	// Hierarchical buckets don't have implicit dirs: every directory is backed
+	// by a folder, even if it was typed implicit e.g. by a stale type cache
+	// entry, so the folder is deleted regardless.
+	if isImplicitDir && !d.isBucketHierarchical() {
 		return nil
 	}
 
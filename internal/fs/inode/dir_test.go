@@ -20,10 +20,12 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
@@ -56,6 +58,9 @@ type DirTest struct {
 	bucket gcsx.SyncerBucket
 	clock  timeutil.SimulatedClock
 
+	// A bucket whose objects can be copied into bucket.
+	otherBucket gcs.Bucket
+
 	in DirInode
 	tc metadata.TypeCache
 }
@@ -69,6 +74,8 @@ func (t *DirTest) SetUp(ti *TestInfo) {
 	t.ctx = ti.Ctx
 	t.clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
 	bucket := fake.NewFakeBucket(&t.clock, "some_bucket", gcs.NonHierarchical)
+	t.otherBucket = fake.NewFakeBucket(&t.clock, "other_bucket", gcs.NonHierarchical)
+	fake.LinkFakeBuckets(bucket, t.otherBucket)
 	t.bucket = gcsx.NewSyncerBucket(
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
@@ -121,6 +128,7 @@ func (t *DirTest) resetInodeWithTypeCacheConfigs(implicitDirs, enableNonexistent
 		&t.clock,
 		typeCacheMaxSizeMB,
 		false,
+		false,
 	)
 
 	d := t.in.(*dirInode)
@@ -149,6 +157,7 @@ func (t *DirTest) createDirInode(dirInodeName string) DirInode {
 		&t.clock,
 		4,
 		false,
+		false,
 	)
 }
 
@@ -203,10 +212,11 @@ func (t *DirTest) createLocalFileInode(parent Name, name string, id fuseops.Inod
 		},
 		&t.bucket,
 		false, // localFileCache
-		contentcache.New("", &t.clock),
+		contentcache.New("", &t.clock, nil, nil),
 		&t.clock,
 		true, //localFile
-		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}})
+		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}},
+		common.NewNoopMetrics())
 	return
 }
 
@@ -214,6 +224,43 @@ func (t *DirTest) getLocalDirentKey(in Inode) string {
 	return path.Base(in.Name().LocalName())
 }
 
+// permissionDeniedBucket denies access to the objects under deniedPrefix, like
+// GCS does for a managed folder whose IAM policy denies access.
+type permissionDeniedBucket struct {
+	gcs.Bucket
+	deniedPrefix string
+}
+
+func (b *permissionDeniedBucket) denied(name string) error {
+	if strings.HasPrefix(name, b.deniedPrefix) {
+		return &gcs.PermissionDeniedError{Err: errors.New("access denied")}
+	}
+	return nil
+}
+
+func (b *permissionDeniedBucket) ListObjects(ctx context.Context, req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if err := b.denied(req.Prefix); err != nil {
+		return nil, err
+	}
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+func (b *permissionDeniedBucket) StatObject(ctx context.Context, req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	if err := b.denied(req.Name); err != nil {
+		return nil, nil, err
+	}
+	return b.Bucket.StatObject(ctx, req)
+}
+
+// denyAccess denies access to the objects under the child directory of the
+// inode with the given name.
+func (t *DirTest) denyAccess(name string) {
+	t.bucket.Bucket = &permissionDeniedBucket{
+		Bucket:       t.bucket.Bucket,
+		deniedPrefix: path.Join(dirInodeName, name) + "/",
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Tests
 ////////////////////////////////////////////////////////////////////////
@@ -366,6 +413,78 @@ func (t *DirTest) LookUpChild_ImplicitDirOnly_Enabled() {
 	ExpectEq(metadata.UnknownType, t.getTypeFromCache(name+ConflictingFileNameSuffix))
 }
 
+func (t *DirTest) LookUpChild_PermissionDenied_ImplicitDirsEnabled() {
+	const name = "qux"
+	objName := path.Join(dirInodeName, name) + "/"
+	t.resetInode(true, false, true)
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, path.Join(objName, "asdf"), []byte(""))
+	AssertEq(nil, err)
+	t.denyAccess(name)
+	_, err = t.readAllEntries()
+	AssertEq(nil, err)
+
+	// The directory is inaccessible rather than failing the lookup.
+	result, err := t.in.LookUpChild(t.ctx, name)
+
+	AssertEq(nil, err)
+	AssertNe(nil, result)
+	ExpectEq(objName, result.FullName.GcsObjectName())
+	ExpectEq(metadata.ImplicitDirType, result.Type())
+	ExpectEq(metadata.ImplicitDirType, t.getTypeFromCache(name))
+	// Its listing fails.
+	_, _, err = t.createDirInode(objName).ReadEntries(t.ctx, "")
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	ExpectTrue(errors.As(err, &permissionDeniedErr))
+}
+
+func (t *DirTest) LookUpChild_PermissionDenied_ImplicitDirsDisabled() {
+	const name = "qux"
+	objName := path.Join(dirInodeName, name) + "/"
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, objName, []byte(""))
+	AssertEq(nil, err)
+	t.denyAccess(name)
+	_, err = t.readAllEntries()
+	AssertEq(nil, err)
+
+	result, err := t.in.LookUpChild(t.ctx, name)
+
+	AssertEq(nil, err)
+	AssertNe(nil, result)
+	ExpectEq(objName, result.FullName.GcsObjectName())
+	ExpectEq(nil, result.MinObject)
+	ExpectTrue(result.FullName.IsDir())
+}
+
+func (t *DirTest) LookUpChild_PermissionDenied_NotListed() {
+	const name = "qux"
+	t.resetInode(true, false, true)
+	t.denyAccess(name)
+
+	// The directory isn't known from the listing of its parent, so it may not
+	// exist: the lookup fails rather than showing it.
+	result, err := t.in.LookUpChild(t.ctx, name)
+
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	ExpectTrue(errors.As(err, &permissionDeniedErr))
+	ExpectEq(nil, result)
+	ExpectEq(metadata.UnknownType, t.getTypeFromCache(name))
+}
+
+func (t *DirTest) LookUpChild_PermissionDenied_File() {
+	const name = "qux"
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, path.Join(dirInodeName, name), []byte("taco"))
+	AssertEq(nil, err)
+	t.denyAccess(name)
+
+	// Only the directory named like the file is denied, so the file is found.
+	result, err := t.in.LookUpChild(t.ctx, name)
+
+	AssertEq(nil, err)
+	AssertNe(nil, result)
+	ExpectEq(metadata.RegularFileType, result.Type())
+	ExpectEq(metadata.RegularFileType, t.getTypeFromCache(name))
+}
+
 func (t *DirTest) LookUpChild_FileAndDir() {
 	const name = "qux"
 	fileObjName := path.Join(dirInodeName, name)
@@ -844,6 +963,22 @@ func (t *DirTest) ReadEntries_NonEmpty_ImplicitDirsDisabled() {
 	AssertFalse(d.prevDirListingTimeStamp.IsZero())
 }
 
+func (t *DirTest) ReadEntries_SymlinksDisabled() {
+	d := t.in.(*dirInode)
+	d.disableSymlinks = true
+	err := storageutil.CreateEmptyObjects(t.ctx, t.bucket, []string{dirInodeName + "symlink"})
+	AssertEq(nil, err)
+	err = t.setSymlinkTarget(dirInodeName+"symlink", "blah")
+	AssertEq(nil, err)
+
+	entries, err := t.readAllEntries()
+
+	AssertEq(nil, err)
+	AssertEq(1, len(entries))
+	ExpectEq("symlink", entries[0].Name)
+	ExpectEq(fuseutil.DT_File, entries[0].Type)
+}
+
 func (t *DirTest) ReadEntries_NonEmpty_ImplicitDirsEnabled() {
 	var err error
 	var entry fuseutil.Dirent
@@ -908,6 +1043,29 @@ func (t *DirTest) ReadEntries_NonEmpty_ImplicitDirsEnabled() {
 	AssertFalse(d.prevDirListingTimeStamp.IsZero())
 }
 
+func (t *DirTest) ReadEntries_PermissionDeniedSubtree() {
+	t.resetInode(true, false, true)
+	objs := []string{
+		dirInodeName + "file",
+		dirInodeName + "denied/file",
+		dirInodeName + "allowed/file",
+	}
+	err := storageutil.CreateEmptyObjects(t.ctx, t.bucket, objs)
+	AssertEq(nil, err)
+	t.denyAccess("denied")
+
+	entries, err := t.readAllEntries()
+
+	AssertEq(nil, err)
+	AssertEq(3, len(entries))
+	ExpectEq("allowed", entries[0].Name)
+	ExpectEq(fuseutil.DT_Directory, entries[0].Type)
+	ExpectEq("denied", entries[1].Name)
+	ExpectEq(fuseutil.DT_Directory, entries[1].Type)
+	ExpectEq("file", entries[2].Name)
+	ExpectEq(fuseutil.DT_File, entries[2].Type)
+}
+
 func (t *DirTest) ReadEntries_TypeCaching() {
 	const name = "qux"
 	fileObjName := path.Join(dirInodeName, name)
@@ -1120,6 +1278,82 @@ func (t *DirTest) CloneToChildFile_DestinationExists() {
 	ExpectEq(metadata.RegularFileType, t.getTypeFromCache("qux"))
 }
 
+func (t *DirTest) CloneToNewChildFile_DestinationDoesntExist() {
+	const srcName = "blah/baz"
+	dstName := path.Join(dirInodeName, "qux")
+
+	// Create the source.
+	src, err := storageutil.CreateObject(t.ctx, t.bucket, srcName, []byte("taco"))
+	AssertEq(nil, err)
+
+	// Call the inode.
+	result, err := t.in.CloneToNewChildFile(t.ctx, path.Base(dstName), storageutil.ConvertObjToMinObject(src))
+	AssertEq(nil, err)
+	AssertNe(nil, result)
+	AssertNe(nil, result.MinObject)
+	ExpectEq(dstName, result.MinObject.Name)
+	ExpectEq(metadata.RegularFileType, t.getTypeFromCache("qux"))
+
+	// Check resulting contents.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, dstName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+}
+
+func (t *DirTest) CloneToNewChildFile_DestinationExists() {
+	const srcName = "blah/baz"
+	dstName := path.Join(dirInodeName, "qux")
+
+	// Create the source.
+	src, err := storageutil.CreateObject(t.ctx, t.bucket, srcName, []byte("taco"))
+	AssertEq(nil, err)
+
+	// And a destination object that must not be overwritten.
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, dstName, []byte("burrito"))
+	AssertEq(nil, err)
+
+	// Call the inode.
+	_, err = t.in.CloneToNewChildFile(t.ctx, path.Base(dstName), storageutil.ConvertObjToMinObject(src))
+	var preconditionErr *gcs.PreconditionError
+	ExpectTrue(errors.As(err, &preconditionErr))
+
+	// Check the contents are unchanged.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, dstName)
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(contents))
+}
+
+func (t *DirTest) CloneFromBucketToChildFile() {
+	const srcName = "blah/baz"
+	dstName := path.Join(dirInodeName, "qux")
+
+	// Create the source in the other bucket.
+	src, err := storageutil.CreateObject(t.ctx, t.otherBucket, srcName, []byte("taco"))
+	AssertEq(nil, err)
+
+	// Call the inode.
+	var copied, total uint64
+	progress := func(copiedBytes, totalBytes uint64) {
+		copied, total = copiedBytes, totalBytes
+	}
+	srcMinObject := storageutil.ConvertObjToMinObject(src)
+	result, err := t.in.CloneFromBucketToChildFile(t.ctx, path.Base(dstName), t.otherBucket.Name(), srcMinObject, progress)
+	AssertEq(nil, err)
+	AssertNe(nil, result)
+	ExpectEq(t.bucket.Name(), result.Bucket.Name())
+	ExpectEq(dstName, result.MinObject.Name)
+	ExpectEq(metadata.RegularFileType, t.getTypeFromCache("qux"))
+	ExpectEq(len("taco"), copied)
+	ExpectEq(len("taco"), total)
+
+	// Check resulting contents, and that the source is left in its bucket.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, dstName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+	_, err = storageutil.ReadObject(t.ctx, t.otherBucket, srcName)
+	ExpectEq(nil, err)
+}
+
 func (t *DirTest) CloneToChildFile_TypeCaching() {
 	const srcName = "blah/baz"
 	dstName := path.Join(dirInodeName, "qux")
@@ -1178,6 +1412,14 @@ func (t *DirTest) CreateChildSymlink_DoesntExist() {
 	ExpectEq(result.FullName.GcsObjectName(), result.MinObject.Name)
 	ExpectEq(objName, result.MinObject.Name)
 	ExpectEq(target, result.MinObject.Metadata[SymlinkMetadataKey])
+
+	// The target is also the contents of the object.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, objName)
+	AssertEq(nil, err)
+	ExpectEq(target, string(contents))
+	_, attrs, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: objName, ForceFetchFromGcs: true, ReturnExtendedObjectAttributes: true})
+	AssertEq(nil, err)
+	ExpectEq(SymlinkContentType, attrs.ContentType)
 }
 
 func (t *DirTest) CreateChildSymlink_Exists() {
@@ -1270,6 +1512,37 @@ func (t *DirTest) CreateChildDir_Exists() {
 	ExpectEq(metadata.UnknownType, t.getTypeFromCache(name))
 }
 
+func (t *DirTest) TrashChildFile() {
+	const name = "qux"
+	objName := path.Join(dirInodeName, name)
+	const trashName = ".trash/foo/bar/qux.deleted-20240102T150405Z"
+
+	var err error
+
+	// Create a backing object.
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, objName, []byte("taco"))
+	AssertEq(nil, err)
+
+	err = t.in.TrashChildFile(t.ctx, name, trashName)
+	AssertEq(nil, err)
+
+	// The object should have been moved to the trash.
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, objName)
+	var notFoundErr *gcs.NotFoundError
+	ExpectTrue(errors.As(err, &notFoundErr))
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, trashName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+	ExpectEq(metadata.UnknownType, t.getTypeFromCache(name))
+}
+
+func (t *DirTest) TrashChildFile_DoesntExist() {
+	err := t.in.TrashChildFile(t.ctx, "qux", ".trash/qux")
+
+	var notFoundErr *gcs.NotFoundError
+	ExpectTrue(errors.As(err, &notFoundErr))
+}
+
 func (t *DirTest) DeleteChildFile_DoesntExist() {
 	const name = "qux"
 
@@ -18,13 +18,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufferedwrites"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
@@ -34,13 +39,20 @@ import (
 	"github.com/jacobsa/syncutil"
 	"github.com/jacobsa/timeutil"
 	"golang.org/x/net/context"
-	"golang.org/x/sync/semaphore"
 )
 
 // A GCS object metadata key for file mtimes. mtimes are UTC, and are stored in
 // the format defined by time.RFC3339Nano.
 const FileMtimeMetadataKey = gcs.MtimeMetadataKey
 
+// A GCS object metadata key for the permission bits of files, in octal, with
+// honor-umask. It's the key used by gcloud storage with --preserve-posix.
+const FileModeMetadataKey = "goog-reserved-posix-mode"
+
+// streamingWritesFeature is the kill switch of the streaming writes, with
+// which the files opened afterwards are written to temp files instead.
+var streamingWritesFeature = featureflag.Register("streaming-writes", "Stream the writes of new and empty files to GCS (--experimental-enable-streaming-writes).")
+
 type FileInode struct {
 	/////////////////////////
 	// Dependencies
@@ -88,14 +100,33 @@ type FileInode struct {
 	// GUARDED_BY(mu)
 	destroyed bool
 
+	// The generation of the source object when the inode was last opened, at
+	// which the kernel may have cached its pages since.
+	//
+	// GUARDED_BY(mu)
+	pageCacheGeneration int64
+
+	// The permission bits requested by the process creating the file, with
+	// honor-umask, until Sync persists them in the metadata of its object.
+	//
+	// GUARDED_BY(mu)
+	creationMode *os.FileMode
+
+	// The generation the object must have for the next Sync to write out the
+	// contents, zero meaning that it must not exist. Nil when there is none.
+	//
+	// GUARDED_BY(mu)
+	generationPrecondition *int64
+
 	// Represents a local file which is not yet synced to GCS.
 	local bool
 
 	// Represents if local file has been unlinked.
 	unlinked bool
 
-	bwh    *bufferedwrites.BufferedWriteHandler
-	config *cfg.Config
+	bwh          *bufferedwrites.BufferedWriteHandler
+	config       *cfg.Config
+	metricHandle common.MetricHandle
 
 	// Once write is started on the file i.e, bwh is initialized, any fileHandles
 	// opened in write mode before or after this and not yet closed are considered
@@ -105,6 +136,17 @@ type FileInode struct {
 	// and set bwh to nil after all fileHandlers are closed.
 	// writeHandleCount tracks the count of open fileHandles in write mode.
 	writeHandleCount int32
+
+	// The object the writes streamed by bwh are appended to, with
+	// write.streaming-appends, bwh then uploading them to a temporary object
+	// composed onto it on flush. Nil when bwh writes the whole object.
+	//
+	// GUARDED_BY(mu)
+	appendTo *gcs.Object
+
+	// upload tracks the contents waiting to be written out and their upload,
+	// without the lock.
+	upload uploadTracker
 }
 
 var _ Inode = &FileInode{}
@@ -127,7 +169,8 @@ func NewFileInode(
 	contentCache *contentcache.ContentCache,
 	mtimeClock timeutil.Clock,
 	localFile bool,
-	cfg *cfg.Config) (f *FileInode) {
+	cfg *cfg.Config,
+	metricHandle common.MetricHandle) (f *FileInode) {
 	// Set up the basic struct.
 	var minObj gcs.MinObject
 	if m != nil {
@@ -145,6 +188,7 @@ func NewFileInode(
 		local:          localFile,
 		unlinked:       false,
 		config:         cfg,
+		metricHandle:   metricHandle,
 	}
 
 	f.lc.Init(id)
@@ -372,6 +416,18 @@ func (f *FileInode) SourceGeneration() (g Generation) {
 	return
 }
 
+// KeepPageCache returns true if the pages of the file cached by the kernel
+// can be kept by an open of the file, i.e. if the source object hasn't changed
+// since the last open, and records the source generation as the one of the
+// pages cached from then on.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) KeepPageCache() bool {
+	keep := f.pageCacheGeneration == f.src.Generation
+	f.pageCacheGeneration = f.src.Generation
+	return keep
+}
+
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) IncrementLookupCount() {
 	f.lc.Inc()
@@ -409,6 +465,7 @@ func (f *FileInode) DeRegisterFileHandle(readOnly bool) {
 			logger.Warnf("Error while destroying the bufferedWritesHandler: %v", err)
 		}
 		f.bwh = nil
+		f.appendTo = nil
 	}
 }
 
@@ -469,7 +526,15 @@ func (f *FileInode) Attributes(
 	if f.bwh != nil {
 		writeFileInfo := f.bwh.WriteFileInfo()
 		attrs.Mtime = writeFileInfo.Mtime
-		attrs.Size = uint64(writeFileInfo.TotalSize)
+		attrs.Size = uint64(f.appendBase() + writeFileInfo.TotalSize)
+	}
+
+	if f.config.FileSystem.HonorUmask {
+		if f.creationMode != nil {
+			attrs.Mode = attrs.Mode&^os.ModePerm | *f.creationMode
+		} else if perm, err := strconv.ParseUint(f.src.Metadata[FileModeMetadataKey], 8, 32); err == nil {
+			attrs.Mode = attrs.Mode&^os.ModePerm | os.FileMode(perm)&os.ModePerm
+		}
 	}
 
 	// We require only that atime and ctime be "reasonable".
@@ -508,9 +573,10 @@ func (f *FileInode) Read(
 	ctx context.Context,
 	dst []byte,
 	offset int64) (n int, err error) {
-	// It is not nil when streaming writes are enabled in 2 scenarios:
+	// It is not nil when streaming writes are enabled in 3 scenarios:
 	// 1. Local file
 	// 2. Empty GCS files and writes are triggered via buffered flow.
+	// 3. Appends to GCS files with write.streaming-appends.
 	if f.bwh != nil {
 		err = fmt.Errorf("cannot read a file when upload in progress")
 		return
@@ -544,12 +610,21 @@ func (f *FileInode) Write(
 	ctx context.Context,
 	data []byte,
 	offset int64) error {
+	if err := f.acquireLease(ctx); err != nil {
+		return err
+	}
+
 	// For empty GCS files also we will trigger bufferedWrites flow.
-	if f.src.Size == 0 && f.config.Write.ExperimentalEnableStreamingWrites {
+	if f.src.Size == 0 && f.streamingWritesEnabled() && f.generationPrecondition == nil {
 		err := f.ensureBufferedWriteHandler(ctx)
 		if err != nil {
 			return err
 		}
+	} else if f.isStreamingAppend(offset) {
+		err := f.ensureStreamingAppend(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	if f.bwh != nil {
@@ -563,16 +638,24 @@ func (f *FileInode) Write(
 //
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) writeUsingTempFile(ctx context.Context, data []byte, offset int64) (err error) {
+	clean := f.content == nil
+
 	// Make sure f.content != nil.
 	err = f.ensureContent(ctx)
 	if err != nil {
 		err = fmt.Errorf("ensureContent: %w", err)
 		return
 	}
+	if clean {
+		f.upload.dirty(int64(f.src.Size))
+	}
 
 	// Write to the mutable content. Note that io.WriterAt guarantees it returns
 	// an error for short writes.
 	_, err = f.content.WriteAt(data, offset)
+	if err == nil {
+		f.upload.grow(offset + int64(len(data)))
+	}
 
 	return
 }
@@ -581,7 +664,12 @@ func (f *FileInode) writeUsingTempFile(ctx context.Context, data []byte, offset
 //
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) writeUsingBufferedWrites(ctx context.Context, data []byte, offset int64) error {
-	err := f.bwh.Write(data, offset)
+	// The offsets of bwh are relative to the object the writes are appended to.
+	bwhOffset := offset - f.appendBase()
+	err := f.bwh.Write(data, bwhOffset)
+	if err == nil {
+		f.upload.grow(bwhOffset + int64(len(data)))
+	}
 	if err == bufferedwrites.ErrOutOfOrderWrite || err == bufferedwrites.ErrUploadFailure {
 		// Finalize the object.
 		flushErr := f.flushUsingBufferedWriteHandler()
@@ -598,18 +686,39 @@ func (f *FileInode) writeUsingBufferedWrites(ctx context.Context, data []byte, o
 	return err
 }
 
+// SyncBufferedWrites waits for the upload of the blocks of the streaming
+// writes filled so far, without finalizing the object. It returns false if
+// the file isn't being written with streaming writes.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) SyncBufferedWrites() (bool, error) {
+	if f.bwh == nil {
+		return false, nil
+	}
+	if err := f.bwh.Sync(); err != nil {
+		return true, fmt.Errorf("f.bwh.Sync(): %w", err)
+	}
+	return true, nil
+}
+
 // Helper function to flush buffered writes handler and update inode state with
 // new object.
 //
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) flushUsingBufferedWriteHandler() error {
+	if f.appendTo != nil {
+		return f.flushStreamingAppend()
+	}
+
+	f.upload.start()
 	obj, err := f.bwh.Flush()
+	f.upload.finish(err == nil)
 
 	var preconditionErr *gcs.PreconditionError
 	if errors.As(err, &preconditionErr) {
-		return &gcsfuse_errors.FileClobberedError{
+		return f.strictClobberedError(&gcsfuse_errors.FileClobberedError{
 			Err: fmt.Errorf("f.bwh.Flush(): %w", err),
-		}
+		})
 	}
 
 	// bwh can return a partially synced object along with an error so updating
@@ -622,6 +731,52 @@ func (f *FileInode) flushUsingBufferedWriteHandler() error {
 	return nil
 }
 
+// flushStreamingAppend finalizes the temporary object holding the writes
+// streamed by bwh, and composes it onto the object they're appended to. If the
+// object was modified since, the appended contents are dropped and the file
+// is reported as clobbered, as the writes of the other actor can't be
+// overwritten by an append.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) flushStreamingAppend() error {
+	ctx := context.Background()
+	mtime := f.bwh.WriteFileInfo().Mtime
+
+	f.upload.start()
+	tmp, err := f.bwh.Flush()
+	if err != nil {
+		f.upload.finish(false)
+		// A partially uploaded temporary object is of no use.
+		if tmp != nil {
+			if deleteErr := f.bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: tmp.Name}); deleteErr != nil {
+				logger.Warnf("Deleting the temporary object %q: %v", tmp.Name, deleteErr)
+			}
+		}
+		return fmt.Errorf("f.bwh.Flush(): %w", err)
+	}
+
+	o, err := f.bucket.AppendObject(ctx, f.appendTo, tmp, &mtime)
+	f.upload.finish(err == nil)
+	if err != nil {
+		// The temporary object is deleted, so the appended contents can't be
+		// written out again.
+		f.bwh = nil
+		f.appendTo = nil
+		f.upload.resize(0)
+
+		var preconditionErr *gcs.PreconditionError
+		if errors.As(err, &preconditionErr) {
+			return f.strictClobberedError(&gcsfuse_errors.FileClobberedError{
+				Err: fmt.Errorf("AppendObject: %w", err),
+			})
+		}
+		return fmt.Errorf("AppendObject: %w", err)
+	}
+
+	f.updateInodeStateAfterSync(storageutil.ConvertObjToMinObject(o))
+	return nil
+}
+
 // Set the mtime for this file. May involve a round trip to GCS.
 //
 // LOCKS_REQUIRED(f.mu)
@@ -738,26 +893,86 @@ func (f *FileInode) fetchLatestGcsObject(ctx context.Context) (*gcs.Object, erro
 //
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) Sync(ctx context.Context) (err error) {
-	// If we have not been dirtied, there is nothing to do.
-	if f.content == nil {
+	// The contents aren't written out if the lease of the object was lost. The
+	// upload is conditioned on the meta-generation of the renewed lease, so it
+	// fails if the lease expires during it and another holder takes it.
+	if f.content != nil || f.bwh != nil {
+		if err = f.renewLease(ctx); err != nil {
+			return
+		}
+	}
+	leased := f.holdsLease()
+
+	// The streaming writes are finalized.
+	if f.bwh != nil {
+		if err = f.flushUsingBufferedWriteHandler(); err != nil {
+			var clobberedErr *gcsfuse_errors.FileClobberedError
+			if leased && errors.As(err, &clobberedErr) {
+				err = f.leaseLostDuringUpload(err)
+			}
+			return
+		}
+		f.persistCreationMode(ctx)
+		f.releaseLease(ctx)
 		return
 	}
 
-	latestGcsObj, err := f.fetchLatestGcsObject(ctx)
-	if err != nil {
+	// If we have not been dirtied, there is nothing to do but persisting the
+	// creation mode, and releasing the lease taken by a failed write.
+	if f.content == nil {
+		f.persistCreationMode(ctx)
+		f.releaseLease(ctx)
 		return
 	}
 
+	// The contents are uploaded from scratch, or appended to the object, and
+	// the upload reports its progress through the context.
+	f.upload.Committed(0)
+	f.upload.start()
+	defer func() {
+		f.upload.finish(err == nil || f.content == nil)
+	}()
+	ctx = gcs.WithUploadProgress(ctx, f.upload.Committed)
+
+	var latestGcsObj *gcs.Object
+	if f.generationPrecondition != nil {
+		latestGcsObj, err = f.checkGenerationPrecondition(ctx)
+		if err != nil {
+			return
+		}
+	} else {
+		latestGcsObj, err = f.fetchLatestGcsObject(ctx)
+		if err != nil {
+			err = f.resolveClobbering(ctx, err)
+			return
+		}
+	}
+
 	// Write out the contents if they are dirty.
 	// Object properties are also synced as part of content sync. Hence, passing
 	// the latest object fetched from gcs which has all the properties populated.
-	newObj, err := f.bucket.SyncObject(ctx, f.Name().GcsObjectName(), latestGcsObj, f.content)
+	var newObj *gcs.Object
+	if f.generationPrecondition != nil && !f.IsLocal() && (latestGcsObj == nil || latestGcsObj.Generation != f.src.Generation) {
+		// The contents don't derive from the expected generation, so they can't
+		// be appended to it.
+		newObj, err = f.createFromContent(ctx, f.Name().GcsObjectName(), latestGcsObj)
+	} else {
+		newObj, err = f.bucket.SyncObject(ctx, f.Name().GcsObjectName(), latestGcsObj, f.content)
+	}
 
 	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) && f.generationPrecondition != nil {
+		err = fmt.Errorf("%w: %q was modified after its generation was checked: %v", syscall.ESTALE, f.Name().GcsObjectName(), err)
+		return
+	}
+	if errors.As(err, &preconditionErr) && leased {
+		err = f.leaseLostDuringUpload(err)
+		return
+	}
 	if errors.As(err, &preconditionErr) {
-		err = &gcsfuse_errors.FileClobberedError{
+		err = f.resolveClobbering(ctx, &gcsfuse_errors.FileClobberedError{
 			Err: fmt.Errorf("SyncObject: %w", err),
-		}
+		})
 		return
 	}
 
@@ -769,9 +984,203 @@ func (f *FileInode) Sync(ctx context.Context) (err error) {
 	minObj := storageutil.ConvertObjToMinObject(newObj)
 	// If we wrote out a new object, we need to update our state.
 	f.updateInodeStateAfterSync(minObj)
+	f.generationPrecondition = nil
+	f.persistCreationMode(ctx)
+	f.releaseLease(ctx)
 	return
 }
 
+// UploadStatus returns the progress of writing out the contents of the file.
+// It doesn't need the lock, so that it can be called during a flush.
+func (f *FileInode) UploadStatus() UploadStatus {
+	return f.upload.status()
+}
+
+// GenerationPrecondition returns the generation set with
+// SetGenerationPrecondition, or nil if there is none.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) GenerationPrecondition() *int64 {
+	return f.generationPrecondition
+}
+
+// SetGenerationPrecondition makes the next Sync write out the contents only if
+// the object has the supplied generation, zero meaning that it must not exist,
+// and fail with ESTALE otherwise. Nil removes the precondition. The upload of
+// streaming writes is created with the generation it started from, so a file
+// with a precondition is written through a temp file instead.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) SetGenerationPrecondition(generation *int64) error {
+	if generation != nil && f.bwh != nil {
+		if f.bwh.WriteFileInfo().TotalSize > 0 {
+			return fmt.Errorf("%w: an upload of %q is in progress", syscall.EBUSY, f.Name().GcsObjectName())
+		}
+		if err := f.bwh.Destroy(); err != nil {
+			logger.Warnf("Error while destroying the bufferedWritesHandler: %v", err)
+		}
+		f.bwh = nil
+		// Nothing was appended to the object yet, its contents are unchanged.
+		if f.appendTo != nil {
+			f.appendTo = nil
+		} else if err := f.createEmptyTempFile(); err != nil {
+			return err
+		}
+	}
+	f.generationPrecondition = generation
+	return nil
+}
+
+// checkGenerationPrecondition returns the latest object if it has the
+// generation set with SetGenerationPrecondition, or nil if that is zero and
+// the object doesn't exist.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) checkGenerationPrecondition(ctx context.Context) (*gcs.Object, error) {
+	o, _, err := f.clobbered(ctx, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var generation int64
+	if o != nil {
+		generation = o.Generation
+	}
+	if generation != *f.generationPrecondition {
+		return nil, fmt.Errorf("%w: the generation of %q is %d, not the expected %d", syscall.ESTALE, f.Name().GcsObjectName(), generation, *f.generationPrecondition)
+	}
+	return o, nil
+}
+
+// SetCreationMode sets the permission bits requested by the process creating
+// the file, with honor-umask. They're reported in place of the file-mode, and
+// persisted in the metadata of the object of the file by the next Sync.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) SetCreationMode(mode os.FileMode) {
+	mode &= os.ModePerm
+	f.creationMode = &mode
+}
+
+// persistCreationMode writes the creation mode to the metadata of the object
+// of the file, once it exists. A failure is only logged, the mode is then
+// reported until the inode is destroyed and written again by the next Sync.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) persistCreationMode(ctx context.Context) {
+	if f.creationMode == nil || f.IsLocal() {
+		return
+	}
+
+	formatted := fmt.Sprintf("%o", *f.creationMode)
+	srcGen := f.SourceGeneration()
+	req := &gcs.UpdateObjectRequest{
+		Name:                       f.src.Name,
+		Generation:                 srcGen.Object,
+		MetaGenerationPrecondition: &srcGen.Metadata,
+		Metadata: map[string]*string{
+			FileModeMetadataKey: &formatted,
+		},
+	}
+	o, err := f.bucket.UpdateObject(ctx, req)
+	if err != nil {
+		logger.Warnf("Persisting the mode %s of %q: %v", formatted, f.src.Name, err)
+		return
+	}
+	f.src = *storageutil.ConvertObjToMinObject(o)
+	f.creationMode = nil
+}
+
+// resolveClobbering applies clobber-action to the supplied error of Sync. It
+// returns nil once the local contents overwrite the generation clobbering the
+// file, and the error otherwise, a GenerationMismatchError with
+// strict-overwrites.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) resolveClobbering(ctx context.Context, err error) error {
+	var clobberedErr *gcsfuse_errors.FileClobberedError
+	if !errors.As(err, &clobberedErr) || f.config == nil {
+		return err
+	}
+
+	switch f.config.FileSystem.ClobberAction {
+	case cfg.ClobberActionOverwrite:
+		latestGcsObj, _, statErr := f.clobbered(ctx, true, true)
+		if statErr != nil {
+			return fmt.Errorf("%w; overwriting it: %v", err, statErr)
+		}
+		newObj, createErr := f.createFromContent(ctx, f.Name().GcsObjectName(), latestGcsObj)
+		if createErr != nil {
+			return fmt.Errorf("%w; overwriting it: %v", err, createErr)
+		}
+		logger.Warnf("%q was modified or deleted by another actor, overwrote it with the local contents: %v", f.Name().GcsObjectName(), err)
+		f.updateInodeStateAfterSync(storageutil.ConvertObjToMinObject(newObj))
+		return nil
+
+	case cfg.ClobberActionConflictCopy:
+		conflictName := fmt.Sprintf("%s.conflict-%s", f.Name().GcsObjectName(), f.mtimeClock.Now().UTC().Format("20060102T150405Z"))
+		if _, createErr := f.createFromContent(ctx, conflictName, nil); createErr != nil {
+			return fmt.Errorf("%w; writing the conflict copy %q: %v", err, conflictName, createErr)
+		}
+		logger.Warnf("%q was modified or deleted by another actor, wrote the local contents to %q: %v", f.Name().GcsObjectName(), conflictName, err)
+		copiedErr := f.strictClobberedError(&gcsfuse_errors.FileClobberedError{
+			Err: fmt.Errorf("%w, the local contents were written to %q", clobberedErr.Err, conflictName),
+		})
+
+		// The local contents are saved, start over from the other actor's
+		// generation so that the next flushes don't copy them again.
+		latestGcsObj, _, statErr := f.clobbered(ctx, true, false)
+		if statErr == nil && latestGcsObj != nil {
+			f.updateInodeStateAfterSync(storageutil.ConvertObjToMinObject(latestGcsObj))
+		} else if !f.IsLocal() {
+			f.content.Destroy()
+			f.content = nil
+		}
+		return copiedErr
+	}
+	return f.strictClobberedError(err)
+}
+
+// strictClobberedError turns the supplied error of a clobbered file into a
+// GenerationMismatchError with strict-overwrites, and returns it unchanged
+// otherwise.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) strictClobberedError(err error) error {
+	if f.config == nil || !f.config.FileSystem.StrictOverwrites {
+		return err
+	}
+	logger.Errorf("Not overwriting %q, modified or deleted by another actor since generation %d was opened: %v", f.Name().GcsObjectName(), f.src.Generation, err)
+	return &gcsfuse_errors.GenerationMismatchError{
+		Name:       f.Name().GcsObjectName(),
+		Generation: f.src.Generation,
+		Err:        err,
+	}
+}
+
+// createFromContent writes out the whole content of the inode to the object
+// with the supplied name, with a precondition on the supplied generation of the
+// object, or on its absence if nil.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) createFromContent(ctx context.Context, objectName string, srcObject *gcs.Object) (*gcs.Object, error) {
+	sr, err := f.content.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	// Content.Stat() seeks the current position to end of file.
+	if _, err = f.content.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error in seeking: %w", err)
+	}
+	req := gcs.NewCreateObjectRequest(srcObject, objectName, sr.Mtime, f.config.GcsRetries.ChunkTransferTimeoutSecs)
+	req.Contents = f.content
+	o, err := f.bucket.CreateObject(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("CreateObject: %w", err)
+	}
+	return o, nil
+}
+
 func (f *FileInode) updateInodeStateAfterSync(minObj *gcs.MinObject) {
 	if minObj != nil && !f.localFileCache {
 		f.src = *minObj
@@ -785,6 +1194,7 @@ func (f *FileInode) updateInodeStateAfterSync(minObj *gcs.MinObject) {
 		}
 		if f.bwh != nil {
 			f.bwh = nil
+			f.appendTo = nil
 		}
 	}
 
@@ -797,8 +1207,12 @@ func (f *FileInode) updateInodeStateAfterSync(minObj *gcs.MinObject) {
 func (f *FileInode) Truncate(
 	ctx context.Context,
 	size int64) (err error) {
+	if err = f.acquireLease(ctx); err != nil {
+		return
+	}
+
 	// For empty GCS files also, we will trigger bufferedWrites flow.
-	if f.src.Size == 0 && f.config.Write.ExperimentalEnableStreamingWrites {
+	if f.src.Size == 0 && f.streamingWritesEnabled() {
 		err = f.ensureBufferedWriteHandler(ctx)
 		if err != nil {
 			return
@@ -806,9 +1220,27 @@ func (f *FileInode) Truncate(
 	}
 
 	if f.bwh != nil {
-		return f.bwh.Truncate(size)
+		bwhSize := size - f.appendBase()
+		if err = f.bwh.Truncate(bwhSize); err == nil {
+			f.upload.grow(bwhSize)
+		}
+		return
 	}
 
+	// Truncating a clean file to zero (e.g. open with O_TRUNC before rewriting
+	// the file) doesn't need the current contents. Stage an empty temp file
+	// instead of downloading the object; the object in GCS is left untouched
+	// until the new contents are synced, at which point the generation
+	// precondition of the source object is applied as usual.
+	if f.isTruncateToZeroOfCleanFile(size) {
+		if err = f.createEmptyTempFile(); err == nil {
+			f.upload.dirty(0)
+		}
+		return
+	}
+
+	clean := f.content == nil
+
 	// Make sure f.content != nil.
 	err = f.ensureContent(ctx)
 	if err != nil {
@@ -818,6 +1250,11 @@ func (f *FileInode) Truncate(
 
 	// Call through.
 	err = f.content.Truncate(size)
+	if err == nil && clean {
+		f.upload.dirty(size)
+	} else if err == nil {
+		f.upload.resize(size)
+	}
 
 	return
 }
@@ -831,9 +1268,15 @@ func (f *FileInode) CacheEnsureContent(ctx context.Context) (err error) {
 	return
 }
 
+// streamingWritesEnabled returns true if the new writes of the file may be
+// streamed to GCS, unless their kill switch has been turned off.
+func (f *FileInode) streamingWritesEnabled() bool {
+	return f.config.Write.ExperimentalEnableStreamingWrites && streamingWritesFeature.Enabled()
+}
+
 func (f *FileInode) CreateBufferedOrTempWriter(ctx context.Context) (err error) {
 	// Skip creating empty file when streaming writes are enabled
-	if f.local && f.config.Write.ExperimentalEnableStreamingWrites {
+	if f.local && f.streamingWritesEnabled() {
 		err = f.ensureBufferedWriteHandler(ctx)
 		if err != nil {
 			return
@@ -843,10 +1286,30 @@ func (f *FileInode) CreateBufferedOrTempWriter(ctx context.Context) (err error)
 
 	// Creating a file with no contents. The contents will be updated with
 	// writeFile operations.
-	f.content, err = f.contentCache.NewTempFile(io.NopCloser(strings.NewReader("")))
+	return f.createEmptyTempFile()
+}
+
+// isTruncateToZeroOfCleanFile reports whether truncating to the given size
+// discards all the contents of a GCS backed file that has no local changes.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) isTruncateToZeroOfCleanFile(size int64) bool {
+	return size == 0 && !f.local && !f.localFileCache && f.content == nil && f.bwh == nil
+}
+
+// createEmptyTempFile sets f.content to an empty temp file with mtime set to
+// the current time.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) createEmptyTempFile() error {
+	tf, err := f.contentCache.NewTempFile(io.NopCloser(strings.NewReader("")))
+	if err != nil {
+		return fmt.Errorf("NewTempFile: %w", err)
+	}
 	// Setting the initial mtime to creation time.
-	f.content.SetMtime(f.mtimeClock.Now())
-	return
+	tf.SetMtime(f.mtimeClock.Now())
+	f.content = tf
+	return nil
 }
 
 func (f *FileInode) ensureBufferedWriteHandler(ctx context.Context) error {
@@ -864,21 +1327,85 @@ func (f *FileInode) ensureBufferedWriteHandler(ctx context.Context) error {
 		}
 	}
 
-	if f.bwh == nil {
-		f.bwh, err = bufferedwrites.NewBWHandler(&bufferedwrites.CreateBWHandlerRequest{
-			Object:                   latestGcsObj,
-			ObjectName:               f.name.GcsObjectName(),
-			Bucket:                   f.bucket,
-			BlockSize:                f.config.Write.BlockSizeMb,
-			MaxBlocksPerFile:         f.config.Write.MaxBlocksPerFile,
-			GlobalMaxBlocksSem:       semaphore.NewWeighted(f.config.Write.GlobalMaxBlocks),
-			ChunkTransferTimeoutSecs: f.config.GcsRetries.ChunkTransferTimeoutSecs,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create bufferedWriteHandler: %w", err)
-		}
-		f.bwh.SetMtime(f.mtimeClock.Now())
+	return f.createBufferedWriteHandler(latestGcsObj, f.name.GcsObjectName())
+}
+
+// isStreamingAppend reports whether the write at offset appends to the object
+// of a clean file, to be streamed with write.streaming-appends.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) isStreamingAppend(offset int64) bool {
+	return f.config.Write.StreamingAppends && f.streamingWritesEnabled() &&
+		f.bwh == nil && f.content == nil && !f.local && f.generationPrecondition == nil &&
+		f.src.Size > 0 && offset == int64(f.src.Size)
+}
+
+// ensureStreamingAppend starts streaming the writes appended to the object of
+// the file to a temporary object, if the object is still the generation of the
+// file. Otherwise they're staged in a temp file, which resolves the clobbering
+// on sync as usual.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) ensureStreamingAppend(ctx context.Context) error {
+	latestGcsObj, err := f.fetchLatestGcsObject(ctx)
+	var clobberedErr *gcsfuse_errors.FileClobberedError
+	if errors.As(err, &clobberedErr) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
+	tmpName, err := f.bucket.TempObjectName()
+	if err != nil {
+		return fmt.Errorf("TempObjectName: %w", err)
+	}
+	if err = f.createBufferedWriteHandler(nil, tmpName); err != nil {
+		return err
+	}
+	f.appendTo = latestGcsObj
+	return nil
+}
+
+// appendBase returns the size of the object the writes streamed by bwh are
+// appended to, at which the offsets of bwh start.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) appendBase() int64 {
+	if f.appendTo == nil {
+		return 0
+	}
+	return int64(f.appendTo.Size)
+}
+
+// createBufferedWriteHandler sets bwh to stream the writes to the object with
+// the supplied name, with a precondition on the generation of obj, or on its
+// absence if nil.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) createBufferedWriteHandler(obj *gcs.Object, objectName string) error {
+	var spill *block.Spill
+	if f.config.Write.SpillMemoryThresholdPercent > 0 {
+		spill = f.contentCache.NewSpill(f.config.Write.SpillMemoryThresholdPercent)
+	}
+	bwh, err := bufferedwrites.NewBWHandler(&bufferedwrites.CreateBWHandlerRequest{
+		Object:                   obj,
+		ObjectName:               objectName,
+		Bucket:                   f.bucket,
+		BlockSize:                f.config.Write.BlockSizeMb,
+		MaxBlocksPerFile:         f.config.Write.MaxBlocksPerFile,
+		DirtyBudget:              f.contentCache.DirtyBudget(),
+		ChunkTransferTimeoutSecs: f.config.GcsRetries.ChunkTransferTimeoutSecs,
+		CoalesceSize:             f.config.Write.CoalesceSizeKb * 1024,
+		MetricHandle:             f.metricHandle,
+		Spill:                    spill,
+		Progress:                 &f.upload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create bufferedWriteHandler: %w", err)
+	}
+	f.bwh = bwh
+	f.upload.dirty(0)
+	f.bwh.SetMtime(f.mtimeClock.Now())
 	return nil
 }
@@ -16,10 +16,12 @@ package inode
 
 import (
 	"context"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
@@ -37,6 +39,7 @@ import (
 
 const localFile = "local"
 const emptyGCSFile = "emptyGCS"
+const gcsFileWithContents = "gcsWithContents"
 
 type FileStreamingWritesTest struct {
 	suite.Suite
@@ -67,8 +70,8 @@ func (t *FileStreamingWritesTest) TearDownTest() {
 }
 
 func (t *FileStreamingWritesTest) createInode(fileName string, fileType string) {
-	if fileType != emptyGCSFile && fileType != localFile {
-		t.T().Errorf("fileType should be either local or empty")
+	if fileType != emptyGCSFile && fileType != localFile && fileType != gcsFileWithContents {
+		t.T().Errorf("fileType should be either local, empty or with contents")
 	}
 
 	name := NewFileName(
@@ -98,6 +101,17 @@ func (t *FileStreamingWritesTest) createInode(fileName string, fileType string)
 		assert.Nil(t.T(), err)
 	}
 
+	if fileType == gcsFileWithContents {
+		object, err := storageutil.CreateObject(
+			t.ctx,
+			t.bucket,
+			fileName,
+			[]byte("taco"))
+		t.backingObj = storageutil.ConvertObjToMinObject(object)
+
+		assert.Nil(t.T(), err)
+	}
+
 	t.in = NewFileInode(
 		fileInodeID,
 		name,
@@ -109,10 +123,11 @@ func (t *FileStreamingWritesTest) createInode(fileName string, fileType string)
 		},
 		&syncerBucket,
 		false, // localFileCache
-		contentcache.New("", &t.clock),
+		contentcache.New("", &t.clock, nil, nil),
 		&t.clock,
 		isLocal,
-		&cfg.Config{})
+		&cfg.Config{},
+		common.NewNoopMetrics())
 
 	// Set buffered write config for created inode.
 	t.in.config = &cfg.Config{Write: cfg.WriteConfig{
@@ -123,8 +138,10 @@ func (t *FileStreamingWritesTest) createInode(fileName string, fileType string)
 	}}
 
 	// Create write handler for the local inode created above.
-	err := t.in.CreateBufferedOrTempWriter(t.ctx)
-	assert.Nil(t.T(), err)
+	if fileType != gcsFileWithContents {
+		err := t.in.CreateBufferedOrTempWriter(t.ctx)
+		assert.Nil(t.T(), err)
+	}
 
 	t.in.Lock()
 }
@@ -189,6 +206,54 @@ func (t *FileStreamingWritesTest) TestOutOfOrderWritesToLocalFileFallBackToTempF
 	}
 }
 
+func (t *FileStreamingWritesTest) TestFlushPersistsMtime() {
+	err := t.in.Write(t.ctx, []byte("taco"), 0)
+	require.Nil(t.T(), err)
+	require.NotNil(t.T(), t.in.bwh)
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+	err = t.in.SetMtime(t.ctx, mtime)
+	require.Nil(t.T(), err)
+
+	// An out-of-order write finalizes the object.
+	err = t.in.Write(t.ctx, []byte("hello"), 0)
+	require.Nil(t.T(), err)
+
+	require.Nil(t.T(), t.in.bwh)
+	o, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), mtime.Format(time.RFC3339Nano), o.Metadata[FileMtimeMetadataKey])
+}
+
+func (t *FileStreamingWritesTest) TestGenerationPreconditionFallsBackToTempFile() {
+	require.NotNil(t.T(), t.in.bwh)
+	var generation int64
+
+	err := t.in.SetGenerationPrecondition(&generation)
+
+	require.Nil(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.NotNil(t.T(), t.in.content)
+	err = t.in.Write(t.ctx, []byte("taco"), 0)
+	require.Nil(t.T(), err)
+	err = t.in.Sync(t.ctx)
+	require.Nil(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *FileStreamingWritesTest) TestGenerationPreconditionDuringUpload() {
+	err := t.in.Write(t.ctx, []byte("taco"), 0)
+	require.Nil(t.T(), err)
+	var generation int64
+
+	err = t.in.SetGenerationPrecondition(&generation)
+
+	assert.ErrorIs(t.T(), err, syscall.EBUSY)
+	assert.NotNil(t.T(), t.in.bwh)
+	assert.Nil(t.T(), t.in.GenerationPrecondition())
+}
+
 func (t *FileStreamingWritesTest) TestOutOfOrderWritesOnClobberedFileThrowsError() {
 	err := t.in.Write(t.ctx, []byte("hi"), 0)
 	require.Nil(t.T(), err)
@@ -249,3 +314,100 @@ func (t *FileStreamingWritesTest) TestUnlinkEmptySyncedFile() {
 
 	assert.True(t.T(), t.in.unlinked)
 }
+
+func (t *FileStreamingWritesTest) TestSyncFinalizesTheObject() {
+	err := t.in.Write(t.ctx, []byte("tacos"), 0)
+	require.Nil(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	require.Nil(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.False(t.T(), t.in.IsLocal())
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), "tacos", string(contents))
+	assert.Equal(t.T(), uint64(5), t.in.Source().Size)
+}
+
+func (t *FileStreamingWritesTest) TestSyncBufferedWritesDoesNotFinalizeTheObject() {
+	err := t.in.Write(t.ctx, []byte("tacos"), 0)
+	require.Nil(t.T(), err)
+
+	synced, err := t.in.SyncBufferedWrites()
+
+	require.Nil(t.T(), err)
+	assert.True(t.T(), synced)
+	assert.NotNil(t.T(), t.in.bwh)
+	operations.ValidateObjectNotFoundErr(t.ctx, t.T(), t.bucket, t.in.Name().GcsObjectName())
+}
+
+func (t *FileStreamingWritesTest) TestSyncBufferedWritesWithoutStreamingWrites() {
+	t.in.bwh = nil
+
+	synced, err := t.in.SyncBufferedWrites()
+
+	require.Nil(t.T(), err)
+	assert.False(t.T(), synced)
+}
+
+func (t *FileStreamingWritesTest) TestStreamingAppendsComposeOntoTheObject() {
+	t.createInode(fileName, gcsFileWithContents)
+	t.in.config.Write.StreamingAppends = true
+
+	// Append twice, closing the file in between.
+	for _, data := range []string{"s", "burrito"} {
+		err := t.in.Write(t.ctx, []byte(data), int64(t.in.Source().Size))
+		require.Nil(t.T(), err)
+		require.NotNil(t.T(), t.in.bwh)
+		assert.Nil(t.T(), t.in.content)
+		attrs, err := t.in.Attributes(t.ctx)
+		require.Nil(t.T(), err)
+		assert.Equal(t.T(), t.in.Source().Size+uint64(len(data)), attrs.Size)
+
+		err = t.in.Sync(t.ctx)
+		require.Nil(t.T(), err)
+	}
+
+	assert.Nil(t.T(), t.in.bwh)
+	assert.Nil(t.T(), t.in.appendTo)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, fileName)
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), "tacosburrito", string(contents))
+	assert.Equal(t.T(), uint64(12), t.in.Source().Size)
+	// The temporary objects are deleted.
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{})
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), 1, len(listing.MinObjects))
+}
+
+func (t *FileStreamingWritesTest) TestStreamingAppendsToClobberedObjectThrowsError() {
+	t.createInode(fileName, gcsFileWithContents)
+	t.in.config.Write.StreamingAppends = true
+	err := t.in.Write(t.ctx, []byte("s"), 4)
+	require.Nil(t.T(), err)
+	require.NotNil(t.T(), t.in.appendTo)
+	// Another actor takes the object over.
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, fileName, []byte("burrito"))
+	require.Nil(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	var clobberedErr *gcsfuse_errors.FileClobberedError
+	assert.ErrorAs(t.T(), err, &clobberedErr)
+	assert.Nil(t.T(), t.in.bwh)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, fileName)
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *FileStreamingWritesTest) TestWritesNotAppendingFallBackToTempFile() {
+	t.createInode(fileName, gcsFileWithContents)
+	t.in.config.Write.StreamingAppends = true
+
+	err := t.in.Write(t.ctx, []byte("p"), 2)
+
+	require.Nil(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.NotNil(t.T(), t.in.content)
+}
@@ -22,10 +22,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
@@ -141,10 +143,11 @@ func (t *FileTest) createInodeWithLocalParam(fileName string, local bool) {
 		},
 		&syncerBucket,
 		false, // localFileCache
-		contentcache.New("", &t.clock),
+		contentcache.New("", &t.clock, nil, nil),
 		&t.clock,
 		local,
-		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}})
+		&cfg.Config{Write: cfg.WriteConfig{GlobalMaxBlocks: math.MaxInt64}},
+		common.NewNoopMetrics())
 
 	t.in.Lock()
 }
@@ -407,6 +410,331 @@ func (t *FileTest) TestWriteThenSync() {
 	assert.Equal(t.T(), attrs.Mtime, writeTime.UTC())
 }
 
+func (t *FileTest) TestUploadStatusOfWriteThenSync() {
+	assert.False(t.T(), t.in.UploadStatus().Pending())
+
+	err := t.in.Write(t.ctx, []byte("burrito"), 2)
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), UploadStatus{Buffered: int64(len("taburrito"))}, t.in.UploadStatus())
+	err = t.in.Sync(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), UploadStatus{Committed: int64(len("taburrito"))}, t.in.UploadStatus())
+	assert.False(t.T(), t.in.UploadStatus().Pending())
+}
+
+func (t *FileTest) TestUploadStatusOfTruncate() {
+	err := t.in.Truncate(t.ctx, 2)
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), UploadStatus{Buffered: 2}, t.in.UploadStatus())
+}
+
+func (t *FileTest) TestKeepPageCacheUntilSync() {
+	// No pages can be cached before the first open.
+	assert.False(t.T(), t.in.KeepPageCache())
+	assert.True(t.T(), t.in.KeepPageCache())
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+	assert.True(t.T(), t.in.KeepPageCache())
+
+	err = t.in.Sync(t.ctx)
+	require.NoError(t.T(), err)
+
+	assert.False(t.T(), t.in.KeepPageCache())
+	assert.True(t.T(), t.in.KeepPageCache())
+}
+
+func (t *FileTest) TestSyncWithMatchingGenerationPrecondition() {
+	generation := t.backingObj.Generation
+	err := t.in.SetGenerationPrecondition(&generation)
+	require.NoError(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	assert.Less(t.T(), generation, t.in.SourceGeneration().Object)
+	assert.Nil(t.T(), t.in.GenerationPrecondition())
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "paco", string(contents))
+}
+
+func (t *FileTest) TestSyncWithMismatchingGenerationPrecondition() {
+	generation := t.backingObj.Generation + 1
+	err := t.in.SetGenerationPrecondition(&generation)
+	require.NoError(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	assert.ErrorIs(t.T(), err, syscall.ESTALE)
+	assert.Equal(t.T(), t.backingObj.Generation, t.in.SourceGeneration().Object)
+	assert.Equal(t.T(), &generation, t.in.GenerationPrecondition())
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *FileTest) TestSyncWithGenerationPreconditionOfAbsentObject() {
+	var generation int64
+	err := t.in.SetGenerationPrecondition(&generation)
+	require.NoError(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	assert.ErrorIs(t.T(), err, syscall.ESTALE)
+}
+
+func (t *FileTest) TestSyncWithGenerationPreconditionOfClobberingGeneration() {
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+	// Another actor writes a new generation, which the precondition expects.
+	newObj, err := storageutil.CreateObject(
+		t.ctx,
+		t.bucket,
+		t.in.Name().GcsObjectName(),
+		[]byte("burrito"))
+	require.NoError(t.T(), err)
+	err = t.in.SetGenerationPrecondition(&newObj.Generation)
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	assert.Less(t.T(), newObj.Generation, t.in.SourceGeneration().Object)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "paco", string(contents))
+}
+
+func (t *FileTest) TestCreationModeIsPersistedBySync() {
+	t.createInodeWithLocalParam("test", true)
+	t.in.config.FileSystem.HonorUmask = true
+	err := t.in.CreateBufferedOrTempWriter(t.ctx)
+	require.NoError(t.T(), err)
+	t.in.SetCreationMode(0600)
+	attrs, err := t.in.Attributes(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), os.FileMode(0600), attrs.Mode)
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "600", m.Metadata[FileModeMetadataKey])
+	assert.Equal(t.T(), m.MetaGeneration, t.in.SourceGeneration().Metadata)
+	attrs, err = t.in.Attributes(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), os.FileMode(0600), attrs.Mode)
+}
+
+func (t *FileTest) TestModeFromMetadataWithHonorUmask() {
+	mode := "640"
+	o, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:     t.backingObj.Name,
+		Metadata: map[string]*string{FileModeMetadataKey: &mode},
+	})
+	require.NoError(t.T(), err)
+	t.backingObj = storageutil.ConvertObjToMinObject(o)
+	t.createInode()
+	attrs, err := t.in.Attributes(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), fileMode, attrs.Mode)
+
+	t.in.config.FileSystem.HonorUmask = true
+	attrs, err = t.in.Attributes(t.ctx)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), os.FileMode(0640), attrs.Mode)
+}
+
+func (t *FileTest) TestWriteLeasesTheObjectUntilSync() {
+	t.in.config.Write.LeaseTtl = time.Minute
+
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	holder, expiry, ok := parseLease(m.Metadata[LeaseMetadataKey])
+	require.True(t.T(), ok)
+	assert.Equal(t.T(), leaseHolder, holder)
+	assert.True(t.T(), expiry.Equal(t.clock.Now().Add(time.Minute)))
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	m, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.NotContains(t.T(), m.Metadata, LeaseMetadataKey)
+	assert.Equal(t.T(), m.MetaGeneration, t.in.SourceGeneration().Metadata)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "paco", string(contents))
+}
+
+func (t *FileTest) TestWriteToObjectLeasedByAnotherHolder() {
+	t.in.config.Write.LeaseTtl = time.Minute
+	lease := formatLease("other-host:1", t.clock.Now().Add(time.Second))
+	_, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:     t.backingObj.Name,
+		Metadata: map[string]*string{LeaseMetadataKey: &lease},
+	})
+	require.NoError(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+
+	assert.ErrorIs(t.T(), err, syscall.EBUSY)
+	assert.Nil(t.T(), t.in.content)
+
+	// The lease expires.
+	t.clock.AdvanceTime(2 * time.Second)
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	holder, _, ok := parseLease(m.Metadata[LeaseMetadataKey])
+	require.True(t.T(), ok)
+	assert.Equal(t.T(), leaseHolder, holder)
+}
+
+func (t *FileTest) TestWritesRenewTheLeasePastItsTtl() {
+	t.in.config.Write.LeaseTtl = time.Minute
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	// The lease isn't renewed before half of its ttl, and is afterwards.
+	t.clock.AdvanceTime(20 * time.Second)
+	err = t.in.Write(t.ctx, []byte("a"), 1)
+	require.NoError(t.T(), err)
+	t.clock.AdvanceTime(20 * time.Second)
+	err = t.in.Write(t.ctx, []byte("c"), 2)
+	require.NoError(t.T(), err)
+	t.clock.AdvanceTime(40 * time.Second)
+
+	// The original lease would have expired by now.
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	holder, expiry, ok := parseLease(m.Metadata[LeaseMetadataKey])
+	require.True(t.T(), ok)
+	assert.Equal(t.T(), leaseHolder, holder)
+	assert.True(t.T(), expiry.Equal(t.clock.Now().Add(-40*time.Second).Add(time.Minute)))
+	assert.Equal(t.T(), t.backingObj.MetaGeneration+2, m.MetaGeneration)
+	err = t.in.Sync(t.ctx)
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "paco", string(contents))
+}
+
+func (t *FileTest) TestFlushFailsIfTheLeaseWasLost() {
+	t.in.config.Write.LeaseTtl = time.Minute
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	// Another holder leases the object once the lease expires.
+	t.clock.AdvanceTime(2 * time.Minute)
+	lease := formatLease("other-host:1", t.clock.Now().Add(time.Minute))
+	_, err = t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:     t.backingObj.Name,
+		Metadata: map[string]*string{LeaseMetadataKey: &lease},
+	})
+	require.NoError(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("a"), 1)
+	assert.ErrorIs(t.T(), err, syscall.EBUSY)
+	err = t.in.Sync(t.ctx)
+
+	assert.ErrorIs(t.T(), err, syscall.EBUSY)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *FileTest) TestFlushFailsIfTheLeaseIsLostDuringTheUpload() {
+	wrapped := t.bucket
+	t.bucket = fake.NewFaultyBucket(wrapped, fake.NewFaultSchedule(fake.Fault{
+		Method: "CreateObject",
+		Call:   1,
+		// The upload outlasts the lease, which another holder then takes.
+		Before: func() {
+			t.clock.AdvanceTime(2 * time.Minute)
+			lease := formatLease("other-host:1", t.clock.Now().Add(time.Minute))
+			_, err := wrapped.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+				Name:     fileName,
+				Metadata: map[string]*string{LeaseMetadataKey: &lease},
+			})
+			require.NoError(t.T(), err)
+		},
+	}))
+	t.in.Unlock()
+	t.createInode()
+	t.in.config.Write.LeaseTtl = time.Minute
+	// The contents of the other holder aren't overwritten either way.
+	t.in.config.FileSystem.ClobberAction = cfg.ClobberActionOverwrite
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	assert.ErrorIs(t.T(), err, syscall.EBUSY)
+	contents, err := storageutil.ReadObject(t.ctx, wrapped, fileName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+	m, _, err := wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: fileName})
+	require.NoError(t.T(), err)
+	holder, _, ok := parseLease(m.Metadata[LeaseMetadataKey])
+	require.True(t.T(), ok)
+	assert.Equal(t.T(), "other-host:1", holder)
+}
+
+func (t *FileTest) TestWriteWithoutLease() {
+	err := t.in.Write(t.ctx, []byte("p"), 0)
+
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.NotContains(t.T(), m.Metadata, LeaseMetadataKey)
+	assert.Equal(t.T(), t.backingObj.MetaGeneration, t.in.SourceGeneration().Metadata)
+}
+
+func (t *FileTest) TestSyncAfterObjectIsPublished() {
+	wrapped := t.bucket
+	t.bucket = gcsx.NewAtomicCommitBucket([]string{"foo/"}, "_SUCCESS", ".staging/", wrapped)
+	var preCond int64
+	o, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   fileName,
+		GenerationPrecondition: &preCond,
+		Contents:               strings.NewReader("taco"),
+	})
+	require.NoError(t.T(), err)
+	t.backingObj = storageutil.ConvertObjToMinObject(o)
+	t.in.Unlock()
+	t.createInode()
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "foo/_SUCCESS", []byte{})
+	require.NoError(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("p"), 0)
+	require.NoError(t.T(), err)
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, wrapped, fileName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "paco", string(contents))
+	m, _, err := wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: fileName})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), m.Generation, t.in.SourceGeneration().Object)
+}
+
 func (t *FileTest) TestWriteToLocalFileThenSync() {
 	var attrs fuseops.InodeAttributes
 	var err error
@@ -622,6 +950,69 @@ func (t *FileTest) TestTruncateUpwardThenSync() {
 	assert.Equal(t.T(), attrs.Mtime, truncateTime.UTC())
 }
 
+func (t *FileTest) TestTruncateToZeroThenWriteThenSync() {
+	// Truncate to zero and write new contents.
+	err := t.in.Truncate(t.ctx, 0)
+	require.NoError(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("burrito"), 0)
+	require.NoError(t.T(), err)
+
+	// Until the inode is synced, the object in the bucket should still hold
+	// the old contents.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), t.initialContents, string(contents))
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	// The generation should have advanced and the new contents swapped in.
+	assert.Less(t.T(), t.backingObj.Generation, t.in.SourceGeneration().Object)
+	contents, err = storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *FileTest) TestTruncateToZeroDoesNotReadObject() {
+	// Delete the backing object so that any attempt to read it fails.
+	err := t.bucket.DeleteObject(
+		t.ctx,
+		&gcs.DeleteObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+
+	err = t.in.Truncate(t.ctx, 0)
+
+	require.NoError(t.T(), err)
+	attrs, err := t.in.Attributes(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), uint64(0), attrs.Size)
+}
+
+func (t *FileTest) TestTruncateToZeroThenSync_Clobbered() {
+	err := t.in.Truncate(t.ctx, 0)
+	require.NoError(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("taco"), 0)
+	require.NoError(t.T(), err)
+	// Clobber the backing object.
+	newObj, err := storageutil.CreateObject(
+		t.ctx,
+		t.bucket,
+		t.in.Name().GcsObjectName(),
+		[]byte("burrito"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	var fcErr *gcsfuse_errors.FileClobberedError
+	assert.True(t.T(), errors.As(err, &fcErr), "expected FileClobberedError but got %v", err)
+	assert.Equal(t.T(), t.backingObj.Generation, t.in.SourceGeneration().Object)
+	// The concurrent modification should not have been overwritten.
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), newObj.Generation, m.Generation)
+	assert.Equal(t.T(), newObj.Size, m.Size)
+}
+
 func (t *FileTest) TestTruncateUpwardForLocalFileShouldUpdateLocalFileAttributes() {
 	var err error
 	var attrs fuseops.InodeAttributes
@@ -881,6 +1272,91 @@ func (t *FileTest) TestSync_Clobbered() {
 	assert.Equal(t.T(), newObj.Size, m.Size)
 }
 
+func (t *FileTest) TestSync_ClobberedWithOverwrite() {
+	t.in.config.FileSystem.ClobberAction = cfg.ClobberActionOverwrite
+	err := t.in.Truncate(t.ctx, 2)
+	require.NoError(t.T(), err)
+	// Clobber the backing object.
+	newObj, err := storageutil.CreateObject(t.ctx, t.bucket, t.in.Name().GcsObjectName(), []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), m.Generation, newObj.Generation)
+	assert.Equal(t.T(), uint64(2), m.Size)
+	assert.Equal(t.T(), m.Generation, t.in.SourceGeneration().Object)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, m.Name)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "ta", string(contents))
+}
+
+func (t *FileTest) TestSync_ClobberedWithConflictCopy() {
+	t.in.config.FileSystem.ClobberAction = cfg.ClobberActionConflictCopy
+	err := t.in.Truncate(t.ctx, 2)
+	require.NoError(t.T(), err)
+	// Clobber the backing object.
+	newObj, err := storageutil.CreateObject(t.ctx, t.bucket, t.in.Name().GcsObjectName(), []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	var fcErr *gcsfuse_errors.FileClobberedError
+	assert.True(t.T(), errors.As(err, &fcErr), "expected FileClobberedError but got %v", err)
+	conflictName := t.in.Name().GcsObjectName() + ".conflict-" + t.clock.Now().UTC().Format("20060102T150405Z")
+	assert.ErrorContains(t.T(), err, conflictName)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, conflictName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "ta", string(contents))
+	// The other actor's generation is kept, and the inode starts over from it.
+	contents, err = storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	assert.Equal(t.T(), newObj.Generation, t.in.SourceGeneration().Object)
+	assert.NoError(t.T(), t.in.Sync(t.ctx))
+}
+
+func (t *FileTest) TestSync_ClobberedWithStrictOverwrites() {
+	t.in.config.FileSystem.StrictOverwrites = true
+	err := t.in.Truncate(t.ctx, 2)
+	require.NoError(t.T(), err)
+	// Clobber the backing object.
+	newObj, err := storageutil.CreateObject(t.ctx, t.bucket, t.in.Name().GcsObjectName(), []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	var mismatchErr *gcsfuse_errors.GenerationMismatchError
+	require.True(t.T(), errors.As(err, &mismatchErr), "expected GenerationMismatchError but got %v", err)
+	assert.Equal(t.T(), t.backingObj.Generation, mismatchErr.Generation)
+	assert.Equal(t.T(), t.backingObj.Generation, t.in.SourceGeneration().Object)
+	// The other actor's generation is kept.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), newObj.Generation, m.Generation)
+}
+
+func (t *FileTest) TestSync_DeletedWithStrictOverwrites() {
+	t.in.config.FileSystem.StrictOverwrites = true
+	err := t.in.Truncate(t.ctx, 2)
+	require.NoError(t.T(), err)
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+
+	err = t.in.Sync(t.ctx)
+
+	var mismatchErr *gcsfuse_errors.GenerationMismatchError
+	assert.True(t.T(), errors.As(err, &mismatchErr), "expected GenerationMismatchError but got %v", err)
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: t.in.Name().GcsObjectName()})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
 func (t *FileTest) TestOpenReader_ThrowsFileClobberedError() {
 	// Modify the file locally.
 	err := t.in.Truncate(t.ctx, 2)
@@ -84,6 +84,7 @@ func (t *HNSDirTest) resetDirInodeWithTypeCacheConfigs(implicitDirs, enableNonex
 		&t.fixedTime,
 		typeCacheMaxSizeMB,
 		true,
+		false,
 	)
 
 	d := t.in.(*dirInode)
@@ -113,6 +114,7 @@ func (t *HNSDirTest) createDirInode(dirInodeName string) DirInode {
 		&t.fixedTime,
 		4,
 		false,
+		false,
 	)
 }
 
@@ -192,6 +194,24 @@ func (t *HNSDirTest) TestLookUpChildShouldCheckOnlyForExplicitHNSDirectory() {
 	assert.Equal(t.T(), metadata.ExplicitDirType, t.typeCache.Get(t.fixedTime.Now(), name))
 }
 
+func (t *HNSDirTest) TestLookUpChildShouldCheckForHNSDirectoryWhenTypeIsImplicitDirType() {
+	const name = "qux"
+	dirName := path.Join(dirInodeName, name) + "/"
+	folder := &gcs.Folder{
+		Name: dirName,
+	}
+	t.mockBucket.On("GetFolder", mock.Anything, dirName).Return(folder, nil)
+	t.mockBucket.On("BucketType").Return(gcs.Hierarchical)
+	t.typeCache.Insert(t.fixedTime.Now().Add(time.Minute), name, metadata.ImplicitDirType)
+
+	result, err := t.in.LookUpChild(t.ctx, name)
+
+	t.mockBucket.AssertExpectations(t.T())
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), dirName, result.Folder.Name)
+	assert.Equal(t.T(), metadata.ExplicitDirType, t.typeCache.Get(t.fixedTime.Now(), name))
+}
+
 func (t *HNSDirTest) TestLookUpChildShouldCheckForHNSDirectoryWhenTypeNotPresent() {
 	const name = "unknown_type"
 	dirName := path.Join(dirInodeName, name) + "/"
@@ -376,6 +396,7 @@ func (t *HNSDirTest) TestRenameFileWithNonExistentSourceFile() {
 func (t *HNSDirTest) TestDeleteChildDir_WhenImplicitDirFlagTrueOnNonHNSBucket() {
 	const folderName = "folder"
 	dirName := path.Join(dirInodeName, folderName) + "/"
+	t.mockBucket.On("BucketType").Return(gcs.NonHierarchical)
 	dirIn := t.createDirInode(dirName)
 
 	// Delete dir
@@ -385,6 +406,25 @@ func (t *HNSDirTest) TestDeleteChildDir_WhenImplicitDirFlagTrueOnNonHNSBucket()
 	assert.NoError(t.T(), err)             // Ensure no error occurred
 }
 
+func (t *HNSDirTest) TestDeleteChildDir_WhenImplicitDirFlagTrueOnHNSBucket_DeletesFolder() {
+	const name = "folder"
+	dirName := path.Join(dirInodeName, name) + "/"
+	deleteObjectReq := gcs.DeleteObjectRequest{
+		Name:       dirName,
+		Generation: 0,
+	}
+	t.mockBucket.On("BucketType").Return(gcs.Hierarchical)
+	t.mockBucket.On("DeleteObject", t.ctx, &deleteObjectReq).Return(nil)
+	t.mockBucket.On("DeleteFolder", t.ctx, dirName).Return(nil)
+	dirIn := t.createDirInode(dirName)
+
+	err := t.in.DeleteChildDir(t.ctx, name, true, dirIn)
+
+	t.mockBucket.AssertExpectations(t.T())
+	assert.NoError(t.T(), err)
+	assert.True(t.T(), dirIn.IsUnlinked())
+}
+
 func (t *HNSDirTest) TestDeleteChildDir_WhenImplicitDirFlagFalseAndNonHNSBucket_DeleteObjectGiveSuccess() {
 	const name = "dir"
 	dirName := path.Join(dirInodeName, name) + "/"
@@ -0,0 +1,213 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+// A GCS object metadata key for the write lease of an object, with
+// write.lease-ttl: the expiry of the lease, in the format defined by
+// time.RFC3339Nano, and the id of its holder, separated by a space.
+const LeaseMetadataKey = "gcsfuse_lease"
+
+// leaseHolder identifies the write leases held by this process.
+var leaseHolder = defaultLeaseHolder()
+
+func defaultLeaseHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+func formatLease(holder string, expiry time.Time) string {
+	return expiry.UTC().Format(time.RFC3339Nano) + " " + holder
+}
+
+// parseLease returns the holder and the expiry of the supplied value of
+// LeaseMetadataKey, and false if it isn't a lease.
+func parseLease(value string) (holder string, expiry time.Time, ok bool) {
+	formatted, holder, found := strings.Cut(value, " ")
+	if !found || holder == "" {
+		return "", time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339Nano, formatted)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return holder, expiry, true
+}
+
+// acquireLease leases the object of a clean file to this process for
+// write.lease-ttl, before its contents are dirtied. It fails with EBUSY if
+// another holder's lease of the object hasn't expired, or is taken at the same
+// time. A modified or deleted object isn't leased, it's dealt with as
+// clobbered on sync. The lease of a dirty file is renewed instead.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) acquireLease(ctx context.Context) error {
+	if f.config == nil || f.config.Write.LeaseTtl <= 0 || f.IsLocal() {
+		return nil
+	}
+	if f.content != nil || f.bwh != nil {
+		return f.renewLease(ctx)
+	}
+
+	o, _, err := f.clobbered(ctx, true, false)
+	if err != nil {
+		return err
+	}
+	if o == nil || o.Generation != f.src.Generation {
+		return nil
+	}
+
+	now := f.mtimeClock.Now()
+	if holder, expiry, ok := parseLease(o.Metadata[LeaseMetadataKey]); ok && holder != leaseHolder && now.Before(expiry) {
+		return fmt.Errorf("%w: %q is leased by %s until %s", syscall.EBUSY, o.Name, holder, expiry.Format(time.RFC3339))
+	}
+
+	lease := formatLease(leaseHolder, now.Add(f.config.Write.LeaseTtl))
+	updated, err := f.bucket.UpdateObject(ctx, &gcs.UpdateObjectRequest{
+		Name:                       o.Name,
+		Generation:                 o.Generation,
+		MetaGenerationPrecondition: &o.MetaGeneration,
+		Metadata: map[string]*string{
+			LeaseMetadataKey: &lease,
+		},
+	})
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		return fmt.Errorf("%w: %q was modified while being leased: %v", syscall.EBUSY, o.Name, err)
+	}
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateObject: %w", err)
+	}
+	f.src = *storageutil.ConvertObjToMinObject(updated)
+	return nil
+}
+
+// holdsLease returns true if the object of the file is leased by this
+// process, whether or not the lease expired.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) holdsLease() bool {
+	holder, _, ok := parseLease(f.src.Metadata[LeaseMetadataKey])
+	return ok && holder == leaseHolder
+}
+
+// renewLease extends the lease held by this process of the object of a dirty
+// file for write.lease-ttl, once half of it has passed, so that it doesn't
+// expire while the file is being written. It fails with EBUSY if the lease was
+// lost, i.e. the metadata of the object changed since it was leased, e.g.
+// because another holder leased it once it expired.
+//
+// The lease of a file written by streaming writes isn't renewed: their upload
+// is conditioned on the meta-generation it started from, which renewing it
+// would change.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) renewLease(ctx context.Context) error {
+	if f.config == nil || f.config.Write.LeaseTtl <= 0 || f.IsLocal() || f.bwh != nil {
+		return nil
+	}
+	holder, expiry, ok := parseLease(f.src.Metadata[LeaseMetadataKey])
+	if !ok || holder != leaseHolder {
+		return nil
+	}
+
+	ttl := f.config.Write.LeaseTtl
+	now := f.mtimeClock.Now()
+	if now.Before(expiry.Add(-ttl / 2)) {
+		return nil
+	}
+
+	lease := formatLease(leaseHolder, now.Add(ttl))
+	srcGen := f.SourceGeneration()
+	updated, err := f.bucket.UpdateObject(ctx, &gcs.UpdateObjectRequest{
+		Name:                       f.src.Name,
+		Generation:                 srcGen.Object,
+		MetaGenerationPrecondition: &srcGen.Metadata,
+		Metadata: map[string]*string{
+			LeaseMetadataKey: &lease,
+		},
+	})
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		return fmt.Errorf("%w: the lease of %q, until %s, was lost: %v", syscall.EBUSY, f.src.Name, expiry.Format(time.RFC3339), err)
+	}
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateObject: %w", err)
+	}
+	f.src = *storageutil.ConvertObjToMinObject(updated)
+	return nil
+}
+
+// leaseLostDuringUpload returns the error of an upload of the contents of a
+// file whose object was leased by this process, which failed with the supplied
+// error because the object was modified in the meantime: the lease expired
+// during the upload, and another holder leased the object. The contents of the
+// other holder aren't overwritten, whatever file-system.clobber-action is.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) leaseLostDuringUpload(err error) error {
+	_, expiry, _ := parseLease(f.src.Metadata[LeaseMetadataKey])
+	return fmt.Errorf("%w: the lease of %q, until %s, was lost during the upload: %v", syscall.EBUSY, f.src.Name, expiry.Format(time.RFC3339), err)
+}
+
+// releaseLease removes the lease of the object of the file held by this
+// process, once its contents are written out. A failure is only logged, the
+// lease then expiring on its own.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) releaseLease(ctx context.Context) {
+	if f.IsLocal() || !f.holdsLease() {
+		return
+	}
+
+	srcGen := f.SourceGeneration()
+	o, err := f.bucket.UpdateObject(ctx, &gcs.UpdateObjectRequest{
+		Name:                       f.src.Name,
+		Generation:                 srcGen.Object,
+		MetaGenerationPrecondition: &srcGen.Metadata,
+		Metadata: map[string]*string{
+			LeaseMetadataKey: nil,
+		},
+	})
+	if err != nil {
+		logger.Warnf("Releasing the lease of %q: %v", f.src.Name, err)
+		return
+	}
+	f.src = *storageutil.ConvertObjToMinObject(o)
+}
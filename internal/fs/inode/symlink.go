@@ -27,6 +27,12 @@ import (
 // this with IsSymlink.
 const SymlinkMetadataKey = "gcsfuse_symlink_target"
 
+// The content type of the symlink objects created by gcsfuse, whose contents
+// are their target, so that the other tools reading the bucket don't take
+// them for regular files. The symlinks are still detected by
+// SymlinkMetadataKey, the content type isn't part of gcs.MinObject.
+const SymlinkContentType = "application/x-gcsfuse-symlink"
+
 // IsSymlink Does the supplied object represent a symlink inode?
 func IsSymlink(m *gcs.MinObject) bool {
 	if m == nil {
@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inode
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// UploadStatus is the progress of writing out the contents of a file to GCS.
+type UploadStatus struct {
+	// Buffered is the number of bytes of the contents not committed to GCS
+	// yet.
+	Buffered int64
+
+	// Committed is the number of bytes committed to GCS by the upload in
+	// progress, or by the last one.
+	Committed int64
+
+	// PendingBlocks is the number of blocks of streaming writes waiting for
+	// their upload.
+	PendingBlocks int64
+
+	// Uploading is true while the file is flushed.
+	Uploading bool
+}
+
+// Pending returns true if some contents are waiting to be written out, or are
+// being written out.
+func (s UploadStatus) Pending() bool {
+	return s.Buffered > 0 || s.PendingBlocks > 0 || s.Uploading
+}
+
+func (s UploadStatus) String() string {
+	return fmt.Sprintf("buffered=%d committed=%d pending_blocks=%d uploading=%t", s.Buffered, s.Committed, s.PendingBlocks, s.Uploading)
+}
+
+// uploadTracker tracks the contents of a file waiting to be written out and
+// their upload. It's read without the lock of the inode, which the flushes
+// hold throughout their uploads, so that the users waiting on a long close()
+// can see whether it progresses.
+type uploadTracker struct {
+	// The size of the contents to write out, or 0 when they're clean.
+	size atomic.Int64
+
+	// The bytes committed to GCS by the upload in progress, or the last one.
+	committed atomic.Int64
+
+	pendingBlocks atomic.Int64
+	uploading     atomic.Bool
+}
+
+// dirty records that the contents of the supplied size are to be written out
+// from scratch.
+func (t *uploadTracker) dirty(size int64) {
+	t.size.Store(size)
+	t.committed.Store(0)
+}
+
+// grow records that the contents to write out extend at least to end.
+func (t *uploadTracker) grow(end int64) {
+	for {
+		size := t.size.Load()
+		if end <= size || t.size.CompareAndSwap(size, end) {
+			return
+		}
+	}
+}
+
+// resize records that the contents to write out are of the supplied size.
+func (t *uploadTracker) resize(size int64) {
+	t.size.Store(size)
+}
+
+// start records that a flush of the contents started.
+func (t *uploadTracker) start() {
+	t.uploading.Store(true)
+}
+
+// finish records that the flush started last ended, having written out all
+// the contents if succeeded is true.
+func (t *uploadTracker) finish(succeeded bool) {
+	if succeeded {
+		t.committed.Store(t.size.Load())
+		t.size.Store(0)
+	}
+	t.uploading.Store(false)
+}
+
+// Committed records the bytes committed to GCS by the upload in progress.
+func (t *uploadTracker) Committed(bytesUploadedSoFar int64) {
+	t.committed.Store(bytesUploadedSoFar)
+}
+
+// BlocksPending records the change in the number of blocks waiting for their
+// upload.
+func (t *uploadTracker) BlocksPending(delta int64) {
+	t.pendingBlocks.Add(delta)
+}
+
+func (t *uploadTracker) status() UploadStatus {
+	size, committed := t.size.Load(), t.committed.Load()
+	return UploadStatus{
+		Buffered:      max(size-committed, 0),
+		Committed:     committed,
+		PendingBlocks: t.pendingBlocks.Load(),
+		Uploading:     t.uploading.Load(),
+	}
+}
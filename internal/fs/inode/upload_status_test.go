@@ -0,0 +1,56 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadTracker_Upload(t *testing.T) {
+	var tracker uploadTracker
+	tracker.dirty(10)
+	tracker.grow(30)
+	tracker.grow(20)
+	tracker.BlocksPending(2)
+
+	tracker.start()
+	tracker.Committed(12)
+	tracker.BlocksPending(-1)
+
+	assert.Equal(t, UploadStatus{Buffered: 18, Committed: 12, PendingBlocks: 1, Uploading: true}, tracker.status())
+	tracker.BlocksPending(-1)
+	tracker.finish(true)
+	assert.Equal(t, UploadStatus{Committed: 30}, tracker.status())
+}
+
+func TestUploadTracker_FailedUpload(t *testing.T) {
+	var tracker uploadTracker
+	tracker.dirty(10)
+	tracker.start()
+	tracker.Committed(4)
+
+	tracker.finish(false)
+
+	assert.Equal(t, UploadStatus{Buffered: 6, Committed: 4}, tracker.status())
+	assert.True(t, tracker.status().Pending())
+}
+
+func TestUploadStatus_String(t *testing.T) {
+	s := UploadStatus{Buffered: 1, Committed: 2, PendingBlocks: 3, Uploading: true}
+
+	assert.Equal(t, "buffered=1 committed=2 pending_blocks=3 uploading=true", s.String())
+}
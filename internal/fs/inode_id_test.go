@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newInodeIDTestFS(syncToolCompat bool) *fileSystem {
+	return &fileSystem{
+		newConfig:   &cfg.Config{FileSystem: cfg.FileSystemConfig{SyncToolCompat: syncToolCompat}},
+		inodes:      make(map[fuseops.InodeID]inode.Inode),
+		nextInodeID: fuseops.RootInodeID + 1,
+		mtimeClock:  timeutil.RealClock(),
+	}
+}
+
+func TestAllocateInodeID_Sequential(t *testing.T) {
+	fs := newInodeIDTestFS(false)
+	name := journalFile(journalRoot, "foo")
+
+	assert.Equal(t, fuseops.InodeID(fuseops.RootInodeID+1), fs.allocateInodeID(name))
+	assert.Equal(t, fuseops.InodeID(fuseops.RootInodeID+2), fs.allocateInodeID(name))
+}
+
+func TestAllocateInodeID_StableAcrossFileSystems(t *testing.T) {
+	foo := journalFile(journalRoot, "foo")
+	bar := journalFile(journalRoot, "bar")
+
+	id := newInodeIDTestFS(true).allocateInodeID(foo)
+
+	assert.Greater(t, id, fuseops.InodeID(fuseops.RootInodeID))
+	assert.Equal(t, id, newInodeIDTestFS(true).allocateInodeID(foo))
+	assert.NotEqual(t, id, newInodeIDTestFS(true).allocateInodeID(bar))
+	assert.NotEqual(t, id, newInodeIDTestFS(true).allocateInodeID(journalDir("foo")))
+}
+
+func TestAllocateInodeID_SkipsLiveInodes(t *testing.T) {
+	fs := newInodeIDTestFS(true)
+	name := journalFile(journalRoot, "foo")
+	id := fs.allocateInodeID(name)
+	fs.inodes[id] = inode.NewSymlinkInode(id, name, &gcs.MinObject{Name: "foo"}, fuseops.InodeAttributes{})
+
+	assert.Equal(t, id+1, fs.allocateInodeID(name))
+}
+
+func TestDirTime(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fs := newInodeIDTestFS(true)
+
+	assert.Equal(t, updated, fs.dirTime(inode.Core{MinObject: &gcs.MinObject{Updated: updated}}))
+	assert.Equal(t, updated, fs.dirTime(inode.Core{Folder: &gcs.Folder{UpdateTime: updated}}))
+	assert.Equal(t, time.Unix(0, 0), fs.dirTime(inode.Core{}))
+	assert.WithinDuration(t, time.Now(), newInodeIDTestFS(false).dirTime(inode.Core{}), time.Minute)
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs_test
+
+import (
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/lifecycle"
+	. "github.com/jacobsa/ogletest"
+	"golang.org/x/sys/unix"
+)
+
+type LifecycleTest struct {
+	fsTest
+}
+
+func init() {
+	RegisterTestSuite(&LifecycleTest{})
+}
+
+func (t *LifecycleTest) SetUpTestSuite() {
+	t.serverCfg.NewConfig = &cfg.Config{
+		FileCache: defaultFileCacheConfig(),
+		FileSystem: cfg.FileSystemConfig{
+			EnableXattrs:        true,
+			LifecycleHintWindow: 48 * time.Hour,
+		},
+		MetadataCache: cfg.MetadataCacheConfig{
+			StatCacheMaxSizeMb: 32,
+			TtlSecs:            60,
+			TypeCacheMaxSizeMb: 4,
+		},
+	}
+	t.serverCfg.LifecycleRules = lifecycle.New([]storage.LifecycleRule{{
+		Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+		Condition: storage.LifecycleCondition{AgeInDays: 1, MatchesPrefix: []string{"expiring/"}},
+	}}, "")
+	t.fsTest.SetUpTestSuite()
+}
+
+func (t *LifecycleTest) ExpiringFilesHaveTheLifecycleXattr() {
+	AssertEq(nil, t.createObjects(map[string]string{
+		"expiring/foo": "taco",
+		"kept/foo":     "burrito",
+	}))
+	buf := make([]byte, 64)
+
+	n, err := unix.Getxattr(path.Join(mntDir, "expiring/foo"), "user.gcsfuse.lifecycle", buf)
+
+	AssertEq(nil, err)
+	ExpectTrue(strings.HasPrefix(string(buf[:n]), "Delete "), "%q", buf[:n])
+	_, err = unix.Getxattr(path.Join(mntDir, "kept/foo"), "user.gcsfuse.lifecycle", buf)
+	ExpectEq(syscall.ENODATA, err)
+}
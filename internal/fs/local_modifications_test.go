@@ -2370,7 +2370,7 @@ func (t *SymlinkTest) CreateLink() {
 
 	AssertEq(nil, err)
 	AssertNe(nil, m)
-	ExpectEq(0, m.Size)
+	ExpectEq(len("foo"), m.Size)
 	ExpectEq("foo", m.Metadata["gcsfuse_symlink_target"])
 
 	// Read the link.
@@ -2453,6 +2453,82 @@ func (t *SymlinkTest) RemoveLink() {
 	ExpectTrue(errors.As(err, &notFoundErr))
 }
 
+////////////////////////////////////////////////////////////////////////
+// Hard links
+////////////////////////////////////////////////////////////////////////
+
+type HardLinkTest struct {
+	fsTest
+}
+
+func init() {
+	RegisterTestSuite(&HardLinkTest{})
+}
+
+func (t *HardLinkTest) CreateLink() {
+	var err error
+
+	// Create a file, without closing it.
+	fileName := path.Join(mntDir, "foo")
+	f, err := os.Create(fileName)
+	AssertEq(nil, err)
+	defer f.Close()
+	_, err = f.Write([]byte("taco"))
+	AssertEq(nil, err)
+
+	// Link to it.
+	linkName := path.Join(mntDir, "bar")
+	err = os.Link(fileName, linkName)
+	AssertEq(nil, err)
+
+	// The link has the contents of the file.
+	contents, err := os.ReadFile(linkName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+	contents, err = storageutil.ReadObject(ctx, bucket, "bar")
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+
+	// The writes through the link aren't seen through the file.
+	err = os.WriteFile(linkName, []byte("burrito"), 0400)
+	AssertEq(nil, err)
+	contents, err = os.ReadFile(fileName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+}
+
+func (t *HardLinkTest) CreateLink_Exists() {
+	var err error
+
+	// Create two files.
+	fileName := path.Join(mntDir, "foo")
+	err = os.WriteFile(fileName, []byte("taco"), 0400)
+	AssertEq(nil, err)
+
+	linkName := path.Join(mntDir, "bar")
+	err = os.WriteFile(linkName, []byte("burrito"), 0400)
+	AssertEq(nil, err)
+
+	// Linking on top of the second one should fail.
+	err = os.Link(fileName, linkName)
+	ExpectThat(err, Error(HasSubstr("exists")))
+
+	contents, err := os.ReadFile(linkName)
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(contents))
+}
+
+func (t *HardLinkTest) CreateLink_Directory() {
+	var err error
+
+	dirName := path.Join(mntDir, "foo")
+	err = os.Mkdir(dirName, 0700)
+	AssertEq(nil, err)
+
+	err = os.Link(dirName, path.Join(mntDir, "bar"))
+	ExpectThat(err, Error(HasSubstr("not permitted")))
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Rename
 ////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	. "github.com/jacobsa/ogletest"
+	"golang.org/x/sys/unix"
+)
+
+type PinTest struct {
+	fsTest
+}
+
+func init() {
+	RegisterTestSuite(&PinTest{})
+}
+
+// The files stay pinned for the lifetime of the mount, so each test pins its
+// own.
+func (t *PinTest) SetUpTestSuite() {
+	t.serverCfg.ImplicitDirectories = true
+	t.serverCfg.NewConfig = &cfg.Config{
+		FileCache: defaultFileCacheConfig(),
+		FileSystem: cfg.FileSystemConfig{
+			PinPatterns: []string{"pinned/*"},
+		},
+	}
+	t.fsTest.SetUpTestSuite()
+}
+
+func (t *PinTest) FirstOpenPinsTheGeneration() {
+	AssertEq(nil, t.createWithContents("pinned/first", "taco"))
+	// The file isn't pinned until it's opened.
+	AssertEq(nil, t.createWithContents("pinned/first", "burrito"))
+	fi, err := os.Stat(path.Join(mntDir, "pinned/first"))
+	AssertEq(nil, err)
+	AssertEq(len("burrito"), fi.Size())
+
+	contents, err := os.ReadFile(path.Join(mntDir, "pinned/first"))
+
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(contents))
+	AssertEq(nil, t.createWithContents("pinned/first", "enchilada"))
+	fi, err = os.Stat(path.Join(mntDir, "pinned/first"))
+	AssertEq(nil, err)
+	ExpectEq(len("burrito"), fi.Size())
+}
+
+func (t *PinTest) LookUpAfterOverwriteReturnsThePinnedGeneration() {
+	AssertEq(nil, t.createObjects(map[string]string{
+		"pinned/overwritten":   "taco",
+		"unpinned/overwritten": "taco",
+	}))
+	for _, n := range []string{"pinned/overwritten", "unpinned/overwritten"} {
+		_, err := os.ReadFile(path.Join(mntDir, n))
+		AssertEq(nil, err)
+	}
+
+	AssertEq(nil, t.createObjects(map[string]string{
+		"pinned/overwritten":   "burrito",
+		"unpinned/overwritten": "burrito",
+	}))
+
+	fi, err := os.Stat(path.Join(mntDir, "pinned/overwritten"))
+	AssertEq(nil, err)
+	ExpectEq(len("taco"), fi.Size())
+	fi, err = os.Stat(path.Join(mntDir, "unpinned/overwritten"))
+	AssertEq(nil, err)
+	ExpectEq(len("burrito"), fi.Size())
+}
+
+func (t *PinTest) OpenOfAnotherGenerationFails() {
+	AssertEq(nil, t.createWithContents("pinned/other", "taco"))
+	// Keep the inode of the first generation, which the kernel opens the file
+	// descriptor through below, without opening it.
+	fd, err := unix.Open(path.Join(mntDir, "pinned/other"), unix.O_PATH, 0)
+	AssertEq(nil, err)
+	defer unix.Close(fd)
+	AssertEq(nil, t.createWithContents("pinned/other", "burrito"))
+	contents, err := os.ReadFile(path.Join(mntDir, "pinned/other"))
+	AssertEq(nil, err)
+	AssertEq("burrito", string(contents))
+
+	_, err = os.Open(fmt.Sprintf("/proc/self/fd/%d", fd))
+
+	ExpectTrue(errors.Is(err, syscall.ESTALE), "err: %v", err)
+}
+
+func (t *PinTest) OpenForWritingFails() {
+	AssertEq(nil, t.createWithContents("pinned/read_only", "taco"))
+
+	_, err := os.OpenFile(path.Join(mntDir, "pinned/read_only"), os.O_WRONLY, 0)
+
+	ExpectTrue(errors.Is(err, syscall.EROFS), "err: %v", err)
+	err = os.Truncate(path.Join(mntDir, "pinned/read_only"), 0)
+	ExpectTrue(errors.Is(err, syscall.EROFS), "err: %v", err)
+	contents, err := os.ReadFile(path.Join(mntDir, "pinned/read_only"))
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+}
+
+func (t *PinTest) ReadAfterThePinnedGenerationIsDeletedFails() {
+	AssertEq(nil, t.createWithContents("pinned/deleted", "taco"))
+	var err error
+	t.f1, err = os.Open(path.Join(mntDir, "pinned/deleted"))
+	AssertEq(nil, err)
+	AssertEq(nil, bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: "pinned/deleted"}))
+
+	_, err = t.f1.Read(make([]byte, 4))
+
+	ExpectTrue(errors.Is(err, syscall.ESTALE), "err: %v", err)
+}
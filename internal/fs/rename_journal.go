@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+)
+
+// renameJournal records the ranges of names being moved by the directory
+// renames in progress, and the names being mutated by the other namespace
+// operations in progress, so that they exclude each other without keeping the
+// directory inodes locked. A directory rename in a non-hierarchical bucket
+// copies and deletes its objects one by one, which may take minutes, during
+// which the lookups in the directories must not stall.
+//
+// A rename waits for the mutations in progress within its ranges, and the
+// mutations of names within its ranges, or overlapping renames, wait for it to
+// complete. Unrelated names in the same directories remain usable.
+type renameJournal struct {
+	mu sync.Mutex
+
+	// The ranges of the renames in progress, i.e. the old and new names of
+	// the renamed directories, which cover all the names they prefix.
+	//
+	// GUARDED_BY(mu)
+	ranges map[*renameRange]struct{}
+
+	// The number of mutations in progress by name.
+	//
+	// GUARDED_BY(mu)
+	mutations map[inode.Name]int
+
+	// Closed and replaced whenever a rename or a mutation completes, to wake up
+	// the waiters.
+	//
+	// GUARDED_BY(mu)
+	changed chan struct{}
+}
+
+type renameRange struct {
+	oldDir inode.Name
+	newDir inode.Name
+}
+
+func newRenameJournal() *renameJournal {
+	return &renameJournal{
+		ranges:    make(map[*renameRange]struct{}),
+		mutations: make(map[inode.Name]int),
+		changed:   make(chan struct{}),
+	}
+}
+
+// covers returns true if the supplied name is the supplied directory or within
+// it.
+func covers(dir inode.Name, name inode.Name) bool {
+	return strings.HasPrefix(name.LocalName(), dir.LocalName())
+}
+
+func (r *renameRange) covers(name inode.Name) bool {
+	return covers(r.oldDir, name) || covers(r.newDir, name)
+}
+
+func (r *renameRange) overlaps(other *renameRange) bool {
+	return r.covers(other.oldDir) || r.covers(other.newDir) ||
+		other.covers(r.oldDir) || other.covers(r.newDir)
+}
+
+// wait waits until blocked returns false, and then calls record. It returns
+// EINTR if ctx is done first.
+//
+// LOCKS_EXCLUDED(j.mu)
+func (j *renameJournal) wait(ctx context.Context, blocked func() bool, record func()) error {
+	for {
+		j.mu.Lock()
+		if !blocked() {
+			record()
+			j.mu.Unlock()
+			return nil
+		}
+		changed := j.changed
+		j.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return syscall.EINTR
+		}
+	}
+}
+
+// LOCKS_REQUIRED(j.mu)
+func (j *renameJournal) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// startRename waits until no rename overlapping the renaming of oldDir to
+// newDir, and no mutation of a name within them, is in progress, and then
+// records the rename until the returned function is called.
+//
+// LOCKS_EXCLUDED(j.mu)
+func (j *renameJournal) startRename(ctx context.Context, oldDir inode.Name, newDir inode.Name) (done func(), err error) {
+	r := &renameRange{oldDir: oldDir, newDir: newDir}
+	blocked := func() bool {
+		for other := range j.ranges {
+			if r.overlaps(other) {
+				return true
+			}
+		}
+		for name := range j.mutations {
+			if r.covers(name) {
+				return true
+			}
+		}
+		return false
+	}
+	if err = j.wait(ctx, blocked, func() { j.ranges[r] = struct{}{} }); err != nil {
+		return nil, err
+	}
+
+	done = func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		delete(j.ranges, r)
+		j.notify()
+	}
+	return done, nil
+}
+
+// startMutation waits until no rename covering the supplied names is in
+// progress, and then records their mutation until the returned function is
+// called.
+//
+// LOCKS_EXCLUDED(j.mu)
+func (j *renameJournal) startMutation(ctx context.Context, names ...inode.Name) (done func(), err error) {
+	blocked := func() bool {
+		for r := range j.ranges {
+			for _, name := range names {
+				if r.covers(name) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	record := func() {
+		for _, name := range names {
+			j.mutations[name]++
+		}
+	}
+	if err = j.wait(ctx, blocked, record); err != nil {
+		return nil, err
+	}
+
+	done = func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		for _, name := range names {
+			if j.mutations[name]--; j.mutations[name] == 0 {
+				delete(j.mutations, name)
+			}
+		}
+		j.notify()
+	}
+	return done, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var journalRoot = inode.NewRootName("")
+
+func journalDir(name string) inode.Name {
+	return inode.NewDirName(journalRoot, name)
+}
+
+func journalFile(parent inode.Name, name string) inode.Name {
+	return inode.NewFileName(parent, name)
+}
+
+// startsWithin returns true if start returns before the timeout, i.e. isn't
+// blocked by the journal.
+func startsWithin(t *testing.T, timeout time.Duration, start func(ctx context.Context) (func(), error)) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done, err := start(ctx)
+	if err != nil {
+		assert.Equal(t, syscall.EINTR, err)
+		return false
+	}
+	done()
+	return true
+}
+
+func TestRenameJournal_MutationsWithinRenameWait(t *testing.T) {
+	j := newRenameJournal()
+	doneRename, err := j.startRename(context.Background(), journalDir("a"), journalDir("b"))
+	require.NoError(t, err)
+
+	for _, name := range []inode.Name{
+		journalFile(journalDir("a"), "foo"),
+		journalDir("a/c"),
+		journalFile(journalDir("b"), "foo"),
+		journalDir("b"),
+	} {
+		assert.False(t, startsWithin(t, 10*time.Millisecond, func(ctx context.Context) (func(), error) {
+			return j.startMutation(ctx, name)
+		}), name)
+	}
+	// Unrelated names in the same directory remain usable.
+	for _, name := range []inode.Name{
+		journalFile(journalRoot, "a"),
+		journalFile(journalRoot, "foo"),
+		journalDir("ab"),
+	} {
+		assert.True(t, startsWithin(t, time.Second, func(ctx context.Context) (func(), error) {
+			return j.startMutation(ctx, name)
+		}), name)
+	}
+
+	doneRename()
+	assert.True(t, startsWithin(t, time.Second, func(ctx context.Context) (func(), error) {
+		return j.startMutation(ctx, journalFile(journalDir("a"), "foo"))
+	}))
+}
+
+func TestRenameJournal_RenameWaitsForMutationsWithin(t *testing.T) {
+	j := newRenameJournal()
+	doneMutation, err := j.startMutation(context.Background(), journalFile(journalDir("a"), "foo"))
+	require.NoError(t, err)
+	started := make(chan struct{})
+
+	go func() {
+		done, err := j.startRename(context.Background(), journalDir("a"), journalDir("b"))
+		if assert.NoError(t, err) {
+			close(started)
+			done()
+		}
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("The rename started during a mutation within it.")
+	case <-time.After(10 * time.Millisecond):
+	}
+	doneMutation()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("The rename didn't start once the mutation completed.")
+	}
+}
+
+func TestRenameJournal_OverlappingRenamesExcludeEachOther(t *testing.T) {
+	j := newRenameJournal()
+	done, err := j.startRename(context.Background(), journalDir("a"), journalDir("b"))
+	require.NoError(t, err)
+	defer done()
+
+	assert.False(t, startsWithin(t, 10*time.Millisecond, func(ctx context.Context) (func(), error) {
+		return j.startRename(ctx, journalDir("c"), journalDir("a/d"))
+	}))
+	assert.False(t, startsWithin(t, 10*time.Millisecond, func(ctx context.Context) (func(), error) {
+		return j.startRename(ctx, journalDir("b/e"), journalDir("f"))
+	}))
+	assert.True(t, startsWithin(t, time.Second, func(ctx context.Context) (func(), error) {
+		return j.startRename(ctx, journalDir("c"), journalDir("d"))
+	}))
+}
@@ -19,6 +19,7 @@ import (
 
 	newcfg "github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/wrappers"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseutil"
 	"golang.org/x/net/context"
@@ -30,11 +31,25 @@ func NewServer(ctx context.Context, cfg *ServerConfig) (fuse.Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create file system: %w", err)
 	}
+	pathOf := fs.(*fileSystem).inodePath
 
+	if len(cfg.VirtualFiles) > 0 {
+		fs = wrappers.WithVirtualFiles(fs, cfg.VirtualFiles, cfg.Uid, cfg.Gid, cfg.FilePerms, cfg.DirPerms)
+	}
+	if newcfg.IsReadOnlyMount(cfg.NewConfig) {
+		logger.Infof("Mounting read-only: ops modifying the file system fail with EROFS.")
+		fs = wrappers.WithReadOnly(fs)
+	}
 	fs = wrappers.WithErrorMapping(fs, cfg.NewConfig.FileSystem.PreconditionErrors)
+	fs = wrappers.WithOpLogging(fs, pathOf)
+	fs = wrappers.WithMonitoring(fs, cfg.MetricHandle, cfg.OpStats)
+	// The metrics of the ops are recorded in their spans, so that the sampled
+	// spans are exemplars of the latency histograms.
 	if newcfg.IsTracingEnabled(cfg.NewConfig) {
 		fs = wrappers.WithTracing(fs)
 	}
-	fs = wrappers.WithMonitoring(fs, cfg.MetricHandle)
+	if cfg.NewConfig.FileSystem.MaxConcurrentOps > 0 {
+		fs = wrappers.WithConcurrencyLimit(fs, cfg.NewConfig.FileSystem.MaxConcurrentOps, cfg.MetricHandle)
+	}
 	return fuseutil.NewFileSystemServer(fs), nil
 }
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// smallFilePrefetchOpens is the number of small files of a listed
+	// directory opened after which its other small files are prefetched.
+	smallFilePrefetchOpens = 2
+
+	// maxSmallFilePrefetchDirs bounds the listed directories tracked at a
+	// time, all of which are forgotten past it.
+	maxSmallFilePrefetchDirs = 4096
+)
+
+// smallFilePrefetchFeature is the kill switch of the prefetching of small
+// files, with which the files opened afterwards don't trigger any.
+var smallFilePrefetchFeature = featureflag.Register("small-file-prefetch", "Prefetch the small files of the directories read file by file into the file cache (--file-cache-small-file-prefetch-max-size-kb).")
+
+// smallFilePrefetcher downloads the small objects of a directory into the file
+// cache concurrently, when a directory is listed and then its small files are
+// opened one after the other, as the datasets made of many tiny files are read.
+// Reading them one RPC at a time is otherwise dominated by the latency of GCS.
+type smallFilePrefetcher struct {
+	// prefetch downloads the whole object into the file cache.
+	prefetch func(ctx context.Context, o *gcs.MinObject, bucket gcs.Bucket) error
+
+	// maxSize is the size up to which the objects are prefetched.
+	maxSize uint64
+
+	// parallelism is the number of objects prefetched at a time.
+	parallelism int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// opens holds the number of small files opened in each directory since it
+	// was listed, by bucket name and directory prefix.
+	//
+	// GUARDED_BY(mu)
+	opens map[smallFileDir]int
+}
+
+type smallFileDir struct {
+	bucket string
+	prefix string
+}
+
+func newSmallFilePrefetcher(prefetch func(ctx context.Context, o *gcs.MinObject, bucket gcs.Bucket) error, maxSize uint64, parallelism int) *smallFilePrefetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &smallFilePrefetcher{
+		prefetch:    prefetch,
+		maxSize:     maxSize,
+		parallelism: parallelism,
+		ctx:         ctx,
+		cancel:      cancel,
+		opens:       make(map[smallFileDir]int),
+	}
+}
+
+// listed records that the directory with the given prefix of the bucket has
+// been listed from the start.
+func (p *smallFilePrefetcher) listed(bucket gcs.Bucket, prefix string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.opens) >= maxSmallFilePrefetchDirs {
+		clear(p.opens)
+	}
+	p.opens[smallFileDir{bucket.Name(), prefix}] = 0
+}
+
+// opened records that the given object has been opened for reading, and
+// prefetches the small objects of its directory in the background if enough
+// of them have been opened since the directory was listed.
+func (p *smallFilePrefetcher) opened(bucket gcs.Bucket, o *gcs.MinObject) {
+	if o.Size > p.maxSize || !smallFilePrefetchFeature.Enabled() {
+		return
+	}
+	dir := smallFileDir{bucket.Name(), o.Name[:strings.LastIndex(o.Name, "/")+1]}
+
+	p.mu.Lock()
+	n, ok := p.opens[dir]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	if n+1 < smallFilePrefetchOpens {
+		p.opens[dir] = n + 1
+		p.mu.Unlock()
+		return
+	}
+	// Prefetch the directory only once per listing.
+	delete(p.opens, dir)
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.prefetchDir(bucket, dir.prefix)
+	}()
+}
+
+// prefetchDir prefetches the objects of the directory with the given prefix
+// up to maxSize, at most parallelism at a time.
+func (p *smallFilePrefetcher) prefetchDir(bucket gcs.Bucket, prefix string) {
+	var g errgroup.Group
+	g.SetLimit(p.parallelism)
+	var count int
+	req := &gcs.ListObjectsRequest{
+		Prefix:    prefix,
+		Delimiter: "/",
+	}
+	for {
+		listing, err := bucket.ListObjects(p.ctx, req)
+		if err != nil {
+			if p.ctx.Err() == nil {
+				logger.Warnf("Failed to list %q to prefetch its small files: %v", prefix, err)
+			}
+			break
+		}
+		for _, o := range listing.MinObjects {
+			if o.Size == 0 || o.Size > p.maxSize || strings.HasSuffix(o.Name, "/") {
+				continue
+			}
+			count++
+			g.Go(func() error {
+				if err := p.prefetch(p.ctx, o, bucket); err != nil && p.ctx.Err() == nil {
+					logger.Debugf("Failed to prefetch %q: %v", o.Name, err)
+				}
+				return nil
+			})
+		}
+		if listing.ContinuationToken == "" || p.ctx.Err() != nil {
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+	_ = g.Wait()
+	logger.Debugf("Prefetched %d small files of %q", count, prefix)
+}
+
+// stop cancels the prefetches in progress and waits for them to return.
+func (p *smallFilePrefetcher) stop() {
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPrefetch struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recordingPrefetch) prefetch(_ context.Context, o *gcs.MinObject, _ gcs.Bucket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, o.Name)
+	return nil
+}
+
+func newSmallFilesBucket(t *testing.T) gcs.Bucket {
+	t.Helper()
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	err := storageutil.CreateObjects(context.Background(), bucket, map[string][]byte{
+		"dir/a":       []byte("taco"),
+		"dir/b":       []byte("burrito"),
+		"dir/c":       []byte("enchilada"),
+		"dir/empty":   nil,
+		"dir/large":   []byte(strings.Repeat("x", 100)),
+		"dir/sub/d":   []byte("tamale"),
+		"other/e":     []byte("queso"),
+		"other/f":     []byte("salsa"),
+		"toplevel":    []byte("nachos"),
+		"dir/sub/e/f": []byte("churro"),
+	})
+	require.NoError(t, err)
+	return bucket
+}
+
+func statObject(t *testing.T, bucket gcs.Bucket, name string) *gcs.MinObject {
+	t.Helper()
+	m, _, err := bucket.StatObject(context.Background(), &gcs.StatObjectRequest{Name: name})
+	require.NoError(t, err)
+	return m
+}
+
+func TestSmallFilePrefetcherPrefetchesListedDirectory(t *testing.T) {
+	bucket := newSmallFilesBucket(t)
+	var r recordingPrefetch
+	p := newSmallFilePrefetcher(r.prefetch, 10, 2)
+
+	p.listed(bucket, "dir/")
+	p.opened(bucket, statObject(t, bucket, "dir/a"))
+	p.opened(bucket, statObject(t, bucket, "dir/b"))
+	p.stop()
+
+	// The empty, large and nested objects are skipped.
+	assert.ElementsMatch(t, []string{"dir/a", "dir/b", "dir/c"}, r.names)
+}
+
+func TestSmallFilePrefetcherWaitsForSecondOpen(t *testing.T) {
+	bucket := newSmallFilesBucket(t)
+	var r recordingPrefetch
+	p := newSmallFilePrefetcher(r.prefetch, 10, 2)
+
+	p.listed(bucket, "dir/")
+	p.opened(bucket, statObject(t, bucket, "dir/a"))
+	// The large objects don't count.
+	p.opened(bucket, statObject(t, bucket, "dir/large"))
+	// Neither do the objects of other directories.
+	p.opened(bucket, statObject(t, bucket, "other/e"))
+	p.stop()
+
+	assert.Empty(t, r.names)
+}
+
+func TestSmallFilePrefetcherIgnoresUnlistedDirectory(t *testing.T) {
+	bucket := newSmallFilesBucket(t)
+	var r recordingPrefetch
+	p := newSmallFilePrefetcher(r.prefetch, 10, 2)
+
+	p.opened(bucket, statObject(t, bucket, "other/e"))
+	p.opened(bucket, statObject(t, bucket, "other/f"))
+	p.stop()
+
+	assert.Empty(t, r.names)
+}
+
+func TestSmallFilePrefetcherPrefetchesOncePerListing(t *testing.T) {
+	bucket := newSmallFilesBucket(t)
+	var r recordingPrefetch
+	p := newSmallFilePrefetcher(r.prefetch, 10, 2)
+
+	p.listed(bucket, "")
+	for range 3 {
+		p.opened(bucket, statObject(t, bucket, "toplevel"))
+	}
+	p.wg.Wait()
+	assert.Equal(t, []string{"toplevel"}, r.names)
+
+	p.listed(bucket, "")
+	p.opened(bucket, statObject(t, bucket, "toplevel"))
+	p.opened(bucket, statObject(t, bucket, "toplevel"))
+	p.stop()
+	assert.Equal(t, []string{"toplevel", "toplevel"}, r.names)
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBucketUsage struct {
+	used uint64
+	err  error
+}
+
+func (u *fakeBucketUsage) UsedBytes(ctx context.Context) (uint64, error) {
+	return u.used, u.err
+}
+
+func statFS(t *testing.T, sizeMb int64, usage BucketUsage) *fuseops.StatFSOp {
+	t.Helper()
+	fs := &fileSystem{
+		newConfig:   &cfg.Config{FileSystem: cfg.FileSystemConfig{StatfsSizeMb: sizeMb}},
+		bucketUsage: usage,
+	}
+	op := &fuseops.StatFSOp{}
+	require.NoError(t, fs.StatFS(context.Background(), op))
+	return op
+}
+
+func TestStatFS_Default(t *testing.T) {
+	op := statFS(t, 0, nil)
+
+	assert.Equal(t, uint64(1<<33), op.Blocks)
+	assert.Equal(t, op.Blocks, op.BlocksFree)
+	assert.Equal(t, op.Blocks, op.BlocksAvailable)
+}
+
+func TestStatFS_SizeAndUsage(t *testing.T) {
+	// 1 GiB is 8192 blocks of 128 KiB, of which 3 are used.
+	op := statFS(t, 1024, &fakeBucketUsage{used: 2<<17 + 1})
+
+	assert.Equal(t, uint64(8192), op.Blocks)
+	assert.Equal(t, uint64(8189), op.BlocksFree)
+	assert.Equal(t, uint64(8189), op.BlocksAvailable)
+}
+
+func TestStatFS_UsageAboveSizeLeavesABlockFree(t *testing.T) {
+	op := statFS(t, 1, &fakeBucketUsage{used: 1 << 30})
+
+	assert.Equal(t, uint64(8), op.Blocks)
+	assert.Equal(t, uint64(1), op.BlocksFree)
+}
+
+func TestStatFS_UsageError(t *testing.T) {
+	op := statFS(t, 1, &fakeBucketUsage{err: errors.New("unavailable")})
+
+	assert.Equal(t, op.Blocks, op.BlocksFree)
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// trashTimeFormat is the format of the time of deletion appended to the names
+// of the files moved to the trash.
+const trashTimeFormat = "20060102T150405Z"
+
+// maxTrashPurgeInterval is the longest interval at which the files older than
+// trash-ttl are purged from the trash.
+const maxTrashPurgeInterval = time.Hour
+
+// trashPrefix returns the prefix of the objects in the trash directory dir,
+// relative to the root of the mount, or "" if dir is.
+func trashPrefix(dir string) string {
+	dir = strings.Trim(dir, "/")
+	if dir == "" || dir == "." {
+		return ""
+	}
+	return dir + "/"
+}
+
+// trashName returns the name of the object to which the file is moved when
+// it's unlinked, or "" if it's deleted, i.e. without a trash or for the files
+// in the trash.
+func (fs *fileSystem) trashName(fileName inode.Name) string {
+	objectName := fileName.GcsObjectName()
+	if fs.trashPrefix == "" || strings.HasPrefix(objectName, fs.trashPrefix) {
+		return ""
+	}
+	return fmt.Sprintf("%s%s.deleted-%s", fs.trashPrefix, objectName, fs.mtimeClock.Now().UTC().Format(trashTimeFormat))
+}
+
+// purgeTrash deletes the objects under prefix which were moved to it, i.e.
+// created, before the supplied time, returning how many were deleted.
+func purgeTrash(ctx context.Context, bucket gcs.Bucket, prefix string, before time.Time) (purged int, err error) {
+	req := &gcs.ListObjectsRequest{Prefix: prefix}
+	for {
+		listing, err := bucket.ListObjects(ctx, req)
+		if err != nil {
+			return purged, fmt.Errorf("ListObjects: %w", err)
+		}
+		for _, o := range listing.MinObjects {
+			if !o.Updated.Before(before) {
+				continue
+			}
+			// Only delete the generation that expired, in case the name was reused.
+			err = bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: o.Name, Generation: o.Generation})
+			var notFoundErr *gcs.NotFoundError
+			if err != nil && !errors.As(err, &notFoundErr) {
+				return purged, fmt.Errorf("DeleteObject %q: %w", o.Name, err)
+			}
+			purged++
+		}
+		if listing.ContinuationToken == "" {
+			return purged, nil
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+// purgeTrashPeriodically deletes the objects moved to the trash more than ttl
+// ago in the background, until the file system is destroyed.
+func (fs *fileSystem) purgeTrashPeriodically(bucket gcs.Bucket, ttl time.Duration) {
+	var ctx context.Context
+	ctx, fs.cancelPurgeTrash = context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(min(ttl, maxTrashPurgeInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			purged, err := purgeTrash(ctx, bucket, fs.trashPrefix, fs.mtimeClock.Now().Add(-ttl))
+			if err != nil && ctx.Err() == nil {
+				logger.Warnf("Failed to purge the trash: %v", err)
+			}
+			if purged > 0 {
+				logger.Infof("Purged %d files from the trash", purged)
+			}
+		}
+	}()
+}
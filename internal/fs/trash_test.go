@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashPrefix(t *testing.T) {
+	assert.Equal(t, "", trashPrefix(""))
+	assert.Equal(t, "", trashPrefix("/"))
+	assert.Equal(t, ".trash/", trashPrefix(".trash"))
+	assert.Equal(t, "a/trash/", trashPrefix("/a/trash/"))
+}
+
+func TestTrashName(t *testing.T) {
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))
+	fs := &fileSystem{trashPrefix: ".trash/", mtimeClock: clock}
+
+	assert.Equal(t, ".trash/foo/bar.deleted-20240102T150405Z", fs.trashName(inode.NewFileName(inode.NewRootName(""), "foo/bar")))
+	// The files in the trash are deleted for good.
+	assert.Equal(t, "", fs.trashName(inode.NewFileName(inode.NewRootName(""), ".trash/foo/bar.deleted-20240102T150405Z")))
+	// As are all the files without a trash.
+	fs.trashPrefix = ""
+	assert.Equal(t, "", fs.trashName(inode.NewFileName(inode.NewRootName(""), "foo/bar")))
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	bucket := fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(ctx, bucket, ".trash/old.deleted-20240102T000000Z", []byte("taco"))
+	require.NoError(t, err)
+	clock.AdvanceTime(2 * time.Hour)
+	_, err = storageutil.CreateObject(ctx, bucket, ".trash/new.deleted-20240102T020000Z", []byte("burrito"))
+	require.NoError(t, err)
+	_, err = storageutil.CreateObject(ctx, bucket, "old", []byte("enchilada"))
+	require.NoError(t, err)
+
+	purged, err := purgeTrash(ctx, bucket, ".trash/", clock.Now().Add(-time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	objects, _, err := storageutil.ListAll(ctx, bucket, &gcs.ListObjectsRequest{})
+	require.NoError(t, err)
+	var remaining []string
+	for _, o := range objects {
+		remaining = append(remaining, o.Name)
+	}
+	assert.Equal(t, []string{".trash/new.deleted-20240102T020000Z", "old"}, remaining)
+}
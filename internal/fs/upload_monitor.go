@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
+)
+
+// UploadMonitor reports the uploads of the files of a mount, on the request of
+// the control socket. It's set up by NewFileSystem.
+type UploadMonitor struct {
+	fs *fileSystem
+}
+
+// Uploads calls status with the path, relative to the root of the mount, and
+// the upload status of each file with contents waiting to be written out or
+// being written out, sorted by path.
+func (m *UploadMonitor) Uploads(status func(path, status string)) error {
+	if m.fs == nil {
+		return errors.New("the file system isn't set up")
+	}
+
+	m.fs.mu.Lock()
+	var files []*inode.FileInode
+	for _, in := range m.fs.inodes {
+		if f, ok := in.(*inode.FileInode); ok {
+			files = append(files, f)
+		}
+	}
+	m.fs.mu.Unlock()
+
+	// The statuses are read without the locks of the files, which their
+	// flushes hold throughout the uploads.
+	type upload struct {
+		path   string
+		status inode.UploadStatus
+	}
+	var uploads []upload
+	for _, f := range files {
+		if s := f.UploadStatus(); s.Pending() {
+			uploads = append(uploads, upload{f.Name().LocalName(), s})
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].path < uploads[j].path })
+	for _, u := range uploads {
+		status(u.path, u.status.String())
+	}
+	return nil
+}
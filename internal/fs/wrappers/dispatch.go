@@ -0,0 +1,184 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// WithConcurrencyLimit takes a FileSystem, returns a FileSystem processing at
+// most limit ops at a time, the other ones waiting for one of them to finish.
+// The time ops wait is recorded as fs/ops_dispatch_latency.
+//
+// The fuse library processes each op in its own goroutine, so without a limit
+// a burst of ops, e.g. the reads of a parallel job, competes for the CPU and
+// the GCS connections all at once.
+//
+// ForgetInode and BatchForget aren't limited: they're cheap, and the fuse
+// library processes ForgetInode in the goroutine reading the ops from the
+// kernel, which must not block.
+func WithConcurrencyLimit(fs fuseutil.FileSystem, limit int64, metricHandle common.MetricHandle) fuseutil.FileSystem {
+	return &concurrencyLimiting{
+		wrapped:      fs,
+		slots:        make(chan struct{}, limit),
+		metricHandle: metricHandle,
+	}
+}
+
+type concurrencyLimiting struct {
+	wrapped      fuseutil.FileSystem
+	slots        chan struct{}
+	metricHandle common.MetricHandle
+}
+
+func (fs *concurrencyLimiting) Destroy() {
+	fs.wrapped.Destroy()
+}
+
+func (fs *concurrencyLimiting) invokeWrapped(ctx context.Context, opName string, w wrappedCall) error {
+	startTime := time.Now()
+	select {
+	case fs.slots <- struct{}{}:
+	case <-ctx.Done():
+		// The op was interrupted by the kernel while waiting.
+		return syscall.EINTR
+	}
+	defer func() { <-fs.slots }()
+	fs.metricHandle.OpsDispatchLatency(ctx, float64(time.Since(startTime).Microseconds()), []common.MetricAttr{{Key: common.FSOp, Value: opName}})
+
+	return w(ctx)
+}
+
+func (fs *concurrencyLimiting) StatFS(ctx context.Context, op *fuseops.StatFSOp) error {
+	return fs.invokeWrapped(ctx, "StatFS", func(ctx context.Context) error { return fs.wrapped.StatFS(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	return fs.invokeWrapped(ctx, "LookUpInode", func(ctx context.Context) error { return fs.wrapped.LookUpInode(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	return fs.invokeWrapped(ctx, "GetInodeAttributes", func(ctx context.Context) error { return fs.wrapped.GetInodeAttributes(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	return fs.invokeWrapped(ctx, "SetInodeAttributes", func(ctx context.Context) error { return fs.wrapped.SetInodeAttributes(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	return fs.wrapped.ForgetInode(ctx, op)
+}
+
+func (fs *concurrencyLimiting) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) error {
+	return fs.wrapped.BatchForget(ctx, op)
+}
+
+func (fs *concurrencyLimiting) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	return fs.invokeWrapped(ctx, "MkDir", func(ctx context.Context) error { return fs.wrapped.MkDir(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) MkNode(ctx context.Context, op *fuseops.MkNodeOp) error {
+	return fs.invokeWrapped(ctx, "MkNode", func(ctx context.Context) error { return fs.wrapped.MkNode(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	return fs.invokeWrapped(ctx, "CreateFile", func(ctx context.Context) error { return fs.wrapped.CreateFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) CreateLink(ctx context.Context, op *fuseops.CreateLinkOp) error {
+	return fs.invokeWrapped(ctx, "CreateLink", func(ctx context.Context) error { return fs.wrapped.CreateLink(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) CreateSymlink(ctx context.Context, op *fuseops.CreateSymlinkOp) error {
+	return fs.invokeWrapped(ctx, "CreateSymlink", func(ctx context.Context) error { return fs.wrapped.CreateSymlink(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	return fs.invokeWrapped(ctx, "Rename", func(ctx context.Context) error { return fs.wrapped.Rename(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) RmDir(ctx context.Context, op *fuseops.RmDirOp) error {
+	return fs.invokeWrapped(ctx, "RmDir", func(ctx context.Context) error { return fs.wrapped.RmDir(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return fs.invokeWrapped(ctx, "Unlink", func(ctx context.Context) error { return fs.wrapped.Unlink(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	return fs.invokeWrapped(ctx, "OpenDir", func(ctx context.Context) error { return fs.wrapped.OpenDir(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	return fs.invokeWrapped(ctx, "ReadDir", func(ctx context.Context) error { return fs.wrapped.ReadDir(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) error {
+	return fs.invokeWrapped(ctx, "ReleaseDirHandle", func(ctx context.Context) error { return fs.wrapped.ReleaseDirHandle(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	return fs.invokeWrapped(ctx, "OpenFile", func(ctx context.Context) error { return fs.wrapped.OpenFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	return fs.invokeWrapped(ctx, "ReadFile", func(ctx context.Context) error { return fs.wrapped.ReadFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	return fs.invokeWrapped(ctx, "WriteFile", func(ctx context.Context) error { return fs.wrapped.WriteFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	return fs.invokeWrapped(ctx, "SyncFile", func(ctx context.Context) error { return fs.wrapped.SyncFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) error {
+	return fs.invokeWrapped(ctx, "FlushFile", func(ctx context.Context) error { return fs.wrapped.FlushFile(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) error {
+	return fs.invokeWrapped(ctx, "ReleaseFileHandle", func(ctx context.Context) error { return fs.wrapped.ReleaseFileHandle(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ReadSymlink(ctx context.Context, op *fuseops.ReadSymlinkOp) error {
+	return fs.invokeWrapped(ctx, "ReadSymlink", func(ctx context.Context) error { return fs.wrapped.ReadSymlink(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) RemoveXattr(ctx context.Context, op *fuseops.RemoveXattrOp) error {
+	return fs.invokeWrapped(ctx, "RemoveXattr", func(ctx context.Context) error { return fs.wrapped.RemoveXattr(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) error {
+	return fs.invokeWrapped(ctx, "GetXattr", func(ctx context.Context) error { return fs.wrapped.GetXattr(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) ListXattr(ctx context.Context, op *fuseops.ListXattrOp) error {
+	return fs.invokeWrapped(ctx, "ListXattr", func(ctx context.Context) error { return fs.wrapped.ListXattr(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) SetXattr(ctx context.Context, op *fuseops.SetXattrOp) error {
+	return fs.invokeWrapped(ctx, "SetXattr", func(ctx context.Context) error { return fs.wrapped.SetXattr(ctx, op) })
+}
+
+func (fs *concurrencyLimiting) Fallocate(ctx context.Context, op *fuseops.FallocateOp) error {
+	return fs.invokeWrapped(ctx, "Fallocate", func(ctx context.Context) error { return fs.wrapped.Fallocate(ctx, op) })
+}
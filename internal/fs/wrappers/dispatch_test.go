@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingFileSystem blocks ReadFile until release is closed.
+type blockingFileSystem struct {
+	fuseutil.NotImplementedFileSystem
+	started chan struct{}
+	release chan struct{}
+}
+
+func (fs *blockingFileSystem) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	fs.started <- struct{}{}
+	<-fs.release
+	return nil
+}
+
+type dispatchMetricHandle struct {
+	common.MetricHandle
+	mu        sync.Mutex
+	latencies map[string][]float64
+}
+
+func (m *dispatchMetricHandle) OpsDispatchLatency(_ context.Context, value float64, attrs []common.MetricAttr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies[attrs[0].Value] = append(m.latencies[attrs[0].Value], value)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	wrapped := &blockingFileSystem{started: make(chan struct{}, 3), release: make(chan struct{})}
+	m := &dispatchMetricHandle{MetricHandle: common.NewNoopMetrics(), latencies: make(map[string][]float64)}
+	fs := WithConcurrencyLimit(wrapped, 2, m)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, fs.ReadFile(context.Background(), &fuseops.ReadFileOp{}))
+		}()
+	}
+
+	<-wrapped.started
+	<-wrapped.started
+	// The third op waits for one of the first two to finish.
+	select {
+	case <-wrapped.started:
+		t.Fatal("More ops than the limit are processed concurrently")
+	case <-time.After(10 * time.Millisecond):
+	}
+	// Forgetting inodes never waits.
+	assert.Equal(t, syscall.ENOSYS, fs.ForgetInode(context.Background(), &fuseops.ForgetInodeOp{}))
+	close(wrapped.release)
+	wg.Wait()
+
+	assert.Len(t, wrapped.started, 1)
+	assert.Len(t, m.latencies["ReadFile"], 3)
+	assert.Empty(t, m.latencies["ForgetInode"])
+}
+
+func TestConcurrencyLimitInterruptedWhileWaiting(t *testing.T) {
+	wrapped := &blockingFileSystem{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(wrapped.release)
+	fs := WithConcurrencyLimit(wrapped, 1, common.NewNoopMetrics())
+	go func() { _ = fs.ReadFile(context.Background(), &fuseops.ReadFileOp{}) }()
+	<-wrapped.started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := fs.ReadFile(ctx, &fuseops.ReadFileOp{})
+
+	assert.Equal(t, syscall.EINTR, err)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/googleapis/gax-go/v2/apierror"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 	"google.golang.org/api/googleapi"
@@ -51,6 +52,13 @@ func errno(err error, preconditionErrCfg bool) error {
 		return syscall.EINTR
 	}
 
+	// The object was modified or deleted since the file was opened, and the
+	// flush refused to overwrite it with strict-overwrites.
+	var mismatchErr *gcsfuse_errors.GenerationMismatchError
+	if errors.As(err, &mismatchErr) {
+		return syscall.ESTALE
+	}
+
 	// The object is modified or deleted by a concurrent process.
 	var clobberedErr *gcsfuse_errors.FileClobberedError
 	if errors.As(err, &clobberedErr) {
@@ -64,6 +72,13 @@ func errno(err error, preconditionErrCfg bool) error {
 		return syscall.ENOENT
 	}
 
+	// Access to the object or folder is denied, e.g. by IAM on the managed
+	// folder containing it.
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	if errors.As(err, &permissionDeniedErr) {
+		return syscall.EACCES
+	}
+
 	// The HTTP request is canceled
 	if strings.Contains(err.Error(), "net/http: request canceled") {
 		return syscall.ECANCELED
@@ -22,6 +22,7 @@ import (
 
 	"github.com/googleapis/gax-go/v2/apierror"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/api/googleapi"
@@ -83,6 +84,14 @@ func (testSuite *ErrorMapping) TestUnAuthenticatedHttpGoogleApiError() {
 	assert.Equal(testSuite.T(), syscall.EACCES, fsErr)
 }
 
+func (testSuite *ErrorMapping) TestPermissionDeniedError() {
+	permissionDeniedErr := fmt.Errorf("ListObjects: %w", &gcs.PermissionDeniedError{Err: fmt.Errorf("managed folder denied")})
+
+	fsErr := errno(permissionDeniedErr, testSuite.preconditionErrCfg)
+
+	assert.Equal(testSuite.T(), syscall.EACCES, fsErr)
+}
+
 func (testSuite *ErrorMapping) TestFileClobberedErrorWithPreconditionErrCfg() {
 	clobberedErr := &gcsfuse_errors.FileClobberedError{
 		Err: fmt.Errorf("some error"),
@@ -93,6 +102,18 @@ func (testSuite *ErrorMapping) TestFileClobberedErrorWithPreconditionErrCfg() {
 	assert.Equal(testSuite.T(), syscall.ESTALE, gotErrno)
 }
 
+func (testSuite *ErrorMapping) TestGenerationMismatchErrorWithoutPreconditionErrCfg() {
+	mismatchErr := &gcsfuse_errors.GenerationMismatchError{
+		Name:       "foo",
+		Generation: 1,
+		Err:        &gcsfuse_errors.FileClobberedError{Err: fmt.Errorf("some error")},
+	}
+
+	gotErrno := errno(mismatchErr, false)
+
+	assert.Equal(testSuite.T(), syscall.ESTALE, gotErrno)
+}
+
 func (testSuite *ErrorMapping) TestFileClobberedErrorWithoutPreconditionErrCfg() {
 	clobberedErr := &gcsfuse_errors.FileClobberedError{
 		Err: fmt.Errorf("some error"),
@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"golang.org/x/sys/unix"
+)
+
+// The directory in which the kernel exposes the state of each fuse connection,
+// by the minor device number of the mount. Requires fusectl to be mounted.
+var fuseConnectionsDir = "/sys/fs/fuse/connections"
+
+// defaultMaxBackground is the max_background requested by the fuse library
+// when mounting.
+const defaultMaxBackground = 12
+
+// OpStats counts the file system ops gcsfuse has started processing.
+type OpStats struct {
+	started  atomic.Int64
+	inFlight atomic.Int64
+}
+
+func (s *OpStats) begin() {
+	s.started.Add(1)
+	s.inFlight.Add(1)
+}
+
+func (s *OpStats) end() {
+	s.inFlight.Add(-1)
+}
+
+// Activity returns the number of ops started so far, and the number of them
+// still being processed.
+func (s *OpStats) Activity() (started, inFlight int64) {
+	return s.started.Load(), s.inFlight.Load()
+}
+
+// MonitorKernelQueue periodically records how many file system ops of the fuse
+// mount at mountPoint are queued in the kernel without gcsfuse having started
+// processing them, along with the time ops spend in the queue, until ctx is
+// done.
+//
+// The kernel only exposes the number of ops it is waiting on, which includes
+// the ops being processed by gcsfuse, and not when each op was queued. The
+// queue depth is the difference between the former and the ops in flight in
+// opStats, and the time in the queue is estimated from the depth and the rate
+// at which gcsfuse starts processing ops (Little's law). Together with
+// fs/ops_latency, which is the time in gcsfuse, this tells whether slow ops
+// are due to gcsfuse not keeping up with the kernel or to slow processing.
+//
+// Returns an error if the kernel doesn't expose the state of the mount, e.g.
+// because fusectl isn't mounted.
+func MonitorKernelQueue(
+	ctx context.Context,
+	mountPoint string,
+	interval time.Duration,
+	opStats *OpStats,
+	metricHandle common.MetricHandle) error {
+	connectionDir, err := connectionDir(mountPoint)
+	if err != nil {
+		return err
+	}
+
+	waitingFile := filepath.Join(connectionDir, "waiting")
+	if _, err := readWaiting(waitingFile); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastStarted := opStats.started.Load()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			waiting, err := readWaiting(waitingFile)
+			if err != nil {
+				// The file system has been unmounted.
+				return
+			}
+			started := opStats.started.Load()
+			recordKernelQueue(ctx, metricHandle, waiting-opStats.inFlight.Load(), started-lastStarted, interval)
+			lastStarted = started
+		}
+	}()
+
+	return nil
+}
+
+// connectionDir returns the directory exposing the state of the fuse mount at
+// mountPoint.
+func connectionDir(mountPoint string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(mountPoint, &st); err != nil {
+		return "", fmt.Errorf("stat %q: %w", mountPoint, err)
+	}
+	return filepath.Join(fuseConnectionsDir, strconv.FormatUint(uint64(unix.Minor(st.Dev)), 10)), nil
+}
+
+// SetMaxBackground sets the number of asynchronous ops, e.g. reads of the
+// page cache, the kernel sends to gcsfuse concurrently for the fuse mount at
+// mountPoint, instead of the 12 requested by the fuse library, along with the
+// congestion threshold at 3/4 of it as the kernel does by default.
+//
+// Returns an error if the kernel doesn't expose the state of the mount, e.g.
+// because fusectl isn't mounted, or it can't be written, e.g. because gcsfuse
+// doesn't run as root.
+func SetMaxBackground(mountPoint string, maxBackground int64) error {
+	connectionDir, err := connectionDir(mountPoint)
+	if err != nil {
+		return err
+	}
+
+	// The congestion threshold can't exceed max_background, so it's written
+	// last when increasing and first when decreasing.
+	writes := []struct {
+		name  string
+		value int64
+	}{
+		{"max_background", maxBackground},
+		{"congestion_threshold", maxBackground * 3 / 4},
+	}
+	if maxBackground < defaultMaxBackground {
+		writes[0], writes[1] = writes[1], writes[0]
+	}
+	for _, w := range writes {
+		if err := os.WriteFile(filepath.Join(connectionDir, w.name), []byte(strconv.FormatInt(w.value, 10)), 0644); err != nil {
+			return fmt.Errorf("setting fuse connection %s: %w", w.name, err)
+		}
+	}
+	return nil
+}
+
+func readWaiting(waitingFile string) (int64, error) {
+	contents, err := os.ReadFile(waitingFile)
+	if err != nil {
+		return 0, fmt.Errorf("reading fuse connection state: %w", err)
+	}
+
+	waiting, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %q: %w", waitingFile, err)
+	}
+	return waiting, nil
+}
+
+// recordKernelQueue records the kernel queue depth and, if any ops were
+// started in the interval, the estimated time spent in the queue.
+func recordKernelQueue(ctx context.Context, metricHandle common.MetricHandle, depth int64, started int64, interval time.Duration) {
+	// The two counts aren't read at the same instant, so the difference can
+	// briefly be negative.
+	depth = max(depth, 0)
+	metricHandle.OpsKernelQueueDepth(ctx, depth, nil)
+
+	if started > 0 {
+		metricHandle.OpsKernelQueueLatency(ctx, float64(depth*interval.Microseconds()/started), nil)
+	}
+}
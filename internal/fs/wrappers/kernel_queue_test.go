@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type kernelQueueMetricHandle struct {
+	common.MetricHandle
+	mu        sync.Mutex
+	depths    []int64
+	latencies []float64
+}
+
+func (m *kernelQueueMetricHandle) OpsKernelQueueDepth(_ context.Context, value int64, _ []common.MetricAttr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depths = append(m.depths, value)
+}
+
+func (m *kernelQueueMetricHandle) OpsKernelQueueLatency(_ context.Context, value float64, _ []common.MetricAttr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies = append(m.latencies, value)
+}
+
+func TestRecordKernelQueue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		depth             int64
+		started           int64
+		expectedDepth     int64
+		expectedLatencies []float64
+	}{
+		{
+			name:              "queued_ops",
+			depth:             10,
+			started:           100,
+			expectedDepth:     10,
+			expectedLatencies: []float64{100000},
+		},
+		{
+			name:              "no_started_ops",
+			depth:             10,
+			started:           0,
+			expectedDepth:     10,
+			expectedLatencies: nil,
+		},
+		{
+			name:              "negative_depth",
+			depth:             -1,
+			started:           5,
+			expectedDepth:     0,
+			expectedLatencies: []float64{0},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := &kernelQueueMetricHandle{MetricHandle: common.NewNoopMetrics()}
+
+			recordKernelQueue(context.Background(), m, tc.depth, tc.started, time.Second)
+
+			assert.Equal(t, []int64{tc.expectedDepth}, m.depths)
+			assert.Equal(t, tc.expectedLatencies, m.latencies)
+		})
+	}
+}
+
+func TestMonitorKernelQueue(t *testing.T) {
+	mountPoint := t.TempDir()
+	var st unix.Stat_t
+	require.NoError(t, unix.Stat(mountPoint, &st))
+	connectionsDir := t.TempDir()
+	connectionDir := filepath.Join(connectionsDir, strconv.FormatUint(uint64(unix.Minor(st.Dev)), 10))
+	require.NoError(t, os.Mkdir(connectionDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(connectionDir, "waiting"), []byte("7\n"), 0644))
+	defer func(dir string) { fuseConnectionsDir = dir }(fuseConnectionsDir)
+	fuseConnectionsDir = connectionsDir
+	opStats := &OpStats{}
+	opStats.begin()
+	opStats.begin()
+	m := &kernelQueueMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := MonitorKernelQueue(ctx, mountPoint, time.Millisecond, opStats, m)
+
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.depths) > 0 && m.depths[0] == 5
+	}, time.Second, time.Millisecond)
+}
+
+func TestMonitorKernelQueueWithoutFusectl(t *testing.T) {
+	defer func(dir string) { fuseConnectionsDir = dir }(fuseConnectionsDir)
+	fuseConnectionsDir = t.TempDir()
+
+	err := MonitorKernelQueue(context.Background(), t.TempDir(), time.Millisecond, &OpStats{}, common.NewNoopMetrics())
+
+	assert.Error(t, err)
+}
+
+func TestSetMaxBackground(t *testing.T) {
+	tests := []struct {
+		name                        string
+		maxBackground               int64
+		expectedCongestionThreshold string
+	}{
+		{"increase", 64, "48"},
+		{"decrease", 4, "3"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mountPoint := t.TempDir()
+			var st unix.Stat_t
+			require.NoError(t, unix.Stat(mountPoint, &st))
+			connectionsDir := t.TempDir()
+			connectionDir := filepath.Join(connectionsDir, strconv.FormatUint(uint64(unix.Minor(st.Dev)), 10))
+			require.NoError(t, os.Mkdir(connectionDir, 0755))
+			defer func(dir string) { fuseConnectionsDir = dir }(fuseConnectionsDir)
+			fuseConnectionsDir = connectionsDir
+
+			err := SetMaxBackground(mountPoint, tc.maxBackground)
+
+			require.NoError(t, err)
+			maxBackground, err := os.ReadFile(filepath.Join(connectionDir, "max_background"))
+			require.NoError(t, err)
+			assert.Equal(t, strconv.FormatInt(tc.maxBackground, 10), string(maxBackground))
+			congestionThreshold, err := os.ReadFile(filepath.Join(connectionDir, "congestion_threshold"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCongestionThreshold, string(congestionThreshold))
+		})
+	}
+}
+
+func TestSetMaxBackgroundWithoutFusectl(t *testing.T) {
+	defer func(dir string) { fuseConnectionsDir = dir }(fuseConnectionsDir)
+	fuseConnectionsDir = t.TempDir()
+
+	err := SetMaxBackground(t.TempDir(), 64)
+
+	assert.Error(t, err)
+}
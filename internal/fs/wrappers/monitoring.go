@@ -240,17 +240,20 @@ func recordOp(ctx context.Context, metricHandle common.MetricHandle, method stri
 }
 
 // WithMonitoring takes a FileSystem, returns a FileSystem with monitoring
-// on the counts of requests per API.
-func WithMonitoring(fs fuseutil.FileSystem, metricHandle common.MetricHandle) fuseutil.FileSystem {
+// on the counts of requests per API. The ops are also counted in opStats, if
+// not nil.
+func WithMonitoring(fs fuseutil.FileSystem, metricHandle common.MetricHandle, opStats *OpStats) fuseutil.FileSystem {
 	return &monitoring{
 		wrapped:      fs,
 		metricHandle: metricHandle,
+		opStats:      opStats,
 	}
 }
 
 type monitoring struct {
 	wrapped      fuseutil.FileSystem
 	metricHandle common.MetricHandle
+	opStats      *OpStats
 }
 
 func (fs *monitoring) Destroy() {
@@ -260,6 +263,11 @@ func (fs *monitoring) Destroy() {
 type wrappedCall func(ctx context.Context) error
 
 func (fs *monitoring) invokeWrapped(ctx context.Context, opName string, w wrappedCall) error {
+	if fs.opStats != nil {
+		fs.opStats.begin()
+		defer fs.opStats.end()
+	}
+
 	startTime := time.Now()
 	err := w(ctx)
 	recordOp(ctx, fs.metricHandle, opName, startTime, err)
@@ -0,0 +1,268 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// WithOpLogging takes a FileSystem, returns a FileSystem logging, with their
+// path, details, outcome and latency:
+//
+//   - the ops in the debug scope set with logger.SetDebugScope, at DEBUG
+//     severity whatever the severity of the logs;
+//   - the ops slower than logger.SlowOpThreshold, with logger.LogSlowOp.
+//
+// pathOf returns the path of an inode, relative to the root of the mount, and
+// is only called for the ops which may be logged.
+//
+// The ops only referring to a handle have an empty path, so they're only in
+// the debug scopes without a path prefix.
+func WithOpLogging(wrapped fuseutil.FileSystem, pathOf func(fuseops.InodeID) string) fuseutil.FileSystem {
+	return &opLogging{
+		wrapped: wrapped,
+		pathOf:  pathOf,
+	}
+}
+
+type opLogging struct {
+	wrapped fuseutil.FileSystem
+	pathOf  func(fuseops.InodeID) string
+}
+
+func (fs *opLogging) Destroy() {
+	fs.wrapped.Destroy()
+}
+
+// childPath returns the path of the child with the supplied name of the
+// directory parent.
+func (fs *opLogging) childPath(parent fuseops.InodeID, name string) func() string {
+	return func() string {
+		// The paths of the directories end with a slash, but for the root.
+		return fs.pathOf(parent) + name
+	}
+}
+
+func (fs *opLogging) inodePath(id fuseops.InodeID) func() string {
+	return func() string { return fs.pathOf(id) }
+}
+
+func noPath() string { return "" }
+
+func handleAttr(h fuseops.HandleID) slog.Attr {
+	return slog.Uint64("handle", uint64(h))
+}
+
+// byteRangeAttrs returns the attributes of the byte range of a read or write.
+func byteRangeAttrs(h fuseops.HandleID, offset int64, size int) []slog.Attr {
+	return []slog.Attr{handleAttr(h), slog.Int64("offset", offset), slog.Int("size", size)}
+}
+
+// invokeWrapped calls w, logging the op if it's in the debug scope or slow.
+// path and details are only called then.
+func (fs *opLogging) invokeWrapped(ctx context.Context, opName string, path func() string, details func() []slog.Attr, w wrappedCall) error {
+	var p string
+	resolved := false
+	scoped := false
+	if s := logger.CurrentDebugScope(); s != nil && s.MatchesOp(opName) {
+		p, resolved = path(), true
+		scoped = s.MatchesPath(p)
+	}
+	threshold := logger.SlowOpThreshold()
+	if !scoped && threshold == 0 {
+		return w(ctx)
+	}
+
+	start := time.Now()
+	err := w(ctx)
+	latency := time.Since(start)
+	slow := threshold > 0 && latency >= threshold
+	if !scoped && !slow {
+		return err
+	}
+
+	if !resolved {
+		p = path()
+	}
+	var attrs []slog.Attr
+	if details != nil {
+		attrs = details()
+	}
+	if scoped {
+		var d string
+		if len(attrs) > 0 {
+			parts := make([]string, 0, len(attrs))
+			for _, a := range attrs {
+				parts = append(parts, a.String())
+			}
+			d = " (" + strings.Join(parts, " ") + ")"
+		}
+		outcome := "OK"
+		if err != nil {
+			outcome = err.Error()
+		}
+		logger.ScopedDebugf("%s %q%s: %s in %v", opName, p, d, outcome, latency)
+	}
+	if slow {
+		attrs = append([]slog.Attr{slog.String("path", p)}, attrs...)
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.LogSlowOp(opName, latency, attrs...)
+	}
+	return err
+}
+
+func (fs *opLogging) StatFS(ctx context.Context, op *fuseops.StatFSOp) error {
+	return fs.invokeWrapped(ctx, "StatFS", noPath, nil, func(ctx context.Context) error { return fs.wrapped.StatFS(ctx, op) })
+}
+
+func (fs *opLogging) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	return fs.invokeWrapped(ctx, "LookUpInode", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.LookUpInode(ctx, op) })
+}
+
+func (fs *opLogging) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	return fs.invokeWrapped(ctx, "GetInodeAttributes", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.GetInodeAttributes(ctx, op) })
+}
+
+func (fs *opLogging) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	return fs.invokeWrapped(ctx, "SetInodeAttributes", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.SetInodeAttributes(ctx, op) })
+}
+
+func (fs *opLogging) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	// The inode may be gone once forgotten, and the ops are too frequent to be
+	// of interest.
+	return fs.wrapped.ForgetInode(ctx, op)
+}
+
+func (fs *opLogging) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) error {
+	return fs.wrapped.BatchForget(ctx, op)
+}
+
+func (fs *opLogging) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	return fs.invokeWrapped(ctx, "MkDir", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.MkDir(ctx, op) })
+}
+
+func (fs *opLogging) MkNode(ctx context.Context, op *fuseops.MkNodeOp) error {
+	return fs.invokeWrapped(ctx, "MkNode", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.MkNode(ctx, op) })
+}
+
+func (fs *opLogging) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	return fs.invokeWrapped(ctx, "CreateFile", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.CreateFile(ctx, op) })
+}
+
+func (fs *opLogging) CreateLink(ctx context.Context, op *fuseops.CreateLinkOp) error {
+	return fs.invokeWrapped(ctx, "CreateLink", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.CreateLink(ctx, op) })
+}
+
+func (fs *opLogging) CreateSymlink(ctx context.Context, op *fuseops.CreateSymlinkOp) error {
+	details := func() []slog.Attr { return []slog.Attr{slog.String("target", op.Target)} }
+	return fs.invokeWrapped(ctx, "CreateSymlink", fs.childPath(op.Parent, op.Name), details, func(ctx context.Context) error { return fs.wrapped.CreateSymlink(ctx, op) })
+}
+
+func (fs *opLogging) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	details := func() []slog.Attr { return []slog.Attr{slog.String("to", fs.childPath(op.NewParent, op.NewName)())} }
+	return fs.invokeWrapped(ctx, "Rename", fs.childPath(op.OldParent, op.OldName), details, func(ctx context.Context) error { return fs.wrapped.Rename(ctx, op) })
+}
+
+func (fs *opLogging) RmDir(ctx context.Context, op *fuseops.RmDirOp) error {
+	return fs.invokeWrapped(ctx, "RmDir", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.RmDir(ctx, op) })
+}
+
+func (fs *opLogging) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return fs.invokeWrapped(ctx, "Unlink", fs.childPath(op.Parent, op.Name), nil, func(ctx context.Context) error { return fs.wrapped.Unlink(ctx, op) })
+}
+
+func (fs *opLogging) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	return fs.invokeWrapped(ctx, "OpenDir", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.OpenDir(ctx, op) })
+}
+
+func (fs *opLogging) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	details := func() []slog.Attr {
+		return []slog.Attr{handleAttr(op.Handle), slog.Uint64("offset", uint64(op.Offset))}
+	}
+	return fs.invokeWrapped(ctx, "ReadDir", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.ReadDir(ctx, op) })
+}
+
+func (fs *opLogging) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) error {
+	details := func() []slog.Attr { return []slog.Attr{handleAttr(op.Handle)} }
+	return fs.invokeWrapped(ctx, "ReleaseDirHandle", noPath, details, func(ctx context.Context) error { return fs.wrapped.ReleaseDirHandle(ctx, op) })
+}
+
+func (fs *opLogging) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	return fs.invokeWrapped(ctx, "OpenFile", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.OpenFile(ctx, op) })
+}
+
+func (fs *opLogging) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	details := func() []slog.Attr {
+		return append(byteRangeAttrs(op.Handle, op.Offset, int(op.Size)), slog.Int("bytes_read", op.BytesRead))
+	}
+	return fs.invokeWrapped(ctx, "ReadFile", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.ReadFile(ctx, op) })
+}
+
+func (fs *opLogging) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	details := func() []slog.Attr { return byteRangeAttrs(op.Handle, op.Offset, len(op.Data)) }
+	return fs.invokeWrapped(ctx, "WriteFile", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.WriteFile(ctx, op) })
+}
+
+func (fs *opLogging) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	details := func() []slog.Attr { return []slog.Attr{handleAttr(op.Handle)} }
+	return fs.invokeWrapped(ctx, "SyncFile", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.SyncFile(ctx, op) })
+}
+
+func (fs *opLogging) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) error {
+	details := func() []slog.Attr { return []slog.Attr{handleAttr(op.Handle)} }
+	return fs.invokeWrapped(ctx, "FlushFile", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.FlushFile(ctx, op) })
+}
+
+func (fs *opLogging) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) error {
+	details := func() []slog.Attr { return []slog.Attr{handleAttr(op.Handle)} }
+	return fs.invokeWrapped(ctx, "ReleaseFileHandle", noPath, details, func(ctx context.Context) error { return fs.wrapped.ReleaseFileHandle(ctx, op) })
+}
+
+func (fs *opLogging) ReadSymlink(ctx context.Context, op *fuseops.ReadSymlinkOp) error {
+	return fs.invokeWrapped(ctx, "ReadSymlink", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.ReadSymlink(ctx, op) })
+}
+
+func (fs *opLogging) RemoveXattr(ctx context.Context, op *fuseops.RemoveXattrOp) error {
+	details := func() []slog.Attr { return []slog.Attr{slog.String("name", op.Name)} }
+	return fs.invokeWrapped(ctx, "RemoveXattr", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.RemoveXattr(ctx, op) })
+}
+
+func (fs *opLogging) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) error {
+	details := func() []slog.Attr { return []slog.Attr{slog.String("name", op.Name)} }
+	return fs.invokeWrapped(ctx, "GetXattr", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.GetXattr(ctx, op) })
+}
+
+func (fs *opLogging) ListXattr(ctx context.Context, op *fuseops.ListXattrOp) error {
+	return fs.invokeWrapped(ctx, "ListXattr", fs.inodePath(op.Inode), nil, func(ctx context.Context) error { return fs.wrapped.ListXattr(ctx, op) })
+}
+
+func (fs *opLogging) SetXattr(ctx context.Context, op *fuseops.SetXattrOp) error {
+	details := func() []slog.Attr { return []slog.Attr{slog.String("name", op.Name)} }
+	return fs.invokeWrapped(ctx, "SetXattr", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.SetXattr(ctx, op) })
+}
+
+func (fs *opLogging) Fallocate(ctx context.Context, op *fuseops.FallocateOp) error {
+	details := func() []slog.Attr { return byteRangeAttrs(op.Handle, int64(op.Offset), int(op.Length)) }
+	return fs.invokeWrapped(ctx, "Fallocate", fs.inodePath(op.Inode), details, func(ctx context.Context) error { return fs.wrapped.Fallocate(ctx, op) })
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpLogging_DebugScope(t *testing.T) {
+	paths := map[fuseops.InodeID]string{
+		fuseops.RootInodeID: "",
+		2:                   "models/",
+		3:                   "models/a.bin",
+		4:                   "data/b.bin",
+	}
+	var resolved []fuseops.InodeID
+	fs := WithOpLogging(&fuseutil.NotImplementedFileSystem{}, func(id fuseops.InodeID) string {
+		resolved = append(resolved, id)
+		return paths[id]
+	})
+	ctx := context.Background()
+	defer logger.SetDebugScope(nil)
+	tests := []struct {
+		name             string
+		scope            *logger.DebugScope
+		call             func() error
+		expectedResolved []fuseops.InodeID
+	}{
+		{"no_scope", nil, func() error { return fs.ReadFile(ctx, &fuseops.ReadFileOp{Inode: 3}) }, nil},
+		{"op_in_scope", &logger.DebugScope{PathPrefix: "models/", Ops: []string{"ReadFile"}}, func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Inode: 3})
+		}, []fuseops.InodeID{3}},
+		{"op_out_of_scope", &logger.DebugScope{PathPrefix: "models/", Ops: []string{"ReadFile"}}, func() error {
+			return fs.WriteFile(ctx, &fuseops.WriteFileOp{Inode: 3})
+		}, nil},
+		{"path_out_of_scope", &logger.DebugScope{PathPrefix: "models/"}, func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Inode: 4})
+		}, []fuseops.InodeID{4}},
+		{"child_op", &logger.DebugScope{PathPrefix: "models/"}, func() error {
+			return fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 2, Name: "a.bin"})
+		}, []fuseops.InodeID{2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logger.SetDebugScope(tc.scope)
+			resolved = nil
+
+			err := tc.call()
+
+			// The errors of the wrapped file system are returned as they are.
+			assert.Equal(t, syscall.ENOSYS, err)
+			assert.Equal(t, tc.expectedResolved, resolved)
+		})
+	}
+}
+
+// slowFileSystem takes delay to read the files.
+type slowFileSystem struct {
+	fuseutil.NotImplementedFileSystem
+	delay time.Duration
+}
+
+func (fs *slowFileSystem) ReadFile(context.Context, *fuseops.ReadFileOp) error {
+	time.Sleep(fs.delay)
+	return nil
+}
+
+func TestOpLogging_SlowOps(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "gcsfuse.log")
+	require.NoError(t, logger.InitLogFile(cfg.LoggingConfig{
+		FilePath:  cfg.ResolvedPath(logFile),
+		Format:    "text",
+		Severity:  "INFO",
+		LogRotate: cfg.LogRotateLoggingConfig{MaxFileSizeMb: 1},
+		SlowOps:   cfg.SlowOpsLoggingConfig{Threshold: 10 * time.Millisecond, SampleRate: 1},
+	}))
+	defer func() { require.NoError(t, logger.InitLogFile(cfg.LoggingConfig{Format: "text", Severity: "INFO"})) }()
+	fs := WithOpLogging(&slowFileSystem{delay: 20 * time.Millisecond}, func(fuseops.InodeID) string { return "models/a.bin" })
+
+	err := fs.ReadFile(context.Background(), &fuseops.ReadFileOp{Inode: 3, Handle: 7, Offset: 4096, Size: 1024})
+	require.NoError(t, err)
+	// Fast ops aren't logged.
+	err = fs.LookUpInode(context.Background(), &fuseops.LookUpInodeOp{Parent: 2, Name: "b.bin"})
+	require.Equal(t, syscall.ENOSYS, err)
+
+	logs, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Regexp(t, `^time="[a-zA-Z0-9/:. ]{26}" severity=INFO message="slow op" op=ReadFile latency=\S+ path=models/a.bin handle=7 offset=4096 size=1024 bytes_read=0\n$`, string(logs))
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// The directory in which the kernel exposes the backing device info of each
+// mount, by the device number of the mount.
+var bdiDir = "/sys/class/bdi"
+
+// SetMaxReadAhead sets the most KiB the kernel reads ahead of the sequential
+// reads of the files of the fuse mount at mountPoint, by writing it directly
+// to the backing device info of the mount.
+//
+// Returns an error if gcsfuse has no permission to write it, i.e. doesn't run
+// as root or with CAP_DAC_OVERRIDE, or it can't be written, e.g. because
+// sysfs is mounted read-only in a container.
+func SetMaxReadAhead(mountPoint string, maxReadAheadKb int64) error {
+	if !canWriteSysfs() {
+		return errors.New("setting the read-ahead requires gcsfuse to run as root or with CAP_DAC_OVERRIDE")
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(mountPoint, &st); err != nil {
+		return fmt.Errorf("stat %q: %w", mountPoint, err)
+	}
+	readAheadFile := filepath.Join(bdiDir, fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)), "read_ahead_kb")
+	err := os.WriteFile(readAheadFile, []byte(strconv.FormatInt(maxReadAheadKb, 10)), 0644)
+	if errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("sysfs is read-only, e.g. in a container: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("setting the read-ahead: %w", err)
+	}
+	return nil
+}
+
+// canWriteSysfs returns true if gcsfuse may write the sysfs files, which are
+// only writable by root.
+func canWriteSysfs() bool {
+	if os.Geteuid() == 0 {
+		return true
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false
+	}
+	return data[unix.CAP_DAC_OVERRIDE/32].Effective&(1<<(unix.CAP_DAC_OVERRIDE%32)) != 0
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSetMaxReadAhead(t *testing.T) {
+	if !canWriteSysfs() {
+		t.Skip("requires root or CAP_DAC_OVERRIDE")
+	}
+	mountPoint := t.TempDir()
+	var st unix.Stat_t
+	require.NoError(t, unix.Stat(mountPoint, &st))
+	dir := t.TempDir()
+	mountBdiDir := filepath.Join(dir, fmt.Sprintf("%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev)))
+	require.NoError(t, os.Mkdir(mountBdiDir, 0755))
+	defer func(dir string) { bdiDir = dir }(bdiDir)
+	bdiDir = dir
+
+	err := SetMaxReadAhead(mountPoint, 16384)
+
+	require.NoError(t, err)
+	readAhead, err := os.ReadFile(filepath.Join(mountBdiDir, "read_ahead_kb"))
+	require.NoError(t, err)
+	assert.Equal(t, "16384", string(readAhead))
+}
+
+func TestSetMaxReadAheadWithoutBdi(t *testing.T) {
+	defer func(dir string) { bdiDir = dir }(bdiDir)
+	bdiDir = t.TempDir()
+
+	err := SetMaxReadAhead(t.TempDir(), 16384)
+
+	assert.Error(t, err)
+}
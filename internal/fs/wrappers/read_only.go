@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// WithReadOnly wraps a FileSystem so that all the ops which would modify it
+// fail with EROFS without reaching the wrapped FileSystem.
+//
+// The kernel already rejects these ops for file systems mounted with "-o ro",
+// so this only guards against the ops it lets through, and against gcsfuse
+// bugs.
+func WithReadOnly(wrapped fuseutil.FileSystem) fuseutil.FileSystem {
+	return &readOnly{
+		FileSystem: wrapped,
+	}
+}
+
+type readOnly struct {
+	fuseutil.FileSystem
+}
+
+func (fs *readOnly) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	if op.Uid != nil || op.Gid != nil || op.Size != nil || op.Mode != nil || op.Atime != nil || op.Mtime != nil {
+		return syscall.EROFS
+	}
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
+
+func (fs *readOnly) MkDir(_ context.Context, _ *fuseops.MkDirOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) MkNode(_ context.Context, _ *fuseops.MkNodeOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) CreateFile(_ context.Context, _ *fuseops.CreateFileOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) CreateLink(_ context.Context, _ *fuseops.CreateLinkOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) CreateSymlink(_ context.Context, _ *fuseops.CreateSymlinkOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) Rename(_ context.Context, _ *fuseops.RenameOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) RmDir(_ context.Context, _ *fuseops.RmDirOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) Unlink(_ context.Context, _ *fuseops.UnlinkOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	if !op.OpenFlags.IsReadOnly() {
+		return syscall.EROFS
+	}
+	return fs.FileSystem.OpenFile(ctx, op)
+}
+
+func (fs *readOnly) WriteFile(_ context.Context, _ *fuseops.WriteFileOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) RemoveXattr(_ context.Context, _ *fuseops.RemoveXattrOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) SetXattr(_ context.Context, _ *fuseops.SetXattrOp) error {
+	return syscall.EROFS
+}
+
+func (fs *readOnly) Fallocate(_ context.Context, _ *fuseops.FallocateOp) error {
+	return syscall.EROFS
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+	// The wrapped file system fails every op with ENOSYS, so that the ops which
+	// are let through can be told apart.
+	fs := WithReadOnly(&fuseutil.NotImplementedFileSystem{})
+	ctx := context.Background()
+	size := uint64(0)
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr error
+	}{
+		{"MkDir", func() error { return fs.MkDir(ctx, &fuseops.MkDirOp{}) }, syscall.EROFS},
+		{"MkNode", func() error { return fs.MkNode(ctx, &fuseops.MkNodeOp{}) }, syscall.EROFS},
+		{"CreateFile", func() error { return fs.CreateFile(ctx, &fuseops.CreateFileOp{}) }, syscall.EROFS},
+		{"CreateLink", func() error { return fs.CreateLink(ctx, &fuseops.CreateLinkOp{}) }, syscall.EROFS},
+		{"CreateSymlink", func() error { return fs.CreateSymlink(ctx, &fuseops.CreateSymlinkOp{}) }, syscall.EROFS},
+		{"Rename", func() error { return fs.Rename(ctx, &fuseops.RenameOp{}) }, syscall.EROFS},
+		{"RmDir", func() error { return fs.RmDir(ctx, &fuseops.RmDirOp{}) }, syscall.EROFS},
+		{"Unlink", func() error { return fs.Unlink(ctx, &fuseops.UnlinkOp{}) }, syscall.EROFS},
+		{"WriteFile", func() error { return fs.WriteFile(ctx, &fuseops.WriteFileOp{}) }, syscall.EROFS},
+		{"SetXattr", func() error { return fs.SetXattr(ctx, &fuseops.SetXattrOp{}) }, syscall.EROFS},
+		{"RemoveXattr", func() error { return fs.RemoveXattr(ctx, &fuseops.RemoveXattrOp{}) }, syscall.EROFS},
+		{"Fallocate", func() error { return fs.Fallocate(ctx, &fuseops.FallocateOp{}) }, syscall.EROFS},
+		{"OpenFile_for_writing", func() error {
+			return fs.OpenFile(ctx, &fuseops.OpenFileOp{OpenFlags: syscall.O_RDWR})
+		}, syscall.EROFS},
+		{"OpenFile_for_reading", func() error {
+			return fs.OpenFile(ctx, &fuseops.OpenFileOp{OpenFlags: syscall.O_RDONLY})
+		}, syscall.ENOSYS},
+		{"SetInodeAttributes_size", func() error {
+			return fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Size: &size})
+		}, syscall.EROFS},
+		{"SetInodeAttributes_nothing", func() error {
+			return fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{})
+		}, syscall.ENOSYS},
+		{"ReadFile", func() error { return fs.ReadFile(ctx, &fuseops.ReadFileOp{}) }, syscall.ENOSYS},
+		{"LookUpInode", func() error { return fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{}) }, syscall.ENOSYS},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectedErr, tc.call())
+		})
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"math"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// VirtualDirName is the name of the directory at the root of the mount
+// containing the virtual files. It hides the objects with the same prefix.
+const VirtualDirName = ".gcsfuse"
+
+// The IDs of the virtual inodes and handles are allocated from the top of the
+// ID space, while the wrapped file system allocates its own from the bottom.
+const (
+	virtualDirInodeID = fuseops.InodeID(math.MaxUint64)
+	firstVirtualID    = math.MaxUint64 - 1<<32
+)
+
+// A VirtualFile is a read-only file generated by gcsfuse rather than backed by
+// an object, e.g. to expose the state of the mount to its operators.
+type VirtualFile struct {
+	Name string
+
+	// Content returns the current content of the file. It is called when the
+	// file is looked up or opened, and must be safe for concurrent use.
+	Content func() []byte
+}
+
+// WithVirtualFiles wraps a FileSystem so that the files are exposed, read-only,
+// in the VirtualDirName directory at the root of the mount. The directory
+// isn't listed in the root, and its files can't be modified, nor can other
+// files be created in it.
+//
+// The content of a file is snapshotted when it's opened, and read with direct
+// IO, since its size may change between the lookups.
+func WithVirtualFiles(wrapped fuseutil.FileSystem, files []VirtualFile, uid, gid uint32, filePerms, dirPerms os.FileMode) fuseutil.FileSystem {
+	return &virtualFiles{
+		FileSystem: wrapped,
+		files:      files,
+		uid:        uid,
+		gid:        gid,
+		filePerms:  filePerms &^ 0222,
+		dirPerms:   dirPerms &^ 0222,
+		handles:    make(map[fuseops.HandleID][]byte),
+		nextHandle: math.MaxUint64,
+	}
+}
+
+type virtualFiles struct {
+	fuseutil.FileSystem
+	files     []VirtualFile
+	uid       uint32
+	gid       uint32
+	filePerms os.FileMode
+	dirPerms  os.FileMode
+
+	mu sync.Mutex
+
+	// The content of the open virtual files, or nil for the virtual directory.
+	//
+	// GUARDED_BY(mu)
+	handles map[fuseops.HandleID][]byte
+
+	// GUARDED_BY(mu)
+	nextHandle fuseops.HandleID
+}
+
+func isVirtualInode(id fuseops.InodeID) bool {
+	return id >= firstVirtualID
+}
+
+func isVirtualHandle(id fuseops.HandleID) bool {
+	return id >= firstVirtualID
+}
+
+func fileInodeID(i int) fuseops.InodeID {
+	return virtualDirInodeID - 1 - fuseops.InodeID(i)
+}
+
+// file returns the virtual file of the inode, or nil for the virtual directory.
+func (fs *virtualFiles) file(id fuseops.InodeID) *VirtualFile {
+	if id == virtualDirInodeID {
+		return nil
+	}
+	return &fs.files[int(virtualDirInodeID-1-id)]
+}
+
+// isVirtualEntry returns true if the entry name of the parent is the virtual
+// directory or one of its files.
+func isVirtualEntry(parent fuseops.InodeID, name string) bool {
+	return isVirtualInode(parent) || (parent == fuseops.RootInodeID && name == VirtualDirName)
+}
+
+func (fs *virtualFiles) attributes(id fuseops.InodeID) fuseops.InodeAttributes {
+	now := time.Now()
+	attrs := fuseops.InodeAttributes{
+		Nlink: 1,
+		Uid:   fs.uid,
+		Gid:   fs.gid,
+		Atime: now,
+		Mtime: now,
+		Ctime: now,
+	}
+	if f := fs.file(id); f != nil {
+		attrs.Size = uint64(len(f.Content()))
+		attrs.Mode = fs.filePerms
+	} else {
+		attrs.Mode = fs.dirPerms | os.ModeDir
+	}
+	return attrs
+}
+
+func (fs *virtualFiles) newHandle(content []byte) fuseops.HandleID {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	h := fs.nextHandle
+	fs.nextHandle--
+	fs.handles[h] = content
+	return h
+}
+
+func (fs *virtualFiles) releaseHandle(h fuseops.HandleID) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	delete(fs.handles, h)
+}
+
+func (fs *virtualFiles) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.LookUpInode(ctx, op)
+	}
+	if op.Parent == fuseops.RootInodeID {
+		op.Entry.Child = virtualDirInodeID
+		op.Entry.Attributes = fs.attributes(virtualDirInodeID)
+		return nil
+	}
+	for i, f := range fs.files {
+		if f.Name == op.Name {
+			op.Entry.Child = fileInodeID(i)
+			op.Entry.Attributes = fs.attributes(op.Entry.Child)
+			return nil
+		}
+	}
+	return syscall.ENOENT
+}
+
+func (fs *virtualFiles) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.GetInodeAttributes(ctx, op)
+	}
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *virtualFiles) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.SetInodeAttributes(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.ForgetInode(ctx, op)
+	}
+	return nil
+}
+
+func (fs *virtualFiles) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) error {
+	var entries []fuseops.BatchForgetEntry
+	for _, e := range op.Entries {
+		if !isVirtualInode(e.Inode) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	op.Entries = entries
+	return fs.FileSystem.BatchForget(ctx, op)
+}
+
+func (fs *virtualFiles) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.MkDir(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) MkNode(ctx context.Context, op *fuseops.MkNodeOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.MkNode(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.CreateFile(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) CreateLink(ctx context.Context, op *fuseops.CreateLinkOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) && !isVirtualInode(op.Target) {
+		return fs.FileSystem.CreateLink(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) CreateSymlink(ctx context.Context, op *fuseops.CreateSymlinkOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.CreateSymlink(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	if !isVirtualEntry(op.OldParent, op.OldName) && !isVirtualEntry(op.NewParent, op.NewName) {
+		return fs.FileSystem.Rename(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) RmDir(ctx context.Context, op *fuseops.RmDirOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.RmDir(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	if !isVirtualEntry(op.Parent, op.Name) {
+		return fs.FileSystem.Unlink(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.OpenDir(ctx, op)
+	}
+	op.Handle = fs.newHandle(nil)
+	return nil
+}
+
+func (fs *virtualFiles) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.ReadDir(ctx, op)
+	}
+	for i := int(op.Offset); i < len(fs.files); i++ {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], fuseutil.Dirent{
+			Offset: fuseops.DirOffset(i + 1),
+			Inode:  fileInodeID(i),
+			Name:   fs.files[i].Name,
+			Type:   fuseutil.DT_File,
+		})
+		if n == 0 {
+			break
+		}
+		op.BytesRead += n
+	}
+	return nil
+}
+
+func (fs *virtualFiles) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.ReleaseDirHandle(ctx, op)
+	}
+	fs.releaseHandle(op.Handle)
+	return nil
+}
+
+func (fs *virtualFiles) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.OpenFile(ctx, op)
+	}
+	if !op.OpenFlags.IsReadOnly() {
+		return syscall.EACCES
+	}
+	op.Handle = fs.newHandle(fs.file(op.Inode).Content())
+	op.UseDirectIO = true
+	return nil
+}
+
+func (fs *virtualFiles) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.ReadFile(ctx, op)
+	}
+	fs.mu.Lock()
+	content := fs.handles[op.Handle]
+	fs.mu.Unlock()
+	if op.Offset < int64(len(content)) {
+		op.BytesRead = copy(op.Dst, content[op.Offset:])
+	}
+	return nil
+}
+
+func (fs *virtualFiles) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.WriteFile(ctx, op)
+	}
+	return syscall.EBADF
+}
+
+func (fs *virtualFiles) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.SyncFile(ctx, op)
+	}
+	return nil
+}
+
+func (fs *virtualFiles) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.FlushFile(ctx, op)
+	}
+	return nil
+}
+
+func (fs *virtualFiles) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) error {
+	if !isVirtualHandle(op.Handle) {
+		return fs.FileSystem.ReleaseFileHandle(ctx, op)
+	}
+	fs.releaseHandle(op.Handle)
+	return nil
+}
+
+func (fs *virtualFiles) ReadSymlink(ctx context.Context, op *fuseops.ReadSymlinkOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.ReadSymlink(ctx, op)
+	}
+	return syscall.EINVAL
+}
+
+func (fs *virtualFiles) RemoveXattr(ctx context.Context, op *fuseops.RemoveXattrOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.RemoveXattr(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.GetXattr(ctx, op)
+	}
+	return syscall.ENODATA
+}
+
+func (fs *virtualFiles) ListXattr(ctx context.Context, op *fuseops.ListXattrOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.ListXattr(ctx, op)
+	}
+	return nil
+}
+
+func (fs *virtualFiles) SetXattr(ctx context.Context, op *fuseops.SetXattrOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.SetXattr(ctx, op)
+	}
+	return syscall.EPERM
+}
+
+func (fs *virtualFiles) Fallocate(ctx context.Context, op *fuseops.FallocateOp) error {
+	if !isVirtualInode(op.Inode) {
+		return fs.FileSystem.Fallocate(ctx, op)
+	}
+	return syscall.EPERM
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrappers
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVirtualFiles(content *string) fuseutil.FileSystem {
+	// The wrapped file system fails every op with ENOSYS, so that the ops which
+	// are let through can be told apart.
+	return WithVirtualFiles(&fuseutil.NotImplementedFileSystem{}, []VirtualFile{
+		{Name: "errors", Content: func() []byte { return []byte(*content) }},
+	}, 1, 2, 0644, 0755)
+}
+
+func lookUpVirtualFile(t *testing.T, fs fuseutil.FileSystem) fuseops.ChildInodeEntry {
+	t.Helper()
+	ctx := context.Background()
+	dirOp := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: VirtualDirName}
+	require.NoError(t, fs.LookUpInode(ctx, dirOp))
+	fileOp := &fuseops.LookUpInodeOp{Parent: dirOp.Entry.Child, Name: "errors"}
+	require.NoError(t, fs.LookUpInode(ctx, fileOp))
+	return fileOp.Entry
+}
+
+func TestVirtualFiles_LookUp(t *testing.T) {
+	t.Parallel()
+	content := "error"
+	fs := newTestVirtualFiles(&content)
+	ctx := context.Background()
+
+	dirOp := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: VirtualDirName}
+	require.NoError(t, fs.LookUpInode(ctx, dirOp))
+	fileOp := &fuseops.LookUpInodeOp{Parent: dirOp.Entry.Child, Name: "errors"}
+	require.NoError(t, fs.LookUpInode(ctx, fileOp))
+
+	assert.Equal(t, os.ModeDir|0555, dirOp.Entry.Attributes.Mode)
+	assert.Equal(t, os.FileMode(0444), fileOp.Entry.Attributes.Mode)
+	assert.Equal(t, uint64(len(content)), fileOp.Entry.Attributes.Size)
+	assert.Equal(t, uint32(1), fileOp.Entry.Attributes.Uid)
+	assert.Equal(t, uint32(2), fileOp.Entry.Attributes.Gid)
+	assert.Equal(t, syscall.ENOENT, fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: dirOp.Entry.Child, Name: "unknown"}))
+	assert.Equal(t, syscall.ENOSYS, fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: "errors"}))
+}
+
+func TestVirtualFiles_Read(t *testing.T) {
+	t.Parallel()
+	content := "first error\n"
+	fs := newTestVirtualFiles(&content)
+	ctx := context.Background()
+	entry := lookUpVirtualFile(t, fs)
+	openOp := &fuseops.OpenFileOp{Inode: entry.Child, OpenFlags: syscall.O_RDONLY}
+	require.NoError(t, fs.OpenFile(ctx, openOp))
+	// The content of an open file doesn't change.
+	content += "second error\n"
+
+	readOp := &fuseops.ReadFileOp{Inode: entry.Child, Handle: openOp.Handle, Offset: 6, Dst: make([]byte, 100)}
+	require.NoError(t, fs.ReadFile(ctx, readOp))
+
+	assert.True(t, openOp.UseDirectIO)
+	assert.Equal(t, "error\n", string(readOp.Dst[:readOp.BytesRead]))
+	readOp = &fuseops.ReadFileOp{Inode: entry.Child, Handle: openOp.Handle, Offset: 100, Dst: make([]byte, 100)}
+	require.NoError(t, fs.ReadFile(ctx, readOp))
+	assert.Equal(t, 0, readOp.BytesRead)
+	assert.NoError(t, fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: openOp.Handle}))
+}
+
+func TestVirtualFiles_ReadDir(t *testing.T) {
+	t.Parallel()
+	content := ""
+	fs := newTestVirtualFiles(&content)
+	ctx := context.Background()
+	dirOp := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: VirtualDirName}
+	require.NoError(t, fs.LookUpInode(ctx, dirOp))
+	openOp := &fuseops.OpenDirOp{Inode: dirOp.Entry.Child}
+	require.NoError(t, fs.OpenDir(ctx, openOp))
+
+	readOp := &fuseops.ReadDirOp{Inode: dirOp.Entry.Child, Handle: openOp.Handle, Dst: make([]byte, 1024)}
+	require.NoError(t, fs.ReadDir(ctx, readOp))
+	expected := make([]byte, 1024)
+	n := fuseutil.WriteDirent(expected, fuseutil.Dirent{Offset: 1, Inode: lookUpVirtualFile(t, fs).Child, Name: "errors", Type: fuseutil.DT_File})
+
+	assert.Equal(t, expected[:n], readOp.Dst[:readOp.BytesRead])
+	readOp = &fuseops.ReadDirOp{Inode: dirOp.Entry.Child, Handle: openOp.Handle, Offset: 1, Dst: make([]byte, 1024)}
+	require.NoError(t, fs.ReadDir(ctx, readOp))
+	assert.Equal(t, 0, readOp.BytesRead)
+	assert.NoError(t, fs.ReleaseDirHandle(ctx, &fuseops.ReleaseDirHandleOp{Handle: openOp.Handle}))
+}
+
+func TestVirtualFiles_Modifications(t *testing.T) {
+	t.Parallel()
+	content := ""
+	fs := newTestVirtualFiles(&content)
+	ctx := context.Background()
+	entry := lookUpVirtualFile(t, fs)
+	dirOp := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: VirtualDirName}
+	require.NoError(t, fs.LookUpInode(ctx, dirOp))
+	dir := dirOp.Entry.Child
+	size := uint64(0)
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr error
+	}{
+		{"OpenFile_for_writing", func() error {
+			return fs.OpenFile(ctx, &fuseops.OpenFileOp{Inode: entry.Child, OpenFlags: syscall.O_RDWR})
+		}, syscall.EACCES},
+		{"SetInodeAttributes", func() error {
+			return fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Inode: entry.Child, Size: &size})
+		}, syscall.EPERM},
+		{"CreateFile_in_virtual_dir", func() error {
+			return fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: dir, Name: "new"})
+		}, syscall.EPERM},
+		{"MkDir_virtual_dir", func() error {
+			return fs.MkDir(ctx, &fuseops.MkDirOp{Parent: fuseops.RootInodeID, Name: VirtualDirName})
+		}, syscall.EPERM},
+		{"Unlink_virtual_file", func() error {
+			return fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: dir, Name: "errors"})
+		}, syscall.EPERM},
+		{"RmDir_virtual_dir", func() error {
+			return fs.RmDir(ctx, &fuseops.RmDirOp{Parent: fuseops.RootInodeID, Name: VirtualDirName})
+		}, syscall.EPERM},
+		{"Rename_into_virtual_dir", func() error {
+			return fs.Rename(ctx, &fuseops.RenameOp{OldParent: fuseops.RootInodeID, OldName: "a", NewParent: dir, NewName: "a"})
+		}, syscall.EPERM},
+		{"Rename_other_file", func() error {
+			return fs.Rename(ctx, &fuseops.RenameOp{OldParent: fuseops.RootInodeID, OldName: "a", NewParent: fuseops.RootInodeID, NewName: "b"})
+		}, syscall.ENOSYS},
+		{"CreateFile_other_file", func() error {
+			return fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: fuseops.RootInodeID, Name: "new"})
+		}, syscall.ENOSYS},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedErr, tc.call())
+		})
+	}
+}
+
+func TestVirtualFiles_ForgetsOnlyWrappedInodes(t *testing.T) {
+	t.Parallel()
+	content := ""
+	fs := newTestVirtualFiles(&content)
+	ctx := context.Background()
+	entry := lookUpVirtualFile(t, fs)
+
+	assert.NoError(t, fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: entry.Child, N: 1}))
+	assert.NoError(t, fs.BatchForget(ctx, &fuseops.BatchForgetOp{Entries: []fuseops.BatchForgetEntry{{Inode: entry.Child, N: 1}}}))
+	assert.Equal(t, syscall.ENOSYS, fs.BatchForget(ctx, &fuseops.BatchForgetOp{Entries: []fuseops.BatchForgetEntry{{Inode: entry.Child, N: 1}, {Inode: 2, N: 1}}}))
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	. "github.com/jacobsa/ogletest"
+)
+
+type WriteQuotaTest struct {
+	fsTest
+}
+
+func init() {
+	RegisterTestSuite(&WriteQuotaTest{})
+}
+
+func (t *WriteQuotaTest) SetUpTestSuite() {
+	t.serverCfg.NewConfig = &cfg.Config{
+		FileCache: defaultFileCacheConfig(),
+		MetadataCache: cfg.MetadataCacheConfig{
+			StatCacheMaxSizeMb: 32,
+			TtlSecs:            60,
+			TypeCacheMaxSizeMb: 4,
+		},
+		Write: cfg.WriteConfig{
+			QuotaMb:      1,
+			QuotaObjects: 2,
+		},
+	}
+	t.fsTest.SetUpTestSuite()
+}
+
+func (t *WriteQuotaTest) WritesAndCreationsBeyondTheQuotaFail() {
+	var err error
+
+	// Fill the quota of bytes with a file.
+	fileName := path.Join(mntDir, "foo")
+	f, err := os.Create(fileName)
+	AssertEq(nil, err)
+	defer f.Close()
+	_, err = f.Write(make([]byte, 1<<20))
+	AssertEq(nil, err)
+
+	_, err = f.Write([]byte("taco"))
+	ExpectTrue(errors.Is(err, syscall.EDQUOT))
+
+	// Fill the quota of objects with a directory.
+	err = os.Mkdir(path.Join(mntDir, "dir"), 0700)
+	AssertEq(nil, err)
+
+	err = os.Mkdir(path.Join(mntDir, "other_dir"), 0700)
+	ExpectTrue(errors.Is(err, syscall.EDQUOT))
+	_, err = os.Create(path.Join(mntDir, "bar"))
+	ExpectTrue(errors.Is(err, syscall.EDQUOT))
+}
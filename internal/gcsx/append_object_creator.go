@@ -118,6 +118,18 @@ func (oc *appendObjectCreator) Create(
 		}
 	}()
 
+	o, err = oc.compose(ctx, srcObject, tmp.Name, tmp.Generation, mtime)
+	return
+}
+
+// compose composes the temporary object tmpName onto srcObject, failing with
+// *gcs.PreconditionError if the source generation is no longer current.
+func (oc *appendObjectCreator) compose(
+	ctx context.Context,
+	srcObject *gcs.Object,
+	tmpName string,
+	tmpGeneration int64,
+	mtime *time.Time) (o *gcs.Object, err error) {
 	MetadataMap := make(map[string]string)
 
 	/* Copy Metadata fields from src object to new object generated by compose. */
@@ -143,8 +155,8 @@ func (oc *appendObjectCreator) Create(
 				},
 
 				gcs.ComposeSource{
-					Name:       tmp.Name,
-					Generation: tmp.Generation,
+					Name:       tmpName,
+					Generation: tmpGeneration,
 				},
 			},
 			Metadata:           MetadataMap,
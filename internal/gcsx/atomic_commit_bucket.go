@@ -0,0 +1,640 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+// NewAtomicCommitBucket creates a wrapper bucket that makes the files written
+// under any of the supplied prefixes appear to other readers of the bucket all
+// at once.
+//
+// New objects under the prefixes are created with stagingPrefix prepended to
+// their names, and are published to their final names only when an object
+// named sentinel (e.g. "_SUCCESS") is created in their directory. Until then,
+// they are visible under their final names through this bucket only. Objects
+// in subdirectories are published by the sentinel of their own directory.
+//
+// Publishing isn't atomic: the staged objects are copied to their final names
+// one at a time, and the sentinel is created only once all of them are. Readers
+// which wait for the sentinel see them all, other readers may see only some.
+//
+// Published objects keep being known by the generations they were staged with
+// through this bucket, e.g. for the inodes of their files to keep writing them,
+// until they are written again.
+//
+// Which objects are staged is tracked in memory, so objects staged but not
+// published before the process exits are left behind under stagingPrefix.
+// Folder renames don't move staged objects.
+func NewAtomicCommitBucket(
+	prefixes []string,
+	sentinel string,
+	stagingPrefix string,
+	b gcs.Bucket) gcs.Bucket {
+	return &atomicCommitBucket{
+		Bucket:        b,
+		prefixes:      prefixes,
+		sentinel:      sentinel,
+		stagingPrefix: stagingPrefix,
+		staged:        make(map[string]*gcs.MinObject),
+		published:     make(map[string]*publishedObject),
+	}
+}
+
+type atomicCommitBucket struct {
+	gcs.Bucket
+	prefixes      []string
+	sentinel      string
+	stagingPrefix string
+
+	mu sync.Mutex
+	// The staged objects, by final name. The names of the objects themselves are
+	// the final names too.
+	//
+	// GUARDED_BY(mu)
+	staged map[string]*gcs.MinObject
+
+	// The objects published and not written since, by final name.
+	//
+	// GUARDED_BY(mu)
+	published map[string]*publishedObject
+}
+
+// publishedObject is an object published under its final name.
+type publishedObject struct {
+	// The staged object it was copied from, under the final name.
+	staged *gcs.MinObject
+
+	// The generation and meta-generation of the copy.
+	generation     int64
+	metaGeneration int64
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+func (b *atomicCommitBucket) stagedName(n string) string {
+	return b.stagingPrefix + n
+}
+
+// inScope returns true if new objects with the given name are subject to the
+// atomic commit.
+func (b *atomicCommitBucket) inScope(n string) bool {
+	if strings.HasPrefix(n, b.stagingPrefix) || strings.HasSuffix(n, "/") {
+		return false
+	}
+
+	for _, p := range b.prefixes {
+		if strings.HasPrefix(n, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *atomicCommitBucket) isSentinel(n string) bool {
+	return b.inScope(n) && path.Base(n) == b.sentinel
+}
+
+func (b *atomicCommitBucket) isStaged(n string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.staged[n]
+	return ok
+}
+
+// shouldStage returns true if an object with the given name and generation
+// precondition is to be written to the staging area.
+func (b *atomicCommitBucket) shouldStage(n string, generationPrecondition *int64) bool {
+	if !b.inScope(n) || b.isSentinel(n) {
+		return false
+	}
+
+	return b.isStaged(n) || (generationPrecondition != nil && *generationPrecondition == 0)
+}
+
+// name returns the name to use in the wrapped bucket for the object with the
+// given final name.
+func (b *atomicCommitBucket) name(n string) string {
+	if b.isStaged(n) {
+		return b.stagedName(n)
+	}
+	return n
+}
+
+func (b *atomicCommitBucket) record(finalName string, o *gcs.MinObject) {
+	m := *o
+	m.Name = finalName
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.staged[finalName] = &m
+	delete(b.published, finalName)
+}
+
+func (b *atomicCommitBucket) forget(finalName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.staged, finalName)
+}
+
+// written forgets the published object with the given final name, once
+// another one has been written or it has been deleted.
+func (b *atomicCommitBucket) written(finalName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.published, finalName)
+}
+
+// current returns the generation and meta-generation to use in the wrapped
+// bucket for the supplied ones of the object with the given final name: those
+// of its published copy if they are the ones it was staged with, or the
+// supplied ones otherwise.
+func (b *atomicCommitBucket) current(n string, gen int64, metaGen *int64) (int64, *int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.published[n]
+	if !ok || gen == 0 || gen != p.staged.Generation {
+		return gen, metaGen
+	}
+	if metaGen != nil && *metaGen == p.staged.MetaGeneration {
+		metaGen = &p.metaGeneration
+	}
+	return p.generation, metaGen
+}
+
+// currentPreconditions makes the preconditions of the given request, of an
+// object not to be staged, apply to the current generation of the object.
+func (b *atomicCommitBucket) currentPreconditions(req *gcs.CreateObjectRequest) {
+	if req.GenerationPrecondition == nil {
+		return
+	}
+
+	var g int64
+	g, req.MetaGenerationPrecondition = b.current(req.Name, *req.GenerationPrecondition, req.MetaGenerationPrecondition)
+	req.GenerationPrecondition = &g
+}
+
+// asStaged shows the given object, if it is a published copy not modified
+// since, with the generation and meta-generation it was staged with.
+func (b *atomicCommitBucket) asStaged(m *gcs.MinObject) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.published[m.Name]
+	if ok && m.Generation == p.generation && m.MetaGeneration == p.metaGeneration {
+		m.Generation = p.staged.Generation
+		m.MetaGeneration = p.staged.MetaGeneration
+	}
+}
+
+// publish copies the objects staged directly in the given directory to their
+// final names and deletes the staged copies, one at a time. If it fails, the
+// objects not published yet stay staged.
+func (b *atomicCommitBucket) publish(ctx context.Context, dir string) error {
+	b.mu.Lock()
+	var objects []*gcs.MinObject
+	for n, o := range b.staged {
+		if strings.HasPrefix(n, dir) && !strings.Contains(n[len(dir):], "/") {
+			objects = append(objects, o)
+		}
+	}
+	b.mu.Unlock()
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	for _, o := range objects {
+		c, err := b.Bucket.CopyObject(ctx, &gcs.CopyObjectRequest{
+			SrcName:       b.stagedName(o.Name),
+			DstName:       o.Name,
+			SrcGeneration: o.Generation,
+		})
+		if err != nil {
+			return fmt.Errorf("CopyObject %q: %w", o.Name, err)
+		}
+
+		err = b.Bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{
+			Name:       b.stagedName(o.Name),
+			Generation: o.Generation,
+		})
+		if err != nil {
+			return fmt.Errorf("DeleteObject %q: %w", b.stagedName(o.Name), err)
+		}
+
+		// Keep the entry if the object was staged again in the meantime.
+		b.mu.Lock()
+		if b.staged[o.Name] == o {
+			delete(b.staged, o.Name)
+			b.published[o.Name] = &publishedObject{
+				staged:         o,
+				generation:     c.Generation,
+				metaGeneration: c.MetaGeneration,
+			}
+		}
+		b.mu.Unlock()
+	}
+
+	if len(objects) > 0 {
+		logger.Infof("Published %d staged objects under %q", len(objects), dir)
+	}
+	return nil
+}
+
+func (b *atomicCommitBucket) publishIfSentinel(ctx context.Context, n string) error {
+	if !b.isSentinel(n) {
+		return nil
+	}
+
+	return b.publish(ctx, n[:strings.LastIndex(n, "/")+1])
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *atomicCommitBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	mReq := new(gcs.ReadObjectRequest)
+	*mReq = *req
+	mReq.Name = b.name(req.Name)
+	mReq.Generation, _ = b.current(req.Name, req.Generation, nil)
+
+	return b.Bucket.NewReader(ctx, mReq)
+}
+
+func (b *atomicCommitBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if err := b.publishIfSentinel(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	mReq := new(gcs.CreateObjectRequest)
+	*mReq = *req
+	if !b.shouldStage(req.Name, req.GenerationPrecondition) {
+		b.currentPreconditions(mReq)
+		o, err := b.Bucket.CreateObject(ctx, mReq)
+		if o != nil {
+			b.written(req.Name)
+		}
+		return o, err
+	}
+
+	mReq.Name = b.stagedName(req.Name)
+
+	o, err := b.Bucket.CreateObject(ctx, mReq)
+	if o != nil {
+		o.Name = req.Name
+		b.record(req.Name, storageutil.ConvertObjToMinObject(o))
+	}
+
+	return o, err
+}
+
+func (b *atomicCommitBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if err := b.publishIfSentinel(ctx, req.Name); err != nil {
+		return nil, err
+	}
+
+	mReq := new(gcs.CreateObjectRequest)
+	*mReq = *req
+	if !b.shouldStage(req.Name, req.GenerationPrecondition) {
+		b.currentPreconditions(mReq)
+		return b.Bucket.CreateObjectChunkWriter(ctx, mReq, chunkSize, callBack)
+	}
+
+	mReq.Name = b.stagedName(req.Name)
+
+	return b.Bucket.CreateObjectChunkWriter(ctx, mReq, chunkSize, callBack)
+}
+
+func (b *atomicCommitBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	o, err := b.Bucket.FinalizeUpload(ctx, w)
+	if o != nil {
+		if strings.HasPrefix(o.Name, b.stagingPrefix) {
+			o.Name = strings.TrimPrefix(o.Name, b.stagingPrefix)
+			b.record(o.Name, o)
+		} else {
+			b.written(o.Name)
+		}
+	}
+
+	return o, err
+}
+
+func (b *atomicCommitBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	// The source object of a copy from another bucket isn't staged here.
+	fromOtherBucket := req.SrcBucketName != "" && req.SrcBucketName != b.Bucket.Name()
+	srcStaged := !fromOtherBucket && b.isStaged(req.SrcName)
+	dstStaged := b.inScope(req.DstName) && !b.isSentinel(req.DstName) &&
+		(srcStaged || b.isStaged(req.DstName))
+
+	mReq := new(gcs.CopyObjectRequest)
+	*mReq = *req
+	if !fromOtherBucket {
+		mReq.SrcName = b.name(req.SrcName)
+		mReq.SrcGeneration, mReq.SrcMetaGenerationPrecondition =
+			b.current(req.SrcName, req.SrcGeneration, req.SrcMetaGenerationPrecondition)
+	}
+	if dstStaged {
+		mReq.DstName = b.stagedName(req.DstName)
+	} else if req.DstGenerationPrecondition != nil {
+		g, _ := b.current(req.DstName, *req.DstGenerationPrecondition, nil)
+		mReq.DstGenerationPrecondition = &g
+	}
+
+	o, err := b.Bucket.CopyObject(ctx, mReq)
+	if o != nil {
+		o.Name = req.DstName
+		if dstStaged {
+			b.record(req.DstName, storageutil.ConvertObjToMinObject(o))
+		} else {
+			b.written(req.DstName)
+		}
+	}
+
+	return o, err
+}
+
+func (b *atomicCommitBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	dstStaged := b.shouldStage(req.DstName, req.DstGenerationPrecondition)
+
+	mReq := new(gcs.ComposeObjectsRequest)
+	*mReq = *req
+	if dstStaged {
+		mReq.DstName = b.stagedName(req.DstName)
+	} else if req.DstGenerationPrecondition != nil {
+		var g int64
+		g, mReq.DstMetaGenerationPrecondition =
+			b.current(req.DstName, *req.DstGenerationPrecondition, req.DstMetaGenerationPrecondition)
+		mReq.DstGenerationPrecondition = &g
+	}
+
+	mReq.Sources = nil
+	for _, s := range req.Sources {
+		s.Generation, _ = b.current(s.Name, s.Generation, nil)
+		s.Name = b.name(s.Name)
+		mReq.Sources = append(mReq.Sources, s)
+	}
+
+	o, err := b.Bucket.ComposeObjects(ctx, mReq)
+	if o != nil {
+		o.Name = req.DstName
+		if dstStaged {
+			b.record(req.DstName, storageutil.ConvertObjToMinObject(o))
+		} else {
+			b.written(req.DstName)
+		}
+	}
+
+	return o, err
+}
+
+func (b *atomicCommitBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	mReq := new(gcs.StatObjectRequest)
+	*mReq = *req
+	mReq.Name = b.name(req.Name)
+
+	m, e, err := b.Bucket.StatObject(ctx, mReq)
+	if m != nil {
+		m.Name = req.Name
+		b.asStaged(m)
+	}
+
+	return m, e, err
+}
+
+// continuationTokenSeparator separates the last name of a page from the
+// continuation token of the wrapped bucket in the continuation tokens returned
+// by ListObjects. GCS object names can't contain it.
+const continuationTokenSeparator = "\n"
+
+func (b *atomicCommitBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	// The staged objects of this page come after the last name of the previous
+	// one.
+	mReq := new(gcs.ListObjectsRequest)
+	*mReq = *req
+	var after string
+	if i := strings.Index(req.ContinuationToken, continuationTokenSeparator); i >= 0 {
+		after = req.ContinuationToken[:i]
+		mReq.ContinuationToken = req.ContinuationToken[i+len(continuationTokenSeparator):]
+	}
+
+	l, err := b.Bucket.ListObjects(ctx, mReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The staged objects up to the last name of this page are shown in it, or
+	// all the remaining ones in the last page.
+	var last string
+	if l.ContinuationToken != "" {
+		last = after
+		if n := len(l.MinObjects); n > 0 {
+			last = max(last, l.MinObjects[n-1].Name)
+		}
+		if n := len(l.CollapsedRuns); n > 0 {
+			last = max(last, l.CollapsedRuns[n-1])
+		}
+		l.ContinuationToken = last + continuationTokenSeparator + l.ContinuationToken
+	}
+	inPage := func(n string) bool {
+		return n > after && (l.ContinuationToken == "" || n <= last)
+	}
+
+	// Hide the staging area.
+	var objects []*gcs.MinObject
+	for _, o := range l.MinObjects {
+		if !strings.HasPrefix(o.Name, b.stagingPrefix) {
+			b.asStaged(o)
+			objects = append(objects, o)
+		}
+	}
+	l.MinObjects = objects
+
+	var runs []string
+	for _, r := range l.CollapsedRuns {
+		if !strings.HasPrefix(r, b.stagingPrefix) {
+			runs = append(runs, r)
+		}
+	}
+	l.CollapsedRuns = runs
+
+	// Show the staged objects under their final names.
+	b.addStaged(req, l, inPage)
+
+	return l, nil
+}
+
+// addStaged adds the staged objects matching the request whose entries belong
+// in the page, according to inPage, to the listing, keeping it sorted.
+func (b *atomicCommitBucket) addStaged(req *gcs.ListObjectsRequest, l *gcs.Listing, inPage func(n string) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.staged) == 0 {
+		return
+	}
+
+	objects := make(map[string]int)
+	for i, o := range l.MinObjects {
+		objects[o.Name] = i
+	}
+	runs := make(map[string]bool)
+	for _, r := range l.CollapsedRuns {
+		runs[r] = true
+	}
+
+	for n, o := range b.staged {
+		if !strings.HasPrefix(n, req.Prefix) {
+			continue
+		}
+
+		// Collapse the names containing the delimiter after the prefix.
+		rest := n[len(req.Prefix):]
+		if req.Delimiter != "" {
+			if i := strings.Index(rest, req.Delimiter); i >= 0 {
+				r := req.Prefix + rest[:i+len(req.Delimiter)]
+				if !runs[r] && inPage(r) {
+					runs[r] = true
+					l.CollapsedRuns = append(l.CollapsedRuns, r)
+				}
+				continue
+			}
+		}
+
+		if !inPage(n) {
+			continue
+		}
+		m := *o
+		if i, ok := objects[n]; ok {
+			l.MinObjects[i] = &m
+		} else {
+			l.MinObjects = append(l.MinObjects, &m)
+		}
+	}
+
+	sort.Slice(l.MinObjects, func(i, j int) bool { return l.MinObjects[i].Name < l.MinObjects[j].Name })
+	sort.Strings(l.CollapsedRuns)
+}
+
+func (b *atomicCommitBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	staged := b.isStaged(req.Name)
+
+	mReq := new(gcs.UpdateObjectRequest)
+	*mReq = *req
+	mReq.Name = b.name(req.Name)
+	mReq.Generation, mReq.MetaGenerationPrecondition =
+		b.current(req.Name, req.Generation, req.MetaGenerationPrecondition)
+
+	o, err := b.Bucket.UpdateObject(ctx, mReq)
+	if o != nil {
+		o.Name = req.Name
+		if staged {
+			b.record(req.Name, storageutil.ConvertObjToMinObject(o))
+		} else {
+			b.written(req.Name)
+		}
+	}
+
+	return o, err
+}
+
+func (b *atomicCommitBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	mReq := new(gcs.DeleteObjectRequest)
+	*mReq = *req
+	if !b.isStaged(req.Name) {
+		mReq.Generation, mReq.MetaGenerationPrecondition =
+			b.current(req.Name, req.Generation, req.MetaGenerationPrecondition)
+		err := b.Bucket.DeleteObject(ctx, mReq)
+		if err == nil {
+			b.written(req.Name)
+		}
+		return err
+	}
+
+	mReq.Name = b.stagedName(req.Name)
+
+	err := b.Bucket.DeleteObject(ctx, mReq)
+	var notFoundErr *gcs.NotFoundError
+	if err == nil || errors.As(err, &notFoundErr) {
+		b.forget(req.Name)
+	}
+
+	return err
+}
+
+func (b *atomicCommitBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	srcStaged := b.isStaged(req.SrcName)
+	dstStaged := b.inScope(req.DstName) && !b.isSentinel(req.DstName) &&
+		(srcStaged || b.isStaged(req.DstName))
+
+	mReq := new(gcs.MoveObjectRequest)
+	*mReq = *req
+	mReq.SrcName = b.name(req.SrcName)
+	mReq.SrcGeneration, mReq.SrcMetaGenerationPrecondition =
+		b.current(req.SrcName, req.SrcGeneration, req.SrcMetaGenerationPrecondition)
+	if dstStaged {
+		mReq.DstName = b.stagedName(req.DstName)
+	}
+
+	o, err := b.Bucket.MoveObject(ctx, mReq)
+	if o != nil {
+		o.Name = req.DstName
+		if srcStaged {
+			b.forget(req.SrcName)
+		} else {
+			b.written(req.SrcName)
+		}
+		if dstStaged {
+			b.record(req.DstName, storageutil.ConvertObjToMinObject(o))
+		} else {
+			b.written(req.DstName)
+		}
+	}
+
+	return o, err
+}
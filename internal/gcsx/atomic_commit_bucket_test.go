@@ -0,0 +1,241 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
+)
+
+const atomicCommitStagingPrefix = ".staging/"
+
+type AtomicCommitBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	wrapped gcs.Bucket
+	bucket  gcs.Bucket
+}
+
+func TestAtomicCommitBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(AtomicCommitBucketTest))
+}
+
+func (t *AtomicCommitBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.wrapped = fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	t.bucket = gcsx.NewAtomicCommitBucket([]string{"out/"}, "_SUCCESS", atomicCommitStagingPrefix, t.wrapped)
+}
+
+func (t *AtomicCommitBucketTest) createNew(name, contents string) {
+	var preCond int64
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   name,
+		GenerationPrecondition: &preCond,
+		Contents:               strings.NewReader(contents),
+	})
+	require.NoError(t.T(), err)
+}
+
+func (t *AtomicCommitBucketTest) listNames(b gcs.Bucket, prefix, delimiter string) (names []string, runs []string) {
+	l, err := b.ListObjects(t.ctx, &gcs.ListObjectsRequest{Prefix: prefix, Delimiter: delimiter})
+	require.NoError(t.T(), err)
+	for _, o := range l.MinObjects {
+		names = append(names, o.Name)
+	}
+	return names, l.CollapsedRuns
+}
+
+func (t *AtomicCommitBucketTest) TestStagedObjectIsVisibleOnlyThroughBucket() {
+	t.createNew("out/part-0", "taco")
+
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "out/part-0")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "out/part-0", m.Name)
+	names, _ := t.listNames(t.bucket, "out/", "/")
+	assert.Equal(t.T(), []string{"out/part-0"}, names)
+	_, _, err = t.wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t.T(), err, &notFoundErr)
+}
+
+func (t *AtomicCommitBucketTest) TestSentinelPublishesStagedObjectsOfItsDirectory() {
+	t.createNew("out/part-0", "taco")
+	t.createNew("out/part-1", "burrito")
+	t.createNew("out/other/part-0", "enchilada")
+
+	t.createNew("out/_SUCCESS", "")
+
+	// The subdirectory waits for its own sentinel.
+	names, _ := t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{atomicCommitStagingPrefix + "out/other/part-0", "out/_SUCCESS", "out/part-0", "out/part-1"}, names)
+	contents, err := storageutil.ReadObject(t.ctx, t.wrapped, "out/part-1")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	names, runs := t.listNames(t.bucket, "out/", "/")
+	assert.Equal(t.T(), []string{"out/_SUCCESS", "out/part-0", "out/part-1"}, names)
+	assert.Equal(t.T(), []string{"out/other/"}, runs)
+}
+
+func (t *AtomicCommitBucketTest) TestNestedSentinelPublishesOnlyItsDirectory() {
+	t.createNew("out/part-0", "taco")
+	t.createNew("out/other/part-0", "enchilada")
+
+	t.createNew("out/other/_SUCCESS", "")
+
+	names, runs := t.listNames(t.wrapped, "", "/")
+	assert.Empty(t.T(), names)
+	assert.Equal(t.T(), []string{atomicCommitStagingPrefix, "out/"}, runs)
+	names, _ = t.listNames(t.wrapped, "out/", "")
+	assert.Equal(t.T(), []string{"out/other/_SUCCESS", "out/other/part-0"}, names)
+	// The staging area is hidden.
+	names, runs = t.listNames(t.bucket, "", "/")
+	assert.Empty(t.T(), names)
+	assert.Equal(t.T(), []string{"out/"}, runs)
+}
+
+func (t *AtomicCommitBucketTest) TestStagedObjectsAreListedInOrderAcrossPages() {
+	for _, n := range []string{"out/a", "out/c", "out/e", "out/g"} {
+		_, err := storageutil.CreateObject(t.ctx, t.wrapped, n, nil)
+		require.NoError(t.T(), err)
+	}
+	t.createNew("out/b", "")
+	t.createNew("out/d/part-0", "")
+	t.createNew("out/f", "")
+	t.createNew("out/h", "")
+
+	var names, runs []string
+	req := &gcs.ListObjectsRequest{Prefix: "out/", Delimiter: "/", MaxResults: 2}
+	for {
+		l, err := t.bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		for _, o := range l.MinObjects {
+			names = append(names, o.Name)
+		}
+		runs = append(runs, l.CollapsedRuns...)
+		if l.ContinuationToken == "" {
+			break
+		}
+		req.ContinuationToken = l.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{"out/a", "out/b", "out/c", "out/e", "out/f", "out/g", "out/h"}, names)
+	assert.Equal(t.T(), []string{"out/d/"}, runs)
+}
+
+func (t *AtomicCommitBucketTest) TestObjectOutOfScopeIsNotStaged() {
+	t.createNew("in/part-0", "taco")
+
+	names, _ := t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{"in/part-0"}, names)
+}
+
+func (t *AtomicCommitBucketTest) TestDeletedStagedObjectIsNotPublished() {
+	t.createNew("out/part-0", "taco")
+	t.createNew("out/part-1", "burrito")
+
+	err := t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+	t.createNew("out/_SUCCESS", "")
+
+	names, _ := t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{"out/_SUCCESS", "out/part-1"}, names)
+}
+
+func (t *AtomicCommitBucketTest) TestChunkWriterIsStaged() {
+	var preCond int64
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "out/part-0",
+		GenerationPrecondition: &preCond,
+	}, 0, func(_ int64) {})
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("taco"))
+	require.NoError(t.T(), err)
+
+	o, err := t.bucket.FinalizeUpload(t.ctx, w)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "out/part-0", o.Name)
+	names, _ := t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{atomicCommitStagingPrefix + "out/part-0"}, names)
+	t.createNew("out/_SUCCESS", "")
+	names, _ = t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{"out/_SUCCESS", "out/part-0"}, names)
+}
+
+func (t *AtomicCommitBucketTest) TestRewriteOfStagedObjectStaysStaged() {
+	t.createNew("out/part-0", "taco")
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "out/part-0",
+		GenerationPrecondition: &m.Generation,
+		Contents:               strings.NewReader("burrito"),
+	})
+
+	require.NoError(t.T(), err)
+	names, _ := t.listNames(t.wrapped, "", "")
+	assert.Equal(t.T(), []string{atomicCommitStagingPrefix + "out/part-0"}, names)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "out/part-0")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *AtomicCommitBucketTest) TestPublishedObjectKeepsItsStagedGeneration() {
+	t.createNew("out/part-0", "taco")
+	staged, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+	t.createNew("out/_SUCCESS", "")
+
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), staged.Generation, m.Generation)
+	assert.Equal(t.T(), staged.MetaGeneration, m.MetaGeneration)
+	o, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                       "out/part-0",
+		GenerationPrecondition:     &staged.Generation,
+		MetaGenerationPrecondition: &staged.MetaGeneration,
+		Contents:                   strings.NewReader("burrito"),
+	})
+
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.wrapped, "out/part-0")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	// The rewritten object is known by its own generation.
+	m, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "out/part-0"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), o.Generation, m.Generation)
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "out/part-0",
+		GenerationPrecondition: &staged.Generation,
+		Contents:               strings.NewReader("enchilada"),
+	})
+	var preconditionErr *gcs.PreconditionError
+	assert.ErrorAs(t.T(), err, &preconditionErr)
+}
@@ -21,12 +21,18 @@ import (
 	"path"
 	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/canned"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/locality"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/memorygovernor"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/monitor"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/quiesce"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/ratelimit"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/readworker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
@@ -39,9 +45,72 @@ type BucketConfig struct {
 	OnlyDir                            string
 	EgressBandwidthLimitBytesPerSecond float64
 	OpRateLimitHz                      float64
-	StatCacheMaxSizeMB                 uint64
-	StatCacheTTL                       time.Duration
-	EnableMonitoring                   bool
+	MetadataOpRateLimitHz              float64
+	MetadataOpBurst                    int64
+	MaxReadStreamsPerObject            int64
+	// The requests in flight of each class, across all the buckets.
+	MaxConcurrentRequests ratelimit.ConcurrencyLimits
+	StatCacheMaxSizeMB    uint64
+	StatCacheTTL          time.Duration
+	EnableMonitoring      bool
+
+	// The GCS metrics of the buckets of a dynamic mount are annotated with the
+	// bucket_name decided by BucketNames, if set.
+	BucketNames *common.BucketNames
+
+	// The cost drivers of the requests, e.g. the class A and class B
+	// operations, are recorded too, if set.
+	EnableCostEstimation bool
+
+	// The objects are read with the worker processes of ReadWorkers, if set.
+	ReadWorkers *readworker.Pool
+
+	// The stat cache is shed by MemoryGovernor under memory pressure, if set.
+	MemoryGovernor *memorygovernor.Governor
+
+	// The stat cache is emptied by IdleReleaser while the mount is idle, if
+	// set.
+	IdleReleaser *quiesce.Releaser
+
+	// Up to StatCacheTTLJitter is randomly taken off the TTL of each stat
+	// cache entry. If StatCacheBatchRefreshThreshold is non-zero, the stat
+	// cache entries of a directory are refreshed with a single listing once
+	// that many of its children miss the cache within a second.
+	StatCacheTTLJitter             time.Duration
+	StatCacheBatchRefreshThreshold int
+
+	// The complete listings of directories are cached for ListCacheTTL, up to
+	// ListCacheMaxSizeMB, if ListCacheTTL is non-zero.
+	ListCacheTTL       time.Duration
+	ListCacheMaxSizeMB uint64
+
+	// The denials of access to folders, e.g. by the IAM policy of managed
+	// folders, are cached for PermissionDeniedTTL, if it is non-zero.
+	PermissionDeniedTTL time.Duration
+
+	// Once GCS is found unreachable, the requests fail fast until it's
+	// reachable again, probing it every OfflineProbeInterval, and the stat
+	// cache serves its expired entries meanwhile, if it is non-zero.
+	OfflineProbeInterval time.Duration
+
+	// Emulate READDIRPLUS by refreshing the stat cache with the cached
+	// listings too, so that the lookups of the listed entries which follow a
+	// ReadDir are served from it.
+	EmulateReadDirPlus bool
+
+	// The clock of the stat and list caches, and of the object creation rules.
+	// Defaults to the real clock. Tests may supply a simulated clock, shared
+	// with the fake bucket and the inode caches, to expire the entries
+	// deterministically.
+	Clock timeutil.Clock
+
+	// The stat cache of the bucket of a static mount is loaded from the
+	// manifest of its objects at MetadataPrefetchManifest, if set.
+	MetadataPrefetchManifest string
+
+	// The bucket of a static mount is listed recursively on set up to fill its
+	// stat and list caches, if set.
+	MetadataPrefetch *MetadataPrefetchConfig
 
 	// Files backed by on object of length at least AppendThreshold that have
 	// only been appended to (i.e. none of the object's contents have been
@@ -62,6 +131,18 @@ type BucketConfig struct {
 	AppendThreshold          int64
 	ChunkTransferTimeoutSecs int64
 	TmpObjectPrefix          string
+
+	// Storage class and custom time to set on objects newly created under a
+	// prefix, relative to OnlyDir.
+	ObjectCreationRules []cfg.ObjectCreationRule
+
+	// New objects under AtomicCommitPrefixes are written with
+	// AtomicCommitStagingPrefix prepended to their names, and are published to
+	// their final names when an object named AtomicCommitSentinel is created in
+	// their directory.
+	AtomicCommitPrefixes      []string
+	AtomicCommitSentinel      string
+	AtomicCommitStagingPrefix string
 }
 
 // BucketManager manages the lifecycle of buckets.
@@ -79,7 +160,15 @@ type bucketManager struct {
 	storageHandle   storage.StorageHandle
 	sharedStatCache *lru.Cache
 
-	// Garbage collector
+	// Throttles the metadata operations of all the buckets, if
+	// MetadataOpRateLimitHz is set.
+	metadataThrottle ratelimit.Throttle
+
+	// Limits the requests in flight of all the buckets, if
+	// MaxConcurrentRequests has limits.
+	concurrencyLimiter *ratelimit.ConcurrencyLimiter
+
+	// Garbage collector, and background metadata prefetch
 	gcCtx                 context.Context
 	stopGarbageCollecting func()
 }
@@ -88,6 +177,8 @@ func NewBucketManager(config BucketConfig, storageHandle storage.StorageHandle)
 	var c *lru.Cache
 	if config.StatCacheMaxSizeMB > 0 {
 		c = lru.NewCache(util.MiBsToBytes(config.StatCacheMaxSizeMB))
+		config.MemoryGovernor.Register(c)
+		config.IdleReleaser.Register(c)
 	}
 
 	bm := &bucketManager{
@@ -95,10 +186,29 @@ func NewBucketManager(config BucketConfig, storageHandle storage.StorageHandle)
 		storageHandle:   storageHandle,
 		sharedStatCache: c,
 	}
+	if config.MetadataOpRateLimitHz > 0 {
+		bm.metadataThrottle = ratelimit.NewMetadataThrottle(config.MetadataOpRateLimitHz, config.MetadataOpBurst)
+	}
+	bm.concurrencyLimiter = ratelimit.NewConcurrencyLimiter(config.MaxConcurrentRequests)
 	bm.gcCtx, bm.stopGarbageCollecting = context.WithCancel(context.Background())
 	return bm
 }
 
+func (bm *bucketManager) clock() timeutil.Clock {
+	if bm.config.Clock == nil {
+		return timeutil.RealClock()
+	}
+	return bm.config.Clock
+}
+
+func (bm *bucketManager) newListCacheBucket(wrapped gcs.Bucket) gcs.Bucket {
+	return caching.NewListCacheBucket(
+		bm.config.ListCacheTTL,
+		bm.config.ListCacheMaxSizeMB<<20,
+		bm.clock(),
+		wrapped)
+}
+
 func setUpRateLimiting(
 	in gcs.Bucket,
 	opRateLimitHz float64,
@@ -153,6 +263,45 @@ func setUpRateLimiting(
 	return
 }
 
+// loadMetadataPrefetchManifest loads the stat cache of the supplied bucket from
+// a manifest of its objects. The mount goes on without it if it can't be read.
+func loadMetadataPrefetchManifest(b gcs.Bucket, manifest string, onlyDir string) {
+	var prefix string
+	if onlyDir != "" {
+		prefix = path.Clean(onlyDir) + "/"
+	}
+	start := time.Now()
+	n, err := caching.LoadManifest(b, manifest, prefix)
+	if err != nil {
+		logger.Warnf("Loading the metadata-prefetch manifest: %v", err)
+		return
+	}
+	logger.Infof("Loaded %d objects from the metadata-prefetch manifest in %v", n, time.Since(start))
+}
+
+// costAttrs returns the monitor.CostResolver of the bucket, comparing its
+// location against the zone of the VM.
+func (bm *bucketManager) costAttrs(name string) monitor.CostResolver {
+	return func(ctx context.Context) (monitor.CostAttrs, error) {
+		zone, err := locality.VMZone(ctx)
+		if err != nil {
+			return monitor.CostAttrs{}, err
+		}
+		attrs, err := bm.storageHandle.BucketAttrs(ctx, name, bm.config.BillingProject)
+		if err != nil {
+			return monitor.CostAttrs{}, err
+		}
+		var dataLocations []string
+		if attrs.CustomPlacementConfig != nil {
+			dataLocations = attrs.CustomPlacementConfig.DataLocations
+		}
+		return monitor.CostAttrs{
+			EgressLocality: locality.EgressLocality(attrs.Location, attrs.LocationType, dataLocations, zone),
+			StorageClass:   attrs.StorageClass,
+		}, nil
+	}
+}
+
 func (bm *bucketManager) SetUpBucket(
 	ctx context.Context,
 	name string,
@@ -160,6 +309,10 @@ func (bm *bucketManager) SetUpBucket(
 	metricHandle common.MetricHandle,
 ) (sb SyncerBucket, err error) {
 	var b gcs.Bucket
+	if isMultibucketMount {
+		metricHandle = common.WithBucketName(metricHandle, bm.config.BucketNames.Of(name))
+	}
+
 	// Set up the appropriate backing bucket.
 	if name == canned.FakeBucketName {
 		b = canned.MakeFakeBucket(ctx)
@@ -167,8 +320,21 @@ func (bm *bucketManager) SetUpBucket(
 		b = bm.storageHandle.BucketHandle(ctx, name, bm.config.BillingProject)
 	}
 
+	// Shard the reads across the worker processes, if any. This is right above
+	// the backing bucket, so that the reads of the workers are monitored,
+	// limited and throttled like the others.
+	if bm.config.ReadWorkers != nil && name != canned.FakeBucketName {
+		b = readworker.NewShardedBucket(bm.config.ReadWorkers, b)
+	}
+
 	// Enable monitoring.
-	if bm.config.EnableMonitoring {
+	if bm.config.EnableMonitoring && bm.config.EnableCostEstimation {
+		var resolve monitor.CostResolver
+		if name != canned.FakeBucketName {
+			resolve = bm.costAttrs(name)
+		}
+		b = monitor.NewCostEstimatingMonitoringBucket(b, metricHandle, resolve)
+	} else if bm.config.EnableMonitoring {
 		b = monitor.NewMonitoringBucket(b, metricHandle)
 	}
 
@@ -195,6 +361,47 @@ func (bm *bucketManager) SetUpBucket(
 		return
 	}
 
+	// Limit the requests in flight of the mount, if requested. This is below
+	// the throttles, so that the requests waiting for a token aren't in flight,
+	// and below the stat cache, so that cache hits aren't limited.
+	if bm.concurrencyLimiter != nil {
+		b = ratelimit.NewConcurrencyLimitedBucket(bm.concurrencyLimiter, metricHandle, b)
+	}
+
+	// Limit the metadata operations of the mount, if requested. This is
+	// below the stat cache, so that cache hits aren't throttled.
+	if bm.metadataThrottle != nil {
+		b = ratelimit.NewMetadataThrottledBucket(bm.metadataThrottle, b)
+	}
+
+	// Limit the concurrent read streams per object, if requested.
+	if bm.config.MaxReadStreamsPerObject > 0 {
+		b = ratelimit.NewStreamLimitedBucket(bm.config.MaxReadStreamsPerObject, metricHandle, b)
+	}
+
+	// Fail the accesses to the folders recently denied without calling GCS, if
+	// requested.
+	if bm.config.PermissionDeniedTTL != 0 {
+		b = caching.NewPermissionDeniedBucket(bm.config.PermissionDeniedTTL, bm.clock(), b)
+	}
+
+	// Fail the requests fast while GCS is unreachable, if requested. This is
+	// below the stat cache, so that it serves its entries meanwhile, and above
+	// the throttles, so that the failing requests don't wait for a token.
+	var reachability *caching.Reachability
+	if bm.config.OfflineProbeInterval != 0 {
+		reachability = caching.NewReachability(bm.config.OfflineProbeInterval, bm.clock(), metricHandle)
+		b = caching.NewOfflineBucket(reachability, b)
+	}
+
+	// Enable cached listings, if requested. This is above the stat cache, so
+	// that the listings served from the cache don't refresh its entries, unless
+	// READDIRPLUS is emulated.
+	listCacheAboveStatCache := !bm.config.EmulateReadDirPlus
+	if bm.config.ListCacheTTL != 0 && !listCacheAboveStatCache {
+		b = bm.newListCacheBucket(b)
+	}
+
 	// Enable cached StatObject results, if appropriate.
 	if bm.config.StatCacheTTL != 0 && bm.sharedStatCache != nil {
 		var statCache metadata.StatCache
@@ -206,14 +413,39 @@ func (bm *bucketManager) SetUpBucket(
 
 		b = caching.NewFastStatBucket(
 			bm.config.StatCacheTTL,
+			bm.config.StatCacheTTLJitter,
+			bm.config.StatCacheBatchRefreshThreshold,
+			reachability,
 			statCache,
-			timeutil.RealClock(),
+			bm.clock(),
 			b)
+
+		if bm.config.MetadataPrefetchManifest != "" && !isMultibucketMount {
+			loadMetadataPrefetchManifest(b, bm.config.MetadataPrefetchManifest, bm.config.OnlyDir)
+		}
+	}
+
+	if bm.config.ListCacheTTL != 0 && listCacheAboveStatCache {
+		b = bm.newListCacheBucket(b)
 	}
 
 	// Enable content type awareness
 	b = NewContentTypeBucket(b)
 
+	// Stage new objects until their directory is committed, if requested.
+	if len(bm.config.AtomicCommitPrefixes) > 0 {
+		b = NewAtomicCommitBucket(
+			bm.config.AtomicCommitPrefixes,
+			bm.config.AtomicCommitSentinel,
+			bm.config.AtomicCommitStagingPrefix,
+			b)
+	}
+
+	// Apply storage class and custom time rules to new objects, if any.
+	if len(bm.config.ObjectCreationRules) > 0 {
+		b = NewObjectCreationRulesBucket(bm.config.ObjectCreationRules, bm.clock(), b)
+	}
+
 	// Enable Syncer
 	if bm.config.TmpObjectPrefix == "" {
 		err = errors.New("you must set TmpObjectPrefix")
@@ -224,6 +456,7 @@ func (bm *bucketManager) SetUpBucket(
 		bm.config.ChunkTransferTimeoutSecs,
 		bm.config.TmpObjectPrefix,
 		b)
+	sb.metricHandle = metricHandle
 
 	// Fetch bucket type from storage layout api and set bucket type.
 	b.BucketType()
@@ -237,6 +470,20 @@ func (bm *bucketManager) SetUpBucket(
 		}
 	}
 
+	// Prefetch the metadata of the bucket, if requested.
+	if bm.config.MetadataPrefetch != nil && !isMultibucketMount {
+		if bm.config.MetadataPrefetch.Async {
+			go func() {
+				if _, err := PrefetchMetadata(bm.gcCtx, b, *bm.config.MetadataPrefetch, metricHandle); err != nil {
+					logger.Errorf("Metadata-prefetch failed: %v", err)
+				}
+			}()
+		} else if _, err = PrefetchMetadata(ctx, b, *bm.config.MetadataPrefetch, metricHandle); err != nil {
+			err = fmt.Errorf("metadata-prefetch: %w", err)
+			return
+		}
+	}
+
 	// Periodically garbage collect temporary objects
 	go garbageCollect(bm.gcCtx, bm.config.TmpObjectPrefix, sb)
 
@@ -16,13 +16,16 @@ package gcsx
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	. "github.com/jacobsa/ogletest"
+	"github.com/jacobsa/timeutil"
 )
 
 func TestBucketManager(t *testing.T) { RunTests(t) }
@@ -124,6 +127,34 @@ func (t *BucketManagerTest) TestSetUpBucketMethod_IsMultiBucketMountTrue() {
 	ExpectEq(nil, err)
 }
 
+type requestAttrsMetrics struct {
+	common.MetricHandle
+	attrs []common.MetricAttr
+}
+
+func (m *requestAttrsMetrics) GCSRequestCount(_ context.Context, _ int64, attrs []common.MetricAttr) {
+	m.attrs = attrs
+}
+
+func (t *BucketManagerTest) TestSetUpBucketMethod_IsMultiBucketMountTrue_AnnotatesMetricsWithBucketName() {
+	var bm bucketManager
+	bm.storageHandle = t.storageHandle
+	bm.config = BucketConfig{
+		TmpObjectPrefix: "TmpObjectPrefix",
+		BucketNames:     common.NewBucketNames(nil, 1),
+	}
+	bm.gcCtx = context.Background()
+	m := &requestAttrsMetrics{MetricHandle: common.NewNoopMetrics()}
+
+	bucket, err := bm.SetUpBucket(context.Background(), TestBucketName, true, m)
+
+	AssertEq(nil, err)
+	bucket.MetricHandle().GCSRequestCount(context.Background(), 1, nil)
+	AssertEq(1, len(m.attrs))
+	ExpectEq(common.BucketName, m.attrs[0].Key)
+	ExpectEq(TestBucketName, m.attrs[0].Value)
+}
+
 func (t *BucketManagerTest) TestSetUpBucketMethodWhenBucketDoesNotExist() {
 	var bm bucketManager
 	bucketConfig := BucketConfig{
@@ -171,3 +202,49 @@ func (t *BucketManagerTest) TestSetUpBucketMethodWhenBucketDoesNotExist_IsMultiB
 	ExpectEq("error in iterating through objects: storage: bucket doesn't exist", err.Error())
 	ExpectNe(nil, bucket.Syncer)
 }
+
+// statAfterCachedListing lists the directory of an object a second time once
+// its stat cache entry expired, deletes it behind the back of the bucket and
+// stats it, with READDIRPLUS emulated or not.
+func (t *BucketManagerTest) statAfterCachedListing(emulateReadDirPlus bool) error {
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2024, 7, 22, 2, 15, 0, 0, time.Local))
+	bm := NewBucketManager(BucketConfig{
+		StatCacheMaxSizeMB: 1,
+		StatCacheTTL:       10 * time.Second,
+		ListCacheTTL:       time.Minute,
+		ListCacheMaxSizeMB: 1,
+		EmulateReadDirPlus: emulateReadDirPlus,
+		TmpObjectPrefix:    "TmpObjectPrefix",
+		Clock:              clock,
+	}, t.storageHandle)
+	ctx := context.Background()
+	bucket, err := bm.SetUpBucket(ctx, TestBucketName, false, common.NewNoopMetrics())
+	AssertEq(nil, err)
+	_, err = storageutil.CreateObject(ctx, t.bucket, "dir/foo", []byte("taco"))
+	AssertEq(nil, err)
+	listReq := &gcs.ListObjectsRequest{Prefix: "dir/", Delimiter: "/"}
+	_, err = bucket.ListObjects(ctx, listReq)
+	AssertEq(nil, err)
+
+	clock.AdvanceTime(20 * time.Second)
+	_, err = bucket.ListObjects(ctx, listReq)
+	AssertEq(nil, err)
+	AssertEq(nil, t.bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: "dir/foo"}))
+
+	_, _, err = bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: "dir/foo"})
+	return err
+}
+
+func (t *BucketManagerTest) TestCachedListingDoesNotRefreshStatCache() {
+	err := t.statAfterCachedListing(false)
+
+	var notFoundErr *gcs.NotFoundError
+	ExpectTrue(errors.As(err, &notFoundErr))
+}
+
+func (t *BucketManagerTest) TestCachedListingRefreshesStatCacheWhenEmulatingReadDirPlus() {
+	err := t.statAfterCachedListing(true)
+
+	ExpectEq(nil, err)
+}
@@ -114,7 +114,7 @@ func (t *IntegrationTest) create(o *gcs.Object) {
 	AssertEq(nil, err)
 
 	// Use it to create the temp file.
-	t.tf, err = gcsx.NewTempFile(rc, "", &t.clock)
+	t.tf, err = gcsx.NewTempFile(rc, "", &t.clock, nil)
 	AssertEq(nil, err)
 
 	// Close it.
@@ -179,7 +179,7 @@ func (t *IntegrationTest) ReadThenSync() {
 
 func (t *IntegrationTest) SyncEmptyLocalFile() {
 	// Create a temp file and write some contents to it.
-	tf, err := gcsx.NewTempFile(io.NopCloser(strings.NewReader("")), "", &t.clock)
+	tf, err := gcsx.NewTempFile(io.NopCloser(strings.NewReader("")), "", &t.clock, nil)
 	AssertEq(nil, err)
 
 	// Sync should update the object in GCS.
@@ -207,7 +207,7 @@ func (t *IntegrationTest) SyncEmptyLocalFile() {
 
 func (t *IntegrationTest) SyncNonEmptyLocalFile() {
 	// Create a temp file and write some contents to it.
-	tf, err := gcsx.NewTempFile(io.NopCloser(strings.NewReader("")), "", &t.clock)
+	tf, err := gcsx.NewTempFile(io.NopCloser(strings.NewReader("")), "", &t.clock, nil)
 	AssertEq(nil, err)
 	t.clock.AdvanceTime(time.Second)
 	writeTime := t.clock.Now()
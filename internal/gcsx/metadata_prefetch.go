@@ -0,0 +1,242 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// metadataPrefetchProgressInterval is the period of the progress logs of the
+// metadata prefetch.
+var metadataPrefetchProgressInterval = 10 * time.Second
+
+// The max results of the listings of the metadata prefetch, as for the
+// listings of the directories.
+const metadataPrefetchListMaxResults = 5000
+
+// MetadataPrefetchConfig configures the metadata prefetch of the bucket of a
+// static mount on set up.
+type MetadataPrefetchConfig struct {
+	// Run the prefetch in the background rather than during SetUpBucket.
+	Async bool
+
+	// The number of directories listed concurrently.
+	Workers int
+
+	// The prefetch stops, without an error, once it has lasted MaxDuration or
+	// has discovered MaxEntries entries, if non-zero.
+	MaxDuration time.Duration
+	MaxEntries  int64
+}
+
+// metadataPrefetcher lists the directories of a bucket with a pool of workers.
+type metadataPrefetcher struct {
+	bucket         gcs.Bucket
+	config         MetadataPrefetchConfig
+	isHierarchical bool
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// The directories to list, and the number of directories being listed.
+	//
+	// GUARDED_BY(mu)
+	pending []string
+	active  int
+
+	// The entries discovered so far, and the last directory picked up.
+	//
+	// GUARDED_BY(mu)
+	entries int64
+	current string
+
+	// The first listing error, or why the prefetch stopped early.
+	//
+	// GUARDED_BY(mu)
+	err    error
+	capped string
+}
+
+// PrefetchMetadata lists the supplied bucket recursively, directory by
+// directory with config.Workers workers, so that the listed objects and
+// folders fill its stat cache and list cache, if any, without the lookups of a
+// walk through the kernel. The progress is logged every
+// metadataPrefetchProgressInterval, and the entries discovered are recorded
+// by metricHandle along with how the prefetch ended. It returns the number of
+// entries discovered.
+func PrefetchMetadata(ctx context.Context, bucket gcs.Bucket, config MetadataPrefetchConfig, metricHandle common.MetricHandle) (int64, error) {
+	p := &metadataPrefetcher{
+		bucket:         bucket,
+		config:         config,
+		isHierarchical: bucket.BucketType() == gcs.Hierarchical,
+		pending:        []string{""},
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	if config.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.MaxDuration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go p.logProgress(start, done)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(config.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+	close(done)
+
+	result := "completed"
+	switch {
+	case p.err != nil:
+		result = "failed"
+	case p.capped != "":
+		result = "capped"
+	}
+	metricHandle.MetadataPrefetchEntryCount(context.Background(), p.entries, []common.MetricAttr{{Key: common.PrefetchResult, Value: result}})
+	if p.err != nil {
+		return p.entries, p.err
+	}
+
+	if p.capped != "" {
+		logger.Warnf("Stopped the metadata-prefetch of bucket %q at %s, the remaining metadata is fetched on first access", bucket.Name(), p.capped)
+	}
+	logger.Info("Metadata-prefetch finished", "bucket", bucket.Name(), "items", p.entries, "elapsed", time.Since(start).String(), "result", result)
+	return p.entries, nil
+}
+
+// logProgress logs the progress of the prefetch periodically until done is
+// closed.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *metadataPrefetcher) logProgress(start time.Time, done <-chan struct{}) {
+	ticker := time.NewTicker(metadataPrefetchProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		entries, current := p.entries, p.current
+		p.mu.Unlock()
+		logger.Info("Metadata-prefetch in progress", "bucket", p.bucket.Name(), "items", entries, "elapsed", time.Since(start).String(), "prefix", current)
+	}
+}
+
+// work lists the pending directories until there are none left and no other
+// worker may discover more, or the prefetch stops.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *metadataPrefetcher) work(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		for len(p.pending) == 0 && p.active > 0 && !p.stopped() {
+			p.cond.Wait()
+		}
+		if len(p.pending) == 0 || p.stopped() {
+			// Wake up the other idle workers to let them return too.
+			p.cond.Broadcast()
+			return
+		}
+
+		// Depth first, so that the pending directories don't pile up.
+		prefix := p.pending[len(p.pending)-1]
+		p.pending = p.pending[:len(p.pending)-1]
+		p.current = prefix
+		p.active++
+
+		p.mu.Unlock()
+		subdirs, entries, err := p.listDir(ctx, prefix)
+		p.mu.Lock()
+
+		p.active--
+		p.entries += entries
+		p.pending = append(p.pending, subdirs...)
+		switch {
+		case p.stopped():
+		case ctx.Err() != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+			p.capped = fmt.Sprintf("experimental-metadata-prefetch-max-duration-secs (%v)", p.config.MaxDuration)
+		case err != nil:
+			p.err = err
+		case p.config.MaxEntries > 0 && p.entries >= p.config.MaxEntries:
+			p.capped = fmt.Sprintf("experimental-metadata-prefetch-max-entries (%d)", p.config.MaxEntries)
+		}
+		p.cond.Broadcast()
+	}
+}
+
+// LOCKS_REQUIRED(p.mu)
+func (p *metadataPrefetcher) stopped() bool {
+	return p.err != nil || p.capped != ""
+}
+
+// listDir lists the directory with the supplied prefix, returning its
+// subdirectories and the number of its entries.
+func (p *metadataPrefetcher) listDir(ctx context.Context, prefix string) (subdirs []string, entries int64, err error) {
+	var tok string
+	for {
+		listing, err := p.bucket.ListObjects(ctx, &gcs.ListObjectsRequest{
+			Delimiter:                "/",
+			IncludeTrailingDelimiter: true,
+			Prefix:                   prefix,
+			ContinuationToken:        tok,
+			MaxResults:               metadataPrefetchListMaxResults,
+			ProjectionVal:            gcs.NoAcl,
+			IncludeFoldersAsPrefixes: p.isHierarchical,
+		})
+		if err != nil {
+			return subdirs, entries, fmt.Errorf("listing %q: %w", prefix, err)
+		}
+
+		for _, o := range listing.MinObjects {
+			// The placeholders of the directories are counted as their prefixes.
+			if !strings.HasSuffix(o.Name, "/") {
+				entries++
+			}
+		}
+		for _, dir := range listing.CollapsedRuns {
+			subdirs = append(subdirs, dir)
+			entries++
+		}
+
+		tok = listing.ContinuationToken
+		if tok == "" {
+			return subdirs, entries, nil
+		}
+	}
+}
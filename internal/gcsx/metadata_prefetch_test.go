@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// prefetchMetricHandle keeps the entry counts of the metadata prefetch by
+// result.
+type prefetchMetricHandle struct {
+	common.MetricHandle
+	entries map[string]int64
+}
+
+func (m *prefetchMetricHandle) MetadataPrefetchEntryCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.entries[attrs[0].Value] += inc
+}
+
+// newPrefetchTestBucket returns a bucket with a stat cache over a faulty fake
+// bucket holding 7 entries: 4 objects and 3 directories.
+func newPrefetchTestBucket(t *testing.T) (gcs.Bucket, *fake.FaultSchedule) {
+	t.Helper()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	wrapped := fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)
+	for _, name := range []string{"a", "dir1/b", "dir1/sub/c", "dir2/d"} {
+		_, err := storageutil.CreateObject(context.Background(), wrapped, name, []byte("taco"))
+		require.NoError(t, err)
+	}
+	schedule := fake.NewFaultSchedule()
+	statCache := metadata.NewStatCacheBucketView(lru.NewCache(1<<20), "")
+	return caching.NewFastStatBucket(time.Hour, 0, 0, nil, statCache, clock, fake.NewFaultyBucket(wrapped, schedule)), schedule
+}
+
+func newPrefetchMetricHandle() *prefetchMetricHandle {
+	return &prefetchMetricHandle{MetricHandle: common.NewNoopMetrics(), entries: make(map[string]int64)}
+}
+
+func TestPrefetchMetadata_FillsTheStatCache(t *testing.T) {
+	bucket, schedule := newPrefetchTestBucket(t)
+	metricHandle := newPrefetchMetricHandle()
+
+	n, err := gcsx.PrefetchMetadata(context.Background(), bucket, gcsx.MetadataPrefetchConfig{Workers: 4}, metricHandle)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+	assert.Equal(t, map[string]int64{"completed": 7}, metricHandle.entries)
+	assert.Equal(t, 4, schedule.Calls("ListObjects"))
+	_, _, err = bucket.StatObject(context.Background(), &gcs.StatObjectRequest{Name: "dir1/sub/c"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, schedule.Calls("StatObject"))
+}
+
+func TestPrefetchMetadata_StopsAtMaxEntries(t *testing.T) {
+	bucket, schedule := newPrefetchTestBucket(t)
+	metricHandle := newPrefetchMetricHandle()
+
+	n, err := gcsx.PrefetchMetadata(context.Background(), bucket, gcsx.MetadataPrefetchConfig{Workers: 1, MaxEntries: 3}, metricHandle)
+
+	require.NoError(t, err)
+	// The root directory alone has 3 entries.
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, map[string]int64{"capped": 3}, metricHandle.entries)
+	assert.Equal(t, 1, schedule.Calls("ListObjects"))
+}
+
+func TestPrefetchMetadata_FailsOnListingError(t *testing.T) {
+	bucket, schedule := newPrefetchTestBucket(t)
+	schedule.Add(fake.Fault{Method: "ListObjects", Call: 2, Err: errors.New("taco")})
+	metricHandle := newPrefetchMetricHandle()
+
+	_, err := gcsx.PrefetchMetadata(context.Background(), bucket, gcsx.MetadataPrefetchConfig{Workers: 1}, metricHandle)
+
+	assert.ErrorContains(t, err, "taco")
+	assert.Contains(t, metricHandle.entries, "failed")
+}
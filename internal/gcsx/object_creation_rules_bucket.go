@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/net/context"
+)
+
+// NewObjectCreationRulesBucket creates a wrapper bucket that sets the storage
+// class and custom time of newly created objects according to the rule with
+// the longest prefix matching the object name. Objects which already exist keep
+// the storage class and custom time they were created with, and so do
+// directory placeholder objects.
+func NewObjectCreationRulesBucket(rules []cfg.ObjectCreationRule, clock timeutil.Clock, b gcs.Bucket) gcs.Bucket {
+	return &objectCreationRulesBucket{
+		Bucket: b,
+		rules:  rules,
+		clock:  clock,
+	}
+}
+
+type objectCreationRulesBucket struct {
+	gcs.Bucket
+	rules []cfg.ObjectCreationRule
+	clock timeutil.Clock
+}
+
+func (b *objectCreationRulesBucket) applyRules(req *gcs.CreateObjectRequest) {
+	// Only apply to objects which don't exist yet.
+	if req.GenerationPrecondition == nil || *req.GenerationPrecondition != 0 {
+		return
+	}
+	if strings.HasSuffix(req.Name, "/") {
+		return
+	}
+
+	var rule *cfg.ObjectCreationRule
+	for i := range b.rules {
+		r := &b.rules[i]
+		if strings.HasPrefix(req.Name, r.Prefix) && (rule == nil || len(r.Prefix) > len(rule.Prefix)) {
+			rule = r
+		}
+	}
+	if rule == nil {
+		return
+	}
+
+	if req.StorageClass == "" {
+		req.StorageClass = rule.StorageClass
+	}
+	if req.CustomTime == "" && rule.TTL > 0 {
+		req.CustomTime = b.clock.Now().Add(rule.TTL).UTC().Format(time.RFC3339)
+	}
+}
+
+func (b *objectCreationRulesBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	b.applyRules(req)
+	return b.Bucket.CreateObject(ctx, req)
+}
+
+func (b *objectCreationRulesBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	b.applyRules(req)
+	return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+var objectCreationRules = []cfg.ObjectCreationRule{
+	{Prefix: "archive/", StorageClass: "NEARLINE"},
+	{Prefix: "archive/cold/", StorageClass: "COLDLINE", TTL: 24 * time.Hour},
+	{Prefix: "tmp/", TTL: time.Hour},
+}
+
+var objectCreationRulesBucketTestCases = []struct {
+	name                 string
+	objectName           string
+	existingObject       bool
+	expectedStorageClass string
+	expectedCustomTime   string
+}{
+	{
+		name:                 "no_matching_rule",
+		objectName:           "data/foo",
+		expectedStorageClass: "STANDARD",
+	},
+	{
+		name:                 "storage_class_only",
+		objectName:           "archive/foo",
+		expectedStorageClass: "NEARLINE",
+	},
+	{
+		name:                 "longest_prefix_wins",
+		objectName:           "archive/cold/foo",
+		expectedStorageClass: "COLDLINE",
+		expectedCustomTime:   "2024-01-02T10:00:00Z",
+	},
+	{
+		name:                 "ttl_only",
+		objectName:           "tmp/foo",
+		expectedStorageClass: "STANDARD",
+		expectedCustomTime:   "2024-01-01T11:00:00Z",
+	},
+	{
+		name:                 "directory_object",
+		objectName:           "archive/dir/",
+		expectedStorageClass: "STANDARD",
+	},
+	{
+		name:                 "existing_object",
+		objectName:           "archive/foo",
+		existingObject:       true,
+		expectedStorageClass: "STANDARD",
+	},
+}
+
+func newObjectCreationRulesBucket(t *testing.T) (gcs.Bucket, gcs.Bucket) {
+	t.Helper()
+	clock := timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	wrapped := fake.NewFakeBucket(&clock, "", gcs.NonHierarchical)
+	return gcsx.NewObjectCreationRulesBucket(objectCreationRules, &clock, wrapped), wrapped
+}
+
+func createObjectRequest(t *testing.T, wrapped gcs.Bucket, name string, existing bool) *gcs.CreateObjectRequest {
+	t.Helper()
+	if !existing {
+		var preCond int64
+		return &gcs.CreateObjectRequest{Name: name, GenerationPrecondition: &preCond}
+	}
+
+	o, err := wrapped.CreateObject(context.Background(), &gcs.CreateObjectRequest{
+		Name:     name,
+		Contents: strings.NewReader(""),
+	})
+	require.NoError(t, err)
+	return gcs.NewCreateObjectRequest(o, name, nil, 0)
+}
+
+func TestObjectCreationRulesBucket_CreateObject(t *testing.T) {
+	for _, tc := range objectCreationRulesBucketTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket, wrapped := newObjectCreationRulesBucket(t)
+			req := createObjectRequest(t, wrapped, tc.objectName, tc.existingObject)
+			req.Contents = strings.NewReader("taco")
+
+			o, err := bucket.CreateObject(context.Background(), req)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStorageClass, o.StorageClass)
+			assert.Equal(t, tc.expectedCustomTime, o.CustomTime)
+		})
+	}
+}
+
+func TestObjectCreationRulesBucket_CreateObjectChunkWriter(t *testing.T) {
+	for _, tc := range objectCreationRulesBucketTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket, wrapped := newObjectCreationRulesBucket(t)
+			req := createObjectRequest(t, wrapped, tc.objectName, tc.existingObject)
+
+			w, err := bucket.CreateObjectChunkWriter(context.Background(), req, 0, func(_ int64) {})
+			require.NoError(t, err)
+			_, err = w.Write([]byte("taco"))
+			require.NoError(t, err)
+			o, err := bucket.FinalizeUpload(context.Background(), w)
+			require.NoError(t, err)
+
+			m, e, err := wrapped.StatObject(context.Background(), &gcs.StatObjectRequest{
+				Name:                           o.Name,
+				ForceFetchFromGcs:              true,
+				ReturnExtendedObjectAttributes: true,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, tc.expectedStorageClass, e.StorageClass)
+			assert.Equal(t, tc.expectedCustomTime, e.CustomTime)
+		})
+	}
+}
@@ -126,6 +126,9 @@ func (b *prefixBucket) CopyObject(
 	// Modify the request and call through.
 	mReq := new(gcs.CopyObjectRequest)
 	*mReq = *req
+	// The source object of a copy from another bucket, i.e. of a rename across
+	// the buckets of a dynamic mount, is prefixed too: all the buckets of a mount
+	// are limited to the same only-dir.
 	mReq.SrcName = b.wrappedName(req.SrcName)
 	mReq.DstName = b.wrappedName(req.DstName)
 
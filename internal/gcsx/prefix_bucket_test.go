@@ -174,6 +174,36 @@ func (t *PrefixBucketTest) CopyObject() {
 	ExpectEq(contents, string(actual))
 }
 
+func (t *PrefixBucketTest) CopyObjectFromOtherBucket() {
+	var err error
+	suffix := "taco"
+	contents := "foobar"
+
+	// The other bucket of the mount is limited to the same prefix.
+	other := fake.NewFakeBucket(timeutil.RealClock(), "other_bucket", gcs.NonHierarchical)
+	fake.LinkFakeBuckets(t.wrapped, other)
+	_, err = storageutil.CreateObject(t.ctx, other, t.prefix+suffix, []byte(contents))
+	AssertEq(nil, err)
+
+	// Copy it into this bucket.
+	newSuffix := "burrito"
+	o, err := t.bucket.CopyObject(
+		t.ctx,
+		&gcs.CopyObjectRequest{
+			SrcBucketName: other.Name(),
+			SrcName:       suffix,
+			DstName:       newSuffix,
+		})
+
+	AssertEq(nil, err)
+	ExpectEq(newSuffix, o.Name)
+
+	// Read it through the back door.
+	actual, err := storageutil.ReadObject(t.ctx, t.wrapped, t.prefix+newSuffix)
+	AssertEq(nil, err)
+	ExpectEq(contents, string(actual))
+}
+
 func (t *PrefixBucketTest) ComposeObjects() {
 	var err error
 
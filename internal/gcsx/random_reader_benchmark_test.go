@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+)
+
+const benchmarkObjectSize = 64 * MB
+
+// newBenchmarkObject creates an object in a bucket simulating the latencies of
+// GCS, for the benchmarks not to depend on the network.
+func newBenchmarkObject(b *testing.B) (gcs.Bucket, *gcs.MinObject) {
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	o, err := storageutil.CreateObject(context.Background(), bucket, "foo", make([]byte, benchmarkObjectSize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return fake.NewLatencyBucket(bucket, fake.GCSLatencyProfile, 1), storageutil.ConvertObjToMinObject(o)
+}
+
+func benchmarkReadAt(b *testing.B, readSize int, offset func(i int) int64) {
+	bucket, object := newBenchmarkObject(b)
+	rr := NewRandomReader(object, bucket, 200, nil, false, false, ReadCoalescing{}, 0, common.NewNoopMetrics())
+	defer rr.Destroy()
+	buf := make([]byte, readSize)
+	b.SetBytes(int64(readSize))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := rr.ReadAt(context.Background(), buf, offset(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
+
+func BenchmarkRandomReader_SequentialRead(b *testing.B) {
+	benchmarkReadAt(b, MB, func(i int) int64 {
+		return int64(i*MB) % benchmarkObjectSize
+	})
+}
+
+func BenchmarkRandomReader_RandomRead(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	benchmarkReadAt(b, 64*1024, func(int) int64 {
+		return r.Int63n(benchmarkObjectSize - 64*1024)
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path"
@@ -41,6 +42,8 @@ import (
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/oglemock"
 	. "github.com/jacobsa/ogletest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"golang.org/x/net/context"
 )
 
@@ -180,10 +183,10 @@ func (t *RandomReaderTest) SetUp(ti *TestInfo) {
 	t.jobManager = downloader.NewJobManager(lruCache, util.DefaultFilePerm, util.DefaultDirPerm, t.cacheDir, sequentialReadSizeInMb, &cfg.FileCacheConfig{
 		EnableCrc: false,
 	}, common.NewNoopMetrics())
-	t.cacheHandler = file.NewCacheHandler(lruCache, t.jobManager, t.cacheDir, util.DefaultFilePerm, util.DefaultDirPerm)
+	t.cacheHandler = file.NewCacheHandler(lruCache, t.jobManager, t.cacheDir, util.DefaultFilePerm, util.DefaultDirPerm, common.NewNoopMetrics(), nil, nil, nil)
 
 	// Set up the reader.
-	rr := NewRandomReader(t.object, t.bucket, sequentialReadSizeInMb, nil, false, common.NewNoopMetrics())
+	rr := NewRandomReader(t.object, t.bucket, sequentialReadSizeInMb, nil, false, false, ReadCoalescing{}, 0, common.NewNoopMetrics())
 	t.rr.wrapped = rr.(*randomReader)
 }
 
@@ -298,12 +301,12 @@ func (t *RandomReaderTest) NewReaderReturnsError() {
 }
 
 func (t *RandomReaderTest) ReaderFails() {
-	// Bucket
-	r := iotest.OneByteReader(iotest.TimeoutReader(strings.NewReader("xxx")))
-	rc := io.NopCloser(r)
-
-	ExpectCall(t.bucket, "NewReader")(Any(), Any()).
-		WillOnce(Return(rc, nil))
+	// Bucket: every stream fails without returning anything.
+	ExpectCall(t.bucket, "NewReader")(Any(), rangeStartIs(0)).
+		Times(maxReadResumesWithoutProgress + 1).
+		WillRepeatedly(Invoke(func(_ context.Context, _ *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+			return io.NopCloser(iotest.ErrReader(iotest.ErrTimeout)), nil
+		}))
 
 	// Call
 	buf := make([]byte, 3)
@@ -311,6 +314,236 @@ func (t *RandomReaderTest) ReaderFails() {
 
 	ExpectThat(err, Error(HasSubstr("readFull")))
 	ExpectThat(err, Error(HasSubstr(iotest.ErrTimeout.Error())))
+	ExpectEq(nil, t.rr.wrapped.reader)
+}
+
+func (t *RandomReaderTest) ReaderFailsMidStream_ResumesAtFailingOffset() {
+	// The first stream is reset after 5 bytes, the second one ends early after
+	// 5 more bytes.
+	content := "abcdefghijklmnopq"
+	ExpectCall(t.bucket, "NewReader")(Any(), rangeStartIs(0)).
+		WillOnce(Return(io.NopCloser(io.MultiReader(strings.NewReader(content[:5]), iotest.ErrReader(errors.New("connection reset")))), nil))
+	ExpectCall(t.bucket, "NewReader")(Any(), rangeStartIs(5)).
+		WillOnce(Return(getReadCloser([]byte(content[5:10])), nil))
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(10), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content[10:])), nil))
+
+	buf := make([]byte, 17)
+	n, _, err := t.rr.ReadAt(buf, 0)
+
+	AssertEq(nil, err)
+	ExpectEq(17, n)
+	ExpectEq(content, string(buf))
+	// Resuming a stream isn't a seek.
+	ExpectEq(0, t.rr.wrapped.seeks)
+}
+
+func (t *RandomReaderTest) ReaderFailsMidStream_LargeObject() {
+	// Read 3 MiB in the middle of a 1 TiB object through streams which are
+	// reset after every MiB. Each new stream starts where the previous one
+	// failed instead of at the start of the read.
+	t.object.Size = 1 << 40
+	const offset = 1 << 39
+	data := make([]byte, 3*MB)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	for i := int64(0); i < 3; i++ {
+		start := offset + i*MB
+		chunk := data[i*MB : (i+1)*MB]
+		ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(uint64(start)), rangeLimitIs(uint64(start+sequentialReadSizeInBytes)))).
+			WillOnce(Return(io.NopCloser(io.MultiReader(bytes.NewReader(chunk), iotest.ErrReader(errors.New("connection reset")))), nil))
+	}
+
+	buf := make([]byte, 3*MB)
+	n, _, err := t.rr.ReadAt(buf, offset)
+
+	AssertEq(nil, err)
+	ExpectEq(3*MB, n)
+	ExpectTrue(bytes.Equal(data, buf))
+}
+
+func (t *RandomReaderTest) VerifyChecksums_SequentialReadsMatch() {
+	content := "abcdefghijklmnopq"
+	checksum := crc32.Checksum([]byte(content), crc32cTable)
+	t.object.CRC32C = &checksum
+	t.rr.wrapped.verifyChecksums = true
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(0), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content)), nil))
+
+	buf := make([]byte, 10)
+	_, _, err := t.rr.ReadAt(buf, 0)
+	AssertEq(nil, err)
+	n, _, err := t.rr.ReadAt(buf[:7], 10)
+
+	AssertEq(nil, err)
+	ExpectEq(content[10:], string(buf[:n]))
+}
+
+func (t *RandomReaderTest) VerifyChecksums_SequentialReadsMismatch() {
+	content := "abcdefghijklmnopq"
+	checksum := crc32.Checksum([]byte("corrupted content"), crc32cTable)
+	t.object.CRC32C = &checksum
+	t.rr.wrapped.verifyChecksums = true
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(0), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content)), nil))
+
+	buf := make([]byte, 10)
+	_, _, err := t.rr.ReadAt(buf, 0)
+	AssertEq(nil, err)
+	_, _, err = t.rr.ReadAt(buf[:7], 10)
+
+	ExpectThat(err, Error(HasSubstr("CRC32C checksum mismatch")))
+}
+
+func (t *RandomReaderTest) VerifyChecksums_RangedReadNotVerified() {
+	content := "abcdefghijklmnopq"
+	checksum := crc32.Checksum([]byte("corrupted content"), crc32cTable)
+	t.object.CRC32C = &checksum
+	t.rr.wrapped.verifyChecksums = true
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(5), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content[5:])), nil))
+
+	buf := make([]byte, 12)
+	n, _, err := t.rr.ReadAt(buf, 5)
+
+	AssertEq(nil, err)
+	ExpectEq(content[5:], string(buf[:n]))
+}
+
+func (t *RandomReaderTest) Coalescing_MergesReadsWithinRange() {
+	content := "abcdefghijklmnopq"
+	t.rr.wrapped.seeks = minSeeksForRandom
+	t.rr.wrapped.coalescing = ReadCoalescing{WindowBytes: 8}
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(8), rangeLimitIs(16))).
+		WillOnce(Return(getReadCloser([]byte(content[8:16])), nil))
+
+	buf := make([]byte, 2)
+	n, _, err := t.rr.ReadAt(buf, 10)
+	AssertEq(nil, err)
+	ExpectEq("kl", string(buf[:n]))
+	// Reads behind the previous one within the range are merged too.
+	n, _, err = t.rr.ReadAt(buf, 8)
+
+	AssertEq(nil, err)
+	ExpectEq("ij", string(buf[:n]))
+	ExpectEq(nil, t.rr.wrapped.reader)
+}
+
+func (t *RandomReaderTest) Coalescing_ReadOutsideRangeFetchesAnother() {
+	content := "abcdefghijklmnopq"
+	t.rr.wrapped.seeks = minSeeksForRandom
+	t.rr.wrapped.coalescing = ReadCoalescing{WindowBytes: 8}
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(8), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content[8:])), nil))
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(0), rangeLimitIs(8))).
+		WillOnce(Return(getReadCloser([]byte(content[:8])), nil))
+
+	buf := make([]byte, 4)
+	n, _, err := t.rr.ReadAt(buf, 15)
+	ExpectEq(io.EOF, err)
+	ExpectEq("pq", string(buf[:n]))
+	n, _, err = t.rr.ReadAt(buf, 2)
+
+	AssertEq(nil, err)
+	ExpectEq("cdef", string(buf[:n]))
+}
+
+func (t *RandomReaderTest) Coalescing_ExpiredRangeIsFetchedAgain() {
+	content := "abcdefghijklmnopq"
+	t.rr.wrapped.seeks = minSeeksForRandom
+	t.rr.wrapped.coalescing = ReadCoalescing{WindowBytes: 8, Window: time.Millisecond}
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(8), rangeLimitIs(16))).
+		Times(2).
+		WillRepeatedly(Invoke(func(_ context.Context, _ *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+			return getReadCloser([]byte(content[8:16])), nil
+		}))
+
+	buf := make([]byte, 2)
+	_, _, err := t.rr.ReadAt(buf, 10)
+	AssertEq(nil, err)
+	time.Sleep(5 * time.Millisecond)
+	n, _, err := t.rr.ReadAt(buf, 12)
+
+	AssertEq(nil, err)
+	ExpectEq("mn", string(buf[:n]))
+}
+
+func (t *RandomReaderTest) Coalescing_LargeReadsAreNotCoalesced() {
+	t.rr.wrapped.seeks = minSeeksForRandom
+	t.rr.wrapped.coalescing = ReadCoalescing{WindowBytes: 8}
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(0), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte("abcdefghijklmnopq")), nil))
+
+	buf := make([]byte, 8)
+	n, _, err := t.rr.ReadAt(buf, 0)
+
+	AssertEq(nil, err)
+	ExpectEq("abcdefgh", string(buf[:n]))
+	ExpectEq(nil, t.rr.wrapped.coalesced)
+}
+
+func (t *RandomReaderTest) ColumnarFooter_ReadsWithinFooterAreServedFromMemory() {
+	content := "abcdefghijklmnopq"
+	t.rr.wrapped.footerBytes = 8
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(9), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte(content[9:])), nil))
+
+	buf := make([]byte, 4)
+	n, _, err := t.rr.ReadAt(buf, 13)
+	AssertEq(nil, err)
+	ExpectEq("nopq", string(buf[:n]))
+	n, _, err = t.rr.ReadAt(buf, 9)
+
+	AssertEq(nil, err)
+	ExpectEq("jklm", string(buf[:n]))
+	ExpectEq(nil, t.rr.wrapped.reader)
+}
+
+func (t *RandomReaderTest) ColumnarFooter_ColumnChunkReadsDontReadAhead() {
+	t.object.Size = 1 << 40
+	t.rr.wrapped.footerBytes = 8
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(1<<40-8), rangeLimitIs(1<<40))).
+		WillOnce(Return(getReadCloser([]byte("xxxxxxxx")), nil))
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(1000), rangeLimitIs(1000+MB))).
+		WillOnce(Return(getReadCloser([]byte(strings.Repeat("x", MB))), nil))
+
+	buf := make([]byte, 8)
+	_, _, err := t.rr.ReadAt(buf, 1<<40-8)
+	AssertEq(nil, err)
+	_, _, err = t.rr.ReadAt(make([]byte, 100), 1000)
+
+	AssertEq(nil, err)
+	ExpectEq(1000+MB, t.rr.wrapped.limit)
+}
+
+func (t *RandomReaderTest) ColumnarFooter_ContinuingReadsDoubleTheFetch() {
+	t.object.Size = 1 << 40
+	t.rr.wrapped.footerBytes = 8
+	t.rr.wrapped.footer = []byte("xxxxxxxx")
+	t.rr.wrapped.footerStart = 1<<40 - 8
+	t.rr.wrapped.columnarFetchSize = 2 * MB
+	t.rr.wrapped.limit = 3 * MB
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(3*MB), rangeLimitIs(7*MB))).
+		WillOnce(Return(getReadCloser([]byte(strings.Repeat("x", 4*MB))), nil))
+
+	_, _, err := t.rr.ReadAt(make([]byte, 100), 3*MB)
+
+	AssertEq(nil, err)
+	ExpectEq(4*MB, t.rr.wrapped.columnarFetchSize)
+}
+
+func (t *RandomReaderTest) ColumnarFooter_ReadAtStartIsSequential() {
+	t.rr.wrapped.footerBytes = 8
+	ExpectCall(t.bucket, "NewReader")(Any(), AllOf(rangeStartIs(0), rangeLimitIs(17))).
+		WillOnce(Return(getReadCloser([]byte("abcdefghijklmnopq")), nil))
+
+	buf := make([]byte, 4)
+	n, _, err := t.rr.ReadAt(buf, 0)
+
+	AssertEq(nil, err)
+	ExpectEq("abcd", string(buf[:n]))
+	ExpectEq(nil, t.rr.wrapped.footer)
 }
 
 func (t *RandomReaderTest) ReaderOvershootsRange() {
@@ -354,6 +587,29 @@ func (t *RandomReaderTest) ReaderNotExhausted() {
 	ExpectEq(4, t.rr.wrapped.limit)
 }
 
+func (t *RandomReaderTest) ExistingReader_LinksSpanOfOpStartingIt() {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	_, startingOp := tracer.Start(context.Background(), "ReadFile")
+	startingOp.End()
+	ctx, readingOp := tracer.Start(t.rr.ctx, "ReadFile")
+	t.rr.ctx = ctx
+	// Set up a reader started by another op.
+	t.rr.wrapped.reader = io.NopCloser(strings.NewReader("abc"))
+	t.rr.wrapped.cancel = func() {}
+	t.rr.wrapped.readerSpan = startingOp.SpanContext()
+	t.rr.wrapped.start = 1
+	t.rr.wrapped.limit = 4
+
+	_, _, err := t.rr.ReadAt(make([]byte, 2), 1)
+	readingOp.End()
+
+	AssertEq(nil, err)
+	links := recorder.Ended()[1].Links()
+	AssertEq(1, len(links))
+	ExpectTrue(startingOp.SpanContext().Equal(links[0].SpanContext))
+}
+
 func (t *RandomReaderTest) ReaderExhausted_ReadFinished() {
 	// Set up a reader that has three bytes left to give.
 	rc := &countingCloser{
@@ -592,7 +848,7 @@ func (t *RandomReaderTest) UpgradesSequentialReads_NoExistingReader() {
 	t.object.Size = 1 << 40
 	const readSize = 1 * MB
 	// Set up the custom randomReader.
-	rr := NewRandomReader(t.object, t.bucket, readSize/MB, nil, false, common.NewNoopMetrics())
+	rr := NewRandomReader(t.object, t.bucket, readSize/MB, nil, false, false, ReadCoalescing{}, 0, common.NewNoopMetrics())
 	t.rr.wrapped = rr.(*randomReader)
 
 	// Simulate a previous exhausted reader that ended at the offset from which
@@ -625,7 +881,7 @@ func (t *RandomReaderTest) SequentialReads_NoExistingReader_requestedSizeGreater
 	const chunkSize = 1 * MB
 	const readSize = 3 * MB
 	// Set up the custom randomReader.
-	rr := NewRandomReader(t.object, t.bucket, chunkSize/MB, nil, false, common.NewNoopMetrics())
+	rr := NewRandomReader(t.object, t.bucket, chunkSize/MB, nil, false, false, ReadCoalescing{}, 0, common.NewNoopMetrics())
 	t.rr.wrapped = rr.(*randomReader)
 	// Create readers for each chunk.
 	chunk1Reader := strings.NewReader(strings.Repeat("x", chunkSize))
@@ -672,7 +928,7 @@ func (t *RandomReaderTest) SequentialReads_existingReader_requestedSizeGreaterTh
 	const chunkSize = 1 * MB
 	const readSize = 3 * MB
 	// Set up the custom randomReader.
-	rr := NewRandomReader(t.object, t.bucket, chunkSize/MB, nil, false, common.NewNoopMetrics())
+	rr := NewRandomReader(t.object, t.bucket, chunkSize/MB, nil, false, false, ReadCoalescing{}, 0, common.NewNoopMetrics())
 	t.rr.wrapped = rr.(*randomReader)
 	// Simulate an existing reader at the correct offset, which will be exhausted
 	// by the read below.
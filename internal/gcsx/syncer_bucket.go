@@ -15,12 +15,24 @@
 package gcsx
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
 )
 
 type SyncerBucket struct {
 	gcs.Bucket
 	Syncer
+
+	// metricHandle records the GCS metrics of the bucket, if set.
+	metricHandle common.MetricHandle
+
+	// appender composes the contents streamed to temporary objects onto the
+	// objects they're appended to.
+	appender *appendObjectCreator
 }
 
 // NewSyncerBucket creates a SyncerBucket, which can be used either as
@@ -32,5 +44,36 @@ func NewSyncerBucket(
 	bucket gcs.Bucket,
 ) SyncerBucket {
 	syncer := NewSyncer(appendThreshold, chunkTransferTimeoutSecs, tmpObjectPrefix, bucket)
-	return SyncerBucket{bucket, syncer}
+	appender := &appendObjectCreator{prefix: tmpObjectPrefix, bucket: bucket}
+	return SyncerBucket{Bucket: bucket, Syncer: syncer, appender: appender}
+}
+
+// TempObjectName returns a name for a temporary object holding contents to be
+// appended to an object with AppendObject.
+func (sb SyncerBucket) TempObjectName() (string, error) {
+	return sb.appender.chooseName()
+}
+
+// AppendObject composes the temporary object tmp onto srcObject, setting its
+// mtime if not nil, and deletes tmp. It fails with *gcs.PreconditionError if
+// the generation of srcObject is no longer current.
+func (sb SyncerBucket) AppendObject(
+	ctx context.Context,
+	srcObject *gcs.Object,
+	tmp *gcs.MinObject,
+	mtime *time.Time) (o *gcs.Object, err error) {
+	defer func() {
+		deleteErr := sb.Bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: tmp.Name})
+		if err == nil && deleteErr != nil {
+			err = fmt.Errorf("DeleteObject: %w", deleteErr)
+		}
+	}()
+
+	return sb.appender.compose(ctx, srcObject, tmp.Name, tmp.Generation, mtime)
+}
+
+// MetricHandle returns the handle recording the GCS metrics of the bucket, e.g.
+// annotated with its name in a dynamic mount, nil if unset.
+func (sb SyncerBucket) MetricHandle() common.MetricHandle {
+	return sb.metricHandle
 }
@@ -319,7 +319,8 @@ func (t *SyncerTest) SetUp(ti *TestInfo) {
 	t.content, err = NewTempFile(
 		dummyReadCloser{strings.NewReader(srcObjectContents)},
 		"",
-		&t.clock)
+		&t.clock,
+		nil)
 
 	AssertEq(nil, err)
 
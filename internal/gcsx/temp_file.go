@@ -21,6 +21,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/jacobsa/fuse/fsutil"
 	"github.com/jacobsa/timeutil"
 )
@@ -76,11 +77,14 @@ type StatResult struct {
 
 // NewTempFile creates a temp file whose initial contents are given by the
 // supplied reader. dir is a directory on whose file system the inode will live,
-// or the system default temporary location if empty.
+// or the system default temporary location if empty. The size of the file is
+// reserved in budget, if not nil, and the writes growing it fail with ENOSPC
+// when it's exhausted.
 func NewTempFile(
 	source io.ReadCloser,
 	dir string,
-	clock timeutil.Clock) (tf TempFile, err error) {
+	clock timeutil.Clock,
+	budget *diskbudget.Budget) (tf TempFile, err error) {
 	// Create an anonymous file to wrap. When we close it, its resources will be
 	// magically cleaned up.
 	f, err := fsutil.AnonymousFile(dir)
@@ -93,6 +97,7 @@ func NewTempFile(
 		source:         source,
 		state:          fileIncomplete,
 		clock:          clock,
+		budget:         budget,
 		f:              f,
 		dirtyThreshold: 0,
 	}
@@ -102,17 +107,20 @@ func NewTempFile(
 
 // NewCacheFile creates a wrapper temp file whose initial contents are given by the
 // supplied source. dir is a directory on whose file system the file will live,
-// or the system default temporary location if empty.
+// or the system default temporary location if empty. The size of the file is
+// reserved in budget, like for NewTempFile.
 func NewCacheFile(
 	source io.ReadCloser,
 	f *os.File,
 	dir string,
-	clock timeutil.Clock) (tf TempFile) {
+	clock timeutil.Clock,
+	budget *diskbudget.Budget) (tf TempFile) {
 
 	tf = &tempFile{
 		source:         source,
 		state:          fileIncomplete,
 		clock:          clock,
+		budget:         budget,
 		f:              f,
 		dirtyThreshold: 0,
 	}
@@ -160,11 +168,17 @@ type tempFile struct {
 
 	source io.ReadCloser
 
+	// The disk budget in which the size of the file is reserved, if any.
+	budget *diskbudget.Budget
+
 	/////////////////////////
 	// Mutable state
 	/////////////////////////
 	state fileState
 
+	// The size reserved in budget, at least the size of the file.
+	reserved int64
+
 	// A file containing our current contents.
 	f *os.File
 
@@ -219,6 +233,7 @@ func (tf *tempFile) CheckInvariants() {
 }
 
 func (tf *tempFile) Destroy() {
+	tf.releaseDownTo(0)
 	tf.state = fileDestroyed
 	// Throw away the file (for anonymous files).
 	tf.f.Close()
@@ -275,6 +290,10 @@ func (tf *tempFile) WriteAt(p []byte, offset int64) (int, error) {
 		return 0, fmt.Errorf("cannot WriteAt incomplete file: %w", err)
 	}
 
+	if err = tf.reserveUpTo(offset + int64(len(p))); err != nil {
+		return 0, err
+	}
+
 	// Update our state regarding being dirty.
 	tf.dirtyThreshold = minInt64(tf.dirtyThreshold, offset)
 
@@ -293,6 +312,10 @@ func (tf *tempFile) Truncate(n int64) error {
 		return fmt.Errorf("cannot Truncate incomplete file: %w", err)
 	}
 
+	if err = tf.reserveUpTo(n); err != nil {
+		return err
+	}
+
 	// Update our state regarding being dirty.
 	tf.dirtyThreshold = minInt64(tf.dirtyThreshold, n)
 
@@ -302,7 +325,11 @@ func (tf *tempFile) Truncate(n int64) error {
 	tf.mtime = &newMtime
 
 	// Call through.
-	return tf.f.Truncate(n)
+	if err = tf.f.Truncate(n); err != nil {
+		return err
+	}
+	tf.releaseDownTo(n)
+	return nil
 }
 
 func (tf *tempFile) SetMtime(mtime time.Time) {
@@ -329,6 +356,55 @@ const (
 	minCopyLength = 64 * 1024 * 1024 // 64 MB
 )
 
+// reserveUpTo reserves the size of the file in the budget up to size bytes.
+func (tf *tempFile) reserveUpTo(size int64) error {
+	if size <= tf.reserved {
+		return nil
+	}
+	if err := tf.budget.Reserve(uint64(size - tf.reserved)); err != nil {
+		return err
+	}
+	tf.reserved = size
+	return nil
+}
+
+// releaseDownTo releases the size of the file reserved in the budget beyond
+// size bytes.
+func (tf *tempFile) releaseDownTo(size int64) {
+	if size >= tf.reserved {
+		return
+	}
+	tf.budget.Release(uint64(tf.reserved - size))
+	tf.reserved = size
+}
+
+// copyFromSource copies up to n bytes of the source at the end of the file of
+// the given size, returning io.EOF like io.CopyN if the source has fewer.
+func (tf *tempFile) copyFromSource(size int64, n int64) (int64, error) {
+	if tf.budget == nil {
+		return io.CopyN(tf.f, tf.source, n)
+	}
+	// The length of the source being unknown, the bytes are reserved as they
+	// are written.
+	return io.CopyN(&reservingWriter{tf: tf, size: size}, tf.source, n)
+}
+
+// reservingWriter appends to the file of a tempFile, reserving the bytes in
+// its budget before writing them.
+type reservingWriter struct {
+	tf   *tempFile
+	size int64
+}
+
+func (w *reservingWriter) Write(p []byte) (int, error) {
+	if err := w.tf.reserveUpTo(w.size + int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.tf.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
 func (tf *tempFile) ensure(limit int64) error {
 	switch tf.state {
 	case fileIncomplete:
@@ -340,7 +416,7 @@ func (tf *tempFile) ensure(limit int64) error {
 		if n < minCopyLength {
 			n = minCopyLength
 		}
-		n, err = io.CopyN(tf.f, tf.source, n)
+		n, err = tf.copyFromSource(size, n)
 		if err == io.EOF {
 			tf.source.Close()
 			tf.dirtyThreshold = size + n
@@ -15,12 +15,15 @@
 package gcsx_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/diskbudget"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
@@ -145,7 +148,8 @@ func (t *TempFileTest) SetUp(ti *TestInfo) {
 	t.tf.wrapped, err = gcsx.NewTempFile(
 		dummyReadCloser{strings.NewReader(initialContent)},
 		"",
-		&t.clock)
+		&t.clock,
+		nil)
 
 	AssertEq(nil, err)
 }
@@ -249,3 +253,49 @@ func (t *TempFileTest) SetMtime() {
 	AssertEq(nil, err)
 	ExpectThat(sr.Mtime, Pointee(timeutil.TimeEq(mtime)))
 }
+
+func (t *TempFileTest) DiskBudget() {
+	budget := diskbudget.New(uint64(initialContentSize) + 4)
+	tf, err := gcsx.NewTempFile(
+		dummyReadCloser{strings.NewReader(initialContent)},
+		"",
+		&t.clock,
+		budget)
+	AssertEq(nil, err)
+
+	// Loading the initial content reserves it.
+	_, err = tf.Stat()
+	AssertEq(nil, err)
+	ExpectEq(initialContentSize, budget.Used())
+
+	// Writes within the budget succeed.
+	_, err = tf.WriteAt([]byte("abcd"), int64(initialContentSize))
+	AssertEq(nil, err)
+	ExpectEq(initialContentSize+4, budget.Used())
+
+	// Writes beyond it fail with ENOSPC.
+	_, err = tf.WriteAt([]byte("e"), int64(initialContentSize)+4)
+	ExpectTrue(errors.Is(err, syscall.ENOSPC))
+	ExpectTrue(errors.Is(tf.Truncate(int64(initialContentSize)+5), syscall.ENOSPC))
+
+	// Shrinking the file and destroying it releases the space.
+	AssertEq(nil, tf.Truncate(2))
+	ExpectEq(2, budget.Used())
+	tf.Destroy()
+	ExpectEq(0, budget.Used())
+}
+
+func (t *TempFileTest) DiskBudget_InitialContentDoesNotFit() {
+	budget := diskbudget.New(uint64(initialContentSize) - 1)
+	tf, err := gcsx.NewTempFile(
+		dummyReadCloser{strings.NewReader(initialContent)},
+		"",
+		&t.clock,
+		budget)
+	AssertEq(nil, err)
+
+	_, err = tf.Stat()
+
+	ExpectTrue(errors.Is(err, syscall.ENOSPC))
+	ExpectEq(0, budget.Used())
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ignore hides the paths of a mount matching gitignore-style patterns.
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type rule struct {
+	// segments of the pattern, "**" matching any number of path segments.
+	segments []string
+
+	// anchored rules match the full path, others its base name.
+	anchored bool
+	dirOnly  bool
+	negated  bool
+}
+
+// Rules are gitignore-style patterns:
+//   - A pattern without a "/", e.g. ".DS_Store", matches the base name of the
+//     paths, otherwise, e.g. "logs/*.tmp", their full path from the root.
+//   - A trailing "/", e.g. "_temporary/", matches the directories only.
+//   - "**" matches any number of directories, e.g. "data/**/_SUCCESS".
+//   - A leading "!" shows again the paths matched by the previous patterns.
+//
+// A nil *Rules matches nothing.
+type Rules struct {
+	rules []rule
+}
+
+// New parses the patterns, returning nil if there are none.
+func New(patterns []string) (*Rules, error) {
+	var rules []rule
+	for _, pattern := range patterns {
+		var r rule
+		p := pattern
+		if strings.HasPrefix(p, "!") {
+			r.negated = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			r.dirOnly = true
+			p = strings.TrimSuffix(p, "/")
+		}
+		r.anchored = strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		if p == "" {
+			return nil, fmt.Errorf("empty ignore pattern %q", pattern)
+		}
+		r.segments = strings.Split(p, "/")
+		for _, s := range r.segments {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// matchSegments returns true if the name segments match the pattern segments.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// Match returns true if the path, relative to the root of the bucket and
+// without a trailing "/", is ignored. Its parent directories aren't considered:
+// the paths under an ignored directory can't be looked up anyway.
+func (r *Rules) Match(name string, isDir bool) bool {
+	if r == nil || name == "" {
+		return false
+	}
+	segments := strings.Split(name, "/")
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		s := segments
+		if !rule.anchored {
+			s = segments[len(segments)-1:]
+		}
+		if matchSegments(rule.segments, s) {
+			ignored = !rule.negated
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ignore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_NoPatterns(t *testing.T) {
+	r, err := New(nil)
+
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.False(t, r.Match("a", false))
+}
+
+func TestNew_InvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"[a", "/", "!", "a/[/b"} {
+		_, err := New([]string{pattern})
+
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	r, err := New([]string{".DS_Store", "_temporary/", "*.tmp", "!keep.tmp", "/logs", "data/**/_SUCCESS"})
+	require.NoError(t, err)
+	testCases := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{".DS_Store", false, true},
+		{"a/b/.DS_Store", false, true},
+		{"a/_temporary", true, true},
+		{"a/_temporary", false, false},
+		{"a/b.tmp", false, true},
+		{"a/keep.tmp", false, false},
+		{"logs", true, true},
+		{"a/logs", true, false},
+		{"data/_SUCCESS", false, true},
+		{"data/a/b/_SUCCESS", false, true},
+		{"other/_SUCCESS", false, false},
+		{"a/b.txt", false, false},
+		{"", true, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, r.Match(tc.name, tc.isDir), tc.name)
+	}
+}
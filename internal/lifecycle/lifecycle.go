@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lifecycle evaluates the lifecycle rules of a bucket, to tell apart
+// the objects about to be deleted or moved to another storage class by them.
+package lifecycle
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Expiry is an action of a lifecycle rule on an object, along with the
+// earliest time at which GCS can take it.
+type Expiry struct {
+	// Action is storage.DeleteAction or storage.SetStorageClassAction.
+	Action string
+
+	// StorageClass is the storage class set by storage.SetStorageClassAction.
+	StorageClass string
+
+	At time.Time
+}
+
+// String returns e.g. "Delete 2024-01-02T00:00:00Z" or
+// "SetStorageClass ARCHIVE 2024-01-02T00:00:00Z".
+func (e Expiry) String() string {
+	if e.Action == storage.SetStorageClassAction {
+		return fmt.Sprintf("%s %s %s", e.Action, e.StorageClass, e.At.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s %s", e.Action, e.At.UTC().Format(time.RFC3339))
+}
+
+// Rules are the lifecycle rules of a bucket deleting or changing the storage
+// class of the live objects based on their age, creation date and name. The
+// rules with other conditions, e.g. on the storage class or the custom time of
+// the objects, are left out, as the objects listed by gcsfuse don't have them.
+//
+// A nil Rules has no rules.
+type Rules struct {
+	rules []storage.LifecycleRule
+
+	// namePrefix is prepended to the names of the objects, e.g. the only-dir
+	// of the mount, to match the names the rules apply to.
+	namePrefix string
+}
+
+// New returns the rules out of the supplied ones that can be evaluated, for
+// the objects named after namePrefix, or nil if there are none.
+func New(rules []storage.LifecycleRule, namePrefix string) *Rules {
+	var supported []storage.LifecycleRule
+	for _, r := range rules {
+		if isSupported(r) {
+			supported = append(supported, r)
+		}
+	}
+	if len(supported) == 0 {
+		return nil
+	}
+	return &Rules{rules: supported, namePrefix: namePrefix}
+}
+
+func isSupported(r storage.LifecycleRule) bool {
+	c := r.Condition
+	return (r.Action.Type == storage.DeleteAction || r.Action.Type == storage.SetStorageClassAction) &&
+		c.Liveness != storage.Archived &&
+		c.CustomTimeBefore.IsZero() &&
+		c.DaysSinceCustomTime == 0 &&
+		c.DaysSinceNoncurrentTime == 0 &&
+		c.NoncurrentTimeBefore.IsZero() &&
+		c.NumNewerVersions == 0 &&
+		len(c.MatchesStorageClasses) == 0
+}
+
+func matchesName(c storage.LifecycleCondition, name string) bool {
+	if len(c.MatchesPrefix) > 0 && !slices.ContainsFunc(c.MatchesPrefix, func(p string) bool { return strings.HasPrefix(name, p) }) {
+		return false
+	}
+	if len(c.MatchesSuffix) > 0 && !slices.ContainsFunc(c.MatchesSuffix, func(s string) bool { return strings.HasSuffix(name, s) }) {
+		return false
+	}
+	return true
+}
+
+// Expiry returns the earliest action of the rules on the object of the
+// supplied name created at created, or false if none applies to it. Deletions
+// take precedence over the changes of storage class at the same time.
+func (r *Rules) Expiry(name string, created time.Time) (e Expiry, ok bool) {
+	if r == nil {
+		return
+	}
+
+	name = r.namePrefix + name
+	for _, rule := range r.rules {
+		c := rule.Condition
+		if !matchesName(c, name) {
+			continue
+		}
+		if !c.CreatedBefore.IsZero() && !created.Before(c.CreatedBefore) {
+			continue
+		}
+
+		at := created.AddDate(0, 0, int(c.AgeInDays))
+		if ok && (at.After(e.At) || (at.Equal(e.At) && e.Action == storage.DeleteAction)) {
+			continue
+		}
+		e = Expiry{Action: rule.Action.Type, StorageClass: rule.Action.StorageClass, At: at}
+		ok = true
+	}
+	return
+}
+
+// Expiring returns the earliest action of the rules on the object of the
+// supplied name created at created that GCS can take before now+window, or
+// false if there's none.
+func (r *Rules) Expiring(name string, created time.Time, now time.Time, window time.Duration) (e Expiry, ok bool) {
+	e, ok = r.Expiry(name, created)
+	if !ok || e.At.After(now.Add(window)) {
+		return Expiry{}, false
+	}
+	return
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func deleteAfter(days int64) storage.LifecycleRule {
+	return storage.LifecycleRule{
+		Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+		Condition: storage.LifecycleCondition{AgeInDays: days},
+	}
+}
+
+func TestNew_NoSupportedRules(t *testing.T) {
+	rules := New([]storage.LifecycleRule{
+		{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "NEARLINE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 30, MatchesStorageClasses: []string{"STANDARD"}},
+		},
+		{
+			Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{NumNewerVersions: 3},
+		},
+		{
+			Action:    storage.LifecycleAction{Type: storage.AbortIncompleteMPUAction},
+			Condition: storage.LifecycleCondition{AgeInDays: 7},
+		},
+	}, "")
+
+	assert.Nil(t, rules)
+	_, ok := rules.Expiry("a", created)
+	assert.False(t, ok)
+}
+
+func TestExpiry_Age(t *testing.T) {
+	rules := New([]storage.LifecycleRule{deleteAfter(30)}, "")
+
+	e, ok := rules.Expiry("a", created)
+
+	require.True(t, ok)
+	assert.Equal(t, storage.DeleteAction, e.Action)
+	assert.Equal(t, created.AddDate(0, 0, 30), e.At)
+	assert.Equal(t, "Delete 2024-02-01T03:04:05Z", e.String())
+}
+
+func TestExpiry_Earliest(t *testing.T) {
+	rules := New([]storage.LifecycleRule{
+		deleteAfter(30),
+		{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "ARCHIVE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 10},
+		},
+		{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "COLDLINE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 30},
+		},
+	}, "")
+
+	e, ok := rules.Expiry("a", created)
+
+	require.True(t, ok)
+	assert.Equal(t, "SetStorageClass ARCHIVE 2024-01-12T03:04:05Z", e.String())
+}
+
+func TestExpiry_DeletionTakesPrecedence(t *testing.T) {
+	rules := New([]storage.LifecycleRule{
+		{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "ARCHIVE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 30},
+		},
+		deleteAfter(30),
+	}, "")
+
+	e, ok := rules.Expiry("a", created)
+
+	require.True(t, ok)
+	assert.Equal(t, storage.DeleteAction, e.Action)
+}
+
+func TestExpiry_Name(t *testing.T) {
+	rule := deleteAfter(1)
+	rule.Condition.MatchesPrefix = []string{"logs/", "tmp/"}
+	rule.Condition.MatchesSuffix = []string{".log"}
+	rules := New([]storage.LifecycleRule{rule}, "")
+
+	_, ok := rules.Expiry("tmp/a.log", created)
+	assert.True(t, ok)
+	_, ok = rules.Expiry("tmp/a.txt", created)
+	assert.False(t, ok)
+	_, ok = rules.Expiry("data/a.log", created)
+	assert.False(t, ok)
+}
+
+func TestExpiry_NamePrefix(t *testing.T) {
+	rule := deleteAfter(1)
+	rule.Condition.MatchesPrefix = []string{"dir/logs/"}
+	rules := New([]storage.LifecycleRule{rule}, "dir/")
+
+	_, ok := rules.Expiry("logs/a", created)
+
+	assert.True(t, ok)
+}
+
+func TestExpiry_CreatedBefore(t *testing.T) {
+	rules := New([]storage.LifecycleRule{{
+		Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+		Condition: storage.LifecycleCondition{CreatedBefore: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}}, "")
+
+	e, ok := rules.Expiry("a", created.AddDate(0, 0, -1))
+	require.True(t, ok)
+	assert.Equal(t, created.AddDate(0, 0, -1), e.At)
+	_, ok = rules.Expiry("a", created)
+	assert.False(t, ok)
+}
+
+func TestExpiring(t *testing.T) {
+	rules := New([]storage.LifecycleRule{deleteAfter(30)}, "")
+	expiry := created.AddDate(0, 0, 30)
+
+	_, ok := rules.Expiring("a", created, expiry.Add(-48*time.Hour), 24*time.Hour)
+	assert.False(t, ok)
+	_, ok = rules.Expiring("a", created, expiry.Add(-12*time.Hour), 24*time.Hour)
+	assert.True(t, ok)
+	// Objects which could already have been deleted are expiring too.
+	_, ok = rules.Expiring("a", created, expiry.Add(time.Hour), 0)
+	assert.True(t, ok)
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package locality compares the location of a bucket against the location of
+// the VM on which gcsfuse is running. Cross-region mounts are one of the most
+// common reasons for poor gcsfuse throughput, so they are surfaced early.
+package locality
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// Bucket location types as reported by GCS.
+const (
+	LocationTypeRegion      = "region"
+	LocationTypeDualRegion  = "dual-region"
+	LocationTypeMultiRegion = "multi-region"
+)
+
+// Reasons reported by Advise when the bucket and the VM are not co-located.
+const (
+	ReasonRegionMismatch = "region_mismatch"
+	ReasonMultiRegion    = "multi_region"
+)
+
+// Egress localities reported by EgressLocality.
+const (
+	EgressSameRegion  = "same_region"
+	EgressCrossRegion = "cross_region"
+	EgressInternet    = "internet"
+	EgressUnknown     = "unknown"
+)
+
+// multiRegionPrefixes maps the multi-regions to the prefix of their regions.
+var multiRegionPrefixes = map[string]string{
+	"ASIA": "asia-",
+	"EU":   "europe-",
+	"US":   "us-",
+}
+
+// predefinedDualRegions maps the locations of the predefined dual-regions to
+// their regions. The regions of configurable dual-regions are instead reported
+// as the data locations of the bucket.
+var predefinedDualRegions = map[string][]string{
+	"ASIA1": {"asia-northeast1", "asia-northeast2"},
+	"EUR4":  {"europe-north1", "europe-west4"},
+	"EUR5":  {"europe-west1", "europe-west2"},
+	"EUR7":  {"europe-west2", "europe-west3"},
+	"EUR8":  {"europe-west3", "europe-west6"},
+	"NAM4":  {"us-central1", "us-east1"},
+}
+
+// vmZone returns the zone of the VM (e.g. "us-central1-a"), or an empty string
+// if gcsfuse isn't running on GCE. Overridden in tests.
+var vmZone = func(ctx context.Context) (string, error) {
+	if !metadata.OnGCE() {
+		return "", nil
+	}
+	return metadata.ZoneWithContext(ctx)
+}
+
+// Advice is the outcome of comparing the location of a bucket against the
+// location of the VM.
+type Advice struct {
+	// Reason is one of the Reason* constants, or empty if no action is needed.
+	Reason string
+
+	// Message is a human-readable explanation meant to be logged as a warning.
+	Message string
+}
+
+// RegionFromZone converts a zone (e.g. "us-central1-a") to its region (e.g.
+// "us-central1"). The full metadata path form
+// ("projects/<num>/zones/us-central1-a") is also accepted.
+func RegionFromZone(zone string) string {
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// Advise compares the bucket location with the VM zone. throughputCritical
+// should be set when the mount is configured for high throughput, in which
+// case multi-region and dual-region buckets are reported as well.
+func Advise(bucketName, bucketLocation, bucketLocationType, zone string, throughputCritical bool) Advice {
+	if zone == "" || bucketLocation == "" {
+		return Advice{}
+	}
+
+	vmRegion := RegionFromZone(zone)
+	switch strings.ToLower(bucketLocationType) {
+	case LocationTypeRegion:
+		if !strings.EqualFold(bucketLocation, vmRegion) {
+			return Advice{
+				Reason: ReasonRegionMismatch,
+				Message: fmt.Sprintf("Bucket %q is located in region %s but this VM is in zone %s (region %s). "+
+					"Cross-region access adds latency and egress charges; for the best performance, "+
+					"use a bucket in the same region as the VM.", bucketName, strings.ToLower(bucketLocation), zone, vmRegion),
+			}
+		}
+	case LocationTypeDualRegion, LocationTypeMultiRegion:
+		if throughputCritical {
+			return Advice{
+				Reason: ReasonMultiRegion,
+				Message: fmt.Sprintf("Bucket %q is a %s bucket (%s) and this VM is in zone %s. "+
+					"For throughput-critical workloads, a regional bucket in %s is recommended.",
+					bucketName, strings.ToLower(bucketLocationType), bucketLocation, zone, vmRegion),
+			}
+		}
+	}
+	return Advice{}
+}
+
+// NearestReadRegion returns the region of a dual-region bucket which contains
+// the VM in the given zone, or an empty string if there is none, e.g. because
+// the bucket isn't a dual-region bucket. dataLocations are the regions of
+// configurable dual-region buckets.
+func NearestReadRegion(bucketLocation, bucketLocationType string, dataLocations []string, zone string) string {
+	if zone == "" || !strings.EqualFold(bucketLocationType, LocationTypeDualRegion) {
+		return ""
+	}
+
+	regions := dataLocations
+	if len(regions) == 0 {
+		regions = predefinedDualRegions[strings.ToUpper(bucketLocation)]
+	}
+	vmRegion := RegionFromZone(zone)
+	for _, region := range regions {
+		if strings.EqualFold(region, vmRegion) {
+			return vmRegion
+		}
+	}
+	return ""
+}
+
+// EgressLocality returns where the bytes read from the bucket travel to reach
+// the VM in the given zone, as billed: EgressSameRegion if the bucket has a
+// region containing the VM, EgressCrossRegion if it hasn't, EgressInternet if
+// gcsfuse isn't running on GCE (zone is empty) and EgressUnknown if the
+// location type of the bucket is unknown. The regions of the multi-regions are
+// approximated by the prefix of their names.
+func EgressLocality(bucketLocation, bucketLocationType string, dataLocations []string, zone string) string {
+	if zone == "" {
+		return EgressInternet
+	}
+
+	vmRegion := RegionFromZone(zone)
+	sameRegion := false
+	switch strings.ToLower(bucketLocationType) {
+	case LocationTypeRegion:
+		sameRegion = strings.EqualFold(bucketLocation, vmRegion)
+	case LocationTypeDualRegion:
+		sameRegion = NearestReadRegion(bucketLocation, bucketLocationType, dataLocations, zone) != ""
+	case LocationTypeMultiRegion:
+		prefix, ok := multiRegionPrefixes[strings.ToUpper(bucketLocation)]
+		sameRegion = ok && strings.HasPrefix(vmRegion, prefix)
+	default:
+		return EgressUnknown
+	}
+	if sameRegion {
+		return EgressSameRegion
+	}
+	return EgressCrossRegion
+}
+
+// VMZone returns the zone of the VM gcsfuse is running on, or an empty string
+// when not running on GCE.
+func VMZone(ctx context.Context) (string, error) {
+	zone, err := vmZone(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error in fetching zone from metadata server: %w", err)
+	}
+	return zone, nil
+}
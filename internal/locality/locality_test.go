@@ -0,0 +1,260 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionFromZone(t *testing.T) {
+	testCases := []struct {
+		zone string
+		want string
+	}{
+		{zone: "us-central1-a", want: "us-central1"},
+		{zone: "europe-west4-b", want: "europe-west4"},
+		{zone: "projects/123456/zones/asia-south1-c", want: "asia-south1"},
+		{zone: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.zone, func(t *testing.T) {
+			assert.Equal(t, tc.want, RegionFromZone(tc.zone))
+		})
+	}
+}
+
+func TestAdvise(t *testing.T) {
+	testCases := []struct {
+		name               string
+		location           string
+		locationType       string
+		zone               string
+		throughputCritical bool
+		wantReason         string
+	}{
+		{
+			name:         "same_region",
+			location:     "US-CENTRAL1",
+			locationType: LocationTypeRegion,
+			zone:         "us-central1-a",
+			wantReason:   "",
+		},
+		{
+			name:         "different_region",
+			location:     "EUROPE-WEST4",
+			locationType: LocationTypeRegion,
+			zone:         "us-central1-a",
+			wantReason:   ReasonRegionMismatch,
+		},
+		{
+			name:         "multi_region_not_throughput_critical",
+			location:     "US",
+			locationType: LocationTypeMultiRegion,
+			zone:         "us-central1-a",
+			wantReason:   "",
+		},
+		{
+			name:               "multi_region_throughput_critical",
+			location:           "US",
+			locationType:       LocationTypeMultiRegion,
+			zone:               "us-central1-a",
+			throughputCritical: true,
+			wantReason:         ReasonMultiRegion,
+		},
+		{
+			name:               "dual_region_throughput_critical",
+			location:           "NAM4",
+			locationType:       LocationTypeDualRegion,
+			zone:               "us-central1-a",
+			throughputCritical: true,
+			wantReason:         ReasonMultiRegion,
+		},
+		{
+			name:         "not_on_gce",
+			location:     "EUROPE-WEST4",
+			locationType: LocationTypeRegion,
+			zone:         "",
+			wantReason:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			advice := Advise("bucket", tc.location, tc.locationType, tc.zone, tc.throughputCritical)
+
+			assert.Equal(t, tc.wantReason, advice.Reason)
+			if tc.wantReason == "" {
+				assert.Empty(t, advice.Message)
+			} else {
+				assert.Contains(t, advice.Message, "bucket")
+				assert.Contains(t, advice.Message, tc.zone)
+			}
+		})
+	}
+}
+
+func TestNearestReadRegion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		location      string
+		locationType  string
+		dataLocations []string
+		zone          string
+		want          string
+	}{
+		{
+			name:         "predefined_dual_region_containing_vm",
+			location:     "NAM4",
+			locationType: LocationTypeDualRegion,
+			zone:         "us-east1-b",
+			want:         "us-east1",
+		},
+		{
+			name:         "predefined_dual_region_not_containing_vm",
+			location:     "EUR4",
+			locationType: LocationTypeDualRegion,
+			zone:         "us-east1-b",
+			want:         "",
+		},
+		{
+			name:          "configurable_dual_region_containing_vm",
+			location:      "US",
+			locationType:  LocationTypeDualRegion,
+			dataLocations: []string{"US-EAST4", "US-WEST1"},
+			zone:          "us-west1-a",
+			want:          "us-west1",
+		},
+		{
+			name:         "region",
+			location:     "US-EAST1",
+			locationType: LocationTypeRegion,
+			zone:         "us-east1-b",
+			want:         "",
+		},
+		{
+			name:         "multi_region",
+			location:     "US",
+			locationType: LocationTypeMultiRegion,
+			zone:         "us-east1-b",
+			want:         "",
+		},
+		{
+			name:         "not_on_gce",
+			location:     "NAM4",
+			locationType: LocationTypeDualRegion,
+			zone:         "",
+			want:         "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NearestReadRegion(tc.location, tc.locationType, tc.dataLocations, tc.zone))
+		})
+	}
+}
+
+func TestEgressLocality(t *testing.T) {
+	testCases := []struct {
+		name          string
+		location      string
+		locationType  string
+		dataLocations []string
+		zone          string
+		want          string
+	}{
+		{
+			name:         "same_region",
+			location:     "US-CENTRAL1",
+			locationType: LocationTypeRegion,
+			zone:         "us-central1-a",
+			want:         EgressSameRegion,
+		},
+		{
+			name:         "cross_region",
+			location:     "EUROPE-WEST4",
+			locationType: LocationTypeRegion,
+			zone:         "us-central1-a",
+			want:         EgressCrossRegion,
+		},
+		{
+			name:         "dual_region_containing_vm",
+			location:     "NAM4",
+			locationType: LocationTypeDualRegion,
+			zone:         "us-east1-b",
+			want:         EgressSameRegion,
+		},
+		{
+			name:          "configurable_dual_region_not_containing_vm",
+			location:      "US",
+			locationType:  LocationTypeDualRegion,
+			dataLocations: []string{"US-EAST4", "US-WEST1"},
+			zone:          "us-central1-a",
+			want:          EgressCrossRegion,
+		},
+		{
+			name:         "multi_region_containing_vm",
+			location:     "EU",
+			locationType: LocationTypeMultiRegion,
+			zone:         "europe-west1-b",
+			want:         EgressSameRegion,
+		},
+		{
+			name:         "multi_region_not_containing_vm",
+			location:     "US",
+			locationType: LocationTypeMultiRegion,
+			zone:         "asia-south1-c",
+			want:         EgressCrossRegion,
+		},
+		{
+			name:         "not_on_gce",
+			location:     "US-CENTRAL1",
+			locationType: LocationTypeRegion,
+			zone:         "",
+			want:         EgressInternet,
+		},
+		{
+			name:     "unknown_location_type",
+			location: "US-CENTRAL1",
+			zone:     "us-central1-a",
+			want:     EgressUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, EgressLocality(tc.location, tc.locationType, tc.dataLocations, tc.zone))
+		})
+	}
+}
+
+func TestVMZone(t *testing.T) {
+	defer func(f func(context.Context) (string, error)) { vmZone = f }(vmZone)
+
+	vmZone = func(context.Context) (string, error) { return "us-east1-b", nil }
+	zone, err := VMZone(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "us-east1-b", zone)
+
+	vmZone = func(context.Context) (string, error) { return "", fmt.Errorf("metadata server unreachable") }
+	_, err = VMZone(context.Background())
+	assert.ErrorContains(t, err, "metadata server unreachable")
+}
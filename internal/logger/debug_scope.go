@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DebugScope selects the file system ops logged at DEBUG severity whatever the
+// severity of the logs, e.g. only the reads of the files under a directory, to
+// debug a single problematic path without the volume of the debug logs of the
+// whole mount.
+type DebugScope struct {
+	// The prefix of the paths of the ops, relative to the root of the mount,
+	// e.g. "models/". All the paths if empty.
+	PathPrefix string
+
+	// The names of the ops, e.g. "ReadFile". All the ops if empty.
+	Ops []string
+}
+
+// MatchesOp returns true if the op with the supplied name is in the scope.
+func (s *DebugScope) MatchesOp(op string) bool {
+	return len(s.Ops) == 0 || slices.Contains(s.Ops, op)
+}
+
+// MatchesPath returns true if the supplied path, relative to the root of the
+// mount, is in the scope.
+func (s *DebugScope) MatchesPath(path string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(path, "/"), strings.TrimPrefix(s.PathPrefix, "/"))
+}
+
+func (s *DebugScope) String() string {
+	ops := "all"
+	if len(s.Ops) > 0 {
+		ops = strings.Join(s.Ops, ",")
+	}
+	return fmt.Sprintf("ops %s under %q", ops, "/"+strings.TrimPrefix(s.PathPrefix, "/"))
+}
+
+var debugScope atomic.Pointer[DebugScope]
+
+// SetDebugScope sets the ops logged at DEBUG severity whatever the severity of
+// the logs, or clears it if nil.
+func SetDebugScope(s *DebugScope) {
+	debugScope.Store(s)
+}
+
+// CurrentDebugScope returns the scope set with SetDebugScope, nil if none is.
+func CurrentDebugScope() *DebugScope {
+	return debugScope.Load()
+}
+
+type scopedKey struct{}
+
+// isScoped returns true if the record logged with ctx is in the debug scope,
+// and so written whatever the levels of the handlers.
+func isScoped(ctx context.Context) bool {
+	scoped, _ := ctx.Value(scopedKey{}).(bool)
+	return scoped
+}
+
+// ScopedDebugf prints the message with DEBUG severity in the specified format,
+// whatever the severity of the logs. It's meant for the ops in the debug scope,
+// which the callers check with CurrentDebugScope.
+func ScopedDebugf(format string, v ...interface{}) {
+	r := slog.NewRecord(time.Now(), LevelDebug, fmt.Sprintf(format, v...), 0)
+	_ = defaultLogger.Handler().Handle(context.WithValue(context.Background(), scopedKey{}, true), r)
+}
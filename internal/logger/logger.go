@@ -22,6 +22,8 @@ import (
 	"log/syslog"
 	"os"
 	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -40,6 +42,9 @@ const (
 var (
 	defaultLoggerFactory *loggerFactory
 	defaultLogger        *slog.Logger
+
+	// Serializes the changes of the severity at runtime.
+	severityMu sync.Mutex
 )
 
 // InitLogFile initializes the logger factory to create loggers that print to
@@ -49,33 +54,33 @@ var (
 // config.
 // Here, background true means, this InitLogFile has been called for the
 // background daemon.
+//
+// With logging.sinks, the logs are written to each of the sinks instead, at
+// its own severity.
 func InitLogFile(newLogConfig cfg.LoggingConfig) error {
 	var f *os.File
 	var sysWriter *syslog.Writer
-	var fileWriter *lumberjack.Logger
+	var fileWriter *rotatingWriter
+	var sinks []sink
 	var err error
-	if newLogConfig.FilePath != "" {
-		f, err = os.OpenFile(
-			string(newLogConfig.FilePath),
-			os.O_WRONLY|os.O_CREATE|os.O_APPEND,
-			0644,
-		)
+	if len(newLogConfig.Sinks) > 0 {
+		f, fileWriter, sinks, err = openSinks(newLogConfig)
 		if err != nil {
 			return err
 		}
-		fileWriter = &lumberjack.Logger{
-			Filename:   f.Name(),
-			MaxSize:    int(newLogConfig.LogRotate.MaxFileSizeMb),
-			MaxBackups: int(newLogConfig.LogRotate.BackupFileCount),
-			Compress:   newLogConfig.LogRotate.Compress,
+	} else if newLogConfig.FilePath != "" {
+		f, fileWriter, err = openLogFile(newLogConfig)
+		if err != nil {
+			return err
 		}
 	} else {
 		if _, ok := os.LookupEnv(GCSFuseInBackgroundMode); ok {
 			// Priority consist of facility and severity, here facility to specify the
 			// type of system that is logging the message to syslog and severity is log-level.
 			// User applications are allowed to take facility value between LOG_LOCAL0
-			// to LOG_LOCAL7. We are using LOG_LOCAL7 as facility and LOG_DEBUG to write
-			// debug messages.
+			// to LOG_LOCAL7. We are using LOG_LOCAL7 as facility and LOG_DEBUG as the
+			// default severity, the records being written with the severity of their
+			// level.
 
 			// Suppressing the error while creating the syslog, although logger will
 			// be initialised with stdout/err, log will be printed anywhere. Because,
@@ -85,26 +90,50 @@ func InitLogFile(newLogConfig cfg.LoggingConfig) error {
 	}
 
 	defaultLoggerFactory = &loggerFactory{
-		file:       f,
-		sysWriter:  sysWriter,
-		fileWriter: fileWriter,
-		format:     newLogConfig.Format,
-		level:      string(newLogConfig.Severity),
-		logRotate:  newLogConfig.LogRotate,
+		file:         f,
+		sysWriter:    sysWriter,
+		fileWriter:   fileWriter,
+		sinks:        sinks,
+		format:       newLogConfig.Format,
+		level:        string(newLogConfig.Severity),
+		logRotate:    newLogConfig.LogRotate,
+		recentErrors: newRecentErrors(int(newLogConfig.RecentErrorsCount)),
 	}
 	defaultLogger = defaultLoggerFactory.newLogger(string(newLogConfig.Severity))
+	setSlowOps(newLogConfig.SlowOps)
 
 	return nil
 }
 
+// openLogFile opens logging.file-path, returning the file along with the
+// writer rotating it.
+func openLogFile(newLogConfig cfg.LoggingConfig) (*os.File, *rotatingWriter, error) {
+	f, err := os.OpenFile(
+		string(newLogConfig.FilePath),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND,
+		0644,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileWriter := newRotatingWriter(&lumberjack.Logger{
+		Filename:   f.Name(),
+		MaxSize:    int(newLogConfig.LogRotate.MaxFileSizeMb),
+		MaxBackups: int(newLogConfig.LogRotate.BackupFileCount),
+		Compress:   newLogConfig.LogRotate.Compress,
+	}, newLogConfig.LogRotate.Interval, time.Now)
+	return f, fileWriter, nil
+}
+
 // init initializes the logger factory to use stdout and stderr.
 func init() {
 	logConfig := cfg.DefaultLoggingConfig()
 	defaultLoggerFactory = &loggerFactory{
-		file:      nil,
-		format:    logConfig.Format,
-		level:     string(logConfig.Severity), // setting log level to INFO by default
-		logRotate: logConfig.LogRotate,
+		file:         nil,
+		format:       logConfig.Format,
+		level:        string(logConfig.Severity), // setting log level to INFO by default
+		logRotate:    logConfig.LogRotate,
+		recentErrors: newRecentErrors(int(logConfig.RecentErrorsCount)),
 	}
 	defaultLogger = defaultLoggerFactory.newLogger(cfg.INFO)
 }
@@ -118,6 +147,32 @@ func SetLogFormat(format string) {
 	defaultLogger = defaultLoggerFactory.newLogger(defaultLoggerFactory.level)
 }
 
+// SetSeverity changes the severity of the logs at runtime, of all the loggers
+// and all the sinks, e.g. to debug an issue without remounting.
+func SetSeverity(severity string) error {
+	var level cfg.LogSeverity
+	if err := level.UnmarshalText([]byte(severity)); err != nil {
+		return err
+	}
+
+	severityMu.Lock()
+	defer severityMu.Unlock()
+	f := defaultLoggerFactory
+	f.level = string(level)
+	setLoggingLevel(f.level, f.sharedLevel())
+	for _, levelVar := range f.sinkLevels() {
+		setLoggingLevel(f.level, levelVar)
+	}
+	return nil
+}
+
+// Severity returns the severity of the logs.
+func Severity() string {
+	severityMu.Lock()
+	defer severityMu.Unlock()
+	return defaultLoggerFactory.level
+}
+
 // Tracef prints the message with TRACE severity in the specified format.
 func Tracef(format string, v ...interface{}) {
 	defaultLogger.Log(context.Background(), LevelTrace, fmt.Sprintf(format, v...))
@@ -167,12 +222,44 @@ type loggerFactory struct {
 	format     string
 	level      string
 	logRotate  cfg.LogRotateLoggingConfig
-	fileWriter *lumberjack.Logger
+	fileWriter *rotatingWriter
+	// If not empty, log to each of these instead, at their own level.
+	sinks []sink
+	// If not nil, the last WARNING and ERROR records are also kept in it.
+	recentErrors *recentErrors
+
+	// The levels shared by the loggers created by the factory, so that
+	// SetSeverity changes all of them: the one of the output, and the ones of
+	// each of the sinks. Created on first use.
+	levelVar      *slog.LevelVar
+	sinkLevelVars []*slog.LevelVar
+}
+
+// sharedLevel returns the level of the loggers of the factory.
+func (f *loggerFactory) sharedLevel() *slog.LevelVar {
+	if f.levelVar == nil {
+		f.levelVar = new(slog.LevelVar)
+	}
+	return f.levelVar
+}
+
+// sinkLevels returns the levels of the sinks of the factory, initially their
+// own.
+func (f *loggerFactory) sinkLevels() []*slog.LevelVar {
+	if len(f.sinkLevelVars) != len(f.sinks) {
+		f.sinkLevelVars = make([]*slog.LevelVar, 0, len(f.sinks))
+		for _, s := range f.sinks {
+			levelVar := new(slog.LevelVar)
+			setLoggingLevel(s.level, levelVar)
+			f.sinkLevelVars = append(f.sinkLevelVars, levelVar)
+		}
+	}
+	return f.sinkLevelVars
 }
 
 func (f *loggerFactory) newLogger(level string) *slog.Logger {
 	// create a new logger
-	var programLevel = new(slog.LevelVar)
+	programLevel := f.sharedLevel()
 	logger := slog.New(f.handler(programLevel, ""))
 	slog.SetDefault(logger)
 	setLoggingLevel(level, programLevel)
@@ -187,12 +274,24 @@ func (f *loggerFactory) createJsonOrTextHandler(writer io.Writer, levelVar *slog
 }
 
 func (f *loggerFactory) handler(levelVar *slog.LevelVar, prefix string) slog.Handler {
+	h := f.outputHandler(levelVar, prefix)
+	if f.recentErrors != nil {
+		return &recentErrorsHandler{Handler: h, recentErrors: f.recentErrors, prefix: prefix}
+	}
+	return h
+}
+
+func (f *loggerFactory) outputHandler(levelVar *slog.LevelVar, prefix string) slog.Handler {
+	if len(f.sinks) > 0 {
+		return f.sinksHandler(prefix)
+	}
+
 	if f.fileWriter != nil {
 		return f.createJsonOrTextHandler(f.fileWriter, levelVar, prefix)
 	}
 
 	if f.sysWriter != nil {
-		return f.createJsonOrTextHandler(f.sysWriter, levelVar, prefix)
+		return f.createPriorityHandler(syslogWriter{w: f.sysWriter}, levelVar, prefix)
 	}
 	return f.createJsonOrTextHandler(os.Stdout, levelVar, prefix)
 }
@@ -16,13 +16,18 @@ package logger
 
 import (
 	"bytes"
+	"io"
 	"log/slog"
+	"log/syslog"
+	"net"
 	"os"
+	"path"
 	"regexp"
 	"testing"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -339,3 +344,211 @@ func (t *LoggerTest) TestSetLogFormatToText() {
 		assert.True(t.T(), expectedRegexp.MatchString(output))
 	}
 }
+
+func (t *LoggerTest) TestRecentErrors() {
+	defaultLoggerFactory = &loggerFactory{
+		format:       "text",
+		recentErrors: newRecentErrors(2),
+	}
+	var buf bytes.Buffer
+	programLevel := new(slog.LevelVar)
+	setLoggingLevel(cfg.ERROR, programLevel)
+	defaultLogger = slog.New(&recentErrorsHandler{
+		Handler:      defaultLoggerFactory.createJsonOrTextHandler(&buf, programLevel, ""),
+		recentErrors: defaultLoggerFactory.recentErrors,
+	})
+
+	Warnf("www.firstWarningExample.com")
+	Infof("www.infoExample.com")
+	Warnf("www.warningExample.com")
+	Errorf("www.errorExample.com")
+
+	var recent bytes.Buffer
+	assert.NoError(t.T(), WriteRecentErrors(&recent))
+	// Only the last two WARNING and ERROR records are kept, irrespective of the
+	// logging severity.
+	assert.Regexp(t.T(), "^time=\"[a-zA-Z0-9/:. ]{26}\" severity=WARNING message=www.warningExample.com\ntime=\"[a-zA-Z0-9/:. ]{26}\" severity=ERROR message=www.errorExample.com\n$", recent.String())
+	assert.NotContains(t.T(), buf.String(), "WARNING")
+	assert.Contains(t.T(), buf.String(), "www.errorExample.com")
+}
+
+func (t *LoggerTest) TestRecentErrorsDisabled() {
+	defaultLoggerFactory = &loggerFactory{
+		format:       "text",
+		recentErrors: newRecentErrors(0),
+	}
+	defaultLogger = defaultLoggerFactory.newLogger(cfg.OFF)
+
+	Errorf("www.errorExample.com")
+
+	var recent bytes.Buffer
+	assert.NoError(t.T(), WriteRecentErrors(&recent))
+	assert.Empty(t.T(), recent.String())
+}
+
+func (t *LoggerTest) TestSetSeverity() {
+	var debugBuf, errorBuf bytes.Buffer
+	defaultLoggerFactory = &loggerFactory{
+		format: "text",
+		level:  cfg.INFO,
+		sinks: []sink{
+			{writer: &debugBuf, level: cfg.DEBUG},
+			{writer: &errorBuf, level: cfg.ERROR},
+		},
+	}
+	defaultLogger = defaultLoggerFactory.newLogger(defaultLoggerFactory.level)
+
+	require.NoError(t.T(), SetSeverity(cfg.TRACE))
+	Tracef("www.traceExample.com")
+
+	// All the sinks get the severity set at runtime.
+	assert.Equal(t.T(), cfg.TRACE, Severity())
+	assert.Contains(t.T(), debugBuf.String(), "severity=TRACE message=www.traceExample.com")
+	assert.Contains(t.T(), errorBuf.String(), "severity=TRACE message=www.traceExample.com")
+	assert.Error(t.T(), SetSeverity("VERBOSE"))
+	assert.Equal(t.T(), cfg.TRACE, Severity())
+}
+
+func (t *LoggerTest) TestScopedDebugf() {
+	var buf, sinkBuf bytes.Buffer
+	defaultLoggerFactory = &loggerFactory{
+		format:       "text",
+		recentErrors: newRecentErrors(10),
+	}
+	programLevel := new(slog.LevelVar)
+	setLoggingLevel(cfg.ERROR, programLevel)
+	defaultLogger = slog.New(&recentErrorsHandler{
+		Handler: multiHandler{
+			defaultLoggerFactory.createJsonOrTextHandler(&buf, programLevel, ""),
+			defaultLoggerFactory.createJsonOrTextHandler(&sinkBuf, programLevel, ""),
+		},
+		recentErrors: defaultLoggerFactory.recentErrors,
+	})
+
+	Debugf("www.debugExample.com")
+	ScopedDebugf("www.%s.com", "scopedExample")
+
+	// The scoped logs are written whatever the severity of the logs.
+	assert.NotContains(t.T(), buf.String(), "www.debugExample.com")
+	assert.Regexp(t.T(), "^time=\"[a-zA-Z0-9/:. ]{26}\" severity=DEBUG message=www.scopedExample.com\n$", buf.String())
+	assert.Equal(t.T(), buf.String(), sinkBuf.String())
+}
+
+func (t *LoggerTest) TestDebugScope() {
+	s := &DebugScope{PathPrefix: "/models/", Ops: []string{"ReadFile"}}
+
+	assert.True(t.T(), s.MatchesOp("ReadFile"))
+	assert.False(t.T(), s.MatchesOp("WriteFile"))
+	assert.True(t.T(), s.MatchesPath("models/a/b.bin"))
+	assert.False(t.T(), s.MatchesPath("data/models/b.bin"))
+	assert.Equal(t.T(), `ops ReadFile under "/models/"`, s.String())
+	assert.True(t.T(), (&DebugScope{}).MatchesOp("WriteFile"))
+	assert.True(t.T(), (&DebugScope{}).MatchesPath(""))
+}
+
+func (t *LoggerTest) TestSinks() {
+	var debugBuf, errorBuf bytes.Buffer
+	defaultLoggerFactory = &loggerFactory{
+		format: "text",
+		level:  cfg.INFO,
+		sinks: []sink{
+			{writer: &debugBuf, level: cfg.DEBUG},
+			{writer: &errorBuf, level: cfg.ERROR},
+		},
+	}
+	defaultLogger = defaultLoggerFactory.newLogger(defaultLoggerFactory.level)
+
+	Tracef("www.traceExample.com")
+	Debugf("www.debugExample.com")
+	Errorf("www.errorExample.com")
+
+	// Each sink gets the records at its own severity, irrespective of the
+	// logging severity.
+	assert.NotContains(t.T(), debugBuf.String(), "www.traceExample.com")
+	assert.Contains(t.T(), debugBuf.String(), "severity=DEBUG message=www.debugExample.com")
+	assert.Contains(t.T(), debugBuf.String(), "severity=ERROR message=www.errorExample.com")
+	assert.Regexp(t.T(), "^time=\"[a-zA-Z0-9/:. ]{26}\" severity=ERROR message=www.errorExample.com\n$", errorBuf.String())
+}
+
+func (t *LoggerTest) TestInitLogFileWithSinks() {
+	filePath := path.Join(t.T().TempDir(), "log.txt")
+	newLogConfig := cfg.LoggingConfig{
+		FilePath: cfg.ResolvedPath(filePath),
+		Severity: "INFO",
+		Format:   "text",
+		Sinks:    []string{"file:trace", "stdout:error"},
+	}
+
+	err := InitLogFile(newLogConfig)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), filePath, defaultLoggerFactory.file.Name())
+	if assert.Len(t.T(), defaultLoggerFactory.sinks, 2) {
+		assert.Equal(t.T(), sink{writer: defaultLoggerFactory.fileWriter, level: cfg.TRACE}, defaultLoggerFactory.sinks[0])
+		assert.Equal(t.T(), sink{writer: os.Stdout, level: cfg.ERROR}, defaultLoggerFactory.sinks[1])
+	}
+	Tracef("www.traceExample.com")
+	contents, err := os.ReadFile(filePath)
+	require.NoError(t.T(), err)
+	assert.Contains(t.T(), string(contents), "severity=TRACE message=www.traceExample.com")
+}
+
+// fakePriorityWriter records the records written with each priority.
+type fakePriorityWriter map[syslog.Priority]*bytes.Buffer
+
+func (w fakePriorityWriter) writerFor(level slog.Level) io.Writer {
+	priority := syslogPriority(level)
+	if w[priority] == nil {
+		w[priority] = new(bytes.Buffer)
+	}
+	return w[priority]
+}
+
+func (t *LoggerTest) TestPriorityHandler() {
+	w := make(fakePriorityWriter)
+	defaultLoggerFactory = &loggerFactory{format: "text", level: cfg.TRACE}
+	levelVar := new(slog.LevelVar)
+	setLoggingLevel(cfg.TRACE, levelVar)
+	defaultLogger = slog.New(defaultLoggerFactory.createPriorityHandler(w, levelVar, ""))
+
+	Tracef("www.traceExample.com")
+	Debugf("www.debugExample.com")
+	Infof("www.infoExample.com")
+	Warnf("www.warningExample.com")
+	Errorf("www.errorExample.com")
+
+	assert.Len(t.T(), w, 4)
+	assert.Contains(t.T(), w[syslog.LOG_DEBUG].String(), "severity=TRACE message=www.traceExample.com")
+	assert.Contains(t.T(), w[syslog.LOG_DEBUG].String(), "severity=DEBUG message=www.debugExample.com")
+	assert.Contains(t.T(), w[syslog.LOG_INFO].String(), "severity=INFO message=www.infoExample.com")
+	assert.Contains(t.T(), w[syslog.LOG_WARNING].String(), "severity=WARNING message=www.warningExample.com")
+	assert.Regexp(t.T(), "severity=ERROR message=www.errorExample.com\n$", w[syslog.LOG_ERR].String())
+}
+
+func (t *LoggerTest) TestJournalWriter() {
+	socket := path.Join(t.T().TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t.T(), err)
+	defer conn.Close()
+	journal, err := newJournalWriter(socket)
+	require.NoError(t.T(), err)
+
+	message := []byte("severity=WARNING message=www.warningExample.com\n")
+
+	n, err := journal.writerFor(LevelWarn).Write(message)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), len(message), n)
+	buf := make([]byte, 1024)
+	n, err = conn.Read(buf)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "PRIORITY=4\nSYSLOG_IDENTIFIER=gcsfuse\nMESSAGE=severity=WARNING message=www.warningExample.com\n", string(buf[:n]))
+}
+
+func (t *LoggerTest) TestAppendJournalFieldWithSeveralLines() {
+	var b bytes.Buffer
+
+	appendJournalField(&b, "MESSAGE", "a\nb")
+
+	assert.Equal(t.T(), "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n", b.String())
+}
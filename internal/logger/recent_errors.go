@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// recentErrors is a ring buffer of the last WARNING and ERROR records, kept in
+// memory so that they can be seen even when the log files have rotated away or
+// the logs written to stdout are lost. It is safe for concurrent use.
+type recentErrors struct {
+	mu sync.Mutex
+
+	// Formats the records as text into buf.
+	//
+	// GUARDED_BY(mu)
+	formatter slog.Handler
+	buf       bytes.Buffer
+
+	// The formatted records, of which next is the oldest once the buffer is
+	// full.
+	//
+	// GUARDED_BY(mu)
+	records [][]byte
+	next    int
+}
+
+// newRecentErrors returns a buffer of the last size records, or nil if size
+// isn't positive.
+func newRecentErrors(size int) *recentErrors {
+	if size <= 0 {
+		return nil
+	}
+	re := &recentErrors{
+		records: make([][]byte, 0, size),
+	}
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(LevelWarn)
+	re.formatter = slog.NewTextHandler(&re.buf, getHandlerOptions(levelVar, "", textFormat))
+	return re
+}
+
+func (re *recentErrors) add(ctx context.Context, r slog.Record) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.buf.Reset()
+	if err := re.formatter.Handle(ctx, r); err != nil {
+		return
+	}
+	record := bytes.Clone(re.buf.Bytes())
+	if len(re.records) < cap(re.records) {
+		re.records = append(re.records, record)
+		return
+	}
+	re.records[re.next] = record
+	re.next = (re.next + 1) % len(re.records)
+}
+
+// writeTo writes the records to w, from the oldest to the newest.
+func (re *recentErrors) writeTo(w io.Writer) error {
+	re.mu.Lock()
+	records := append(append([][]byte(nil), re.records[re.next:]...), re.records[:re.next]...)
+	re.mu.Unlock()
+
+	for _, record := range records {
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recentErrorsHandler is a slog.Handler adding the WARNING and ERROR records to
+// recentErrors, irrespective of the level of the wrapped handler, before
+// passing the records enabled by the wrapped handler, or in the debug scope, to
+// it.
+type recentErrorsHandler struct {
+	slog.Handler
+	recentErrors *recentErrors
+	// Prefixed to the messages, like the wrapped handler does.
+	prefix string
+}
+
+func (h *recentErrorsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h *recentErrorsHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= LevelWarn {
+		prefixed := r.Clone()
+		prefixed.Message = h.prefix + r.Message
+		h.recentErrors.add(ctx, prefixed)
+	}
+	if !h.Handler.Enabled(ctx, r.Level) && !isScoped(ctx) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *recentErrorsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recentErrorsHandler{Handler: h.Handler.WithAttrs(attrs), recentErrors: h.recentErrors, prefix: h.prefix}
+}
+
+func (h *recentErrorsHandler) WithGroup(name string) slog.Handler {
+	return &recentErrorsHandler{Handler: h.Handler.WithGroup(name), recentErrors: h.recentErrors, prefix: h.prefix}
+}
+
+// WriteRecentErrors writes the last WARNING and ERROR logs, as many as
+// configured by logging.recent-errors-count, to w in text format, from the
+// oldest to the newest.
+func WriteRecentErrors(w io.Writer) error {
+	if defaultLoggerFactory.recentErrors == nil {
+		return nil
+	}
+	return defaultLoggerFactory.recentErrors.writeTo(w)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// rotatingWriter writes the logs to the log file, which lumberjack rotates
+// once it reaches its most size, compressing and pruning the rotated files,
+// and which is also rotated at every multiple of interval of the wall clock,
+// if set.
+type rotatingWriter struct {
+	*lumberjack.Logger
+
+	interval time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+
+	// The time from which the log file is rotated on the next write.
+	//
+	// GUARDED_BY(mu)
+	nextRotation time.Time
+}
+
+func newRotatingWriter(l *lumberjack.Logger, interval time.Duration, now func() time.Time) *rotatingWriter {
+	w := &rotatingWriter{
+		Logger:   l,
+		interval: interval,
+		now:      now,
+	}
+	if interval > 0 {
+		w.nextRotation = now().Truncate(interval).Add(interval)
+	}
+	return w
+}
+
+// LOCKS_EXCLUDED(w.mu)
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	if w.interval > 0 {
+		w.rotateIfDue()
+	}
+	return w.Logger.Write(p)
+}
+
+// rotateIfDue rotates the log file if a multiple of the interval has passed
+// since it was last rotated, unless it's empty.
+//
+// LOCKS_EXCLUDED(w.mu)
+func (w *rotatingWriter) rotateIfDue() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	if now.Before(w.nextRotation) {
+		return
+	}
+	w.nextRotation = now.Truncate(w.interval).Add(w.interval)
+
+	if fi, err := os.Stat(w.Filename); err != nil || fi.Size() == 0 {
+		return
+	}
+	// The logs can't report their own failures; lumberjack keeps writing to
+	// the current file, which is rotated at the next interval or by size.
+	_ = w.Logger.Rotate()
+}
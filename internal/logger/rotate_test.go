@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// newTestRotatingWriter returns a writer rotating a log file in a temporary
+// directory every interval of the supplied clock.
+func newTestRotatingWriter(t *testing.T, interval time.Duration, now *time.Time) (*rotatingWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	w := newRotatingWriter(&lumberjack.Logger{
+		Filename: filepath.Join(dir, "gcsfuse.log"),
+		MaxSize:  1,
+	}, interval, func() time.Time { return *now })
+	t.Cleanup(func() { w.Close() })
+	return w, dir
+}
+
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestRotatingWriter_RotatesEveryInterval(t *testing.T) {
+	now := time.Date(2024, 5, 6, 10, 30, 0, 0, time.UTC)
+	w, dir := newTestRotatingWriter(t, time.Hour, &now)
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	now = now.Add(20 * time.Minute)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.Len(t, logFiles(t, dir), 1)
+
+	// 11:00 is past.
+	now = now.Add(20 * time.Minute)
+	_, err = w.Write([]byte("third\n"))
+
+	require.NoError(t, err)
+	files := logFiles(t, dir)
+	require.Len(t, files, 2)
+	current, err := os.ReadFile(filepath.Join(dir, "gcsfuse.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(current))
+	for _, f := range files {
+		if f != "gcsfuse.log" {
+			rotated, err := os.ReadFile(filepath.Join(dir, f))
+			require.NoError(t, err)
+			assert.Equal(t, "first\nsecond\n", string(rotated))
+		}
+	}
+}
+
+func TestRotatingWriter_DoesNotRotateEmptyFiles(t *testing.T) {
+	now := time.Date(2024, 5, 6, 10, 30, 0, 0, time.UTC)
+	w, dir := newTestRotatingWriter(t, time.Hour, &now)
+	require.NoError(t, os.WriteFile(w.Filename, nil, 0644))
+	now = now.Add(time.Hour)
+
+	_, err := w.Write([]byte("first\n"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gcsfuse.log"}, logFiles(t, dir))
+}
+
+func TestRotatingWriter_ZeroIntervalOnlyRotatesBySize(t *testing.T) {
+	now := time.Date(2024, 5, 6, 10, 30, 0, 0, time.UTC)
+	w, dir := newTestRotatingWriter(t, 0, &now)
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	now = now.Add(48 * time.Hour)
+
+	_, err = w.Write([]byte("second\n"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gcsfuse.log"}, logFiles(t, dir))
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"os"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+)
+
+// sink is a destination of the logs, of the records at level or above.
+type sink struct {
+	writer io.Writer
+	level  string
+}
+
+// openSinks opens the sinks of logging.sinks, returning them along with the
+// log file and the writer rotating it if the file is one of them.
+func openSinks(newLogConfig cfg.LoggingConfig) (f *os.File, fileWriter *rotatingWriter, sinks []sink, err error) {
+	parsed, err := cfg.ParseLogSinks(newLogConfig.Sinks, newLogConfig.Severity)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, s := range parsed {
+		var writer io.Writer
+		switch s.Destination {
+		case cfg.LogSinkStdout:
+			writer = os.Stdout
+		case cfg.LogSinkFile:
+			if f, fileWriter, err = openLogFile(newLogConfig); err != nil {
+				return nil, nil, nil, err
+			}
+			writer = fileWriter
+		case cfg.LogSinkSyslog:
+			// Like the syslog used without a log file in the background, see
+			// InitLogFile.
+			sysWriter, err := syslog.New(syslog.LOG_LOCAL7|syslog.LOG_DEBUG, ProgrammeName)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("opening syslog: %w", err)
+			}
+			writer = syslogWriter{w: sysWriter}
+		case cfg.LogSinkJournald:
+			journal, err := newJournalWriter(journalSocket)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("opening journald: %w", err)
+			}
+			writer = journal
+		}
+		sinks = append(sinks, sink{writer: writer, level: string(s.Severity)})
+	}
+	return f, fileWriter, sinks, nil
+}
+
+// sinksHandler returns a handler writing the records to each of the sinks of
+// the factory, at their own level.
+func (f *loggerFactory) sinksHandler(prefix string) slog.Handler {
+	handlers := make(multiHandler, 0, len(f.sinks))
+	levels := f.sinkLevels()
+	for i, s := range f.sinks {
+		handlers = append(handlers, f.createHandler(s.writer, levels[i], prefix))
+	}
+	return handlers
+}
+
+// multiHandler is a slog.Handler passing the records to each of its handlers
+// which is enabled for them, or to all of them for the records of the ops in
+// the debug scope.
+type multiHandler []slog.Handler
+
+func (h multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) || isScoped(ctx) {
+			errs = append(errs, handler.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return handlers
+}
+
+func (h multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return handlers
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+)
+
+// slowOps are the FUSE ops and the GCS requests logged with LogSlowOp.
+type slowOps struct {
+	threshold  time.Duration
+	sampleRate float64
+}
+
+var currentSlowOps atomic.Pointer[slowOps]
+
+// setSlowOps logs the ops slower than c.Threshold, a c.SampleRate fraction of
+// them, with LogSlowOp, or none of them if c.Threshold is 0.
+func setSlowOps(c cfg.SlowOpsLoggingConfig) {
+	if c.Threshold <= 0 {
+		currentSlowOps.Store(nil)
+		return
+	}
+	currentSlowOps.Store(&slowOps{threshold: c.Threshold, sampleRate: c.SampleRate})
+}
+
+// SlowOpThreshold returns the latency above which the ops are logged with
+// LogSlowOp, 0 if they aren't. The callers can skip timing the ops then.
+func SlowOpThreshold() time.Duration {
+	if s := currentSlowOps.Load(); s != nil {
+		return s.threshold
+	}
+	return 0
+}
+
+// LogSlowOp logs op, e.g. "ReadFile" or "GET", which took latency, with attrs
+// describing it, at INFO severity in a structured "slow op" record, if latency
+// is above the threshold and op is sampled.
+func LogSlowOp(op string, latency time.Duration, attrs ...slog.Attr) {
+	s := currentSlowOps.Load()
+	if s == nil || latency < s.threshold {
+		return
+	}
+	if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("op", op), slog.Duration("latency", latency)}, attrs...)
+	defaultLogger.LogAttrs(context.Background(), LevelInfo, "slow op", attrs...)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectSlowOps logs the slow ops configured with c to the returned buffer,
+// in text format.
+func redirectSlowOps(t *testing.T, c cfg.SlowOpsLoggingConfig) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	defaultLoggerFactory = &loggerFactory{format: "text"}
+	programLevel := new(slog.LevelVar)
+	setLoggingLevel(cfg.INFO, programLevel)
+	defaultLogger = slog.New(defaultLoggerFactory.createJsonOrTextHandler(&buf, programLevel, ""))
+	setSlowOps(c)
+	t.Cleanup(func() { setSlowOps(cfg.SlowOpsLoggingConfig{}) })
+	return &buf
+}
+
+func TestLogSlowOp_AboveThreshold(t *testing.T) {
+	buf := redirectSlowOps(t, cfg.SlowOpsLoggingConfig{Threshold: time.Second, SampleRate: 1})
+
+	LogSlowOp("ReadFile", 1500*time.Millisecond, slog.String("path", "models/a.bin"), slog.Int64("offset", 4096))
+	LogSlowOp("ReadFile", 500*time.Millisecond, slog.String("path", "models/b.bin"))
+
+	assert.Equal(t, time.Second, SlowOpThreshold())
+	assert.Regexp(t, "^time=\"[a-zA-Z0-9/:. ]{26}\" severity=INFO message=\"slow op\" op=ReadFile latency=1.5s path=models/a.bin offset=4096\n$", buf.String())
+}
+
+func TestLogSlowOp_NotSampled(t *testing.T) {
+	buf := redirectSlowOps(t, cfg.SlowOpsLoggingConfig{Threshold: time.Second, SampleRate: 0})
+
+	LogSlowOp("ReadFile", time.Minute)
+
+	assert.Empty(t, buf.String())
+}
+
+func TestLogSlowOp_Disabled(t *testing.T) {
+	buf := redirectSlowOps(t, cfg.SlowOpsLoggingConfig{SampleRate: 1})
+
+	LogSlowOp("ReadFile", time.Minute)
+
+	assert.Zero(t, SlowOpThreshold())
+	assert.Empty(t, buf.String())
+}
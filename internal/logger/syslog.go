@@ -0,0 +1,203 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is the socket of the native protocol of journald, see
+// https://systemd.io/JOURNAL_NATIVE_PROTOCOL/.
+const journalSocket = "/run/systemd/journal/socket"
+
+// priorityWriter is a destination writing each record with the priority of
+// its level, e.g. syslog, so that the standard tools can filter them.
+type priorityWriter interface {
+	// writerFor returns the writer of the records of the level.
+	writerFor(level slog.Level) io.Writer
+}
+
+// writerFunc is an io.Writer calling the function.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// syslogPriority returns the syslog severity of the level.
+func syslogPriority(level slog.Level) syslog.Priority {
+	switch {
+	case level >= LevelError:
+		return syslog.LOG_ERR
+	case level >= LevelWarn:
+		return syslog.LOG_WARNING
+	case level >= LevelInfo:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// syslogWriter writes the records to syslog with the priority of their level.
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func (s syslogWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s syslogWriter) writerFor(level slog.Level) io.Writer {
+	priority := syslogPriority(level)
+	return writerFunc(func(p []byte) (int, error) {
+		var err error
+		switch priority {
+		case syslog.LOG_ERR:
+			err = s.w.Err(string(p))
+		case syslog.LOG_WARNING:
+			err = s.w.Warning(string(p))
+		case syslog.LOG_INFO:
+			err = s.w.Info(string(p))
+		default:
+			err = s.w.Debug(string(p))
+		}
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	})
+}
+
+// journalWriter writes the records to journald with the priority of their
+// level, using its native protocol.
+type journalWriter struct {
+	conn *net.UnixConn
+}
+
+// newJournalWriter connects to the journald socket at the path.
+func newJournalWriter(socket string) (*journalWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journalWriter{conn: conn}, nil
+}
+
+func (j *journalWriter) Write(p []byte) (int, error) {
+	return j.send(syslog.LOG_INFO, p)
+}
+
+func (j *journalWriter) writerFor(level slog.Level) io.Writer {
+	priority := syslogPriority(level)
+	return writerFunc(func(p []byte) (int, error) {
+		return j.send(priority, p)
+	})
+}
+
+// send sends the message as a journal entry of the priority.
+func (j *journalWriter) send(priority syslog.Priority, message []byte) (int, error) {
+	var b bytes.Buffer
+	appendJournalField(&b, "PRIORITY", strconv.Itoa(int(priority)))
+	appendJournalField(&b, "SYSLOG_IDENTIFIER", ProgrammeName)
+	appendJournalField(&b, "MESSAGE", strings.TrimSuffix(string(message), "\n"))
+	if _, err := j.conn.Write(b.Bytes()); err != nil {
+		return 0, fmt.Errorf("writing to journald: %w", err)
+	}
+	return len(message), nil
+}
+
+// appendJournalField appends the field to the journal entry, in the binary
+// form if its value has several lines.
+func appendJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// priorityLevels are the levels with their own priority, in increasing order.
+var priorityLevels = []slog.Level{LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+// priorityHandler is a slog.Handler passing the records to the handler of
+// their level, writing them with its priority.
+type priorityHandler struct {
+	// handlers[i] handles the records at priorityLevels[i] or above, below
+	// priorityLevels[i+1], and handlers[0] the ones below too.
+	handlers []slog.Handler
+}
+
+// createPriorityHandler returns a handler writing the records to w with the
+// priority of their level.
+func (f *loggerFactory) createPriorityHandler(w priorityWriter, levelVar *slog.LevelVar, prefix string) slog.Handler {
+	h := &priorityHandler{}
+	for _, level := range priorityLevels {
+		h.handlers = append(h.handlers, f.createJsonOrTextHandler(w.writerFor(level), levelVar, prefix))
+	}
+	return h
+}
+
+// createHandler returns a handler writing the records to the writer, with the
+// priority of their level if it has priorities.
+func (f *loggerFactory) createHandler(writer io.Writer, levelVar *slog.LevelVar, prefix string) slog.Handler {
+	if w, ok := writer.(priorityWriter); ok {
+		return f.createPriorityHandler(w, levelVar, prefix)
+	}
+	return f.createJsonOrTextHandler(writer, levelVar, prefix)
+}
+
+func (h *priorityHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// The handlers share the level.
+	return h.handlers[0].Enabled(ctx, level)
+}
+
+func (h *priorityHandler) Handle(ctx context.Context, r slog.Record) error {
+	i := len(priorityLevels) - 1
+	for i > 0 && r.Level < priorityLevels[i] {
+		i--
+	}
+	return h.handlers[i].Handle(ctx, r)
+}
+
+func (h *priorityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return &priorityHandler{handlers: handlers}
+}
+
+func (h *priorityHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return &priorityHandler{handlers: handlers}
+}
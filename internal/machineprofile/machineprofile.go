@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package machineprofile probes the resources available on the machine
+// gcsfuse is running on. Unlike the machine type, which is only known on GCE,
+// these can be read on any Linux machine.
+package machineprofile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Overridden in tests.
+var (
+	procMeminfoPath = "/proc/meminfo"
+	sysClassNetPath = "/sys/class/net"
+)
+
+// Profile describes the resources available on the machine. Zero values mean
+// that the corresponding resource couldn't be determined.
+type Profile struct {
+	// AvailableMemoryBytes is the memory available for starting new
+	// applications without swapping, as reported by MemAvailable in
+	// /proc/meminfo, or the memory left below the limit of the cgroup of
+	// gcsfuse if that's less.
+	AvailableMemoryBytes uint64
+
+	// MemoryLimitBytes is the memory limit of the cgroup of gcsfuse, e.g. of
+	// the container it runs in, or the total memory of the machine if the
+	// cgroup has no limit.
+	MemoryLimitBytes uint64
+
+	// NumCPU is the number of logical CPUs usable by the process.
+	NumCPU int
+
+	// NICSpeedMbps is the speed of the fastest physical network interface in
+	// megabits per second.
+	NICSpeedMbps int64
+}
+
+func (p Profile) String() string {
+	return fmt.Sprintf("available-memory: %d MiB, memory-limit: %d MiB, cpus: %d, nic-speed: %d Mbps",
+		p.AvailableMemoryBytes>>20, p.MemoryLimitBytes>>20, p.NumCPU, p.NICSpeedMbps)
+}
+
+// Probe returns the profile of the machine. Resources which can't be read are
+// left unset in the returned profile.
+func Probe() Profile {
+	used, limit := MemoryUsage()
+	available := meminfoBytes("MemAvailable:")
+	// In a container, the machine may have plenty of memory available while
+	// the container is about to be OOM-killed.
+	if limit > used && (available == 0 || limit-used < available) {
+		available = limit - used
+	}
+	return Profile{
+		AvailableMemoryBytes: available,
+		MemoryLimitBytes:     limit,
+		NumCPU:               runtime.NumCPU(),
+		NICSpeedMbps:         maxNICSpeedMbps(),
+	}
+}
+
+// meminfoBytes returns the supplied field of /proc/meminfo, e.g.
+// "MemAvailable:", in bytes, or 0 if it can't be read.
+func meminfoBytes(field string) uint64 {
+	f, err := os.Open(procMeminfoPath)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Line format: "MemAvailable:    5548368 kB".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != field || fields[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb << 10
+	}
+	return 0
+}
+
+func maxNICSpeedMbps() int64 {
+	entries, err := os.ReadDir(sysClassNetPath)
+	if err != nil {
+		return 0
+	}
+
+	var maxSpeed int64
+	for _, e := range entries {
+		// Only physical interfaces have a device link; this skips loopback,
+		// bridges and other virtual interfaces.
+		if _, err := os.Stat(filepath.Join(sysClassNetPath, e.Name(), "device")); err != nil {
+			continue
+		}
+		// Reading speed fails for interfaces which are down, and reports -1 for
+		// drivers which don't expose it.
+		b, err := os.ReadFile(filepath.Join(sysClassNetPath, e.Name(), "speed"))
+		if err != nil {
+			continue
+		}
+		speed, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			continue
+		}
+		maxSpeed = max(maxSpeed, speed)
+	}
+	return maxSpeed
+}
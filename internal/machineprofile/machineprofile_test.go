@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machineprofile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// addNIC creates the sysfs entries of a network interface. An empty speed
+// means the speed file doesn't exist.
+func addNIC(t *testing.T, netDir, name, speed string, physical bool) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(netDir, name), 0755))
+	if physical {
+		require.NoError(t, os.MkdirAll(filepath.Join(netDir, name, "device"), 0755))
+	}
+	if speed != "" {
+		writeFile(t, filepath.Join(netDir, name, "speed"), speed+"\n")
+	}
+}
+
+func overridePaths(t *testing.T, meminfo, net string) {
+	t.Helper()
+	oldMeminfo, oldNet := procMeminfoPath, sysClassNetPath
+	procMeminfoPath, sysClassNetPath = meminfo, net
+	t.Cleanup(func() { procMeminfoPath, sysClassNetPath = oldMeminfo, oldNet })
+}
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+	meminfo := filepath.Join(dir, "meminfo")
+	writeFile(t, meminfo, "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\n")
+	netDir := filepath.Join(dir, "net")
+	addNIC(t, netDir, "lo", "", false)
+	addNIC(t, netDir, "eth0", "10000", true)
+	addNIC(t, netDir, "eth1", "100000", true)
+	addNIC(t, netDir, "eth2", "-1", true)
+	addNIC(t, netDir, "veth0", "200000", false)
+	overridePaths(t, meminfo, netDir)
+	overrideCgroupPaths(t, filepath.Join(dir, "missing"), filepath.Join(dir, "missing"))
+
+	p := Probe()
+
+	assert.Equal(t, uint64(8192000)<<10, p.AvailableMemoryBytes)
+	assert.Equal(t, uint64(16384000)<<10, p.MemoryLimitBytes)
+	assert.Equal(t, runtime.NumCPU(), p.NumCPU)
+	assert.Equal(t, int64(100000), p.NICSpeedMbps)
+}
+
+func TestProbeWhenResourcesCannotBeRead(t *testing.T) {
+	dir := t.TempDir()
+	netDir := filepath.Join(dir, "net")
+	addNIC(t, netDir, "eth0", "-1", true)
+	overridePaths(t, filepath.Join(dir, "missing"), netDir)
+	overrideCgroupPaths(t, filepath.Join(dir, "missing"), filepath.Join(dir, "missing"))
+
+	p := Probe()
+
+	assert.Equal(t, uint64(0), p.AvailableMemoryBytes)
+	assert.Equal(t, uint64(0), p.MemoryLimitBytes)
+	assert.Equal(t, int64(0), p.NICSpeedMbps)
+}
+
+func TestProfileString(t *testing.T) {
+	p := Profile{AvailableMemoryBytes: 2 << 30, MemoryLimitBytes: 4 << 30, NumCPU: 8, NICSpeedMbps: 32000}
+
+	assert.Equal(t, "available-memory: 2048 MiB, memory-limit: 4096 MiB, cpus: 8, nic-speed: 32000 Mbps", p.String())
+}
+
+func TestProbeInContainer(t *testing.T) {
+	dir := t.TempDir()
+	meminfo := filepath.Join(dir, "meminfo")
+	writeFile(t, meminfo, "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\n")
+	overridePaths(t, meminfo, filepath.Join(dir, "net"))
+	writeFile(t, filepath.Join(dir, "cgroup"), "0::/pod/gcsfuse\n")
+	writeFile(t, filepath.Join(dir, "sys", "pod/gcsfuse/memory.max"), "536870912\n")
+	writeFile(t, filepath.Join(dir, "sys", "pod/gcsfuse/memory.current"), "134217728\n")
+	writeFile(t, filepath.Join(dir, "sys", "pod/gcsfuse/memory.stat"), "inactive_file 0\n")
+	overrideCgroupPaths(t, filepath.Join(dir, "cgroup"), filepath.Join(dir, "sys"))
+
+	p := Probe()
+
+	assert.Equal(t, uint64(384<<20), p.AvailableMemoryBytes)
+	assert.Equal(t, uint64(512<<20), p.MemoryLimitBytes)
+}
+
+func overrideCgroupPaths(t *testing.T, cgroup, root string) {
+	t.Helper()
+	oldCgroup, oldRoot := procSelfCgroupPath, sysFsCgroupPath
+	procSelfCgroupPath, sysFsCgroupPath = cgroup, root
+	t.Cleanup(func() { procSelfCgroupPath, sysFsCgroupPath = oldCgroup, oldRoot })
+}
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name          string
+		cgroup        string
+		files         map[string]string
+		expectedUsed  uint64
+		expectedLimit uint64
+	}{
+		{
+			name:   "cgroup_v2",
+			cgroup: "0::/pod/gcsfuse\n",
+			files: map[string]string{
+				"pod/gcsfuse/memory.max":     "1000\n",
+				"pod/gcsfuse/memory.current": "800\n",
+				"pod/gcsfuse/memory.stat":    "anon 500\ninactive_file 300\n",
+			},
+			expectedUsed:  500,
+			expectedLimit: 1000,
+		},
+		{
+			name:   "cgroup_v1_on_hybrid_system",
+			cgroup: "4:cpu,memory:/pod\n0::/\n",
+			files: map[string]string{
+				"memory/pod/memory.limit_in_bytes": "1000\n",
+				"memory/pod/memory.usage_in_bytes": "700\n",
+				"memory/pod/memory.stat":           "total_inactive_file 100\n",
+			},
+			expectedUsed:  600,
+			expectedLimit: 1000,
+		},
+		{
+			name:   "no_cgroup_limit",
+			cgroup: "0::/\n",
+			files: map[string]string{
+				"memory.max":     "max\n",
+				"memory.current": "800\n",
+			},
+			expectedUsed:  (16384000 - 8192000) << 10,
+			expectedLimit: 16384000 << 10,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			meminfo := filepath.Join(dir, "meminfo")
+			writeFile(t, meminfo, "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\n")
+			overridePaths(t, meminfo, filepath.Join(dir, "net"))
+			writeFile(t, filepath.Join(dir, "cgroup"), tc.cgroup)
+			for name, content := range tc.files {
+				writeFile(t, filepath.Join(dir, "sys", name), content)
+			}
+			overrideCgroupPaths(t, filepath.Join(dir, "cgroup"), filepath.Join(dir, "sys"))
+
+			used, limit := MemoryUsage()
+
+			assert.Equal(t, tc.expectedUsed, used)
+			assert.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machineprofile
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Overridden in tests.
+var (
+	procSelfCgroupPath = "/proc/self/cgroup"
+	sysFsCgroupPath    = "/sys/fs/cgroup"
+)
+
+// MemoryUsage returns the memory used by the cgroup of gcsfuse and its limit,
+// e.g. the one of the container gcsfuse runs in, or those of the machine if
+// the cgroup has no limit. The memory used excludes the inactive page cache,
+// which the kernel reclaims before running out of memory, like the working set
+// reported by Kubernetes. Both are 0 if they can't be read.
+func MemoryUsage() (usedBytes, limitBytes uint64) {
+	if used, limit, ok := cgroupMemoryUsage(); ok {
+		return used, limit
+	}
+
+	total := meminfoBytes("MemTotal:")
+	available := meminfoBytes("MemAvailable:")
+	if total == 0 || available > total {
+		return 0, 0
+	}
+	return total - available, total
+}
+
+// cgroupMemoryUsage returns the memory usage of the cgroup of gcsfuse, with
+// cgroup v2 or v1, if it has a limit.
+func cgroupMemoryUsage() (usedBytes, limitBytes uint64, ok bool) {
+	f, err := os.Open(procSelfCgroupPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Line format: "0::/system.slice/gcsfuse.service" for cgroup v2, and
+		// "4:memory:/user.slice" for the memory controller of cgroup v1.
+		// Both are listed on hybrid systems, where only one of them has the
+		// memory controller.
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch {
+		case fields[0] == "0" && fields[1] == "":
+			dir := filepath.Join(sysFsCgroupPath, fields[2])
+			usedBytes, limitBytes, ok = readCgroupMemoryUsage(dir, "memory.current", "memory.max", "inactive_file")
+		case containsController(fields[1], "memory"):
+			dir := filepath.Join(sysFsCgroupPath, "memory", fields[2])
+			usedBytes, limitBytes, ok = readCgroupMemoryUsage(dir, "memory.usage_in_bytes", "memory.limit_in_bytes", "total_inactive_file")
+		}
+		if ok {
+			return
+		}
+	}
+	return
+}
+
+func containsController(controllers, controller string) bool {
+	for _, c := range strings.Split(controllers, ",") {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+func readCgroupMemoryUsage(dir, usageFile, limitFile, inactiveFileStat string) (usedBytes, limitBytes uint64, ok bool) {
+	limitBytes, err := readUint(filepath.Join(dir, limitFile))
+	// cgroup v2 writes "max" and v1 a huge number when there's no limit.
+	if err != nil || limitBytes >= 1<<62 {
+		return 0, 0, false
+	}
+	usedBytes, err = readUint(filepath.Join(dir, usageFile))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if inactive := memoryStat(filepath.Join(dir, "memory.stat"), inactiveFileStat); inactive < usedBytes {
+		usedBytes -= inactive
+	}
+	return usedBytes, limitBytes, true
+}
+
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// memoryStat returns the supplied field of the memory.stat file of a cgroup,
+// or 0 if it can't be read.
+func memoryStat(path, field string) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Line format: "inactive_file 1073741824".
+		name, value, found := strings.Cut(scanner.Text(), " ")
+		if !found || name != field {
+			continue
+		}
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	return 0
+}
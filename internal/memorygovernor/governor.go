@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memorygovernor sheds the in-memory caches of gcsfuse when the memory
+// used in its container, or on the machine, gets close to the limit, rather
+// than letting gcsfuse be OOM-killed.
+package memorygovernor
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/machineprofile"
+)
+
+// checkInterval is the period at which the memory usage is checked.
+const checkInterval = time.Second
+
+// Governor evicts the least recently used entries of the registered caches
+// while the memory used exceeds thresholdPercent of the memory limit.
+//
+// A nil *Governor never evicts anything.
+type Governor struct {
+	thresholdPercent uint64
+
+	// Overridden in tests.
+	memoryUsage func() (usedBytes, limitBytes uint64)
+
+	mu sync.Mutex
+
+	// The caches shed, in that order, until enough memory is evicted.
+	//
+	// GUARDED_BY(mu)
+	caches []*lru.Cache
+}
+
+// New returns a governor keeping the memory used below the supplied
+// percentage of the limit, or nil if the percentage is 0.
+func New(thresholdPercent int64) *Governor {
+	if thresholdPercent <= 0 {
+		return nil
+	}
+	return &Governor{
+		thresholdPercent: uint64(thresholdPercent),
+		memoryUsage:      machineprofile.MemoryUsage,
+	}
+}
+
+// Register makes the supplied cache shed under memory pressure.
+//
+// LOCKS_EXCLUDED(g.mu)
+func (g *Governor) Register(c *lru.Cache) {
+	if g == nil || c == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.caches = append(g.caches, c)
+}
+
+// Run sheds the caches every checkInterval, as needed, until ctx is done.
+func (g *Governor) Run(ctx context.Context) {
+	if g == nil {
+		return
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.shed()
+		}
+	}
+}
+
+// shed evicts entries of the caches whose sizes sum up to the memory used
+// beyond the threshold, and returns the size of the evicted entries.
+//
+// LOCKS_EXCLUDED(g.mu)
+func (g *Governor) shed() (evicted uint64) {
+	used, limit := g.memoryUsage()
+	threshold := limit / 100 * g.thresholdPercent
+	if limit == 0 || used <= threshold {
+		return 0
+	}
+	excess := used - threshold
+
+	g.mu.Lock()
+	caches := g.caches
+	g.mu.Unlock()
+
+	for _, c := range caches {
+		if evicted >= excess {
+			break
+		}
+		for _, v := range c.EvictLeastRecentlyUsed(excess - evicted) {
+			evicted += v.Size()
+		}
+	}
+	if evicted == 0 {
+		return 0
+	}
+
+	logger.Warnf("Memory used %d MiB is above %d%% of the %d MiB limit, evicted %d MiB of cached entries", used>>20, g.thresholdPercent, limit>>20, evicted>>20)
+	// The limit applies to the memory held by the process, so return the
+	// evicted entries to the OS rather than waiting for the next GC.
+	debug.FreeOSMemory()
+	return evicted
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorygovernor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testValue uint64
+
+func (v testValue) Size() uint64 {
+	return uint64(v)
+}
+
+func newTestGovernor(used, limit uint64) *Governor {
+	g := New(90)
+	g.memoryUsage = func() (uint64, uint64) { return used, limit }
+	return g
+}
+
+func newFullCache(t *testing.T, keys ...string) *lru.Cache {
+	t.Helper()
+	c := lru.NewCache(uint64(len(keys)) * 100)
+	for _, k := range keys {
+		_, err := c.Insert(k, testValue(100))
+		require.NoError(t, err)
+	}
+	return c
+}
+
+func TestGovernor_ShedsTheExcessFromTheLeastRecentlyUsedEntries(t *testing.T) {
+	g := newTestGovernor(1150, 1000)
+	first := newFullCache(t, "a", "b")
+	second := newFullCache(t, "c", "d")
+	g.Register(first)
+	g.Register(second)
+
+	// The excess above 900 spans the whole first cache and one entry of the
+	// second one.
+	evicted := g.shed()
+
+	assert.Equal(t, uint64(300), evicted)
+	assert.Nil(t, first.LookUp("a"))
+	assert.Nil(t, first.LookUp("b"))
+	assert.Nil(t, second.LookUp("c"))
+	assert.NotNil(t, second.LookUp("d"))
+}
+
+func TestGovernor_DoesNothingBelowTheThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		used  uint64
+		limit uint64
+	}{
+		{name: "below_threshold", used: 900, limit: 1000},
+		{name: "unknown_usage", used: 0, limit: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGovernor(tc.used, tc.limit)
+			c := newFullCache(t, "a")
+			g.Register(c)
+
+			assert.Equal(t, uint64(0), g.shed())
+			assert.NotNil(t, c.LookUp("a"))
+		})
+	}
+}
+
+func TestGovernor_Nil(t *testing.T) {
+	g := New(0)
+
+	assert.Nil(t, g)
+	g.Register(lru.NewCache(100))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	g.Run(ctx)
+}
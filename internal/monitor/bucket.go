@@ -33,17 +33,42 @@ func recordRequest(ctx context.Context, metricHandle common.MetricHandle, method
 	metricHandle.GCSRequestLatency(ctx, latencyMs, []common.MetricAttr{{Key: common.GCSMethod, Value: method}})
 }
 
+// recordRequest records a request of the bucket, its latency and the operation
+// it's billed as.
+func (mb *monitoringBucket) recordRequest(ctx context.Context, method string, start time.Time) {
+	recordRequest(ctx, mb.metricHandle, method, start)
+	mb.cost.recordOp(ctx, mb.metricHandle, method)
+}
+
 // NewMonitoringBucket returns a gcs.Bucket that exports metrics for monitoring
 func NewMonitoringBucket(b gcs.Bucket, m common.MetricHandle) gcs.Bucket {
-	return &monitoringBucket{
+	mb := &monitoringBucket{
 		wrapped:      b,
 		metricHandle: m,
 	}
+	if r, ok := b.(gcs.ReadRegionReporter); ok && r.ReadRegion() != "" {
+		mb.readBytesAttrs = []common.MetricAttr{{Key: common.ReadRegion, Value: r.ReadRegion()}}
+	}
+	return mb
 }
 
 type monitoringBucket struct {
 	wrapped      gcs.Bucket
 	metricHandle common.MetricHandle
+
+	// readBytesAttrs annotate the bytes read with the region serving them, if
+	// known.
+	readBytesAttrs []common.MetricAttr
+
+	// cost records the cost drivers of the requests, with cost estimation.
+	cost *costEstimate
+}
+
+func (mb *monitoringBucket) ReadRegion() string {
+	if r, ok := mb.wrapped.(gcs.ReadRegionReporter); ok {
+		return r.ReadRegion()
+	}
+	return ""
 }
 
 func (mb *monitoringBucket) Name() string {
@@ -61,10 +86,10 @@ func (mb *monitoringBucket) NewReader(
 
 	rc, err = mb.wrapped.NewReader(ctx, req)
 	if err == nil {
-		rc = newMonitoringReadCloser(ctx, req.Name, rc, mb.metricHandle)
+		rc = newMonitoringReadCloser(ctx, req.Name, rc, mb.metricHandle, mb.readBytesAttrs, mb.cost)
 	}
 
-	recordRequest(ctx, mb.metricHandle, "NewReader", startTime)
+	mb.recordRequest(ctx, "NewReader", startTime)
 	return
 }
 
@@ -72,22 +97,35 @@ func (mb *monitoringBucket) CreateObject(
 	ctx context.Context,
 	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
 	startTime := time.Now()
+	if req.Contents != nil {
+		// Don't modify the request of the caller, which may retry it.
+		r := *req
+		r.Contents = &monitoringContents{ctx: ctx, wrapped: req.Contents, metricHandle: mb.metricHandle}
+		req = &r
+	}
 	o, err := mb.wrapped.CreateObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "CreateObject", startTime)
+	mb.recordRequest(ctx, "CreateObject", startTime)
 	return o, err
 }
 
 func (mb *monitoringBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
 	startTime := time.Now()
 	wc, err := mb.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
-	recordRequest(ctx, mb.metricHandle, "CreateObjectChunkWriter", startTime)
-	return wc, err
+	mb.recordRequest(ctx, "CreateObjectChunkWriter", startTime)
+	if err != nil {
+		return nil, err
+	}
+	return &monitoringWriter{Writer: wc, ctx: ctx, metricHandle: mb.metricHandle}, nil
 }
 
 func (mb *monitoringBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
 	startTime := time.Now()
+	if mw, ok := w.(*monitoringWriter); ok {
+		w = mw.Writer
+	}
 	o, err := mb.wrapped.FinalizeUpload(ctx, w)
-	recordRequest(ctx, mb.metricHandle, "FinalizeUpload", startTime)
+	mb.recordRequest(ctx, "FinalizeUpload", startTime)
+	mb.metricHandle.GCSUploadFinalizeLatency(ctx, float64(time.Since(startTime).Microseconds())/1000.0, nil)
 	return o, err
 }
 
@@ -96,7 +134,7 @@ func (mb *monitoringBucket) CopyObject(
 	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
 	startTime := time.Now()
 	o, err := mb.wrapped.CopyObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "CopyObject", startTime)
+	mb.recordRequest(ctx, "CopyObject", startTime)
 	return o, err
 }
 
@@ -105,7 +143,7 @@ func (mb *monitoringBucket) ComposeObjects(
 	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
 	startTime := time.Now()
 	o, err := mb.wrapped.ComposeObjects(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "ComposeObjects", startTime)
+	mb.recordRequest(ctx, "ComposeObjects", startTime)
 	return o, err
 }
 
@@ -114,7 +152,7 @@ func (mb *monitoringBucket) StatObject(
 	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
 	startTime := time.Now()
 	m, e, err := mb.wrapped.StatObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "StatObject", startTime)
+	mb.recordRequest(ctx, "StatObject", startTime)
 	return m, e, err
 }
 
@@ -123,7 +161,7 @@ func (mb *monitoringBucket) ListObjects(
 	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
 	startTime := time.Now()
 	listing, err := mb.wrapped.ListObjects(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "ListObjects", startTime)
+	mb.recordRequest(ctx, "ListObjects", startTime)
 	return listing, err
 }
 
@@ -132,7 +170,7 @@ func (mb *monitoringBucket) UpdateObject(
 	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
 	startTime := time.Now()
 	o, err := mb.wrapped.UpdateObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "UpdateObject", startTime)
+	mb.recordRequest(ctx, "UpdateObject", startTime)
 	return o, err
 }
 
@@ -141,42 +179,42 @@ func (mb *monitoringBucket) DeleteObject(
 	req *gcs.DeleteObjectRequest) error {
 	startTime := time.Now()
 	err := mb.wrapped.DeleteObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "DeleteObject", startTime)
+	mb.recordRequest(ctx, "DeleteObject", startTime)
 	return err
 }
 
 func (mb *monitoringBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
 	startTime := time.Now()
 	o, err := mb.wrapped.MoveObject(ctx, req)
-	recordRequest(ctx, mb.metricHandle, "MoveObject", startTime)
+	mb.recordRequest(ctx, "MoveObject", startTime)
 	return o, err
 }
 
 func (mb *monitoringBucket) DeleteFolder(ctx context.Context, folderName string) error {
 	startTime := time.Now()
 	err := mb.wrapped.DeleteFolder(ctx, folderName)
-	recordRequest(ctx, mb.metricHandle, "DeleteFolder", startTime)
+	mb.recordRequest(ctx, "DeleteFolder", startTime)
 	return err
 }
 
 func (mb *monitoringBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
 	startTime := time.Now()
 	folder, err := mb.wrapped.GetFolder(ctx, folderName)
-	recordRequest(ctx, mb.metricHandle, "GetFolder", startTime)
+	mb.recordRequest(ctx, "GetFolder", startTime)
 	return folder, err
 }
 
 func (mb *monitoringBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
 	startTime := time.Now()
 	folder, err := mb.wrapped.CreateFolder(ctx, folderName)
-	recordRequest(ctx, mb.metricHandle, "CreateFolder", startTime)
+	mb.recordRequest(ctx, "CreateFolder", startTime)
 	return folder, err
 }
 
 func (mb *monitoringBucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (o *gcs.Folder, err error) {
 	startTime := time.Now()
 	o, err = mb.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
-	recordRequest(ctx, mb.metricHandle, "RenameFolder", startTime)
+	mb.recordRequest(ctx, "RenameFolder", startTime)
 	return
 }
 
@@ -186,27 +224,32 @@ func recordReader(ctx context.Context, metricHandle common.MetricHandle, ioMetho
 }
 
 // Monitoring on the object reader
-func newMonitoringReadCloser(ctx context.Context, object string, rc io.ReadCloser, metricHandle common.MetricHandle) io.ReadCloser {
+func newMonitoringReadCloser(ctx context.Context, object string, rc io.ReadCloser, metricHandle common.MetricHandle, readBytesAttrs []common.MetricAttr, cost *costEstimate) io.ReadCloser {
 	recordReader(ctx, metricHandle, "opened")
 	return &monitoringReadCloser{
-		ctx:          ctx,
-		object:       object,
-		wrapped:      rc,
-		metricHandle: metricHandle,
+		ctx:            ctx,
+		object:         object,
+		wrapped:        rc,
+		metricHandle:   metricHandle,
+		readBytesAttrs: readBytesAttrs,
+		cost:           cost,
 	}
 }
 
 type monitoringReadCloser struct {
-	ctx          context.Context
-	object       string
-	wrapped      io.ReadCloser
-	metricHandle common.MetricHandle
+	ctx            context.Context
+	object         string
+	wrapped        io.ReadCloser
+	metricHandle   common.MetricHandle
+	readBytesAttrs []common.MetricAttr
+	cost           *costEstimate
 }
 
 func (mrc *monitoringReadCloser) Read(p []byte) (n int, err error) {
 	n, err = mrc.wrapped.Read(p)
 	if err == nil || err == io.EOF {
-		mrc.metricHandle.GCSReadBytesCount(mrc.ctx, int64(n), nil)
+		mrc.metricHandle.GCSReadBytesCount(mrc.ctx, int64(n), mrc.readBytesAttrs)
+		mrc.cost.recordRead(mrc.ctx, mrc.metricHandle, int64(n))
 	}
 	return
 }
@@ -219,3 +262,34 @@ func (mrc *monitoringReadCloser) Close() (err error) {
 	recordReader(mrc.ctx, mrc.metricHandle, "closed")
 	return
 }
+
+// monitoringContents counts the bytes of the objects created with a single
+// request as they're sent to GCS.
+type monitoringContents struct {
+	ctx          context.Context
+	wrapped      io.Reader
+	metricHandle common.MetricHandle
+}
+
+func (mc *monitoringContents) Read(p []byte) (n int, err error) {
+	n, err = mc.wrapped.Read(p)
+	if n > 0 {
+		mc.metricHandle.GCSWriteBytesCount(mc.ctx, int64(n), nil)
+	}
+	return
+}
+
+// monitoringWriter counts the bytes written to the objects uploaded in chunks.
+type monitoringWriter struct {
+	gcs.Writer
+	ctx          context.Context
+	metricHandle common.MetricHandle
+}
+
+func (mw *monitoringWriter) Write(p []byte) (n int, err error) {
+	n, err = mw.Writer.Write(p)
+	if n > 0 {
+		mw.metricHandle.GCSWriteBytesCount(mw.ctx, int64(n), nil)
+	}
+	return
+}
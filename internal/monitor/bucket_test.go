@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/locality"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type writeBytesMetrics struct {
+	common.MetricHandle
+	bytes map[string]int64
+}
+
+func (m *writeBytesMetrics) GCSWriteBytesCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	bucket := ""
+	for _, a := range attrs {
+		if a.Key == common.BucketName {
+			bucket = a.Value
+		}
+	}
+	m.bytes[bucket] += inc
+}
+
+func newTestMonitoringBucket(bucketName string) (gcs.Bucket, *writeBytesMetrics) {
+	m := &writeBytesMetrics{MetricHandle: common.NewNoopMetrics(), bytes: make(map[string]int64)}
+	b := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	return NewMonitoringBucket(b, common.WithBucketName(m, bucketName)), m
+}
+
+func TestMonitoringBucket_CreateObjectCountsWriteBytes(t *testing.T) {
+	b, m := newTestMonitoringBucket("some_bucket")
+
+	_, err := b.CreateObject(context.Background(), &gcs.CreateObjectRequest{Name: "foo", Contents: strings.NewReader("taco")})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"some_bucket": 4}, m.bytes)
+}
+
+func TestMonitoringBucket_ChunkWriterCountsWriteBytes(t *testing.T) {
+	b, m := newTestMonitoringBucket("")
+	ctx := context.Background()
+	w, err := b.CreateObjectChunkWriter(ctx, &gcs.CreateObjectRequest{Name: "foo"}, 1<<20, nil)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("burrito"))
+	require.NoError(t, err)
+	o, err := b.FinalizeUpload(ctx, w)
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), o.Size)
+	assert.Equal(t, map[string]int64{"": 7}, m.bytes)
+}
+
+type costMetrics struct {
+	common.MetricHandle
+	classAOps      int64
+	classBOps      int64
+	egressBytes    map[string]int64
+	retrievalBytes map[string]int64
+}
+
+func (m *costMetrics) GCSClassAOpCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.classAOps += inc
+}
+
+func (m *costMetrics) GCSClassBOpCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.classBOps += inc
+}
+
+func (m *costMetrics) GCSEgressBytesCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.egressBytes[attrs[0].Value] += inc
+}
+
+func (m *costMetrics) GCSRetrievalBytesCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.retrievalBytes[attrs[0].Value] += inc
+}
+
+func readObject(t *testing.T, b gcs.Bucket, name string) {
+	t.Helper()
+	rc, err := b.NewReader(context.Background(), &gcs.ReadObjectRequest{Name: name})
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+}
+
+func TestCostEstimatingMonitoringBucket(t *testing.T) {
+	m := &costMetrics{MetricHandle: common.NewNoopMetrics(), egressBytes: make(map[string]int64), retrievalBytes: make(map[string]int64)}
+	b := NewCostEstimatingMonitoringBucket(fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), m, nil)
+	ctx := context.Background()
+	_, err := b.CreateObject(ctx, &gcs.CreateObjectRequest{Name: "foo", Contents: strings.NewReader("taco")})
+	require.NoError(t, err)
+	_, _, err = b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t, err)
+	require.NoError(t, b.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: "bar"}))
+
+	// The bytes read before the cost attributes of the bucket are resolved have
+	// an unknown egress locality.
+	readObject(t, b, "foo")
+	b.(*monitoringBucket).cost.resolve("some_bucket", func(context.Context) (CostAttrs, error) {
+		return CostAttrs{EgressLocality: locality.EgressCrossRegion, StorageClass: "coldline"}, nil
+	})
+	readObject(t, b, "foo")
+
+	assert.Equal(t, int64(1), m.classAOps)
+	assert.Equal(t, int64(3), m.classBOps)
+	assert.Equal(t, map[string]int64{locality.EgressUnknown: 4, locality.EgressCrossRegion: 4}, m.egressBytes)
+	assert.Equal(t, map[string]int64{"COLDLINE": 4}, m.retrievalBytes)
+}
+
+func TestCostEstimatingMonitoringBucket_NoRetrievalFees(t *testing.T) {
+	m := &costMetrics{MetricHandle: common.NewNoopMetrics(), egressBytes: make(map[string]int64), retrievalBytes: make(map[string]int64)}
+	b := NewCostEstimatingMonitoringBucket(fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), m, nil)
+	_, err := b.CreateObject(context.Background(), &gcs.CreateObjectRequest{Name: "foo", Contents: strings.NewReader("taco")})
+	require.NoError(t, err)
+
+	b.(*monitoringBucket).cost.resolve("some_bucket", func(context.Context) (CostAttrs, error) {
+		return CostAttrs{EgressLocality: locality.EgressSameRegion, StorageClass: "STANDARD"}, nil
+	})
+	readObject(t, b, "foo")
+
+	assert.Equal(t, map[string]int64{locality.EgressSameRegion: 4}, m.egressBytes)
+	assert.Empty(t, m.retrievalBytes)
+}
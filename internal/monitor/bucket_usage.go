@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/jacobsa/timeutil"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// bucketUsageTTL is how long the bytes stored in a bucket are cached. Cloud
+// Storage samples them once a day.
+const bucketUsageTTL = time.Hour
+
+// BucketUsage reports the bytes stored in a bucket, from the
+// storage/total_bytes metric of Cloud Monitoring, summed over the storage
+// classes, cached for bucketUsageTTL.
+type BucketUsage struct {
+	fetch func(ctx context.Context) (uint64, error)
+	clock timeutil.Clock
+
+	mu sync.Mutex
+	// The bytes fetched last, and when, zero until the first fetch succeeds.
+	//
+	// GUARDED_BY(mu)
+	used    uint64
+	fetched time.Time
+}
+
+// NewBucketUsage returns the usage of the bucket of the project, queried with
+// the credentials in keyFile, or the default credentials when empty.
+func NewBucketUsage(ctx context.Context, project, bucket, keyFile string) (*BucketUsage, error) {
+	var opts []option.ClientOption
+	if keyFile != "" {
+		opts = append(opts, option.WithCredentialsFile(keyFile))
+	}
+	client, err := monitoring.NewMetricClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating the Cloud Monitoring client: %w", err)
+	}
+	return newBucketUsage(timeutil.RealClock(), func(ctx context.Context) (uint64, error) {
+		return queryTotalBytes(ctx, client, project, bucket)
+	}), nil
+}
+
+func newBucketUsage(clock timeutil.Clock, fetch func(ctx context.Context) (uint64, error)) *BucketUsage {
+	return &BucketUsage{fetch: fetch, clock: clock}
+}
+
+// UsedBytes returns the bytes stored in the bucket, fetching them if the
+// cached ones are stale. A failure to refresh them is logged and the stale
+// ones are returned, until the next TTL.
+func (u *BucketUsage) UsedBytes(ctx context.Context) (uint64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.clock.Now()
+	if !u.fetched.IsZero() && now.Sub(u.fetched) < bucketUsageTTL {
+		return u.used, nil
+	}
+	used, err := u.fetch(ctx)
+	if err != nil {
+		if u.fetched.IsZero() {
+			return 0, err
+		}
+		logger.Warnf("Keeping the stale usage of the bucket: %v", err)
+		u.fetched = now
+		return u.used, nil
+	}
+	u.used, u.fetched = used, now
+	return used, nil
+}
+
+// queryTotalBytes returns the latest storage/total_bytes of the bucket, summed
+// over its storage classes.
+func queryTotalBytes(ctx context.Context, client *monitoring.MetricClient, project, bucket string) (uint64, error) {
+	end := time.Now()
+	it := client.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+		Name:   "projects/" + project,
+		Filter: fmt.Sprintf(`metric.type = "storage.googleapis.com/storage/total_bytes" AND resource.labels.bucket_name = %q`, bucket),
+		// The metric is sampled once a day.
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(end.Add(-48 * time.Hour)),
+			EndTime:   timestamppb.New(end),
+		},
+		Aggregation: &monitoringpb.Aggregation{
+			AlignmentPeriod:    durationpb.New(24 * time.Hour),
+			PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_MAX,
+			CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	})
+	ts, err := it.Next()
+	if errors.Is(err, iterator.Done) {
+		return 0, fmt.Errorf("no storage/total_bytes of the bucket %s in the project %s", bucket, project)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("querying the storage/total_bytes of the bucket %s: %w", bucket, err)
+	}
+	// The points are from the newest to the oldest.
+	if len(ts.GetPoints()) == 0 {
+		return 0, fmt.Errorf("no storage/total_bytes of the bucket %s in the project %s", bucket, project)
+	}
+	return uint64(ts.GetPoints()[0].GetValue().GetDoubleValue()), nil
+}
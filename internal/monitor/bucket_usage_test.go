@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFetch struct {
+	used  uint64
+	err   error
+	calls int
+}
+
+func (f *fakeFetch) fetch(ctx context.Context) (uint64, error) {
+	f.calls++
+	return f.used, f.err
+}
+
+func newTestBucketUsage() (*BucketUsage, *fakeFetch, *timeutil.SimulatedClock) {
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	f := &fakeFetch{used: 100}
+	return newBucketUsage(clock, f.fetch), f, clock
+}
+
+func TestBucketUsage_CachesUntilTTL(t *testing.T) {
+	u, f, clock := newTestBucketUsage()
+
+	used, err := u.UsedBytes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), used)
+
+	f.used = 200
+	clock.AdvanceTime(bucketUsageTTL - time.Second)
+	used, err = u.UsedBytes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), used)
+	assert.Equal(t, 1, f.calls)
+
+	clock.AdvanceTime(time.Second)
+	used, err = u.UsedBytes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(200), used)
+	assert.Equal(t, 2, f.calls)
+}
+
+func TestBucketUsage_KeepsStaleOnError(t *testing.T) {
+	u, f, clock := newTestBucketUsage()
+	_, err := u.UsedBytes(context.Background())
+	require.NoError(t, err)
+
+	f.err = errors.New("unavailable")
+	clock.AdvanceTime(bucketUsageTTL)
+	used, err := u.UsedBytes(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), used)
+	// The failure isn't retried until the next TTL.
+	_, _ = u.UsedBytes(context.Background())
+	assert.Equal(t, 2, f.calls)
+}
+
+func TestBucketUsage_ErrorBeforeFirstFetch(t *testing.T) {
+	u, f, _ := newTestBucketUsage()
+	f.err = errors.New("unavailable")
+
+	_, err := u.UsedBytes(context.Background())
+
+	assert.ErrorIs(t, err, f.err)
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/locality"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// Classes of the GCS operations, as billed.
+const (
+	classA = "A"
+	classB = "B"
+)
+
+// opClasses maps the methods of gcs.Bucket to the class of the operation they
+// are billed as. The others, e.g. DeleteObject, are free.
+var opClasses = map[string]string{
+	"CreateObject":            classA,
+	"CreateObjectChunkWriter": classA,
+	"CopyObject":              classA,
+	"ComposeObjects":          classA,
+	"ListObjects":             classA,
+	"UpdateObject":            classA,
+	"MoveObject":              classA,
+	"CreateFolder":            classA,
+	"RenameFolder":            classA,
+	"NewReader":               classB,
+	"StatObject":              classB,
+	"GetFolder":               classB,
+}
+
+// retrievalStorageClasses are the storage classes whose reads incur retrieval
+// fees.
+var retrievalStorageClasses = map[string]bool{
+	"NEARLINE": true,
+	"COLDLINE": true,
+	"ARCHIVE":  true,
+}
+
+// CostAttrs are the attributes of a bucket deciding what the bytes read from
+// it cost.
+type CostAttrs struct {
+	// EgressLocality is where the bytes read travel to reach the VM, one of the
+	// locality.Egress* constants.
+	EgressLocality string
+
+	// StorageClass is the default storage class of the bucket, e.g. "STANDARD",
+	// which approximates the one of its objects.
+	StorageClass string
+}
+
+// CostResolver returns the CostAttrs of a bucket.
+type CostResolver func(ctx context.Context) (CostAttrs, error)
+
+// costEstimate records the cost drivers of the requests of a bucket. The nil
+// *costEstimate records nothing.
+type costEstimate struct {
+	// The attributes of the bytes read, set once the CostAttrs of the bucket
+	// are resolved.
+	egressAttrs    atomic.Pointer[[]common.MetricAttr]
+	retrievalAttrs atomic.Pointer[[]common.MetricAttr]
+}
+
+func newCostEstimate(bucketName string, resolve CostResolver) *costEstimate {
+	c := &costEstimate{}
+	c.setEgressLocality(locality.EgressUnknown)
+	if resolve != nil {
+		go c.resolve(bucketName, resolve)
+	}
+	return c
+}
+
+func (c *costEstimate) setEgressLocality(egressLocality string) {
+	c.egressAttrs.Store(&[]common.MetricAttr{{Key: common.EgressLocality, Value: egressLocality}})
+}
+
+// resolve sets the attributes of the bytes read from the CostAttrs of the
+// bucket. Failures are logged, and the bytes read keep being recorded with an
+// unknown egress locality, as this is only an estimation.
+func (c *costEstimate) resolve(bucketName string, resolve CostResolver) {
+	attrs, err := resolve(context.Background())
+	if err != nil {
+		logger.Debugf("The egress locality of bucket %q is unknown: %v", bucketName, err)
+		return
+	}
+	storageClass := strings.ToUpper(attrs.StorageClass)
+	if retrievalStorageClasses[storageClass] {
+		c.retrievalAttrs.Store(&[]common.MetricAttr{{Key: common.StorageClass, Value: storageClass}})
+	}
+	c.setEgressLocality(attrs.EgressLocality)
+}
+
+// recordOp records the operation the request to the given method of gcs.Bucket
+// is billed as, if any.
+func (c *costEstimate) recordOp(ctx context.Context, metricHandle common.MetricHandle, method string) {
+	if c == nil {
+		return
+	}
+	switch opClasses[method] {
+	case classA:
+		metricHandle.GCSClassAOpCount(ctx, 1, nil)
+	case classB:
+		metricHandle.GCSClassBOpCount(ctx, 1, nil)
+	}
+}
+
+// recordRead records the egress and the retrieval of the bytes read.
+func (c *costEstimate) recordRead(ctx context.Context, metricHandle common.MetricHandle, n int64) {
+	if c == nil {
+		return
+	}
+	metricHandle.GCSEgressBytesCount(ctx, n, *c.egressAttrs.Load())
+	if attrs := c.retrievalAttrs.Load(); attrs != nil {
+		metricHandle.GCSRetrievalBytesCount(ctx, n, *attrs)
+	}
+}
+
+// NewCostEstimatingMonitoringBucket returns the gcs.Bucket of
+// NewMonitoringBucket also recording the cost drivers of its requests: the
+// class A and class B operations, the bytes read by egress locality and, for
+// the storage classes incurring retrieval fees, by storage class. The
+// CostAttrs of the bucket are resolved in the background, not to delay the
+// mount, and the bytes read until then have an unknown egress locality.
+func NewCostEstimatingMonitoringBucket(b gcs.Bucket, m common.MetricHandle, resolve CostResolver) gcs.Bucket {
+	mb := NewMonitoringBucket(b, m).(*monitoringBucket)
+	mb.cost = newCostEstimate(b.Name(), resolve)
+	return mb
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/detectors/gcp"
 	"go.opentelemetry.io/otel"
@@ -132,7 +133,12 @@ func setupPrometheus(port int64) ([]metric.Option, common.ShutdownFn) {
 func serveMetrics(port int64, shutdownCh <-chan context.Context, done chan<- interface{}) {
 	logger.Infof("Serving metrics at localhost:%d/metrics", port)
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	// The OpenMetrics format has the exemplars of the histograms, i.e. the
+	// sampled traces of the ops.
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		promclient.DefaultRegisterer,
+		promhttp.HandlerFor(promclient.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
 	prometheusServer := &http.Server{
 		Addr:           fmt.Sprintf(":%d", port),
 		Handler:        mux,
@@ -159,6 +165,7 @@ func serveMetrics(port int64, shutdownCh <-chan context.Context, done chan<- int
 }
 
 func getResource(ctx context.Context) (*resource.Resource, error) {
+	buildInfo := common.GetBuildInfo()
 	return resource.New(ctx,
 		// Use the GCP resource detector to detect information about the GCP platform
 		resource.WithDetectors(gcp.NewDetector()),
@@ -166,6 +173,10 @@ func getResource(ctx context.Context) (*resource.Resource, error) {
 		resource.WithAttributes(
 			semconv.ServiceName(serviceName),
 			semconv.ServiceVersion(common.GetVersion()),
+			// The provenance of the build, to audit which build runs where.
+			attribute.String("gcsfuse.commit", buildInfo.Commit),
+			attribute.String("gcsfuse.build_time", buildInfo.BuildTime),
+			attribute.String("gcsfuse.builder", buildInfo.Builder),
 		),
 	)
 }
@@ -16,6 +16,7 @@ package monitor
 
 import (
 	"context"
+	"fmt"
 
 	cloudtrace "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
@@ -49,21 +50,58 @@ func SetupTracing(ctx context.Context, c *cfg.Config) common.ShutdownFn {
 func newTraceProvider(ctx context.Context, c *cfg.Config) (trace.TracerProvider, common.ShutdownFn, error) {
 	switch c.Monitoring.ExperimentalTracingMode {
 	case "stdout":
-		return newStdoutTraceProvider()
+		return newStdoutTraceProvider(c)
 	case "gcptrace":
 		return newGCPCloudTraceExporter(ctx, c)
 	default:
 		return nil, nil, nil
 	}
 }
-func newStdoutTraceProvider() (trace.TracerProvider, common.ShutdownFn, error) {
+
+// opSampler samples the root spans, i.e. the ones of the FUSE ops named after
+// them, with the ratio of their op if any, or else with the default one.
+type opSampler struct {
+	ops      map[string]sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// newSampler returns the sampler of the spans with the sampling ratios of
+// monitoring.experimental-tracing-op-sampling-ratios, or else defaultRatio. The
+// spans of the GCS requests of an op, and of the downloads it starts, are
+// sampled with it.
+func newSampler(c *cfg.Config, defaultRatio float64) sdktrace.Sampler {
+	// The ratios are validated with the config.
+	ratios, _ := cfg.ParseTracingOpSamplingRatios(c.Monitoring.ExperimentalTracingOpSamplingRatios)
+	s := opSampler{
+		ops:      make(map[string]sdktrace.Sampler, len(ratios)),
+		fallback: sdktrace.TraceIDRatioBased(defaultRatio),
+	}
+	for op, ratio := range ratios {
+		s.ops[op] = sdktrace.TraceIDRatioBased(ratio)
+	}
+	return sdktrace.ParentBased(s)
+}
+
+func (s opSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if op, ok := s.ops[p.Name]; ok {
+		return op.ShouldSample(p)
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s opSampler) Description() string {
+	return fmt.Sprintf("OpSampler{ops:%d,fallback:%s}", len(s.ops), s.fallback.Description())
+}
+
+func newStdoutTraceProvider(c *cfg.Config) (trace.TracerProvider, common.ShutdownFn, error) {
 	exporter, err := stdouttrace.New(
 		stdouttrace.WithPrettyPrint())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	// All the spans are printed, but the ones of the ops with their own ratio.
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithSampler(newSampler(c, 1)))
 	return tp, tp.Shutdown, nil
 }
 
@@ -85,7 +123,7 @@ func newGCPCloudTraceExporter(ctx context.Context, c *cfg.Config) (*sdktrace.Tra
 		return nil, nil, err
 	}
 
-	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res), sdktrace.WithSampler(sdktrace.TraceIDRatioBased(c.Monitoring.ExperimentalTracingSamplingRatio)))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res), sdktrace.WithSampler(newSampler(c, c.Monitoring.ExperimentalTracingSamplingRatio)))
 
 	return tp, tp.Shutdown, nil
 }
@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewSampler(t *testing.T) {
+	c := &cfg.Config{Monitoring: cfg.MonitoringConfig{ExperimentalTracingOpSamplingRatios: []string{"ReadFile:1", "LookUpInode:0"}}}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithSampler(newSampler(c, 0)))
+	tracer := tp.Tracer("test")
+
+	ctx, readFile := tracer.Start(context.Background(), "ReadFile")
+	// The spans of the GCS requests of an op are sampled with the op.
+	_, request := tracer.Start(ctx, "storage.Reader.Read")
+	request.End()
+	readFile.End()
+	_, lookUp := tracer.Start(context.Background(), "LookUpInode")
+	lookUp.End()
+	_, other := tracer.Start(context.Background(), "WriteFile")
+	other.End()
+
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	assert.Equal(t, []string{"storage.Reader.Read", "ReadFile"}, names)
+}
+
+func TestNewSampler_DefaultRatio(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithSampler(newSampler(&cfg.Config{}, 1)))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "ReadFile")
+	span.End()
+
+	assert.Len(t, recorder.Ended(), 1)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quiesce releases the resources a mount holds while it's idle, e.g.
+// its connections to GCS and its caches, so that the many mostly idle mounts
+// of a node don't hold memory and sockets. They're recreated lazily by the
+// next ops.
+package quiesce
+
+import (
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+)
+
+// Releaser releases the resources registered by the components of a mount.
+// The nil *Releaser releases nothing.
+type Releaser struct {
+	mu sync.Mutex
+
+	// The caches emptied on release.
+	//
+	// GUARDED_BY(mu)
+	caches []*lru.Cache
+
+	// The functions releasing the other resources, e.g. closing connections.
+	//
+	// GUARDED_BY(mu)
+	releases []func()
+}
+
+// New returns the Releaser of a mount released once idle for idleAfter, or
+// nil if idleAfter isn't positive, in which case nothing is ever released.
+func New(idleAfter time.Duration) *Releaser {
+	if idleAfter <= 0 {
+		return nil
+	}
+	return &Releaser{}
+}
+
+// Register makes c emptied on release.
+func (r *Releaser) Register(c *lru.Cache) {
+	if r == nil || c == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches = append(r.caches, c)
+}
+
+// RegisterFunc makes release called on release. It must be safe to call
+// concurrently with the ops, as the mount may stop being idle meanwhile.
+func (r *Releaser) RegisterFunc(release func()) {
+	if r == nil || release == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releases = append(r.releases, release)
+}
+
+// Release releases the registered resources, returning the size of the cache
+// entries evicted, and returns the memory freed to the OS.
+func (r *Releaser) Release() (evicted uint64) {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	caches, releases := r.caches, r.releases
+	r.mu.Unlock()
+
+	for _, release := range releases {
+		release()
+	}
+	for _, c := range caches {
+		for _, v := range c.EvictLeastRecentlyUsed(math.MaxUint64) {
+			evicted += v.Size()
+		}
+	}
+	// Rather than waiting for the next GC, which may not come before the
+	// mount is used again.
+	debug.FreeOSMemory()
+	return evicted
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quiesce
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testValue uint64
+
+func (v testValue) Size() uint64 {
+	return uint64(v)
+}
+
+func TestReleaser_Release(t *testing.T) {
+	r := New(time.Minute)
+	c := lru.NewCache(1000)
+	_, err := c.Insert("a", testValue(100))
+	require.NoError(t, err)
+	_, err = c.Insert("b", testValue(200))
+	require.NoError(t, err)
+	r.Register(c)
+	released := 0
+	r.RegisterFunc(func() { released++ })
+
+	evicted := r.Release()
+
+	assert.Equal(t, uint64(300), evicted)
+	assert.Nil(t, c.LookUp("a"))
+	assert.Nil(t, c.LookUp("b"))
+	assert.Equal(t, 1, released)
+	// The cache keeps being used once released.
+	_, err = c.Insert("a", testValue(100))
+	require.NoError(t, err)
+	assert.NotNil(t, c.LookUp("a"))
+}
+
+func TestReleaser_Disabled(t *testing.T) {
+	r := New(0)
+	c := lru.NewCache(1000)
+	_, err := c.Insert("a", testValue(100))
+	require.NoError(t, err)
+
+	r.Register(c)
+	r.RegisterFunc(func() { t.Fatal("released") })
+
+	assert.Nil(t, r)
+	assert.Equal(t, uint64(0), r.Release())
+	assert.NotNil(t, c.LookUp("a"))
+}
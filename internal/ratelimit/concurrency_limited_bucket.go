@@ -0,0 +1,358 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/semaphore"
+)
+
+// ConcurrencyLimits are the max numbers of concurrent GCS requests of each
+// class. 0 means no limit.
+type ConcurrencyLimits struct {
+	// ListObjects.
+	List int64
+	// StatObject and GetFolder.
+	Stat int64
+	// The read streams, from NewReader until they are closed.
+	Read int64
+	// The percentage of the Read streams reserved for the interactive reads.
+	// It also schedules the read streams across the flows of WithReadFlow with
+	// deficit round robin, instead of first come first served. 0 disables both.
+	ReadMinSharePercent int64
+	// The requests creating, finalizing, copying, composing, updating, moving
+	// or deleting objects and folders.
+	Write int64
+}
+
+// ConcurrencyLimiter holds the requests in flight of each class. It can be
+// shared by the buckets of a mount, to limit the requests of the whole mount.
+type ConcurrencyLimiter struct {
+	list, stat, read, write *requestClass
+}
+
+// NewConcurrencyLimiter returns a limiter of the concurrent requests of each
+// class to the supplied limits, or nil if there are no limits.
+func NewConcurrencyLimiter(limits ConcurrencyLimits) *ConcurrencyLimiter {
+	if limits == (ConcurrencyLimits{}) {
+		return nil
+	}
+	return &ConcurrencyLimiter{
+		list:  newRequestClass("list", limits.List),
+		stat:  newRequestClass("stat", limits.Stat),
+		read:  newReadRequestClass(limits.Read, limits.ReadMinSharePercent),
+		write: newRequestClass("write", limits.Write),
+	}
+}
+
+// requestClass limits the requests in flight of one class.
+type requestClass struct {
+	sem   *semaphore.Weighted
+	attrs []common.MetricAttr
+
+	// fair, if not nil, schedules the read streams fairly in place of sem.
+	fair *fairScheduler
+
+	mu sync.Mutex
+	// The number of requests holding sem.
+	//
+	// GUARDED_BY(mu)
+	inflight int64
+}
+
+// newRequestClass returns nil if limit is 0, i.e. if the requests of the class
+// aren't limited.
+func newRequestClass(name string, limit int64) *requestClass {
+	if limit == 0 {
+		return nil
+	}
+	return &requestClass{
+		sem:   semaphore.NewWeighted(limit),
+		attrs: []common.MetricAttr{{Key: common.RequestClass, Value: name}},
+	}
+}
+
+// newReadRequestClass returns the class of the read streams, scheduled fairly
+// if minSharePercent isn't 0.
+func newReadRequestClass(limit int64, minSharePercent int64) *requestClass {
+	c := newRequestClass("read", limit)
+	if c != nil && minSharePercent > 0 {
+		c.fair = newFairScheduler(limit, max(limit*minSharePercent/100, 1))
+	}
+	return c
+}
+
+// acquire waits for the request to be allowed in flight.
+func (c *requestClass) acquire(ctx context.Context, metricHandle common.MetricHandle) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	c.add(metricHandle, 1)
+	return nil
+}
+
+// acquireRead waits for the read stream of req to be allowed in flight, in
+// the turn of its flow if the streams are scheduled fairly, and returns the
+// function releasing it.
+func (c *requestClass) acquireRead(ctx context.Context, metricHandle common.MetricHandle, req *gcs.ReadObjectRequest) (func(), error) {
+	if c == nil || c.fair == nil {
+		if err := c.acquire(ctx, metricHandle); err != nil {
+			return nil, err
+		}
+		return func() { c.release(metricHandle) }, nil
+	}
+
+	cost, interactive := readCost(req)
+	readClass := "bulk"
+	if interactive {
+		readClass = "interactive"
+	}
+	start := time.Now()
+	if err := c.fair.acquire(ctx, ctx.Value(readFlowKey{}), cost, interactive); err != nil {
+		return nil, err
+	}
+	metricHandle.GCSReadSchedulingDelay(ctx, float64(time.Since(start).Microseconds())/1000.0, []common.MetricAttr{{Key: common.ReadClass, Value: readClass}})
+	c.add(metricHandle, 1)
+	return func() {
+		c.add(metricHandle, -1)
+		c.fair.release(interactive)
+	}, nil
+}
+
+func (c *requestClass) release(metricHandle common.MetricHandle) {
+	if c == nil {
+		return
+	}
+	c.add(metricHandle, -1)
+	c.sem.Release(1)
+}
+
+// add records the number of requests in flight in the gcs/inflight_requests
+// metric, under the lock so that the last recorded value is the current one.
+func (c *requestClass) add(metricHandle common.MetricHandle, delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inflight += delta
+	metricHandle.GCSInflightRequests(context.Background(), c.inflight, c.attrs)
+}
+
+// NewConcurrencyLimitedBucket creates a bucket that waits for the requests it
+// sends to the wrapped bucket to be allowed in flight by the supplied limiter,
+// and records the requests in flight of each class in the
+// gcs/inflight_requests metric.
+//
+// Without the limits, a single program walking the whole bucket, e.g. find,
+// can send as many listings and stats as there are threads and starve the reads
+// of the other programs sharing the mount.
+func NewConcurrencyLimitedBucket(
+	limiter *ConcurrencyLimiter,
+	metricHandle common.MetricHandle,
+	wrapped gcs.Bucket) gcs.Bucket {
+	return &concurrencyLimitedBucket{
+		Bucket:       wrapped,
+		limiter:      limiter,
+		metricHandle: metricHandle,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// concurrencyLimitedBucket
+////////////////////////////////////////////////////////////////////////
+
+type concurrencyLimitedBucket struct {
+	gcs.Bucket
+	limiter      *ConcurrencyLimiter
+	metricHandle common.MetricHandle
+}
+
+// call calls f once the request of the supplied class is allowed in flight.
+func (b *concurrencyLimitedBucket) call(ctx context.Context, c *requestClass, f func() error) error {
+	if err := c.acquire(ctx, b.metricHandle); err != nil {
+		return err
+	}
+	defer c.release(b.metricHandle)
+	return f()
+}
+
+func (b *concurrencyLimitedBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	c := b.limiter.read
+	release, err := c.acquireRead(ctx, b.metricHandle, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := b.Bucket.NewReader(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if c == nil {
+		return rc, nil
+	}
+
+	// The stream is in flight until the reader is closed or reaches its end.
+	return newStreamReleasingReader(rc, 0, release), nil
+}
+
+func (b *concurrencyLimitedBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	err = b.call(ctx, b.limiter.list, func() (err error) {
+		listing, err = b.Bucket.ListObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, attrs *gcs.ExtendedObjectAttributes, err error) {
+	err = b.call(ctx, b.limiter.stat, func() (err error) {
+		m, attrs, err = b.Bucket.StatObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (folder *gcs.Folder, err error) {
+	err = b.call(ctx, b.limiter.stat, func() (err error) {
+		folder, err = b.Bucket.GetFolder(ctx, folderName)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.CreateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (w gcs.Writer, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		w, err = b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) FinalizeUpload(
+	ctx context.Context,
+	w gcs.Writer) (o *gcs.MinObject, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.FinalizeUpload(ctx, w)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.CopyObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.ComposeObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.UpdateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return b.call(ctx, b.limiter.write, func() error {
+		return b.Bucket.DeleteObject(ctx, req)
+	})
+}
+
+func (b *concurrencyLimitedBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		o, err = b.Bucket.MoveObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) CreateFolder(
+	ctx context.Context,
+	folderName string) (folder *gcs.Folder, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		folder, err = b.Bucket.CreateFolder(ctx, folderName)
+		return
+	})
+	return
+}
+
+func (b *concurrencyLimitedBucket) DeleteFolder(
+	ctx context.Context,
+	folderName string) error {
+	return b.call(ctx, b.limiter.write, func() error {
+		return b.Bucket.DeleteFolder(ctx, folderName)
+	})
+}
+
+func (b *concurrencyLimitedBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (folder *gcs.Folder, err error) {
+	err = b.call(ctx, b.limiter.write, func() (err error) {
+		folder, err = b.Bucket.RenameFolder(ctx, folderName, destinationFolderId)
+		return
+	})
+	return
+}
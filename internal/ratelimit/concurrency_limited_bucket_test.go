@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
+)
+
+// inflightMetricHandle keeps the last number of requests in flight recorded for
+// each class.
+type inflightMetricHandle struct {
+	common.MetricHandle
+	mu       sync.Mutex
+	inflight map[string]int64
+}
+
+func (m *inflightMetricHandle) GCSInflightRequests(_ context.Context, value int64, attrs []common.MetricAttr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight[attrs[0].Value] = value
+}
+
+func (m *inflightMetricHandle) get(class string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inflight[class]
+}
+
+type ConcurrencyLimitedBucketTest struct {
+	suite.Suite
+	ctx          context.Context
+	metricHandle *inflightMetricHandle
+	bucket       gcs.Bucket
+}
+
+func TestConcurrencyLimitedBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(ConcurrencyLimitedBucketTest))
+}
+
+func (t *ConcurrencyLimitedBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(t.ctx, wrapped, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	t.metricHandle = &inflightMetricHandle{
+		MetricHandle: common.NewNoopMetrics(),
+		inflight:     make(map[string]int64),
+	}
+	limiter := NewConcurrencyLimiter(ConcurrencyLimits{Read: 1, Stat: 1})
+	t.bucket = NewConcurrencyLimitedBucket(limiter, t.metricHandle, wrapped)
+}
+
+func (t *ConcurrencyLimitedBucketTest) TestNoLimits() {
+	assert.Nil(t.T(), NewConcurrencyLimiter(ConcurrencyLimits{}))
+}
+
+func (t *ConcurrencyLimitedBucketTest) TestReaderBeyondLimitWaitsForClose() {
+	rc1, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), int64(1), t.metricHandle.get("read"))
+	opened := make(chan io.ReadCloser)
+	go func() {
+		rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+		assert.NoError(t.T(), err)
+		opened <- rc
+	}()
+
+	select {
+	case <-opened:
+		assert.FailNow(t.T(), "reader beyond the limit was not queued")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Stats are limited separately.
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	assert.NoError(t.T(), rc1.Close())
+	rc2 := <-opened
+
+	assert.Equal(t.T(), int64(1), t.metricHandle.get("read"))
+	assert.NoError(t.T(), rc2.Close())
+	assert.Equal(t.T(), int64(0), t.metricHandle.get("read"))
+	assert.Equal(t.T(), int64(0), t.metricHandle.get("stat"))
+}
+
+func (t *ConcurrencyLimitedBucketTest) TestWaitingRequestReturnsErrorWhenContextIsCancelled() {
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	defer rc.Close()
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = t.bucket.NewReader(ctx, &gcs.ReadObjectRequest{Name: "foo"})
+
+	assert.ErrorIs(t.T(), err, context.DeadlineExceeded)
+	assert.Equal(t.T(), int64(1), t.metricHandle.get("read"))
+}
+
+func (t *ConcurrencyLimitedBucketTest) TestFailedRequestsAreReleased() {
+	for i := 0; i < 3; i++ {
+		_, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "missing"})
+
+		var notFoundErr *gcs.NotFoundError
+		assert.ErrorAs(t.T(), err, &notFoundErr)
+	}
+	assert.Equal(t.T(), int64(0), t.metricHandle.get("read"))
+}
+
+func (t *ConcurrencyLimitedBucketTest) TestUnlimitedClassesAreNotRecorded() {
+	_, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{})
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "bar", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	assert.NotContains(t.T(), t.metricHandle.inflight, "list")
+	assert.NotContains(t.T(), t.metricHandle.inflight, "write")
+}
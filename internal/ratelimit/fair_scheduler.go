@@ -0,0 +1,248 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+const (
+	// interactiveReadBytes is the size up to which the reads are interactive,
+	// e.g. the reads of an application, rather than the read ahead of the
+	// sequential reads.
+	interactiveReadBytes = 1 << 20
+
+	// fairQuantum is the number of bytes credited to a flow in each of its turns
+	// of the deficit round robin.
+	fairQuantum = 1 << 20
+
+	// maxFairCost bounds the bytes a read is charged, so that the read ahead
+	// of a flow waits for at most that many turns, as its streams are often
+	// closed before their end.
+	maxFairCost = 64 * fairQuantum
+)
+
+type readFlowKey struct{}
+
+// WithReadFlow returns a context whose read streams are scheduled as the flow
+// of the given key, e.g. a file handle, when the read streams are scheduled
+// fairly with ConcurrencyLimits.ReadMinSharePercent. The streams of the
+// contexts without a flow share the same one.
+func WithReadFlow(ctx context.Context, key any) context.Context {
+	return context.WithValue(ctx, readFlowKey{}, key)
+}
+
+// readCost returns the bytes the read stream of req is charged, and whether
+// it's an interactive read.
+func readCost(req *gcs.ReadObjectRequest) (cost int64, interactive bool) {
+	if req.Range == nil || req.Range.Limit <= req.Range.Start {
+		return maxFairCost, false
+	}
+	n := int64(req.Range.Limit - req.Range.Start)
+	return min(n, maxFairCost), n <= interactiveReadBytes
+}
+
+// fairScheduler hands out a fixed number of slots, the read streams in flight,
+// to the flows waiting for them with deficit round robin over the bytes they
+// request, so that a flow opening streams for huge read ahead doesn't starve
+// the small reads of the others. Some slots are also reserved for the
+// interactive reads.
+type fairScheduler struct {
+	// bulkSlots is the number of slots the reads other than the interactive
+	// ones may hold.
+	bulkSlots int64
+
+	mu sync.Mutex
+	// The slots not held.
+	//
+	// GUARDED_BY(mu)
+	free int64
+	// The slots held by bulk reads.
+	//
+	// GUARDED_BY(mu)
+	bulk int64
+	// The flows with waiting reads, by key, and in round robin order.
+	//
+	// GUARDED_BY(mu)
+	flows  map[any]*readFlow
+	active []*readFlow
+	// The index in active of the flow whose turn it is, and whether it has been
+	// credited its quantum for this turn.
+	//
+	// GUARDED_BY(mu)
+	next     int
+	credited bool
+}
+
+type readFlow struct {
+	key     any
+	deficit int64
+	waiters []*readWaiter
+}
+
+type readWaiter struct {
+	cost        int64
+	interactive bool
+	granted     chan struct{}
+}
+
+// newFairScheduler returns a scheduler of slots of which reserved are kept for
+// the interactive reads, at least one being left to the others.
+func newFairScheduler(slots int64, reserved int64) *fairScheduler {
+	return &fairScheduler{
+		bulkSlots: max(slots-reserved, 1),
+		free:      slots,
+		flows:     make(map[any]*readFlow),
+	}
+}
+
+// acquire waits for a slot for a read of the supplied flow, charged cost bytes.
+func (s *fairScheduler) acquire(ctx context.Context, key any, cost int64, interactive bool) error {
+	s.mu.Lock()
+	if len(s.active) == 0 && s.allowed(interactive) {
+		s.take(interactive)
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &readWaiter{cost: cost, interactive: interactive, granted: make(chan struct{})}
+	f := s.flows[key]
+	if f == nil {
+		f = &readFlow{key: key}
+		s.flows[key] = f
+		s.active = append(s.active, f)
+	}
+	f.waiters = append(f.waiters, w)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-w.granted:
+		// The slot was granted concurrently, hand it over.
+		s.give(interactive)
+	default:
+		f.waiters = slices.DeleteFunc(f.waiters, func(o *readWaiter) bool { return o == w })
+		if len(f.waiters) == 0 {
+			s.removeFlow(slices.Index(s.active, f))
+		}
+		// The read may have been blocking the others of its flow.
+		s.dispatch()
+	}
+	return ctx.Err()
+}
+
+// release releases a slot acquired with acquire.
+func (s *fairScheduler) release(interactive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.give(interactive)
+}
+
+// allowed returns true if a slot is free for a read of the supplied kind.
+//
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) allowed(interactive bool) bool {
+	return s.free > 0 && (interactive || s.bulk < s.bulkSlots)
+}
+
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) take(interactive bool) {
+	s.free--
+	if !interactive {
+		s.bulk++
+	}
+}
+
+// give frees a slot and hands it over to the waiting reads.
+//
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) give(interactive bool) {
+	s.free++
+	if !interactive {
+		s.bulk--
+	}
+	s.dispatch()
+}
+
+// dispatch grants the free slots to the waiting reads, taking turns among
+// their flows. A flow is credited fairQuantum bytes in each of its turns,
+// and its reads are granted in order as long as they're charged less than its
+// credit, which is reset once it has no read waiting.
+//
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) dispatch() {
+	for s.free > 0 && slices.ContainsFunc(s.active, func(f *readFlow) bool { return s.allowed(f.waiters[0].interactive) }) {
+		f := s.active[s.next]
+		w := f.waiters[0]
+		// A flow is credited only in the turns it can take a slot.
+		if !s.allowed(w.interactive) {
+			s.advance()
+			continue
+		}
+		if !s.credited {
+			f.deficit += fairQuantum
+			s.credited = true
+		}
+		if w.cost > f.deficit {
+			s.advance()
+			continue
+		}
+
+		f.deficit -= w.cost
+		f.waiters = f.waiters[1:]
+		s.take(w.interactive)
+		close(w.granted)
+		if len(f.waiters) == 0 {
+			s.removeFlow(s.next)
+		}
+	}
+}
+
+// advance gives the turn to the next flow.
+//
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) advance() {
+	s.next = (s.next + 1) % len(s.active)
+	s.credited = false
+}
+
+// removeFlow removes the flow at index i of active, which has no read waiting.
+//
+// LOCKS_REQUIRED(s.mu)
+func (s *fairScheduler) removeFlow(i int) {
+	delete(s.flows, s.active[i].key)
+	s.active = slices.Delete(s.active, i, i+1)
+	switch {
+	case i < s.next:
+		s.next--
+	case i == s.next:
+		s.credited = false
+	}
+	if s.next >= len(s.active) {
+		s.next = 0
+	}
+}
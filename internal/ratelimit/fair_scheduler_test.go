@@ -0,0 +1,178 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// waiting returns the number of reads waiting for a slot of s.
+func waiting(s *fairScheduler) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for _, f := range s.active {
+		n += len(f.waiters)
+	}
+	return n
+}
+
+type queuedRead struct {
+	name        string
+	flow        string
+	cost        int64
+	interactive bool
+}
+
+// grantOrder acquires a slot of s for each of the reads in the background, in
+// order, and returns a function returning the order in which they were
+// granted, releasing each slot right away.
+func grantOrder(t *testing.T, s *fairScheduler, reads []queuedRead) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var granted []string
+	var wg sync.WaitGroup
+	for i, r := range reads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.acquire(context.Background(), r.flow, r.cost, r.interactive))
+			mu.Lock()
+			granted = append(granted, r.name)
+			mu.Unlock()
+			s.release(r.interactive)
+		}()
+		require.Eventually(t, func() bool { return waiting(s) == i+1 }, time.Second, time.Millisecond)
+	}
+	return func() []string {
+		wg.Wait()
+		return granted
+	}
+}
+
+func TestReadCost(t *testing.T) {
+	cost, interactive := readCost(&gcs.ReadObjectRequest{Range: &gcs.ByteRange{Start: 10, Limit: 4106}})
+	assert.Equal(t, int64(4096), cost)
+	assert.True(t, interactive)
+
+	cost, interactive = readCost(&gcs.ReadObjectRequest{Range: &gcs.ByteRange{Start: 0, Limit: 200 << 20}})
+	assert.Equal(t, int64(maxFairCost), cost)
+	assert.False(t, interactive)
+
+	cost, interactive = readCost(&gcs.ReadObjectRequest{})
+	assert.Equal(t, int64(maxFairCost), cost)
+	assert.False(t, interactive)
+}
+
+func TestFairSchedulerReservesSlotsForInteractiveReads(t *testing.T) {
+	s := newFairScheduler(2, 1)
+	require.NoError(t, s.acquire(context.Background(), "a", maxFairCost, false))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// The second bulk read can't take the reserved slot.
+	err := s.acquire(ctx, "a", maxFairCost, false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, s.acquire(context.Background(), "b", 4096, true))
+	assert.Equal(t, 0, waiting(s))
+}
+
+func TestFairSchedulerTakesTurnsAcrossFlows(t *testing.T) {
+	s := newFairScheduler(1, 1)
+	require.NoError(t, s.acquire(context.Background(), "a", 8<<20, false))
+	order := grantOrder(t, s, []queuedRead{
+		{"a1", "a", 8 << 20, false},
+		{"a2", "a", 8 << 20, false},
+		{"b1", "b", 4096, true},
+		{"a3", "a", 8 << 20, false},
+		{"b2", "b", 4096, true},
+	})
+
+	s.release(false)
+
+	// The small reads of b don't wait for the large ones of a queued before.
+	assert.Equal(t, []string{"b1", "b2", "a1", "a2", "a3"}, order())
+}
+
+func TestFairSchedulerSharesBytesAcrossFlows(t *testing.T) {
+	s := newFairScheduler(1, 1)
+	require.NoError(t, s.acquire(context.Background(), "a", fairQuantum, false))
+	order := grantOrder(t, s, []queuedRead{
+		{"a1", "a", fairQuantum, false},
+		{"a2", "a", fairQuantum, false},
+		{"b1", "b", 2 * fairQuantum, false},
+		{"c1", "c", fairQuantum, false},
+	})
+
+	s.release(false)
+
+	// b needs two turns for a read twice as large.
+	assert.Equal(t, []string{"a1", "c1", "a2", "b1"}, order())
+}
+
+func TestFairSchedulerCancelledReadDoesNotBlockItsFlow(t *testing.T) {
+	s := newFairScheduler(1, 1)
+	require.NoError(t, s.acquire(context.Background(), "a", 4096, true))
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- s.acquire(ctx, "a", 4096, true) }()
+	require.Eventually(t, func() bool { return waiting(s) == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Equal(t, 0, waiting(s))
+	s.release(true)
+	assert.NoError(t, s.acquire(context.Background(), "a", 4096, true))
+}
+
+func TestConcurrencyLimitedBucketSchedulesReadsFairly(t *testing.T) {
+	ctx := context.Background()
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(ctx, wrapped, "foo", []byte("taco"))
+	require.NoError(t, err)
+	metricHandle := &inflightMetricHandle{
+		MetricHandle: common.NewNoopMetrics(),
+		inflight:     make(map[string]int64),
+	}
+	limiter := NewConcurrencyLimiter(ConcurrencyLimits{Read: 2, ReadMinSharePercent: 50})
+	bucket := NewConcurrencyLimitedBucket(limiter, metricHandle, wrapped)
+	rc1, err := bucket.NewReader(WithReadFlow(ctx, 1), &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t, err)
+	defer rc1.Close()
+
+	// The whole object is a bulk read, limited to the unreserved slot.
+	timeoutCtx, cancel := context.WithTimeout(WithReadFlow(ctx, 1), 10*time.Millisecond)
+	defer cancel()
+	_, err = bucket.NewReader(timeoutCtx, &gcs.ReadObjectRequest{Name: "foo"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	rc2, err := bucket.NewReader(WithReadFlow(ctx, 2), &gcs.ReadObjectRequest{Name: "foo", Range: &gcs.ByteRange{Start: 0, Limit: 4}})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), metricHandle.get("read"))
+	assert.NoError(t, rc2.Close())
+	assert.Equal(t, int64(1), metricHandle.get("read"))
+}
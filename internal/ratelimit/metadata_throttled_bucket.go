@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"math"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// NewMetadataThrottle returns the throttle of the metadata operations sent to
+// GCS at most rateHz times per second, in bursts of at most burst operations.
+// A burst of 0 allows one second worth of operations.
+//
+// Unlike the throttle of limit-ops-per-sec, whose capacity is chosen to be
+// accurate over hours, the small capacity smooths the bursts so that programs
+// stat-ing in a loop can't use more than the limit over any second.
+func NewMetadataThrottle(rateHz float64, burst int64) Throttle {
+	if burst == 0 {
+		burst = int64(math.Ceil(rateHz))
+	}
+	return NewThrottle(rateHz, uint64(burst))
+}
+
+// NewMetadataThrottledBucket creates a bucket that waits for a token of
+// throttle before each metadata operation, i.e. stat and list, it sends to the
+// wrapped bucket.
+//
+// The throttle can be shared by the buckets of a mount, to limit the metadata
+// operations of the whole mount.
+func NewMetadataThrottledBucket(throttle Throttle, wrapped gcs.Bucket) gcs.Bucket {
+	return &metadataThrottledBucket{
+		Bucket:   wrapped,
+		throttle: throttle,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// metadataThrottledBucket
+////////////////////////////////////////////////////////////////////////
+
+type metadataThrottledBucket struct {
+	gcs.Bucket
+	throttle Throttle
+}
+
+func (b *metadataThrottledBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	if err := b.throttle.Wait(ctx, 1); err != nil {
+		return nil, nil, err
+	}
+	return b.Bucket.StatObject(ctx, req)
+}
+
+func (b *metadataThrottledBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if err := b.throttle.Wait(ctx, 1); err != nil {
+		return nil, err
+	}
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+func (b *metadataThrottledBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (*gcs.Folder, error) {
+	if err := b.throttle.Wait(ctx, 1); err != nil {
+		return nil, err
+	}
+	return b.Bucket.GetFolder(ctx, folderName)
+}
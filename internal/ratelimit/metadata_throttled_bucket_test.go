@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
+)
+
+// countingThrottle counts the tokens waited for, and fails with err if set.
+type countingThrottle struct {
+	tokens uint64
+	err    error
+}
+
+func (t *countingThrottle) Capacity() uint64 {
+	return 1
+}
+
+func (t *countingThrottle) Wait(_ context.Context, tokens uint64) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.tokens += tokens
+	return nil
+}
+
+type MetadataThrottledBucketTest struct {
+	suite.Suite
+	ctx      context.Context
+	throttle *countingThrottle
+	bucket   gcs.Bucket
+}
+
+func TestMetadataThrottledBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(MetadataThrottledBucketTest))
+}
+
+func (t *MetadataThrottledBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(t.ctx, wrapped, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	t.throttle = &countingThrottle{}
+	t.bucket = NewMetadataThrottledBucket(t.throttle, wrapped)
+}
+
+func (t *MetadataThrottledBucketTest) TestMetadataOpsAreThrottled() {
+	_, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{})
+	require.NoError(t.T(), err)
+
+	assert.Len(t.T(), listing.MinObjects, 1)
+	assert.Equal(t.T(), uint64(2), t.throttle.tokens)
+}
+
+func (t *MetadataThrottledBucketTest) TestReadsAreNotThrottled() {
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	content, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), "taco", string(content))
+	assert.Equal(t.T(), uint64(0), t.throttle.tokens)
+}
+
+func (t *MetadataThrottledBucketTest) TestThrottleError() {
+	t.throttle.err = errors.New("context canceled")
+
+	_, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	assert.ErrorIs(t.T(), err, t.throttle.err)
+}
+
+func TestNewMetadataThrottle(t *testing.T) {
+	tests := []struct {
+		name             string
+		rateHz           float64
+		burst            int64
+		expectedCapacity uint64
+	}{
+		{"default_burst", 10, 0, 10},
+		{"default_burst_of_fractional_rate", 0.5, 0, 1},
+		{"burst", 10, 2, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			throttle := NewMetadataThrottle(tc.rateHz, tc.burst)
+
+			assert.Equal(t, tc.expectedCapacity, throttle.Capacity())
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/semaphore"
+)
+
+// How long a reader can go without being read before giving up its stream of
+// the object, e.g. the reader kept by the handle of a file open but no longer
+// read.
+const defaultStreamIdleTimeout = time.Second
+
+// NewStreamLimitedBucket creates a bucket that allows at most
+// maxStreamsPerObject readers to be open concurrently against any single
+// object of the wrapped bucket. Further NewReader calls for the object wait
+// until one of its readers is closed, reaches its end or stays idle, and are
+// counted in the gcs/read_stream_queued_count metric.
+//
+// Without the limit, hundreds of threads reading the same large object open as
+// many streams against it, which makes GCS throttle the object and in turn
+// makes all the reads slower.
+func NewStreamLimitedBucket(
+	maxStreamsPerObject int64,
+	metricHandle common.MetricHandle,
+	wrapped gcs.Bucket) gcs.Bucket {
+	return &streamLimitedBucket{
+		Bucket:              wrapped,
+		maxStreamsPerObject: maxStreamsPerObject,
+		streamIdleTimeout:   defaultStreamIdleTimeout,
+		metricHandle:        metricHandle,
+		objects:             make(map[string]*objectStreams),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// streamLimitedBucket
+////////////////////////////////////////////////////////////////////////
+
+// objectStreams limits the readers of one object.
+type objectStreams struct {
+	sem *semaphore.Weighted
+
+	// The number of readers holding or waiting for sem. The entry is removed
+	// from the map once this drops to zero, so that the map only tracks objects
+	// being read.
+	//
+	// GUARDED_BY(streamLimitedBucket.mu)
+	refs int
+}
+
+type streamLimitedBucket struct {
+	gcs.Bucket
+	maxStreamsPerObject int64
+	streamIdleTimeout   time.Duration
+	metricHandle        common.MetricHandle
+
+	mu sync.Mutex
+	// GUARDED_BY(mu)
+	objects map[string]*objectStreams
+}
+
+func (b *streamLimitedBucket) acquire(ctx context.Context, name string) (*objectStreams, error) {
+	b.mu.Lock()
+	s, ok := b.objects[name]
+	if !ok {
+		s = &objectStreams{sem: semaphore.NewWeighted(b.maxStreamsPerObject)}
+		b.objects[name] = s
+	}
+	s.refs++
+	b.mu.Unlock()
+
+	if s.sem.TryAcquire(1) {
+		return s, nil
+	}
+
+	b.metricHandle.GCSReadStreamQueuedCount(ctx, 1, nil)
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		b.unref(name, s)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (b *streamLimitedBucket) release(name string, s *objectStreams) {
+	s.sem.Release(1)
+	b.unref(name, s)
+}
+
+func (b *streamLimitedBucket) unref(name string, s *objectStreams) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s.refs--
+	if s.refs == 0 {
+		delete(b.objects, name)
+	}
+}
+
+func (b *streamLimitedBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	// Wait for a free stream of the object.
+	s, err := b.acquire(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call through.
+	rc, err := b.Bucket.NewReader(ctx, req)
+	if err != nil {
+		b.release(req.Name, s)
+		return nil, err
+	}
+
+	// Hold on to the stream while the reader is read.
+	return newStreamReleasingReader(rc, b.streamIdleTimeout, func() { b.release(req.Name, s) }), nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// streamReleasingReader
+////////////////////////////////////////////////////////////////////////
+
+// An io.ReadCloser that releases its stream of the object the first time it is
+// closed, its Read fails, e.g. with io.EOF at its end, or it isn't read for
+// idleTimeout, unless 0. This way, a reader kept open but not read doesn't hold
+// up the readers of the object waiting for a stream. It can still be read
+// beyond that, over the limit.
+type streamReleasingReader struct {
+	io.ReadCloser
+	release     func()
+	once        sync.Once
+	idleTimeout time.Duration
+	idle        *time.Timer
+}
+
+func newStreamReleasingReader(rc io.ReadCloser, idleTimeout time.Duration, release func()) *streamReleasingReader {
+	r := &streamReleasingReader{
+		ReadCloser:  rc,
+		release:     release,
+		idleTimeout: idleTimeout,
+	}
+	if idleTimeout > 0 {
+		r.idle = time.AfterFunc(idleTimeout, r.releaseStream)
+	}
+	return r
+}
+
+func (rc *streamReleasingReader) releaseStream() {
+	rc.once.Do(rc.release)
+}
+
+func (rc *streamReleasingReader) Read(p []byte) (n int, err error) {
+	// The time waiting for the data isn't idle.
+	if rc.idle != nil {
+		rc.idle.Stop()
+	}
+	n, err = rc.ReadCloser.Read(p)
+	if err != nil {
+		rc.releaseStream()
+	} else if rc.idle != nil {
+		rc.idle.Reset(rc.idleTimeout)
+	}
+	return
+}
+
+func (rc *streamReleasingReader) Close() (err error) {
+	if rc.idle != nil {
+		rc.idle.Stop()
+	}
+	err = rc.ReadCloser.Close()
+	rc.releaseStream()
+	return
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/context"
+)
+
+type queuedCountMetricHandle struct {
+	common.MetricHandle
+	queued atomic.Int64
+}
+
+func (m *queuedCountMetricHandle) GCSReadStreamQueuedCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.queued.Add(inc)
+}
+
+type StreamLimitedBucketTest struct {
+	suite.Suite
+	ctx          context.Context
+	metricHandle *queuedCountMetricHandle
+	bucket       *streamLimitedBucket
+}
+
+func TestStreamLimitedBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(StreamLimitedBucketTest))
+}
+
+func (t *StreamLimitedBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(t.ctx, wrapped, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, wrapped, "bar", []byte("burrito"))
+	require.NoError(t.T(), err)
+	t.metricHandle = &queuedCountMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	t.bucket = NewStreamLimitedBucket(2, t.metricHandle, wrapped).(*streamLimitedBucket)
+}
+
+func (t *StreamLimitedBucketTest) newReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	return t.bucket.NewReader(ctx, &gcs.ReadObjectRequest{Name: name})
+}
+
+func (t *StreamLimitedBucketTest) TestReadersWithinLimitAreNotQueued() {
+	rc1, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	rc2, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	// Readers of other objects have their own limit.
+	rc3, err := t.newReader(t.ctx, "bar")
+	require.NoError(t.T(), err)
+
+	content, err := io.ReadAll(rc1)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(content))
+	assert.Equal(t.T(), int64(0), t.metricHandle.queued.Load())
+	for _, rc := range []io.ReadCloser{rc1, rc2, rc3} {
+		assert.NoError(t.T(), rc.Close())
+	}
+	assert.Empty(t.T(), t.bucket.objects)
+}
+
+func (t *StreamLimitedBucketTest) TestReaderBeyondLimitWaitsForClose() {
+	rc1, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	rc2, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	opened := make(chan io.ReadCloser)
+	go func() {
+		rc, err := t.newReader(t.ctx, "foo")
+		assert.NoError(t.T(), err)
+		opened <- rc
+	}()
+
+	select {
+	case <-opened:
+		assert.FailNow(t.T(), "reader beyond the limit was not queued")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t.T(), int64(1), t.metricHandle.queued.Load())
+	// Closing a reader twice releases its stream only once.
+	assert.NoError(t.T(), rc1.Close())
+	assert.NoError(t.T(), rc1.Close())
+	rc3 := <-opened
+
+	for _, rc := range []io.ReadCloser{rc2, rc3} {
+		assert.NoError(t.T(), rc.Close())
+	}
+	assert.Empty(t.T(), t.bucket.objects)
+}
+
+func (t *StreamLimitedBucketTest) TestIdleReaderReleasesStream() {
+	t.bucket.streamIdleTimeout = 50 * time.Millisecond
+	rc1, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	rc2, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	// rc2 is read while rc1 stays open without being read.
+	b := make([]byte, 1)
+	_, err = rc2.Read(b)
+	require.NoError(t.T(), err)
+	ctx, cancel := context.WithTimeout(t.ctx, time.Second)
+	defer cancel()
+
+	rc3, err := t.newReader(ctx, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), int64(1), t.metricHandle.queued.Load())
+	// The idle reader can still be read.
+	content, err := io.ReadAll(rc1)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(content))
+	for _, rc := range []io.ReadCloser{rc1, rc2, rc3} {
+		assert.NoError(t.T(), rc.Close())
+	}
+	assert.Empty(t.T(), t.bucket.objects)
+}
+
+func (t *StreamLimitedBucketTest) TestReaderReleasesStreamAtEOF() {
+	rc1, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	rc2, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	_, err = io.ReadAll(rc1)
+	require.NoError(t.T(), err)
+
+	rc3, err := t.newReader(t.ctx, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), int64(0), t.metricHandle.queued.Load())
+	for _, rc := range []io.ReadCloser{rc1, rc2, rc3} {
+		assert.NoError(t.T(), rc.Close())
+	}
+	assert.Empty(t.T(), t.bucket.objects)
+}
+
+func (t *StreamLimitedBucketTest) TestQueuedReaderReturnsErrorWhenContextIsCancelled() {
+	rc1, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	rc2, err := t.newReader(t.ctx, "foo")
+	require.NoError(t.T(), err)
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = t.newReader(ctx, "foo")
+
+	assert.ErrorIs(t.T(), err, context.DeadlineExceeded)
+	assert.NoError(t.T(), rc1.Close())
+	assert.NoError(t.T(), rc2.Close())
+	assert.Empty(t.T(), t.bucket.objects)
+}
+
+func (t *StreamLimitedBucketTest) TestFailedNewReaderReleasesStream() {
+	for i := 0; i < 3; i++ {
+		_, err := t.newReader(t.ctx, "missing")
+
+		var notFoundErr *gcs.NotFoundError
+		assert.ErrorAs(t.T(), err, &notFoundErr)
+	}
+	assert.Equal(t.T(), int64(0), t.metricHandle.queued.Load())
+	assert.Empty(t.T(), t.bucket.objects)
+}
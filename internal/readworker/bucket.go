@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readworker
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/featureflag"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+var readWorkersFeature = featureflag.Register("read-workers", "Shard the reads of the objects across worker processes (--experimental-read-worker-processes).")
+
+// NewShardedBucket creates a bucket reading the objects of the wrapped bucket
+// with the workers of pool. The reads the workers fail to serve, e.g. because
+// one of them exited, are served by the wrapped bucket, as are the reads of
+// the compressed contents of the objects.
+func NewShardedBucket(pool *Pool, wrapped gcs.Bucket) gcs.Bucket {
+	return &shardedBucket{
+		Bucket: wrapped,
+		pool:   pool,
+	}
+}
+
+type shardedBucket struct {
+	gcs.Bucket
+	pool *Pool
+}
+
+func (b *shardedBucket) NewReader(ctx context.Context, req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	if req.ReadCompressed || !readWorkersFeature.Enabled() {
+		return b.Bucket.NewReader(ctx, req)
+	}
+
+	rc, err := b.pool.read(ctx, b.Name(), req)
+	var workerErr *workerError
+	if errors.As(err, &workerErr) {
+		logger.Warnf("Reading %q from the file system process: %v", req.Name, err)
+		return b.Bucket.NewReader(ctx, req)
+	}
+	return rc, err
+}
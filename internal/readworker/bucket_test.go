@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readworker
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveWorker serves the reads of the buckets named like wrapped, counting
+// them in reads, on a socket, and returns its path.
+func serveWorker(t *testing.T, wrapped gcs.Bucket, reads *int) string {
+	t.Helper()
+	// The paths of Unix domain sockets are limited to about 100 bytes, shorter
+	// than those of t.TempDir().
+	dir, err := os.MkdirTemp("", "readworker")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "worker.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = Serve(ctx, socketPath, NewHandler(func(name string) gcs.Bucket {
+			require.Equal(t, wrapped.Name(), name)
+			return &countingBucket{Bucket: wrapped, reads: reads}
+		}))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	w := &worker{socketPath: socketPath, exited: make(chan struct{})}
+	require.NoError(t, w.waitForSocket(time.Now().Add(startTimeout)))
+	return socketPath
+}
+
+type countingBucket struct {
+	gcs.Bucket
+	reads *int
+}
+
+func (b *countingBucket) NewReader(ctx context.Context, req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	*b.reads++
+	return b.Bucket.NewReader(ctx, req)
+}
+
+func newPool(socketPaths ...string) *Pool {
+	p := &Pool{}
+	for _, socketPath := range socketPaths {
+		p.workers = append(p.workers, &worker{socketPath: socketPath, client: NewClient(socketPath)})
+	}
+	return p
+}
+
+func readAll(t *testing.T, b gcs.Bucket, req *gcs.ReadObjectRequest) string {
+	t.Helper()
+	rc, err := b.NewReader(context.Background(), req)
+	require.NoError(t, err)
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(contents)
+}
+
+func TestShardedBucket_ReadsWithTheWorkers(t *testing.T) {
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(context.Background(), wrapped, "foo", []byte("0123456789"))
+	require.NoError(t, err)
+	var readsA, readsB int
+	b := NewShardedBucket(newPool(serveWorker(t, wrapped, &readsA), serveWorker(t, wrapped, &readsB)), wrapped)
+
+	assert.Equal(t, "0123456789", readAll(t, b, &gcs.ReadObjectRequest{Name: "foo"}))
+	assert.Equal(t, "234", readAll(t, b, &gcs.ReadObjectRequest{Name: "foo", Range: &gcs.ByteRange{Start: 2, Limit: 5}}))
+
+	// The reads are spread across the workers.
+	assert.Equal(t, 1, readsA)
+	assert.Equal(t, 1, readsB)
+}
+
+func TestShardedBucket_NotFound(t *testing.T) {
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	var reads int
+	b := NewShardedBucket(newPool(serveWorker(t, wrapped, &reads)), wrapped)
+
+	_, err := b.NewReader(context.Background(), &gcs.ReadObjectRequest{Name: "foo"})
+
+	var notFound *gcs.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, 1, reads)
+}
+
+func TestShardedBucket_FallsBackWhenTheWorkerIsUnavailable(t *testing.T) {
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(context.Background(), wrapped, "foo", []byte("taco"))
+	require.NoError(t, err)
+	b := NewShardedBucket(newPool(filepath.Join(t.TempDir(), "missing.sock")), wrapped)
+
+	assert.Equal(t, "taco", readAll(t, b, &gcs.ReadObjectRequest{Name: "foo"}))
+}
+
+func TestStart_WorkerExits(t *testing.T) {
+	_, err := Start(2, func(string) *exec.Cmd {
+		return exec.Command("sh", "-c", "exit 3")
+	})
+
+	assert.ErrorContains(t, err, "exited")
+}
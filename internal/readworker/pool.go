@@ -0,0 +1,211 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+const (
+	// How long Start waits for the workers to serve their sockets.
+	startTimeout = 30 * time.Second
+
+	// How often Start checks whether the workers serve their sockets.
+	startPollInterval = 50 * time.Millisecond
+)
+
+// Pool is the set of workers the reads are sharded across, one after the
+// other.
+type Pool struct {
+	dir     string
+	workers []*worker
+	next    atomic.Uint64
+}
+
+type worker struct {
+	socketPath string
+	cmd        *exec.Cmd
+	exited     chan struct{}
+	client     *http.Client
+}
+
+// Start starts n workers with command, which returns the command running a
+// worker serving the socket at socketPath, and waits for them to serve their
+// sockets.
+func Start(n int, command func(socketPath string) *exec.Cmd) (p *Pool, err error) {
+	dir, err := os.MkdirTemp("", "gcsfuse-read-workers-")
+	if err != nil {
+		return nil, fmt.Errorf("creating the directory of the sockets: %w", err)
+	}
+	p = &Pool{dir: dir}
+	defer func() {
+		if err != nil {
+			p.Close()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		socketPath := filepath.Join(dir, fmt.Sprintf("worker-%d.sock", i))
+		w := &worker{
+			socketPath: socketPath,
+			cmd:        command(socketPath),
+			exited:     make(chan struct{}),
+			client:     NewClient(socketPath),
+		}
+		if err = w.cmd.Start(); err != nil {
+			return p, fmt.Errorf("starting worker %d: %w", i, err)
+		}
+		go func() {
+			_ = w.cmd.Wait()
+			close(w.exited)
+		}()
+		p.workers = append(p.workers, w)
+	}
+
+	deadline := time.Now().Add(startTimeout)
+	for i, w := range p.workers {
+		if err = w.waitForSocket(deadline); err != nil {
+			return p, fmt.Errorf("worker %d: %w", i, err)
+		}
+	}
+	return p, nil
+}
+
+func (w *worker) waitForSocket(deadline time.Time) error {
+	for {
+		conn, err := net.Dial("unix", w.socketPath)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not serving after %v: %w", startTimeout, err)
+		}
+		select {
+		case <-w.exited:
+			return fmt.Errorf("exited: %v", w.cmd.ProcessState)
+		case <-time.After(startPollInterval):
+		}
+	}
+}
+
+// NewClient returns a client of the worker serving the socket at socketPath.
+func NewClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+			MaxIdleConnsPerHost: 64,
+		},
+	}
+}
+
+// Close stops the workers and removes their sockets. It's safe to call on a
+// nil pool.
+func (p *Pool) Close() {
+	if p == nil {
+		return
+	}
+	for _, w := range p.workers {
+		if w.cmd.Process != nil {
+			_ = w.cmd.Process.Kill()
+		}
+		<-w.exited
+	}
+	os.RemoveAll(p.dir)
+}
+
+// Size returns the number of workers of the pool.
+func (p *Pool) Size() int {
+	return len(p.workers)
+}
+
+// A *workerError is the failure of a worker to serve a read, e.g. because it
+// exited, as opposed to the failure of GCS to serve the read.
+type workerError struct {
+	err error
+}
+
+func (e *workerError) Error() string {
+	return fmt.Sprintf("read worker: %v", e.err)
+}
+
+func (e *workerError) Unwrap() error {
+	return e.err
+}
+
+// read reads the object of req in bucket with the next worker.
+func (p *Pool) read(ctx context.Context, bucket string, req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	w := p.workers[p.next.Add(1)%uint64(len(p.workers))]
+	return read(ctx, w.client, bucket, req)
+}
+
+func read(ctx context.Context, client *http.Client, bucket string, req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("bucket", bucket)
+	query.Set("object", req.Name)
+	query.Set("generation", strconv.FormatInt(req.Generation, 10))
+	if req.Range != nil {
+		query.Set("start", strconv.FormatUint(req.Range.Start, 10))
+		query.Set("limit", strconv.FormatUint(req.Range.Limit, 10))
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://readworker"+readPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &workerError{err: err}
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	err = errors.New(strings.TrimSpace(string(body)))
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, &gcs.NotFoundError{Err: err}
+	case http.StatusPreconditionFailed:
+		return nil, &gcs.PreconditionError{Err: err}
+	case http.StatusForbidden:
+		return nil, &gcs.PermissionDeniedError{Err: err}
+	case http.StatusBadGateway:
+		return nil, err
+	default:
+		return nil, &workerError{err: fmt.Errorf("%s: %w", resp.Status, err)}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package readworker shards the reads of the objects of a mount across worker
+// processes, each reading from GCS with its own connections, so that the reads
+// aren't capped by the HTTP/2 connections and the garbage collector of a
+// single process. The file system process forwards the reads to the workers
+// over HTTP on Unix domain sockets.
+package readworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// The path of the read requests, with the query parameters bucket, object,
+// generation, start and limit.
+const readPath = "/read"
+
+// NewHandler returns the handler of the read requests of a worker, which
+// reads the objects from the buckets returned by bucket, called once per
+// bucket.
+func NewHandler(bucket func(name string) gcs.Bucket) http.Handler {
+	h := &handler{
+		bucket:  bucket,
+		buckets: make(map[string]gcs.Bucket),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+readPath, h.serveRead)
+	return mux
+}
+
+// Serve serves the read requests on a Unix domain socket at socketPath with
+// handler until ctx is done.
+func Serve(ctx context.Context, socketPath string, handler http.Handler) error {
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing the stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listening on %q: %w", socketPath, err)
+	}
+	server := &http.Server{Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err = server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type handler struct {
+	bucket func(name string) gcs.Bucket
+
+	mu sync.Mutex
+	// GUARDED_BY(mu)
+	buckets map[string]gcs.Bucket
+}
+
+func (h *handler) bucketNamed(name string) gcs.Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.buckets[name]
+	if !ok {
+		b = h.bucket(name)
+		h.buckets[name] = b
+	}
+	return b
+}
+
+func (h *handler) serveRead(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &gcs.ReadObjectRequest{Name: query.Get("object")}
+	var err error
+	if req.Generation, err = strconv.ParseInt(query.Get("generation"), 10, 64); err != nil {
+		http.Error(w, "invalid generation", http.StatusBadRequest)
+		return
+	}
+	if query.Has("start") {
+		req.Range = &gcs.ByteRange{}
+		if req.Range.Start, err = strconv.ParseUint(query.Get("start"), 10, 64); err != nil {
+			http.Error(w, "invalid start", http.StatusBadRequest)
+			return
+		}
+		if req.Range.Limit, err = strconv.ParseUint(query.Get("limit"), 10, 64); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rc, err := h.bucketNamed(query.Get("bucket")).NewReader(r.Context(), req)
+	if err != nil {
+		var notFound *gcs.NotFoundError
+		var precondition *gcs.PreconditionError
+		var permissionDenied *gcs.PermissionDeniedError
+		switch {
+		case errors.As(err, &notFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.As(err, &precondition):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.As(err, &permissionDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err = io.Copy(w, rc); err != nil {
+		// Abort the response, so that the file system process fails the read
+		// rather than seeing a short object.
+		logger.Debugf("readworker: reading %q: %v", req.Name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -20,11 +20,13 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -50,6 +52,21 @@ type bucketHandle struct {
 	bucketName    string
 	bucketType    gcs.BucketType
 	controlClient StorageControlClient
+
+	// readBucket is the bucket used for reading objects, bound to the regional
+	// endpoint of readRegion, or nil to read through bucket.
+	readBucket *storage.BucketHandle
+	readRegion string
+
+	// singleShotUploadThreshold is the size up to which the contents of the
+	// objects created are sent in a single request, or zero to always use
+	// resumable uploads.
+	singleShotUploadThreshold int64
+
+	// client and billingProject are used to reach the source buckets of the
+	// copies from other buckets.
+	client         *storage.Client
+	billingProject string
 }
 
 func (bh *bucketHandle) Name() string {
@@ -91,6 +108,10 @@ func (bh *bucketHandle) BucketType() gcs.BucketType {
 	return bh.bucketType
 }
 
+func (bh *bucketHandle) ReadRegion() string {
+	return bh.readRegion
+}
+
 func (bh *bucketHandle) NewReader(
 	ctx context.Context,
 	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
@@ -105,7 +126,11 @@ func (bh *bucketHandle) NewReader(
 		length = end - start
 	}
 
-	obj := bh.bucket.Object(req.Name)
+	readBucket := bh.bucket
+	if bh.readBucket != nil {
+		readBucket = bh.readBucket
+	}
+	obj := readBucket.Object(req.Name)
 
 	// Switching to the requested generation of object.
 	if req.Generation != 0 {
@@ -121,6 +146,8 @@ func (bh *bucketHandle) NewReader(
 
 	if err == storage.ErrObjectNotExist {
 		err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
+	} else if isPermissionDenied(err) {
+		err = &gcs.PermissionDeniedError{Err: err}
 	}
 
 	return r, err
@@ -173,6 +200,9 @@ func (bh *bucketHandle) StatObject(ctx context.Context,
 	}
 	if err != nil {
 		err = fmt.Errorf("error in fetching object attributes: %w", err)
+		if isPermissionDenied(err) {
+			err = &gcs.PermissionDeniedError{Err: err}
+		}
 		return
 	}
 
@@ -225,12 +255,32 @@ func (bh *bucketHandle) CreateObject(ctx context.Context, req *gcs.CreateObjectR
 	wc := obj.NewWriter(ctx)
 	wc.ChunkTransferTimeout = time.Duration(req.ChunkTransferTimeoutSecs) * time.Second
 	wc = storageutil.SetAttrsInWriter(wc, req)
+	progress := gcs.UploadProgress(ctx)
 	wc.ProgressFunc = func(bytesUploadedSoFar int64) {
 		logger.Tracef("gcs: Req %#16x: -- CreateObject(%q): %20v bytes uploaded so far", ctx.Value(gcs.ReqIdField), req.Name, bytesUploadedSoFar)
+		if progress != nil {
+			progress(bytesUploadedSoFar)
+		}
+	}
+
+	contents := req.Contents
+	if bh.singleShotUploadThreshold > 0 {
+		var singleShot bool
+		if contents, singleShot, err = peekContents(req.Contents, bh.singleShotUploadThreshold); err != nil {
+			err = fmt.Errorf("error in reading contents: %w", err)
+			return
+		}
+		// Contents within a chunk are sent along with the metadata in one
+		// request, skipping the initiation of a resumable session, which the
+		// writer retries like the others. A zero chunk size would send them in
+		// one request too, but without any retry.
+		if singleShot && int64(wc.ChunkSize) < bh.singleShotUploadThreshold {
+			wc.ChunkSize = int(bh.singleShotUploadThreshold)
+		}
 	}
 
 	// Copy the contents to the writer.
-	if _, err = io.Copy(wc, req.Contents); err != nil {
+	if _, err = io.Copy(wc, contents); err != nil {
 		err = fmt.Errorf("error in io.Copy: %w", err)
 		return
 	}
@@ -265,6 +315,20 @@ func (bh *bucketHandle) CreateObject(ctx context.Context, req *gcs.CreateObjectR
 	o = storageutil.ObjectAttrsToBucketObject(attrs)
 	return
 }
+
+// peekContents reads up to threshold bytes of the supplied contents, reporting
+// whether they ended there, and returns a reader of the whole contents.
+func peekContents(r io.Reader, threshold int64) (io.Reader, bool, error) {
+	head, err := io.ReadAll(io.LimitReader(r, threshold+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(head)) <= threshold {
+		return bytes.NewReader(head), true, nil
+	}
+	return io.MultiReader(bytes.NewReader(head), r), false, nil
+}
+
 func (bh *bucketHandle) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
 	obj := bh.getObjectHandleWithPreconditionsSet(req)
 
@@ -301,7 +365,19 @@ func (bh *bucketHandle) FinalizeUpload(ctx context.Context, w gcs.Writer) (o *gc
 }
 
 func (bh *bucketHandle) CopyObject(ctx context.Context, req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
-	srcObj := bh.bucket.Object(req.SrcName)
+	srcBucket := bh.bucket
+	if req.SrcBucketName != "" && req.SrcBucketName != bh.bucketName {
+		if bh.client == nil {
+			// EXDEV, for a rename across buckets to fall back to a copy.
+			err = fmt.Errorf("copying from bucket %q: no storage client: %w", req.SrcBucketName, syscall.EXDEV)
+			return
+		}
+		srcBucket = bh.client.Bucket(req.SrcBucketName)
+		if bh.billingProject != "" {
+			srcBucket = srcBucket.UserProject(bh.billingProject)
+		}
+	}
+	srcObj := srcBucket.Object(req.SrcName)
 	dstObj := bh.bucket.Object(req.DstName)
 
 	// Switching to the requested generation of source object.
@@ -314,7 +390,17 @@ func (bh *bucketHandle) CopyObject(ctx context.Context, req *gcs.CopyObjectReque
 		srcObj = srcObj.If(storage.Conditions{MetagenerationMatch: *req.SrcMetaGenerationPrecondition})
 	}
 
-	objAttrs, err := dstObj.CopierFrom(srcObj).Run(ctx)
+	if req.DstGenerationPrecondition != nil {
+		if *req.DstGenerationPrecondition == 0 {
+			dstObj = dstObj.If(storage.Conditions{DoesNotExist: true})
+		} else {
+			dstObj = dstObj.If(storage.Conditions{GenerationMatch: *req.DstGenerationPrecondition})
+		}
+	}
+
+	copier := dstObj.CopierFrom(srcObj)
+	copier.ProgressFunc = req.Progress
+	objAttrs, err := copier.Run(ctx)
 
 	if err != nil {
 		switch ee := err.(type) {
@@ -385,6 +471,9 @@ func (bh *bucketHandle) ListObjects(ctx context.Context, req *gcs.ListObjectsReq
 		}
 		if err != nil {
 			err = fmt.Errorf("error in iterating through objects: %w", err)
+			if isPermissionDenied(err) {
+				err = &gcs.PermissionDeniedError{Err: err}
+			}
 			return
 		}
 
@@ -546,6 +635,13 @@ func (bh *bucketHandle) DeleteFolder(ctx context.Context, folderName string) (er
 		Name: fmt.Sprintf(FullFolderPathHNS, bh.bucketName, folderName),
 	}, callOptions...)
 
+	switch status.Code(err) {
+	case codes.NotFound:
+		err = &gcs.NotFoundError{Err: err}
+	case codes.FailedPrecondition:
+		// The folder isn't empty.
+		err = &gcs.PreconditionError{Err: err}
+	}
 	return err
 }
 
@@ -563,6 +659,22 @@ func isPreconditionFailed(err error) (bool, error) {
 	return false, nil
 }
 
+// isPermissionDenied returns true if GCS denied access to the resource of the
+// request, e.g. because IAM denies access to the managed folder containing it.
+func isPermissionDenied(err error) bool {
+	var gapiErr *googleapi.Error
+	if errors.As(err, &gapiErr) {
+		return gapiErr.Code == http.StatusForbidden
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.GRPCStatus().Code() == codes.PermissionDenied
+	}
+
+	return status.Code(err) == codes.PermissionDenied
+}
+
 func (bh *bucketHandle) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
 	var o *gcs.Object
 	var err error
@@ -604,22 +716,21 @@ func (bh *bucketHandle) MoveObject(ctx context.Context, req *gcs.MoveObjectReque
 }
 
 func (bh *bucketHandle) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (folder *gcs.Folder, err error) {
-	var controlFolder *controlpb.Folder
 	req := &controlpb.RenameFolderRequest{
 		Name:                fmt.Sprintf(FullFolderPathHNS, bh.bucketName, folderName),
 		DestinationFolderId: destinationFolderId,
 	}
-	resp, err := bh.controlClient.RenameFolder(ctx, req)
+	op, err := bh.controlClient.RenameFolder(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, &gcs.FolderRenameError{
+			SrcName: folderName,
+			DstName: destinationFolderId,
+			State:   gcs.FolderRenameStateNotStarted,
+			Err:     err,
+		}
 	}
 
-	// Wait blocks until the long-running operation is completed,
-	// returning the response and any errors encountered.
-	controlFolder, err = resp.Wait(ctx)
-	folder = gcs.GCSFolder(bh.bucketName, controlFolder)
-
-	return folder, err
+	return bh.awaitRenameFolder(ctx, folderName, destinationFolderId, op)
 }
 
 // TODO: Consider adding this method to the bucket interface if additional
@@ -650,6 +761,9 @@ func (bh *bucketHandle) GetFolder(ctx context.Context, folderName string) (*gcs.
 				return nil, &gcs.NotFoundError{Err: err}
 			}
 		}
+		if isPermissionDenied(err) {
+			return nil, &gcs.PermissionDeniedError{Err: err}
+		}
 		return nil, err
 	}
 
@@ -666,6 +780,11 @@ func (bh *bucketHandle) CreateFolder(ctx context.Context, folderName string) (*g
 
 	clientFolder, err := bh.controlClient.CreateFolder(ctx, req)
 	if err != nil {
+		// Like the creation of an object with a zero generation precondition,
+		// the creation of an existing folder fails with a precondition error.
+		if status.Code(err) == codes.AlreadyExists {
+			err = &gcs.PreconditionError{Err: err}
+		}
 		return nil, err
 	}
 
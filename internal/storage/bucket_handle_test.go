@@ -18,22 +18,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"reflect"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/storage"
 	control "cloud.google.com/go/storage/control/apiv2"
 	"cloud.google.com/go/storage/control/apiv2/controlpb"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/gax-go/v2/apierror"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -349,6 +359,46 @@ func (testSuite *BucketHandleTest) TestCopyObjectMethodWithValidObject() {
 	assert.Nil(testSuite.T(), err)
 }
 
+func (testSuite *BucketHandleTest) TestCopyObjectMethodFromOtherBucket() {
+	const otherBucketName = "other-bucket"
+	testSuite.fakeStorage.(*fakeStorage).fakeStorageServer.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: otherBucketName})
+	otherBucketHandle := testSuite.storageHandle.BucketHandle(context.Background(), otherBucketName, "")
+	var copied, total uint64
+
+	o, err := otherBucketHandle.CopyObject(context.Background(),
+		&gcs.CopyObjectRequest{
+			SrcBucketName: TestBucketName,
+			SrcName:       TestObjectName,
+			DstName:       dstObjectName,
+			SrcGeneration: TestObjectGeneration,
+			Progress: func(copiedBytes, totalBytes uint64) {
+				copied, total = copiedBytes, totalBytes
+			},
+		})
+
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), dstObjectName, o.Name)
+	assert.Equal(testSuite.T(), uint64(len(ContentInTestObject)), o.Size)
+	assert.Equal(testSuite.T(), uint64(len(ContentInTestObject)), copied)
+	assert.Equal(testSuite.T(), uint64(len(ContentInTestObject)), total)
+	// The source object is left in its bucket.
+	_, _, err = testSuite.bucketHandle.StatObject(context.Background(), &gcs.StatObjectRequest{Name: TestObjectName})
+	assert.NoError(testSuite.T(), err)
+}
+
+func (testSuite *BucketHandleTest) TestCopyObjectMethodFromOtherBucketWithoutClient() {
+	testSuite.bucketHandle.client = nil
+
+	_, err := testSuite.bucketHandle.CopyObject(context.Background(),
+		&gcs.CopyObjectRequest{
+			SrcBucketName: "other-bucket",
+			SrcName:       TestObjectName,
+			DstName:       dstObjectName,
+		})
+
+	assert.ErrorIs(testSuite.T(), err, syscall.EXDEV)
+}
+
 func (testSuite *BucketHandleTest) TestCopyObjectMethodWithMissingObject() {
 	var notfound *gcs.NotFoundError
 
@@ -390,6 +440,22 @@ func (testSuite *BucketHandleTest) TestCreateObjectMethodWithValidObject() {
 	assert.Nil(testSuite.T(), err)
 }
 
+func (testSuite *BucketHandleTest) TestCreateObjectMethodWithSingleShotUpload() {
+	testSuite.bucketHandle.singleShotUploadThreshold = 8
+	for _, content := range []string{"", "small", "exactly8", "larger than the threshold"} {
+		obj, err := testSuite.bucketHandle.CreateObject(context.Background(),
+			&gcs.CreateObjectRequest{
+				Name:     "test_object",
+				Contents: strings.NewReader(content),
+			})
+
+		require.NoError(testSuite.T(), err)
+		assert.Equal(testSuite.T(), len(content), int(obj.Size))
+		assert.Equal(testSuite.T(), content, testSuite.readObjectContent(context.Background(),
+			&gcs.ReadObjectRequest{Name: "test_object", Range: &gcs.ByteRange{Start: 0, Limit: uint64(len(content))}}))
+	}
+}
+
 func (testSuite *BucketHandleTest) TestCreateObjectMethodWithGenerationAsZero() {
 	content := "Creating a new object"
 	var generation int64 = 0
@@ -1454,6 +1520,23 @@ func (testSuite *BucketHandleTest) TestDeleteFolderWhenFolderNotExistForHierarch
 	assert.NotNil(testSuite.T(), err)
 }
 
+func (testSuite *BucketHandleTest) TestDeleteFolderErrorsForHierarchicalBucket() {
+	ctx := context.Background()
+	deleteFolderReq := controlpb.DeleteFolderRequest{Name: fmt.Sprintf(FullFolderPathHNS, TestBucketName, TestFolderName)}
+	testSuite.mockClient.On("DeleteFolder", ctx, &deleteFolderReq, mock.Anything).Return(status.Error(codes.NotFound, "folder not found")).Once()
+	testSuite.mockClient.On("DeleteFolder", ctx, &deleteFolderReq, mock.Anything).Return(status.Error(codes.FailedPrecondition, "folder not empty")).Once()
+	testSuite.bucketHandle.bucketType = gcs.Hierarchical
+
+	err := testSuite.bucketHandle.DeleteFolder(ctx, TestFolderName)
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(testSuite.T(), err, &notFoundErr)
+	err = testSuite.bucketHandle.DeleteFolder(ctx, TestFolderName)
+	var preconditionErr *gcs.PreconditionError
+	assert.ErrorAs(testSuite.T(), err, &preconditionErr)
+
+	testSuite.mockClient.AssertExpectations(testSuite.T())
+}
+
 func (testSuite *BucketHandleTest) TestGetFolderWhenFolderExistsForHierarchicalBucket() {
 	ctx := context.Background()
 	folderPath := fmt.Sprintf(FullFolderPathHNS, TestBucketName, TestFolderName)
@@ -1494,7 +1577,124 @@ func (testSuite *BucketHandleTest) TestRenameFolderWithError() {
 	_, err := testSuite.bucketHandle.RenameFolder(ctx, TestFolderName, TestRenameFolder)
 
 	testSuite.mockClient.AssertExpectations(testSuite.T())
-	assert.NotNil(testSuite.T(), err)
+	var renameErr *gcs.FolderRenameError
+	require.ErrorAs(testSuite.T(), err, &renameErr)
+	assert.Equal(testSuite.T(), gcs.FolderRenameStateNotStarted, renameErr.State)
+	assert.ErrorContains(testSuite.T(), err, "mock error")
+}
+
+// fakeRenameFolderOperation is a rename folder operation which completes after
+// pollsLeft polls, with err if set.
+type fakeRenameFolderOperation struct {
+	pollsLeft int
+	err       error
+	done      bool
+}
+
+func (op *fakeRenameFolderOperation) Poll(_ context.Context, _ ...gax.CallOption) (*controlpb.Folder, error) {
+	if op.pollsLeft > 0 {
+		op.pollsLeft--
+		return nil, nil
+	}
+	op.done = true
+	if op.err != nil {
+		return nil, op.err
+	}
+	return &controlpb.Folder{Name: fmt.Sprintf(FullFolderPathHNS, TestBucketName, TestRenameFolder)}, nil
+}
+
+func (op *fakeRenameFolderOperation) Metadata() (*controlpb.RenameFolderMetadata, error) {
+	return &controlpb.RenameFolderMetadata{
+		CommonMetadata: &controlpb.CommonLongRunningOperationMetadata{ProgressPercent: int32(50 / (op.pollsLeft + 1))},
+	}, nil
+}
+
+func (op *fakeRenameFolderOperation) Done() bool {
+	return op.done
+}
+
+func (op *fakeRenameFolderOperation) Name() string {
+	return "operations/rename"
+}
+
+func (testSuite *BucketHandleTest) mockGetFolder(folderName string, exists bool) {
+	getFolderReq := controlpb.GetFolderRequest{Name: fmt.Sprintf(FullFolderPathHNS, TestBucketName, folderName)}
+	if exists {
+		testSuite.mockClient.On("GetFolder", mock.Anything, &getFolderReq, mock.Anything).Return(&controlpb.Folder{Name: getFolderReq.Name}, nil)
+		return
+	}
+	notFoundErr, _ := apierror.FromError(status.Error(codes.NotFound, "folder not found"))
+	testSuite.mockClient.On("GetFolder", mock.Anything, &getFolderReq, mock.Anything).Return(nil, notFoundErr)
+}
+
+func (testSuite *BucketHandleTest) TestAwaitRenameFolderPollsUntilDone() {
+	defer func(interval time.Duration) { renameFolderPollInterval = interval }(renameFolderPollInterval)
+	renameFolderPollInterval = time.Millisecond
+	op := &fakeRenameFolderOperation{pollsLeft: 2}
+
+	folder, err := testSuite.bucketHandle.awaitRenameFolder(context.Background(), TestFolderName, TestRenameFolder, op)
+
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), TestRenameFolder, folder.Name)
+	assert.Equal(testSuite.T(), 0, op.pollsLeft)
+}
+
+func (testSuite *BucketHandleTest) TestAwaitRenameFolderFailureStates() {
+	testCases := []struct {
+		name          string
+		srcExists     bool
+		dstExists     bool
+		expectedState gcs.FolderRenameState
+	}{
+		{"not_started", true, false, gcs.FolderRenameStateNotStarted},
+		{"partial", true, true, gcs.FolderRenameStatePartial},
+		{"unknown", false, false, gcs.FolderRenameStateUnknown},
+	}
+	for _, tc := range testCases {
+		testSuite.Run(tc.name, func() {
+			testSuite.mockClient = new(MockStorageControlClient)
+			testSuite.bucketHandle.controlClient = testSuite.mockClient
+			testSuite.mockGetFolder(TestFolderName, tc.srcExists)
+			testSuite.mockGetFolder(TestRenameFolder, tc.dstExists)
+			op := &fakeRenameFolderOperation{err: errors.New("mock error")}
+
+			_, err := testSuite.bucketHandle.awaitRenameFolder(context.Background(), TestFolderName, TestRenameFolder, op)
+
+			testSuite.mockClient.AssertExpectations(testSuite.T())
+			var renameErr *gcs.FolderRenameError
+			require.ErrorAs(testSuite.T(), err, &renameErr)
+			assert.Equal(testSuite.T(), tc.expectedState, renameErr.State)
+			assert.Equal(testSuite.T(), "operations/rename", renameErr.Operation)
+			assert.ErrorContains(testSuite.T(), err, "mock error")
+		})
+	}
+}
+
+func (testSuite *BucketHandleTest) TestAwaitRenameFolderFailureAfterRenameCompleted() {
+	testSuite.mockGetFolder(TestFolderName, false)
+	testSuite.mockGetFolder(TestRenameFolder, true)
+	op := &fakeRenameFolderOperation{err: errors.New("mock error")}
+
+	folder, err := testSuite.bucketHandle.awaitRenameFolder(context.Background(), TestFolderName, TestRenameFolder, op)
+
+	testSuite.mockClient.AssertExpectations(testSuite.T())
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), TestRenameFolder, folder.Name)
+}
+
+func (testSuite *BucketHandleTest) TestAwaitRenameFolderCancelled() {
+	defer func(interval time.Duration) { renameFolderPollInterval = interval }(renameFolderPollInterval)
+	renameFolderPollInterval = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	op := &fakeRenameFolderOperation{pollsLeft: 1}
+
+	_, err := testSuite.bucketHandle.awaitRenameFolder(ctx, TestFolderName, TestRenameFolder, op)
+
+	var renameErr *gcs.FolderRenameError
+	require.ErrorAs(testSuite.T(), err, &renameErr)
+	assert.Equal(testSuite.T(), gcs.FolderRenameStateInProgress, renameErr.State)
+	assert.ErrorIs(testSuite.T(), err, context.DeadlineExceeded)
 }
 
 func (testSuite *BucketHandleTest) TestCreateFolderWithError() {
@@ -1509,6 +1709,19 @@ func (testSuite *BucketHandleTest) TestCreateFolderWithError() {
 	assert.Nil(testSuite.T(), folder)
 }
 
+func (testSuite *BucketHandleTest) TestCreateFolderWhenFolderExists() {
+	createFolderReq := controlpb.CreateFolderRequest{Parent: fmt.Sprintf(FullBucketPathHNS, TestBucketName), FolderId: TestFolderName, Recursive: true}
+	testSuite.mockClient.On("CreateFolder", context.Background(), &createFolderReq, mock.Anything).Return(nil, status.Error(codes.AlreadyExists, "folder exists"))
+	testSuite.bucketHandle.bucketType = gcs.Hierarchical
+
+	folder, err := testSuite.bucketHandle.CreateFolder(context.Background(), TestFolderName)
+
+	testSuite.mockClient.AssertExpectations(testSuite.T())
+	var preconditionErr *gcs.PreconditionError
+	assert.ErrorAs(testSuite.T(), err, &preconditionErr)
+	assert.Nil(testSuite.T(), folder)
+}
+
 func (testSuite *BucketHandleTest) TestCreateFolderWithGivenName() {
 	mockFolder := controlpb.Folder{
 		Name: fmt.Sprintf(FullFolderPathHNS, TestBucketName, TestFolderName),
@@ -1579,3 +1792,118 @@ func TestIsPreconditionFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPermissionDenied(t *testing.T) {
+	permissionDeniedApiError, _ := apierror.FromError(status.New(codes.PermissionDenied, "Permission denied error").Err())
+	notFoundApiError, _ := apierror.FromError(status.New(codes.NotFound, "Not Found error").Err())
+
+	tests := []struct {
+		name   string
+		err    error
+		expect bool
+	}{
+		{
+			name:   "googleapi.Error with Forbidden",
+			err:    fmt.Errorf("error in iterating through objects: %w", &googleapi.Error{Code: http.StatusForbidden}),
+			expect: true,
+		},
+		{
+			name:   "googleapi.Error with other code",
+			err:    &googleapi.Error{Code: http.StatusNotFound},
+			expect: false,
+		},
+		{
+			name:   "apierror.APIError with PermissionDenied",
+			err:    permissionDeniedApiError,
+			expect: true,
+		},
+		{
+			name:   "apierror.APIError with other code",
+			err:    notFoundApiError,
+			expect: false,
+		},
+		{
+			name:   "gRPC status with PermissionDenied",
+			err:    status.Error(codes.PermissionDenied, "Permission denied error"),
+			expect: true,
+		},
+		{
+			name:   "generic error",
+			err:    errors.New("generic error"),
+			expect: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, isPermissionDenied(tt.err))
+		})
+	}
+}
+
+func TestPeekContents(t *testing.T) {
+	testCases := []struct {
+		content    string
+		singleShot bool
+	}{
+		{content: "", singleShot: true},
+		{content: "abc", singleShot: true},
+		{content: "abcd", singleShot: true},
+		{content: "abcde", singleShot: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.content, func(t *testing.T) {
+			r, singleShot, err := peekContents(strings.NewReader(tc.content), 4)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.singleShot, singleShot)
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, string(b))
+		})
+	}
+}
+
+// transientFailureProxy serves the requests of a fake GCS server, failing the
+// first upload with 503 Service Unavailable.
+type transientFailureProxy struct {
+	proxy   *httputil.ReverseProxy
+	uploads atomic.Int32
+}
+
+func (p *transientFailureProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/upload/") && p.uploads.Add(1) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+func TestCreateObjectRetriesSingleShotUploadOnTransientFailure(t *testing.T) {
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		Scheme:         "http",
+		InitialObjects: []fakestorage.Object{{ObjectAttrs: fakestorage.ObjectAttrs{BucketName: TestBucketName, Name: TestObjectName}}},
+	})
+	require.NoError(t, err)
+	defer server.Stop()
+	serverURL, err := url.Parse(server.URL())
+	require.NoError(t, err)
+	p := &transientFailureProxy{proxy: httputil.NewSingleHostReverseProxy(serverURL)}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+	ctx := context.Background()
+	sc, err := storage.NewClient(ctx, option.WithEndpoint(proxy.URL+"/storage/v1/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	setRetryConfig(sc, &storageutil.StorageClientConfig{MaxRetrySleep: time.Millisecond, RetryMultiplier: 2})
+	sh := &storageClient{client: sc, clientConfig: storageutil.StorageClientConfig{SingleShotUploadThreshold: 1024}}
+	bh := sh.BucketHandle(ctx, TestBucketName, "")
+
+	obj, err := bh.CreateObject(ctx, &gcs.CreateObjectRequest{
+		Name:     "small",
+		Contents: strings.NewReader("taco"),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len("taco")), obj.Size)
+	assert.Equal(t, int32(2), p.uploads.Load())
+}
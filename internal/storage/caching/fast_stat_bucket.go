@@ -15,8 +15,10 @@
 package caching
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -30,25 +32,62 @@ import (
 	"github.com/jacobsa/timeutil"
 )
 
+const (
+	// batchRefreshWindow is the window within which the cache misses of the
+	// children of a directory are counted against the batch refresh threshold.
+	batchRefreshWindow = time.Second
+
+	// batchRefreshMaxResults is the page size of the single list call
+	// refreshing a directory.
+	batchRefreshMaxResults = 5000
+)
+
 // Create a bucket that caches object records returned by the supplied wrapped
 // bucket. Records are invalidated when modifications are made through this
-// bucket, and after the supplied TTL.
+// bucket, and after the supplied TTL, less a random duration of up to
+// ttlJitter.
+//
+// If batchRefreshThreshold is non-zero, once that many children of a directory
+// miss the cache within batchRefreshWindow, the further misses are served by
+// listing the directory once, instead of statting each child.
+//
+// If reachability is non-nil, the expired records are kept until refreshed,
+// and served when the wrapped bucket fails to refresh them with a
+// *gcs.UnreachableError, for offline-mode serve-cache.
 func NewFastStatBucket(
 	ttl time.Duration,
+	ttlJitter time.Duration,
+	batchRefreshThreshold int,
+	reachability *Reachability,
 	cache metadata.StatCache,
 	clock timeutil.Clock,
 	wrapped gcs.Bucket) (b gcs.Bucket) {
 	fsb := &fastStatBucket{
-		cache:   cache,
-		clock:   clock,
-		wrapped: wrapped,
-		ttl:     ttl,
+		cache:                 cache,
+		clock:                 clock,
+		wrapped:               wrapped,
+		ttl:                   ttl,
+		ttlJitter:             ttlJitter,
+		batchRefreshThreshold: batchRefreshThreshold,
+		reachability:          reachability,
+		dirMisses:             make(map[string]int),
+		dirRefreshes:          make(map[string]*dirRefresh),
 	}
 
 	b = fsb
 	return
 }
 
+// dirRefresh is an in-flight listing refreshing the entries of a directory.
+type dirRefresh struct {
+	// done is closed once the listing is done.
+	done chan struct{}
+
+	// complete is set if the listing returned all the objects of the
+	// directory, so that the children missing from it don't exist.
+	complete bool
+}
+
 type fastStatBucket struct {
 	mu sync.Mutex
 
@@ -66,22 +105,57 @@ type fastStatBucket struct {
 	// Constant data
 	/////////////////////////
 
-	ttl time.Duration
+	ttl                   time.Duration
+	ttlJitter             time.Duration
+	batchRefreshThreshold int
+	reachability          *Reachability
+
+	/////////////////////////
+	// Mutable state
+	/////////////////////////
+
+	// The cache misses by directory name since missesWindowStart, and the
+	// in-flight listings by directory name, for the batched refresh.
+	//
+	// GUARDED_BY(mu)
+	missesWindowStart time.Time
+	dirMisses         map[string]int
+	dirRefreshes      map[string]*dirRefresh
+
+	// The directories of the objects of the manifest loaded with LoadManifest
+	// and of the objects cached since, mapped to the first object under them,
+	// until manifestExpiration. Nil if no manifest was loaded.
+	//
+	// GUARDED_BY(mu)
+	manifestDirs       map[string]string
+	manifestExpiration time.Time
 }
 
 ////////////////////////////////////////////////////////////////////////
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
+// expiration returns the expiration time of an entry inserted at the supplied
+// time, brought forward by a random duration of up to b.ttlJitter so that the
+// entries inserted together don't all expire together.
+func (b *fastStatBucket) expiration(now time.Time) time.Time {
+	ttl := b.ttl
+	if b.ttlJitter > 0 {
+		ttl -= time.Duration(rand.Int63n(int64(b.ttlJitter) + 1))
+	}
+	return now.Add(ttl)
+}
+
 // LOCKS_EXCLUDED(b.mu)
 func (b *fastStatBucket) insertMultiple(objs []*gcs.Object) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	expiration := b.clock.Now().Add(b.ttl)
+	now := b.clock.Now()
 	for _, o := range objs {
 		m := storageutil.ConvertObjToMinObject(o)
-		b.cache.Insert(m, expiration)
+		b.cache.Insert(m, b.expiration(now))
+		b.noteManifestDirs(m.Name)
 	}
 }
 
@@ -90,9 +164,10 @@ func (b *fastStatBucket) insertMultipleMinObjects(minObjs []*gcs.MinObject) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	expiration := b.clock.Now().Add(b.ttl)
+	now := b.clock.Now()
 	for _, o := range minObjs {
-		b.cache.Insert(o, expiration)
+		b.cache.Insert(o, b.expiration(now))
+		b.noteManifestDirs(o.Name)
 	}
 }
 
@@ -111,12 +186,13 @@ func (b *fastStatBucket) insertHierarchicalListing(listing *gcs.Listing) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	expiration := b.clock.Now().Add(b.ttl)
+	now := b.clock.Now()
 
 	for _, o := range listing.MinObjects {
 		if !strings.HasSuffix(o.Name, "/") {
-			b.cache.Insert(o, expiration)
+			b.cache.Insert(o, b.expiration(now))
 		}
+		b.noteManifestDirs(o.Name)
 	}
 
 	for _, p := range listing.CollapsedRuns {
@@ -127,7 +203,8 @@ func (b *fastStatBucket) insertHierarchicalListing(listing *gcs.Listing) {
 			f := &gcs.Folder{
 				Name: p,
 			}
-			b.cache.InsertFolder(f, expiration)
+			b.cache.InsertFolder(f, b.expiration(now))
+			b.noteManifestDirs(p)
 		}
 	}
 
@@ -147,7 +224,8 @@ func (b *fastStatBucket) insertFolder(f *gcs.Folder) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.cache.InsertFolder(f, b.clock.Now().Add(b.ttl))
+	b.cache.InsertFolder(f, b.expiration(b.clock.Now()))
+	b.noteManifestDirs(f.Name)
 }
 
 // LOCKS_EXCLUDED(b.mu)
@@ -155,8 +233,7 @@ func (b *fastStatBucket) addNegativeEntry(name string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	expiration := b.clock.Now().Add(b.ttl)
-	b.cache.AddNegativeEntry(name, expiration)
+	b.cache.AddNegativeEntry(name, b.expiration(b.clock.Now()))
 }
 
 // LOCKS_EXCLUDED(b.mu)
@@ -164,8 +241,7 @@ func (b *fastStatBucket) addNegativeEntryForFolder(name string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	expiration := b.clock.Now().Add(b.ttl)
-	b.cache.AddNegativeEntryForFolder(name, expiration)
+	b.cache.AddNegativeEntryForFolder(name, b.expiration(b.clock.Now()))
 }
 
 // LOCKS_EXCLUDED(b.mu)
@@ -176,11 +252,18 @@ func (b *fastStatBucket) invalidate(name string) {
 	b.cache.Erase(name)
 }
 
+// lookUp looks the supplied name up in the cache. If the records are served
+// while GCS is unreachable, an expired record is returned with expired set,
+// rather than as a miss.
+//
 // LOCKS_EXCLUDED(b.mu)
-func (b *fastStatBucket) lookUp(name string) (hit bool, m *gcs.MinObject) {
+func (b *fastStatBucket) lookUp(name string) (hit bool, m *gcs.MinObject, expired bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.reachability != nil {
+		return b.cache.LookUpKeepingExpired(name, b.clock.Now())
+	}
 	hit, m = b.cache.LookUp(name, b.clock.Now())
 	return
 }
@@ -193,6 +276,99 @@ func (b *fastStatBucket) lookUpFolder(name string) (bool, *gcs.Folder) {
 	return hit, f
 }
 
+// recordMiss records a cache miss of a child of the supplied directory, and
+// returns the listing refreshing the directory to wait for, if any. The returned bool is
+// set if the caller started that listing, and must call refreshDir.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) recordMiss(dir string) (r *dirRefresh, start bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r = b.dirRefreshes[dir]; r != nil {
+		return r, false
+	}
+
+	now := b.clock.Now()
+	if now.Sub(b.missesWindowStart) >= batchRefreshWindow {
+		b.missesWindowStart = now
+		clear(b.dirMisses)
+	}
+	b.dirMisses[dir]++
+	if b.dirMisses[dir] < b.batchRefreshThreshold {
+		return nil, false
+	}
+
+	delete(b.dirMisses, dir)
+	r = &dirRefresh{done: make(chan struct{})}
+	b.dirRefreshes[dir] = r
+	return r, true
+}
+
+// refreshDir lists the supplied directory to refresh the cache entries of its
+// children, and then completes r.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) refreshDir(ctx context.Context, dir string, r *dirRefresh) {
+	listing, err := b.ListObjects(ctx, &gcs.ListObjectsRequest{
+		Prefix:     dir,
+		Delimiter:  "/",
+		MaxResults: batchRefreshMaxResults,
+	})
+	if err != nil {
+		logger.Debugf("Refreshing the stat-cache entries of %q: %v", dir, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r.complete = err == nil && listing.ContinuationToken == ""
+	delete(b.dirRefreshes, dir)
+	close(r.done)
+}
+
+// statFromDirListing serves a stat-cache miss of the named object by listing
+// its directory along with the other children missing from the cache, if
+// enough of them have been missed recently. The returned bool is false if the
+// object must be statted instead.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) statFromDirListing(ctx context.Context, name string) (m *gcs.MinObject, ok bool, err error) {
+	// The listing doesn't return the placeholder objects of the directories.
+	if strings.HasSuffix(name, "/") {
+		return
+	}
+
+	dir := name[:strings.LastIndex(name, "/")+1]
+	r, start := b.recordMiss(dir)
+	if r == nil {
+		return
+	}
+	if start {
+		b.refreshDir(ctx, dir, r)
+	} else {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if hit, entry, expired := b.lookUp(name); hit && !expired {
+		ok = true
+		m = entry
+	} else if r.complete {
+		b.addNegativeEntry(name)
+		ok = true
+	}
+	if ok && m == nil {
+		err = &gcs.NotFoundError{
+			Err: fmt.Errorf("%v not found in the listing of %q", name, dir),
+		}
+	}
+	return
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Bucket interface
 ////////////////////////////////////////////////////////////////////////
@@ -306,21 +482,56 @@ func (b *fastStatBucket) StatObject(
 	}
 
 	// Do we have an entry in the cache?
-	if hit, entry := b.lookUp(req.Name); hit {
-		// Negative entries result in NotFoundError.
-		if entry == nil {
-			err = &gcs.NotFoundError{
-				Err: fmt.Errorf("negative cache entry for %v", req.Name),
-			}
+	hit, entry, expired := b.lookUp(req.Name)
+	if hit && !expired {
+		return cachedStat(req.Name, entry)
+	}
 
-			return
+	m, e, err = b.statUncached(ctx, req)
+
+	// Serve the expired entry instead if GCS couldn't be reached.
+	var unreachableErr *gcs.UnreachableError
+	if hit && errors.As(err, &unreachableErr) {
+		b.reachability.servedFromCache(ctx, req.Name)
+		return cachedStat(req.Name, entry)
+	}
+	return
+}
+
+// cachedStat returns the result of a stat served by the supplied cache entry.
+func cachedStat(name string, entry *gcs.MinObject) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	// Negative entries result in NotFoundError.
+	if entry == nil {
+		return nil, nil, &gcs.NotFoundError{
+			Err: fmt.Errorf("negative cache entry for %v", name),
 		}
+	}
 
-		// Otherwise, return MinObject and nil ExtendedObjectAttributes.
-		m = entry
+	// Otherwise, return MinObject and nil ExtendedObjectAttributes.
+	return entry, nil, nil
+}
+
+// statUncached stats the object of a cache miss.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) statUncached(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	// Rule the directories missing from the manifest out, if one was loaded.
+	if b.absentFromManifest(req.Name) {
+		b.addNegativeEntry(req.Name)
+		err = manifestNotFound(req.Name)
 		return
 	}
 
+	// Refresh the directory at once if many of its children are missing.
+	if b.batchRefreshThreshold > 0 {
+		var ok bool
+		if m, ok, err = b.statFromDirListing(ctx, req.Name); ok {
+			return
+		}
+	}
+
 	return b.StatObjectFromGcs(ctx, req)
 }
 
@@ -328,6 +539,12 @@ func (b *fastStatBucket) StatObject(
 func (b *fastStatBucket) ListObjects(
 	ctx context.Context,
 	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	// Look for the implicit directories in the manifest, if one was loaded.
+	var ok bool
+	if listing, ok = b.listFromManifest(req); ok {
+		return
+	}
+
 	// Fetch the listing.
 	listing, err = b.wrapped.ListObjects(ctx, req)
 	if err != nil {
@@ -439,6 +656,12 @@ func (b *fastStatBucket) GetFolder(ctx context.Context, prefix string) (*gcs.Fol
 		return entry, nil
 	}
 
+	// Rule the folders missing from the manifest out, if one was loaded.
+	if b.absentFromManifest(prefix) {
+		b.addNegativeEntryForFolder(prefix)
+		return nil, manifestNotFound(prefix)
+	}
+
 	// Fetch the Folder from GCS
 	return b.getFolderFromGCS(ctx, prefix)
 }
@@ -58,6 +58,9 @@ func (t *fastStatBucketTest) SetUp(ti *TestInfo) {
 
 	t.bucket = caching.NewFastStatBucket(
 		ttl,
+		0,
+		0,
+		nil,
 		t.cache,
 		&t.clock,
 		t.wrapped)
@@ -15,6 +15,8 @@
 package caching_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -63,6 +65,9 @@ func (t *IntegrationTest) SetUp(ti *TestInfo) {
 
 	t.bucket = caching.NewFastStatBucket(
 		ttl,
+		0,
+		0,
+		nil,
 		cache,
 		&t.clock,
 		t.wrapped)
@@ -293,3 +298,220 @@ func (t *IntegrationTest) NegativeCacheExpiration() {
 	AssertEq(nil, err)
 	ExpectNe(nil, o)
 }
+
+////////////////////////////////////////////////////////////////////////
+// Batched refresh
+////////////////////////////////////////////////////////////////////////
+
+const batchRefreshThreshold = 3
+
+type BatchRefreshIntegrationTest struct {
+	IntegrationTest
+}
+
+func init() { RegisterTestSuite(&BatchRefreshIntegrationTest{}) }
+
+func (t *BatchRefreshIntegrationTest) SetUp(ti *TestInfo) {
+	t.IntegrationTest.SetUp(ti)
+
+	const cacheCapacity = 100
+	lruCache := lru.NewCache(cfg.AverageSizeOfPositiveStatCacheEntry * cacheCapacity)
+	t.bucket = caching.NewFastStatBucket(
+		ttl,
+		0,
+		batchRefreshThreshold,
+		nil,
+		metadata.NewStatCacheBucketView(lruCache, ""),
+		&t.clock,
+		t.wrapped)
+}
+
+func (t *BatchRefreshIntegrationTest) createThroughBackDoor(names ...string) {
+	for _, name := range names {
+		_, err := storageutil.CreateObject(t.ctx, t.wrapped, name, []byte{})
+		AssertEq(nil, err)
+	}
+}
+
+func (t *BatchRefreshIntegrationTest) MissesRefreshDirectory() {
+	t.createThroughBackDoor("dir/a", "dir/b", "dir/c", "dir/d")
+
+	// Miss the cache enough times for the directory to be listed.
+	for _, name := range []string{"dir/a", "dir/b", "dir/c"} {
+		_, err := t.stat(name)
+		AssertEq(nil, err)
+	}
+
+	// Delete an object never statted through the back door.
+	err := t.wrapped.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/d"})
+	AssertEq(nil, err)
+
+	// StatObject should still see it, from the listing.
+	o, err := t.stat("dir/d")
+	AssertEq(nil, err)
+	ExpectNe(nil, o)
+}
+
+func (t *BatchRefreshIntegrationTest) ChildrenMissingFromListingAreNegativelyCached() {
+	t.createThroughBackDoor("dir/a", "dir/b")
+
+	_, err := t.stat("dir/a")
+	AssertEq(nil, err)
+	_, err = t.stat("dir/b")
+	AssertEq(nil, err)
+	_, err = t.stat("dir/taco")
+	AssertThat(err, HasSameTypeAs(&gcs.NotFoundError{}))
+
+	// Create the object through the back door.
+	t.createThroughBackDoor("dir/taco")
+
+	// StatObject should still not see it.
+	_, err = t.stat("dir/taco")
+	ExpectThat(err, HasSameTypeAs(&gcs.NotFoundError{}))
+}
+
+func (t *BatchRefreshIntegrationTest) MissesOutsideWindowDontRefreshDirectory() {
+	t.createThroughBackDoor("dir/a", "dir/b", "dir/c")
+
+	_, err := t.stat("dir/a")
+	AssertEq(nil, err)
+	t.clock.AdvanceTime(2 * time.Second)
+	_, err = t.stat("dir/b")
+	AssertEq(nil, err)
+
+	// Delete an object never statted through the back door.
+	err = t.wrapped.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/c"})
+	AssertEq(nil, err)
+
+	// StatObject shouldn't see it, as the directory hasn't been listed.
+	_, err = t.stat("dir/c")
+	ExpectThat(err, HasSameTypeAs(&gcs.NotFoundError{}))
+}
+
+////////////////////////////////////////////////////////////////////////
+// Manifest
+////////////////////////////////////////////////////////////////////////
+
+type ManifestIntegrationTest struct {
+	IntegrationTest
+	dir string
+}
+
+func init() { RegisterTestSuite(&ManifestIntegrationTest{}) }
+
+func (t *ManifestIntegrationTest) SetUp(ti *TestInfo) {
+	t.IntegrationTest.SetUp(ti)
+
+	var err error
+	t.dir, err = os.MkdirTemp("", "manifest")
+	AssertEq(nil, err)
+}
+
+func (t *ManifestIntegrationTest) TearDown() {
+	os.RemoveAll(t.dir)
+}
+
+func (t *ManifestIntegrationTest) load(name, contents, prefix string) int {
+	p := filepath.Join(t.dir, name)
+	AssertEq(nil, os.WriteFile(p, []byte(contents), 0600))
+
+	n, err := caching.LoadManifest(t.bucket, p, prefix)
+	AssertEq(nil, err)
+	return n
+}
+
+func (t *ManifestIntegrationTest) listOne(prefix string) []*gcs.MinObject {
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{
+		Prefix:     prefix,
+		MaxResults: 1,
+	})
+	AssertEq(nil, err)
+	return listing.MinObjects
+}
+
+func (t *ManifestIntegrationTest) ObjectsAreStattedFromManifest() {
+	n := t.load("manifest.json", `
+{"name": "dir/a", "size": "3", "generation": "7", "metageneration": "1"}
+{"name": "dir/b", "size": 5, "generation": 8}
+`, "")
+
+	ExpectEq(2, n)
+	o, err := t.stat("dir/a")
+	AssertEq(nil, err)
+	ExpectEq(3, o.Size)
+	ExpectEq(7, o.Generation)
+	ExpectEq(1, o.MetaGeneration)
+	o, err = t.stat("dir/b")
+	AssertEq(nil, err)
+	ExpectEq(5, o.Size)
+}
+
+func (t *ManifestIntegrationTest) DirectoriesMissingFromManifestDontExist() {
+	t.load("manifest.csv", "name,size,generation\ndir/a,3,7\n", "")
+	// Create a directory through the back door.
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "dir/a/", []byte{})
+	AssertEq(nil, err)
+
+	_, err = t.stat("dir/a/")
+	ExpectThat(err, HasSameTypeAs(&gcs.NotFoundError{}))
+	ExpectEq(0, len(t.listOne("dir/a/")))
+}
+
+func (t *ManifestIntegrationTest) ImplicitDirectoriesAreListedFromManifest() {
+	t.load("manifest.json", `[{"name": "dir/sub/b"}, {"name": "dir/sub/a"}]`, "")
+
+	objects := t.listOne("dir/")
+	AssertEq(1, len(objects))
+	ExpectEq("dir/sub/a", objects[0].Name)
+	objects = t.listOne("dir/sub/")
+	AssertEq(1, len(objects))
+	ExpectEq("dir/sub/a", objects[0].Name)
+}
+
+func (t *ManifestIntegrationTest) NewObjectsAddDirectories() {
+	t.load("manifest.json", `[{"name": "dir/a"}]`, "")
+
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "new/a", []byte{})
+	AssertEq(nil, err)
+
+	objects := t.listOne("new/")
+	AssertEq(1, len(objects))
+	ExpectEq("new/a", objects[0].Name)
+}
+
+func (t *ManifestIntegrationTest) ObjectsOutsideOfPrefixAreSkipped() {
+	n := t.load("manifest.csv", "only/a,3,7\nother/b,5,8\n", "only/")
+
+	ExpectEq(1, n)
+	o, err := t.stat("a")
+	AssertEq(nil, err)
+	ExpectEq(3, o.Size)
+	_, err = t.stat("other/b")
+	ExpectThat(err, HasSameTypeAs(&gcs.NotFoundError{}))
+}
+
+func (t *ManifestIntegrationTest) ManifestExpires() {
+	t.load("manifest.json", `[{"name": "dir/a"}]`, "")
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "dir/a/", []byte{})
+	AssertEq(nil, err)
+
+	t.clock.AdvanceTime(ttl + time.Millisecond)
+
+	_, err = t.stat("dir/a/")
+	ExpectEq(nil, err)
+	ExpectEq(1, len(t.listOne("dir/a/")))
+}
+
+func (t *ManifestIntegrationTest) InvalidManifest() {
+	p := filepath.Join(t.dir, "manifest.csv")
+	AssertEq(nil, os.WriteFile(p, []byte("dir/a,3,7\ndir/b,x,8\n"), 0600))
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "dir/a/", []byte{})
+	AssertEq(nil, err)
+
+	_, err = caching.LoadManifest(t.bucket, p, "")
+
+	ExpectThat(err, Error(HasSubstr("line 2: size")))
+	// The partial manifest doesn't rule the directories out.
+	_, err = t.stat("dir/a/")
+	ExpectEq(nil, err)
+}
@@ -0,0 +1,350 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/net/context"
+)
+
+// Create a bucket that caches the listings of the directories returned by the
+// supplied wrapped bucket, i.e. of the prefixes ending with "/", for the
+// supplied TTL. The TTL applies to the whole listing: its pages are cached
+// along with its first one, and are listed again once it is listed again.
+// Once a listing expires, its first page is listed again, and if unchanged,
+// the listing is renewed with its cached further pages for another TTL, after
+// which it is listed again in full.
+// The listings of the directories modified through this bucket, and of their
+// parents, are invalidated. The least recently used listings are evicted
+// beyond maxSizeBytes.
+func NewListCacheBucket(
+	ttl time.Duration,
+	maxSizeBytes uint64,
+	clock timeutil.Clock,
+	wrapped gcs.Bucket) gcs.Bucket {
+	return &listCacheBucket{
+		Bucket: wrapped,
+		ttl:    ttl,
+		clock:  clock,
+		cache:  lru.NewCache(maxSizeBytes),
+	}
+}
+
+type listCacheBucket struct {
+	gcs.Bucket
+
+	ttl   time.Duration
+	clock timeutil.Clock
+
+	mu sync.Mutex
+
+	// The cached listings by listingKey.
+	//
+	// GUARDED_BY(mu)
+	cache *lru.Cache
+
+	// Incremented by each invalidation, so that the listings started before
+	// one aren't cached.
+	//
+	// GUARDED_BY(mu)
+	invalidations uint64
+}
+
+// cachedListing is a listing cached until expiration, along with the pages
+// of it listed since, by continuation token. It is immutable once cached, the
+// cache accounting for its size when it is inserted.
+type cachedListing struct {
+	expiration time.Time
+	pages      map[string]*gcs.Listing
+	size       uint64
+
+	// Whether the listing was renewed by revalidating its first page, after
+	// which it is listed again in full once it expires.
+	revalidated bool
+}
+
+// withPage returns a copy of the listing with the supplied page.
+func (l *cachedListing) withPage(token string, page *gcs.Listing) *cachedListing {
+	c := &cachedListing{
+		expiration:  l.expiration,
+		pages:       make(map[string]*gcs.Listing, len(l.pages)+1),
+		revalidated: l.revalidated,
+	}
+	for t, p := range l.pages {
+		if t != token {
+			c.pages[t] = p
+			c.size += listingSize(p)
+		}
+	}
+	c.pages[token] = page
+	c.size += listingSize(page)
+	return c
+}
+
+func (l *cachedListing) Size() uint64 {
+	return l.size
+}
+
+// listingSize returns the assumed size of the supplied listing in memory.
+func listingSize(listing *gcs.Listing) (size uint64) {
+	for _, o := range listing.MinObjects {
+		size += cfg.AverageSizeOfPositiveStatCacheEntry + uint64(len(o.Name))
+	}
+	for _, p := range listing.CollapsedRuns {
+		size += cfg.AverageSizeOfNegativeStatCacheEntry + uint64(len(p))
+	}
+	return size + uint64(len(listing.ContinuationToken))
+}
+
+// listingKey returns the cache key of the listings made with the supplied
+// request, less its continuation token. The directory comes first, for the
+// invalidation of all its listings.
+func listingKey(req *gcs.ListObjectsRequest) string {
+	return fmt.Sprintf("%s%q/%t/%t/%d/%d",
+		listingKeyPrefix(req.Prefix),
+		req.Delimiter,
+		req.IncludeTrailingDelimiter,
+		req.IncludeFoldersAsPrefixes,
+		req.MaxResults,
+		req.ProjectionVal)
+}
+
+// listingKeyPrefix returns the prefix of the cache keys of the listings of the
+// supplied directory.
+func listingKeyPrefix(dir string) string {
+	return dir + "\x00"
+}
+
+// samePage returns whether the supplied pages list the same generations of
+// the same objects, and the same prefixes.
+func samePage(a *gcs.Listing, b *gcs.Listing) bool {
+	if a.ContinuationToken != b.ContinuationToken ||
+		len(a.MinObjects) != len(b.MinObjects) ||
+		len(a.CollapsedRuns) != len(b.CollapsedRuns) {
+		return false
+	}
+	for i, o := range a.MinObjects {
+		p := b.MinObjects[i]
+		if o.Name != p.Name || o.Generation != p.Generation || o.MetaGeneration != p.MetaGeneration {
+			return false
+		}
+	}
+	for i, r := range a.CollapsedRuns {
+		if r != b.CollapsedRuns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookUp returns the cached page of the listing made with the supplied
+// request, if any, and the number of invalidations so far. For the first page
+// of an expired listing not yet revalidated, it returns the listing to
+// revalidate instead.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) lookUp(req *gcs.ListObjectsRequest) (page *gcs.Listing, expired *cachedListing, invalidations uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := listingKey(req)
+	v := b.cache.LookUp(key)
+	if v == nil {
+		return nil, nil, b.invalidations
+	}
+	l := v.(*cachedListing)
+	if !b.clock.Now().Before(l.expiration) {
+		if req.ContinuationToken != "" || l.revalidated {
+			b.cache.Erase(key)
+			return nil, nil, b.invalidations
+		}
+		return nil, l, b.invalidations
+	}
+	return l.pages[req.ContinuationToken], nil, b.invalidations
+}
+
+// insert caches the supplied page of the listing made with the supplied
+// request, unless the listing was invalidated since the supplied number of
+// invalidations. The first page starts a new cached listing, unless it is
+// unchanged from the first page of the supplied expired listing, which is
+// then renewed with its other pages. The further pages are added to the
+// listing if it is still cached.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) insert(req *gcs.ListObjectsRequest, listing *gcs.Listing, expired *cachedListing, invalidations uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.invalidations != invalidations {
+		return
+	}
+
+	key := listingKey(req)
+	var l *cachedListing
+	if req.ContinuationToken == "" {
+		l = &cachedListing{
+			expiration: b.clock.Now().Add(b.ttl),
+			pages:      make(map[string]*gcs.Listing),
+		}
+		if expired != nil && b.cache.LookUpWithoutChangingOrder(key) == expired && samePage(expired.pages[""], listing) {
+			l = expired.withPage("", listing)
+			l.expiration = b.clock.Now().Add(b.ttl)
+			l.revalidated = true
+		}
+	} else if v := b.cache.LookUpWithoutChangingOrder(key); v != nil {
+		l = v.(*cachedListing)
+	} else {
+		return
+	}
+
+	// A new listing is inserted, rather than the cached one being modified,
+	// for the cache to account for its new size.
+	l = l.withPage(req.ContinuationToken, listing)
+	b.cache.Erase(key)
+	// The listings too large for the cache aren't cached.
+	if _, err := b.cache.Insert(key, l); err != nil {
+		b.cache.Erase(key)
+	}
+}
+
+// invalidate erases the cached listings of the directories of the supplied
+// names, and of all the directories under the supplied folder, if any.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) invalidate(folder string, names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.invalidations++
+	if folder != "" {
+		b.cache.EraseEntriesWithGivenPrefix(folder)
+		names = append(names, folder)
+	}
+	for _, name := range names {
+		// The listings of all the ancestors may include the name or its
+		// directory.
+		b.cache.EraseEntriesWithGivenPrefix(listingKeyPrefix(""))
+		for i, c := range name {
+			if c == '/' {
+				b.cache.EraseEntriesWithGivenPrefix(listingKeyPrefix(name[:i+1]))
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	// Only the listings of directories are cached, for their invalidation.
+	if req.Prefix != "" && !strings.HasSuffix(req.Prefix, "/") {
+		return b.Bucket.ListObjects(ctx, req)
+	}
+
+	cached, expired, invalidations := b.lookUp(req)
+	if cached != nil {
+		listing := *cached
+		return &listing, nil
+	}
+
+	listing, err := b.Bucket.ListObjects(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	b.insert(req, listing, expired, invalidations)
+	return listing, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	defer b.invalidate("", req.Name)
+	return b.Bucket.CreateObject(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) FinalizeUpload(ctx context.Context, writer gcs.Writer) (*gcs.MinObject, error) {
+	defer b.invalidate("", writer.ObjectName())
+	return b.Bucket.FinalizeUpload(ctx, writer)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	defer b.invalidate("", req.DstName)
+	return b.Bucket.CopyObject(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	defer b.invalidate("", req.DstName)
+	return b.Bucket.ComposeObjects(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	defer b.invalidate("", req.Name)
+	return b.Bucket.UpdateObject(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	defer b.invalidate("", req.Name)
+	return b.Bucket.DeleteObject(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	defer b.invalidate("", req.SrcName, req.DstName)
+	return b.Bucket.MoveObject(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	defer b.invalidate(folderName)
+	return b.Bucket.DeleteFolder(ctx, folderName)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	defer b.invalidate(folderName, destinationFolderId)
+	return b.Bucket.RenameFolder(ctx, folderName, destinationFolderId)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *listCacheBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	defer b.invalidate("", folderName)
+	return b.Bucket.CreateFolder(ctx, folderName)
+}
@@ -0,0 +1,245 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+const listCacheTTL = 10 * time.Second
+
+// listCountingBucket counts the listings of the wrapped bucket, and the pages
+// listed.
+type listCountingBucket struct {
+	gcs.Bucket
+	listings int
+	pages    int
+}
+
+func (b *listCountingBucket) ListObjects(ctx context.Context, req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if req.ContinuationToken == "" {
+		b.listings++
+	}
+	b.pages++
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+type listCacheBucketTest struct {
+	ctx     context.Context
+	clock   *timeutil.SimulatedClock
+	wrapped *listCountingBucket
+	bucket  gcs.Bucket
+}
+
+func newListCacheBucketTest(t *testing.T, maxSizeBytes uint64) *listCacheBucketTest {
+	t.Helper()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	wrapped := &listCountingBucket{Bucket: fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)}
+	return &listCacheBucketTest{
+		ctx:     context.Background(),
+		clock:   clock,
+		wrapped: wrapped,
+		bucket:  caching.NewListCacheBucket(listCacheTTL, maxSizeBytes, clock, wrapped),
+	}
+}
+
+func (lt *listCacheBucketTest) createThroughBackDoor(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		_, err := storageutil.CreateObject(lt.ctx, lt.wrapped.Bucket, name, []byte{})
+		require.NoError(t, err)
+	}
+}
+
+// list lists the supplied directory, a page of two objects at a time, and
+// returns the names of the objects.
+func (lt *listCacheBucketTest) list(t *testing.T, dir string) (names []string) {
+	t.Helper()
+	req := &gcs.ListObjectsRequest{Prefix: dir, Delimiter: "/", MaxResults: 2}
+	for {
+		listing, err := lt.bucket.ListObjects(lt.ctx, req)
+		require.NoError(t, err)
+		for _, o := range listing.MinObjects {
+			names = append(names, o.Name)
+		}
+		if listing.ContinuationToken == "" {
+			return names
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+func TestListCacheBucket_ListingIsCachedUntilTTL(t *testing.T) {
+	lt := newListCacheBucketTest(t, 1<<20)
+	lt.createThroughBackDoor(t, "dir/a", "dir/b", "dir/c")
+
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c"}, lt.list(t, "dir/"))
+	assert.Equal(t, 2, lt.wrapped.pages)
+
+	// Modify the directory through the back door.
+	lt.createThroughBackDoor(t, "dir/d")
+
+	// The whole listing is served from the cache.
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c"}, lt.list(t, "dir/"))
+	assert.Equal(t, 2, lt.wrapped.pages)
+
+	// Until it expires, when its unchanged first page renews it for another
+	// TTL.
+	lt.clock.AdvanceTime(listCacheTTL)
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c"}, lt.list(t, "dir/"))
+	assert.Equal(t, 3, lt.wrapped.pages)
+
+	// After which it is listed again in full.
+	lt.clock.AdvanceTime(listCacheTTL)
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c", "dir/d"}, lt.list(t, "dir/"))
+	assert.Equal(t, 5, lt.wrapped.pages)
+}
+
+func TestListCacheBucket_ExpiredListingWithChangedFirstPageIsListedAgain(t *testing.T) {
+	lt := newListCacheBucketTest(t, 1<<20)
+	lt.createThroughBackDoor(t, "dir/b", "dir/c", "dir/d")
+	assert.Equal(t, []string{"dir/b", "dir/c", "dir/d"}, lt.list(t, "dir/"))
+
+	lt.createThroughBackDoor(t, "dir/a")
+	lt.clock.AdvanceTime(listCacheTTL)
+
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c", "dir/d"}, lt.list(t, "dir/"))
+	assert.Equal(t, 4, lt.wrapped.pages)
+}
+
+func TestListCacheBucket_InvalidatingMultiPageListingKeepsCacheUsable(t *testing.T) {
+	// Room for the pages of the two listings, each of about 3 KB.
+	lt := newListCacheBucketTest(t, 20000)
+	lt.createThroughBackDoor(t, "dir/a", "dir/b", "dir/c", "dir/d", "dir/e")
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c", "dir/d", "dir/e"}, lt.list(t, "dir/"))
+
+	// Invalidate the listing, then cache listings again.
+	require.NoError(t, lt.bucket.DeleteObject(lt.ctx, &gcs.DeleteObjectRequest{Name: "dir/e"}))
+	lt.createThroughBackDoor(t, "other/a", "other/b", "other/c")
+
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c", "dir/d"}, lt.list(t, "dir/"))
+	assert.Equal(t, []string{"other/a", "other/b", "other/c"}, lt.list(t, "other/"))
+	assert.Equal(t, []string{"dir/a", "dir/b", "dir/c", "dir/d"}, lt.list(t, "dir/"))
+}
+
+func TestListCacheBucket_ModificationsInvalidateListings(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(context.Context, gcs.Bucket) error
+	}{
+		{
+			name: "create",
+			modify: func(ctx context.Context, b gcs.Bucket) error {
+				_, err := storageutil.CreateObject(ctx, b, "dir/sub/d", []byte{})
+				return err
+			},
+		},
+		{
+			name: "delete",
+			modify: func(ctx context.Context, b gcs.Bucket) error {
+				return b.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: "dir/a"})
+			},
+		},
+		{
+			name: "copy",
+			modify: func(ctx context.Context, b gcs.Bucket) error {
+				_, err := b.CopyObject(ctx, &gcs.CopyObjectRequest{SrcName: "other/x", DstName: "dir/d"})
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lt := newListCacheBucketTest(t, 1<<20)
+			lt.createThroughBackDoor(t, "dir/a", "other/x")
+			lt.list(t, "")
+			lt.list(t, "dir/")
+			lt.list(t, "other/")
+			listings := lt.wrapped.listings
+
+			require.NoError(t, tc.modify(lt.ctx, lt.bucket))
+
+			// The listings of the modified directory and of its parent are
+			// refreshed, unlike the ones of the other directories.
+			lt.list(t, "")
+			lt.list(t, "dir/")
+			lt.list(t, "other/")
+			assert.Equal(t, listings+2, lt.wrapped.listings)
+		})
+	}
+}
+
+func TestListCacheBucket_PrefixesOtherThanDirectoriesAreNotCached(t *testing.T) {
+	lt := newListCacheBucketTest(t, 1<<20)
+	lt.createThroughBackDoor(t, "dir/a")
+
+	lt.list(t, "dir/a")
+	lt.list(t, "dir/a")
+
+	assert.Equal(t, 2, lt.wrapped.listings)
+}
+
+func TestListCacheBucket_ListingsLargerThanCacheAreNotCached(t *testing.T) {
+	lt := newListCacheBucketTest(t, 1)
+	lt.createThroughBackDoor(t, "dir/a")
+
+	lt.list(t, "dir/")
+	lt.list(t, "dir/")
+
+	assert.Equal(t, 2, lt.wrapped.listings)
+}
+
+func TestListCacheBucket_ListingRacingWithModificationIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	schedule := fake.NewFaultSchedule()
+	wrapped := &listCountingBucket{Bucket: fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)}
+	bucket := caching.NewListCacheBucket(listCacheTTL, 1<<20, clock, fake.NewFaultyBucket(wrapped, schedule))
+	_, err := storageutil.CreateObject(ctx, wrapped.Bucket, "dir/a", []byte{})
+	require.NoError(t, err)
+	// The object is deleted through the cache while the first listing is in
+	// flight, after GCS listed it.
+	schedule.Add(fake.Fault{
+		Method: "ListObjects",
+		Call:   1,
+		After: func() {
+			assert.NoError(t, bucket.DeleteObject(ctx, &gcs.DeleteObjectRequest{Name: "dir/a"}))
+		},
+	})
+	req := &gcs.ListObjectsRequest{Prefix: "dir/", Delimiter: "/"}
+
+	listing, err := bucket.ListObjects(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, listing.MinObjects, 1)
+	listing, err = bucket.ListObjects(ctx, req)
+
+	// The stale listing wasn't cached.
+	require.NoError(t, err)
+	assert.Empty(t, listing.MinObjects)
+	assert.Equal(t, 2, wrapped.listings)
+}
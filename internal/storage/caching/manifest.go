@@ -0,0 +1,331 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// manifestEntry is an object of a JSON manifest. The numbers may also be
+// strings, as in the listings of the GCS JSON API.
+type manifestEntry struct {
+	Name           string      `json:"name"`
+	Size           manifestInt `json:"size"`
+	Generation     manifestInt `json:"generation"`
+	MetaGeneration manifestInt `json:"metageneration"`
+	Updated        time.Time   `json:"updated"`
+}
+
+type manifestInt int64
+
+func (i *manifestInt) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*i = manifestInt(n)
+	return nil
+}
+
+func (e *manifestEntry) minObject() *gcs.MinObject {
+	return &gcs.MinObject{
+		Name:           e.Name,
+		Size:           uint64(e.Size),
+		Generation:     int64(e.Generation),
+		MetaGeneration: int64(e.MetaGeneration),
+		Updated:        e.Updated,
+	}
+}
+
+// ReadManifest calls fn with each object of the supplied manifest: either a
+// JSON array or a stream of JSON objects with "name", "size", "generation",
+// "metageneration" and "updated" fields, or, if isCSV is set, CSV records with
+// the columns name,size,generation[,metageneration[,updated]] and an optional
+// header.
+func ReadManifest(r io.Reader, isCSV bool, fn func(*gcs.MinObject) error) error {
+	if isCSV {
+		return readCSVManifest(r, fn)
+	}
+	return readJSONManifest(r, fn)
+}
+
+func readJSONManifest(r io.Reader, fn func(*gcs.MinObject) error) error {
+	br := bufio.NewReader(r)
+	isArray, err := startsWithArray(br)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(br)
+	if isArray {
+		if _, err = d.Token(); err != nil {
+			return err
+		}
+	}
+	for i := 1; ; i++ {
+		if isArray && !d.More() {
+			_, err = d.Token()
+			return err
+		}
+
+		var e manifestEntry
+		if err = d.Decode(&e); err == io.EOF && !isArray {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+		if e.Name == "" {
+			return fmt.Errorf("object %d: missing name", i)
+		}
+		if err = fn(e.minObject()); err != nil {
+			return err
+		}
+	}
+}
+
+// startsWithArray reports whether the first non-space byte of r opens a JSON
+// array, without consuming it.
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(c)) {
+			return c == '[', r.UnreadByte()
+		}
+	}
+}
+
+func readCSVManifest(r io.Reader, fn func(*gcs.MinObject) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		o, err := parseCSVRecord(record)
+		if err != nil {
+			// The first record may be a header.
+			if line == 1 && record[0] == "name" {
+				continue
+			}
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err = fn(o); err != nil {
+			return err
+		}
+	}
+}
+
+func parseCSVRecord(record []string) (o *gcs.MinObject, err error) {
+	if len(record) < 3 || len(record) > 5 {
+		return nil, fmt.Errorf("%d columns instead of 3 to 5", len(record))
+	}
+	if record[0] == "" {
+		return nil, errors.New("missing name")
+	}
+
+	o = &gcs.MinObject{Name: record[0]}
+	if o.Size, err = strconv.ParseUint(record[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("size: %w", err)
+	}
+	if o.Generation, err = strconv.ParseInt(record[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("generation: %w", err)
+	}
+	if len(record) > 3 {
+		if o.MetaGeneration, err = strconv.ParseInt(record[3], 10, 64); err != nil {
+			return nil, fmt.Errorf("metageneration: %w", err)
+		}
+	}
+	if len(record) > 4 {
+		if o.Updated, err = time.Parse(time.RFC3339Nano, record[4]); err != nil {
+			return nil, fmt.Errorf("updated: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// LoadManifest loads the objects of the manifest at the supplied path into the
+// stat cache of a bucket created with NewFastStatBucket, and returns their
+// number. See ReadManifest for the format, CSV being used for the paths ending
+// in ".csv". The objects are named with the supplied prefix, which is taken
+// off their names, and the ones outside of it are skipped.
+//
+// The entries expire like the ones of the objects statted from GCS. Until
+// then, the directories that have no objects in the manifest, nor in the
+// objects cached since, are considered nonexistent, so that the lookups of
+// the files don't also look for directories with their names in GCS.
+func LoadManifest(bucket gcs.Bucket, path string, prefix string) (n int, err error) {
+	b, ok := bucket.(*fastStatBucket)
+	if !ok {
+		return 0, errors.New("the bucket doesn't have a stat cache")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	b.mu.Lock()
+	b.manifestDirs = make(map[string]string)
+	b.manifestExpiration = b.clock.Now().Add(b.ttl)
+	b.mu.Unlock()
+
+	isHierarchical := b.BucketType() == gcs.Hierarchical
+	isCSV := strings.EqualFold(filepath.Ext(path), ".csv")
+	err = ReadManifest(bufio.NewReader(f), isCSV, func(o *gcs.MinObject) error {
+		name, ok := strings.CutPrefix(o.Name, prefix)
+		if !ok || name == "" {
+			return nil
+		}
+		o.Name = name
+		b.insertFromManifest(o, isHierarchical)
+		n++
+		return nil
+	})
+	if err != nil {
+		// Don't rely on a partial manifest to rule directories out.
+		b.mu.Lock()
+		b.manifestDirs = nil
+		b.mu.Unlock()
+		return n, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if isHierarchical {
+		b.insertManifestFolders()
+	}
+	return n, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) insertFromManifest(o *gcs.MinObject, isHierarchical bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Like in the hierarchical listings, the placeholders of the folders
+	// aren't objects.
+	if !isHierarchical || !strings.HasSuffix(o.Name, "/") {
+		b.cache.Insert(o, b.expiration(b.clock.Now()))
+	}
+	b.noteManifestDirs(o.Name)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) insertManifestFolders() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	for dir := range b.manifestDirs {
+		b.cache.InsertFolder(&gcs.Folder{Name: dir}, b.expiration(now))
+	}
+}
+
+// noteManifestDirs records the directories of the named object, or of the
+// named directory and the directory itself, if a manifest was loaded. Each
+// directory is mapped to the first object under it in lexicographic order,
+// which is the one returned by listing it.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *fastStatBucket) noteManifestDirs(name string) {
+	if b.manifestDirs == nil {
+		return
+	}
+	for i := 0; ; {
+		j := strings.Index(name[i:], "/")
+		if j < 0 {
+			return
+		}
+		i += j + 1
+		dir := name[:i]
+		if first, ok := b.manifestDirs[dir]; !ok || name < first {
+			b.manifestDirs[dir] = name
+		}
+	}
+}
+
+// absentFromManifest reports whether the named directory is known not to exist
+// from the loaded manifest.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) absentFromManifest(name string) bool {
+	if !strings.HasSuffix(name, "/") {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.manifestDirs == nil || !b.clock.Now().Before(b.manifestExpiration) {
+		return false
+	}
+	_, ok := b.manifestDirs[name]
+	return !ok
+}
+
+// listFromManifest serves from the loaded manifest the listings of a single
+// object under a directory, which look for the implicit directories. The
+// returned bool is false if GCS must be listed instead.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *fastStatBucket) listFromManifest(req *gcs.ListObjectsRequest) (*gcs.Listing, bool) {
+	if req.MaxResults != 1 || req.Delimiter != "" || req.ContinuationToken != "" || req.Prefix == "" {
+		return nil, false
+	}
+	if b.absentFromManifest(req.Prefix) {
+		return &gcs.Listing{}, true
+	}
+
+	b.mu.Lock()
+	first, ok := b.manifestDirs[req.Prefix]
+	b.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	if hit, m, expired := b.lookUp(first); hit && !expired && m != nil {
+		return &gcs.Listing{MinObjects: []*gcs.MinObject{m}}, true
+	}
+	return nil, false
+}
+
+// manifestNotFound returns the error of the stat of a directory known not to
+// exist from the loaded manifest.
+func manifestNotFound(name string) error {
+	return &gcs.NotFoundError{
+		Err: fmt.Errorf("%v not found in the manifest", name),
+	}
+}
@@ -141,6 +141,40 @@ func (m *mockStatCache) LookUp(p0 string, p1 time.Time) (o0 bool, o1 *gcs.MinObj
 	return
 }
 
+func (m *mockStatCache) LookUpKeepingExpired(p0 string, p1 time.Time) (o0 bool, o1 *gcs.MinObject, o2 bool) {
+	// Get a file name and line number for the caller.
+	_, file, line, _ := runtime.Caller(1)
+
+	// Hand the call off to the controller, which does most of the work.
+	retVals := m.controller.HandleMethodCall(
+		m,
+		"LookUpKeepingExpired",
+		file,
+		line,
+		[]interface{}{p0, p1})
+
+	if len(retVals) != 3 {
+		panic(fmt.Sprintf("mockStatCache.LookUpKeepingExpired: invalid return values: %v", retVals))
+	}
+
+	// o0 bool
+	if retVals[0] != nil {
+		o0 = retVals[0].(bool)
+	}
+
+	// o1 *gcs.MinObject
+	if retVals[1] != nil {
+		o1 = retVals[1].(*gcs.MinObject)
+	}
+
+	// o2 bool
+	if retVals[2] != nil {
+		o2 = retVals[2].(bool)
+	}
+
+	return
+}
+
 func (m *mockStatCache) InsertFolder(p0 *gcs.Folder, p1 time.Time) {
 	// Get a file name and line number for the caller.
 	_, file, line, _ := runtime.Caller(1)
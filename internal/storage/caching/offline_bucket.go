@@ -0,0 +1,367 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The outcomes of the requests made while GCS is unreachable.
+const (
+	offlineServedFromCache = "served_from_cache"
+	offlineFailedFast      = "failed_fast"
+)
+
+// Reachability tracks whether GCS is reachable, from the requests of the
+// buckets created with NewOfflineBucket. Once a request fails because GCS is
+// unreachable, the requests fail fast with a *gcs.UnreachableError, but for
+// one every probe interval which probes whether it's reachable again, and the
+// stat caches created with it serve their expired entries.
+type Reachability struct {
+	probeInterval time.Duration
+	clock         timeutil.Clock
+	metricHandle  common.MetricHandle
+
+	mu sync.Mutex
+
+	// The time GCS was found unreachable, zero while it's reachable.
+	//
+	// GUARDED_BY(mu)
+	unreachableSince time.Time
+
+	// The time of the last request which found GCS unreachable, or probed it,
+	// and the error it failed with.
+	//
+	// GUARDED_BY(mu)
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewReachability returns the tracker of the reachability of GCS, for
+// offline-mode serve-cache, probing it every probeInterval while it's
+// unreachable.
+func NewReachability(
+	probeInterval time.Duration,
+	clock timeutil.Clock,
+	metricHandle common.MetricHandle) *Reachability {
+	return &Reachability{
+		probeInterval: probeInterval,
+		clock:         clock,
+		metricHandle:  metricHandle,
+	}
+}
+
+// isUnreachable returns true if err shows that GCS couldn't be reached, and ok
+// false if it shows neither that it could nor that it couldn't, e.g. because
+// the request was cancelled.
+func isUnreachable(err error) (unreachable bool, ok bool) {
+	if err == nil {
+		return false, true
+	}
+
+	// The cancelled requests, e.g. interrupted reads, come back as errors of
+	// the transport too, and tell nothing about GCS.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, false
+	}
+
+	// Connections refused or timed out, and failed DNS lookups, which the
+	// retries of the client wrap. The other transport errors, e.g. of a
+	// connection reset mid-read, don't show that GCS can't be reached.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true, true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, true
+	}
+
+	var gapiErr *googleapi.Error
+	if errors.As(err, &gapiErr) {
+		switch gapiErr.Code {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, true
+		}
+		return false, true
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.GRPCStatus().Code() == codes.Unavailable, true
+	}
+
+	if status.Code(err) == codes.Unavailable {
+		return true, true
+	}
+
+	// Errors like *gcs.NotFoundError are answers of GCS.
+	return false, true
+}
+
+// LOCKS_EXCLUDED(r.mu)
+func (r *Reachability) admit(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.unreachableSince.IsZero() {
+		return nil
+	}
+
+	// Let a request through to probe GCS once in a while.
+	now := r.clock.Now()
+	if now.Sub(r.lastAttempt) >= r.probeInterval {
+		r.lastAttempt = now
+		return nil
+	}
+
+	r.metricHandle.GCSOfflineRequestCount(ctx, 1, []common.MetricAttr{{Key: common.OfflineOutcome, Value: offlineFailedFast}})
+	return &gcs.UnreachableError{
+		Err: fmt.Errorf("GCS has been unreachable for %v: %w", now.Sub(r.unreachableSince).Round(time.Second), r.lastErr),
+	}
+}
+
+// record records the error returned by a request to GCS, and returns it,
+// as a *gcs.UnreachableError if it shows that GCS couldn't be reached.
+//
+// LOCKS_EXCLUDED(r.mu)
+func (r *Reachability) record(err error) error {
+	unreachable, ok := isUnreachable(err)
+	if !ok {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	if !unreachable {
+		if !r.unreachableSince.IsZero() {
+			logger.Infof("GCS is reachable again after %v.", now.Sub(r.unreachableSince).Round(time.Second))
+			r.unreachableSince = time.Time{}
+			r.lastErr = nil
+		}
+		return err
+	}
+
+	if r.unreachableSince.IsZero() {
+		logger.Warnf("GCS is unreachable, serving the cached objects and failing the other ops until it's reachable again: %v", err)
+		r.unreachableSince = now
+	}
+	r.lastAttempt = now
+	r.lastErr = err
+	return &gcs.UnreachableError{Err: err}
+}
+
+// servedFromCache records that a request was served from the stat cache
+// because GCS is unreachable.
+func (r *Reachability) servedFromCache(ctx context.Context, name string) {
+	logger.Tracef("Serving the expired stat cache entry of %q as GCS is unreachable", name)
+	r.metricHandle.GCSOfflineRequestCount(ctx, 1, []common.MetricAttr{{Key: common.OfflineOutcome, Value: offlineServedFromCache}})
+}
+
+// Create a bucket that fails the requests fast with a *gcs.UnreachableError,
+// without calling the supplied wrapped bucket, while the supplied tracker finds
+// GCS unreachable. The requests failing because GCS couldn't be reached fail
+// with a *gcs.UnreachableError too, so that the stat caches above serve their
+// expired entries instead.
+func NewOfflineBucket(r *Reachability, wrapped gcs.Bucket) gcs.Bucket {
+	return &offlineBucket{
+		Bucket:       wrapped,
+		reachability: r,
+	}
+}
+
+type offlineBucket struct {
+	gcs.Bucket
+
+	reachability *Reachability
+}
+
+// call calls f unless GCS was recently found unreachable, and records whether
+// it was reachable.
+func (b *offlineBucket) call(ctx context.Context, f func() error) error {
+	if err := b.reachability.admit(ctx); err != nil {
+		return err
+	}
+	return b.reachability.record(f())
+}
+
+func (b *offlineBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	err = b.call(ctx, func() (err error) {
+		rc, err = b.Bucket.NewReader(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, func() (err error) {
+		o, err = b.Bucket.CreateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (w gcs.Writer, err error) {
+	err = b.call(ctx, func() (err error) {
+		w, err = b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) FinalizeUpload(
+	ctx context.Context,
+	w gcs.Writer) (m *gcs.MinObject, err error) {
+	// The contents written so far are lost if the upload isn't finalized, so
+	// it's always attempted.
+	m, err = b.Bucket.FinalizeUpload(ctx, w)
+	err = b.reachability.record(err)
+	return
+}
+
+func (b *offlineBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, func() (err error) {
+		o, err = b.Bucket.CopyObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, func() (err error) {
+		o, err = b.Bucket.ComposeObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, attrs *gcs.ExtendedObjectAttributes, err error) {
+	err = b.call(ctx, func() (err error) {
+		m, attrs, err = b.Bucket.StatObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	err = b.call(ctx, func() (err error) {
+		listing, err = b.Bucket.ListObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, func() (err error) {
+		o, err = b.Bucket.UpdateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return b.call(ctx, func() error {
+		return b.Bucket.DeleteObject(ctx, req)
+	})
+}
+
+func (b *offlineBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (o *gcs.Object, err error) {
+	err = b.call(ctx, func() (err error) {
+		o, err = b.Bucket.MoveObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return b.call(ctx, func() error {
+		return b.Bucket.DeleteFolder(ctx, folderName)
+	})
+}
+
+func (b *offlineBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (f *gcs.Folder, err error) {
+	err = b.call(ctx, func() (err error) {
+		f, err = b.Bucket.GetFolder(ctx, folderName)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (f *gcs.Folder, err error) {
+	err = b.call(ctx, func() (err error) {
+		f, err = b.Bucket.RenameFolder(ctx, folderName, destinationFolderId)
+		return
+	})
+	return
+}
+
+func (b *offlineBucket) CreateFolder(
+	ctx context.Context,
+	folderName string) (f *gcs.Folder, err error) {
+	err = b.call(ctx, func() (err error) {
+		f, err = b.Bucket.CreateFolder(ctx, folderName)
+		return
+	})
+	return
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	offlineStatTTL       = time.Minute
+	offlineProbeInterval = 10 * time.Second
+)
+
+type offlineBucketTest struct {
+	ctx      context.Context
+	clock    *timeutil.SimulatedClock
+	schedule *fake.FaultSchedule
+	wrapped  gcs.Bucket
+	bucket   gcs.Bucket
+}
+
+func newOfflineBucketTest(t *testing.T) *offlineBucketTest {
+	t.Helper()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	wrapped := fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)
+	_, err := storageutil.CreateObject(context.Background(), wrapped, "model/weights", []byte("taco"))
+	require.NoError(t, err)
+	schedule := fake.NewFaultSchedule()
+	reachability := caching.NewReachability(offlineProbeInterval, clock, common.NewNoopMetrics())
+	statCache := metadata.NewStatCacheBucketView(lru.NewCache(1<<20), "")
+	return &offlineBucketTest{
+		ctx:      context.Background(),
+		clock:    clock,
+		schedule: schedule,
+		wrapped:  wrapped,
+		bucket: caching.NewFastStatBucket(
+			offlineStatTTL,
+			0,
+			0,
+			reachability,
+			statCache,
+			clock,
+			caching.NewOfflineBucket(reachability, fake.NewFaultyBucket(wrapped, schedule))),
+	}
+}
+
+// goOffline fails the calls of the supplied method as if GCS couldn't be
+// reached, from the next one on.
+func (ot *offlineBucketTest) goOffline(method string) {
+	ot.schedule.Add(fake.Fault{
+		Method: method,
+		Call:   ot.schedule.Calls(method) + 1,
+		Err: &url.Error{
+			Op:  "Get",
+			URL: "https://storage.googleapis.com",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+		},
+	})
+}
+
+func (ot *offlineBucketTest) stat(name string) (*gcs.MinObject, error) {
+	m, _, err := ot.bucket.StatObject(ot.ctx, &gcs.StatObjectRequest{Name: name})
+	return m, err
+}
+
+func TestOfflineBucket_ServesExpiredEntriesWhileUnreachable(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	_, err := ot.stat("model/weights")
+	require.NoError(t, err)
+	_, err = ot.stat("model/missing")
+	require.Error(t, err)
+	ot.clock.AdvanceTime(2 * offlineStatTTL)
+	ot.goOffline("StatObject")
+
+	m, err := ot.stat("model/weights")
+
+	require.NoError(t, err)
+	assert.Equal(t, "model/weights", m.Name)
+	_, err = ot.stat("model/missing")
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t, err, &notFoundErr)
+	assert.Equal(t, 3, ot.schedule.Calls("StatObject"))
+}
+
+func TestOfflineBucket_FailsMissesFast(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	ot.goOffline("StatObject")
+	_, err := ot.stat("model/weights")
+	var unreachableErr *gcs.UnreachableError
+	require.ErrorAs(t, err, &unreachableErr)
+
+	_, err = ot.stat("model/other")
+	_, createErr := storageutil.CreateObject(ot.ctx, ot.bucket, "model/new", []byte("burrito"))
+
+	assert.ErrorAs(t, err, &unreachableErr)
+	assert.ErrorAs(t, createErr, &unreachableErr)
+	assert.Equal(t, 1, ot.schedule.Calls("StatObject"))
+	assert.Equal(t, 0, ot.schedule.Calls("CreateObject"))
+}
+
+func TestOfflineBucket_ProbesUntilReachable(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	ot.goOffline("StatObject")
+	_, err := ot.stat("model/weights")
+	require.Error(t, err)
+	ot.clock.AdvanceTime(offlineProbeInterval)
+	ot.goOffline("StatObject")
+
+	// The probe finds GCS still unreachable.
+	_, err = ot.stat("model/weights")
+	require.Error(t, err)
+	_, err = ot.stat("model/weights")
+	require.Error(t, err)
+	require.Equal(t, 2, ot.schedule.Calls("StatObject"))
+	ot.clock.AdvanceTime(offlineProbeInterval)
+
+	// The next one finds it reachable again.
+	m, err := ot.stat("model/weights")
+	require.NoError(t, err)
+	assert.Equal(t, "model/weights", m.Name)
+	_, err = ot.stat("model/other")
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t, err, &notFoundErr)
+	assert.Equal(t, 4, ot.schedule.Calls("StatObject"))
+}
+
+func TestOfflineBucket_ErrorsFromGCSDontTakeItOffline(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	ot.schedule.Add(fake.Fault{
+		Method: "StatObject",
+		Call:   1,
+		Err:    &googleapi.Error{Code: 429},
+	})
+	_, err := ot.stat("model/weights")
+	require.Error(t, err)
+
+	_, err = ot.stat("model/weights")
+
+	assert.NoError(t, err)
+}
+
+func TestOfflineBucket_ServiceUnavailableTakesItOffline(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	ot.schedule.Add(fake.Fault{
+		Method: "StatObject",
+		Call:   1,
+		Err:    &googleapi.Error{Code: 503},
+	})
+	_, err := ot.stat("model/weights")
+	require.Error(t, err)
+
+	_, err = ot.stat("model/weights")
+
+	var unreachableErr *gcs.UnreachableError
+	assert.ErrorAs(t, err, &unreachableErr)
+	assert.Equal(t, 1, ot.schedule.Calls("StatObject"))
+}
+
+func TestOfflineBucket_CancelledRequestsDontTakeItOffline(t *testing.T) {
+	ot := newOfflineBucketTest(t)
+	// An interrupted read comes back from the HTTP client as a *url.Error,
+	// which is a net.Error.
+	ot.schedule.Add(fake.Fault{
+		Method: "StatObject",
+		Call:   1,
+		Err: &url.Error{
+			Op:  "Get",
+			URL: "https://storage.googleapis.com",
+			Err: context.Canceled,
+		},
+	})
+	_, err := ot.stat("model/weights")
+	require.Error(t, err)
+
+	_, err = ot.stat("model/weights")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ot.schedule.Calls("StatObject"))
+}
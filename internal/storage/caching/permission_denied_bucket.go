@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/net/context"
+)
+
+// The kinds of access to a folder denied separately, as the IAM policy of a
+// managed folder may e.g. allow reading its objects but not listing it.
+const (
+	listAccess = "list"
+	readAccess = "read"
+)
+
+// Create a bucket that caches, for the supplied TTL, the denials of access to
+// a folder returned by the supplied wrapped bucket as *gcs.PermissionDeniedError,
+// e.g. by the IAM policy of a managed folder. Meanwhile, the same kind of
+// access to the folder - the listings of the folder, or the stats and reads of
+// its objects - fails with the cached error without calling the wrapped
+// bucket.
+//
+// Without it, the lookups of the entries of a folder whose listing is denied
+// each list it again, and a program walking the folder retries for a long
+// time before giving up.
+func NewPermissionDeniedBucket(
+	ttl time.Duration,
+	clock timeutil.Clock,
+	wrapped gcs.Bucket) gcs.Bucket {
+	return &permissionDeniedBucket{
+		Bucket:  wrapped,
+		ttl:     ttl,
+		clock:   clock,
+		denials: make(map[string]denial),
+	}
+}
+
+type permissionDeniedBucket struct {
+	gcs.Bucket
+
+	ttl   time.Duration
+	clock timeutil.Clock
+
+	mu sync.Mutex
+
+	// The cached denials by the kind of access and the folder.
+	//
+	// GUARDED_BY(mu)
+	denials map[string]denial
+}
+
+type denial struct {
+	err        error
+	expiration time.Time
+}
+
+// folderOf returns the folder containing the object with the supplied name,
+// or the folder itself if the name ends with "/".
+func folderOf(name string) string {
+	return name[:strings.LastIndex(name, "/")+1]
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *permissionDeniedBucket) lookUp(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d, ok := b.denials[key]
+	if !ok {
+		return nil
+	}
+	if b.clock.Now().After(d.expiration) {
+		delete(b.denials, key)
+		return nil
+	}
+	return d.err
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *permissionDeniedBucket) insert(key string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Expired denials are only deleted when looked up, drop them all before
+	// the map grows with the folders walked.
+	now := b.clock.Now()
+	if _, ok := b.denials[key]; !ok {
+		for k, d := range b.denials {
+			if now.After(d.expiration) {
+				delete(b.denials, k)
+			}
+		}
+	}
+
+	b.denials[key] = denial{
+		err:        err,
+		expiration: now.Add(b.ttl),
+	}
+}
+
+// call calls f unless the supplied kind of access to the folder of the
+// supplied name was recently denied, and caches the denial of the access if f
+// returns one.
+func (b *permissionDeniedBucket) call(access string, name string, f func() error) error {
+	folder := folderOf(name)
+	key := access + "\x00" + folder
+	if err := b.lookUp(key); err != nil {
+		return err
+	}
+
+	err := f()
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	if errors.As(err, &permissionDeniedErr) {
+		logger.Warnf("%s access to %q is denied, failing it for %v: %v", access, folder, b.ttl, err)
+		b.insert(key, &gcs.PermissionDeniedError{
+			Err: fmt.Errorf("%s access to %q was denied within the last %v: %w", access, folder, b.ttl, err),
+		})
+	}
+	return err
+}
+
+func (b *permissionDeniedBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	err = b.call(readAccess, req.Name, func() (err error) {
+		rc, err = b.Bucket.NewReader(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *permissionDeniedBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, attrs *gcs.ExtendedObjectAttributes, err error) {
+	err = b.call(readAccess, req.Name, func() (err error) {
+		m, attrs, err = b.Bucket.StatObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *permissionDeniedBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	err = b.call(listAccess, req.Prefix, func() (err error) {
+		listing, err = b.Bucket.ListObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *permissionDeniedBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (folder *gcs.Folder, err error) {
+	err = b.call(readAccess, folderName, func() (err error) {
+		folder, err = b.Bucket.GetFolder(ctx, folderName)
+		return
+	})
+	return
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+const permissionDeniedTTL = 5 * time.Second
+
+type permissionDeniedBucketTest struct {
+	ctx      context.Context
+	clock    *timeutil.SimulatedClock
+	schedule *fake.FaultSchedule
+	bucket   gcs.Bucket
+}
+
+func newPermissionDeniedBucketTest(t *testing.T) *permissionDeniedBucketTest {
+	t.Helper()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	wrapped := fake.NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical)
+	for _, name := range []string{"denied/foo", "denied/bar", "allowed/foo"} {
+		_, err := storageutil.CreateObject(context.Background(), wrapped, name, []byte{})
+		require.NoError(t, err)
+	}
+	schedule := fake.NewFaultSchedule()
+	return &permissionDeniedBucketTest{
+		ctx:      context.Background(),
+		clock:    clock,
+		schedule: schedule,
+		bucket:   caching.NewPermissionDeniedBucket(permissionDeniedTTL, clock, fake.NewFaultyBucket(wrapped, schedule)),
+	}
+}
+
+// denyFirst denies the first call of the supplied method.
+func (pt *permissionDeniedBucketTest) denyFirst(method string) {
+	pt.schedule.Add(fake.Fault{
+		Method: method,
+		Call:   1,
+		Err:    &gcs.PermissionDeniedError{Err: errors.New("managed folder IAM")},
+	})
+}
+
+func (pt *permissionDeniedBucketTest) list(prefix string) error {
+	_, err := pt.bucket.ListObjects(pt.ctx, &gcs.ListObjectsRequest{Prefix: prefix, Delimiter: "/"})
+	return err
+}
+
+func (pt *permissionDeniedBucketTest) stat(name string) error {
+	_, _, err := pt.bucket.StatObject(pt.ctx, &gcs.StatObjectRequest{Name: name})
+	return err
+}
+
+func TestPermissionDeniedBucket_DeniedListingIsCachedUntilTTL(t *testing.T) {
+	pt := newPermissionDeniedBucketTest(t)
+	pt.denyFirst("ListObjects")
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	require.ErrorAs(t, pt.list("denied/"), &permissionDeniedErr)
+
+	pt.clock.AdvanceTime(permissionDeniedTTL)
+	err := pt.list("denied/")
+
+	assert.ErrorAs(t, err, &permissionDeniedErr)
+	assert.ErrorContains(t, err, "managed folder IAM")
+	assert.Equal(t, 1, pt.schedule.Calls("ListObjects"))
+	// The other folders and the other kinds of access aren't denied.
+	assert.NoError(t, pt.list("allowed/"))
+	assert.NoError(t, pt.stat("denied/foo"))
+	// The folder is listed again once the denial expires.
+	pt.clock.AdvanceTime(time.Millisecond)
+	assert.NoError(t, pt.list("denied/"))
+}
+
+func TestPermissionDeniedBucket_DeniedStatDeniesTheObjectsOfTheFolder(t *testing.T) {
+	pt := newPermissionDeniedBucketTest(t)
+	pt.denyFirst("StatObject")
+	var permissionDeniedErr *gcs.PermissionDeniedError
+	require.ErrorAs(t, pt.stat("denied/foo"), &permissionDeniedErr)
+
+	err := pt.stat("denied/bar")
+
+	assert.ErrorAs(t, err, &permissionDeniedErr)
+	assert.Equal(t, 1, pt.schedule.Calls("StatObject"))
+	_, err = pt.bucket.NewReader(pt.ctx, &gcs.ReadObjectRequest{Name: "denied/bar"})
+	assert.ErrorAs(t, err, &permissionDeniedErr)
+	assert.NoError(t, pt.stat("allowed/foo"))
+	assert.NoError(t, pt.list("denied/"))
+}
+
+func TestPermissionDeniedBucket_OtherErrorsAreNotCached(t *testing.T) {
+	pt := newPermissionDeniedBucketTest(t)
+	pt.schedule.Add(fake.Fault{Method: "StatObject", Call: 1, Err: errors.New("taco")})
+	require.ErrorContains(t, pt.stat("denied/foo"), "taco")
+
+	assert.NoError(t, pt.stat("denied/foo"))
+	assert.Equal(t, 2, pt.schedule.Calls("StatObject"))
+}
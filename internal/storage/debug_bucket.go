@@ -28,16 +28,24 @@ import (
 // Wrap the supplied bucket in a layer that prints debug messages.
 func NewDebugBucket(
 	wrapped gcs.Bucket) (b gcs.Bucket) {
-	b = &debugBucket{
+	db := &debugBucket{
 		wrapped: wrapped,
 	}
+	if r, ok := wrapped.(gcs.ReadRegionReporter); ok {
+		db.readRegion = r.ReadRegion()
+	}
 
+	b = db
 	return
 }
 
 type debugBucket struct {
 	wrapped gcs.Bucket
 
+	// readRegion is the region serving the reads, logged along with them if
+	// known.
+	readRegion string
+
 	nextRequestID uint64
 }
 
@@ -132,7 +140,14 @@ func (b *debugBucket) BucketType() gcs.BucketType {
 func (b *debugBucket) NewReader(
 	ctx context.Context,
 	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
-	id, desc, start := b.startRequest("Read(%q, %v)", req.Name, req.Range)
+	var id uint64
+	var desc string
+	var start time.Time
+	if b.readRegion != "" {
+		id, desc, start = b.startRequest("Read(%q, %v, region %s)", req.Name, req.Range, b.readRegion)
+	} else {
+		id, desc, start = b.startRequest("Read(%q, %v)", req.Name, req.Range)
+	}
 
 	// Call through.
 	rc, err = b.wrapped.NewReader(ctx, req)
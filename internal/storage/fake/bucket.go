@@ -26,7 +26,6 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
 	"unicode/utf8"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
@@ -44,6 +43,21 @@ func NewFakeBucket(clock timeutil.Clock, name string, bucketType gcs.BucketType)
 	return b
 }
 
+// LinkFakeBuckets lets the objects of each of the supplied fake buckets be
+// copied into the others with CopyObjectRequest.SrcBucketName, like GCS copies
+// objects across buckets. It must be called before the buckets are used.
+func LinkFakeBuckets(buckets ...gcs.Bucket) {
+	for _, b := range buckets {
+		fb := b.(*bucket)
+		fb.peers = make(map[string]*bucket)
+		for _, other := range buckets {
+			if other != b {
+				fb.peers[other.Name()] = other.(*bucket)
+			}
+		}
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Helper types
 ////////////////////////////////////////////////////////////////////////
@@ -178,6 +192,10 @@ type bucket struct {
 	//
 	// INVARIANT: This is an upper bound for generation numbers in objects.
 	prevGeneration int64 // GUARDED_BY(mu)
+
+	// The buckets whose objects can be copied into this one, by name. Set by
+	// LinkFakeBuckets.
+	peers map[string]*bucket
 }
 
 func checkName(name string) (err error) {
@@ -255,8 +273,12 @@ func (b *bucket) mintObject(
 		Generation:      b.prevGeneration,
 		MetaGeneration:  1,
 		StorageClass:    "STANDARD",
+		CustomTime:      req.CustomTime,
 		Updated:         b.clock.Now(),
 	}
+	if req.StorageClass != "" {
+		o.metadata.StorageClass = req.StorageClass
+	}
 
 	// Set up data.
 	o.data = contents
@@ -705,19 +727,11 @@ func (b *bucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObje
 	return fakeObjectWriter.Object, nil
 }
 
-// LOCKS_EXCLUDED(b.mu)
-func (b *bucket) CopyObject(
-	ctx context.Context,
-	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	// Check that the destination name is legal.
-	err = checkName(req.DstName)
-	if err != nil {
-		return
-	}
-
+// copySource returns the source object of the supplied copy request in this
+// bucket.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) copySource(req *gcs.CopyObjectRequest) (src fakeObject, err error) {
 	// Does the object exist?
 	srcIndex := b.objects.find(req.SrcName)
 	if srcIndex == len(b.objects) {
@@ -754,9 +768,74 @@ func (b *bucket) CopyObject(
 		}
 	}
 
+	src = b.objects[srcIndex]
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	// The source object of a copy from another bucket is found first, so that
+	// the two buckets are never locked at the same time.
+	var src fakeObject
+	fromOtherBucket := req.SrcBucketName != "" && req.SrcBucketName != b.name
+	if fromOtherBucket {
+		other, ok := b.peers[req.SrcBucketName]
+		if !ok {
+			err = &gcs.NotFoundError{
+				Err: fmt.Errorf("bucket %q not found", req.SrcBucketName),
+			}
+
+			return
+		}
+
+		other.mu.Lock()
+		src, err = other.copySource(req)
+		other.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Check that the destination name is legal.
+	err = checkName(req.DstName)
+	if err != nil {
+		return
+	}
+
+	if !fromOtherBucket {
+		src, err = b.copySource(req)
+		if err != nil {
+			return
+		}
+	}
+
+	// Does the destination have the correct generation?
+	existingIndex := b.objects.find(req.DstName)
+	if req.DstGenerationPrecondition != nil {
+		var existingGen int64
+		if existingIndex < len(b.objects) {
+			existingGen = b.objects[existingIndex].metadata.Generation
+		}
+		if existingGen != *req.DstGenerationPrecondition {
+			err = &gcs.PreconditionError{
+				Err: fmt.Errorf(
+					"object %q has generation %d",
+					req.DstName,
+					existingGen),
+			}
+
+			return
+		}
+	}
+
 	// Copy it and assign a new generation number, to ensure that the generation
 	// number for the destination name is strictly increasing.
-	dst := b.objects[srcIndex]
+	dst := src
 	dst.metadata.Name = req.DstName
 	dst.metadata.MediaLink = "http://localhost/download/storage/fake/" + req.DstName
 
@@ -764,7 +843,6 @@ func (b *bucket) CopyObject(
 	dst.metadata.Generation = b.prevGeneration
 
 	// Insert into our array.
-	existingIndex := b.objects.find(req.DstName)
 	if existingIndex < len(b.objects) {
 		b.objects[existingIndex] = dst
 	} else {
@@ -772,6 +850,11 @@ func (b *bucket) CopyObject(
 		sort.Sort(b.objects)
 	}
 
+	// The object is copied in one go.
+	if req.Progress != nil {
+		req.Progress(dst.metadata.Size, dst.metadata.Size)
+	}
+
 	o = copyObject(&dst.metadata)
 	return
 }
@@ -1178,7 +1261,7 @@ func (b *bucket) RenameFolder(ctx context.Context, folderName string, destinatio
 	for i := range b.folders {
 		if strings.HasPrefix(b.folders[i].Name, folderName) {
 			b.folders[i].Name = strings.Replace(b.folders[i].Name, folderName, destinationFolderId, 1)
-			b.folders[i].UpdateTime = time.Now()
+			b.folders[i].UpdateTime = b.clock.Now()
 		}
 	}
 
@@ -1189,7 +1272,7 @@ func (b *bucket) RenameFolder(ctx context.Context, folderName string, destinatio
 	for i := range b.objects {
 		if strings.HasPrefix(b.objects[i].metadata.Name, folderName) {
 			b.objects[i].metadata.Name = strings.Replace(b.objects[i].metadata.Name, folderName, destinationFolderId, 1)
-			b.objects[i].metadata.Updated = time.Now()
+			b.objects[i].metadata.Updated = b.clock.Now()
 		}
 	}
 
@@ -1199,7 +1282,7 @@ func (b *bucket) RenameFolder(ctx context.Context, folderName string, destinatio
 	// Return the updated folder.
 	folder := &gcs.Folder{
 		Name:       destinationFolderId,
-		UpdateTime: time.Now(),
+		UpdateTime: b.clock.Now(),
 	}
 
 	return folder, nil
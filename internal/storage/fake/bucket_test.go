@@ -20,8 +20,11 @@ import (
 
 	gcstesting "github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake/testing"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/jacobsa/ogletest"
 	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 )
 
@@ -42,3 +45,40 @@ func init() {
 
 	gcstesting.RegisterBucketTests(makeDeps)
 }
+
+func TestCopyObjectFromLinkedBucket(t *testing.T) {
+	ctx := context.Background()
+	src := NewFakeBucket(timeutil.RealClock(), "src", gcs.NonHierarchical)
+	dst := NewFakeBucket(timeutil.RealClock(), "dst", gcs.NonHierarchical)
+	unlinked := NewFakeBucket(timeutil.RealClock(), "unlinked", gcs.NonHierarchical)
+	LinkFakeBuckets(src, dst)
+	o, err := storageutil.CreateObject(ctx, src, "foo", []byte("taco"))
+	require.NoError(t, err)
+	var copied, total uint64
+
+	dstObject, err := dst.CopyObject(ctx, &gcs.CopyObjectRequest{
+		SrcBucketName: "src",
+		SrcName:       "foo",
+		SrcGeneration: o.Generation,
+		DstName:       "bar",
+		Progress: func(copiedBytes, totalBytes uint64) {
+			copied, total = copiedBytes, totalBytes
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "bar", dstObject.Name)
+	contents, err := storageutil.ReadObject(ctx, dst, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "taco", string(contents))
+	assert.Equal(t, uint64(4), copied)
+	assert.Equal(t, uint64(4), total)
+	// The source object is left in its bucket.
+	_, _, err = src.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	assert.NoError(t, err)
+	var notFoundErr *gcs.NotFoundError
+	_, err = dst.CopyObject(ctx, &gcs.CopyObjectRequest{SrcBucketName: "src", SrcName: "baz", DstName: "baz"})
+	assert.ErrorAs(t, err, &notFoundErr)
+	_, err = unlinked.CopyObject(ctx, &gcs.CopyObjectRequest{SrcBucketName: "src", SrcName: "foo", DstName: "bar"})
+	assert.ErrorAs(t, err, &notFoundErr)
+}
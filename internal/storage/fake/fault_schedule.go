@@ -0,0 +1,297 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// A Fault is injected into a call of a method of a bucket created with
+// NewFaultyBucket.
+//
+// Along with a simulated clock shared by the fake bucket and the layers above
+// it, the faults make the races between the requests and the expiry of the
+// caches deterministic: e.g. a fault advancing the clock in Before simulates a
+// request taking longer than the TTL, and one modifying the bucket in Before
+// a modification racing with the request.
+type Fault struct {
+	// The name of the gcs.Bucket method, e.g. "StatObject".
+	Method string
+
+	// The number of the call of the method, starting at 1, or 0 for all the
+	// calls.
+	Call int
+
+	// Called before the call, if set.
+	Before func()
+
+	// Returned instead of calling through, if set.
+	Err error
+
+	// Called after the call, before it returns, if set.
+	After func()
+}
+
+// A FaultSchedule is the list of the faults injected by the buckets created
+// with NewFaultyBucket. It is safe for concurrent use.
+type FaultSchedule struct {
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	faults []Fault
+
+	// The number of calls so far by method.
+	//
+	// GUARDED_BY(mu)
+	calls map[string]int
+}
+
+func NewFaultSchedule(faults ...Fault) *FaultSchedule {
+	return &FaultSchedule{
+		faults: faults,
+		calls:  make(map[string]int),
+	}
+}
+
+// Add schedules another fault.
+func (s *FaultSchedule) Add(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, f)
+}
+
+// Calls returns the number of calls of the supplied method so far.
+func (s *FaultSchedule) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// next counts a call of the supplied method, and returns its faults in the
+// order they were scheduled.
+func (s *FaultSchedule) next(method string) (faults []Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+	call := s.calls[method]
+	for _, f := range s.faults {
+		if f.Method == method && (f.Call == 0 || f.Call == call) {
+			faults = append(faults, f)
+		}
+	}
+	return faults
+}
+
+// inject runs the supplied call with the faults scheduled for it.
+func (s *FaultSchedule) inject(method string, call func() error) error {
+	faults := s.next(method)
+	for _, f := range faults {
+		if f.Before != nil {
+			f.Before()
+		}
+	}
+	var err error
+	for _, f := range faults {
+		if f.Err != nil {
+			err = f.Err
+			break
+		}
+	}
+	if err == nil {
+		err = call()
+	}
+	for _, f := range faults {
+		if f.After != nil {
+			f.After()
+		}
+	}
+	return err
+}
+
+// NewFaultyBucket creates a bucket that injects the faults of the supplied
+// schedule into the calls to the wrapped bucket.
+func NewFaultyBucket(wrapped gcs.Bucket, schedule *FaultSchedule) gcs.Bucket {
+	return &faultyBucket{
+		wrapped:  wrapped,
+		schedule: schedule,
+	}
+}
+
+type faultyBucket struct {
+	wrapped  gcs.Bucket
+	schedule *FaultSchedule
+}
+
+func (b *faultyBucket) Name() string {
+	return b.wrapped.Name()
+}
+
+func (b *faultyBucket) BucketType() gcs.BucketType {
+	return b.wrapped.BucketType()
+}
+
+func (b *faultyBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	err = b.schedule.inject("NewReader", func() (err error) {
+		rc, err = b.wrapped.NewReader(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	err = b.schedule.inject("CreateObject", func() (err error) {
+		o, err = b.wrapped.CreateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (w gcs.Writer, err error) {
+	err = b.schedule.inject("CreateObjectChunkWriter", func() (err error) {
+		w, err = b.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) FinalizeUpload(
+	ctx context.Context,
+	w gcs.Writer) (o *gcs.MinObject, err error) {
+	err = b.schedule.inject("FinalizeUpload", func() (err error) {
+		o, err = b.wrapped.FinalizeUpload(ctx, w)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	err = b.schedule.inject("CopyObject", func() (err error) {
+		o, err = b.wrapped.CopyObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	err = b.schedule.inject("ComposeObjects", func() (err error) {
+		o, err = b.wrapped.ComposeObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, attrs *gcs.ExtendedObjectAttributes, err error) {
+	err = b.schedule.inject("StatObject", func() (err error) {
+		m, attrs, err = b.wrapped.StatObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	err = b.schedule.inject("ListObjects", func() (err error) {
+		listing, err = b.wrapped.ListObjects(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	err = b.schedule.inject("UpdateObject", func() (err error) {
+		o, err = b.wrapped.UpdateObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return b.schedule.inject("DeleteObject", func() error {
+		return b.wrapped.DeleteObject(ctx, req)
+	})
+}
+
+func (b *faultyBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (o *gcs.Object, err error) {
+	err = b.schedule.inject("MoveObject", func() (err error) {
+		o, err = b.wrapped.MoveObject(ctx, req)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return b.schedule.inject("DeleteFolder", func() error {
+		return b.wrapped.DeleteFolder(ctx, folderName)
+	})
+}
+
+func (b *faultyBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (f *gcs.Folder, err error) {
+	err = b.schedule.inject("GetFolder", func() (err error) {
+		f, err = b.wrapped.GetFolder(ctx, folderName)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (f *gcs.Folder, err error) {
+	err = b.schedule.inject("RenameFolder", func() (err error) {
+		f, err = b.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
+		return
+	})
+	return
+}
+
+func (b *faultyBucket) CreateFolder(
+	ctx context.Context,
+	folderName string) (f *gcs.Folder, err error) {
+	err = b.schedule.inject("CreateFolder", func() (err error) {
+		f, err = b.wrapped.CreateFolder(ctx, folderName)
+		return
+	})
+	return
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultyBucket_InjectsErrorIntoScheduledCall(t *testing.T) {
+	ctx := context.Background()
+	injected := errors.New("injected")
+	schedule := NewFaultSchedule(Fault{Method: "StatObject", Call: 2, Err: injected})
+	b := NewFaultyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), schedule)
+	_, err := storageutil.CreateObject(ctx, b, "foo", []byte("taco"))
+	require.NoError(t, err)
+
+	_, _, err1 := b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	_, _, err2 := b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	_, _, err3 := b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	assert.NoError(t, err1)
+	assert.ErrorIs(t, err2, injected)
+	assert.NoError(t, err3)
+	assert.Equal(t, 3, schedule.Calls("StatObject"))
+	assert.Equal(t, 1, schedule.Calls("CreateObject"))
+}
+
+func TestFaultyBucket_RunsHooksAroundEveryCall(t *testing.T) {
+	ctx := context.Background()
+	clock := &timeutil.SimulatedClock{}
+	clock.SetTime(time.Date(2015, 4, 5, 2, 15, 0, 0, time.Local))
+	schedule := NewFaultSchedule()
+	b := NewFaultyBucket(NewFakeBucket(clock, "some_bucket", gcs.NonHierarchical), schedule)
+	var events []string
+	schedule.Add(Fault{
+		Method: "CreateObject",
+		Before: func() { events = append(events, "before") },
+		After: func() {
+			events = append(events, "after")
+			clock.AdvanceTime(time.Minute)
+		},
+	})
+
+	o1, err := storageutil.CreateObject(ctx, b, "foo", []byte("taco"))
+	require.NoError(t, err)
+	o2, err := storageutil.CreateObject(ctx, b, "bar", []byte("burrito"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"before", "after", "before", "after"}, events)
+	assert.Equal(t, time.Minute, o2.Updated.Sub(o1.Updated))
+}
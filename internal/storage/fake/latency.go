@@ -0,0 +1,344 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// A LatencyDistribution is the latency of the calls of a method of a bucket
+// created with NewLatencyBucket, log-normally distributed like the ones of GCS,
+// along with the bandwidth of the contents they transfer.
+type LatencyDistribution struct {
+	// The median latency of the calls, before they return or their first byte is
+	// transferred.
+	Median time.Duration
+
+	// The 99th percentile of the latency, no less than Median.
+	P99 time.Duration
+
+	// The bytes per second read from NewReader, or written to CreateObject or the
+	// writers of CreateObjectChunkWriter, or 0 for no limit.
+	BytesPerSecond int64
+}
+
+// A LatencyProfile is the latency distribution by gcs.Bucket method, e.g.
+// "StatObject". The methods missing from it have no latency.
+type LatencyProfile map[string]LatencyDistribution
+
+// GCSLatencyProfile roughly simulates a bucket in the region of the VM.
+var GCSLatencyProfile = LatencyProfile{
+	"NewReader":               {Median: 25 * time.Millisecond, P99: 80 * time.Millisecond, BytesPerSecond: 200 << 20},
+	"CreateObject":            {Median: 40 * time.Millisecond, P99: 120 * time.Millisecond, BytesPerSecond: 100 << 20},
+	"CreateObjectChunkWriter": {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond, BytesPerSecond: 100 << 20},
+	"FinalizeUpload":          {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+	"CopyObject":              {Median: 40 * time.Millisecond, P99: 120 * time.Millisecond},
+	"ComposeObjects":          {Median: 40 * time.Millisecond, P99: 120 * time.Millisecond},
+	"StatObject":              {Median: 15 * time.Millisecond, P99: 50 * time.Millisecond},
+	"ListObjects":             {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+	"UpdateObject":            {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+	"DeleteObject":            {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+	"MoveObject":              {Median: 40 * time.Millisecond, P99: 120 * time.Millisecond},
+	"DeleteFolder":            {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+	"GetFolder":               {Median: 15 * time.Millisecond, P99: 50 * time.Millisecond},
+	"RenameFolder":            {Median: 40 * time.Millisecond, P99: 120 * time.Millisecond},
+	"CreateFolder":            {Median: 30 * time.Millisecond, P99: 100 * time.Millisecond},
+}
+
+// z99 is the 99th percentile of the standard normal distribution.
+const z99 = 2.326
+
+// NewLatencyBucket creates a bucket that delays the calls to the wrapped
+// bucket and paces the contents they transfer as in the supplied profile, so
+// that e.g. the read and write paths can be benchmarked without the variance of
+// the network. The latencies are drawn from a source with the supplied seed,
+// for the runs to be reproducible.
+func NewLatencyBucket(wrapped gcs.Bucket, profile LatencyProfile, seed int64) gcs.Bucket {
+	return &latencyBucket{
+		wrapped: wrapped,
+		profile: profile,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+type latencyBucket struct {
+	wrapped gcs.Bucket
+	profile LatencyProfile
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	rand *rand.Rand
+}
+
+// latency draws the latency of a call of the supplied method.
+func (b *latencyBucket) latency(method string) time.Duration {
+	d, ok := b.profile[method]
+	if !ok || d.Median <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	n := b.rand.NormFloat64()
+	b.mu.Unlock()
+
+	var sigma float64
+	if d.P99 > d.Median {
+		sigma = math.Log(float64(d.P99)/float64(d.Median)) / z99
+	}
+	return time.Duration(float64(d.Median) * math.Exp(sigma*n))
+}
+
+// wait waits for the latency of a call of the supplied method, or until the
+// context is done.
+func (b *latencyBucket) wait(ctx context.Context, method string) error {
+	latency := b.latency(method)
+	if latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newThrottle returns the throttle of the contents transferred by a call of
+// the supplied method.
+func (b *latencyBucket) newThrottle(method string) *throttle {
+	return &throttle{
+		bytesPerSecond: b.profile[method].BytesPerSecond,
+		start:          time.Now(),
+	}
+}
+
+// throttle paces the transfer of contents at a bandwidth.
+type throttle struct {
+	bytesPerSecond int64
+	start          time.Time
+	bytes          int64
+}
+
+// transferred counts n more bytes, and sleeps until they would have been
+// transferred at the bandwidth.
+func (t *throttle) transferred(n int) {
+	if t.bytesPerSecond <= 0 {
+		return
+	}
+	t.bytes += int64(n)
+	due := time.Duration(float64(t.bytes) / float64(t.bytesPerSecond) * float64(time.Second))
+	if d := due - time.Since(t.start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+type throttledReader struct {
+	io.Reader
+	throttle *throttle
+}
+
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.throttle.transferred(n)
+	return
+}
+
+type throttledReadCloser struct {
+	throttledReader
+	io.Closer
+}
+
+type throttledWriter struct {
+	gcs.Writer
+	throttle *throttle
+}
+
+func (w *throttledWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	w.throttle.transferred(n)
+	return
+}
+
+func (b *latencyBucket) Name() string {
+	return b.wrapped.Name()
+}
+
+func (b *latencyBucket) BucketType() gcs.BucketType {
+	return b.wrapped.BucketType()
+}
+
+func (b *latencyBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	if err := b.wait(ctx, "NewReader"); err != nil {
+		return nil, err
+	}
+	rc, err := b.wrapped.NewReader(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledReadCloser{
+		throttledReader: throttledReader{Reader: rc, throttle: b.newThrottle("NewReader")},
+		Closer:          rc,
+	}, nil
+}
+
+func (b *latencyBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if err := b.wait(ctx, "CreateObject"); err != nil {
+		return nil, err
+	}
+	if req.Contents == nil {
+		return b.wrapped.CreateObject(ctx, req)
+	}
+	throttled := *req
+	throttled.Contents = &throttledReader{Reader: req.Contents, throttle: b.newThrottle("CreateObject")}
+	return b.wrapped.CreateObject(ctx, &throttled)
+}
+
+func (b *latencyBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if err := b.wait(ctx, "CreateObjectChunkWriter"); err != nil {
+		return nil, err
+	}
+	w, err := b.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledWriter{Writer: w, throttle: b.newThrottle("CreateObjectChunkWriter")}, nil
+}
+
+func (b *latencyBucket) FinalizeUpload(
+	ctx context.Context,
+	w gcs.Writer) (*gcs.MinObject, error) {
+	if err := b.wait(ctx, "FinalizeUpload"); err != nil {
+		return nil, err
+	}
+	if tw, ok := w.(*throttledWriter); ok {
+		w = tw.Writer
+	}
+	return b.wrapped.FinalizeUpload(ctx, w)
+}
+
+func (b *latencyBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	if err := b.wait(ctx, "CopyObject"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.CopyObject(ctx, req)
+}
+
+func (b *latencyBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	if err := b.wait(ctx, "ComposeObjects"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.ComposeObjects(ctx, req)
+}
+
+func (b *latencyBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	if err := b.wait(ctx, "StatObject"); err != nil {
+		return nil, nil, err
+	}
+	return b.wrapped.StatObject(ctx, req)
+}
+
+func (b *latencyBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if err := b.wait(ctx, "ListObjects"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.ListObjects(ctx, req)
+}
+
+func (b *latencyBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	if err := b.wait(ctx, "UpdateObject"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.UpdateObject(ctx, req)
+}
+
+func (b *latencyBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	if err := b.wait(ctx, "DeleteObject"); err != nil {
+		return err
+	}
+	return b.wrapped.DeleteObject(ctx, req)
+}
+
+func (b *latencyBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	if err := b.wait(ctx, "MoveObject"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.MoveObject(ctx, req)
+}
+
+func (b *latencyBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	if err := b.wait(ctx, "DeleteFolder"); err != nil {
+		return err
+	}
+	return b.wrapped.DeleteFolder(ctx, folderName)
+}
+
+func (b *latencyBucket) GetFolder(
+	ctx context.Context,
+	folderName string) (*gcs.Folder, error) {
+	if err := b.wait(ctx, "GetFolder"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.GetFolder(ctx, folderName)
+}
+
+func (b *latencyBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	if err := b.wait(ctx, "RenameFolder"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
+}
+
+func (b *latencyBucket) CreateFolder(
+	ctx context.Context,
+	folderName string) (*gcs.Folder, error) {
+	if err := b.wait(ctx, "CreateFolder"); err != nil {
+		return nil, err
+	}
+	return b.wrapped.CreateFolder(ctx, folderName)
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyBucket_LatencyDistribution(t *testing.T) {
+	profile := LatencyProfile{"StatObject": {Median: 10 * time.Millisecond, P99: 50 * time.Millisecond}}
+	b := NewLatencyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), profile, 1).(*latencyBucket)
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = b.latency("StatObject")
+	}
+
+	slices.Sort(latencies)
+	assert.InDelta(t, 10*time.Millisecond, latencies[5000], float64(time.Millisecond))
+	assert.InDelta(t, 50*time.Millisecond, latencies[9900], float64(5*time.Millisecond))
+	assert.Zero(t, b.latency("ListObjects"))
+}
+
+func TestLatencyBucket_ReproducibleWithSeed(t *testing.T) {
+	profile := LatencyProfile{"StatObject": {Median: 10 * time.Millisecond, P99: 50 * time.Millisecond}}
+	b1 := NewLatencyBucket(nil, profile, 7).(*latencyBucket)
+	b2 := NewLatencyBucket(nil, profile, 7).(*latencyBucket)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, b1.latency("StatObject"), b2.latency("StatObject"))
+	}
+}
+
+func TestLatencyBucket_DelaysCalls(t *testing.T) {
+	ctx := context.Background()
+	profile := LatencyProfile{"StatObject": {Median: 20 * time.Millisecond, P99: 20 * time.Millisecond}}
+	b := NewLatencyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), profile, 1)
+	_, err := storageutil.CreateObject(ctx, b, "foo", []byte("taco"))
+	require.NoError(t, err)
+
+	start := time.Now()
+	m, _, err := b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "foo", m.Name)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestLatencyBucket_CancelledWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	profile := LatencyProfile{"StatObject": {Median: time.Hour, P99: time.Hour}}
+	b := NewLatencyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), profile, 1)
+
+	_, _, err := b.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLatencyBucket_PacesReads(t *testing.T) {
+	ctx := context.Background()
+	profile := LatencyProfile{"NewReader": {BytesPerSecond: 1 << 20}}
+	b := NewLatencyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), profile, 1)
+	contents := make([]byte, 100<<10)
+	_, err := storageutil.CreateObject(ctx, b, "foo", contents)
+	require.NoError(t, err)
+
+	start := time.Now()
+	rc, err := b.NewReader(ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t, err)
+	read, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.Equal(t, contents, read)
+	// 100 KiB at 1 MiB/s.
+	assert.GreaterOrEqual(t, time.Since(start), 97*time.Millisecond)
+}
+
+func TestLatencyBucket_PacesChunkWrites(t *testing.T) {
+	ctx := context.Background()
+	profile := LatencyProfile{"CreateObjectChunkWriter": {BytesPerSecond: 1 << 20}}
+	b := NewLatencyBucket(NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical), profile, 1)
+
+	start := time.Now()
+	w, err := b.CreateObjectChunkWriter(ctx, &gcs.CreateObjectRequest{Name: "foo"}, 1<<20, nil)
+	require.NoError(t, err)
+	_, err = w.Write(make([]byte, 100<<10))
+	require.NoError(t, err)
+	o, err := b.FinalizeUpload(ctx, w)
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100<<10), o.Size)
+	assert.GreaterOrEqual(t, time.Since(start), 97*time.Millisecond)
+}
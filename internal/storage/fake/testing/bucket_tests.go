@@ -1664,6 +1664,61 @@ func (t *copyTest) SrcMetaGenerationPrecondition_Satisfied() {
 	ExpectEq(nil, err)
 }
 
+func (t *copyTest) DstGenerationPrecondition_Unsatisfied() {
+	var err error
+
+	// Create a source object and a destination object.
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	AssertEq(nil, err)
+
+	dst, err := storageutil.CreateObject(t.ctx, t.bucket, "bar", []byte("burrito"))
+	AssertEq(nil, err)
+
+	// Attempt to copy, with a precondition that the destination doesn't exist.
+	var precond int64
+	req := &gcs.CopyObjectRequest{
+		SrcName:                   "foo",
+		DstName:                   "bar",
+		DstGenerationPrecondition: &precond,
+	}
+
+	_, err = t.bucket.CopyObject(t.ctx, req)
+	AssertThat(err, HasSameTypeAs(&gcs.PreconditionError{}))
+
+	// The destination should not have been overwritten.
+	m, _, err := t.bucket.StatObject(
+		t.ctx,
+		&gcs.StatObjectRequest{Name: "bar"})
+
+	AssertEq(nil, err)
+	ExpectEq(dst.Generation, m.Generation)
+}
+
+func (t *copyTest) DstGenerationPrecondition_Satisfied() {
+	var err error
+
+	// Create a source object.
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	AssertEq(nil, err)
+
+	// Copy, with a precondition that the destination doesn't exist.
+	var precond int64
+	req := &gcs.CopyObjectRequest{
+		SrcName:                   "foo",
+		DstName:                   "bar",
+		DstGenerationPrecondition: &precond,
+	}
+
+	_, err = t.bucket.CopyObject(t.ctx, req)
+	AssertEq(nil, err)
+
+	// The object should have been created.
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "bar")
+
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Compose
 ////////////////////////////////////////////////////////////////////////
@@ -177,3 +177,12 @@ type Bucket interface {
 
 	CreateFolder(ctx context.Context, folderName string) (*Folder, error)
 }
+
+// ReadRegionReporter is implemented by the buckets which know the region
+// serving their reads, e.g. when the reads of a dual-region bucket are sent to
+// the regional endpoint of one of its regions.
+type ReadRegionReporter interface {
+	// ReadRegion returns the region serving the reads of the bucket, or an
+	// empty string if it's picked by GCS.
+	ReadRegion() string
+}
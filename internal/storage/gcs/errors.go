@@ -26,6 +26,36 @@ func (nfe *NotFoundError) Error() string {
 	return fmt.Sprintf("gcs.NotFoundError: %v", nfe.Err)
 }
 
+// A *PermissionDeniedError value is an error that indicates the caller isn't
+// allowed to access an object or folder, e.g. because IAM denies access to the
+// managed folder containing it.
+type PermissionDeniedError struct {
+	Err error
+}
+
+func (pde *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("gcs.PermissionDeniedError: %v", pde.Err)
+}
+
+func (pde *PermissionDeniedError) Unwrap() error {
+	return pde.Err
+}
+
+// A *UnreachableError value is an error that indicates GCS couldn't be
+// reached, e.g. during a network outage, or that a request wasn't sent because
+// it recently couldn't be.
+type UnreachableError struct {
+	Err error
+}
+
+func (ue *UnreachableError) Error() string {
+	return fmt.Sprintf("gcs.UnreachableError: %v", ue.Err)
+}
+
+func (ue *UnreachableError) Unwrap() error {
+	return ue.Err
+}
+
 // A *PreconditionError value is an error that indicates a precondition failed.
 type PreconditionError struct {
 	Err error
@@ -35,3 +65,62 @@ type PreconditionError struct {
 func (pe *PreconditionError) Error() string {
 	return fmt.Sprintf("gcs.PreconditionError: %v", pe.Err)
 }
+
+// FolderRenameState describes the state a failed folder rename left the source
+// and destination folders in.
+type FolderRenameState int
+
+const (
+	// The state of the folders could not be determined.
+	FolderRenameStateUnknown FolderRenameState = iota
+
+	// The source folder is intact and the destination folder doesn't exist, so
+	// the rename can safely be retried.
+	FolderRenameStateNotStarted
+
+	// Both the source and the destination folders exist, so some of the contents
+	// may have been moved.
+	FolderRenameStatePartial
+
+	// The rename operation was still running when waiting for it was given up,
+	// and may complete in the background.
+	FolderRenameStateInProgress
+)
+
+// A *FolderRenameError value is an error that indicates a folder rename in a
+// hierarchical bucket failed, along with what is known about the state of the
+// folders.
+type FolderRenameError struct {
+	SrcName string
+	DstName string
+
+	// The name of the long-running rename operation, if it was started.
+	Operation string
+
+	State FolderRenameState
+	Err   error
+}
+
+func (fre *FolderRenameError) Error() string {
+	msg := fmt.Sprintf("gcs.FolderRenameError: renaming folder %q to %q", fre.SrcName, fre.DstName)
+	if fre.Operation != "" {
+		msg += fmt.Sprintf(" (operation %q)", fre.Operation)
+	}
+	msg += fmt.Sprintf(": %v; ", fre.Err)
+
+	switch fre.State {
+	case FolderRenameStateNotStarted:
+		msg += "the source folder is intact and the rename can be retried"
+	case FolderRenameStatePartial:
+		msg += fmt.Sprintf("both folders exist and the contents may be split between them; retry the rename to complete it, or rename %q back to %q to roll it back", fre.DstName, fre.SrcName)
+	case FolderRenameStateInProgress:
+		msg += "the rename may still complete in the background; check both folders once it is done"
+	default:
+		msg += "the state of the folders is unknown; check both folders before retrying"
+	}
+	return msg
+}
+
+func (fre *FolderRenameError) Unwrap() error {
+	return fre.Err
+}
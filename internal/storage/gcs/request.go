@@ -104,6 +104,15 @@ type CopyObjectRequest struct {
 	// generation is equal to the given value. Zero means the object does not
 	// exist.
 	DstGenerationPrecondition *int64
+
+	// The name of the bucket holding the source object, if not the bucket the
+	// object is copied to. GCS then rewrites the contents of the object into
+	// the destination bucket, possibly in several requests.
+	SrcBucketName string
+
+	// If non-nil, called with the number of bytes copied so far and the size
+	// of the object after each request of a rewrite.
+	Progress func(copiedBytes, totalBytes uint64)
 }
 
 // MaxSourcesPerComposeRequest is the maximum number of sources that a
@@ -15,6 +15,7 @@
 package gcs
 
 import (
+	"context"
 	"time"
 )
 
@@ -62,3 +63,18 @@ func NewCreateObjectRequest(srcObject *Object, objectName string, mtime *time.Ti
 
 	return req
 }
+
+type uploadProgressKey struct{}
+
+// WithUploadProgress returns a context with which CreateObject reports the
+// number of bytes of the contents committed to GCS so far to progress, where
+// the bucket supports it.
+func WithUploadProgress(ctx context.Context, progress func(bytesUploadedSoFar int64)) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, progress)
+}
+
+// UploadProgress returns the function set with WithUploadProgress, or nil.
+func UploadProgress(ctx context.Context) func(bytesUploadedSoFar int64) {
+	progress, _ := ctx.Value(uploadProgressKey{}).(func(bytesUploadedSoFar int64))
+	return progress
+}
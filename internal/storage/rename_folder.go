@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/storage/control/apiv2/controlpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// How often a folder rename operation is polled for completion.
+var renameFolderPollInterval = time.Second
+
+// How long to spend finding out the state of the folders after a failed
+// rename.
+const renameFolderStateTimeout = 30 * time.Second
+
+// renameFolderOperation is the subset of control.RenameFolderOperation used to
+// wait for a folder rename.
+type renameFolderOperation interface {
+	Poll(ctx context.Context, opts ...gax.CallOption) (*controlpb.Folder, error)
+	Metadata() (*controlpb.RenameFolderMetadata, error)
+	Done() bool
+	Name() string
+}
+
+// awaitRenameFolder polls op until the rename completes, logging its progress.
+// If the rename fails, or ctx is done first, it returns a
+// *gcs.FolderRenameError describing the state of the folders, unless the
+// rename turns out to have completed after all.
+func (bh *bucketHandle) awaitRenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string,
+	op renameFolderOperation) (*gcs.Folder, error) {
+	var progress int32 = -1
+	for {
+		controlFolder, err := op.Poll(ctx)
+		if err == nil && op.Done() {
+			return gcs.GCSFolder(bh.bucketName, controlFolder), nil
+		}
+
+		if err == nil {
+			if m, mErr := op.Metadata(); mErr == nil && m.GetCommonMetadata().GetProgressPercent() != progress {
+				progress = m.GetCommonMetadata().GetProgressPercent()
+				logger.Debugf("RenameFolder(%q, %q): operation %q is %d%% done", folderName, destinationFolderId, op.Name(), progress)
+			}
+
+			select {
+			case <-time.After(renameFolderPollInterval):
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+
+		return bh.renameFolderFailed(ctx, folderName, destinationFolderId, op, err)
+	}
+}
+
+func (bh *bucketHandle) renameFolderFailed(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string,
+	op renameFolderOperation,
+	err error) (*gcs.Folder, error) {
+	renameErr := &gcs.FolderRenameError{
+		SrcName:   folderName,
+		DstName:   destinationFolderId,
+		Operation: op.Name(),
+		Err:       err,
+	}
+	if !op.Done() {
+		renameErr.State = gcs.FolderRenameStateInProgress
+		return nil, renameErr
+	}
+
+	// Find out where the contents ended up, even if ctx is done.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), renameFolderStateTimeout)
+	defer cancel()
+	_, srcErr := bh.GetFolder(ctx, folderName)
+	dst, dstErr := bh.GetFolder(ctx, destinationFolderId)
+	var notFoundErr *gcs.NotFoundError
+	switch {
+	case srcErr == nil && errors.As(dstErr, &notFoundErr):
+		renameErr.State = gcs.FolderRenameStateNotStarted
+	case srcErr == nil && dstErr == nil:
+		renameErr.State = gcs.FolderRenameStatePartial
+	case errors.As(srcErr, &notFoundErr) && dstErr == nil:
+		// The rename completed despite the error.
+		logger.Warnf("RenameFolder(%q, %q): operation %q reported %v, but the folder has been renamed", folderName, destinationFolderId, op.Name(), err)
+		return dst, nil
+	}
+
+	return nil, renameErr
+}
@@ -19,12 +19,14 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	control "cloud.google.com/go/storage/control/apiv2"
 	"cloud.google.com/go/storage/experimental"
 	"github.com/googleapis/gax-go/v2"
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/locality"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"golang.org/x/net/context"
@@ -42,6 +44,10 @@ const (
 	// Ref: https://github.com/googleapis/google-cloud-go/blob/main/storage/option.go#L30
 	dynamicReadReqIncreaseRateEnv   = "DYNAMIC_READ_REQ_INCREASE_RATE"
 	dynamicReadReqInitialTimeoutEnv = "DYNAMIC_READ_REQ_INITIAL_TIMEOUT"
+
+	// regionalEndpointFormat is the JSON API endpoint of a region, formatted
+	// with the region. The XML API reads use the same host.
+	regionalEndpointFormat = "https://storage.%s.rep.googleapis.com/storage/v1/"
 )
 
 type StorageHandle interface {
@@ -51,12 +57,47 @@ type StorageHandle interface {
 	//
 	// A user-project is required for all operations on Requester Pays buckets.
 	BucketHandle(ctx context.Context, bucketName string, billingProject string) (bh *bucketHandle)
+
+	// BucketLocation fetches the location (e.g. "US-CENTRAL1", "US") and the
+	// location type (e.g. "region", "dual-region", "multi-region") of the
+	// given bucket.
+	BucketLocation(ctx context.Context, bucketName string, billingProject string) (location string, locationType string, err error)
+
+	// BucketAttrs fetches the attributes of the given bucket, e.g. its location
+	// and its default storage class.
+	BucketAttrs(ctx context.Context, bucketName string, billingProject string) (attrs *storage.BucketAttrs, err error)
+
+	// CloseIdleConnections closes the connections to GCS not in use by a
+	// request, with StorageClientConfig.IdleConnections. The next requests open
+	// new ones.
+	CloseIdleConnections()
+
+	// BucketLifecycle fetches the lifecycle rules of the given bucket.
+	BucketLifecycle(ctx context.Context, bucketName string, billingProject string) (rules []storage.LifecycleRule, err error)
+
+	// BucketHierarchicalNamespace fetches whether the given bucket has
+	// hierarchical namespace enabled.
+	BucketHierarchicalNamespace(ctx context.Context, bucketName string, billingProject string) (enabled bool, err error)
+
+	// BucketPermissions returns the ones of the supplied IAM permissions which
+	// the caller has on the given bucket, with testIamPermissions, which
+	// requires no permission itself.
+	BucketPermissions(ctx context.Context, bucketName string, billingProject string, permissions []string) (granted []string, err error)
 }
 
 type storageClient struct {
 	client               *storage.Client
 	storageControlClient *control.StorageControlClient
 	directPathDetector   *gRPCDirectPathDetector
+
+	// clientConfig is kept to create the clients of the regional endpoints
+	// serving reads with PreferNearestRegionReads.
+	clientConfig storageutil.StorageClientConfig
+
+	mu sync.Mutex
+	// regionalClients are the clients of the regional endpoints, by region.
+	// GUARDED_BY(mu)
+	regionalClients map[string]*storage.Client
 }
 
 type gRPCDirectPathDetector struct {
@@ -97,6 +138,26 @@ func createClientOptionForGRPCClient(clientConfig *storageutil.StorageClientConf
 		}
 	}
 
+	proxyDialer, err := storageutil.GRPCProxyDialer(clientConfig)
+	if err != nil {
+		err = fmt.Errorf("while configuring the proxy: %w", err)
+		return
+	}
+	if proxyDialer != nil {
+		clientOpts = append(clientOpts, option.WithGRPCDialOption(grpc.WithContextDialer(proxyDialer)))
+	}
+	for _, opt := range storageutil.GRPCConnectionRetryDialOptions(clientConfig) {
+		clientOpts = append(clientOpts, option.WithGRPCDialOption(opt))
+	}
+	if clientConfig.LogSlowRequests {
+		for _, opt := range storageutil.GRPCSlowRequestDialOptions() {
+			clientOpts = append(clientOpts, option.WithGRPCDialOption(opt))
+		}
+	}
+	for _, opt := range storageutil.GRPCIdleDialOptions(clientConfig) {
+		clientOpts = append(clientOpts, option.WithGRPCDialOption(opt))
+	}
+
 	clientOpts = append(clientOpts, option.WithGRPCConnectionPool(clientConfig.GrpcConnPoolSize))
 	clientOpts = append(clientOpts, option.WithUserAgent(clientConfig.UserAgent))
 	// Turning off the go-sdk metrics exporter to prevent any problems.
@@ -138,7 +199,7 @@ func createHTTPClientHandle(ctx context.Context, clientConfig *storageutil.Stora
 	var clientOpts []option.ClientOption
 
 	// Add WithHttpClient option.
-	if clientConfig.ClientProtocol == cfg.HTTP1 || clientConfig.ClientProtocol == cfg.HTTP2 {
+	if clientConfig.ClientProtocol == cfg.HTTP1 || clientConfig.ClientProtocol == cfg.HTTP2 || clientConfig.ClientProtocol == cfg.HTTP3 {
 		var httpClient *http.Client
 		httpClient, err = storageutil.CreateHttpClient(clientConfig)
 		if err != nil {
@@ -148,7 +209,7 @@ func createHTTPClientHandle(ctx context.Context, clientConfig *storageutil.Stora
 
 		clientOpts = append(clientOpts, option.WithHTTPClient(httpClient))
 	} else {
-		return nil, fmt.Errorf("client-protocol requested is not HTTP1 or HTTP2: %s", clientConfig.ClientProtocol)
+		return nil, fmt.Errorf("client-protocol requested is not HTTP1, HTTP2 or HTTP3: %s", clientConfig.ClientProtocol)
 	}
 
 	if clientConfig.AnonymousAccess {
@@ -208,7 +269,7 @@ func NewStorageHandle(ctx context.Context, clientConfig storageutil.StorageClien
 			clientOpts, err = createClientOptionForGRPCClient(&clientConfig)
 			directPathDetector = &gRPCDirectPathDetector{clientOptions: clientOpts}
 		}
-	} else if clientConfig.ClientProtocol == cfg.HTTP1 || clientConfig.ClientProtocol == cfg.HTTP2 {
+	} else if clientConfig.ClientProtocol == cfg.HTTP1 || clientConfig.ClientProtocol == cfg.HTTP2 || clientConfig.ClientProtocol == cfg.HTTP3 {
 		sc, err = createHTTPClientHandle(ctx, &clientConfig)
 	} else {
 		err = fmt.Errorf("invalid client-protocol requested: %s", clientConfig.ClientProtocol)
@@ -232,6 +293,13 @@ func NewStorageHandle(ctx context.Context, clientConfig storageutil.StorageClien
 		}
 	}
 
+	setRetryConfig(sc, &clientConfig)
+
+	sh = &storageClient{client: sc, storageControlClient: controlClient, directPathDetector: directPathDetector, clientConfig: clientConfig}
+	return
+}
+
+func setRetryConfig(sc *storage.Client, clientConfig *storageutil.StorageClientConfig) {
 	// ShouldRetry function checks if an operation should be retried based on the
 	// response of operation (error.Code).
 	// RetryAlways causes all operations to be checked for retries using
@@ -251,9 +319,77 @@ func NewStorageHandle(ctx context.Context, clientConfig storageutil.StorageClien
 	if clientConfig.MaxRetryAttempts != 0 {
 		sc.SetRetry(storage.WithMaxAttempts(clientConfig.MaxRetryAttempts))
 	}
+}
 
-	sh = &storageClient{client: sc, storageControlClient: controlClient, directPathDetector: directPathDetector}
-	return
+// regionalClient returns the client of the regional endpoint of the given
+// region, creating it on first use.
+func (sh *storageClient) regionalClient(ctx context.Context, region string) (*storage.Client, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sc, ok := sh.regionalClients[region]; ok {
+		return sc, nil
+	}
+	clientConfig := sh.clientConfig
+	clientConfig.CustomEndpoint = fmt.Sprintf(regionalEndpointFormat, region)
+	sc, err := createHTTPClientHandle(ctx, &clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("while creating the client of the %s regional endpoint: %w", region, err)
+	}
+	setRetryConfig(sc, &clientConfig)
+
+	if sh.regionalClients == nil {
+		sh.regionalClients = make(map[string]*storage.Client)
+	}
+	sh.regionalClients[region] = sc
+	return sc, nil
+}
+
+// nearestRegionReadBucket returns the bucket handle bound to the regional
+// endpoint of the region of the given dual-region bucket containing the VM,
+// along with the region. A nil handle is returned if the reads should go
+// through the global endpoint, as it isn't possible or the region is unknown.
+func (sh *storageClient) nearestRegionReadBucket(ctx context.Context, bucketName string, billingProject string) (*storage.BucketHandle, string) {
+	if sh.clientConfig.ClientProtocol == cfg.GRPC || sh.clientConfig.CustomEndpoint != "" {
+		logger.Warnf("Ignoring prefer-nearest-region-reads for bucket %q: the regional endpoints aren't used with the grpc client protocol or a custom endpoint.", bucketName)
+		return nil, ""
+	}
+
+	zone, err := locality.VMZone(ctx)
+	if err != nil || zone == "" {
+		logger.Debugf("Reads of bucket %q use the global endpoint: the zone of the VM is unknown (%v).", bucketName, err)
+		return nil, ""
+	}
+	storageBucketHandle := sh.client.Bucket(bucketName)
+	if billingProject != "" {
+		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
+	}
+	attrs, err := storageBucketHandle.Attrs(ctx)
+	if err != nil {
+		logger.Warnf("Reads of bucket %q use the global endpoint: error in fetching the bucket attributes: %v", bucketName, err)
+		return nil, ""
+	}
+	var dataLocations []string
+	if attrs.CustomPlacementConfig != nil {
+		dataLocations = attrs.CustomPlacementConfig.DataLocations
+	}
+	region := locality.NearestReadRegion(attrs.Location, attrs.LocationType, dataLocations, zone)
+	if region == "" {
+		logger.Debugf("Reads of bucket %q use the global endpoint: the bucket in %s (%s) has no region containing zone %s.", bucketName, attrs.Location, attrs.LocationType, zone)
+		return nil, ""
+	}
+
+	sc, err := sh.regionalClient(ctx, region)
+	if err != nil {
+		logger.Warnf("Reads of bucket %q use the global endpoint: %v", bucketName, err)
+		return nil, ""
+	}
+	readBucketHandle := sc.Bucket(bucketName)
+	if billingProject != "" {
+		readBucketHandle = readBucketHandle.UserProject(billingProject)
+	}
+	logger.Infof("Reads of dual-region bucket %q are served from the %s regional endpoint.", bucketName, region)
+	return readBucketHandle, region
 }
 
 func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, billingProject string) (bh *bucketHandle) {
@@ -264,9 +400,15 @@ func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, bi
 	}
 
 	bh = &bucketHandle{
-		bucket:        storageBucketHandle,
-		bucketName:    bucketName,
-		controlClient: sh.storageControlClient,
+		bucket:                    storageBucketHandle,
+		bucketName:                bucketName,
+		controlClient:             sh.storageControlClient,
+		singleShotUploadThreshold: sh.clientConfig.SingleShotUploadThreshold,
+		client:                    sh.client,
+		billingProject:            billingProject,
+	}
+	if sh.clientConfig.PreferNearestRegionReads {
+		bh.readBucket, bh.readRegion = sh.nearestRegionReadBucket(ctx, bucketName, billingProject)
 	}
 	if sh.directPathDetector != nil {
 		if err := sh.directPathDetector.isDirectPathPossible(ctx, bucketName); err != nil {
@@ -275,3 +417,69 @@ func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, bi
 	}
 	return
 }
+
+func (sh *storageClient) BucketLocation(ctx context.Context, bucketName string, billingProject string) (location string, locationType string, err error) {
+	attrs, err := sh.BucketAttrs(ctx, bucketName, billingProject)
+	if err != nil {
+		return
+	}
+	return attrs.Location, attrs.LocationType, nil
+}
+
+func (sh *storageClient) CloseIdleConnections() {
+	sh.clientConfig.IdleConnections.Close()
+}
+
+func (sh *storageClient) BucketAttrs(ctx context.Context, bucketName string, billingProject string) (attrs *storage.BucketAttrs, err error) {
+	storageBucketHandle := sh.client.Bucket(bucketName)
+	if billingProject != "" {
+		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
+	}
+
+	attrs, err = storageBucketHandle.Attrs(ctx)
+	if err != nil {
+		err = fmt.Errorf("error in fetching attributes of bucket %q: %w", bucketName, err)
+	}
+	return
+}
+
+func (sh *storageClient) BucketLifecycle(ctx context.Context, bucketName string, billingProject string) (rules []storage.LifecycleRule, err error) {
+	storageBucketHandle := sh.client.Bucket(bucketName)
+	if billingProject != "" {
+		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
+	}
+
+	attrs, err := storageBucketHandle.Attrs(ctx)
+	if err != nil {
+		err = fmt.Errorf("error in fetching attributes of bucket %q: %w", bucketName, err)
+		return
+	}
+	return attrs.Lifecycle.Rules, nil
+}
+
+func (sh *storageClient) BucketHierarchicalNamespace(ctx context.Context, bucketName string, billingProject string) (enabled bool, err error) {
+	storageBucketHandle := sh.client.Bucket(bucketName)
+	if billingProject != "" {
+		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
+	}
+
+	attrs, err := storageBucketHandle.Attrs(ctx)
+	if err != nil {
+		err = fmt.Errorf("error in fetching attributes of bucket %q: %w", bucketName, err)
+		return
+	}
+	return attrs.HierarchicalNamespace != nil && attrs.HierarchicalNamespace.Enabled, nil
+}
+
+func (sh *storageClient) BucketPermissions(ctx context.Context, bucketName string, billingProject string, permissions []string) (granted []string, err error) {
+	storageBucketHandle := sh.client.Bucket(bucketName)
+	if billingProject != "" {
+		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
+	}
+
+	granted, err = storageBucketHandle.IAM().TestPermissions(ctx, permissions)
+	if err != nil {
+		err = fmt.Errorf("error in testing the permissions on bucket %q: %w", bucketName, err)
+	}
+	return
+}
@@ -224,6 +224,11 @@ func (testSuite *StorageHandleTest) TestNewStorageHandleDirectPathDetector() {
 			clientProtocol:           cfg.HTTP2,
 			expectDirectPathDetector: false,
 		},
+		{
+			name:                     "http3WithNilDirectPathDetector",
+			clientProtocol:           cfg.HTTP3,
+			expectDirectPathDetector: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -296,7 +301,7 @@ func (testSuite *StorageHandleTest) TestCreateHTTPClientHandle_WithGRPCClientPro
 
 	assert.NotNil(testSuite.T(), err)
 	assert.Nil(testSuite.T(), storageClient)
-	assert.Contains(testSuite.T(), err.Error(), fmt.Sprintf("client-protocol requested is not HTTP1 or HTTP2: %s", cfg.GRPC))
+	assert.Contains(testSuite.T(), err.Error(), fmt.Sprintf("client-protocol requested is not HTTP1, HTTP2 or HTTP3: %s", cfg.GRPC))
 }
 
 func (testSuite *StorageHandleTest) TestCreateHTTPClientHandle_WithReadStallRetry() {
@@ -547,3 +552,58 @@ func (testSuite *StorageHandleTest) TestNewStorageHandleWithMaxRetryAttemptsNotZ
 		assert.NotNil(testSuite.T(), handleCreated)
 	}
 }
+
+func (testSuite *StorageHandleTest) TestBucketLocationWhenBucketExists() {
+	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
+
+	_, locationType, err := storageHandle.BucketLocation(testSuite.ctx, TestBucketName, "")
+
+	assert.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), "region", locationType)
+}
+
+func (testSuite *StorageHandleTest) TestBucketLocationWhenBucketDoesNotExist() {
+	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
+
+	_, _, err := storageHandle.BucketLocation(testSuite.ctx, invalidBucketName, "")
+
+	assert.Error(testSuite.T(), err)
+}
+
+func (testSuite *StorageHandleTest) TestBucketHandleWithPreferNearestRegionReadsWhenNotOnGCE() {
+	fakeStorageHandle := testSuite.fakeStorage.CreateStorageHandle().(*storageClient)
+	fakeStorageHandle.clientConfig = storageutil.GetDefaultStorageClientConfig()
+	fakeStorageHandle.clientConfig.PreferNearestRegionReads = true
+
+	bucketHandle := fakeStorageHandle.BucketHandle(testSuite.ctx, TestBucketName, "")
+
+	assert.Nil(testSuite.T(), bucketHandle.readBucket)
+	assert.Equal(testSuite.T(), "", bucketHandle.ReadRegion())
+}
+
+func (testSuite *StorageHandleTest) TestBucketHandleWithPreferNearestRegionReadsAndGRPCClientProtocol() {
+	fakeStorageHandle := testSuite.fakeStorage.CreateStorageHandle().(*storageClient)
+	fakeStorageHandle.clientConfig = storageutil.GetDefaultStorageClientConfig()
+	fakeStorageHandle.clientConfig.ClientProtocol = cfg.GRPC
+	fakeStorageHandle.clientConfig.PreferNearestRegionReads = true
+
+	bucketHandle := fakeStorageHandle.BucketHandle(testSuite.ctx, TestBucketName, "")
+
+	assert.Nil(testSuite.T(), bucketHandle.readBucket)
+	assert.Equal(testSuite.T(), "", bucketHandle.ReadRegion())
+}
+
+func (testSuite *StorageHandleTest) TestRegionalClientIsReused() {
+	sc := storageutil.GetDefaultStorageClientConfig()
+	sh := &storageClient{clientConfig: sc}
+
+	client1, err := sh.regionalClient(testSuite.ctx, "us-east1")
+	require.NoError(testSuite.T(), err)
+	client2, err := sh.regionalClient(testSuite.ctx, "us-east1")
+	require.NoError(testSuite.T(), err)
+	client3, err := sh.regionalClient(testSuite.ctx, "us-central1")
+	require.NoError(testSuite.T(), err)
+
+	assert.Same(testSuite.T(), client1, client2)
+	assert.NotSame(testSuite.T(), client1, client3)
+}
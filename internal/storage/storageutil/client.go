@@ -22,7 +22,10 @@ import (
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/auth"
+	"github.com/quic-go/quic-go/http3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 )
@@ -42,6 +45,36 @@ type StorageClientConfig struct {
 	MaxRetrySleep     time.Duration
 	RetryMultiplier   float64
 
+	// MaxConnectionRetryAttempts is the number of times establishing a
+	// connection is retried for a request, separately from MaxRetryAttempts.
+	MaxConnectionRetryAttempts int
+
+	// CredentialConfigFile is a credential configuration file for workload
+	// identity federation.
+	CredentialConfigFile string
+	// ExternalCredentialCommand is run with the shell to get access tokens.
+	ExternalCredentialCommand string
+	// ImpersonateServiceAccount is the service account, or comma-separated
+	// delegation chain, impersonated with the credentials.
+	ImpersonateServiceAccount string
+
+	// ProxyURL is the proxy of the GCS connections, overriding the one of the
+	// environment.
+	ProxyURL string
+	// ProxyHeaders are sent to HTTP proxies, as "<name>: <value>".
+	ProxyHeaders []string
+
+	// LogSlowRequests logs the requests slower than logger.SlowOpThreshold.
+	LogSlowRequests bool
+
+	// EnableTracing traces the HTTP requests, in the spans of the ops sending
+	// them.
+	EnableTracing bool
+
+	// IdleConnections closes the idle connections of the clients on demand.
+	// Optional.
+	IdleConnections *IdleConnections
+
 	/** HTTP client parameters. */
 	MaxConnsPerHost            int
 	MaxIdleConnsPerHost        int
@@ -50,6 +83,18 @@ type StorageClientConfig struct {
 	ExperimentalEnableJsonRead bool
 	AnonymousAccess            bool
 
+	// PreferNearestRegionReads sends the reads of dual-region buckets to the
+	// regional endpoint of the region containing the VM, if any.
+	PreferNearestRegionReads bool
+
+	// ReadOnly restricts the scope of the token to reading from GCS, except
+	// for tokens fetched from TokenUrl, whose scope is decided by the server.
+	ReadOnly bool
+
+	// SingleShotUploadThreshold is the size, in bytes, up to which objects are
+	// created with a single request rather than a resumable upload session.
+	SingleShotUploadThreshold int64
+
 	/** Grpc client parameters. */
 	GrpcConnPoolSize int
 
@@ -57,14 +102,24 @@ type StorageClientConfig struct {
 	EnableHNS bool
 
 	ReadStallRetryConfig cfg.ReadStallGcsRetriesConfig
+
+	// MetricHandle records the token refreshes and the retries of the
+	// resumable uploads. Optional.
+	MetricHandle common.MetricHandle
 }
 
 func CreateHttpClient(storageClientConfig *StorageClientConfig) (httpClient *http.Client, err error) {
-	var transport *http.Transport
+	pc, err := newProxyConfig(storageClientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper
 	// Using http1 makes the client more performant.
 	if storageClientConfig.ClientProtocol == cfg.HTTP1 {
 		transport = &http.Transport{
-			Proxy:               http.ProxyFromEnvironment,
+			Proxy:               pc.proxy,
+			ProxyConnectHeader:  pc.connectHeader,
 			MaxConnsPerHost:     storageClientConfig.MaxConnsPerHost,
 			MaxIdleConnsPerHost: storageClientConfig.MaxIdleConnsPerHost,
 			// This disables HTTP/2 in transport.
@@ -72,13 +127,20 @@ func CreateHttpClient(storageClientConfig *StorageClientConfig) (httpClient *htt
 				map[string]func(string, *tls.Conn) http.RoundTripper,
 			),
 		}
+	} else if storageClientConfig.ClientProtocol == cfg.HTTP3 {
+		// HTTP/3 runs over QUIC, i.e. UDP, so that a lost packet only blocks its
+		// stream rather than all the streams of the connection. Proxies and
+		// MaxConnsPerHost don't apply as all the requests are multiplexed over a
+		// single QUIC connection.
+		transport = &http3.Transport{}
 	} else {
 		// For http2, change in MaxConnsPerHost doesn't affect the performance.
 		transport = &http.Transport{
-			Proxy:             http.ProxyFromEnvironment,
-			DisableKeepAlives: true,
-			MaxConnsPerHost:   storageClientConfig.MaxConnsPerHost,
-			ForceAttemptHTTP2: true,
+			Proxy:              pc.proxy,
+			ProxyConnectHeader: pc.connectHeader,
+			DisableKeepAlives:  true,
+			MaxConnsPerHost:    storageClientConfig.MaxConnsPerHost,
+			ForceAttemptHTTP2:  true,
 		}
 	}
 
@@ -93,20 +155,35 @@ func CreateHttpClient(storageClientConfig *StorageClientConfig) (httpClient *htt
 		httpClient = &http.Client{
 			Timeout: storageClientConfig.HttpClientTimeout,
 		}
+		// The default transport supports neither HTTP/3 nor the configured proxy.
+		base := http.DefaultTransport
+		if storageClientConfig.ClientProtocol == cfg.HTTP3 || pc.explicit {
+			base = transport
+		}
+		storageClientConfig.IdleConnections.register(base)
+		httpClient.Transport = &connectionRetryTransport{
+			wrapped: withTracing(storageClientConfig, base),
+			retrier: newConnectionRetrier(storageClientConfig),
+		}
 	} else {
-		var tokenSrc oauth2.TokenSource
-		tokenSrc, err = CreateTokenSource(storageClientConfig)
+		var tokenSrc *auth.RefreshingTokenSource
+		tokenSrc, err = createRefreshingTokenSource(storageClientConfig)
 		if err != nil {
 			err = fmt.Errorf("while fetching tokenSource: %w", err)
 			return
 		}
+		storageClientConfig.IdleConnections.register(transport)
 
-		// Custom http client for Go Client.
+		// Custom http client for Go Client. The requests rejected because the
+		// token was revoked or expired early are retried with a new token.
 		httpClient = &http.Client{
-			Transport: &oauth2.Transport{
-				Base:   transport,
+			Transport: auth.NewUnauthorizedRetryTransport(&oauth2.Transport{
+				Base: &connectionRetryTransport{
+					wrapped: withTracing(storageClientConfig, transport),
+					retrier: newConnectionRetrier(storageClientConfig),
+				},
 				Source: tokenSrc,
-			},
+			}, tokenSrc),
 			Timeout: storageClientConfig.HttpClientTimeout,
 		}
 		// Setting UserAgent through RoundTripper middleware
@@ -115,13 +192,44 @@ func CreateHttpClient(storageClientConfig *StorageClientConfig) (httpClient *htt
 			UserAgent: storageClientConfig.UserAgent,
 		}
 	}
+	if storageClientConfig.MetricHandle != nil {
+		httpClient.Transport = &uploadMetricsTransport{
+			wrapped:      httpClient.Transport,
+			metricHandle: storageClientConfig.MetricHandle,
+		}
+	}
+	if storageClientConfig.LogSlowRequests {
+		httpClient.Transport = &slowRequestTransport{wrapped: httpClient.Transport}
+	}
 	return httpClient, err
 }
 
-// It creates the token-source from the provided
-// key-file or using ADC search order (https://cloud.google.com/docs/authentication/application-default-credentials#order).
+// withTracing returns transport, tracing each attempt of the requests if
+// tracing is enabled.
+func withTracing(storageClientConfig *StorageClientConfig, transport http.RoundTripper) http.RoundTripper {
+	if !storageClientConfig.EnableTracing {
+		return transport
+	}
+	return otelhttp.NewTransport(transport)
+}
+
+// It creates the token-source from the provided key-file, credential
+// configuration file or external command, or using ADC search order (https://cloud.google.com/docs/authentication/application-default-credentials#order).
+// The tokens are refreshed in the background before they expire.
 func CreateTokenSource(storageClientConfig *StorageClientConfig) (tokenSrc oauth2.TokenSource, err error) {
-	return auth.GetTokenSource(context.Background(), storageClientConfig.KeyFile, storageClientConfig.TokenUrl, storageClientConfig.ReuseTokenFromUrl)
+	refreshingTokenSrc, err := createRefreshingTokenSource(storageClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return refreshingTokenSrc, nil
+}
+
+func createRefreshingTokenSource(storageClientConfig *StorageClientConfig) (*auth.RefreshingTokenSource, error) {
+	tokenSrc, err := auth.GetTokenSource(context.Background(), storageClientConfig.KeyFile, storageClientConfig.CredentialConfigFile, storageClientConfig.ExternalCredentialCommand, storageClientConfig.TokenUrl, storageClientConfig.ReuseTokenFromUrl, storageClientConfig.ImpersonateServiceAccount, storageClientConfig.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewRefreshingTokenSource(tokenSrc, storageClientConfig.MetricHandle), nil
 }
 
 // StripScheme strips the scheme part of given url.
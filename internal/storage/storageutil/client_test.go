@@ -18,8 +18,11 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
+	"github.com/quic-go/quic-go/http3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2"
 )
 
@@ -37,7 +40,9 @@ func (t *clientTest) validateProxyInTransport(httpClient *http.Client) {
 	AssertEq(true, ok)
 	oauthTransport, ok := userAgentRT.wrapped.(*oauth2.Transport)
 	AssertEq(true, ok)
-	transport, ok := oauthTransport.Base.(*http.Transport)
+	connectionRetryRT, ok := oauthTransport.Base.(*connectionRetryTransport)
+	AssertEq(true, ok)
+	transport, ok := connectionRetryRT.wrapped.(*http.Transport)
 	AssertEq(true, ok)
 	if ok {
 		ExpectEq(http.ProxyFromEnvironment, transport.Proxy)
@@ -66,6 +71,36 @@ func (t *clientTest) TestCreateHttpClientWithHttp2() {
 	ExpectEq(sc.HttpClientTimeout, httpClient.Timeout)
 }
 
+func (t *clientTest) TestCreateHttpClientWithHttp3() {
+	sc := GetDefaultStorageClientConfig()
+	sc.ClientProtocol = cfg.HTTP3
+
+	httpClient, err := CreateHttpClient(&sc)
+
+	ExpectEq(nil, err)
+	AssertNe(nil, httpClient)
+	connectionRetryRT, ok := httpClient.Transport.(*connectionRetryTransport)
+	AssertTrue(ok)
+	_, ok = connectionRetryRT.wrapped.(*http3.Transport)
+	ExpectTrue(ok)
+	ExpectEq(sc.HttpClientTimeout, httpClient.Timeout)
+}
+
+func (t *clientTest) TestCreateHttpClientWithTracing() {
+	sc := GetDefaultStorageClientConfig()
+	sc.ClientProtocol = cfg.HTTP3
+	sc.EnableTracing = true
+
+	httpClient, err := CreateHttpClient(&sc)
+
+	ExpectEq(nil, err)
+	AssertNe(nil, httpClient)
+	connectionRetryRT, ok := httpClient.Transport.(*connectionRetryTransport)
+	AssertTrue(ok)
+	_, ok = connectionRetryRT.wrapped.(*otelhttp.Transport)
+	ExpectTrue(ok)
+}
+
 func (t *clientTest) TestCreateHttpClientWithHttp1AndAuthEnabled() {
 	sc := GetDefaultStorageClientConfig() // By default http1 enabled
 	sc.AnonymousAccess = false
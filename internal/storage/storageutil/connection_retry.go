@@ -0,0 +1,199 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A *ConnectionError value is the error of a request which failed to establish
+// a connection to GCS, i.e. the DNS lookup, the TCP connection or the TLS or
+// ALTS handshake failed, after all its connection retries, if any were
+// configured. It isn't retried further by the API-level retries.
+type ConnectionError struct {
+	// The number of attempts to establish the connection.
+	Attempts int
+	Err      error
+}
+
+func (ce *ConnectionError) Error() string {
+	return fmt.Sprintf("failed to establish a connection to GCS after %d attempt(s), check the DNS, proxy, firewall and TLS settings of the network: %v", ce.Attempts, ce.Err)
+}
+
+func (ce *ConnectionError) Unwrap() error {
+	return ce.Err
+}
+
+// GRPCStatus keeps the status code of the gRPC errors, so that the gRPC
+// clients handle them like the original errors.
+func (ce *ConnectionError) GRPCStatus() *status.Status {
+	code := status.Code(ce.Err)
+	if code == codes.Unknown || code == codes.OK {
+		code = codes.Unavailable
+	}
+	return status.New(code, ce.Error())
+}
+
+// isConnectionEstablishmentError returns true if err is a failure to establish
+// a connection, as opposed to a failure of the request over an established
+// connection.
+func isConnectionEstablishmentError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	// The TCP connection, or the TLS handshake rejected by the server.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "remote error") {
+		return true
+	}
+
+	// The TLS handshake rejected by the client.
+	var recordHeaderErr tls.RecordHeaderError
+	var certVerificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordHeaderErr) || errors.As(err, &certVerificationErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalidErr) {
+		return true
+	}
+	if strings.Contains(err.Error(), "net/http: TLS handshake timeout") {
+		return true
+	}
+
+	// gRPC establishes the connections in the background and fails the RPCs
+	// with Unavailable until it succeeds, describing why.
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Unavailable {
+		msg := s.Message()
+		return strings.Contains(msg, "authentication handshake failed") ||
+			strings.Contains(msg, "Error while dialing") ||
+			strings.Contains(msg, "name resolver error") ||
+			strings.Contains(msg, "produced zero addresses")
+	}
+	return false
+}
+
+// connectionRetrier retries the requests which failed to establish a
+// connection, with a limit distinct from the one of the API-level retries. With
+// no limit, i.e. maxRetries 0, these requests are left to the API-level
+// retries, like any other failure.
+type connectionRetrier struct {
+	maxRetries    int
+	maxRetrySleep time.Duration
+	multiplier    float64
+}
+
+func newConnectionRetrier(storageClientConfig *StorageClientConfig) connectionRetrier {
+	return connectionRetrier{
+		maxRetries:    storageClientConfig.MaxConnectionRetryAttempts,
+		maxRetrySleep: storageClientConfig.MaxRetrySleep,
+		multiplier:    storageClientConfig.RetryMultiplier,
+	}
+}
+
+// run calls call until it doesn't fail to establish a connection, at most
+// maxRetries+1 times, and returns a *ConnectionError if it keeps failing. If
+// retryable is false or maxRetries is 0, the failures are returned as they are
+// on the first attempt, so that they are left to the API-level retries.
+func (r connectionRetrier) run(ctx context.Context, retryable bool, call func() error) error {
+	if r.maxRetries == 0 {
+		return call()
+	}
+	backoff := gax.Backoff{
+		Initial:    min(time.Second, r.maxRetrySleep),
+		Max:        r.maxRetrySleep,
+		Multiplier: r.multiplier,
+	}
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !retryable || !isConnectionEstablishmentError(err) {
+			return err
+		}
+		if attempt > r.maxRetries {
+			return &ConnectionError{Attempts: attempt, Err: err}
+		}
+
+		logger.Warnf("Failed to establish a connection to GCS, retry %d of %d: %v", attempt, r.maxRetries, err)
+		if err := gax.Sleep(ctx, backoff.Pause()); err != nil {
+			return err
+		}
+	}
+}
+
+// connectionRetryTransport is a RoundTripper retrying the requests which failed
+// to establish a connection.
+type connectionRetryTransport struct {
+	wrapped http.RoundTripper
+	retrier connectionRetrier
+}
+
+func (t *connectionRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The transport closes the body of the failed requests.
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	var resp *http.Response
+	attempt := req
+	err := t.retrier.run(req.Context(), retryable, func() (err error) {
+		if attempt == nil {
+			attempt = req.Clone(req.Context())
+			if req.GetBody != nil {
+				if attempt.Body, err = req.GetBody(); err != nil {
+					return err
+				}
+			}
+		}
+		resp, err = t.wrapped.RoundTrip(attempt)
+		attempt = nil
+		return err
+	})
+	return resp, err
+}
+
+// GRPCConnectionRetryDialOptions returns the dial options retrying the RPCs of
+// the gRPC clients which failed to establish a connection.
+func GRPCConnectionRetryDialOptions(storageClientConfig *StorageClientConfig) []grpc.DialOption {
+	r := newConnectionRetrier(storageClientConfig)
+	unary := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return r.run(ctx, true, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (cs grpc.ClientStream, err error) {
+		err = r.run(ctx, true, func() (err error) {
+			cs, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		})
+		return cs, err
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary),
+		grpc.WithChainStreamInterceptor(stream),
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRoundTripper fails the first failures requests with err, and returns
+// 200 with the body of the request for the following ones.
+type fakeRoundTripper struct {
+	err      error
+	failures int
+	calls    int
+}
+
+func (rt *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if rt.calls <= rt.failures {
+		return nil, rt.err
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+func newTestConnectionRetryTransport(wrapped http.RoundTripper, maxRetries int) *connectionRetryTransport {
+	return &connectionRetryTransport{
+		wrapped: wrapped,
+		retrier: connectionRetrier{maxRetries: maxRetries, maxRetrySleep: time.Millisecond},
+	}
+}
+
+func TestIsConnectionEstablishmentError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "DNS error",
+			err:      &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "storage.googleapis.com"}},
+			expected: true,
+		},
+		{
+			name:     "dial error",
+			err:      &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			expected: true,
+		},
+		{
+			name:     "TLS handshake rejected by the server",
+			err:      &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")},
+			expected: true,
+		},
+		{
+			name:     "unknown certificate authority",
+			err:      x509.UnknownAuthorityError{},
+			expected: true,
+		},
+		{
+			name:     "TLS handshake timeout",
+			err:      errors.New("net/http: TLS handshake timeout"),
+			expected: true,
+		},
+		{
+			name:     "ALTS handshake",
+			err:      status.Error(codes.Unavailable, `connection error: desc = "transport: authentication handshake failed: EOF"`),
+			expected: true,
+		},
+		{
+			name:     "gRPC DNS error",
+			err:      status.Error(codes.Unavailable, `name resolver error: produced zero addresses`),
+			expected: true,
+		},
+		{
+			name:     "read error",
+			err:      &net.OpError{Op: "read", Err: errors.New("connection reset by peer")},
+			expected: false,
+		},
+		{
+			name:     "gRPC transport closing",
+			err:      status.Error(codes.Unavailable, "transport is closing"),
+			expected: false,
+		},
+		{
+			name:     "API error",
+			err:      &googleapi.Error{Code: http.StatusServiceUnavailable},
+			expected: false,
+		},
+		{
+			name:     "unexpected EOF",
+			err:      io.ErrUnexpectedEOF,
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isConnectionEstablishmentError(tc.err))
+		})
+	}
+}
+
+func TestConnectionRetryTransport_FailsAfterMaxRetries(t *testing.T) {
+	rt := &fakeRoundTripper{err: &net.DNSError{Err: "no such host"}, failures: 10}
+	req, err := http.NewRequest(http.MethodGet, "https://storage.googleapis.com", nil)
+	require.NoError(t, err)
+
+	_, err = newTestConnectionRetryTransport(rt, 2).RoundTrip(req)
+
+	var connErr *ConnectionError
+	require.ErrorAs(t, err, &connErr)
+	assert.Equal(t, 3, connErr.Attempts)
+	assert.Equal(t, 3, rt.calls)
+	assert.False(t, ShouldRetry(err))
+}
+
+func TestConnectionRetryTransport_NoRetries(t *testing.T) {
+	rt := &fakeRoundTripper{err: &net.DNSError{Err: "no such host"}, failures: 10}
+	req, err := http.NewRequest(http.MethodGet, "https://storage.googleapis.com", nil)
+	require.NoError(t, err)
+
+	_, err = newTestConnectionRetryTransport(rt, 0).RoundTrip(req)
+
+	// The error is left to the API-level retries.
+	var connErr *ConnectionError
+	assert.False(t, errors.As(err, &connErr))
+	assert.Equal(t, rt.err, err)
+	assert.Equal(t, 1, rt.calls)
+}
+
+func TestConnectionRetryTransport_RetriesWithBody(t *testing.T) {
+	rt := &fakeRoundTripper{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, failures: 1}
+	req, err := http.NewRequest(http.MethodPost, "https://storage.googleapis.com", strings.NewReader("body"))
+	require.NoError(t, err)
+
+	resp, err := newTestConnectionRetryTransport(rt, 2).RoundTrip(req)
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+	assert.Equal(t, 2, rt.calls)
+}
+
+func TestConnectionRetryTransport_BodyNotRewindable(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host"}
+	rt := &fakeRoundTripper{err: dnsErr, failures: 10}
+	req, err := http.NewRequest(http.MethodPost, "https://storage.googleapis.com", io.NopCloser(strings.NewReader("body")))
+	require.NoError(t, err)
+
+	_, err = newTestConnectionRetryTransport(rt, 2).RoundTrip(req)
+
+	// The error is left to the API-level retries.
+	assert.Equal(t, dnsErr, err)
+	assert.Equal(t, 1, rt.calls)
+}
+
+func TestConnectionRetryTransport_OtherErrorsNotRetried(t *testing.T) {
+	rt := &fakeRoundTripper{err: io.ErrUnexpectedEOF, failures: 10}
+	req, err := http.NewRequest(http.MethodGet, "https://storage.googleapis.com", nil)
+	require.NoError(t, err)
+
+	_, err = newTestConnectionRetryTransport(rt, 2).RoundTrip(req)
+
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, 1, rt.calls)
+}
+
+func TestConnectionRetrier_KeepsGRPCStatusCode(t *testing.T) {
+	r := connectionRetrier{maxRetries: 1, maxRetrySleep: time.Millisecond}
+	calls := 0
+
+	err := r.run(context.Background(), true, func() error {
+		calls++
+		return status.Error(codes.Unavailable, `connection error: desc = "transport: Error while dialing: dial tcp: lookup storage.googleapis.com: no such host"`)
+	})
+
+	var connErr *ConnectionError
+	require.ErrorAs(t, err, &connErr)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "failed to establish a connection to GCS after 2 attempt(s)")
+}
+
+func TestConnectionRetrier_StopsWhenContextDone(t *testing.T) {
+	r := connectionRetrier{maxRetries: 5, maxRetrySleep: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := r.run(ctx, true, func() error {
+		calls++
+		cancel()
+		return &net.DNSError{Err: "no such host"}
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
@@ -16,6 +16,7 @@ package storageutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -27,11 +28,25 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+// connectionErrorRetryer doesn't retry the RPCs which failed to establish a
+// connection, as they were already retried with their own limit.
+type connectionErrorRetryer struct {
+	gax.Retryer
+}
+
+func (r connectionErrorRetryer) Retry(err error) (time.Duration, bool) {
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) {
+		return 0, false
+	}
+	return r.Retryer.Retry(err)
+}
+
 func storageControlClientRetryOptions(clientConfig *StorageClientConfig) []gax.CallOption {
 	return []gax.CallOption{
 		gax.WithTimeout(300000 * time.Millisecond),
 		gax.WithRetry(func() gax.Retryer {
-			return gax.OnCodes([]codes.Code{
+			return connectionErrorRetryer{gax.OnCodes([]codes.Code{
 				codes.ResourceExhausted,
 				codes.Unavailable,
 				codes.DeadlineExceeded,
@@ -40,7 +55,7 @@ func storageControlClientRetryOptions(clientConfig *StorageClientConfig) []gax.C
 			}, gax.Backoff{
 				Max:        clientConfig.MaxRetrySleep,
 				Multiplier: clientConfig.RetryMultiplier,
-			})
+			})}
 		}),
 	}
 }
@@ -18,9 +18,12 @@ import (
 	"context"
 	"testing"
 
+	"github.com/googleapis/gax-go/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ControlClientTest struct {
@@ -45,6 +48,17 @@ func (testSuite *ControlClientTest) TestStorageControlClientRetryOptions() {
 	assert.NotNil(testSuite.T(), gaxOpts)
 }
 
+func (testSuite *ControlClientTest) TestConnectionErrorRetryer() {
+	retryer := connectionErrorRetryer{gax.OnCodes([]codes.Code{codes.Unavailable}, gax.Backoff{})}
+	unavailableErr := status.Error(codes.Unavailable, "unavailable")
+
+	_, retryUnavailable := retryer.Retry(unavailableErr)
+	_, retryConnErr := retryer.Retry(&ConnectionError{Attempts: 1, Err: unavailableErr})
+
+	assert.True(testSuite.T(), retryUnavailable)
+	assert.False(testSuite.T(), retryConnErr)
+}
+
 func (testSuite *ControlClientTest) TestStorageControlClient() {
 	var clientOpts []option.ClientOption
 	clientOpts = append(clientOpts, option.WithoutAuthentication())
@@ -15,12 +15,21 @@
 package storageutil
 
 import (
+	"errors"
+
 	"cloud.google.com/go/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"google.golang.org/api/googleapi"
 )
 
 func ShouldRetry(err error) (b bool) {
+	// The requests which failed to establish a connection were already retried
+	// with their own limit.
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) {
+		return false
+	}
+
 	b = storage.ShouldRetry(err)
 	if b {
 		logger.Infof("Retrying for the error: %v", err)
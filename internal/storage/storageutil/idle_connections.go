@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// IdleConnections closes the connections to GCS not in use by a request of the
+// clients created with a StorageClientConfig referring to it, e.g. while the
+// mount is idle. The next requests open new ones.
+type IdleConnections struct {
+	// The channels of the gRPC clients, whose connections can't be closed on
+	// demand, close them once no RPC has been sent for idleAfter.
+	idleAfter time.Duration
+
+	mu sync.Mutex
+
+	// The transports of the HTTP clients.
+	//
+	// GUARDED_BY(mu)
+	transports []interface{ CloseIdleConnections() }
+}
+
+// NewIdleConnections returns the IdleConnections of a mount whose resources
+// are released once idle for idleAfter, or nil if idleAfter isn't positive.
+func NewIdleConnections(idleAfter time.Duration) *IdleConnections {
+	if idleAfter <= 0 {
+		return nil
+	}
+	return &IdleConnections{idleAfter: idleAfter}
+}
+
+func (c *IdleConnections) register(transport http.RoundTripper) {
+	if c == nil {
+		return
+	}
+	closer, ok := transport.(interface{ CloseIdleConnections() })
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transports = append(c.transports, closer)
+}
+
+// Close closes the idle connections of the HTTP clients.
+func (c *IdleConnections) Close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	transports := c.transports
+	c.mu.Unlock()
+
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+}
+
+// GRPCIdleDialOptions returns the dial options closing the connections of the
+// channels of the gRPC clients once they're idle, if requested.
+func GRPCIdleDialOptions(storageClientConfig *StorageClientConfig) []grpc.DialOption {
+	c := storageClientConfig.IdleConnections
+	if c == nil {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithIdleTimeout(c.idleAfter)}
+}
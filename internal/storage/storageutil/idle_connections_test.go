@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleConnections_Close(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+	idle := NewIdleConnections(time.Minute)
+	client, err := CreateHttpClient(&StorageClientConfig{ClientProtocol: cfg.HTTP1, AnonymousAccess: true, IdleConnections: idle})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	idle.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the idle connection wasn't closed")
+	}
+}
+
+func TestIdleConnections_Disabled(t *testing.T) {
+	idle := NewIdleConnections(0)
+
+	assert.Nil(t, idle)
+	assert.Empty(t, GRPCIdleDialOptions(&StorageClientConfig{IdleConnections: idle}))
+	idle.Close()
+}
+
+func TestGRPCIdleDialOptions(t *testing.T) {
+	assert.Len(t, GRPCIdleDialOptions(&StorageClientConfig{IdleConnections: NewIdleConnections(time.Minute)}), 1)
+}
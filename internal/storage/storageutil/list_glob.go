@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// ListGlob lists the objects in the supplied bucket matching pattern, with the
+// syntax of path.Match, or under a directory matching it, e.g. "logs/*/a"
+// matches "logs/x/a" and "logs/x/a/b" but not "logs/x/y/a". An empty pattern
+// matches all the objects. Directory placeholder objects, whose names end
+// with a slash, aren't listed.
+func ListGlob(
+	ctx context.Context,
+	bucket gcs.Bucket,
+	pattern string) (minObjects []*gcs.MinObject, err error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if _, err = path.Match(pattern, ""); err != nil {
+		err = fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		return
+	}
+
+	// Only the objects starting with the part of the pattern before the first
+	// meta character can match.
+	prefix := pattern
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		prefix = pattern[:i]
+	}
+	listed, _, err := ListAll(ctx, bucket, &gcs.ListObjectsRequest{Prefix: prefix})
+	if err != nil {
+		err = fmt.Errorf("ListObjects: %w", err)
+		return
+	}
+
+	for _, o := range listed {
+		if !strings.HasSuffix(o.Name, "/") && matchesGlob(pattern, o.Name) {
+			minObjects = append(minObjects, o)
+		}
+	}
+	return
+}
+
+// matchesGlob returns true if name, or one of its parent directories, matches
+// pattern.
+func matchesGlob(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	for {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	. "github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListGlob(t *testing.T) {
+	ctx := context.Background()
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+	require.NoError(t, CreateObjects(ctx, bucket, map[string][]byte{
+		"a":         nil,
+		"ab":        nil,
+		"logs/":     nil,
+		"logs/x/a":  nil,
+		"logs/x/a/": nil,
+		"logs/x/ab": nil,
+		"logs/x/y":  nil,
+		"logs/y/a":  nil,
+		"logs/y/b":  nil,
+	}))
+	cases := []struct {
+		pattern string
+		names   []string
+	}{
+		{pattern: "", names: []string{"a", "ab", "logs/x/a", "logs/x/ab", "logs/x/y", "logs/y/a", "logs/y/b"}},
+		{pattern: "a", names: []string{"a"}},
+		{pattern: "logs", names: []string{"logs/x/a", "logs/x/ab", "logs/x/y", "logs/y/a", "logs/y/b"}},
+		{pattern: "logs/x/", names: []string{"logs/x/a", "logs/x/ab", "logs/x/y"}},
+		{pattern: "a*", names: []string{"a", "ab"}},
+		{pattern: "logs/*/a", names: []string{"logs/x/a", "logs/y/a"}},
+		{pattern: "logs/x/?", names: []string{"logs/x/a", "logs/x/y"}},
+		{pattern: "logs/[xz]", names: []string{"logs/x/a", "logs/x/ab", "logs/x/y"}},
+		{pattern: "missing", names: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			objects, err := ListGlob(ctx, bucket, tc.pattern)
+
+			require.NoError(t, err)
+			var names []string
+			for _, o := range objects {
+				names = append(names, o.Name)
+			}
+			assert.Equal(t, tc.names, names)
+		})
+	}
+}
+
+func TestListGlob_InvalidPattern(t *testing.T) {
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.NonHierarchical)
+
+	_, err := ListGlob(context.Background(), bucket, "logs/[")
+
+	assert.ErrorContains(t, err, "invalid pattern")
+}
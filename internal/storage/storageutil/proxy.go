@@ -0,0 +1,190 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"golang.org/x/net/proxy"
+)
+
+// proxyConfig is the proxy of the GCS connections.
+type proxyConfig struct {
+	// proxy returns the proxy of the requests, or nil for a direct connection.
+	proxy func(*http.Request) (*url.URL, error)
+
+	// connectHeader is sent with the CONNECT requests to HTTP proxies.
+	connectHeader http.Header
+
+	// explicit is true if the proxy is configured rather than only taken from
+	// the environment.
+	explicit bool
+}
+
+func newProxyConfig(storageClientConfig *StorageClientConfig) (*proxyConfig, error) {
+	proxyURL, err := cfg.ParseProxyURL(storageClientConfig.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	connectHeader, err := cfg.ParseProxyHeaders(storageClientConfig.ProxyHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &proxyConfig{
+		proxy:         http.ProxyFromEnvironment,
+		connectHeader: connectHeader,
+		explicit:      proxyURL != nil || len(connectHeader) > 0,
+	}
+	if proxyURL != nil {
+		pc.proxy = http.ProxyURL(proxyURL)
+	}
+	return pc, nil
+}
+
+// GRPCProxyDialer returns the dialer of the gRPC connections through the
+// configured proxy, or nil if no proxy is configured, in which case gRPC uses
+// the proxy of the environment by itself.
+func GRPCProxyDialer(storageClientConfig *StorageClientConfig) (func(context.Context, string) (net.Conn, error), error) {
+	pc, err := newProxyConfig(storageClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !pc.explicit {
+		return nil, nil
+	}
+	return pc.dialContext, nil
+}
+
+// dialContext dials addr, i.e. host:port, through the proxy of
+// https://addr, like http.Transport does for HTTP requests. It is meant for
+// gRPC, whose own proxy support neither handles SOCKS5 nor custom headers.
+func (pc *proxyConfig) dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	proxyURL, err := pc.proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+	if err != nil {
+		return nil, fmt.Errorf("proxy for %s: %w", addr, err)
+	}
+
+	var dialer net.Dialer
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u := proxyURL.User; u != nil {
+			password, _ := u.Password()
+			auth = &proxy.Auth{User: u.Username(), Password: password}
+		}
+		socksDialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &dialer)
+		if err != nil {
+			return nil, fmt.Errorf("SOCKS5: %w", err)
+		}
+		return socksDialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+
+	default:
+		return pc.dialConnect(ctx, proxyURL, addr)
+	}
+}
+
+// dialConnect tunnels a connection to addr through the HTTP proxy with a
+// CONNECT request.
+func (pc *proxyConfig) dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing proxy %s: %w", proxyAddr, err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s: %w", proxyAddr, err)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: pc.connectHeader.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	if u := proxyURL.User; u != nil && req.Header.Get("Proxy-Authorization") == "" {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+
+	// Don't block on a proxy which doesn't answer after the context is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing CONNECT to proxy %s: %w", proxyAddr, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading CONNECT response from proxy %s: %w", proxyAddr, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT %s: %s", proxyAddr, addr, resp.Status)
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+
+	// The proxy may have sent the first bytes of the tunnel along with the
+	// response.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes were read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
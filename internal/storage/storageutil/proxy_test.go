@@ -0,0 +1,181 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveOnce serves the first connection accepted by a new listener with
+// handle, and returns the address of the listener.
+func serveOnce(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+	return l.Addr().String()
+}
+
+func dialThroughProxy(t *testing.T, sc *StorageClientConfig, addr string) (net.Conn, error) {
+	t.Helper()
+	dial, err := GRPCProxyDialer(sc)
+	require.NoError(t, err)
+	require.NotNil(t, dial)
+	return dial(context.Background(), addr)
+}
+
+func TestGRPCProxyDialer_NotConfigured(t *testing.T) {
+	sc := GetDefaultStorageClientConfig()
+
+	dial, err := GRPCProxyDialer(&sc)
+
+	require.NoError(t, err)
+	assert.Nil(t, dial)
+}
+
+func TestGRPCProxyDialer_InvalidProxyURL(t *testing.T) {
+	sc := GetDefaultStorageClientConfig()
+	sc.ProxyURL = "ftp://proxy"
+
+	_, err := GRPCProxyDialer(&sc)
+
+	assert.Error(t, err)
+}
+
+func TestGRPCProxyDialer_HTTPConnect(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	proxyAddr := serveOnce(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		// The first bytes of the tunnel are sent along with the response.
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\nhello")
+	})
+	sc := GetDefaultStorageClientConfig()
+	sc.ProxyURL = "http://user:password@" + proxyAddr
+	sc.ProxyHeaders = []string{"X-Tenant: gcsfuse"}
+
+	conn, err := dialThroughProxy(t, &sc, "storage.googleapis.com:443")
+
+	require.NoError(t, err)
+	defer conn.Close()
+	req := <-requests
+	assert.Equal(t, http.MethodConnect, req.Method)
+	assert.Equal(t, "storage.googleapis.com:443", req.Host)
+	assert.Equal(t, "gcsfuse", req.Header.Get("X-Tenant"))
+	assert.Equal(t, "Basic dXNlcjpwYXNzd29yZA==", req.Header.Get("Proxy-Authorization"))
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestGRPCProxyDialer_HTTPConnectRefused(t *testing.T) {
+	proxyAddr := serveOnce(t, func(conn net.Conn) {
+		if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+			return
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+	})
+	sc := GetDefaultStorageClientConfig()
+	sc.ProxyURL = "http://" + proxyAddr
+
+	_, err := dialThroughProxy(t, &sc, "storage.googleapis.com:443")
+
+	assert.ErrorContains(t, err, "407")
+}
+
+func TestGRPCProxyDialer_SOCKS5(t *testing.T) {
+	type socksRequest struct {
+		user, password, host string
+		port                 uint16
+	}
+	requests := make(chan socksRequest, 1)
+	proxyAddr := serveOnce(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		var req socksRequest
+		readBytes := func(n int) []byte {
+			b := make([]byte, n)
+			_, _ = io.ReadFull(r, b)
+			return b
+		}
+		// Greeting: version, methods. Select username/password.
+		greeting := readBytes(2)
+		readBytes(int(greeting[1]))
+		_, _ = conn.Write([]byte{5, 2})
+		// Username/password authentication.
+		readBytes(1)
+		req.user = string(readBytes(int(readBytes(1)[0])))
+		req.password = string(readBytes(int(readBytes(1)[0])))
+		_, _ = conn.Write([]byte{1, 0})
+		// Connect request with a domain name.
+		readBytes(4)
+		req.host = string(readBytes(int(readBytes(1)[0])))
+		req.port = binary.BigEndian.Uint16(readBytes(2))
+		requests <- req
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		_, _ = io.WriteString(conn, "hello")
+	})
+	sc := GetDefaultStorageClientConfig()
+	sc.ProxyURL = (&url.URL{Scheme: "socks5", User: url.UserPassword("user", "password"), Host: proxyAddr}).String()
+
+	conn, err := dialThroughProxy(t, &sc, "storage.googleapis.com:443")
+
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, socksRequest{user: "user", password: "password", host: "storage.googleapis.com", port: 443}, <-requests)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestCreateHttpClient_ProxyURL(t *testing.T) {
+	sc := GetDefaultStorageClientConfig()
+	sc.ProxyURL = "socks5://proxy:1080"
+	sc.ProxyHeaders = []string{"X-Tenant: gcsfuse"}
+
+	httpClient, err := CreateHttpClient(&sc)
+
+	require.NoError(t, err)
+	connectionRetryRT, ok := httpClient.Transport.(*connectionRetryTransport)
+	require.True(t, ok)
+	transport, ok := connectionRetryRT.wrapped.(*http.Transport)
+	require.True(t, ok)
+	proxyURL, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "storage.googleapis.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, "socks5://proxy:1080", proxyURL.String())
+	assert.Equal(t, "gcsfuse", transport.ProxyConnectHeader.Get("X-Tenant"))
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// invocationIDRegexp matches the ID the Go client library adds to the
+// X-Goog-Api-Client header of the requests, the same for all the attempts of a
+// request.
+var invocationIDRegexp = regexp.MustCompile(`gccl-invocation-id/([^ ]+)`)
+
+// invocationID returns the invocation ID in the supplied X-Goog-Api-Client
+// header, "" if none.
+func invocationID(apiClient string) string {
+	m := invocationIDRegexp.FindStringSubmatch(apiClient)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// objectName returns the name of the object of a request to the JSON or the
+// XML API, "" if it isn't about a single object.
+func objectName(req *http.Request) string {
+	p := req.URL.Path
+	if _, name, ok := strings.Cut(p, "/o/"); ok && strings.Contains(p, "/b/") {
+		return name
+	}
+	if name := req.URL.Query().Get("name"); name != "" {
+		return name
+	}
+	for _, api := range []string{"/storage/", "/upload/", "/download/", "/batch/"} {
+		if strings.HasPrefix(p, api) {
+			return ""
+		}
+	}
+	// The reads of the XML API are sent to /BUCKET/OBJECT.
+	if parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return ""
+}
+
+// slowRequestTransport is a RoundTripper logging the requests slower than
+// logger.SlowOpThreshold, from when they're sent until their response body is
+// read or closed, with the object, the byte range and the IDs of the request.
+type slowRequestTransport struct {
+	wrapped http.RoundTripper
+}
+
+func (t *slowRequestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if logger.SlowOpThreshold() == 0 {
+		return t.wrapped.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.wrapped.RoundTrip(req)
+	headersLatency := time.Since(start)
+	attrs := []slog.Attr{slog.String("object", objectName(req))}
+	if r := req.Header.Get("Range"); r != "" {
+		attrs = append(attrs, slog.String("range", r))
+	}
+	if id := invocationID(req.Header.Get("X-Goog-Api-Client")); id != "" {
+		attrs = append(attrs, slog.String("invocation_id", id))
+	}
+	if err != nil {
+		logger.LogSlowOp(req.Method, headersLatency, append(attrs, slog.String("error", err.Error()))...)
+		return resp, err
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.Duration("headers_latency", headersLatency))
+	// GCS support finds the requests by the ID it returns in this header.
+	if id := resp.Header.Get("X-Guploader-Uploadid"); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	resp.Body = &slowResponseBody{
+		ReadCloser: resp.Body,
+		done: func() {
+			logger.LogSlowOp(req.Method, time.Since(start), attrs...)
+		},
+	}
+	return resp, nil
+}
+
+// slowResponseBody calls done once it's read to the end or closed.
+type slowResponseBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *slowResponseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *slowResponseBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// rpcAttrs returns the attributes of the RPC with the supplied outgoing
+// context.
+func rpcAttrs(ctx context.Context, method string) []slog.Attr {
+	attrs := []slog.Attr{slog.String("method", method)}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if params := md.Get("x-goog-request-params"); len(params) > 0 {
+		attrs = append(attrs, slog.String("request_params", params[0]))
+	}
+	if apiClient := md.Get("x-goog-api-client"); len(apiClient) > 0 {
+		if id := invocationID(apiClient[0]); id != "" {
+			attrs = append(attrs, slog.String("invocation_id", id))
+		}
+	}
+	return attrs
+}
+
+// logSlowRPC logs the RPC if it's slower than logger.SlowOpThreshold.
+func logSlowRPC(attrs []slog.Attr, latency time.Duration, err error) {
+	attrs = append(attrs, slog.String("code", status.Code(err).String()))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogSlowOp("RPC", latency, attrs...)
+}
+
+// GRPCSlowRequestDialOptions returns the dial options logging the RPCs of the
+// gRPC clients slower than logger.SlowOpThreshold, the streams from when they
+// are opened until they end.
+func GRPCSlowRequestDialOptions() []grpc.DialOption {
+	unary := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if logger.SlowOpThreshold() == 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logSlowRPC(rpcAttrs(ctx, method), time.Since(start), err)
+		return err
+	}
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if logger.SlowOpThreshold() == 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logSlowRPC(rpcAttrs(ctx, method), time.Since(start), err)
+			return cs, err
+		}
+		return &slowClientStream{ClientStream: cs, start: start, attrs: rpcAttrs(ctx, method)}, nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary),
+		grpc.WithChainStreamInterceptor(stream),
+	}
+}
+
+// slowClientStream logs its RPC once it ends, i.e. once a message fails to be
+// received, with io.EOF at the end of the stream.
+type slowClientStream struct {
+	grpc.ClientStream
+	start time.Time
+	attrs []slog.Attr
+	once  sync.Once
+}
+
+func (s *slowClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if err == io.EOF {
+				logSlowRPC(s.attrs, time.Since(s.start), nil)
+			} else {
+				logSlowRPC(s.attrs, time.Since(s.start), err)
+			}
+		})
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectName_OfRequest(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://storage.googleapis.com/storage/v1/b/bucket/o/dir%2Fa.txt?alt=json", "dir/a.txt"},
+		{"https://storage.googleapis.com/download/storage/v1/b/bucket/o/a.txt?alt=media", "a.txt"},
+		{"https://storage.googleapis.com/upload/storage/v1/b/bucket/o?uploadType=multipart&name=a.txt", "a.txt"},
+		{"https://storage.googleapis.com/bucket/dir/a.txt", "dir/a.txt"},
+		{"https://storage.googleapis.com/storage/v1/b/bucket/o?prefix=dir%2F", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, objectName(req))
+		})
+	}
+}
+
+// slowRoundTripper returns its response after delay.
+type slowRoundTripper struct {
+	delay time.Duration
+}
+
+func (rt slowRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	time.Sleep(rt.delay)
+	header := http.Header{}
+	header.Set("X-Guploader-Uploadid", "upload-id-1")
+	return &http.Response{StatusCode: http.StatusPartialContent, Header: header, Body: io.NopCloser(strings.NewReader("contents"))}, nil
+}
+
+func TestSlowRequestTransport(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "gcsfuse.log")
+	require.NoError(t, logger.InitLogFile(cfg.LoggingConfig{
+		FilePath:  cfg.ResolvedPath(logFile),
+		Format:    "text",
+		Severity:  "INFO",
+		LogRotate: cfg.LogRotateLoggingConfig{MaxFileSizeMb: 1},
+		SlowOps:   cfg.SlowOpsLoggingConfig{Threshold: 10 * time.Millisecond, SampleRate: 1},
+	}))
+	defer func() { require.NoError(t, logger.InitLogFile(cfg.LoggingConfig{Format: "text", Severity: "INFO"})) }()
+	transport := &slowRequestTransport{wrapped: slowRoundTripper{delay: 20 * time.Millisecond}}
+	req, err := http.NewRequest(http.MethodGet, "https://storage.googleapis.com/download/storage/v1/b/bucket/o/a.txt?alt=media", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-7")
+	req.Header.Set("X-Goog-Api-Client", "gl-go/1.23 gccl-invocation-id/xyz gccl-attempt-count/1")
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	logs, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	// The request is logged once, when its body is read to the end.
+	assert.Equal(t, 1, strings.Count(string(logs), "slow op"))
+	assert.Regexp(t, `message="slow op" op=GET latency=\S+ object=a.txt range="bytes=0-7" invocation_id=xyz status=206 headers_latency=\S+ request_id=upload-id-1`, string(logs))
+}
@@ -33,6 +33,7 @@ func GetDefaultStorageClientConfig() (clientConfig StorageClientConfig) {
 		HttpClientTimeout:          800 * time.Millisecond,
 		MaxRetrySleep:              time.Minute,
 		MaxRetryAttempts:           0,
+		MaxConnectionRetryAttempts: 3,
 		RetryMultiplier:            2,
 		UserAgent:                  "gcsfuse/unknown (Go version go1.20-pre3 cl/474093167 +a813be86df) (GCP:gcsfuse)",
 		CustomEndpoint:             "",
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+)
+
+// attemptCountRegexp matches the attempt number the Go client library adds to
+// the X-Goog-Api-Client header of the requests it may retry.
+var attemptCountRegexp = regexp.MustCompile(`gccl-attempt-count/(\d+)`)
+
+// uploadMetricsTransport is a RoundTripper recording the bytes of the chunks of
+// the resumable uploads sent again after a failed attempt, and the resumable
+// upload sessions which were lost or expired in GCS.
+type uploadMetricsTransport struct {
+	wrapped      http.RoundTripper
+	metricHandle common.MetricHandle
+}
+
+// isResumableUploadChunk returns true if req sends a chunk of a resumable
+// upload to its session URI.
+func isResumableUploadChunk(req *http.Request) bool {
+	return req.URL.Query().Has("upload_id") && req.Header.Get("Content-Range") != ""
+}
+
+// attemptCount returns the attempt number of req, 1 if it isn't known.
+func attemptCount(req *http.Request) int {
+	m := attemptCountRegexp.FindStringSubmatch(req.Header.Get("X-Goog-Api-Client"))
+	if m == nil {
+		return 1
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+func (t *uploadMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isResumableUploadChunk(req) {
+		return t.wrapped.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	if attemptCount(req) > 1 && req.ContentLength > 0 {
+		t.metricHandle.GCSUploadBytesRetriedCount(ctx, req.ContentLength, nil)
+	}
+	resp, err := t.wrapped.RoundTrip(req)
+	// The sessions unknown to GCS, or expired after a week, can't be resumed,
+	// and the upload has to be started again in a new one.
+	if err == nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) {
+		t.metricHandle.GCSUploadSessionRestartCount(ctx, 1, nil)
+	}
+	return resp, err
+}
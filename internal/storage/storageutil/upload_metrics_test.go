@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageutil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sessionURI = "https://storage.googleapis.com/upload/storage/v1/b/bucket/o?uploadType=resumable&upload_id=abc"
+
+// uploadMetricHandle counts the upload metrics recorded.
+type uploadMetricHandle struct {
+	common.MetricHandle
+	bytesRetried int64
+	restarts     int64
+}
+
+func (m *uploadMetricHandle) GCSUploadBytesRetriedCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.bytesRetried += inc
+}
+
+func (m *uploadMetricHandle) GCSUploadSessionRestartCount(_ context.Context, inc int64, _ []common.MetricAttr) {
+	m.restarts += inc
+}
+
+// statusRoundTripper returns the responses with the supplied status code.
+type statusRoundTripper int
+
+func (rt statusRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: int(rt), Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func newChunkRequest(t *testing.T, url string, attempt string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader("chunk"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Range", "bytes 0-4/*")
+	req.Header.Set("X-Goog-Api-Client", "gl-go/1.23 gccl-invocation-id/xyz gccl-attempt-count/"+attempt)
+	return req
+}
+
+func TestUploadMetricsTransport(t *testing.T) {
+	testCases := []struct {
+		name             string
+		url              string
+		attempt          string
+		status           int
+		wantBytesRetried int64
+		wantRestarts     int64
+	}{
+		{
+			name:    "first_attempt",
+			url:     sessionURI,
+			attempt: "1",
+			status:  http.StatusOK,
+		},
+		{
+			name:             "retried_chunk",
+			url:              sessionURI,
+			attempt:          "3",
+			status:           http.StatusOK,
+			wantBytesRetried: 5,
+		},
+		{
+			name:         "session_not_found",
+			url:          sessionURI,
+			attempt:      "1",
+			status:       http.StatusNotFound,
+			wantRestarts: 1,
+		},
+		{
+			name:         "session_expired",
+			url:          sessionURI,
+			attempt:      "1",
+			status:       http.StatusGone,
+			wantRestarts: 1,
+		},
+		{
+			name:    "not_an_upload",
+			url:     "https://storage.googleapis.com/storage/v1/b/bucket/o/object",
+			attempt: "2",
+			status:  http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &uploadMetricHandle{MetricHandle: common.NewNoopMetrics()}
+			transport := &uploadMetricsTransport{wrapped: statusRoundTripper(tc.status), metricHandle: m}
+
+			resp, err := transport.RoundTrip(newChunkRequest(t, tc.url, tc.attempt))
+
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.wantBytesRetried, m.bytesRetried)
+			assert.Equal(t, tc.wantRestarts, m.restarts)
+		})
+	}
+}
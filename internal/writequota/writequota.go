@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package writequota caps what a mount writes to its buckets, e.g. so that a
+// runaway job can't fill a shared bucket.
+package writequota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+const (
+	quotaBytes   = "bytes"
+	quotaObjects = "objects"
+)
+
+// Quota is a cap on the bytes written through a mount and on the objects it
+// creates, over its lifetime: overwritten bytes count again, and the deleted
+// objects don't free any of it. The reservations beyond it fail with EDQUOT.
+//
+// A nil Quota is unlimited. Safe for concurrent use.
+type Quota struct {
+	// The caps, 0 for no cap.
+	maxBytes   int64
+	maxObjects int64
+
+	metricHandle common.MetricHandle
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	bytes   int64
+	objects int64
+
+	// Whether exceeding each cap has been logged, which is only done once.
+	//
+	// GUARDED_BY(mu)
+	logged map[string]bool
+}
+
+// New returns a quota of maxBytes bytes and maxObjects objects, 0 for no cap,
+// or nil if neither is capped.
+func New(maxBytes, maxObjects int64, metricHandle common.MetricHandle) *Quota {
+	if maxBytes <= 0 && maxObjects <= 0 {
+		return nil
+	}
+	return &Quota{
+		maxBytes:     maxBytes,
+		maxObjects:   maxObjects,
+		metricHandle: metricHandle,
+		logged:       make(map[string]bool),
+	}
+}
+
+// reserve reserves n of the quota of the supplied type, in which used are
+// used out of max.
+//
+// LOCKS_REQUIRED(q.mu)
+func (q *Quota) reserve(ctx context.Context, quotaType string, used *int64, max, n int64) error {
+	if max <= 0 || *used+n <= max {
+		*used += n
+		return nil
+	}
+
+	q.metricHandle.WriteQuotaExceededCount(ctx, 1, []common.MetricAttr{{Key: common.QuotaType, Value: quotaType}})
+	if !q.logged[quotaType] {
+		q.logged[quotaType] = true
+		logger.Warnf("The write quota of %d %s of the mount is exhausted, failing the writes with EDQUOT", max, quotaType)
+	}
+	return fmt.Errorf("the write quota of %d %s is exhausted, %d are used: %w", max, quotaType, *used, syscall.EDQUOT)
+}
+
+// ReserveBytes reserves n bytes about to be written. The returned error wraps
+// syscall.EDQUOT if they are beyond the quota.
+func (q *Quota) ReserveBytes(ctx context.Context, n int64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.reserve(ctx, quotaBytes, &q.bytes, q.maxBytes, n)
+}
+
+// ReleaseBytes releases n bytes reserved, e.g. for a write that failed.
+func (q *Quota) ReleaseBytes(n int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes -= n
+}
+
+// ReserveObject reserves an object about to be created. The returned error
+// wraps syscall.EDQUOT if it's beyond the quota.
+func (q *Quota) ReserveObject(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.reserve(ctx, quotaObjects, &q.objects, q.maxObjects, 1)
+}
+
+// ReleaseObject releases an object reserved, e.g. for a creation that failed.
+func (q *Quota) ReleaseObject() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.objects--
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writequota
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type quotaMetricHandle struct {
+	common.MetricHandle
+	exceeded map[string]int64
+}
+
+func (m *quotaMetricHandle) WriteQuotaExceededCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.exceeded[attrs[0].Value] += inc
+}
+
+func newTestQuota(maxBytes, maxObjects int64) (*Quota, *quotaMetricHandle) {
+	m := &quotaMetricHandle{MetricHandle: common.NewNoopMetrics(), exceeded: make(map[string]int64)}
+	return New(maxBytes, maxObjects, m), m
+}
+
+func TestNew_Unlimited(t *testing.T) {
+	q := New(0, 0, common.NewNoopMetrics())
+
+	assert.Nil(t, q)
+	assert.NoError(t, q.ReserveBytes(context.Background(), 1<<40))
+	assert.NoError(t, q.ReserveObject(context.Background()))
+	q.ReleaseBytes(1 << 40)
+	q.ReleaseObject()
+}
+
+func TestReserveBytes(t *testing.T) {
+	q, m := newTestQuota(100, 0)
+	ctx := context.Background()
+
+	require.NoError(t, q.ReserveBytes(ctx, 60))
+	require.NoError(t, q.ReserveBytes(ctx, 40))
+	err := q.ReserveBytes(ctx, 1)
+
+	assert.ErrorIs(t, err, syscall.EDQUOT)
+	assert.Equal(t, int64(1), m.exceeded["bytes"])
+	// The objects aren't capped.
+	assert.NoError(t, q.ReserveObject(ctx))
+}
+
+func TestReleaseBytes(t *testing.T) {
+	q, _ := newTestQuota(100, 0)
+	ctx := context.Background()
+	require.NoError(t, q.ReserveBytes(ctx, 100))
+
+	q.ReleaseBytes(30)
+
+	assert.NoError(t, q.ReserveBytes(ctx, 30))
+	assert.ErrorIs(t, q.ReserveBytes(ctx, 1), syscall.EDQUOT)
+}
+
+func TestReserveObject(t *testing.T) {
+	q, m := newTestQuota(0, 2)
+	ctx := context.Background()
+	require.NoError(t, q.ReserveObject(ctx))
+	require.NoError(t, q.ReserveObject(ctx))
+
+	assert.ErrorIs(t, q.ReserveObject(ctx), syscall.EDQUOT)
+	assert.ErrorIs(t, q.ReserveObject(ctx), syscall.EDQUOT)
+	assert.Equal(t, int64(2), m.exceeded["objects"])
+
+	q.ReleaseObject()
+	assert.NoError(t, q.ReserveObject(ctx))
+}
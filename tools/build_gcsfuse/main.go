@@ -32,6 +32,12 @@
 //
 //	bin/gcsfuse
 //	sbin/mount_gcsfuse
+//
+// Along with the version, the commit of src_dir, if it's a git repository, the
+// build time and the builder are embedded in gcsfuse, and printed by
+// `gcsfuse --version --json`. The builder is $GCSFUSE_BUILDER, or user@host by
+// default, and the build time is taken from $SOURCE_DATE_EPOCH, if set, for
+// reproducible builds.
 package main
 
 import (
@@ -42,9 +48,54 @@ import (
 	"os/exec"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const commonPkg = "github.com/googlecloudplatform/gcsfuse/v2/common"
+
+// ldflag returns the linker flag setting the supplied string variable of the
+// common package.
+func ldflag(name, value string) string {
+	return fmt.Sprintf("-X '%s.%s=%s'", commonPkg, name, strings.ReplaceAll(value, "'", ""))
+}
+
+// buildProvenance returns the linker flags embedding the commit, build time
+// and builder of the build of srcDir in gcsfuse.
+func buildProvenance(srcDir string) (flags []string, err error) {
+	// A tarball of the repository has no commit.
+	if output, err := exec.Command("git", "-C", srcDir, "rev-parse", "HEAD").Output(); err == nil {
+		flags = append(flags, ldflag("gcsfuseCommit", strings.TrimSpace(string(output))))
+	}
+
+	buildTime := time.Now()
+	if epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		var secs int64
+		secs, err = strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("parsing SOURCE_DATE_EPOCH: %w", err)
+			return
+		}
+		buildTime = time.Unix(secs, 0)
+	}
+	flags = append(flags, ldflag("gcsfuseBuildTime", buildTime.UTC().Format(time.RFC3339)))
+
+	builder, ok := os.LookupEnv("GCSFUSE_BUILDER")
+	if !ok {
+		var hostname string
+		hostname, err = os.Hostname()
+		if err != nil {
+			err = fmt.Errorf("hostname: %w", err)
+			return
+		}
+		builder = fmt.Sprintf("%s@%s", os.Getenv("USER"), hostname)
+	}
+	flags = append(flags, ldflag("gcsfuseBuilder", builder))
+
+	return
+}
+
 // Build release binaries according to the supplied settings, setting up the
 // the file system structure we desire (see package-level comments).
 //
@@ -112,6 +163,12 @@ func buildBinaries(dstDir, srcDir, version string, buildArgs []string) (err erro
 		mountHelperName = "mount.gcsfuse"
 	}
 
+	provenance, err := buildProvenance(srcDir)
+	if err != nil {
+		err = fmt.Errorf("buildProvenance: %w", err)
+		return
+	}
+
 	// Build the binaries.
 	binaries := []struct {
 		goTarget   string
@@ -143,7 +200,7 @@ func buildBinaries(dstDir, srcDir, version string, buildArgs []string) (err erro
 			cmd.Args = append(
 				cmd.Args,
 				"-ldflags",
-				fmt.Sprintf("-X github.com/googlecloudplatform/gcsfuse/v2/common.gcsfuseVersion=%s", version),
+				strings.Join(append([]string{ldflag("gcsfuseVersion", version)}, provenance...), " "),
 			)
 			cmd.Args = append(cmd.Args, buildArgs...)
 		}
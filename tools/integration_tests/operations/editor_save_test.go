@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Provides integration tests for the save patterns commonly used by editors
+// and config management tools to overwrite an existing file.
+package operations_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/client"
+	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/operations"
+	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/setup"
+)
+
+const editorSaveFileName = "config.yaml"
+const editorSaveOldContent = "key: old-value\n"
+const editorSaveNewContent = "key: new-value\nother-key: 1\n"
+
+// //////////////////////////////////////////////////////////////////////
+// Tests
+// //////////////////////////////////////////////////////////////////////
+
+func TestOverwriteWithOTruncIsNotVisibleInGCSUntilClose(t *testing.T) {
+	testDir := setup.SetupTestDirectory(DirForOperationTests)
+	filePath := path.Join(testDir, editorSaveFileName)
+	operations.CreateFileWithContent(filePath, setup.FilePermission_0600, editorSaveOldContent, t)
+
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, setup.FilePermission_0600)
+	if err != nil {
+		t.Fatalf("os.OpenFile(O_TRUNC): %v", err)
+	}
+	operations.WriteWithoutClose(f, editorSaveNewContent, t)
+
+	// Readers of the object must never observe the truncated, zero-length file:
+	// the old generation stays in place until the new one is flushed.
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveOldContent, t)
+	operations.CloseFileShouldNotThrowError(f, t)
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveNewContent, t)
+	setup.CompareFileContents(t, filePath, editorSaveNewContent)
+}
+
+func TestTruncateToZeroThenRewriteIsNotVisibleInGCSUntilClose(t *testing.T) {
+	testDir := setup.SetupTestDirectory(DirForOperationTests)
+	filePath := path.Join(testDir, editorSaveFileName)
+	operations.CreateFileWithContent(filePath, setup.FilePermission_0600, editorSaveOldContent, t)
+
+	f, err := os.OpenFile(filePath, os.O_RDWR, setup.FilePermission_0600)
+	if err != nil {
+		t.Fatalf("os.OpenFile: %v", err)
+	}
+	if err = f.Truncate(0); err != nil {
+		t.Fatalf("f.Truncate(0): %v", err)
+	}
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveOldContent, t)
+	operations.WriteAt(editorSaveNewContent, 0, f, t)
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveOldContent, t)
+
+	operations.CloseFileShouldNotThrowError(f, t)
+
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveNewContent, t)
+	setup.CompareFileContents(t, filePath, editorSaveNewContent)
+}
+
+func TestTruncateToZeroThenSyncWritesEmptyFile(t *testing.T) {
+	testDir := setup.SetupTestDirectory(DirForOperationTests)
+	filePath := path.Join(testDir, editorSaveFileName)
+	operations.CreateFileWithContent(filePath, setup.FilePermission_0600, editorSaveOldContent, t)
+
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, setup.FilePermission_0600)
+	if err != nil {
+		t.Fatalf("os.OpenFile(O_TRUNC): %v", err)
+	}
+	operations.CloseFileShouldNotThrowError(f, t)
+
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, "", t)
+	setup.CompareFileContents(t, filePath, "")
+}
+
+func TestSaveByRenamingTempFileOverExistingFile(t *testing.T) {
+	testDir := setup.SetupTestDirectory(DirForOperationTests)
+	filePath := path.Join(testDir, editorSaveFileName)
+	swapFileName := "." + editorSaveFileName + ".swp"
+	swapFilePath := path.Join(testDir, swapFileName)
+	operations.CreateFileWithContent(filePath, setup.FilePermission_0600, editorSaveOldContent, t)
+
+	operations.CreateFileWithContent(swapFilePath, setup.FilePermission_0600, editorSaveNewContent, t)
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveOldContent, t)
+	if err := os.Rename(swapFilePath, filePath); err != nil {
+		t.Fatalf("os.Rename(%q, %q): %v", swapFilePath, filePath, err)
+	}
+
+	client.ValidateObjectContentsFromGCS(ctx, storageClient, DirForOperationTests, editorSaveFileName, editorSaveNewContent, t)
+	client.ValidateObjectNotFoundErrOnGCS(ctx, storageClient, DirForOperationTests, swapFileName, t)
+	setup.CompareFileContents(t, filePath, editorSaveNewContent)
+}
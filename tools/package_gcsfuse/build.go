@@ -23,14 +23,16 @@ import (
 	"runtime"
 )
 
-// Build at the supplied commit (or branch or tag), embedding the given version
-// name and returning a path to a directory containing exactly the
-// root-relative file system structure we desire.
+// Build at the supplied commit (or branch or tag) for the supplied
+// architecture, embedding the given version name and returning a path to a
+// directory containing exactly the root-relative file system structure we
+// desire.
 func build(
 	commit string,
 	version string,
-	osys string) (dir string, err error) {
-	log.Printf("Building version %s from %s.", version, commit)
+	osys string,
+	arch string) (dir string, err error) {
+	log.Printf("Building version %s from %s for %s.", version, commit, arch)
 
 	// Create a directory to become GOCACHE below.
 	var gocache string
@@ -120,6 +122,13 @@ func build(
 			dir,
 			version)
 
+		// build_gcsfuse disables cgo, so the binaries can be cross-compiled.
+		cmd.Env = append(
+			os.Environ(),
+			fmt.Sprintf("GOOS=%s", osys),
+			fmt.Sprintf("GOARCH=%s", arch),
+		)
+
 		var output []byte
 		output, err = cmd.CombinedOutput()
 		if err != nil {
@@ -17,14 +17,19 @@
 //
 // Usage:
 //
-//	package_gcsfuse dst_dir version [commit]
+//	package_gcsfuse [-arch amd64|arm64] [-static] dst_dir version [commit]
 //
 // This will cause the gcsfuse git repo to be cloned to a temporary location
 // and a build performed, embedding the given version name. The build will be
 // performed at the given commit (or branch or tag), which defaults to
 // `v<version>`.
 //
-// .deb and .rpm files will be written to dst_dir.
+// .deb and .rpm files will be written to dst_dir, for the architecture given by
+// -arch, which defaults to the one of the machine. With -static, a
+// gcsfuse_<version>_<arch>_static.tar.gz archive of the binaries is also
+// written, for the images without the packages or glibc, e.g. distroless or
+// Alpine ones. The binaries are built with cgo disabled, so they're statically
+// linked and run with any libc, which is checked before packaging them.
 package main
 
 import (
@@ -35,9 +40,16 @@ import (
 	"runtime"
 )
 
+var fArch = flag.String("arch", runtime.GOARCH, "The architecture to build the packages for: amd64 or arm64.")
+var fStatic = flag.Bool("static", false, "Also write an archive of the statically linked binaries.")
+
 func run(args []string) (err error) {
 	osys := runtime.GOOS
-	arch := runtime.GOARCH
+	arch := *fArch
+	if arch != "amd64" && arch != "arm64" {
+		err = fmt.Errorf("unsupported architecture %q: it should be amd64 or arm64", arch)
+		return
+	}
 
 	// Extract arguments.
 	if len(args) < 2 || len(args) > 3 {
@@ -57,6 +69,8 @@ func run(args []string) (err error) {
 	log.Printf("  dstDir:  %s", dstDir)
 	log.Printf("  commit:  %s", commit)
 	log.Printf("  version: %s", version)
+	log.Printf("  arch:    %s", arch)
+	log.Printf("  static:  %t", *fStatic)
 
 	// Ensure that all of the tools we need are present.
 	err = checkForTools()
@@ -65,7 +79,7 @@ func run(args []string) (err error) {
 	}
 
 	// Assemble binaries, mount(8) helper scripts, etc.
-	buildDir, err := build(commit, version, osys)
+	buildDir, err := build(commit, version, osys, arch)
 	if err != nil {
 		err = fmt.Errorf("build: %w", err)
 		return
@@ -86,6 +100,14 @@ func run(args []string) (err error) {
 			err = fmt.Errorf("packageDeb: %w", err)
 			return
 		}
+
+		if *fStatic {
+			err = packageStatic(buildDir, version, arch, dstDir)
+			if err != nil {
+				err = fmt.Errorf("packageStatic: %w", err)
+				return
+			}
+		}
 	}
 
 	return
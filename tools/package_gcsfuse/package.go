@@ -15,11 +15,33 @@
 package main
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"debug/elf"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 )
 
+// fpmArch returns the name of the supplied Go architecture in packages of the
+// supplied type.
+func fpmArch(packageType string, arch string) string {
+	if packageType == "rpm" {
+		switch arch {
+		case "amd64":
+			return "x86_64"
+		case "arm64":
+			return "aarch64"
+		}
+	}
+	return arch
+}
+
 func packageFpm(
 	packageType string,
 	binDir string,
@@ -35,6 +57,7 @@ func packageFpm(
 		"-n", "gcsfuse",
 		"-C", binDir,
 		"-v", version,
+		"-a", fpmArch(packageType, arch),
 		"-d", "fuse",
 		"--vendor", "",
 		"--maintainer", "GCSFuse dev <gcs-fuse-dev@google.com>",
@@ -80,3 +103,131 @@ func packageRpm(
 	err = packageFpm("rpm", binDir, version, osys, arch, outputDir)
 	return
 }
+
+// checkStatic returns an error if the supplied binary is dynamically linked,
+// i.e. it requests an interpreter or shared libraries.
+func checkStatic(binPath string) (err error) {
+	f, err := elf.Open(binPath)
+	if err != nil {
+		err = fmt.Errorf("elf.Open: %w", err)
+		return
+	}
+	defer f.Close()
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			err = fmt.Errorf("%s is dynamically linked", binPath)
+			return
+		}
+	}
+
+	libs, err := f.ImportedLibraries()
+	if err != nil {
+		err = fmt.Errorf("ImportedLibraries: %w", err)
+		return
+	}
+	if len(libs) > 0 {
+		err = fmt.Errorf("%s is linked with %v", binPath, libs)
+		return
+	}
+
+	return
+}
+
+// Given a directory containing release binaries, check that they're statically
+// linked and write them to a gcsfuse_<version>_<arch>_static.tar.gz archive.
+func packageStatic(
+	binDir string,
+	version string,
+	arch string,
+	outputDir string) (err error) {
+	log.Println("Building a static .tar.gz archive.")
+
+	for _, bin := range []string{"usr/bin/gcsfuse", "sbin/mount.gcsfuse"} {
+		err = checkStatic(path.Join(binDir, bin))
+		if err != nil {
+			return
+		}
+	}
+
+	out, err := os.Create(path.Join(outputDir, fmt.Sprintf("gcsfuse_%s_%s_static.tar.gz", version, arch)))
+	if err != nil {
+		err = fmt.Errorf("Create: %w", err)
+		return
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("Close: %w", closeErr)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(binDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == binDir {
+			return err
+		}
+		return addToTar(tw, binDir, p, d)
+	})
+	if err != nil {
+		err = fmt.Errorf("WalkDir: %w", err)
+		return
+	}
+
+	err = tw.Close()
+	if err != nil {
+		err = fmt.Errorf("tar Close: %w", err)
+		return
+	}
+
+	err = gz.Close()
+	if err != nil {
+		err = fmt.Errorf("gzip Close: %w", err)
+		return
+	}
+
+	return
+}
+
+// addToTar writes the supplied file, directory or symlink under binDir to the
+// archive, by its path relative to binDir.
+func addToTar(tw *tar.Writer, binDir string, p string, d fs.DirEntry) (err error) {
+	info, err := d.Info()
+	if err != nil {
+		return
+	}
+
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err = os.Readlink(p)
+		if err != nil {
+			return
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return
+	}
+	hdr.Name, err = filepath.Rel(binDir, p)
+	if err != nil {
+		return
+	}
+	if d.IsDir() {
+		hdr.Name += "/"
+	}
+
+	err = tw.WriteHeader(hdr)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return
+}
@@ -0,0 +1,710 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// A check verifies one POSIX behavior in an empty directory of the file system
+// under test, returning nil if the file system provides it.
+type check struct {
+	id          string
+	category    string
+	description string
+	run         func(dir string) error
+}
+
+var testContent = []byte("0123456789")
+
+// checks are the POSIX behaviors verified by the tool, in the order of the
+// report.
+var checks = []check{
+	// Files.
+	{"file.create_excl", "file", "O_CREAT|O_EXCL fails with EEXIST on an existing file", checkCreateExcl},
+	{"file.read_after_close", "file", "The data written to a closed file is read back", checkReadAfterClose},
+	{"file.read_own_writes", "file", "The data written to an open file is read back through the same descriptor", checkReadOwnWrites},
+	{"file.append", "file", "Writes with O_APPEND are appended to the existing content", checkAppend},
+	{"file.overwrite_at_offset", "file", "Writes at an offset of an existing file overwrite its content in place", checkOverwriteAtOffset},
+	{"file.sparse_write", "file", "Writes beyond the end of a file leave a hole of zeros", checkSparseWrite},
+	{"file.truncate_shrink", "file", "truncate(2) shrinks a file", checkTruncateShrink},
+	{"file.truncate_extend", "file", "ftruncate(2) extends a file with zeros", checkTruncateExtend},
+	{"file.open_trunc", "file", "O_TRUNC empties an existing file", checkOpenTrunc},
+	{"file.fsync", "file", "fsync(2) succeeds on a written file", checkFsync},
+	{"file.unlink_open", "file", "An unlinked file stays readable through its open descriptors", checkUnlinkOpen},
+	{"file.mmap_shared", "file", "Writes through a shared mapping are read back after msync(2)", checkMmapShared},
+
+	// Directories.
+	{"dir.mkdir_rmdir", "directory", "mkdir(2) creates a directory which rmdir(2) removes", checkMkdirRmdir},
+	{"dir.mkdir_exists", "directory", "mkdir(2) fails with EEXIST on an existing entry", checkMkdirExists},
+	{"dir.rmdir_not_empty", "directory", "rmdir(2) fails with ENOTEMPTY or EEXIST on a non-empty directory", checkRmdirNotEmpty},
+	{"dir.unlink_dir", "directory", "unlink(2) fails with EISDIR or EPERM on a directory", checkUnlinkDir},
+	{"dir.readdir", "directory", "readdir(3) lists exactly the entries of a directory", checkReaddir},
+	{"dir.nlink", "directory", "A directory has a link count of at least 2", checkDirNlink},
+
+	// Renames.
+	{"rename.file", "rename", "rename(2) moves a file", checkRenameFile},
+	{"rename.file_replace", "rename", "rename(2) atomically replaces an existing file", checkRenameFileReplace},
+	{"rename.dir", "rename", "rename(2) moves a non-empty directory with its content", checkRenameDir},
+	{"rename.dir_onto_non_empty", "rename", "rename(2) of a directory fails with ENOTEMPTY or EEXIST onto a non-empty directory", checkRenameDirOntoNonEmpty},
+
+	// Links.
+	{"link.hard", "link", "link(2) creates a hard link sharing the content of the file", checkHardLink},
+	{"link.symlink", "link", "symlink(2) creates a symbolic link which readlink(2) reads", checkSymlink},
+
+	// Metadata.
+	{"metadata.chmod", "metadata", "chmod(2) changes the permissions of a file", checkChmod},
+	{"metadata.chown", "metadata", "chown(2) to the owner of a file succeeds", checkChown},
+	{"metadata.utimes", "metadata", "utimes(2) sets the modification time of a file", checkUtimes},
+	{"metadata.mtime_on_write", "metadata", "Writes update the modification time of a file", checkMtimeOnWrite},
+	{"metadata.size_while_open", "metadata", "stat(2) reports the size of the data written to a file still open", checkSizeWhileOpen},
+
+	// Locks.
+	{"lock.flock", "lock", "flock(2) takes an exclusive lock on a file", checkFlock},
+	{"lock.fcntl", "lock", "fcntl(2) takes an exclusive record lock on a file", checkFcntlLock},
+
+	// Extended attributes.
+	{"xattr.user", "xattr", "setxattr(2) sets a user extended attribute which getxattr(2) reads", checkUserXattr},
+
+	// Special files.
+	{"special.fifo", "special", "mkfifo(3) creates a named pipe", checkFifo},
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// expectContent returns an error if the content of the file isn't expected.
+func expectContent(path string, expected []byte) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(content, expected) {
+		return fmt.Errorf("content of %s is %q, want %q", filepath.Base(path), content, expected)
+	}
+	return nil
+}
+
+// expectErrno returns an error unless err is one of the errnos.
+func expectErrno(op string, err error, errnos ...unix.Errno) error {
+	if err == nil {
+		return fmt.Errorf("%s succeeded, want one of %v", op, errnos)
+	}
+	for _, errno := range errnos {
+		if errors.Is(err, errno) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s failed with %w, want one of %v", op, err, errnos)
+}
+
+func expectNotExist(path string) error {
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s still exists: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeFile writes the content to a new file and closes it.
+func writeFile(path string, content []byte) error {
+	return os.WriteFile(path, content, 0644)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Files
+////////////////////////////////////////////////////////////////////////
+
+func checkCreateExcl(dir string) error {
+	path := filepath.Join(dir, "file")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	_, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	return expectErrno("second open", err, unix.EEXIST)
+}
+
+func checkReadAfterClose(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	return expectContent(path, testContent)
+}
+
+func checkReadOwnWrites(dir string) error {
+	f, err := os.OpenFile(filepath.Join(dir, "file"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(testContent); err != nil {
+		return err
+	}
+	buf := make([]byte, len(testContent))
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	if !bytes.Equal(buf, testContent) {
+		return fmt.Errorf("read %q, want %q", buf, testContent)
+	}
+	return nil
+}
+
+func checkAppend(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write([]byte("abc")); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, []byte("0123456789abc"))
+}
+
+func checkOverwriteAtOffset(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt([]byte("abc"), 4); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, []byte("0123abc789"))
+}
+
+func checkSparseWrite(dir string) error {
+	path := filepath.Join(dir, "file")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt([]byte("abc"), 5); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, []byte("\x00\x00\x00\x00\x00abc"))
+}
+
+func checkTruncateShrink(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	if err := os.Truncate(path, 4); err != nil {
+		return err
+	}
+	return expectContent(path, testContent[:4])
+}
+
+func checkTruncateExtend(dir string) error {
+	path := filepath.Join(dir, "file")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write([]byte("ab")); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Truncate(4); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, []byte("ab\x00\x00"))
+}
+
+func checkOpenTrunc(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_TRUNC|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, nil)
+}
+
+func checkFsync(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(testContent); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func checkUnlinkOpen(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = os.Remove(path); err != nil {
+		return err
+	}
+	buf := make([]byte, len(testContent))
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("read after unlink: %w", err)
+	}
+	if !bytes.Equal(buf, testContent) {
+		return fmt.Errorf("read %q after unlink, want %q", buf, testContent)
+	}
+	return expectNotExist(path)
+}
+
+func checkMmapShared(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mapped, err := unix.Mmap(int(f.Fd()), 0, len(testContent), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mmap: %w", err)
+	}
+	copy(mapped, "abc")
+	if err = unix.Msync(mapped, unix.MS_SYNC); err != nil {
+		unix.Munmap(mapped)
+		return fmt.Errorf("msync: %w", err)
+	}
+	if err = unix.Munmap(mapped); err != nil {
+		return fmt.Errorf("munmap: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return expectContent(path, []byte("abc3456789"))
+}
+
+////////////////////////////////////////////////////////////////////////
+// Directories
+////////////////////////////////////////////////////////////////////////
+
+func checkMkdirRmdir(dir string) error {
+	path := filepath.Join(dir, "dir")
+	if err := os.Mkdir(path, 0755); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("mode of the directory is %v", fi.Mode())
+	}
+	if err = unix.Rmdir(path); err != nil {
+		return err
+	}
+	return expectNotExist(path)
+}
+
+func checkMkdirExists(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	return expectErrno("mkdir", os.Mkdir(path, 0755), unix.EEXIST)
+}
+
+func checkRmdirNotEmpty(dir string) error {
+	path := filepath.Join(dir, "dir")
+	if err := os.Mkdir(path, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(path, "file"), testContent); err != nil {
+		return err
+	}
+	return expectErrno("rmdir", unix.Rmdir(path), unix.ENOTEMPTY, unix.EEXIST)
+}
+
+func checkUnlinkDir(dir string) error {
+	path := filepath.Join(dir, "dir")
+	if err := os.Mkdir(path, 0755); err != nil {
+		return err
+	}
+	return expectErrno("unlink", unix.Unlink(path), unix.EISDIR, unix.EPERM)
+}
+
+func checkReaddir(dir string) error {
+	expected := []string{"a", "b", "c"}
+	for _, name := range expected {
+		if err := writeFile(filepath.Join(dir, name), testContent); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "b")); err != nil {
+		return err
+	}
+	expected = []string{"a", "c"}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		return fmt.Errorf("listed %v, want %v", names, expected)
+	}
+	return nil
+}
+
+func checkDirNlink(dir string) error {
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		return err
+	}
+	if st.Nlink < 2 {
+		return fmt.Errorf("link count is %d", st.Nlink)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Renames
+////////////////////////////////////////////////////////////////////////
+
+func checkRenameFile(dir string) error {
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := writeFile(oldPath, testContent); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := expectNotExist(oldPath); err != nil {
+		return err
+	}
+	return expectContent(newPath, testContent)
+}
+
+func checkRenameFileReplace(dir string) error {
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := writeFile(oldPath, testContent); err != nil {
+		return err
+	}
+	if err := writeFile(newPath, []byte("replaced")); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := expectNotExist(oldPath); err != nil {
+		return err
+	}
+	return expectContent(newPath, testContent)
+}
+
+func checkRenameDir(dir string) error {
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := os.MkdirAll(filepath.Join(oldPath, "sub"), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(oldPath, "sub", "file"), testContent); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := expectNotExist(oldPath); err != nil {
+		return err
+	}
+	return expectContent(filepath.Join(newPath, "sub", "file"), testContent)
+}
+
+func checkRenameDirOntoNonEmpty(dir string) error {
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := os.Mkdir(oldPath, 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(newPath, 0755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(newPath, "file"), testContent); err != nil {
+		return err
+	}
+	return expectErrno("rename", os.Rename(oldPath, newPath), unix.ENOTEMPTY, unix.EEXIST)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Links
+////////////////////////////////////////////////////////////////////////
+
+func checkHardLink(dir string) error {
+	path, linkPath := filepath.Join(dir, "file"), filepath.Join(dir, "link")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	if err := os.Link(path, linkPath); err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return err
+	}
+	if st.Nlink != 2 {
+		return fmt.Errorf("link count is %d, want 2", st.Nlink)
+	}
+	return expectContent(linkPath, testContent)
+}
+
+func checkSymlink(dir string) error {
+	linkPath := filepath.Join(dir, "link")
+	if err := os.Symlink("target", linkPath); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(linkPath)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Type() != os.ModeSymlink {
+		return fmt.Errorf("mode of the link is %v", fi.Mode())
+	}
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return err
+	}
+	if target != "target" {
+		return fmt.Errorf("readlink returned %q, want %q", target, "target")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Metadata
+////////////////////////////////////////////////////////////////////////
+
+func checkChmod(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm() != 0600 {
+		return fmt.Errorf("permissions are %v, want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+	return nil
+}
+
+func checkChown(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return err
+	}
+	return os.Chown(path, int(st.Uid), int(st.Gid))
+}
+
+func checkUtimes(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	mtime := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.ModTime().Equal(mtime) {
+		return fmt.Errorf("modification time is %v, want %v", fi.ModTime().UTC(), mtime)
+	}
+	return nil
+}
+
+func checkMtimeOnWrite(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt([]byte("abc"), 0); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.ModTime().After(past) {
+		return fmt.Errorf("modification time %v wasn't updated", fi.ModTime())
+	}
+	return nil
+}
+
+func checkSizeWhileOpen(dir string) error {
+	path := filepath.Join(dir, "file")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(testContent); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != int64(len(testContent)) {
+		return fmt.Errorf("size is %d, want %d", fi.Size(), len(testContent))
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Locks
+////////////////////////////////////////////////////////////////////////
+
+func checkFlock(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		return err
+	}
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+func checkFcntlLock(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+	if err = unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock); err != nil {
+		return err
+	}
+	lock.Type = unix.F_UNLCK
+	return unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Extended attributes
+////////////////////////////////////////////////////////////////////////
+
+func checkUserXattr(dir string) error {
+	path := filepath.Join(dir, "file")
+	if err := writeFile(path, testContent); err != nil {
+		return err
+	}
+	if err := unix.Setxattr(path, "user.posix_compliance", []byte("value"), 0); err != nil {
+		return err
+	}
+	buf := make([]byte, 64)
+	n, err := unix.Getxattr(path, "user.posix_compliance", buf)
+	if err != nil {
+		return err
+	}
+	if string(buf[:n]) != "value" {
+		return fmt.Errorf("getxattr returned %q, want %q", buf[:n], "value")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Special files
+////////////////////////////////////////////////////////////////////////
+
+func checkFifo(dir string) error {
+	path := filepath.Join(dir, "fifo")
+	if err := unix.Mkfifo(path, 0644); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Type() != os.ModeNamedPipe {
+		return fmt.Errorf("mode of the pipe is %v", fi.Mode())
+	}
+	return nil
+}
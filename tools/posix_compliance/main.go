@@ -0,0 +1,149 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reports which POSIX behaviors a gcsfuse mount provides with a given config
+//
+// Usage:
+//
+//	posix_compliance [--format json|csv|text] [--output file] bucket_name [gcsfuse_flags...]
+//	posix_compliance [--format json|csv|text] [--output file] --mount-point dir
+//
+// The first form mounts the bucket in a temporary directory by running gcsfuse
+// (see --gcsfuse) with the given flags, e.g. --config-file=config.yaml, and
+// unmounts it afterwards. The second form uses an existing mount.
+//
+// A check is run for each behavior, in the style of pjdfstest, in a new
+// directory of the mount which is removed afterwards, and the result of each
+// check is written as a compliance matrix. The tool exits with status 0 even if
+// checks fail, so that the matrix of a config can be compared with another's.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/tools/util"
+)
+
+var (
+	fMountPoint = flag.String("mount-point", "", "An existing mount to check, instead of mounting a bucket.")
+	fGcsfuse    = flag.String("gcsfuse", "gcsfuse", "The gcsfuse binary mounting the bucket.")
+	fFormat     = flag.String("format", "json", "The format of the report: json, csv or text.")
+	fOutput     = flag.String("output", "", "The file the report is written to, instead of stdout.")
+)
+
+// mount mounts the bucket in a new temporary directory with gcsfuse run with
+// the flags, returning the directory and a function unmounting the bucket and
+// removing the directory.
+func mount(gcsfuse string, bucketName string, flags []string) (mountPoint string, unmount func(), err error) {
+	mountPoint, err = os.MkdirTemp("", "posix_compliance")
+	if err != nil {
+		err = fmt.Errorf("MkdirTemp: %w", err)
+		return
+	}
+
+	args := append(append([]string{}, flags...), bucketName, mountPoint)
+	log.Printf("Mounting: %s %v", gcsfuse, args)
+	cmd := exec.Command(gcsfuse, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// gcsfuse returns once the bucket is mounted, unless it's run in the
+	// foreground.
+	if err = cmd.Run(); err != nil {
+		os.Remove(mountPoint)
+		err = fmt.Errorf("running gcsfuse: %w", err)
+		return
+	}
+
+	unmount = func() {
+		if err := util.Unmount(mountPoint); err != nil {
+			log.Printf("Unmount: %v", err)
+			return
+		}
+		os.Remove(mountPoint)
+	}
+	return
+}
+
+func run(args []string) (err error) {
+	switch *fFormat {
+	case "json", "csv", "text":
+	default:
+		err = fmt.Errorf("unsupported format %q", *fFormat)
+		return
+	}
+
+	var mountPoint string
+	var gcsfuseArgs []string
+	switch {
+	case *fMountPoint != "" && len(args) == 0:
+		mountPoint = *fMountPoint
+	case *fMountPoint == "" && len(args) > 0:
+		var unmount func()
+		mountPoint, unmount, err = mount(*fGcsfuse, args[0], args[1:])
+		if err != nil {
+			return
+		}
+		defer unmount()
+		gcsfuseArgs = args[1:]
+	default:
+		err = fmt.Errorf("usage: %s [flags] (bucket_name [gcsfuse_flags...] | --mount-point dir)", os.Args[0])
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	if *fOutput != "" {
+		var f *os.File
+		f, err = os.Create(*fOutput)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+
+	dir := filepath.Join(mountPoint, fmt.Sprintf("posix_compliance_%d", time.Now().UnixNano()))
+	if err = os.Mkdir(dir, 0755); err != nil {
+		return
+	}
+	log.Printf("Running %d checks in %s", len(checks), dir)
+	results := runChecks(dir, checks)
+	if removeErr := os.RemoveAll(dir); removeErr != nil {
+		log.Printf("Removing %s: %v", dir, removeErr)
+	}
+
+	err = newReport(mountPoint, gcsfuseArgs, results).write(w, *fFormat)
+	return
+}
+
+func main() {
+	log.SetFlags(log.Lmicroseconds)
+	flag.Parse()
+
+	err := run(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
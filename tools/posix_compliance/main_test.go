@@ -0,0 +1,100 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecks_UniqueIDs(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, c := range checks {
+		assert.False(t, ids[c.id], "duplicate id %s", c.id)
+		ids[c.id] = true
+		assert.True(t, strings.HasPrefix(c.id, c.category[:3]), "id %s doesn't match category %s", c.id, c.category)
+	}
+}
+
+func TestRunChecks_LocalFileSystem(t *testing.T) {
+	dir := t.TempDir()
+
+	results := runChecks(dir, checks)
+
+	require.Len(t, results, len(checks))
+	for _, r := range results {
+		// The local file system may not support user extended attributes.
+		if r.Category == "xattr" {
+			continue
+		}
+		assert.Equal(t, statusPass, r.Status, "%s: %s", r.ID, r.Error)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRunChecks_Failure(t *testing.T) {
+	failing := []check{
+		{"file.ok", "file", "Passes", func(string) error { return nil }},
+		{"file.broken", "file", "Fails", func(string) error { return errors.New("broken") }},
+	}
+
+	results := runChecks(t.TempDir(), failing)
+
+	assert.Equal(t, []Result{
+		{ID: "file.ok", Category: "file", Description: "Passes", Status: statusPass},
+		{ID: "file.broken", Category: "file", Description: "Fails", Status: statusFail, Error: "broken"},
+	}, results)
+}
+
+func TestReport_JSON(t *testing.T) {
+	r := newReport("/mnt", []string{"--implicit-dirs"}, []Result{
+		{ID: "file.ok", Category: "file", Description: "Passes", Status: statusPass},
+		{ID: "file.broken", Category: "file", Description: "Fails", Status: statusFail, Error: "broken"},
+	})
+	var buf bytes.Buffer
+
+	require.NoError(t, r.write(&buf, "json"))
+
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *r, decoded)
+	assert.Equal(t, Summary{Total: 2, Passed: 1, Failed: 1}, decoded.Summary)
+}
+
+func TestReport_CSV(t *testing.T) {
+	r := newReport("/mnt", nil, []Result{
+		{ID: "file.broken", Category: "file", Description: "Fails", Status: statusFail, Error: "broken, badly"},
+	})
+	var buf bytes.Buffer
+
+	require.NoError(t, r.write(&buf, "csv"))
+
+	assert.Equal(t, "id,category,status,description,error\nfile.broken,file,fail,Fails,\"broken, badly\"\n", buf.String())
+}
+
+func TestReport_UnsupportedFormat(t *testing.T) {
+	r := newReport("/mnt", nil, nil)
+
+	assert.ErrorContains(t, r.write(&bytes.Buffer{}, "xml"), "unsupported format")
+}
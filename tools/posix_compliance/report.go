@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+)
+
+// Result is the outcome of a check in the compliance matrix.
+type Result struct {
+	ID          string `json:"id"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	// Error is why the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Summary counts the results by status.
+type Summary struct {
+	Total  int `json:"total"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+}
+
+// Report is the compliance matrix of a mount.
+type Report struct {
+	MountPoint string `json:"mount_point"`
+	// GcsfuseArgs are the arguments gcsfuse was run with, if the tool mounted
+	// the bucket itself.
+	GcsfuseArgs []string `json:"gcsfuse_args,omitempty"`
+	Results     []Result `json:"results"`
+	Summary     Summary  `json:"summary"`
+}
+
+// runChecks runs the checks, each in a new directory under dir which is
+// removed afterwards.
+func runChecks(dir string, checks []check) (results []Result) {
+	for _, c := range checks {
+		r := Result{
+			ID:          c.id,
+			Category:    c.category,
+			Description: c.description,
+			Status:      statusPass,
+		}
+
+		checkDir := filepath.Join(dir, c.id)
+		err := os.Mkdir(checkDir, 0755)
+		if err == nil {
+			err = c.run(checkDir)
+		} else {
+			err = fmt.Errorf("creating the directory of the check: %w", err)
+		}
+		if err != nil {
+			r.Status = statusFail
+			r.Error = err.Error()
+		}
+		// The check failing doesn't matter here: whatever it leaves behind is
+		// removed with the directory of the tool.
+		_ = os.RemoveAll(checkDir)
+
+		results = append(results, r)
+	}
+	return
+}
+
+func newReport(mountPoint string, gcsfuseArgs []string, results []Result) *Report {
+	r := &Report{
+		MountPoint:  mountPoint,
+		GcsfuseArgs: gcsfuseArgs,
+		Results:     results,
+	}
+	for _, result := range results {
+		r.Summary.Total++
+		if result.Status == statusPass {
+			r.Summary.Passed++
+		} else {
+			r.Summary.Failed++
+		}
+	}
+	return r
+}
+
+// writeJSON writes the report as an indented JSON object.
+func (r *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCSV writes a header and a row per result.
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "category", "status", "description", "error"}); err != nil {
+		return err
+	}
+	for _, result := range r.Results {
+		if err := cw.Write([]string{result.ID, result.Category, result.Status, result.Description, result.Error}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeText writes a table of the results for humans, followed by the
+// summary.
+func (r *Report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tDESCRIPTION")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.ID, result.Status, result.Description)
+		if result.Error != "" {
+			fmt.Fprintf(tw, "\t\t  %s\n", result.Error)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d checks: %d passed, %d failed\n", r.Summary.Total, r.Summary.Passed, r.Summary.Failed)
+	return err
+}
+
+// write writes the report in the format, one of json, csv or text.
+func (r *Report) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.writeJSON(w)
+	case "csv":
+		return r.writeCSV(w)
+	case "text":
+		return r.writeText(w)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}